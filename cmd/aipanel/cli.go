@@ -3,6 +3,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
@@ -535,6 +536,7 @@ var providerChoices = []struct{ key, label string }{
 	{"openai", "OpenAI (GPT)"},
 	{"deepseek", "DeepSeek"},
 	{"openrouter", "OpenRouter"},
+	{"gemini", "Google Gemini"},
 	{"zhipu", "智谱 AI (GLM)"},
 	{"kimi", "月之暗面 (Kimi)"},
 	{"minimax", "MiniMax"},
@@ -712,6 +714,14 @@ func menuProviderDelete(cfg *config.Config, configPath string) {
 }
 
 func testAPIKey(provider, apiKey, baseURL string) (bool, string) {
+	if provider == "gemini" {
+		// Gemini 原生 API 无 Bearer /v1/models，复用 llm 的健康探测
+		r := llm.Ping(context.Background(), provider, apiKey, baseURL, true)
+		if r.OK {
+			return true, "连接成功 (200)"
+		}
+		return false, "连接失败: " + r.Error
+	}
 	// 复用 HTTP 测试逻辑（直接实现，不依赖 api 包）
 	defaults := map[string]string{
 		"anthropic":  "https://api.anthropic.com",
@@ -1791,6 +1801,22 @@ func printSubcmdHelp() {
   zyhive backup inspect --input FILE
   zyhive backup restore --input FILE --yes [--no-service] [--config FILE] [--workdir DIR]
 
+MCP 服务（stdio，供 IDE / 外部 Agent 调用）：
+  zyhive mcp [--token TOKEN]     令牌为管理令牌或 mcpServe.tokens 中的受限令牌
+                                 （也可用环境变量 ZYHIVE_MCP_TOKEN）
+  HTTP 版本：网关 POST /mcp，Authorization: Bearer <TOKEN>
+
+用量计费：
+  zyhive usage reprice [--from YYYY-MM-DD] [--to YYYY-MM-DD]
+                                 按当前价格表重新计算历史用量费用
+
+离线评测：
+  zyhive eval run --dataset ID [--agent ID] [--model ID|scripted] [--label TEXT]
+                  [--case ID,...] [--compare RUN_ID]
+                                 回放评测集并打分；有用例未通过时退出码为 1
+  zyhive eval compare BASE_RUN_ID HEAD_RUN_ID
+                                 并排对比两次运行（修复 / 回退）
+
 服务以 --serve 标志直接启动（systemd/launchd 使用）：
   zyhive --serve --config /etc/zyhive/zyhive.json
 
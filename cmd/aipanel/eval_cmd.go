@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/eval"
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/tools"
+	"github.com/Zyling-ai/zyhive/pkg/usage"
+)
+
+// errEvalFailed makes `eval run` exit non-zero when any case fails, so
+// the command can gate CI.
+var errEvalFailed = errors.New("eval: some cases did not pass")
+
+// runEvalCommand implements the `eval` subcommand:
+//
+//	zyhive [--config FILE] eval run --dataset ID [--agent ID] [--model ID|scripted]
+//	                                [--label TEXT] [--case ID,...] [--compare RUN_ID]
+//	zyhive [--config FILE] eval compare BASE_RUN_ID HEAD_RUN_ID
+//
+// Datasets live in {agentsDir}/.evals/datasets/{id}.json (or are created
+// via POST /api/evals/datasets). --model scripted replays each case's
+// canned script instead of calling a provider, for offline CI.
+func runEvalCommand(configPath string, args []string) error {
+	const usageLine = "usage: zyhive eval run --dataset ID [--agent ID] [--model ID|scripted] [--label TEXT] [--case ID,...] [--compare RUN_ID]\n" +
+		"       zyhive eval compare BASE_RUN_ID HEAD_RUN_ID"
+	if len(args) == 0 {
+		return errors.New(usageLine)
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("load config %s: %w", configPath, err)
+	}
+	agentsDir := cfg.Agents.Dir
+	if agentsDir == "" {
+		agentsDir = "./agents"
+	}
+	if abs, err := filepath.Abs(agentsDir); err == nil {
+		agentsDir = abs
+	}
+	store := eval.NewStore(agentsDir)
+
+	switch args[0] {
+	case "compare":
+		if len(args) != 3 {
+			return errors.New(usageLine)
+		}
+		return printEvalComparison(store, args[1], args[2])
+	case "run":
+	default:
+		return errors.New(usageLine)
+	}
+
+	fs := flag.NewFlagSet("eval run", flag.ContinueOnError)
+	datasetID := fs.String("dataset", "", "dataset id (required)")
+	agentID := fs.String("agent", "", "agent id; default = dataset agentId")
+	model := fs.String("model", "", `model id from config, or "scripted" for offline replay; default = agent model`)
+	label := fs.String("label", "", "free-text run label")
+	cases := fs.String("case", "", "comma-separated case ids to run; default = all")
+	compareTo := fs.String("compare", "", "run id to compare the new run against")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *datasetID == "" {
+		return errors.New(usageLine)
+	}
+	ds, err := store.GetDataset(*datasetID)
+	if err != nil {
+		return fmt.Errorf("dataset %s: %w", *datasetID, err)
+	}
+	defer tools.CloseBackgroundProcesses()
+
+	mgr := agent.NewManager(agentsDir)
+	if err := mgr.LoadAll(); err != nil {
+		log.Printf("Warning: failed to load agents: %v", err)
+	}
+	closeTracing := setupTracing(cfg.Tracing, agentsDir)
+	defer closeTracing()
+	pool := agent.NewPool(cfg, mgr)
+	defer pool.CloseBrowser()
+	if *model != eval.ScriptedModel {
+		usageStore := usage.NewStore(agentsDir)
+		pool.SetUsageStore(usageStore)
+		if pricer, err := usage.NewPricer(usageStore.PricingPath()); err == nil {
+			usage.SetDefaultPricer(pricer)
+		}
+		if t := buildLLMThrottle(cfg.Throttle); t != nil {
+			llm.SetGlobalThrottle(t)
+		}
+	}
+
+	var grader eval.Grader
+	if scorer, err := eval.NewJudge(cfg, ""); err != nil {
+		log.Printf("Warning: llm_judge disabled: %v", err)
+	} else {
+		grader.Judge = scorer
+	}
+	opts := eval.RunOpts{AgentID: *agentID, Model: *model, Label: *label}
+	if *cases != "" {
+		opts.CaseIDs = strings.Split(*cases, ",")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	run := eval.NewHarness(pool, &grader).Run(ctx, ds, opts)
+	if err := store.SaveRun(run); err != nil {
+		log.Printf("Warning: save run: %v", err)
+	}
+
+	for _, c := range run.Cases {
+		mark := "✅"
+		switch {
+		case c.Error != "":
+			mark = "💥"
+		case !c.Pass:
+			mark = "❌"
+		}
+		fmt.Printf("%s %s", mark, c.CaseID)
+		if c.Error != "" {
+			fmt.Printf("  %s", c.Error)
+		}
+		fmt.Println()
+		for _, g := range c.Grades {
+			if !g.Pass {
+				fmt.Printf("     %s: %s\n", g.Type, g.Detail)
+			}
+		}
+	}
+	s := run.Summary
+	fmt.Printf("\n运行 %s：%d 通过 / %d 失败 / %d 出错（共 %d，通过率 %.0f%%），tokens %d→%d\n",
+		run.ID, s.Passed, s.Failed, s.Errored, s.Total, s.PassRate*100, s.InputTokens, s.OutputTokens)
+	if run.Status == eval.RunFailed {
+		return fmt.Errorf("run %s failed: %s", run.ID, run.Error)
+	}
+	if *compareTo != "" {
+		if err := printEvalComparison(store, *compareTo, run.ID); err != nil {
+			return err
+		}
+	}
+	if s.Passed != s.Total {
+		return errEvalFailed
+	}
+	return nil
+}
+
+func printEvalComparison(store *eval.Store, baseID, headID string) error {
+	base, err := store.GetRun(baseID)
+	if err != nil {
+		return fmt.Errorf("run %s: %w", baseID, err)
+	}
+	head, err := store.GetRun(headID)
+	if err != nil {
+		return fmt.Errorf("run %s: %w", headID, err)
+	}
+	cmp := eval.Compare(base, head)
+	fmt.Printf("\n对比 %s → %s：修复 %d，回退 %d\n", base.ID, head.ID, cmp.Fixes, cmp.Regressions)
+	for _, d := range cmp.Cases {
+		if d.Change == eval.ChangeSame {
+			continue
+		}
+		fmt.Printf("  %-10s %s\n", d.Change, d.CaseID)
+	}
+	fmt.Printf("  通过率 %.0f%% → %.0f%%\n", base.Summary.PassRate*100, head.Summary.PassRate*100)
+	return nil
+}
@@ -35,19 +35,25 @@ import (
 	aiteamPromptDef "github.com/Zyling-ai/zyhive/pkg/aiteam/promptdef"
 	aiteamRevenuePkg "github.com/Zyling-ai/zyhive/pkg/aiteam/revenue"
 	aiteamWalletPkg "github.com/Zyling-ai/zyhive/pkg/aiteam/wallet"
+	"github.com/Zyling-ai/zyhive/pkg/artifact"
 	"github.com/Zyling-ai/zyhive/pkg/budget"
 	"github.com/Zyling-ai/zyhive/pkg/channel"
 	"github.com/Zyling-ai/zyhive/pkg/config"
 	"github.com/Zyling-ai/zyhive/pkg/cron"
+	"github.com/Zyling-ai/zyhive/pkg/docextract"
+	"github.com/Zyling-ai/zyhive/pkg/knowledge"
 	"github.com/Zyling-ai/zyhive/pkg/llm"
 	"github.com/Zyling-ai/zyhive/pkg/logging"
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
 	"github.com/Zyling-ai/zyhive/pkg/netguard"
 	"github.com/Zyling-ai/zyhive/pkg/project"
 	"github.com/Zyling-ai/zyhive/pkg/session"
 	"github.com/Zyling-ai/zyhive/pkg/skillopt"
 	"github.com/Zyling-ai/zyhive/pkg/subagent"
 	"github.com/Zyling-ai/zyhive/pkg/tools"
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
 	"github.com/Zyling-ai/zyhive/pkg/usage"
+	"github.com/Zyling-ai/zyhive/pkg/voice"
 )
 
 // Version 由 Makefile ldflags 在编译时注入：-X main.Version=v0.9.15
@@ -119,7 +125,7 @@ func main() {
 	}
 
 	// ── 子命令处理 ──────────────────────────────────────────────────────────
-	// 支持：zyhive token / start / stop / restart / status / version
+	// 支持：zyhive token / mcp / usage / start / stop / restart / status / version
 	args := flag.Args()
 	if len(args) > 0 {
 		switch args[0] {
@@ -137,6 +143,28 @@ func main() {
 			fmt.Println(cfg.Auth.Token)
 			os.Exit(0)
 
+		case "mcp":
+			// MCP server on stdio (for IDEs / external agent runtimes)
+			if err := runMCPCommand(*configPath, args[1:]); err != nil {
+				fmt.Fprintln(os.Stderr, "mcp:", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+
+		case "usage":
+			if err := runUsageCommand(*configPath, args[1:]); err != nil {
+				fmt.Fprintln(os.Stderr, "usage:", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+
+		case "eval":
+			if err := runEvalCommand(*configPath, args[1:]); err != nil {
+				fmt.Fprintln(os.Stderr, "eval:", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+
 		case "start", "stop", "restart", "status", "enable", "disable":
 			runServiceSubcmd(args[0])
 			os.Exit(0)
@@ -196,6 +224,16 @@ func main() {
 		log.Printf("Warning: failed to load agents: %v", err)
 	}
 
+	// Extracted document text (read / web_fetch / channels / memory index)
+	// is cached by content hash under {agentsDir}/.cache/docextract.
+	docextract.SetCacheDir(filepath.Join(agentsDir, ".cache", "docextract"))
+
+	// Session share links (up to 7 days) survive restarts: their tickets
+	// (token hashes only) live in {agentsDir}/.shares.json.
+	if err := artifact.DefaultTickets.PersistShares(filepath.Join(agentsDir, ".shares.json")); err != nil {
+		log.Printf("Warning: failed to load share links: %v", err)
+	}
+
 	// Initialize project manager (shared workspace for all agents)
 	projectsDir := "projects"
 	projectMgr := project.NewManager(projectsDir)
@@ -231,10 +269,21 @@ func main() {
 		}
 	}
 
+	// Run tracing: spans → {agentsDir}/.traces/YYYY-MM-DD.jsonl (+ optional OTLP)
+	closeTracing := setupTracing(cfg.Tracing, agentsDir)
+
 	// Initialize multi-agent runner pool
 	pool := agent.NewPool(cfg, mgr)
 	pool.SetProjectManager(projectMgr)
 
+	// Knowledge bases: {agentsDir}/.knowledge/<id>/ holds uploads, crawled
+	// pages and the index; project folders are indexed in place.
+	knowledgeMgr := knowledge.NewManager(filepath.Join(agentsDir, ".knowledge"), projectMgr)
+	if err := knowledgeMgr.LoadAll(); err != nil {
+		log.Printf("Warning: failed to load knowledge bases: %v", err)
+	}
+	pool.SetKnowledgeManager(knowledgeMgr)
+
 	// Initialize subagent manager — background task execution
 	subagentStoreDir := filepath.Join(agentsDir, ".subagent-tasks")
 	subagentMgr := subagent.New(pool.SubagentRunFunc(), subagentStoreDir)
@@ -294,7 +343,7 @@ func main() {
 
 	// SkillOpt manager — self-evolving skills. Uses the agent's default model
 	// for the critic/evolver LLM calls (no tools, no history).
-	skilloptMgr := skillopt.NewManager(pool.CallLLMOnce)
+	skilloptMgr := skillopt.NewManager(pool.CallLLMOnceJSON)
 
 	// ── Cron: isolated session runner ────────────────────────────────────────
 	// Each cron job invocation gets its own fresh session ("cron-{jobID}-{runID}"),
@@ -334,17 +383,20 @@ func main() {
 	// ── Cron: announce delivery (botPool captured by closure, lazy eval) ──
 	// botPool is initialised after cronEngine; using a closure ensures we always
 	// reference the live botPool at call time (not at setup time).
-	var botPool *channel.BotPool // forward-declared; assigned below
-	cronAnnounceFunc := func(agentID, jobName, output string) {
-		if botPool == nil {
-			return
+	var botPool *channel.BotPool          // forward-declared; assigned below
+	var deliveries *channel.DeliveryQueue // likewise
+	cronAnnounceFunc := func(agentID, jobName, runID, output string) error {
+		if botPool == nil || deliveries == nil {
+			return fmt.Errorf("channels not started")
 		}
-		bot, _, ok := botPool.GetFirstBot(agentID)
+		bot, channelID, ok := botPool.First(agentID)
 		if !ok {
-			return
+			return fmt.Errorf("no active channel bot for agent %q", agentID)
 		}
 		header := fmt.Sprintf("📋 **%s**\n\n", jobName)
-		_ = bot.ProactiveSend(header + output)
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		return deliveries.Proactive(ctx, agentID, channelID, bot, "cron/"+runID, header+output)
 	}
 
 	// Shared aiteam audit log — created here so the channel-promptdef
@@ -402,6 +454,15 @@ func main() {
 		log.Printf("ACP agents configured: %d", len(cfg.ACPAgents))
 	}
 
+	// Connect MCP servers and discover their tools before any Runner is built.
+	mcpMgr := mcp.NewManager()
+	if len(cfg.MCPServers) > 0 {
+		mcpMgr.Start(context.Background(), cfg.MCPServers)
+		log.Printf("MCP servers configured: %d", len(cfg.MCPServers))
+	}
+	pool.SetMCPManager(mcpMgr)
+	api.SetMCPManager(mcpMgr)
+
 	// Tool-call approval broker must be attached before any heartbeat, channel,
 	// cron, or subagent can create a Runner.
 	approvalAuditDir := filepath.Join(agentsDir, "approvals")
@@ -440,73 +501,123 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// BotPool manages running Telegram bot goroutines — supports hot-add/remove.
+	// BotPool manages running channel driver goroutines — supports hot-add/remove.
 	// Assigned here (not `:=`) because botPool is forward-declared above for the cron closure.
 	botPool = channel.NewBotPool(ctx)
 
+	// Outbound delivery queue: final replies, cron announcements and
+	// send_message pushes are persisted under {agentsDir}/.outbox and
+	// retried until delivered (dead letters: GET /api/outbox).
+	deliveries = channel.NewDeliveryQueue(filepath.Join(agentsDir, ".outbox"), botPool.Get)
+	channel.SetDeliveryQueue(deliveries)
+	api.SetDeliveryQueue(deliveries)
+	// Cron announcements that were still queued when the run finished get
+	// their run record updated once the background retry settles.
+	deliveries.OnSettled("cron/", func(d channel.Delivery) {
+		var err error
+		if d.Status == channel.DeliveryDead {
+			err = fmt.Errorf("%w: %s", channel.ErrDeliveryDead, d.LastError)
+		}
+		cronEngine.ResolveAnnounce(strings.TrimPrefix(d.Key, "cron/"), err)
+	})
+	go deliveries.Run(ctx)
+
 	// Wire send_message tool: agents (especially those in isolated cron sessions) can call
-	// send_message to proactively push notifications to the agent's authorised Telegram users.
+	// send_message to proactively push notifications to the agent's authorised channel users.
 	// The closure captures botPool (now assigned) and looks up the live bot at call time.
 	pool.SetMessageSenderFn(func(agentID string) tools.MessageSenderFunc {
 		return func(ctx context.Context, text string) error {
-			bot, _, ok := botPool.GetFirstBot(agentID)
+			bot, channelID, ok := botPool.First(agentID)
 			if !ok {
-				return fmt.Errorf("send_message: no active Telegram bot for agent %q", agentID)
+				return fmt.Errorf("send_message: no active channel bot for agent %q", agentID)
 			}
-			return bot.ProactiveSend(text)
+			// Keyed on the tool call: a retried call must not push twice,
+			// but the agent may deliberately repeat the same text.
+			var key string
+			if id := tools.CallID(ctx); id != "" {
+				key = "send_message/" + agentID + "/" + id
+			}
+			return deliveries.Proactive(ctx, agentID, channelID, bot, key, text)
 		}
 	})
 
-	// startBotForChannel creates and starts a TelegramBot via the pool.
-	// Safe to call at any time (API handler uses it when channels are updated).
-	startBotForChannel := func(agentID, chID, token string) {
-		aID := agentID
-		cID := chID
-		pdDir := filepath.Join(agentsDir, aID, "channels-pending")
-		pending := channel.NewPendingStore(pdDir, cID)
-		sf := func(ctx2 context.Context, aid, msg, sessionID string, media []channel.MediaInput, fileSender channel.FileSenderFunc, extraCtx ...string) (<-chan channel.StreamEvent, error) {
-			return pool.RunStreamEvents(ctx2, aid, msg, sessionID, media, fileSender, extraCtx...)
+	// Wire email_send: new mail goes out through the agent's first running
+	// email channel (held in its outbox when approveReplies is on).
+	pool.SetEmailSenderFn(func(agentID string) tools.EmailSenderFunc {
+		return func(ctx context.Context, to []string, subject, body string) (string, error) {
+			bot, _, ok := botPool.FirstOfType(agentID, "email")
+			if !ok {
+				return "", fmt.Errorf("no active email channel for agent %q", agentID)
+			}
+			eb, ok := bot.(*channel.EmailBot)
+			if !ok {
+				return "", fmt.Errorf("email channel of agent %q cannot send", agentID)
+			}
+			id, queued, err := eb.SendNew(ctx, to, subject, body)
+			if err != nil {
+				return "", err
+			}
+			if queued {
+				return fmt.Sprintf("邮件已进入待审批发件箱（草稿 %s），管理员批准后发出", id), nil
+			}
+			return "邮件已发送，Message-ID: " + id, nil
 		}
-		getAllowFrom := func() []int64 { return mgr.GetAllowFrom(aID, cID) }
-		agentDir := filepath.Join(agentsDir, aID)
-		bot := channel.NewTelegramBotWithStream(token, aID, agentDir, cID, getAllowFrom, sf, pending)
-		// On successful getMe, mark channel status "ok" and save botName
-		bot.SetOnConnected(func(botUsername string) {
-			mgr.UpdateChannelStatus(aID, cID, "ok", botUsername)
-		})
-		botPool.StartBot(aID, cID, bot)
+	})
+
+	// Speech providers for channel voice notes / voice replies, resolved
+	// from the live tool registry on every call.
+	channelVoice := channel.VoiceOptions{
+		Transcribe: func(ctx context.Context, m channel.MediaInput) (string, error) {
+			stt, _ := voice.Live(cfg)
+			if stt == nil {
+				return "", channel.ErrNoSpeechProvider
+			}
+			return stt.Transcribe(ctx, m.Data, m.FileName, m.ContentType)
+		},
+		Synthesize: func(ctx context.Context, text string) ([]byte, string, error) {
+			_, tts := voice.Live(cfg)
+			if tts == nil {
+				return nil, "", channel.ErrNoSpeechProvider
+			}
+			return tts.Synthesize(ctx, text, "")
+		},
 	}
 
-	// startFeishuBotForChannel creates and starts a FeishuBot via the pool.
-	startFeishuBotForChannel := func(agentID, chID, appID, appSecret string) {
-		aID := agentID
-		cID := chID
-		sf := func(ctx2 context.Context, aid, msg, sessionID string, media []channel.MediaInput, fileSender channel.FileSenderFunc, extraCtx ...string) (<-chan channel.StreamEvent, error) {
-			return pool.RunStreamEvents(ctx2, aid, msg, sessionID, media, fileSender, extraCtx...)
-		}
-		getAllowFrom := func() []string {
-			raw := mgr.GetAllowFromStr(aID, cID)
-			return raw
-		}
-		agentDir := filepath.Join(agentsDir, aID)
-		pdDir := filepath.Join(agentsDir, aID, "channels-pending")
-		pending := channel.NewPendingStoreStr(pdDir, cID)
-		bot := channel.NewFeishuBotWithStream(appID, appSecret, aID, agentDir, cID, getAllowFrom, sf, pending)
-		bot.SetPanelBaseURL(cfg.Gateway.BaseURL())
-		bot.SetOnConnected(func(name string) {
-			mgr.UpdateChannelStatus(aID, cID, "ok", name)
+	// startChannel builds the channel's driver from the registry and starts it
+	// via the pool. Safe to call at any time (API handler uses it when channels
+	// are updated); channels of unknown types or with missing credentials are skipped.
+	startChannel := func(agentID string, ch config.ChannelEntry) {
+		aID, cID := agentID, ch.ID
+		d, err := channel.NewDriver(ch.Type, channel.DriverEnv{
+			AgentID:   aID,
+			AgentDir:  filepath.Join(agentsDir, aID),
+			ChannelID: cID,
+			Config:    ch.Config,
+			Stream: func(ctx2 context.Context, aid, msg, sessionID string, media []channel.MediaInput, fileSender channel.FileSenderFunc, extraCtx ...string) (<-chan channel.StreamEvent, error) {
+				return pool.RunStreamEvents(ctx2, aid, msg, sessionID, media, fileSender, extraCtx...)
+			},
+			AllowFrom:    func() []string { return mgr.GetAllowFromStr(aID, cID) },
+			PanelBaseURL: cfg.Gateway.BaseURL(),
+			PublicURL:    cfg.Gateway.PublicURL,
+			// On successful connect, mark channel status "ok" and save botName
+			OnConnected: func(name string) {
+				mgr.UpdateChannelStatus(aID, cID, "ok", name)
+			},
+			Approvals: pool.ChannelApprovals(),
+			Voice:     channelVoice,
 		})
-		botPool.StartFeishuBot(aID, cID, bot)
+		if err != nil {
+			log.Printf("[channel] agent=%s channel=%s not started: %v", aID, cID, err)
+			return
+		}
+		botPool.Start(aID, cID, d)
 	}
 
-	// Start Telegram bots — one per AI member (per-agent channel config)
+	// Start channel bots — one per enabled AI member channel (per-agent channel config)
 	for _, ag := range mgr.List() {
 		for _, ch := range ag.Channels {
-			if ch.Type == "telegram" && ch.Enabled && ch.Config["botToken"] != "" {
-				startBotForChannel(ag.ID, ch.ID, ch.Config["botToken"])
-			}
-			if ch.Type == "feishu" && ch.Enabled && ch.Config["appId"] != "" && ch.Config["appSecret"] != "" {
-				startFeishuBotForChannel(ag.ID, ch.ID, ch.Config["appId"], ch.Config["appSecret"])
+			if spec, ok := channel.LookupDriver(ch.Type); ok && ch.Enabled && spec.Ready(ch.Config) {
+				startChannel(ag.ID, ch)
 			}
 		}
 	}
@@ -548,25 +659,51 @@ func main() {
 	// Setup router
 	r := gin.Default()
 	botCtrl := api.BotControl{
-		Start: startBotForChannel,
-		Stop:  botPool.StopBot,
-		Notify: func(ctx context.Context, agentID, channelID string, chatID, threadID int64, prompt string) error {
-			var bot *channel.TelegramBot
+		Start: startChannel,
+		Stop:  botPool.Stop,
+		Notify: func(ctx context.Context, agentID, channelID string, chat channel.ChatRef, prompt string) error {
+			var bot channel.Driver
 			var ok bool
 			if channelID != "" {
-				bot, ok = botPool.GetBot(agentID, channelID)
+				bot, ok = botPool.Get(agentID, channelID)
 			} else {
-				bot, _, ok = botPool.GetFirstBot(agentID)
+				bot, _, ok = botPool.First(agentID)
+			}
+			if !ok {
+				return fmt.Errorf("no active channel bot found for agent %q", agentID)
 			}
+			n, ok := bot.(channel.Notifier)
 			if !ok {
-				return fmt.Errorf("no active Telegram bot found for agent %q", agentID)
+				return fmt.Errorf("%s channel does not support notify", bot.Type())
 			}
-			return bot.Notify(ctx, chatID, threadID, prompt)
+			return n.Notify(ctx, chat, prompt)
+		},
+		Webhook: func(agentID, channelID string) (channel.WebhookHandler, bool) {
+			bot, ok := botPool.Get(agentID, channelID)
+			if !ok {
+				return nil, false
+			}
+			h, ok := bot.(channel.WebhookHandler)
+			return h, ok
+		},
+		Driver: botPool.Get,
+		Outbox: func(agentID, channelID string) (channel.Outbox, bool) {
+			bot, ok := botPool.Get(agentID, channelID)
+			if !ok {
+				return nil, false
+			}
+			ob, ok := bot.(channel.Outbox)
+			return ob, ok
 		},
 	}
 	// Usage store: records are written to {agentsDir}/.usage/YYYY-MM.jsonl
 	usageStore := usage.NewStore(agentsDir)
 	pool.SetUsageStore(usageStore)
+	if pricer, err := usage.NewPricer(usageStore.PricingPath()); err != nil {
+		log.Printf("Warning: pricing table: %v (using built-in prices)", err)
+	} else {
+		usage.SetDefaultPricer(pricer)
+	}
 
 	// P1-02: Budget store. Disabled by default; reads cfg.Budget. Wired to
 	// usageStore via SetBudgetCharger so every recorded LLM call is also
@@ -699,9 +836,14 @@ func main() {
 			if ownerTGChat != 0 {
 				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 				defer cancel()
-				// Try the panicked agent's own bot first, fall back to
-				// any active bot in the pool.
-				if err := botCtrl.Notify(ctx, agentID, "", ownerTGChat, 0, message); err != nil {
+				// The chat is a Telegram chat: use the panicked agent's
+				// first active Telegram bot.
+				err := fmt.Errorf("no active Telegram bot for agent %q", agentID)
+				if bot, _, ok := botPool.FirstOfType(agentID, "telegram"); ok {
+					chat := channel.ChatRef{ID: fmt.Sprintf("%d", ownerTGChat)}
+					err = bot.(channel.Notifier).Notify(ctx, chat, message)
+				}
+				if err != nil {
 					log.Printf("[PANIC] tg push failed (agent=%s): %v", agentID, err)
 				}
 			}
@@ -737,7 +879,7 @@ func main() {
 				if apiKey == "" && llm.RequiresAPIKey(modelEntry.Provider) {
 					log.Printf("[aiteam] judge model %q has no api key wired — heuristic fallback", cfg.Aiteam.Judge.Model)
 				} else {
-					llmClient := llm.NewClient(modelEntry.Provider, baseURL)
+					llmClient := agent.LLMClient(modelEntry, baseURL)
 					timeout := 30 * time.Second
 					if cfg.Aiteam.Judge.TimeoutMs > 0 {
 						timeout = time.Duration(cfg.Aiteam.Judge.TimeoutMs) * time.Millisecond
@@ -1003,6 +1145,8 @@ func main() {
 		<-shutdownCtx.Done()
 
 		pool.CloseBrowser() // shut down headless browser if running
+		mcpMgr.Close()      // stop stdio MCP server subprocesses
+		closeTracing()      // flush pending OTLP spans
 
 		srvCtx, srvCancel := context.WithTimeout(context.Background(), 10*time.Second)
 		defer srvCancel()
@@ -1094,6 +1238,9 @@ func checkDefaultModelOnStartup(cfg *config.Config, cfgPath string) {
 	switch def.Provider {
 	case "anthropic":
 		ok, errMsg = startupTestAnthropic(key, resolvedBaseURL)
+	case "gemini", "google":
+		r := llm.Ping(context.Background(), "gemini", key, resolvedBaseURL, true)
+		ok, errMsg = r.OK, r.Error
 	default:
 		// OpenAI-compatible providers
 		baseURL := resolvedBaseURL
@@ -1128,6 +1275,8 @@ func envVarName(provider string) string {
 		return "OPENAI_API_KEY"
 	case "deepseek":
 		return "DEEPSEEK_API_KEY"
+	case "gemini", "google":
+		return "GEMINI_API_KEY"
 	default:
 		return ""
 	}
@@ -1206,6 +1355,8 @@ func startupDefaultBaseURL(provider string) string {
 		return "https://dashscope.aliyuncs.com/compatible-mode/v1"
 	case "openrouter":
 		return "https://openrouter.ai/api/v1"
+	case "gemini", "google":
+		return "https://generativelanguage.googleapis.com/v1beta"
 	case "ollama":
 		return "http://localhost:11434/v1"
 	default:
@@ -1236,6 +1387,25 @@ func buildLLMThrottle(cfg config.ThrottleConfig) llm.Throttle {
 	}
 }
 
+// setupTracing installs the default tracer from cfg and returns a closer
+// that flushes the OTLP exporter (no-op when export is off).
+func setupTracing(cfg config.TracingConfig, agentsDir string) func() {
+	if cfg.Disabled {
+		tracing.SetDefault(nil)
+		return func() {}
+	}
+	sinks := []tracing.Sink{tracing.NewStore(tracing.Dir(agentsDir), cfg.RetentionDays)}
+	closer := func() {}
+	if cfg.OTLPEndpoint != "" {
+		exp := tracing.NewOTLPExporter(cfg.OTLPEndpoint, cfg.OTLPHeaders, cfg.ServiceName)
+		sinks = append(sinks, exp)
+		closer = exp.Close
+		log.Printf("[tracing] exporting spans to %s", cfg.OTLPEndpoint)
+	}
+	tracing.SetDefault(tracing.NewTracer(sinks...))
+	return closer
+}
+
 func convertProviderConfig(p config.ThrottleProviderConfig) llm.AdaptiveConfig {
 	out := llm.AdaptiveConfig{
 		Min:       p.Min,
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/budget"
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
+	"github.com/Zyling-ai/zyhive/pkg/project"
+	"github.com/Zyling-ai/zyhive/pkg/tools"
+	"github.com/Zyling-ai/zyhive/pkg/usage"
+)
+
+// runMCPCommand serves the MCP server on stdin/stdout:
+//
+//	zyhive [--config FILE] mcp [--token TOKEN]
+//
+// The token (or ZYHIVE_MCP_TOKEN) is the admin token or one of
+// mcpServe.tokens and decides which agents are exposed. Runs share the
+// agents directory with the gateway, so sessions and usage records land in
+// the same place; today's spend is replayed into the budget store first so
+// daily caps hold in this process too.
+func runMCPCommand(configPath string, args []string) error {
+	fs := flag.NewFlagSet("mcp", flag.ContinueOnError)
+	token := fs.String("token", os.Getenv("ZYHIVE_MCP_TOKEN"), "admin token or scoped mcpServe token")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	// stdout carries JSON-RPC only; anything else printing there would
+	// corrupt the stream, so point the process-wide Stdout at stderr.
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	log.SetOutput(os.Stderr)
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("load config %s: %w", configPath, err)
+	}
+	scope, ok := mcp.ResolveScope(cfg, *token)
+	if !ok {
+		return fmt.Errorf("invalid or missing token (use --token or ZYHIVE_MCP_TOKEN)")
+	}
+	defer tools.CloseBackgroundProcesses()
+
+	agentsDir := cfg.Agents.Dir
+	if agentsDir == "" {
+		agentsDir = "./agents"
+	}
+	if abs, err := filepath.Abs(agentsDir); err == nil {
+		agentsDir = abs
+	}
+	mgr := agent.NewManager(agentsDir)
+	if err := mgr.LoadAll(); err != nil {
+		log.Printf("Warning: failed to load agents: %v", err)
+	}
+	projectMgr := project.NewManager("projects")
+	if err := projectMgr.LoadAll(); err != nil {
+		log.Printf("Warning: failed to load projects: %v", err)
+	}
+
+	closeTracing := setupTracing(cfg.Tracing, agentsDir)
+	defer closeTracing()
+
+	pool := agent.NewPool(cfg, mgr)
+	pool.SetProjectManager(projectMgr)
+
+	usageStore := usage.NewStore(agentsDir)
+	pool.SetUsageStore(usageStore)
+	if pricer, err := usage.NewPricer(usageStore.PricingPath()); err != nil {
+		log.Printf("Warning: pricing table: %v (using built-in prices)", err)
+	} else {
+		usage.SetDefaultPricer(pricer)
+	}
+	budgetStore := budget.NewStore(budget.Config{
+		Enabled:              cfg.Budget.Enabled,
+		GlobalDailyUSD:       cfg.Budget.GlobalDailyUSD,
+		DefaultAgentDailyUSD: cfg.Budget.DefaultAgentDailyUSD,
+		WarnAtPct:            cfg.Budget.WarnAtPct,
+		TZ:                   cfg.Budget.TZ,
+	})
+	today := usageStore.Summarize(budgetStore.DayStart().Unix(), time.Now().Unix(), "", "")
+	for agentID, stat := range today.ByAgent {
+		budgetStore.Charge(agentID, stat.Cost)
+	}
+	usageStore.SetBudgetCharger(budgetStore.Charge)
+	pool.SetBudgetStore(budgetStore)
+
+	if t := buildLLMThrottle(cfg.Throttle); t != nil {
+		llm.SetGlobalThrottle(t)
+	}
+
+	mcpMgr := mcp.NewManager()
+	if len(cfg.MCPServers) > 0 {
+		mcpMgr.Start(context.Background(), cfg.MCPServers)
+	}
+	pool.SetMCPManager(mcpMgr)
+	defer mcpMgr.Close()
+	defer pool.CloseBrowser()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("[mcp] serving on stdio (%s)", scope)
+	server := mcp.NewServer(pool.MCPBackend(), "zyhive", Version)
+	return server.ServeStdio(ctx, scope, os.Stdin, out)
+}
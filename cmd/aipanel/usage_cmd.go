@@ -0,0 +1,72 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/usage"
+)
+
+// runUsageCommand implements the `usage` subcommand:
+//
+//	zyhive [--config FILE] usage reprice [--from YYYY-MM-DD] [--to YYYY-MM-DD]
+//
+// reprice recomputes Record.Cost in {agentsDir}/.usage/*.jsonl with the
+// current pricing table (.usage/pricing.json) and provider overrides, so
+// reports stop mixing old and new prices. Provider-reported costs are kept.
+// A running gateway keeps its in-memory budget totals until restarted or
+// until POST /api/usage/reprice reseeds them.
+func runUsageCommand(configPath string, args []string) error {
+	if len(args) == 0 || args[0] != "reprice" {
+		return fmt.Errorf("usage: zyhive usage reprice [--from YYYY-MM-DD] [--to YYYY-MM-DD]")
+	}
+	fs := flag.NewFlagSet("usage reprice", flag.ContinueOnError)
+	fromStr := fs.String("from", "", "first day to re-price (UTC, inclusive); empty = all history")
+	toStr := fs.String("to", "", "last day to re-price (UTC, inclusive); empty = up to now")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	var from, to int64
+	if *fromStr != "" {
+		t, err := time.Parse("2006-01-02", *fromStr)
+		if err != nil {
+			return fmt.Errorf("--from: %w", err)
+		}
+		from = t.Unix()
+	}
+	if *toStr != "" {
+		t, err := time.Parse("2006-01-02", *toStr)
+		if err != nil {
+			return fmt.Errorf("--to: %w", err)
+		}
+		to = t.AddDate(0, 0, 1).Unix() - 1
+	}
+
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		return fmt.Errorf("load config %s: %w", configPath, err)
+	}
+	agentsDir := cfg.Agents.Dir
+	if agentsDir == "" {
+		agentsDir = "./agents"
+	}
+	if abs, err := filepath.Abs(agentsDir); err == nil {
+		agentsDir = abs
+	}
+	store := usage.NewStore(agentsDir)
+	pricer, err := usage.NewPricer(store.PricingPath())
+	if err != nil {
+		return err
+	}
+	res, err := store.Reprice(pricer, usage.ConfigOverrides(cfg), from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✅ 已按价格表 v%d 重新计价：%d 条记录，%d 条变化，%d 条为供应商计费未改动，改写 %d 个文件\n",
+		res.Version, res.Records, res.Changed, res.Skipped, res.Files)
+	fmt.Printf("   总费用 $%.4f → $%.4f\n", res.OldTotal, res.NewTotal)
+	return nil
+}
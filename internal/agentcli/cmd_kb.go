@@ -0,0 +1,314 @@
+package agentcli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func init() {
+	registerCommand(&command{
+		name:    "kb",
+		summary: "知识库：文档 / 项目 / 网页来源、挂载 agent、重建索引、检索",
+		actions: []*action{
+			{name: "list", summary: "列出知识库", usage: "zyhive kb list [--agent AGENT]", run: runKBList},
+			{name: "get", summary: "查看知识库及上传的文件", usage: "zyhive kb get <kbId>", run: runKBGet},
+			{name: "create", summary: "创建知识库", usage: "zyhive kb create --name NAME [--id ID] [--description TEXT] [--agents a,b] [--projects p1,p2] [--auto] [--auto-top-k N] --yes", run: runKBCreate},
+			{name: "update", summary: "更新知识库（挂载 agent、项目来源、自动检索）", usage: "zyhive kb update <kbId> [--name NAME] [--description TEXT] [--agents a,b] [--projects p1,p2] [--auto true|false] [--auto-top-k N] --yes", run: runKBUpdate},
+			{name: "delete", summary: "删除知识库（含上传文件与索引）", usage: "zyhive kb delete <kbId> --yes", run: runKBDelete},
+			{name: "upload", summary: "上传文档", usage: "zyhive kb upload <kbId> <file> [--as PATH] --yes", run: runKBUpload},
+			{name: "delete-file", summary: "删除上传的文档", usage: "zyhive kb delete-file <kbId> <path> --yes", run: runKBDeleteFile},
+			{name: "add-url", summary: "添加网页来源（可跟随同站链接）", usage: "zyhive kb add-url <kbId> <url> [--depth 0-2] --yes", run: runKBAddURL},
+			{name: "remove-url", summary: "移除网页来源", usage: "zyhive kb remove-url <kbId> <url> --yes", run: runKBRemoveURL},
+			{name: "reindex", summary: "增量重建索引", usage: "zyhive kb reindex <kbId> [--refresh-web] --yes", run: runKBReindex},
+			{name: "search", summary: "检索知识库（带出处）", usage: "zyhive kb search <kbId> <query> [--top-k N]", run: runKBSearch},
+		},
+	})
+}
+
+func runKBList(c *ctx, args []string) error {
+	fs := newFlagSet("kb list")
+	var agentID string
+	fs.StringVar(&agentID, "agent", "", "只列出挂载给该 agent 的知识库")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	resp, err := c.get("/api/knowledge" + q(map[string]string{"agentId": agentID}))
+	if err != nil {
+		return err
+	}
+	return c.result(resp, func(v any) {
+		rows := [][]string{}
+		for _, item := range asSlice(v) {
+			m := asMap(item)
+			idx := asMap(m["index"])
+			var agents []string
+			for _, a := range asSlice(m["agentIds"]) {
+				agents = append(agents, fmt.Sprint(a))
+			}
+			rows = append(rows, []string{
+				str(m, "id"), str(m, "name"), strings.Join(agents, ","),
+				str(idx, "files"), str(idx, "chunks"), str(m, "autoRetrieve"),
+			})
+		}
+		table(c.out, []string{"ID", "NAME", "AGENTS", "FILES", "CHUNKS", "AUTO"}, rows)
+	})
+}
+
+func runKBGet(c *ctx, args []string) error {
+	id := arg(args, 0)
+	if id == "" {
+		return usageErr("用法: zyhive kb get <kbId>")
+	}
+	resp, err := c.get(slashPath("api/knowledge", id))
+	if err != nil {
+		return err
+	}
+	return c.result(resp, nil)
+}
+
+func runKBCreate(c *ctx, args []string) error {
+	fs := newFlagSet("kb create")
+	var id, name, desc, agents, projects, topK string
+	var auto bool
+	fs.StringVar(&id, "id", "", "知识库 ID（默认自动生成）")
+	fs.StringVar(&name, "name", "", "名称")
+	fs.StringVar(&desc, "description", "", "描述（会出现在 kb_search 工具说明里）")
+	fs.StringVar(&agents, "agents", "", "逗号分隔挂载的 agent IDs")
+	fs.StringVar(&projects, "projects", "", "逗号分隔纳入索引的项目 IDs")
+	fs.BoolVar(&auto, "auto", false, "每轮自动检索并注入系统提示词")
+	fs.StringVar(&topK, "auto-top-k", "", "自动检索注入的段落数（默认 3）")
+	if _, err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if name == "" {
+		return usageErr("kb create 需要 --name")
+	}
+	if err := c.confirm("创建知识库 %s", name); err != nil {
+		return err
+	}
+	body := map[string]any{"id": id, "name": name, "description": desc,
+		"agentIds": parseCSV(agents), "projects": parseCSV(projects), "autoRetrieve": auto}
+	if topK != "" {
+		n, err := strconv.Atoi(topK)
+		if err != nil {
+			return usageErr("--auto-top-k 必须是整数")
+		}
+		body["autoTopK"] = n
+	}
+	resp, err := c.post("/api/knowledge", body)
+	if err != nil {
+		return err
+	}
+	return c.result(resp, nil)
+}
+
+func runKBUpdate(c *ctx, args []string) error {
+	fs := newFlagSet("kb update")
+	var name, desc, agents, projects, auto, topK string
+	fs.StringVar(&name, "name", "", "名称")
+	fs.StringVar(&desc, "description", "", "描述")
+	fs.StringVar(&agents, "agents", "", "逗号分隔挂载的 agent IDs（整体替换；- = 清空）")
+	fs.StringVar(&projects, "projects", "", "逗号分隔纳入索引的项目 IDs（整体替换；- = 清空）")
+	fs.StringVar(&auto, "auto", "", "true|false：每轮自动检索")
+	fs.StringVar(&topK, "auto-top-k", "", "自动检索注入的段落数")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	id := arg(pos, 0)
+	if id == "" {
+		return usageErr("用法: zyhive kb update <kbId> [flags] --yes")
+	}
+	if err := c.confirm("更新知识库 %s", id); err != nil {
+		return err
+	}
+	body := map[string]any{}
+	addIf(body, "name", name)
+	addIf(body, "description", desc)
+	for key, val := range map[string]string{"agentIds": agents, "projects": projects} {
+		switch val {
+		case "":
+		case "-":
+			body[key] = []string{}
+		default:
+			body[key] = parseCSV(val)
+		}
+	}
+	if auto != "" {
+		b, err := strconv.ParseBool(auto)
+		if err != nil {
+			return usageErr("--auto 必须是 true 或 false")
+		}
+		body["autoRetrieve"] = b
+	}
+	if topK != "" {
+		n, err := strconv.Atoi(topK)
+		if err != nil {
+			return usageErr("--auto-top-k 必须是整数")
+		}
+		body["autoTopK"] = n
+	}
+	resp, err := c.patch(slashPath("api/knowledge", id), body)
+	if err != nil {
+		return err
+	}
+	return c.result(resp, nil)
+}
+
+func runKBDelete(c *ctx, args []string) error {
+	id := arg(args, 0)
+	if id == "" {
+		return usageErr("用法: zyhive kb delete <kbId> --yes")
+	}
+	if err := c.confirm("删除知识库 %s", id); err != nil {
+		return err
+	}
+	resp, err := c.del(slashPath("api/knowledge", id))
+	if err != nil {
+		return err
+	}
+	return c.result(resp, nil)
+}
+
+func runKBUpload(c *ctx, args []string) error {
+	fs := newFlagSet("kb upload")
+	var as string
+	fs.StringVar(&as, "as", "", "知识库内的路径（默认用文件名）")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	id, file := arg(pos, 0), arg(pos, 1)
+	if id == "" || file == "" {
+		return usageErr("用法: zyhive kb upload <kbId> <file> [--as PATH] --yes")
+	}
+	if as == "" {
+		as = filepath.Base(file)
+	}
+	if err := c.confirm("上传 %s 到知识库 %s", as, id); err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	resp, err := c.put(slashPath("api/knowledge", id, "files", as), data)
+	if err != nil {
+		return err
+	}
+	return c.result(resp, func(any) {
+		c.ok("已上传 %s（%d 字节），后台建索引中", as, len(data))
+	})
+}
+
+func runKBDeleteFile(c *ctx, args []string) error {
+	id, path := arg(args, 0), arg(args, 1)
+	if id == "" || path == "" {
+		return usageErr("用法: zyhive kb delete-file <kbId> <path> --yes")
+	}
+	if err := c.confirm("删除知识库文件 %s/%s", id, path); err != nil {
+		return err
+	}
+	resp, err := c.del(slashPath("api/knowledge", id, "files", path))
+	if err != nil {
+		return err
+	}
+	return c.result(resp, nil)
+}
+
+func runKBAddURL(c *ctx, args []string) error {
+	fs := newFlagSet("kb add-url")
+	var depth int
+	fs.IntVar(&depth, "depth", 0, "跟随同站链接的层数（0-2）")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	id, u := arg(pos, 0), arg(pos, 1)
+	if id == "" || u == "" {
+		return usageErr("用法: zyhive kb add-url <kbId> <url> [--depth 0-2] --yes")
+	}
+	if err := c.confirm("添加网页来源 %s", u); err != nil {
+		return err
+	}
+	resp, err := c.post(slashPath("api/knowledge", id, "urls"), map[string]any{"url": u, "depth": depth})
+	if err != nil {
+		return err
+	}
+	return c.result(resp, nil)
+}
+
+func runKBRemoveURL(c *ctx, args []string) error {
+	id, u := arg(args, 0), arg(args, 1)
+	if id == "" || u == "" {
+		return usageErr("用法: zyhive kb remove-url <kbId> <url> --yes")
+	}
+	if err := c.confirm("移除网页来源 %s", u); err != nil {
+		return err
+	}
+	resp, err := c.del(slashPath("api/knowledge", id, "urls") + q(map[string]string{"url": u}))
+	if err != nil {
+		return err
+	}
+	return c.result(resp, nil)
+}
+
+func runKBReindex(c *ctx, args []string) error {
+	fs := newFlagSet("kb reindex")
+	var refresh bool
+	fs.BoolVar(&refresh, "refresh-web", false, "重新抓取网页来源")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	id := arg(pos, 0)
+	if id == "" {
+		return usageErr("用法: zyhive kb reindex <kbId> [--refresh-web] --yes")
+	}
+	if err := c.confirm("重建知识库 %s 的索引", id); err != nil {
+		return err
+	}
+	// No timeout: crawling and embedding a large knowledge base takes a while.
+	resp, err := c.client.Request(context.Background(), "POST", slashPath("api/knowledge", id, "reindex"), map[string]any{"refreshWeb": refresh})
+	if err != nil {
+		return err
+	}
+	return c.result(resp, func(v any) {
+		m := asMap(v)
+		c.ok("索引已更新：新增 %s、修改 %s、删除 %s 个文件，嵌入 %s 段",
+			str(m, "added"), str(m, "changed"), str(m, "removed"), str(m, "embedded"))
+	})
+}
+
+func runKBSearch(c *ctx, args []string) error {
+	fs := newFlagSet("kb search")
+	var topK string
+	fs.StringVar(&topK, "top-k", "", "返回段落数（默认 5）")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	id, query := arg(pos, 0), strings.Join(pos[min(1, len(pos)):], " ")
+	if id == "" || query == "" {
+		return usageErr("用法: zyhive kb search <kbId> <query> [--top-k N]")
+	}
+	resp, err := c.get(slashPath("api/knowledge", id, "search") + q(map[string]string{"q": query, "topK": topK}))
+	if err != nil {
+		return err
+	}
+	return c.result(resp, func(v any) {
+		hits := asSlice(v, "results")
+		if len(hits) == 0 {
+			c.printf("没有找到相关内容\n")
+			return
+		}
+		for i, item := range hits {
+			m := asMap(item)
+			c.printf("[%d] %s\n%s\n\n", i+1, str(m, "citation"), strings.TrimSpace(str(m, "text")))
+		}
+	})
+}
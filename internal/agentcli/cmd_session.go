@@ -1,12 +1,27 @@
 package agentcli
 
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
 func init() {
 	registerCommand(&command{
 		name:    "session",
-		summary: "全局会话：跨成员列表、查看、重命名、删除",
+		summary: "全局会话：跨成员列表、搜索、查看、分支 / 回退、导出 / 导入 / 分享、重命名、删除",
 		actions: []*action{
 			{name: "list", summary: "列出全局会话", usage: "zyhive session list [--agent AGENT] [--limit N]", run: runSessionList},
-			{name: "get", summary: "查看会话", usage: "zyhive session get <agentId> <sessionId>", run: runSessionGet},
+			{name: "search", summary: "全文 / 语义搜索历史消息", usage: "zyhive session search <query> [--agent AGENT] [--source SOURCE] [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--limit N] [--keyword]", run: runSessionSearch},
+			{name: "get", summary: "查看会话", usage: "zyhive session get <agentId> <sessionId> [--rewound]", run: runSessionGet},
+			{name: "fork", summary: "从某条消息分支出新会话", usage: "zyhive session fork <agentId> <sessionId> <messageId> --yes", run: runSessionFork},
+			{name: "rewind", summary: "回退到某条消息（之后的消息隐藏，可恢复）", usage: "zyhive session rewind <agentId> <sessionId> <messageId> [--drop] --yes", run: runSessionRewind},
+			{name: "restore", summary: "恢复一次回退", usage: "zyhive session restore <agentId> <sessionId> <rewindId> --yes", run: runSessionRestore},
+			{name: "export", summary: "导出会话（JSON 包 / Markdown / HTML）", usage: "zyhive session export <agentId> <sessionId> [--format json|md|html] [--out FILE] [--redact-tools] [--redact-env]", run: runSessionExport},
+			{name: "import", summary: "把导出的 JSON 包导入为新会话", usage: "zyhive session import <agentId> <bundle.json|-> --yes", run: runSessionImport},
+			{name: "share", summary: "生成限时只读分享链接", usage: "zyhive session share <agentId> <sessionId> [--ttl 24h] [--redact-tools] [--redact-env] --yes", run: runSessionShare},
 			{name: "delete", summary: "删除会话", usage: "zyhive session delete <agentId> <sessionId> --yes", run: runSessionDelete},
 			{name: "patch", summary: "更新会话元数据", usage: "zyhive session patch <agentId> <sessionId> --title TITLE --yes", run: runSessionPatch},
 		},
@@ -28,18 +43,261 @@ func runSessionList(c *ctx, args []string) error {
 	return c.result(resp, nil)
 }
 
+func runSessionSearch(c *ctx, args []string) error {
+	fs := newFlagSet("session search")
+	var agentID, source, from, to, limit string
+	var keyword bool
+	fs.StringVar(&agentID, "agent", "", "按 agent 过滤")
+	fs.StringVar(&source, "source", "", "按来源渠道过滤（web / feishu / telegram ...）")
+	fs.StringVar(&from, "from", "", "起始日期（UTC，含）")
+	fs.StringVar(&to, "to", "", "结束日期（UTC，含）")
+	fs.StringVar(&limit, "limit", "", "最大条数")
+	fs.BoolVar(&keyword, "keyword", false, "只做关键词检索，不调用 embedding")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	query := strings.Join(pos, " ")
+	if query == "" {
+		return usageErr("用法: zyhive session search <query> [--agent AGENT] [--source SOURCE] [--from YYYY-MM-DD] [--to YYYY-MM-DD]")
+	}
+	mode := ""
+	if keyword {
+		mode = "keyword"
+	}
+	resp, err := c.get("/api/sessions/search" + q(map[string]string{
+		"q": query, "agentId": agentID, "source": source,
+		"dateFrom": from, "dateTo": to, "limit": limit, "mode": mode,
+	}))
+	if err != nil {
+		return err
+	}
+	return c.result(resp, func(v any) {
+		hits := asSlice(v, "results")
+		if len(hits) == 0 {
+			c.printf("没有找到匹配的消息\n")
+			return
+		}
+		for _, item := range hits {
+			m := asMap(item)
+			when := ""
+			if ts, ok := m["timestamp"].(float64); ok {
+				when = time.UnixMilli(int64(ts)).Format("2006-01-02 15:04")
+			}
+			c.printf("%s/%s  %s  %s  %s\n", str(m, "agentId"), str(m, "sessionId"), when, str(m, "role"), str(m, "title"))
+			c.printf("    %s\n", markSnippet(str(m, "snippet"), m["highlights"]))
+		}
+	})
+}
+
+// markSnippet brackets the highlight ranges ([start,end) rune offsets).
+func markSnippet(snippet string, highlights any) string {
+	runes := []rune(snippet)
+	var b strings.Builder
+	last := 0
+	for _, h := range asSlice(highlights) {
+		r, _ := h.([]any)
+		if len(r) != 2 {
+			continue
+		}
+		start, _ := r[0].(float64)
+		end, _ := r[1].(float64)
+		if int(start) < last || int(end) > len(runes) || start >= end {
+			continue
+		}
+		b.WriteString(string(runes[last:int(start)]) + "[" + string(runes[int(start):int(end)]) + "]")
+		last = int(end)
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
 func runSessionGet(c *ctx, args []string) error {
-	agentID, sid := arg(args, 0), arg(args, 1)
+	fs := newFlagSet("session get")
+	var rewound bool
+	fs.BoolVar(&rewound, "rewound", false, "同时列出已回退（隐藏）的消息")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	agentID, sid := arg(pos, 0), arg(pos, 1)
 	if agentID == "" || sid == "" {
-		return usageErr("用法: zyhive session get <agentId> <sessionId>")
+		return usageErr("用法: zyhive session get <agentId> <sessionId> [--rewound]")
+	}
+	params := map[string]string{}
+	if rewound {
+		params["rewound"] = "1"
+	}
+	resp, err := c.get("/api/sessions/" + agentID + "/" + sid + q(params))
+	if err != nil {
+		return err
+	}
+	return c.result(resp, nil)
+}
+
+func runSessionFork(c *ctx, args []string) error {
+	agentID, sid, msgID := arg(args, 0), arg(args, 1), arg(args, 2)
+	if agentID == "" || sid == "" || msgID == "" {
+		return usageErr("用法: zyhive session fork <agentId> <sessionId> <messageId> --yes")
+	}
+	if err := c.confirm("从 %s/%s 的消息 %s 创建分支会话", agentID, sid, msgID); err != nil {
+		return err
+	}
+	resp, err := c.post("/api/sessions/"+agentID+"/"+sid+"/fork", map[string]any{"messageId": msgID})
+	if err != nil {
+		return err
+	}
+	return c.result(resp, func(v any) {
+		c.printf("已创建分支会话 %s\n", str(asMap(v), "sessionId"))
+	})
+}
+
+func runSessionRewind(c *ctx, args []string) error {
+	fs := newFlagSet("session rewind")
+	var drop bool
+	fs.BoolVar(&drop, "drop", false, "连同该消息本身一起隐藏")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	agentID, sid, msgID := arg(pos, 0), arg(pos, 1), arg(pos, 2)
+	if agentID == "" || sid == "" || msgID == "" {
+		return usageErr("用法: zyhive session rewind <agentId> <sessionId> <messageId> [--drop] --yes")
+	}
+	if err := c.confirm("回退会话 %s/%s 到消息 %s", agentID, sid, msgID); err != nil {
+		return err
+	}
+	resp, err := c.post("/api/sessions/"+agentID+"/"+sid+"/rewind", map[string]any{"messageId": msgID, "keep": !drop})
+	if err != nil {
+		return err
 	}
-	resp, err := c.get("/api/sessions/" + agentID + "/" + sid)
+	return c.result(resp, func(v any) {
+		id, _ := asMap(v)["rewindId"].(float64)
+		if id == 0 {
+			c.printf("该消息之后没有可回退的消息\n")
+			return
+		}
+		c.printf("已回退，恢复请执行: zyhive session restore %s %s %d --yes\n", agentID, sid, int64(id))
+	})
+}
+
+func runSessionRestore(c *ctx, args []string) error {
+	agentID, sid := arg(args, 0), arg(args, 1)
+	rewindID, _ := strconv.ParseInt(arg(args, 2), 10, 64)
+	if agentID == "" || sid == "" || rewindID == 0 {
+		return usageErr("用法: zyhive session restore <agentId> <sessionId> <rewindId> --yes")
+	}
+	if err := c.confirm("恢复会话 %s/%s 的回退 %d", agentID, sid, rewindID); err != nil {
+		return err
+	}
+	resp, err := c.post("/api/sessions/"+agentID+"/"+sid+"/restore", map[string]any{"rewindId": rewindID})
 	if err != nil {
 		return err
 	}
 	return c.result(resp, nil)
 }
 
+func runSessionExport(c *ctx, args []string) error {
+	fs := newFlagSet("session export")
+	var format, out string
+	var redactTools, redactEnv bool
+	fs.StringVar(&format, "format", "json", "json（可导入）| md | html")
+	fs.StringVar(&out, "out", "", "写入文件（默认输出到 stdout）")
+	fs.BoolVar(&redactTools, "redact-tools", false, "隐藏工具调用参数")
+	fs.BoolVar(&redactEnv, "redact-env", false, "把成员环境变量的值替换为 [env:KEY]")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	agentID, sid := arg(pos, 0), arg(pos, 1)
+	if agentID == "" || sid == "" {
+		return usageErr("用法: zyhive session export <agentId> <sessionId> [--format json|md|html] [--out FILE]")
+	}
+	resp, err := c.get("/api/sessions/" + agentID + "/" + sid + "/export" + q(map[string]string{
+		"format":      format,
+		"redactTools": map[bool]string{true: "1", false: ""}[redactTools],
+		"redactEnv":   map[bool]string{true: "1", false: ""}[redactEnv],
+	}))
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = c.out.Write(resp)
+		return err
+	}
+	if err := os.WriteFile(out, resp, 0o600); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", out, err)
+	}
+	c.ok("已导出到 %s（%d 字节）", out, len(resp))
+	return nil
+}
+
+func runSessionImport(c *ctx, args []string) error {
+	agentID, file := arg(args, 0), arg(args, 1)
+	if agentID == "" || file == "" {
+		return usageErr("用法: zyhive session import <agentId> <bundle.json|-> --yes")
+	}
+	if err := c.confirm("导入会话到 %s", agentID); err != nil {
+		return err
+	}
+	var data []byte
+	var err error
+	if file == "-" {
+		var s string
+		s, err = stdinIfDash(file)
+		data = []byte(s)
+	} else {
+		data, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	resp, err := c.post("/api/sessions/"+agentID+"/import", data)
+	if err != nil {
+		return err
+	}
+	return c.result(resp, func(v any) {
+		m := asMap(v)
+		c.printf("已导入为会话 %s\n", str(m, "sessionId"))
+		if w := str(m, "warning"); w != "" {
+			c.printf("注意: %s\n", w)
+		}
+	})
+}
+
+func runSessionShare(c *ctx, args []string) error {
+	fs := newFlagSet("session share")
+	var ttl time.Duration
+	var redactTools, redactEnv bool
+	fs.DurationVar(&ttl, "ttl", 24*time.Hour, "有效期（最长 168h）")
+	fs.BoolVar(&redactTools, "redact-tools", false, "隐藏工具调用参数")
+	fs.BoolVar(&redactEnv, "redact-env", false, "把成员环境变量的值替换为 [env:KEY]")
+	pos, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+	agentID, sid := arg(pos, 0), arg(pos, 1)
+	if agentID == "" || sid == "" || ttl < time.Minute {
+		return usageErr("用法: zyhive session share <agentId> <sessionId> [--ttl 24h] [--redact-tools] [--redact-env] --yes")
+	}
+	if err := c.confirm("为会话 %s/%s 生成公开只读链接（%s）", agentID, sid, ttl); err != nil {
+		return err
+	}
+	resp, err := c.post("/api/sessions/"+agentID+"/"+sid+"/share", map[string]any{
+		"ttlMinutes": int(ttl / time.Minute), "redactTools": redactTools, "redactEnv": redactEnv,
+	})
+	if err != nil {
+		return err
+	}
+	return c.result(resp, func(v any) {
+		m := asMap(v)
+		c.printf("%s\n", str(m, "url"))
+		if ts, ok := m["expiresAt"].(float64); ok {
+			c.printf("有效期至 %s\n", time.UnixMilli(int64(ts)).Format("2006-01-02 15:04"))
+		}
+	})
+}
+
 func runSessionDelete(c *ctx, args []string) error {
 	agentID, sid := arg(args, 0), arg(args, 1)
 	if agentID == "" || sid == "" {
@@ -22,7 +22,7 @@ import (
 type agentChannelHandler struct {
 	manager    *agent.Manager
 	runnerFunc channel.RunnerFunc
-	botCtrl    BotControl // start/stop Telegram bots dynamically
+	botCtrl    BotControl // start/stop channel bots dynamically
 }
 
 // GetChannels GET /api/agents/:id/channels
@@ -42,7 +42,7 @@ func (h *agentChannelHandler) GetChannels(c *gin.Context) {
 	for i := range result {
 		mc := make(map[string]string)
 		for k, v := range result[i].Config {
-			if strings.Contains(strings.ToLower(k), "token") || strings.Contains(strings.ToLower(k), "key") {
+			if isSecretField(k) {
 				mc[k] = maskKey(v)
 			} else {
 				mc[k] = v
@@ -65,9 +65,9 @@ func (h *agentChannelHandler) GetChannels(c *gin.Context) {
 	pd := pendingDir(ag)
 	for i, ch := range result {
 		rc := RichChannel{ChannelEntry: ch}
-		if ch.Config["allowedFrom"] != "" {
-			switch ch.Type {
-			case "telegram":
+		if spec, known := channel.LookupDriver(ch.Type); known && ch.Config["allowedFrom"] != "" {
+			switch {
+			case spec.NumericIDs:
 				as := channel.NewApprovedStore(pd, ch.ID)
 				for _, idStr := range strings.Split(ch.Config["allowedFrom"], ",") {
 					idStr = strings.TrimSpace(idStr)
@@ -85,7 +85,7 @@ func (h *agentChannelHandler) GetChannels(c *gin.Context) {
 					}
 					rc.AllowedFromUsers = append(rc.AllowedFromUsers, ui)
 				}
-			case "feishu":
+			default:
 				as := channel.NewApprovedStoreStr(pd, ch.ID)
 				for _, idStr := range strings.Split(ch.Config["allowedFrom"], ",") {
 					idStr = strings.TrimSpace(idStr)
@@ -150,19 +150,21 @@ func (h *agentChannelHandler) SetChannels(c *gin.Context) {
 		}
 	}
 
-	// ── Uniqueness check: a Telegram bot token can only belong to one agent ──
+	// ── Uniqueness check: a bot account (the driver's UniqueKey, e.g. the
+	// Telegram bot token) can only belong to one agent ──
 	for _, ch := range incoming {
-		if ch.Type != "telegram" {
+		spec, ok := channel.LookupDriver(ch.Type)
+		if !ok || spec.UniqueKey == "" {
 			continue
 		}
-		token := ch.Config["botToken"]
-		if token == "" || ismasked(token) {
+		val := ch.Config[spec.UniqueKey]
+		if val == "" || ismasked(val) {
 			continue
 		}
-		if owner, ownerCh := h.manager.FindAgentByBotToken(token, agentID); owner != nil {
+		if owner, ownerCh := h.manager.FindAgentByChannelKey(ch.Type, spec.UniqueKey, val, agentID); owner != nil {
 			c.JSON(http.StatusConflict, gin.H{
 				"error": fmt.Sprintf(
-					"Bot Token 已被成员「%s」的渠道「%s」使用，每个 Bot 只能绑定一个 AI 成员",
+					"Bot 账号已被成员「%s」的渠道「%s」使用，每个 Bot 只能绑定一个 AI 成员",
 					owner.Name, ownerCh,
 				),
 			})
@@ -197,25 +199,25 @@ func (h *agentChannelHandler) SetChannels(c *gin.Context) {
 		for _, ch := range existing {
 			existingMap[ch.ID] = ch
 		}
-		// Stop removed or disabled channels
+		// Stop removed, disabled or no-longer-runnable channels
 		for _, ex := range existing {
-			if ex.Type != "telegram" {
+			if _, ok := channel.LookupDriver(ex.Type); !ok {
 				continue
 			}
 			inc, still := incomingMap[ex.ID]
-			if !still || !inc.Enabled {
+			if !still || !inc.Enabled || inc.Type != ex.Type {
 				h.botCtrl.Stop(agentID, ex.ID)
 			}
 		}
-		// Start new or token-changed channels
+		// Start new or credential-changed channels
 		for _, ch := range incoming {
-			if ch.Type != "telegram" || !ch.Enabled || ch.Config["botToken"] == "" || ismasked(ch.Config["botToken"]) {
+			spec, ok := channel.LookupDriver(ch.Type)
+			if !ok || !ch.Enabled || !spec.Ready(ch.Config) {
 				continue
 			}
 			ex, existed := existingMap[ch.ID]
-			tokenChanged := !existed || ex.Config["botToken"] != ch.Config["botToken"]
-			if !existed || tokenChanged {
-				h.botCtrl.Start(agentID, ch.ID, ch.Config["botToken"])
+			if !existed || !ex.Enabled || ex.Type != ch.Type || spec.ConfigChanged(ex.Config, ch.Config) {
+				h.botCtrl.Start(agentID, ch)
 			}
 		}
 	}
@@ -224,7 +226,7 @@ func (h *agentChannelHandler) SetChannels(c *gin.Context) {
 }
 
 // TestChannel POST /api/agents/:id/channels/:chId/test
-// For Telegram: calls getMe to verify the bot token.
+// Verifies credentials with the driver's Test hook (Telegram: getMe).
 func (h *agentChannelHandler) TestChannel(c *gin.Context) {
 	agentID := c.Param("id")
 	chID := c.Param("chId")
@@ -247,36 +249,34 @@ func (h *agentChannelHandler) TestChannel(c *gin.Context) {
 		return
 	}
 
-	switch ch.Type {
-	case "telegram":
-		token := ch.Config["botToken"]
-		if token == "" || ismasked(token) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "botToken is required"})
-			return
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
-
-		botName, err := channel.TestTelegramBot(ctx, token)
-		if err != nil {
-			ch.Status = "error"
-			_ = h.manager.UpdateChannels(agentID, ag.Channels)
-			c.JSON(http.StatusOK, gin.H{"valid": false, "error": err.Error()})
-			return
-		}
-		ch.Status = "ok"
-		if botName != "" {
-			ch.Config["botName"] = botName
-		}
-		_ = h.manager.UpdateChannels(agentID, ag.Channels)
-		c.JSON(http.StatusOK, gin.H{"valid": true, "botName": botName})
-
-	default:
+	spec, ok := channel.LookupDriver(ch.Type)
+	if !ok || spec.Test == nil {
 		// Generic: just mark ok
 		ch.Status = "ok"
 		_ = h.manager.UpdateChannels(agentID, ag.Channels)
 		c.JSON(http.StatusOK, gin.H{"valid": true})
+		return
+	}
+	if !spec.Ready(ch.Config) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": strings.Join(spec.Required, ", ") + " is required"})
+		return
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	botName, err := spec.Test(ctx, ch.Config)
+	if err != nil {
+		ch.Status = "error"
+		_ = h.manager.UpdateChannels(agentID, ag.Channels)
+		c.JSON(http.StatusOK, gin.H{"valid": false, "error": err.Error()})
+		return
+	}
+	ch.Status = "ok"
+	if botName != "" {
+		ch.Config["botName"] = botName
+	}
+	_ = h.manager.UpdateChannels(agentID, ag.Channels)
+	c.JSON(http.StatusOK, gin.H{"valid": true, "botName": botName})
 }
 
 // CheckToken POST /api/agents/:id/channels/check-token
@@ -349,7 +349,7 @@ func (h *agentChannelHandler) ListPending(c *gin.Context) {
 			break
 		}
 	}
-	if chType == "feishu" {
+	if stringIDChannel(chType) {
 		ps := channel.NewPendingStoreStr(pendingDir(ag), chID)
 		c.JSON(http.StatusOK, ps.List())
 		return
@@ -389,8 +389,8 @@ func (h *agentChannelHandler) AllowPending(c *gin.Context) {
 		ch.Config = map[string]string{}
 	}
 
-	// Feishu: string open_id
-	if ch.Type == "feishu" {
+	// String user IDs (Feishu open_id, Slack user id, ...)
+	if stringIDChannel(ch.Type) {
 		existing := ch.Config["allowedFrom"]
 		ids := parseIDList(existing)
 		ids = appendUnique(ids, userIDStr)
@@ -413,14 +413,16 @@ func (h *agentChannelHandler) AllowPending(c *gin.Context) {
 		ps.Remove(userIDStr)
 
 		// Notify the user via Feishu that they have been approved
-		go func(appID, appSecret, openID string) {
-			if appID == "" || appSecret == "" || openID == "" {
-				return
-			}
-			if err := channel.SendFeishuApprovedNotice(appID, appSecret, openID); err != nil {
-				log.Printf("[api] feishu approved notice error: %v", err)
-			}
-		}(ch.Config["appId"], ch.Config["appSecret"], userIDStr)
+		if ch.Type == "feishu" {
+			go func(appID, appSecret, openID string) {
+				if appID == "" || appSecret == "" || openID == "" {
+					return
+				}
+				if err := channel.SendFeishuApprovedNotice(appID, appSecret, openID); err != nil {
+					log.Printf("[api] feishu approved notice error: %v", err)
+				}
+			}(ch.Config["appId"], ch.Config["appSecret"], userIDStr)
+		}
 
 		c.JSON(http.StatusOK, gin.H{"ok": true, "allowedFrom": ch.Config["allowedFrom"]})
 		return
@@ -502,8 +504,8 @@ func (h *agentChannelHandler) RemoveAllowed(c *gin.Context) {
 		ch.Config = map[string]string{}
 	}
 
-	// Feishu: string open_id
-	if ch.Type == "feishu" {
+	// String user IDs (Feishu open_id, Slack user id, ...)
+	if stringIDChannel(ch.Type) {
 		existing := ch.Config["allowedFrom"]
 		ids := parseIDList(existing)
 		filtered := make([]string, 0, len(ids))
@@ -521,7 +523,7 @@ func (h *agentChannelHandler) RemoveAllowed(c *gin.Context) {
 		as := channel.NewApprovedStoreStr(pendingDir(ag), chID)
 		as.Remove(userIDStr)
 
-		log.Printf("[channels] removed feishu user=%s from whitelist of agent=%s channel=%s", userIDStr, agentID, chID)
+		log.Printf("[channels] removed %s user=%s from whitelist of agent=%s channel=%s", ch.Type, userIDStr, agentID, chID)
 		c.JSON(http.StatusOK, gin.H{"ok": true, "allowedFrom": ch.Config["allowedFrom"]})
 		return
 	}
@@ -581,7 +583,7 @@ func (h *agentChannelHandler) DismissPending(c *gin.Context) {
 		}
 	}
 
-	if chType == "feishu" {
+	if stringIDChannel(chType) {
 		ps := channel.NewPendingStoreStr(pendingDir(ag), chID)
 		ps.Remove(userIDStr)
 		c.JSON(http.StatusOK, gin.H{"ok": true})
@@ -599,6 +601,13 @@ func (h *agentChannelHandler) DismissPending(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+// stringIDChannel reports whether a channel type keys its pending / approved
+// users by string (PendingStoreStr) rather than int64 (DriverSpec.NumericIDs).
+func stringIDChannel(chType string) bool {
+	spec, ok := channel.LookupDriver(chType)
+	return ok && !spec.NumericIDs
+}
+
 // parseIDList splits a comma-separated ID string into a slice.
 func parseIDList(s string) []string {
 	if s == "" {
@@ -61,9 +61,11 @@ func (h *agentExtHandler) ToolHealth(c *gin.Context) {
 
 	// 当前 agent 绑定的 modelId / provider
 	modelProvider := ""
+	modelVision := true
 	if ag.ModelID != "" {
 		if me := h.cfg.FindModel(ag.ModelID); me != nil {
 			modelProvider = me.Provider
+			modelVision = config.ModelCapabilities(me).Vision
 		}
 	}
 	// agent 绑定的 channel type 集合
@@ -103,9 +105,10 @@ func (h *agentExtHandler) ToolHealth(c *gin.Context) {
 		{"agent_tasks", "agent", nil}, {"agent_kill", "agent", nil},
 		{"agent_result", "agent", nil},
 		{"sessions_list", "sessions", nil}, {"sessions_history", "sessions", nil},
+		{"sessions_search", "sessions", nil},
 		{"sessions_send", "sessions", nil}, {"sessions_spawn", "sessions", nil},
 		{"cron_list", "cron", nil}, {"cron_add", "cron", nil}, {"cron_remove", "cron", nil},
-		{"memory_search", "memory", nil},
+		{"memory_search", "memory", nil}, {"kb_search", "memory", nil},
 		{"project_list", "project", nil}, {"project_read", "project", nil},
 		{"project_write", "project", nil}, {"project_create", "project", nil},
 		{"project_glob", "project", nil},
@@ -123,9 +126,16 @@ func (h *agentExtHandler) ToolHealth(c *gin.Context) {
 			}
 			return false, "未配置 Brave Search API Key", "前往「密钥管理」添加 brave_search 类型的 key"
 		}},
+		// tts: 需要 TTS 服务 key
+		{"tts", "ui", func() (bool, string, string) {
+			if toolKeys["openai_tts"] || toolKeys["elevenlabs"] {
+				return true, "", ""
+			}
+			return false, "未配置语音合成服务", "前往「密钥管理」添加 openai_tts 或 elevenlabs 类型的 key"
+		}},
 		// image: 视觉能力依赖模型
 		{"image", "ui", func() (bool, string, string) {
-			if modelProvider == "anthropic" || modelProvider == "openai" || modelProvider == "" {
+			if modelProvider == "" || modelVision {
 				return true, "", ""
 			}
 			return false, "当前绑定模型不支持视觉", "切换到 Claude / GPT-4o 等多模态模型"
@@ -143,6 +153,12 @@ func (h *agentExtHandler) ToolHealth(c *gin.Context) {
 			}
 			return false, "未配置任何消息渠道", "同 send_message"
 		}},
+		{"email_send", "messaging", func() (bool, string, string) {
+			if channelTypes["email"] {
+				return true, "", ""
+			}
+			return false, "未绑定邮件渠道", "前往「渠道」tab 添加邮件渠道"
+		}},
 		// 飞书专属工具：依赖绑定飞书渠道
 		{"feishu_send_message", "feishu", checkFeishuChannel(channelTypes)},
 		{"feishu_send_rich_message", "feishu", checkFeishuChannel(channelTypes)},
@@ -154,6 +170,13 @@ func (h *agentExtHandler) ToolHealth(c *gin.Context) {
 		{"feishu_get_user_info", "feishu", checkFeishuChannel(channelTypes)},
 		{"feishu_create_calendar_event", "feishu", checkFeishuChannel(channelTypes)},
 		{"feishu_create_task", "feishu", checkFeishuChannel(channelTypes)},
+		// 钉钉 / 企业微信专属工具
+		{"dingtalk_send_message", "dingtalk", checkChannelType(channelTypes, "dingtalk", "未绑定钉钉渠道", "前往「渠道」tab 添加钉钉机器人")},
+		{"dingtalk_list_departments", "dingtalk", checkChannelType(channelTypes, "dingtalk", "未绑定钉钉渠道", "前往「渠道」tab 添加钉钉机器人")},
+		{"dingtalk_list_users", "dingtalk", checkChannelType(channelTypes, "dingtalk", "未绑定钉钉渠道", "前往「渠道」tab 添加钉钉机器人")},
+		{"wecom_send_message", "wecom", checkChannelType(channelTypes, "wecom", "未绑定企业微信渠道", "前往「渠道」tab 添加企业微信应用")},
+		{"wecom_list_departments", "wecom", checkChannelType(channelTypes, "wecom", "未绑定企业微信渠道", "前往「渠道」tab 添加企业微信应用")},
+		{"wecom_list_users", "wecom", checkChannelType(channelTypes, "wecom", "未绑定企业微信渠道", "前往「渠道」tab 添加企业微信应用")},
 	}
 
 	var ready, blocked int
@@ -229,3 +252,13 @@ func checkFeishuChannel(channelTypes map[string]bool) func() (bool, string, stri
 		return false, "未绑定飞书渠道", "前往「渠道」tab 添加飞书 Bot"
 	}
 }
+
+// checkChannelType 返回「需绑定某类渠道」工具的 readiness 检查闭包。
+func checkChannelType(channelTypes map[string]bool, channelType, reason, hint string) func() (bool, string, string) {
+	return func() (bool, string, string) {
+		if channelTypes[channelType] {
+			return true, "", ""
+		}
+		return false, reason, hint
+	}
+}
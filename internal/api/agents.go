@@ -22,20 +22,22 @@ type agentHandler struct {
 
 // AgentInfo is the JSON shape returned to the frontend.
 type AgentInfo struct {
-	ID           string                  `json:"id"`
-	Name         string                  `json:"name"`
-	Description  string                  `json:"description,omitempty"`
-	Model        string                  `json:"model"`
-	ModelID      string                  `json:"modelId,omitempty"`
-	ToolIDs      []string                `json:"toolIds,omitempty"`
-	SkillIDs     []string                `json:"skillIds,omitempty"`
-	AvatarColor  string                  `json:"avatarColor,omitempty"`
-	System       bool                    `json:"system,omitempty"`
-	Status       string                  `json:"status"`
-	WorkspaceDir string                  `json:"workspaceDir"`
-	Env          map[string]string       `json:"env,omitempty"`        // per-agent env vars
-	Heartbeat    *config.HeartbeatConfig `json:"heartbeat,omitempty"`  // built-in heartbeat config
-	ToolPolicy   json.RawMessage         `json:"toolPolicy,omitempty"` // per-agent tool permission policy
+	ID           string                   `json:"id"`
+	Name         string                   `json:"name"`
+	Description  string                   `json:"description,omitempty"`
+	Model        string                   `json:"model"`
+	ModelID      string                   `json:"modelId,omitempty"`
+	ToolIDs      []string                 `json:"toolIds,omitempty"`
+	SkillIDs     []string                 `json:"skillIds,omitempty"`
+	MCPServerIDs []string                 `json:"mcpServerIds,omitempty"`
+	AvatarColor  string                   `json:"avatarColor,omitempty"`
+	System       bool                     `json:"system,omitempty"`
+	Status       string                   `json:"status"`
+	WorkspaceDir string                   `json:"workspaceDir"`
+	Env          map[string]string        `json:"env,omitempty"`        // per-agent env vars
+	Heartbeat    *config.HeartbeatConfig  `json:"heartbeat,omitempty"`  // built-in heartbeat config
+	ToolPolicy   json.RawMessage          `json:"toolPolicy,omitempty"` // per-agent tool permission policy
+	Generation   *config.GenerationParams `json:"generation,omitempty"` // sampling overrides on top of the model's
 }
 
 func agentToInfo(a *agent.Agent) AgentInfo {
@@ -47,6 +49,7 @@ func agentToInfo(a *agent.Agent) AgentInfo {
 		ModelID:      a.ModelID,
 		ToolIDs:      a.ToolIDs,
 		SkillIDs:     a.SkillIDs,
+		MCPServerIDs: a.MCPServerIDs,
 		AvatarColor:  a.AvatarColor,
 		System:       a.System,
 		Status:       a.Status,
@@ -54,6 +57,7 @@ func agentToInfo(a *agent.Agent) AgentInfo {
 		Env:          a.Env,
 		Heartbeat:    a.Heartbeat,
 		ToolPolicy:   a.ToolPolicyRaw,
+		Generation:   a.Generation,
 	}
 }
 
@@ -77,6 +81,7 @@ func (h *agentHandler) Create(c *gin.Context) {
 		ModelID     string          `json:"modelId"`
 		ToolIDs     []string        `json:"toolIds"`
 		SkillIDs    []string        `json:"skillIds"`
+		MCPServers  []string        `json:"mcpServerIds"`
 		AvatarColor string          `json:"avatarColor"`
 		ToolPolicy  json.RawMessage `json:"toolPolicy"`
 	}
@@ -130,6 +135,7 @@ func (h *agentHandler) Create(c *gin.Context) {
 		ModelID:       modelID,
 		ToolIDs:       req.ToolIDs,
 		SkillIDs:      req.SkillIDs,
+		MCPServerIDs:  req.MCPServers,
 		AvatarColor:   req.AvatarColor,
 		ToolPolicyRaw: toolPolicy,
 	})
@@ -232,6 +238,17 @@ func (h *agentHandler) Update(c *gin.Context) {
 			opts.SkillIDs = ids
 		}
 	}
+	if v, ok := raw["mcpServerIds"]; ok {
+		if arr, ok := v.([]interface{}); ok {
+			ids := make([]string, 0, len(arr))
+			for _, item := range arr {
+				if s, ok := item.(string); ok {
+					ids = append(ids, s)
+				}
+			}
+			opts.MCPServerIDs = ids
+		}
+	}
 	if v, ok := raw["env"]; ok {
 		// env is a map[string]string; nil value in JSON means "clear all"
 		if v == nil {
@@ -263,6 +280,18 @@ func (h *agentHandler) Update(c *gin.Context) {
 			opts.ToolPolicyRaw = json.RawMessage(b)
 		}
 	}
+	if _, ok := raw["generation"]; ok {
+		opts.GenerationSet = true
+		if raw["generation"] != nil {
+			b, _ := json.Marshal(raw["generation"])
+			var g config.GenerationParams
+			if err := json.Unmarshal(b, &g); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid generation: " + err.Error()})
+				return
+			}
+			opts.Generation = &g
+		}
+	}
 	if _, ok := raw["heartbeat"]; ok {
 		opts.HeartbeatSet = true
 		if raw["heartbeat"] == nil {
@@ -307,10 +336,10 @@ func (h *agentHandler) Delete(c *gin.Context) {
 		return
 	}
 
-	// Stop all Telegram bots for this agent before removing
+	// Stop all channel bots for this agent before removing
 	if h.botCtrl.Stop != nil {
 		for _, ch := range ag.Channels {
-			if ch.Type == "telegram" && ch.Enabled {
+			if ch.Enabled {
 				h.botCtrl.Stop(id, ch.ID)
 			}
 		}
@@ -70,8 +70,10 @@ func bodyLimitMiddleware() gin.HandlerFunc {
 		// Skip for endpoints that already manage body size:
 		// PUT /api/agents/:id/files/*path  → 5 MiB per chunk via io.LimitReader
 		// PUT /api/projects/:id/files/*path → 10 MiB per chunk via io.LimitReader
+		// PUT /api/knowledge/:id/files/*path → docextract.MaxInputBytes via http.MaxBytesReader
 		path := c.FullPath()
-		if path == "/api/agents/:id/files/*path" || path == "/api/projects/:id/files/*path" {
+		if path == "/api/agents/:id/files/*path" || path == "/api/projects/:id/files/*path" ||
+			path == "/api/knowledge/:id/files/*path" {
 			c.Next()
 			return
 		}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Zyling-ai/zyhive/pkg/channel"
+)
+
+// channelOutboxHandler exposes replies held for approval by a running
+// channel driver (channel.Outbox, e.g. email with approveReplies).
+type channelOutboxHandler struct {
+	botCtrl BotControl
+}
+
+func (h *channelOutboxHandler) outbox(c *gin.Context) (channel.Outbox, bool) {
+	if h.botCtrl.Outbox != nil {
+		if ob, ok := h.botCtrl.Outbox(c.Param("id"), c.Param("chId")); ok {
+			return ob, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "channel not running or has no outbox"})
+	return nil, false
+}
+
+// List GET /api/agents/:id/channels/:chId/outbox
+func (h *channelOutboxHandler) List(c *gin.Context) {
+	ob, ok := h.outbox(c)
+	if !ok {
+		return
+	}
+	drafts := ob.Drafts()
+	if drafts == nil {
+		drafts = []channel.OutboxDraft{}
+	}
+	c.JSON(http.StatusOK, drafts)
+}
+
+// Approve POST /api/agents/:id/channels/:chId/outbox/:draftId/approve
+// Body {"thread": true} also approves later replies in the draft's thread.
+func (h *channelOutboxHandler) Approve(c *gin.Context) {
+	ob, ok := h.outbox(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Thread bool `json:"thread"`
+	}
+	_ = c.ShouldBindJSON(&body)
+	if err := ob.ApproveDraft(c.Request.Context(), c.Param("draftId"), body.Thread); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// Discard DELETE /api/agents/:id/channels/:chId/outbox/:draftId
+func (h *channelOutboxHandler) Discard(c *gin.Context) {
+	ob, ok := h.outbox(c)
+	if !ok {
+		return
+	}
+	if err := ob.DiscardDraft(c.Param("draftId")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// channelWebhookHandler forwards GET / POST /channels/:agentId/:channelId/webhook
+// to the running driver of that channel (channel.WebhookHandler). The
+// driver authenticates the request (e.g. Slack signing secret).
+type channelWebhookHandler struct {
+	botCtrl BotControl
+}
+
+// Handle GET|POST /channels/:agentId/:channelId/webhook
+func (h *channelWebhookHandler) Handle(c *gin.Context) {
+	if h.botCtrl.Webhook == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not running"})
+		return
+	}
+	wh, ok := h.botCtrl.Webhook(c.Param("agentId"), c.Param("channelId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "channel not running or has no webhook"})
+		return
+	}
+	wh.ServeWebhook(c.Writer, c.Request)
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/Zyling-ai/zyhive/pkg/channel"
@@ -168,30 +169,8 @@ func (h *channelHandler) Test(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "channel not found"})
 		return
 	}
-	var (
-		name string
-		err  error
-	)
-	switch ch.Type {
-	case "telegram":
-		token := ch.Config["botToken"]
-		if token == "" || ismasked(token) {
-			_ = h.updateStatus(id, "error")
-			c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": "telegram botToken is required"})
-			return
-		}
-		ctx, cancel := context.WithTimeout(c.Request.Context(), 6*time.Second)
-		defer cancel()
-		name, err = channel.TestTelegramBot(ctx, token)
-	case "feishu":
-		appID, appSecret := ch.Config["appId"], ch.Config["appSecret"]
-		if appID == "" || appSecret == "" || ismasked(appSecret) {
-			_ = h.updateStatus(id, "error")
-			c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": "feishu appId and appSecret are required"})
-			return
-		}
-		name, err = channel.TestFeishuBot(appID, appSecret)
-	default:
+	spec, ok := channel.LookupDriver(ch.Type)
+	if !ok || spec.Test == nil {
 		_ = h.updateStatus(id, "error")
 		c.JSON(http.StatusNotImplemented, gin.H{
 			"valid": false,
@@ -199,6 +178,23 @@ func (h *channelHandler) Test(c *gin.Context) {
 		})
 		return
 	}
+	if !spec.Ready(ch.Config) {
+		_ = h.updateStatus(id, "error")
+		c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": ch.Type + " " + strings.Join(spec.Required, ", ") + " is required"})
+		return
+	}
+	// Secrets outside Required (e.g. email passwords) may still be UI masks.
+	for k, v := range ch.Config {
+		if ismasked(v) {
+			_ = h.updateStatus(id, "error")
+			c.JSON(http.StatusBadRequest, gin.H{"valid": false, "error": ch.Type + " " + k + " is masked"})
+			return
+		}
+	}
+	// Generous enough for the slowest probe (email dials IMAP and SMTP).
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+	name, err := spec.Test(ctx, ch.Config)
 	if err != nil {
 		_ = h.updateStatus(id, "error")
 		c.JSON(http.StatusOK, gin.H{"valid": false, "error": err.Error()})
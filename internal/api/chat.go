@@ -31,6 +31,7 @@ import (
 	"github.com/Zyling-ai/zyhive/pkg/budget"
 	"github.com/Zyling-ai/zyhive/pkg/chatlog"
 	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/knowledge"
 	"github.com/Zyling-ai/zyhive/pkg/llm"
 	"github.com/Zyling-ai/zyhive/pkg/project"
 	"github.com/Zyling-ai/zyhive/pkg/runner"
@@ -55,6 +56,9 @@ type chatHandler struct {
 	// the SSE chat handler installs a BudgetCheck adapter on the runner so
 	// turns are pre-flighted before any LLM call.
 	budgetStore *budget.Store
+	// knowledgeMgr — knowledge bases for kb_search and auto-retrieval.
+	// May be nil (disabled).
+	knowledgeMgr *knowledge.Manager
 }
 
 // Chat POST /api/agents/:id/chat
@@ -75,7 +79,10 @@ func (h *chatHandler) Chat(c *gin.Context) {
 		Scenario  string   `json:"scenario"`
 		SkillID   string   `json:"skillId"`
 		Images    []string `json:"images"`
-		History   []struct {
+		// EditMessageID replaces that earlier user message with Message and
+		// regenerates from there; the old continuation is rewound, not deleted.
+		EditMessageID string `json:"editMessageId"`
+		History       []struct {
 			Role    string `json:"role"`
 			Content string `json:"content"`
 		} `json:"history"`
@@ -95,6 +102,20 @@ func (h *chatHandler) Chat(c *gin.Context) {
 	modelBaseURL := resolvedBaseURL
 
 	store := session.NewStore(ag.SessionDir)
+	if body.EditMessageID != "" {
+		if body.SessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "editMessageId requires sessionId"})
+			return
+		}
+		if w := h.workerPool.Get(ag.ID, body.SessionID); w != nil && w.IsBusy() {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is generating"})
+			return
+		}
+		if _, err := store.Rewind(body.SessionID, body.EditMessageID, false); err != nil {
+			c.JSON(branchErrStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+	}
 	sessionID, _, err := store.GetOrCreate(body.SessionID, ag.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "session error: " + err.Error()})
@@ -124,7 +145,7 @@ func (h *chatHandler) Chat(c *gin.Context) {
 		return h.execRunner(ctx, agID, workspaceDir, sessionDir, model, apiKey,
 			modelProvider, modelBaseURL,
 			sid, message, extraContext, scenario, skillID, images, legacyHist, agEnv, bc,
-			modelSupportsTools)
+			modelSupportsTools, me)
 	}
 
 	worker := h.workerPool.GetOrCreate(ag.ID, sessionID)
@@ -240,8 +261,9 @@ func (h *chatHandler) execRunner(
 	agEnv map[string]string,
 	bc *session.Broadcaster,
 	supportsTools bool,
+	modelEntry *config.ModelEntry,
 ) error {
-	llmClient := llm.NewClient(provider, baseURL)
+	llmClient := agent.NewModelClient(h.cfg, modelEntry, apiKey, baseURL)
 	store := session.NewStore(sessionDir)
 
 	var toolRegistry *tools.Registry
@@ -252,6 +274,9 @@ func (h *chatHandler) execRunner(
 		if h.projectMgr != nil {
 			toolRegistry.WithProjectAccess(h.projectMgr)
 		}
+		if h.knowledgeMgr != nil {
+			toolRegistry.WithKnowledgeSearch(h.knowledgeMgr, agentID)
+		}
 	}
 	if len(agEnv) > 0 {
 		toolRegistry.WithEnv(agEnv)
@@ -322,6 +347,9 @@ func (h *chatHandler) execRunner(
 	var agentPolicy json.RawMessage
 	if ag, ok := h.manager.Get(agentID); ok {
 		agentPolicy = ag.ToolPolicyRaw
+		if scenario != "skill-studio" && len(ag.MCPServerIDs) > 0 {
+			toolRegistry.WithMCP(MCPManager(), ag.MCPServerIDs)
+		}
 	}
 	if err := toolRegistry.ConfigureGovernance(
 		h.cfg.ToolPolicyRaw,
@@ -344,22 +372,11 @@ func (h *chatHandler) execRunner(
 	}
 
 	// 构造 UsageRecorder (chat API 之前遗漏此字段, 导致所有对话 output_tokens 记录为 0)
-	var usageRec func(in, out int, provider, model, agentID, sessionID string)
+	var usageRec func(u llm.Usage, provider, model, agentID, sessionID string)
 	if h.usageStore != nil {
 		us := h.usageStore
-		usageRec = func(in, out int, providerIn, modelIn, agentIDIn, sessionIDIn string) {
-			rec := usage.Record{
-				ID:           usage.NewID(),
-				AgentID:      agentIDIn,
-				SessionID:    sessionIDIn,
-				Provider:     providerIn,
-				Model:        modelIn,
-				InputTokens:  in,
-				OutputTokens: out,
-				Cost:         usage.EstimateCost(modelIn, in, out),
-				CreatedAt:    time.Now().Unix(),
-			}
-			_ = us.Append(rec)
+		usageRec = func(u llm.Usage, providerIn, modelIn, agentIDIn, sessionIDIn string) {
+			_ = us.Append(agent.NewUsageRecord(h.cfg, u, providerIn, modelIn, agentIDIn, sessionIDIn))
 		}
 	}
 	// 构造能力上下文（工具体检 + WISHLIST）让 AI 感知真实能力边界
@@ -368,6 +385,10 @@ func (h *chatHandler) execRunner(
 	if ag != nil {
 		capCtx = agent.BuildCapabilitiesContext(toolRegistry, ag, h.cfg, workspaceDir)
 	}
+	var retrieve func(ctx context.Context, query string) string
+	if h.knowledgeMgr != nil && scenario != "skill-studio" {
+		retrieve = h.knowledgeMgr.Retriever(agentID)
+	}
 	r := runner.New(runner.Config{
 		AgentID:               agentID,
 		WorkspaceDir:          workspaceDir,
@@ -383,9 +404,12 @@ func (h *chatHandler) execRunner(
 		Images:                images,
 		PreloadedHistory:      preHistory,
 		ProjectContext:        runner.BuildProjectContext(h.projectMgr, agentID),
+		Retrieve:              retrieve,
 		AgentEnv:              agEnv,
 		UsageRecorder:         usageRec,
 		BudgetCheck:           h.budgetCheckAdapter(),
+		PrepareRequest:        agent.GenerationHook(modelEntry, ag),
+		CompactionThreshold:   agent.CompactionThreshold(modelEntry),
 		CapabilitiesContext:   capCtx,
 		CurrentSessionContext: agent.BuildSessionContext(store, sessionID),
 		ToolAudit:             toolaudit.New(filepath.Dir(workspaceDir)),
@@ -440,6 +464,13 @@ func runEventToJSON(ev runner.RunEvent) []byte {
 	switch ev.Type {
 	case "text_delta", "thinking_delta":
 		m["text"] = ev.Text
+	case "model_switch":
+		m["text"] = ev.Text
+		if ev.ModelSwitch != nil {
+			m["from_model"] = ev.ModelSwitch.FromModel
+			m["to_model"] = ev.ModelSwitch.ToModel
+			m["reason"] = ev.ModelSwitch.Reason
+		}
 	case "tool_result":
 		m["text"] = ev.Text
 		// 并行 tool 场景下前端必须按此 ID 精准匹配, 不能靠 activeToolId 猜测
@@ -574,17 +605,22 @@ func (h *chatHandler) GetSession(c *gin.Context) {
 
 	// Convert raw JSONL entries to UI-friendly {messages:[{role,text,toolCalls,isCompact}]} format.
 	type UIMessage struct {
+		ID        string                   `json:"id,omitempty"`
 		Role      string                   `json:"role"`
 		Text      string                   `json:"text"`
 		ToolCalls []session.ToolCallRecord `json:"toolCalls,omitempty"`
 		IsCompact bool                     `json:"isCompact,omitempty"`
+		RewoundAt int64                    `json:"rewoundAt,omitempty"`
 	}
 	type UISession struct {
 		Messages []UIMessage `json:"messages"`
 	}
 
+	// Messages hidden by a rewind are only listed with ?rewound=1.
+	withRewound := c.Query("rewound") == "1"
+	ids := session.MessageIDs(entries)
 	var msgs []UIMessage
-	for _, raw := range entries {
+	for i, raw := range entries {
 		var base struct {
 			Type string `json:"type"`
 		}
@@ -597,12 +633,17 @@ func (h *chatHandler) GetSession(c *gin.Context) {
 			if err2 := json.Unmarshal(raw, &me); err2 != nil {
 				continue
 			}
+			if me.RewoundAt != 0 && !withRewound {
+				continue
+			}
 			// Extract displayable text from content (string or block array)
 			text := extractTextFromContent(me.Message.Content)
 			msgs = append(msgs, UIMessage{
+				ID:        ids[i],
 				Role:      me.Message.Role,
 				Text:      text,
 				ToolCalls: me.Message.ToolCalls,
+				RewoundAt: me.RewoundAt,
 			})
 		case "compaction":
 			msgs = append(msgs, UIMessage{
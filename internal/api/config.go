@@ -77,6 +77,19 @@ func (h *configHandler) Get(c *gin.Context) {
 		maskedTools[i].APIKey = maskKey(maskedTools[i].APIKey)
 	}
 	safe.Tools = maskedTools
+	// Mask MCP server headers / secret env values
+	maskedMCP := make([]config.MCPServerEntry, len(safe.MCPServers))
+	for i := range safe.MCPServers {
+		maskedMCP[i] = maskMCPServer(safe.MCPServers[i])
+	}
+	safe.MCPServers = maskedMCP
+	// Mask scoped MCP server tokens
+	maskedServe := make([]config.MCPServeToken, len(safe.MCPServe.Tokens))
+	copy(maskedServe, safe.MCPServe.Tokens)
+	for i := range maskedServe {
+		maskedServe[i].Token = maskKey(maskedServe[i].Token)
+	}
+	safe.MCPServe.Tokens = maskedServe
 	data, err := json.Marshal(safe)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -143,6 +156,17 @@ func (h *configHandler) Patch(c *gin.Context) {
 		if err := json.Unmarshal(merged, &updated); err != nil {
 			return fmt.Errorf("invalid config: %w", err)
 		}
+		// Scoped MCP tokens echoed back masked keep their stored value.
+		for i := range updated.MCPServe.Tokens {
+			if !ismasked(updated.MCPServe.Tokens[i].Token) {
+				continue
+			}
+			for _, existing := range candidate.MCPServe.Tokens {
+				if existing.ID == updated.MCPServe.Tokens[i].ID {
+					updated.MCPServe.Tokens[i].Token = existing.Token
+				}
+			}
+		}
 		if _, err := tools.DecodeToolPolicy(updated.ToolPolicyRaw); err != nil {
 			return fmt.Errorf("invalid toolPolicy: %w", err)
 		}
@@ -208,6 +232,8 @@ func (h *configHandler) TestKey(c *gin.Context) {
 		valid, errMsg = testOpenAIKey(req.Key)
 	case "deepseek":
 		valid, errMsg = testDeepSeekKey(req.Key)
+	case "gemini", "google":
+		valid, errMsg = testGeminiKey(req.Key, "")
 	default:
 		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported provider: " + req.Provider})
 		return
@@ -326,6 +352,15 @@ func testOpenAICompatKey(provider, key, baseURL string) (bool, string) {
 	return false, fmt.Sprintf("status %d: %s", resp.StatusCode, string(respBody))
 }
 
+// testGeminiKey validates a Gemini API key via the native models listing
+// (see llm.Ping); Gemini has no OpenAI-style Bearer /models endpoint.
+func testGeminiKey(key, baseURL string) (bool, string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	r := llm.Ping(ctx, "gemini", key, baseURL, true)
+	return r.OK, r.Error
+}
+
 // testMiniMaxKey validates a MiniMax API key via a minimal chat completion request.
 // MiniMax 不支持 GET /v1/models，用 POST /v1/chat/completions + max_tokens=1 探测。
 func testMiniMaxKey(key, baseURL string) (bool, string) {
@@ -380,6 +415,8 @@ func defaultBaseURLForProvider(provider string) string {
 		return "https://dashscope.aliyuncs.com/compatible-mode/v1"
 	case "openrouter":
 		return "https://openrouter.ai/api/v1"
+	case "gemini", "google":
+		return "https://generativelanguage.googleapis.com/v1beta"
 	case "ollama":
 		return "http://localhost:11434/v1"
 	default:
@@ -0,0 +1,185 @@
+// internal/api/evals.go — offline eval datasets, runs and run comparison.
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/eval"
+	"github.com/gin-gonic/gin"
+)
+
+type evalHandler struct {
+	store *eval.Store
+	cfg   *config.Config
+	mgr   *agent.Manager
+	pool  *agent.Pool
+}
+
+func (h *evalHandler) fail(c *gin.Context, err error) {
+	if errors.Is(err, eval.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// ListDatasets GET /api/evals/datasets
+func (h *evalHandler) ListDatasets(c *gin.Context) {
+	list, err := h.store.ListDatasets()
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	if list == nil {
+		list = []eval.Dataset{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetDataset GET /api/evals/datasets/:id
+func (h *evalHandler) GetDataset(c *gin.Context) {
+	ds, err := h.store.GetDataset(c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, ds)
+}
+
+// SaveDataset POST /api/evals/datasets and PUT /api/evals/datasets/:id
+func (h *evalHandler) SaveDataset(c *gin.Context) {
+	var ds eval.Dataset
+	if err := c.ShouldBindJSON(&ds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if id := c.Param("id"); id != "" {
+		ds.ID = id
+	}
+	if ds.AgentID != "" {
+		if _, ok := h.mgr.Get(ds.AgentID); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "agent not found: " + ds.AgentID})
+			return
+		}
+	}
+	if err := h.store.SaveDataset(&ds); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, ds)
+}
+
+// DeleteDataset DELETE /api/evals/datasets/:id
+func (h *evalHandler) DeleteDataset(c *gin.Context) {
+	if err := h.store.DeleteDataset(c.Param("id")); err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// StartRun POST /api/evals/runs — body {datasetId, agentId?, model?, label?,
+// caseIds?}. The run executes in the background; poll GET /evals/runs/:id
+// until status leaves "running".
+func (h *evalHandler) StartRun(c *gin.Context) {
+	var req struct {
+		DatasetID string   `json:"datasetId"`
+		AgentID   string   `json:"agentId"`
+		Model     string   `json:"model"`
+		Label     string   `json:"label"`
+		CaseIDs   []string `json:"caseIds"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ds, err := h.store.GetDataset(req.DatasetID)
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	opts := eval.RunOpts{AgentID: req.AgentID, Model: req.Model, Label: req.Label, CaseIDs: req.CaseIDs}
+	agentID := opts.AgentID
+	if agentID == "" {
+		agentID = ds.AgentID
+	}
+	if _, ok := h.mgr.Get(agentID); !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "agent not found: " + agentID})
+		return
+	}
+	if opts.Model != "" && opts.Model != eval.ScriptedModel && h.cfg.FindModel(opts.Model) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "model not found: " + opts.Model})
+		return
+	}
+
+	var grader eval.Grader
+	if scorer, err := eval.NewJudge(h.cfg, ""); err != nil {
+		log.Printf("[eval] llm_judge disabled: %v", err)
+	} else {
+		grader.Judge = scorer
+	}
+	pending := &eval.Run{
+		ID:          eval.NewRunID(),
+		DatasetID:   ds.ID,
+		DatasetName: ds.Name,
+		AgentID:     agentID,
+		Model:       opts.Model,
+		Label:       opts.Label,
+		Status:      eval.RunRunning,
+	}
+	if err := h.store.SaveRun(pending); err != nil {
+		h.fail(c, err)
+		return
+	}
+	harness := eval.NewHarness(h.pool, &grader)
+	go func() {
+		run := harness.RunWithID(context.Background(), pending.ID, ds, opts)
+		if err := h.store.SaveRun(run); err != nil {
+			log.Printf("[eval] save run %s: %v", run.ID, err)
+		}
+	}()
+	c.JSON(http.StatusAccepted, pending)
+}
+
+// ListRuns GET /api/evals/runs?datasetId= — summaries, newest first.
+func (h *evalHandler) ListRuns(c *gin.Context) {
+	runs, err := h.store.ListRuns(c.Query("datasetId"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	if runs == nil {
+		runs = []eval.Run{}
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GetRun GET /api/evals/runs/:id — full case results.
+func (h *evalHandler) GetRun(c *gin.Context) {
+	run, err := h.store.GetRun(c.Param("id"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// Compare GET /api/evals/compare?base=RUN&head=RUN — side-by-side report.
+func (h *evalHandler) Compare(c *gin.Context) {
+	base, err := h.store.GetRun(c.Query("base"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	head, err := h.store.GetRun(c.Query("head"))
+	if err != nil {
+		h.fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, eval.Compare(base, head))
+}
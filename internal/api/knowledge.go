@@ -0,0 +1,258 @@
+// Knowledge base handlers — CRUD, documents, web sources, reindex and search.
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Zyling-ai/zyhive/pkg/docextract"
+	"github.com/Zyling-ai/zyhive/pkg/knowledge"
+	"github.com/gin-gonic/gin"
+)
+
+type knowledgeHandler struct {
+	mgr *knowledge.Manager
+}
+
+// knowledgeError maps a manager error to a status code.
+func knowledgeError(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	switch {
+	case errors.Is(err, knowledge.ErrNotFound), errors.Is(err, os.ErrNotExist):
+		status = http.StatusNotFound
+	case strings.Contains(err.Error(), "already exists"):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// List GET /api/knowledge?agentId=
+func (h *knowledgeHandler) List(c *gin.Context) {
+	list := h.mgr.List()
+	if agentID := c.Query("agentId"); agentID != "" {
+		list = h.mgr.ForAgent(agentID)
+	}
+	if list == nil {
+		list = []*knowledge.KB{}
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// Create POST /api/knowledge {id?, name, description, agentIds, projects, autoRetrieve, autoTopK}
+func (h *knowledgeHandler) Create(c *gin.Context) {
+	var req struct {
+		ID           string   `json:"id"`
+		Name         string   `json:"name" binding:"required"`
+		Description  string   `json:"description"`
+		AgentIDs     []string `json:"agentIds"`
+		Projects     []string `json:"projects"`
+		AutoRetrieve bool     `json:"autoRetrieve"`
+		AutoTopK     int      `json:"autoTopK"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	kb, err := h.mgr.Create(knowledge.CreateOpts{
+		ID: req.ID, Name: req.Name, Description: req.Description,
+		AgentIDs: req.AgentIDs, Projects: req.Projects,
+		AutoRetrieve: req.AutoRetrieve, AutoTopK: req.AutoTopK,
+	})
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	if len(kb.Projects) > 0 {
+		h.mgr.ReindexIfStale(kb.ID)
+	}
+	c.JSON(http.StatusCreated, kb)
+}
+
+// Get GET /api/knowledge/:id  → the knowledge base plus its uploaded files
+func (h *knowledgeHandler) Get(c *gin.Context) {
+	kb, ok := h.mgr.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "knowledge base not found"})
+		return
+	}
+	files, err := h.mgr.Files(kb.ID)
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"knowledgeBase": kb, "files": files})
+}
+
+// Update PATCH /api/knowledge/:id  (absent fields are left unchanged)
+func (h *knowledgeHandler) Update(c *gin.Context) {
+	var req struct {
+		Name         *string   `json:"name"`
+		Description  *string   `json:"description"`
+		AgentIDs     *[]string `json:"agentIds"`
+		Projects     *[]string `json:"projects"`
+		AutoRetrieve *bool     `json:"autoRetrieve"`
+		AutoTopK     *int      `json:"autoTopK"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	kb, err := h.mgr.Update(c.Param("id"), knowledge.UpdateOpts{
+		Name: req.Name, Description: req.Description,
+		AgentIDs: req.AgentIDs, Projects: req.Projects,
+		AutoRetrieve: req.AutoRetrieve, AutoTopK: req.AutoTopK,
+	})
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	if req.Projects != nil {
+		h.mgr.ReindexIfStale(kb.ID)
+	}
+	c.JSON(http.StatusOK, kb)
+}
+
+// Delete DELETE /api/knowledge/:id
+func (h *knowledgeHandler) Delete(c *gin.Context) {
+	if err := h.mgr.Delete(c.Param("id")); err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// Files GET /api/knowledge/:id/files
+func (h *knowledgeHandler) Files(c *gin.Context) {
+	files, err := h.mgr.Files(c.Param("id"))
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, files)
+}
+
+// Upload PUT /api/knowledge/:id/files/*path  (raw body = document bytes)
+// Stores or replaces a document; it is indexed in the background.
+func (h *knowledgeHandler) Upload(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, docextract.MaxInputBytes))
+	if err != nil {
+		if IsBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "document too large"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	id := c.Param("id")
+	if err := h.mgr.AddFile(id, c.Param("path"), body); err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	h.mgr.ReindexIfStale(id)
+	c.JSON(http.StatusOK, gin.H{"ok": true, "size": len(body)})
+}
+
+// RemoveFile DELETE /api/knowledge/:id/files/*path
+func (h *knowledgeHandler) RemoveFile(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.mgr.RemoveFile(id, c.Param("path")); err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	h.mgr.ReindexIfStale(id)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// AddURL POST /api/knowledge/:id/urls {url, depth}
+// Adds a web source; its pages are fetched and indexed in the background.
+func (h *knowledgeHandler) AddURL(c *gin.Context) {
+	var req struct {
+		URL   string `json:"url" binding:"required"`
+		Depth int    `json:"depth"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	kb, err := h.mgr.AddURL(c.Param("id"), req.URL, req.Depth)
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	h.mgr.ReindexIfStale(kb.ID)
+	c.JSON(http.StatusOK, kb)
+}
+
+// RemoveURL DELETE /api/knowledge/:id/urls?url=
+func (h *knowledgeHandler) RemoveURL(c *gin.Context) {
+	kb, err := h.mgr.RemoveURL(c.Param("id"), c.Query("url"))
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	h.mgr.ReindexIfStale(kb.ID)
+	c.JSON(http.StatusOK, kb)
+}
+
+// Reindex POST /api/knowledge/:id/reindex {refreshWeb}
+// Updates the index now and returns what changed. Only documents whose
+// content changed are re-embedded; refreshWeb re-fetches the web sources.
+func (h *knowledgeHandler) Reindex(c *gin.Context) {
+	var req struct {
+		RefreshWeb bool `json:"refreshWeb"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	id := c.Param("id")
+	st, err := h.mgr.Reindex(c.Request.Context(), id, req.RefreshWeb)
+	if err != nil {
+		if errors.Is(err, knowledge.ErrNotFound) {
+			knowledgeError(c, err)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	kb, _ := h.mgr.Get(id)
+	c.JSON(http.StatusOK, gin.H{
+		"added": st.Added, "changed": st.Changed, "removed": st.Removed,
+		"embedded": st.Embedded, "compacted": st.Compacted,
+		"knowledgeBase": kb,
+	})
+}
+
+// Search GET /api/knowledge/:id/search?q=&topK=
+func (h *knowledgeHandler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	topK, _ := strconv.Atoi(c.Query("topK"))
+	if topK <= 0 || topK > 50 {
+		topK = 5
+	}
+	passages, err := h.mgr.Search(c.Request.Context(), []string{c.Param("id")}, q, topK)
+	if err != nil {
+		knowledgeError(c, err)
+		return
+	}
+	if passages == nil {
+		passages = []knowledge.Passage{}
+	}
+	type result struct {
+		knowledge.Passage
+		Citation string `json:"citation"`
+	}
+	out := make([]result, len(passages))
+	for i, p := range passages {
+		out[i] = result{p, p.Citation()}
+	}
+	c.JSON(http.StatusOK, gin.H{"results": out, "total": len(out)})
+}
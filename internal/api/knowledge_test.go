@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/knowledge"
+	"github.com/gin-gonic/gin"
+)
+
+func TestKnowledgeUploadReindexSearch(t *testing.T) {
+	mgr := knowledge.NewManager(t.TempDir(), nil)
+	h := &knowledgeHandler{mgr: mgr}
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/api/knowledge", h.Create)
+	r.PUT("/api/knowledge/:id/files/*path", h.Upload)
+	r.POST("/api/knowledge/:id/reindex", h.Reindex)
+	r.GET("/api/knowledge/:id/search", h.Search)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	w := do(http.MethodPost, "/api/knowledge", `{"id":"support","name":"客服","agentIds":["main"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create = %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, "/api/knowledge", `{"id":"support","name":"again"}`); w.Code != http.StatusConflict {
+		t.Errorf("duplicate create = %d", w.Code)
+	}
+	if w := do(http.MethodPut, "/api/knowledge/support/files/faq/returns.md", "# Returns\n\nOpened items can be returned within thirty days.\n"); w.Code != http.StatusOK {
+		t.Fatalf("upload = %d %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPut, "/api/knowledge/support/files/../meta.json", "{}"); w.Code != http.StatusBadRequest {
+		t.Errorf("escaping upload = %d", w.Code)
+	}
+	if w := do(http.MethodPost, "/api/knowledge/missing/reindex", ""); w.Code != http.StatusNotFound {
+		t.Errorf("reindex missing = %d", w.Code)
+	}
+
+	w = do(http.MethodPost, "/api/knowledge/support/reindex", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("reindex = %d %s", w.Code, w.Body.String())
+	}
+	w = do(http.MethodGet, "/api/knowledge/support/search?q=returned+thirty", "")
+	var resp struct {
+		Results []struct {
+			Citation string `json:"citation"`
+		} `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Results) == 0 {
+		t.Fatalf("search = %d %s", w.Code, w.Body.String())
+	}
+	if got := resp.Results[0].Citation; got != "files/faq/returns.md:3" {
+		t.Errorf("citation = %q", got)
+	}
+}
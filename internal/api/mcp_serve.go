@@ -0,0 +1,82 @@
+// Package api — MCP server endpoint (streamable HTTP).
+// POST /mcp exposes agents as ask_<agentId> tools plus session / memory /
+// project resources to external MCP clients. The stdio flavour of the same
+// server is `aipanel mcp` (cmd/aipanel/mcp_cmd.go).
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
+	"github.com/gin-gonic/gin"
+)
+
+// maxMCPRequestBytes caps one JSON-RPC message. It applies even when the
+// global bodyLimitMiddleware is switched off (ZYHIVE_MAX_REQUEST_BODY_MB=0),
+// since /mcp accepts scoped tokens and not just the admin token.
+const maxMCPRequestBytes = 4 << 20
+
+type mcpServeHandler struct {
+	cfg        *config.Config
+	adminToken string // token active at startup, same as authMiddleware
+	server     *mcp.Server
+}
+
+// scope authenticates the bearer token: the admin token gets full access,
+// mcpServe.tokens get their configured scope.
+func (h *mcpServeHandler) scope(c *gin.Context) (mcp.Scope, bool) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok {
+		return mcp.Scope{}, false
+	}
+	snapshot, err := config.Snapshot(h.cfg)
+	if err != nil {
+		return mcp.Scope{}, false
+	}
+	snapshot.Auth.Token = h.adminToken
+	return mcp.ResolveScope(snapshot, token)
+}
+
+// Post POST /mcp — one JSON-RPC message in, one JSON response out.
+// Notifications are acknowledged with 202. Stateless: no Mcp-Session-Id.
+func (h *mcpServeHandler) Post(c *gin.Context) {
+	scope, ok := h.scope(c)
+	if !ok {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxMCPRequestBytes))
+	if err != nil {
+		if IsBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request too large"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	reply := h.server.Handle(c.Request.Context(), scope, body)
+	if reply == nil {
+		c.Status(http.StatusAccepted)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", reply)
+}
+
+// Stream GET /mcp — server-initiated streams are not offered.
+func (h *mcpServeHandler) Stream(c *gin.Context) {
+	c.Header("Allow", "POST, DELETE")
+	c.Status(http.StatusMethodNotAllowed)
+}
+
+// End DELETE /mcp — nothing to tear down for a stateless server.
+func (h *mcpServeHandler) End(c *gin.Context) {
+	if _, ok := h.scope(c); !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	c.Status(http.StatusOK)
+}
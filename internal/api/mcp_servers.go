@@ -0,0 +1,366 @@
+// Package api — MCP servers CRUD handler.
+// MCP servers are external Model Context Protocol tool providers (stdio
+// subprocesses or streamable HTTP endpoints). Their tools are mounted into
+// agents that list the server in mcpServerIds.
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
+	"github.com/Zyling-ai/zyhive/pkg/safefs"
+	"github.com/gin-gonic/gin"
+)
+
+// globalMCPManager is the process-wide MCP client manager injected from main.go.
+var globalMCPManager *mcp.Manager
+
+// SetMCPManager wires the MCP manager so chat.go can mount MCP tools and the
+// handlers below can reconnect servers after config edits.
+func SetMCPManager(m *mcp.Manager) {
+	globalMCPManager = m
+}
+
+// MCPManager returns the global MCP manager (may be nil).
+func MCPManager() *mcp.Manager {
+	return globalMCPManager
+}
+
+type mcpServerHandler struct {
+	cfg     *config.Config
+	cfgPath string
+}
+
+// mcpServerView is a config entry with secrets masked plus live status.
+type mcpServerView struct {
+	config.MCPServerEntry
+	Connected bool   `json:"connected"`
+	ToolCount int    `json:"toolCount"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+// List GET /api/mcp-servers
+func (h *mcpServerHandler) List(c *gin.Context) {
+	snapshot, err := config.Snapshot(h.cfg)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	status := make(map[string]mcp.ServerStatus)
+	if globalMCPManager != nil {
+		for _, s := range globalMCPManager.Status() {
+			status[s.ID] = s
+		}
+	}
+	out := make([]mcpServerView, 0, len(snapshot.MCPServers))
+	for _, entry := range snapshot.MCPServers {
+		st := status[entry.ID]
+		out = append(out, mcpServerView{
+			MCPServerEntry: maskMCPServer(entry),
+			Connected:      st.Connected,
+			ToolCount:      st.Tools,
+			LastError:      st.Error,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// Tools GET /api/mcp-servers/:id/tools — discovered tools (namespaced names).
+func (h *mcpServerHandler) Tools(c *gin.Context) {
+	if globalMCPManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "mcp manager not initialised"})
+		return
+	}
+	type toolView struct {
+		Name        string `json:"name"`
+		Remote      string `json:"remote"`
+		Description string `json:"description,omitempty"`
+	}
+	mounted := globalMCPManager.Tools([]string{c.Param("id")})
+	out := make([]toolView, 0, len(mounted))
+	for _, mt := range mounted {
+		out = append(out, toolView{Name: mt.Name, Remote: mt.Tool.Name, Description: mt.Tool.Description})
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// Create POST /api/mcp-servers
+func (h *mcpServerHandler) Create(c *gin.Context) {
+	var entry config.MCPServerEntry
+	if err := c.ShouldBindJSON(&entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if entry.ID == "" {
+		entry.ID = fmt.Sprintf("mcp-%d", time.Now().UnixNano()%1_000_000_000)
+	}
+	if err := validateMCPServer(entry); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	entry.Status = "untested"
+
+	err := config.Transaction(h.path(), h.cfg, func(candidate *config.Config) error {
+		for _, existing := range candidate.MCPServers {
+			if existing.ID == entry.ID {
+				return errMCPServerExists
+			}
+		}
+		candidate.MCPServers = append(candidate.MCPServers, entry)
+		return nil
+	})
+	if errors.Is(err, errMCPServerExists) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.restart(entry)
+	c.JSON(http.StatusOK, maskMCPServer(entry))
+}
+
+// Update PATCH /api/mcp-servers/:id — only the fields present in the body
+// change; masked header/env values are kept.
+func (h *mcpServerHandler) Update(c *gin.Context) {
+	id := c.Param("id")
+	var body struct {
+		Name      *string            `json:"name"`
+		Transport *string            `json:"transport"`
+		Command   *string            `json:"command"`
+		Args      *[]string          `json:"args"`
+		Env       *[]string          `json:"env"`
+		URL       *string            `json:"url"`
+		Headers   *map[string]string `json:"headers"`
+		Enabled   *bool              `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var updated config.MCPServerEntry
+	var invalid error
+	err := config.Transaction(h.path(), h.cfg, func(candidate *config.Config) error {
+		for i := range candidate.MCPServers {
+			if candidate.MCPServers[i].ID != id {
+				continue
+			}
+			current := candidate.MCPServers[i]
+			next := current
+			if body.Name != nil {
+				next.Name = *body.Name
+			}
+			if body.Transport != nil {
+				next.Transport = *body.Transport
+			}
+			if body.Command != nil {
+				next.Command = *body.Command
+			}
+			if body.Args != nil {
+				next.Args = *body.Args
+			}
+			if body.Env != nil {
+				next.Env = *body.Env
+			}
+			if body.URL != nil {
+				next.URL = *body.URL
+			}
+			if body.Headers != nil {
+				next.Headers = *body.Headers
+			}
+			if body.Enabled != nil {
+				next.Enabled = *body.Enabled
+			}
+			next = unmaskMCPServer(next, current)
+			if invalid = validateMCPServer(next); invalid != nil {
+				return invalid
+			}
+			candidate.MCPServers[i] = next
+			updated = next
+			return nil
+		}
+		return errMCPServerNotFound
+	})
+	if errors.Is(err, errMCPServerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "MCP server not found"})
+		return
+	}
+	if invalid != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": invalid.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.restart(updated)
+	c.JSON(http.StatusOK, maskMCPServer(updated))
+}
+
+// Delete DELETE /api/mcp-servers/:id
+func (h *mcpServerHandler) Delete(c *gin.Context) {
+	id := c.Param("id")
+	err := config.Transaction(h.path(), h.cfg, func(candidate *config.Config) error {
+		newList := make([]config.MCPServerEntry, 0, len(candidate.MCPServers))
+		found := false
+		for _, entry := range candidate.MCPServers {
+			if entry.ID == id {
+				found = true
+			} else {
+				newList = append(newList, entry)
+			}
+		}
+		if !found {
+			return errMCPServerNotFound
+		}
+		candidate.MCPServers = newList
+		return nil
+	})
+	if errors.Is(err, errMCPServerNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "MCP server not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if globalMCPManager != nil {
+		globalMCPManager.Remove(id)
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// Reload POST /api/mcp-servers/reload — reconnect every server and rediscover tools.
+func (h *mcpServerHandler) Reload(c *gin.Context) {
+	h.reconnect()
+	h.List(c)
+}
+
+func (h *mcpServerHandler) path() string {
+	if h.cfgPath == "" {
+		return "aipanel.json"
+	}
+	return h.cfgPath
+}
+
+// reconnect restarts the manager against the current config and persists
+// the resulting per-server status. New runs pick up the new tool set.
+func (h *mcpServerHandler) reconnect() {
+	if globalMCPManager == nil {
+		return
+	}
+	snapshot, err := config.Snapshot(h.cfg)
+	if err != nil {
+		return
+	}
+	globalMCPManager.Start(context.Background(), snapshot.MCPServers)
+	h.saveStatus()
+}
+
+// restart reconnects only the edited server, in the background: connecting
+// a stdio server or a slow endpoint can take up to the connect timeout, and
+// the other servers keep running untouched. The status lands in the config
+// (and List) once the connect finishes.
+func (h *mcpServerHandler) restart(entry config.MCPServerEntry) {
+	if globalMCPManager == nil {
+		return
+	}
+	go func() {
+		globalMCPManager.Restart(context.Background(), entry)
+		h.saveStatus()
+	}()
+}
+
+// saveStatus persists each running server's connect result as "ok"/"error".
+func (h *mcpServerHandler) saveStatus() {
+	status := make(map[string]string)
+	for _, s := range globalMCPManager.Status() {
+		if s.Connected {
+			status[s.ID] = "ok"
+		} else {
+			status[s.ID] = "error"
+		}
+	}
+	_ = config.Transaction(h.path(), h.cfg, func(candidate *config.Config) error {
+		for i := range candidate.MCPServers {
+			if s, ok := status[candidate.MCPServers[i].ID]; ok {
+				candidate.MCPServers[i].Status = s
+			}
+		}
+		return nil
+	})
+}
+
+func validateMCPServer(entry config.MCPServerEntry) error {
+	if err := safefs.ValidateResourceID(entry.ID); err != nil || entry.ID == "*" {
+		return fmt.Errorf("invalid id %q", entry.ID)
+	}
+	switch entry.Transport {
+	case "", "stdio":
+		if entry.Command == "" {
+			return errors.New("command is required for stdio transport")
+		}
+	case "http", "streamable-http":
+		if !strings.HasPrefix(entry.URL, "http://") && !strings.HasPrefix(entry.URL, "https://") {
+			return errors.New("url must be http(s) for http transport")
+		}
+	default:
+		return fmt.Errorf("unknown transport %q", entry.Transport)
+	}
+	return nil
+}
+
+// maskMCPServer hides header values and secret-looking env values.
+func maskMCPServer(entry config.MCPServerEntry) config.MCPServerEntry {
+	if len(entry.Headers) > 0 {
+		headers := make(map[string]string, len(entry.Headers))
+		for k, v := range entry.Headers {
+			headers[k] = maskKey(v)
+		}
+		entry.Headers = headers
+	}
+	if len(entry.Env) > 0 {
+		env := make([]string, len(entry.Env))
+		for i, kv := range entry.Env {
+			if k, v, ok := strings.Cut(kv, "="); ok && isSecretField(k) {
+				kv = k + "=" + maskKey(v)
+			}
+			env[i] = kv
+		}
+		entry.Env = env
+	}
+	return entry
+}
+
+// unmaskMCPServer restores secrets that the client echoed back masked.
+func unmaskMCPServer(patch, current config.MCPServerEntry) config.MCPServerEntry {
+	for k, v := range patch.Headers {
+		if ismasked(v) {
+			patch.Headers[k] = current.Headers[k]
+		}
+	}
+	currentEnv := make(map[string]string, len(current.Env))
+	for _, kv := range current.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			currentEnv[k] = v
+		}
+	}
+	for i, kv := range patch.Env {
+		if k, v, ok := strings.Cut(kv, "="); ok && ismasked(v) {
+			patch.Env[i] = k + "=" + currentEnv[k]
+		}
+	}
+	return patch
+}
+
+var (
+	errMCPServerExists   = errors.New("MCP server id already exists")
+	errMCPServerNotFound = errors.New("MCP server not found")
+)
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/gin-gonic/gin"
+)
+
+func TestMCPServerUpdateIsPartial(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.MCPServers = []config.MCPServerEntry{{
+		ID: "gh", Name: "GitHub", Transport: "stdio", Command: "gh-mcp",
+		Env: []string{"GITHUB_TOKEN=ghp_secretvalue"}, Enabled: true,
+	}}
+	handler := &mcpServerHandler{cfg: cfg, cfgPath: filepath.Join(t.TempDir(), "aipanel.json")}
+	patch := func(body string) int {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Params = gin.Params{{Key: "id", Value: "gh"}}
+		ctx.Request = httptest.NewRequest(http.MethodPatch, "/api/mcp-servers/gh", strings.NewReader(body))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		handler.Update(ctx)
+		return recorder.Code
+	}
+
+	if code := patch(`{"enabled":false}`); code != http.StatusOK {
+		t.Fatalf("enabled-only patch: status %d", code)
+	}
+	got := cfg.MCPServers[0]
+	if got.Enabled || got.Command != "gh-mcp" || got.Name != "GitHub" || len(got.Env) != 1 || got.Env[0] != "GITHUB_TOKEN=ghp_secretvalue" {
+		t.Fatalf("partial patch clobbered the entry: %+v", got)
+	}
+	if code := patch(`{"command":""}`); code != http.StatusBadRequest {
+		t.Fatalf("clearing command: status %d, want 400", code)
+	}
+	if cfg.MCPServers[0].Command != "gh-mcp" {
+		t.Fatalf("rejected patch was applied: %+v", cfg.MCPServers[0])
+	}
+}
@@ -127,3 +127,40 @@ func TestProviderAndModelCreateRejectPrivateBaseURL(t *testing.T) {
 		t.Fatalf("model status=%d body=%s", modelRecorder.Code, modelRecorder.Body.String())
 	}
 }
+
+func TestModelUpdateSetsAndClearsAPIIndependently(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Models = []config.ModelEntry{{ID: "gpt", Name: "GPT", Provider: "openai", Model: "gpt-5"}}
+	handler := &modelHandler{cfg: cfg, configPath: filepath.Join(t.TempDir(), "aipanel.json")}
+	patch := func(body string) config.ModelEntry {
+		t.Helper()
+		recorder := httptest.NewRecorder()
+		ctx, _ := gin.CreateTestContext(recorder)
+		ctx.Params = gin.Params{{Key: "id", Value: "gpt"}}
+		ctx.Request = httptest.NewRequest(http.MethodPatch, "/api/models/gpt", bytes.NewReader([]byte(body)))
+		ctx.Request.Header.Set("Content-Type", "application/json")
+		handler.Update(ctx)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("PATCH %s: status=%d body=%s", body, recorder.Code, recorder.Body.String())
+		}
+		var m config.ModelEntry
+		if err := json.Unmarshal(recorder.Body.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	if m := patch(`{"api":"responses","chainResponses":true}`); m.API != "responses" || !m.ChainResponses {
+		t.Fatalf("set both: %+v", m)
+	}
+	if m := patch(`{"chainResponses":false}`); m.API != "responses" || m.ChainResponses {
+		t.Fatalf("chainResponses alone: %+v", m)
+	}
+	if m := patch(`{"name":"GPT-5"}`); m.API != "responses" {
+		t.Fatalf("unrelated patch must keep api: %+v", m)
+	}
+	if m := patch(`{"api":""}`); m.API != "" {
+		t.Fatalf("api should reset to default: %+v", m)
+	}
+}
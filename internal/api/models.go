@@ -25,6 +25,8 @@ type modelHandler struct {
 type ModelWithProviderStatus struct {
 	config.ModelEntry
 	ProviderStatus string `json:"providerStatus,omitempty"` // "ok" | "error" | "untested"
+	// ResolvedCapabilities 是内置能力表 + capabilities 覆盖后的最终能力（只读）
+	ResolvedCapabilities config.ModelCaps `json:"resolvedCapabilities"`
 }
 
 // List GET /api/models
@@ -56,7 +58,7 @@ func (h *modelHandler) List(c *gin.Context) {
 		if ps == "" {
 			ps = "untested"
 		}
-		result[i] = ModelWithProviderStatus{ModelEntry: m, ProviderStatus: ps}
+		result[i] = ModelWithProviderStatus{ModelEntry: m, ProviderStatus: ps, ResolvedCapabilities: config.ModelCapabilities(&m)}
 	}
 	c.JSON(http.StatusOK, result)
 }
@@ -125,6 +127,30 @@ func validateModelEntry(entry *config.ModelEntry, cfg *config.Config) error {
 	case entry.Model == "":
 		return fmt.Errorf("model is required")
 	}
+	seen := map[string]bool{}
+	for _, id := range entry.Fallbacks {
+		switch {
+		case id == entry.ID:
+			return fmt.Errorf("model cannot fall back to itself")
+		case seen[id]:
+			return fmt.Errorf("duplicate fallback %q", id)
+		case cfg.FindModel(id) == nil:
+			return fmt.Errorf("fallback model %q does not exist", id)
+		}
+		seen[id] = true
+	}
+	if c := entry.Capabilities; c != nil && (c.ContextWindow < 0 || c.MaxOutput < 0) {
+		return fmt.Errorf("capabilities.contextWindow / maxOutput must not be negative")
+	}
+	switch entry.API {
+	case "", config.ModelAPIChat:
+	case config.ModelAPIResponses:
+		if entry.Provider != "openai" {
+			return fmt.Errorf("api %q is only supported for provider openai", entry.API)
+		}
+	default:
+		return fmt.Errorf("api must be %q or %q", config.ModelAPIChat, config.ModelAPIResponses)
+	}
 	if entry.ProviderID == "" {
 		return nil
 	}
@@ -141,7 +167,13 @@ func validateModelEntry(entry *config.ModelEntry, cfg *config.Config) error {
 // Update PATCH /api/models/:id
 func (h *modelHandler) Update(c *gin.Context) {
 	id := c.Param("id")
-	var patch config.ModelEntry
+	var patch struct {
+		config.ModelEntry
+		// Pointers so each can be set or cleared on its own; "api": "" resets
+		// to the default chat interface.
+		API            *string `json:"api"`
+		ChainResponses *bool   `json:"chainResponses"`
+	}
 	if err := c.ShouldBindJSON(&patch); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -183,6 +215,21 @@ func (h *modelHandler) Update(c *gin.Context) {
 				if patch.Status != "" {
 					m.Status = patch.Status
 				}
+				if patch.Fallbacks != nil {
+					m.Fallbacks = patch.Fallbacks
+				}
+				if patch.Generation != nil {
+					m.Generation = patch.Generation
+				}
+				if patch.Capabilities != nil {
+					m.Capabilities = patch.Capabilities
+				}
+				if patch.API != nil {
+					m.API = *patch.API
+				}
+				if patch.ChainResponses != nil {
+					m.ChainResponses = *patch.ChainResponses
+				}
 				if err := validateModelEntry(m, candidate); err != nil {
 					return err
 				}
@@ -198,7 +245,7 @@ func (h *modelHandler) Update(c *gin.Context) {
 	}
 	if err != nil {
 		status := http.StatusInternalServerError
-		if strings.Contains(err.Error(), "baseUrl is blocked") || strings.Contains(err.Error(), "providerId") {
+		if strings.Contains(err.Error(), "baseUrl is blocked") || strings.Contains(err.Error(), "providerId") || strings.HasPrefix(err.Error(), "api ") {
 			status = http.StatusBadRequest
 		}
 		c.JSON(status, gin.H{"error": err.Error()})
@@ -215,6 +262,16 @@ func (h *modelHandler) Delete(c *gin.Context) {
 		for i := range candidate.Models {
 			if candidate.Models[i].ID == id {
 				candidate.Models = append(candidate.Models[:i], candidate.Models[i+1:]...)
+				// Drop dangling references so the referring models stay valid.
+				for j := range candidate.Models {
+					var fbs []string
+					for _, fb := range candidate.Models[j].Fallbacks {
+						if fb != id {
+							fbs = append(fbs, fb)
+						}
+					}
+					candidate.Models[j].Fallbacks = fbs
+				}
 				return nil
 			}
 		}
@@ -289,6 +346,8 @@ func (h *modelHandler) Test(c *gin.Context) {
 		} else {
 			valid, errMsg = testOpenAICompatKey(m.Provider, key, resolvedBase)
 		}
+	case "gemini", "google":
+		valid, errMsg = testGeminiKey(key, resolvedBase)
 	case "deepseek", "moonshot", "kimi", "zhipu", "glm", "minimax", "qwen", "dashscope", "openrouter", "custom", "ollama":
 		baseURL := resolvedBase
 		if baseURL == "" {
@@ -340,6 +399,7 @@ func (h *modelHandler) EnvKeys(c *gin.Context) {
 		{"openai", "OPENAI_API_KEY", "https://api.openai.com"},
 		{"deepseek", "DEEPSEEK_API_KEY", "https://api.deepseek.com"},
 		{"openrouter", "OPENROUTER_API_KEY", "https://openrouter.ai/api"},
+		{"gemini", "GEMINI_API_KEY", "https://generativelanguage.googleapis.com/v1beta"},
 	}
 
 	found := []EnvKey{}
@@ -363,11 +423,19 @@ var envVarForProvider = map[string]string{
 	"openai":     "OPENAI_API_KEY",
 	"deepseek":   "DEEPSEEK_API_KEY",
 	"openrouter": "OPENROUTER_API_KEY",
+	"gemini":     "GEMINI_API_KEY",
 }
 
 // providerHardcodedModels 为不支持 /v1/models 端点的 provider 提供兜底模型列表。
 // 当 API 返回 404/405/501 时自动回退到此列表。
 var providerHardcodedModels = map[string][]string{
+	// Gemini 的模型列表是原生格式（/v1beta/models，非 OpenAI data[]），直接用内置列表
+	"gemini": {
+		"gemini-2.5-pro",
+		"gemini-2.5-flash",
+		"gemini-2.5-flash-lite",
+		"gemini-2.0-flash",
+	},
 	"minimax": {
 		"MiniMax-Text-01",
 		"abab6.5s-chat",
@@ -599,7 +667,7 @@ func isChatCompatible(provider, modelID string) bool {
 	// Non-OpenAI providers typically only list chat models — allow all.
 	switch provider {
 	case "anthropic", "deepseek", "minimax", "zhipu", "moonshot",
-		"openrouter", "ollama", "qwen", "kimi", "baidu", "yi":
+		"openrouter", "ollama", "qwen", "kimi", "baidu", "yi", "gemini":
 		return true
 	}
 
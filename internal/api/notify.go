@@ -1,23 +1,29 @@
 // notify.go — proactive notification endpoint.
 //
 // POST /api/agents/:id/notify
-//   Runs the agent in the per-chat Telegram session and sends the response to
-//   the specified chat. This is the primary mechanism for cron-triggered or
+//   Runs the agent in the per-chat session of one of its channels (any driver
+//   implementing channel.Notifier — Telegram, Feishu, ...) and sends the
+//   response to the specified chat. This is the primary mechanism for cron-triggered or
 //   system-event-triggered outbound messages that maintain conversation context.
 //
 // Architecture:
-//   1. Agent runner loads/saves the session keyed "telegram-{chatID}".
+//   1. Agent runner loads/saves the session keyed "{channelType}-{chatID}".
 //   2. The user's prompt is recorded as a "user" turn.
 //   3. The agent's response is recorded as an "assistant" turn.
-//   4. The response is sent to Telegram (HTML-formatted, falls back to plain).
+//   4. The response is sent through the channel driver (Telegram: HTML-formatted,
+//      falls back to plain).
 //
 // This makes proactive notifications first-class citizens of the conversation:
-// the user can reply in Telegram and the agent remembers the prior notification.
+// the user can reply in the chat and the agent remembers the prior notification.
 package api
 
 import (
+	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 
+	"github.com/Zyling-ai/zyhive/pkg/channel"
 	"github.com/gin-gonic/gin"
 )
 
@@ -27,17 +33,40 @@ type notifyHandler struct {
 
 // NotifyRequest is the request body for POST /api/agents/:id/notify.
 type NotifyRequest struct {
-	// ChannelID is the agent channel config ID. Pass "" to use the first active Telegram bot.
+	// ChannelID is the agent channel config ID. Pass "" to use the first active bot.
 	ChannelID string `json:"channelID"`
-	// ChatID is the Telegram chat ID to send the notification to.
-	ChatID int64 `json:"chatID"`
-	// ThreadID is the message thread ID. Use 0 for non-threaded chats.
-	ThreadID int64 `json:"threadID,omitempty"`
+	// ChatID is the platform chat ID to send the notification to
+	// (Telegram: numeric, Feishu: "oc_..."); JSON number or string.
+	ChatID NotifyID `json:"chatID"`
+	// ThreadID is the message thread ID. Omit (or 0) for non-threaded chats.
+	ThreadID NotifyID `json:"threadID,omitempty"`
 	// Prompt is the message injected as the "user" turn in the session.
 	// The agent will respond to this and send its response to the chat.
 	Prompt string `json:"prompt"`
 }
 
+// NotifyID is a chat / thread ID that accepts a JSON number (Telegram, the
+// original wire format) or a string (platforms with opaque IDs).
+type NotifyID string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (id *NotifyID) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err == nil {
+		if _, err := strconv.ParseInt(string(n), 10, 64); err != nil {
+			return fmt.Errorf("invalid id %s", n)
+		}
+		*id = NotifyID(n)
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("id must be a number or string")
+	}
+	*id = NotifyID(s)
+	return nil
+}
+
 // Notify POST /api/agents/:id/notify
 func (h *notifyHandler) Notify(c *gin.Context) {
 	agentID := c.Param("id")
@@ -47,7 +76,7 @@ func (h *notifyHandler) Notify(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	if req.ChatID == 0 {
+	if req.ChatID == "" || req.ChatID == "0" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "chatID is required"})
 		return
 	}
@@ -60,7 +89,11 @@ func (h *notifyHandler) Notify(c *gin.Context) {
 		return
 	}
 
-	if err := h.botCtrl.Notify(c.Request.Context(), agentID, req.ChannelID, req.ChatID, req.ThreadID, req.Prompt); err != nil {
+	chat := channel.ChatRef{ID: string(req.ChatID)}
+	if req.ThreadID != "0" {
+		chat.ThreadID = string(req.ThreadID)
+	}
+	if err := h.botCtrl.Notify(c.Request.Context(), agentID, req.ChannelID, chat, req.Prompt); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/Zyling-ai/zyhive/pkg/channel"
+)
+
+// globalDeliveries is the outbound delivery queue injected from main.go.
+var globalDeliveries *channel.DeliveryQueue
+
+// SetDeliveryQueue wires the queue behind /api/outbox.
+func SetDeliveryQueue(q *channel.DeliveryQueue) {
+	globalDeliveries = q
+}
+
+// outboxHandler exposes undelivered channel messages: pending retries and
+// dead letters (channel.DeliveryQueue). Not to be confused with the
+// per-channel approval outbox (channel_outbox.go).
+type outboxHandler struct{}
+
+func (h *outboxHandler) queue(c *gin.Context) (*channel.DeliveryQueue, bool) {
+	if globalDeliveries == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "delivery queue not initialised"})
+		return nil, false
+	}
+	return globalDeliveries, true
+}
+
+// List GET /api/outbox?status=pending|dead|sent&agentId=
+// Without status: pending and dead.
+func (h *outboxHandler) List(c *gin.Context) {
+	q, ok := h.queue(c)
+	if !ok {
+		return
+	}
+	status := c.Query("status")
+	switch status {
+	case "", channel.DeliveryPending, channel.DeliveryDead, channel.DeliverySent:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be pending, dead or sent"})
+		return
+	}
+	agentID := c.Query("agentId")
+	out := []channel.Delivery{}
+	for _, d := range q.List(status) {
+		if agentID == "" || d.AgentID == agentID {
+			out = append(out, d)
+		}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// Retry POST /api/outbox/:id/retry
+func (h *outboxHandler) Retry(c *gin.Context) {
+	q, ok := h.queue(c)
+	if !ok {
+		return
+	}
+	if err := q.Retry(c.Param("id")); err != nil {
+		outboxError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// Discard DELETE /api/outbox/:id
+func (h *outboxHandler) Discard(c *gin.Context) {
+	q, ok := h.queue(c)
+	if !ok {
+		return
+	}
+	if err := q.Discard(c.Param("id")); err != nil {
+		outboxError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func outboxError(c *gin.Context, err error) {
+	if errors.Is(err, channel.ErrDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+}
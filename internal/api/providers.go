@@ -11,6 +11,7 @@ import (
 
 	"github.com/Zyling-ai/zyhive/pkg/config"
 	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/usage"
 	"github.com/gin-gonic/gin"
 )
 
@@ -130,14 +131,22 @@ func (h *providerHandler) Update(c *gin.Context) {
 		return
 	}
 	var body struct {
-		Name    *string `json:"name"`
-		APIKey  *string `json:"apiKey"`
-		BaseURL *string `json:"baseUrl"`
+		Name    *string                 `json:"name"`
+		APIKey  *string                 `json:"apiKey"`
+		BaseURL *string                 `json:"baseUrl"`
+		Pricing *[]config.PriceOverride `json:"pricing"` // [] clears the overrides
 	}
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
 		return
 	}
+	if body.Pricing != nil {
+		table := usage.DefaultPricer().Table()
+		if err := table.ValidateOverrides(usage.ProviderOverrides(&config.ProviderEntry{Pricing: *body.Pricing})); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 	var validatedBaseURL *string
 	if body.BaseURL != nil {
 		baseURL := strings.TrimRight(strings.TrimSpace(*body.BaseURL), "/")
@@ -164,6 +173,9 @@ func (h *providerHandler) Update(c *gin.Context) {
 			if validatedBaseURL != nil {
 				p.BaseURL = *validatedBaseURL
 			}
+			if body.Pricing != nil {
+				p.Pricing = *body.Pricing
+			}
 			updated = *p
 			return nil
 		}
@@ -270,6 +282,8 @@ func (h *providerHandler) Test(c *gin.Context) {
 	case "minimax":
 		// MiniMax 不支持 GET /v1/models，改用 chat completion 轻量探测
 		ok, msg2 = testMiniMaxKey(apiKey, baseURL)
+	case "gemini", "google":
+		ok, msg2 = testGeminiKey(apiKey, baseURL)
 	default:
 		ok, msg2 = testOpenAICompatKey(p.Provider, apiKey, baseURL)
 	}
@@ -319,6 +333,7 @@ func providerDisplayName(provider string) string {
 		"openai":     "OpenAI",
 		"deepseek":   "DeepSeek",
 		"openrouter": "OpenRouter",
+		"gemini":     "Google Gemini",
 		"zhipu":      "智谱 AI",
 		"kimi":       "月之暗面 (Kimi)",
 		"minimax":    "MiniMax",
@@ -322,7 +322,7 @@ func (h *publicChatHandler) runPublic(
 		return fmt.Errorf("no API key for model %s", me.ProviderModel())
 	}
 
-	llmClient := llm.NewClient(me.Provider, resolvedBaseURL)
+	llmClient := agent.NewModelClient(h.cfg, me, apiKey, resolvedBaseURL)
 	store := session.NewStore(sessionDir)
 	toolRegistry := newPublicToolRegistry(workspaceDir, agentID, sessionID)
 
@@ -339,17 +339,20 @@ func (h *publicChatHandler) runPublic(
 	}
 
 	r := runner.New(runner.Config{
-		AgentID:       agentID,
-		WorkspaceDir:  workspaceDir,
-		Model:         me.ProviderModel(),
-		APIKey:        apiKey,
-		SessionID:     sessionID,
-		LLM:           llmClient,
-		Tools:         toolRegistry,
-		SupportsTools: false,
-		Session:       store,
-		ExtraContext:  extraCtx,
-		ToolAudit:     toolaudit.New(filepath.Dir(workspaceDir)),
+		AgentID:             agentID,
+		WorkspaceDir:        workspaceDir,
+		Model:               me.ProviderModel(),
+		APIKey:              apiKey,
+		Provider:            me.Provider,
+		SessionID:           sessionID,
+		LLM:                 llmClient,
+		Tools:               toolRegistry,
+		SupportsTools:       false,
+		Session:             store,
+		ExtraContext:        extraCtx,
+		ToolAudit:           toolaudit.New(filepath.Dir(workspaceDir)),
+		PrepareRequest:      agent.GenerationHook(me, ag),
+		CompactionThreshold: agent.CompactionThreshold(me),
 	})
 
 	var fullResponse strings.Builder
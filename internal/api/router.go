@@ -21,12 +21,15 @@ import (
 	"github.com/Zyling-ai/zyhive/pkg/channel"
 	"github.com/Zyling-ai/zyhive/pkg/config"
 	"github.com/Zyling-ai/zyhive/pkg/cron"
+	"github.com/Zyling-ai/zyhive/pkg/eval"
 	"github.com/Zyling-ai/zyhive/pkg/goal"
 	"github.com/Zyling-ai/zyhive/pkg/logging"
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
 	"github.com/Zyling-ai/zyhive/pkg/project"
 	"github.com/Zyling-ai/zyhive/pkg/session"
 	"github.com/Zyling-ai/zyhive/pkg/skillopt"
 	"github.com/Zyling-ai/zyhive/pkg/subagent"
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
 	"github.com/Zyling-ai/zyhive/pkg/usage"
 	"github.com/gin-gonic/gin"
 )
@@ -40,12 +43,21 @@ var configFilePath = "aipanel.json"
 var AppVersion = "dev"
 
 // BotControl groups the functions needed by the channel handler to manage running bots.
+// Bots of every registered channel type (channel.RegisterDriver) go through it.
 type BotControl struct {
-	Start func(agentID, channelID, token string) // start or restart a bot
-	Stop  func(agentID, channelID string)        // stop a bot
+	Start func(agentID string, ch config.ChannelEntry) // start or restart a channel's bot
+	Stop  func(agentID, channelID string)              // stop a bot
 	// Notify runs the agent in the named channel's per-chat session and sends
 	// the response to the specified chat. Pass channelID="" to use the first active bot.
-	Notify func(ctx context.Context, agentID, channelID string, chatID, threadID int64, prompt string) error
+	Notify func(ctx context.Context, agentID, channelID string, chat channel.ChatRef, prompt string) error
+	// Webhook returns the running bot of a channel that receives platform
+	// events over HTTP (channel.WebhookHandler), if any.
+	Webhook func(agentID, channelID string) (channel.WebhookHandler, bool)
+	// Outbox returns the running bot of a channel that holds replies for
+	// approval (channel.Outbox), if any.
+	Outbox func(agentID, channelID string) (channel.Outbox, bool)
+	// Driver returns the running bot of a channel, if any.
+	Driver func(agentID, channelID string) (channel.Driver, bool)
 }
 
 // RegisterRoutes mounts all API handlers onto the Gin engine.
@@ -123,9 +135,17 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 	agents.DELETE("/:id/channels/:chId/pending/:userId", agChH.DismissPending)
 	// Whitelist management
 	agents.DELETE("/:id/channels/:chId/allowed/:userId", agChH.RemoveAllowed)
+	// Outbox: replies held for approval (email approveReplies)
+	outboxH := &channelOutboxHandler{botCtrl: botCtrl}
+	agents.GET("/:id/channels/:chId/outbox", outboxH.List)
+	agents.POST("/:id/channels/:chId/outbox/:draftId/approve", outboxH.Approve)
+	agents.DELETE("/:id/channels/:chId/outbox/:draftId", outboxH.Discard)
 
 	// Chat (streaming SSE) — background worker architecture
 	chatH := &chatHandler{cfg: cfg, manager: mgr, projectMgr: projectMgr, subagentMgr: subagentMgr, workerPool: workerPool, usageStore: usageStore, budgetStore: budgetStore}
+	if pool != nil {
+		chatH.knowledgeMgr = pool.GetKnowledgeMgr()
+	}
 	agents.POST("/:id/chat", chatH.Chat)                // enqueue + stream
 	agents.GET("/:id/chat/stream", chatH.StreamSession) // reconnect: subscribe to broadcaster
 	agents.GET("/:id/chat/status", chatH.SessionStatus) // poll status
@@ -190,6 +210,29 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 	taggH := &toolAuditAggregateHandler{manager: mgr}
 	v1.GET("/tool-audit", taggH.ListAll)
 
+	// Run traces (pkg/tracing): spans written by the runner, subagents,
+	// cron and channel dispatch.
+	trH := &traceHandler{store: tracing.NewStore(tracing.Dir(mgr.AgentsDir()), cfg.Tracing.RetentionDays)}
+	v1.GET("/traces/:traceId", trH.Get)
+
+	// Offline evals (pkg/eval): datasets, background runs, run comparison.
+	evH := &evalHandler{store: eval.NewStore(mgr.AgentsDir()), cfg: cfg, mgr: mgr, pool: pool}
+	v1.GET("/evals/datasets", evH.ListDatasets)
+	v1.POST("/evals/datasets", evH.SaveDataset)
+	v1.GET("/evals/datasets/:id", evH.GetDataset)
+	v1.PUT("/evals/datasets/:id", evH.SaveDataset)
+	v1.DELETE("/evals/datasets/:id", evH.DeleteDataset)
+	v1.POST("/evals/runs", evH.StartRun)
+	v1.GET("/evals/runs", evH.ListRuns)
+	v1.GET("/evals/runs/:id", evH.GetRun)
+	v1.GET("/evals/compare", evH.Compare)
+
+	// Outbound delivery queue: pending retries + dead letters
+	obH := &outboxHandler{}
+	v1.GET("/outbox", obH.List)
+	v1.POST("/outbox/:id/retry", obH.Retry)
+	v1.DELETE("/outbox/:id", obH.Discard)
+
 	// F-01 (26.5.12v1): tool-call approval broker REST + SSE.
 	apH := &approvalHandler{}
 	v1.GET("/approvals/pending", apH.ListPending)
@@ -203,6 +246,8 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 	v1.POST("/feishu/probe", fsH.Probe)
 	v1.POST("/feishu/test-connect", fsH.TestConnect)
 	agents.GET("/:id/channels/:chId/feishu-status", fsH.ChannelStatus)
+	tgH := &telegramStatusHandler{mgr: mgr, botCtrl: botCtrl}
+	agents.GET("/:id/channels/:chId/telegram-status", tgH.ChannelStatus)
 
 	// Memory tree API
 	memH := &memoryHandler{manager: mgr, cronEngine: cronEngine, pool: pool}
@@ -307,13 +352,21 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 	}
 
 	// Global Sessions (conversation management across all agents)
-	sessH := &globalSessionsHandler{cfg: cfg, manager: mgr}
+	sessH := &globalSessionsHandler{cfg: cfg, manager: mgr, pool: pool, workerPool: workerPool}
 	globalSess := v1.Group("/sessions")
 	{
 		globalSess.GET("", sessH.List)
+		globalSess.GET("/search", sessH.Search)
 		globalSess.GET("/:agentId/:sid", sessH.Get)
 		globalSess.DELETE("/:agentId/:sid", sessH.Delete)
 		globalSess.PATCH("/:agentId/:sid", sessH.Patch)
+		globalSess.POST("/:agentId/:sid/fork", sessH.Fork)
+		globalSess.POST("/:agentId/:sid/rewind", sessH.Rewind)
+		globalSess.POST("/:agentId/:sid/restore", sessH.Restore)
+		globalSess.GET("/:agentId/:sid/export", sessH.Export)
+		globalSess.POST("/:agentId/:sid/share", sessH.Share)
+		globalSess.DELETE("/:agentId/:sid/share/:shareId", sessH.Unshare)
+		globalSess.POST("/:agentId/import", sessH.Import)
 	}
 
 	// Cron jobs
@@ -340,6 +393,18 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 		acpGroup.POST("/:id/test", acpH.Test)
 	}
 
+	// MCP servers (external tool providers mounted via mcpServerIds)
+	mcpH := &mcpServerHandler{cfg: cfg, cfgPath: cfgPath}
+	mcpGroup := v1.Group("/mcp-servers")
+	{
+		mcpGroup.GET("", mcpH.List)
+		mcpGroup.POST("", mcpH.Create)
+		mcpGroup.POST("/reload", mcpH.Reload)
+		mcpGroup.PATCH("/:id", mcpH.Update)
+		mcpGroup.DELETE("/:id", mcpH.Delete)
+		mcpGroup.GET("/:id/tools", mcpH.Tools)
+	}
+
 	// Goals & Planning
 	goalDataDir := "cron" // same directory as cron, goals.json lives alongside jobs.json
 	goalMgr := goal.NewManager(goalDataDir, cronEngine)
@@ -410,6 +475,26 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 		projects.DELETE("/:id/files/*path", projFileH.Delete)
 	}
 
+	// ── Knowledge bases (attached to agents; kb_search + auto-retrieval) ───
+	if pool != nil && pool.GetKnowledgeMgr() != nil {
+		kbH := &knowledgeHandler{mgr: pool.GetKnowledgeMgr()}
+		kbs := v1.Group("/knowledge")
+		{
+			kbs.GET("", kbH.List)
+			kbs.POST("", kbH.Create)
+			kbs.GET("/:id", kbH.Get)
+			kbs.PATCH("/:id", kbH.Update)
+			kbs.DELETE("/:id", kbH.Delete)
+			kbs.GET("/:id/files", kbH.Files)
+			kbs.PUT("/:id/files/*path", kbH.Upload)
+			kbs.DELETE("/:id/files/*path", kbH.RemoveFile)
+			kbs.POST("/:id/urls", kbH.AddURL)
+			kbs.DELETE("/:id/urls", kbH.RemoveURL)
+			kbs.POST("/:id/reindex", kbH.Reindex)
+			kbs.GET("/:id/search", kbH.Search)
+		}
+	}
+
 	// Background Tasks (subagents)
 	if subagentMgr != nil {
 		taskH := &subagentHandler{mgr: subagentMgr, agentMgr: mgr}
@@ -450,11 +535,26 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 	rzH := &readyzHandler{cronEngine: cronEngine, workerPool: workerPool}
 	r.GET("/readyz", rzH.Handle)
 
+	// MCP server (streamable HTTP) — own bearer auth: admin token or a
+	// scoped mcpServe token.
+	if pool != nil {
+		mcpH := &mcpServeHandler{cfg: cfg, adminToken: cfg.Auth.Token, server: mcp.NewServer(pool.MCPBackend(), "zyhive", AppVersion)}
+		r.POST("/mcp", mcpH.Post)
+		r.GET("/mcp", mcpH.Stream)
+		r.DELETE("/mcp", mcpH.End)
+	}
+
 	// Feishu card action callback — no auth (Feishu calls this with its own token)
 	feishuCbH := &feishuCardCallbackHandler{manager: mgr, pool: pool}
 	r.POST("/feishu/card-callback", feishuCbH.Handle)
 	r.GET("/feishu/card-callback", feishuCbH.Handle) // URL verification also comes as GET sometimes
 
+	// Channel webhooks (Slack Events API, ...) — no admin auth; each driver
+	// verifies the platform's request signature.
+	chWhH := &channelWebhookHandler{botCtrl: botCtrl}
+	r.POST("/channels/:agentId/:channelId/webhook", chWhH.Handle)
+	r.GET("/channels/:agentId/:channelId/webhook", chWhH.Handle) // WeCom callback URL verification
+
 	// Detailed status endpoint — auth required
 	stsH := &statusHandler{manager: mgr, cronEngine: cronEngine}
 	v1.GET("/status", stsH.Handle)
@@ -473,10 +573,13 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 	v1.GET("/llm/throttle", thrH.Get)
 
 	// Usage statistics
-	usageH := newUsageHandler(usageStore, mgr)
+	usageH := newUsageHandler(usageStore, mgr, cfg, budgetStore)
 	v1.GET("/usage/summary", usageH.Summary)
 	v1.GET("/usage/timeline", usageH.Timeline)
 	v1.GET("/usage/records", usageH.Records)
+	v1.GET("/usage/pricing", usageH.GetPricing)
+	v1.PUT("/usage/pricing", usageH.PutPricing)
+	v1.POST("/usage/reprice", usageH.Reprice)
 
 	// Logs
 	v1.GET("/logs", logsHandler)
@@ -484,6 +587,9 @@ func RegisterRoutes(r *gin.Engine, cfg *config.Config, cfgPath string, mgr *agen
 	// Media serving uses one-time Artifact credentials.
 	r.GET("/api/media", (&mediaHandler{manager: mgr}).ServeMedia)
 
+	// Shared session pages use reusable, time-limited share tickets.
+	r.GET("/api/share", (&shareHandler{manager: mgr}).ServeShare)
+
 	// WebSocket
 	r.GET("/ws", wsHandler)
 
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/artifact"
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+	"github.com/Zyling-ai/zyhive/pkg/session"
+	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
+	"github.com/Zyling-ai/zyhive/pkg/transcript"
+	"github.com/gin-gonic/gin"
+)
+
+// shareDirName holds rendered share pages inside an agent's session dir.
+const shareDirName = ".shares"
+
+// sharePageStamp is the time layout in share page names ({sid}-{stamp}.html).
+const sharePageStamp = "20060102150405.000"
+
+// sharePageCSP keeps share pages inert: no scripts, no external loads.
+const sharePageCSP = "default-src 'none'; img-src data:; style-src 'unsafe-inline'"
+
+// buildBundle exports one session with the redaction requested by the
+// redactTools / redactEnv flags.
+func buildBundle(ag *agent.Agent, sid string, redactTools, redactEnv bool) (*transcript.Bundle, error) {
+	opts := transcript.Options{RedactToolInputs: redactTools}
+	if redactEnv {
+		opts.Env = ag.Env
+	}
+	return transcript.Build(transcript.Source{
+		Store:     session.NewStore(ag.SessionDir),
+		SessionID: sid,
+		AgentID:   ag.ID,
+		AgentName: ag.Name,
+		Audit:     toolaudit.New(filepath.Dir(ag.WorkspaceDir)),
+	}, opts)
+}
+
+// exportAgentSession resolves :agentId / :sid for the export endpoints.
+func (h *globalSessionsHandler) exportAgentSession(c *gin.Context) (*agent.Agent, bool) {
+	ag, ok := h.manager.Get(c.Param("agentId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return nil, false
+	}
+	if _, exists := session.NewStore(ag.SessionDir).GetMeta(c.Param("sid")); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return nil, false
+	}
+	return ag, true
+}
+
+// Export GET /api/sessions/:agentId/:sid/export?format=json|md|html&redactTools=1&redactEnv=1
+// Downloads the session as a JSON bundle (importable), Markdown or a
+// self-contained HTML page.
+func (h *globalSessionsHandler) Export(c *gin.Context) {
+	ag, ok := h.exportAgentSession(c)
+	if !ok {
+		return
+	}
+	sid := c.Param("sid")
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "md" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json, md or html"})
+		return
+	}
+	b, err := buildBundle(ag, sid, c.Query("redactTools") == "1", c.Query("redactEnv") == "1")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	var data []byte
+	var mimeType string
+	switch format {
+	case "md":
+		data, mimeType = transcript.Markdown(b), "text/markdown; charset=utf-8"
+	case "html":
+		data, err = transcript.HTML(b)
+		mimeType = "text/html; charset=utf-8"
+		c.Header("Content-Security-Policy", sharePageCSP)
+	default:
+		data, err = json.MarshalIndent(b, "", "  ")
+		mimeType = "application/json; charset=utf-8"
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+sid+"."+format+`"`)
+	c.Data(http.StatusOK, mimeType, data)
+}
+
+// Share POST /api/sessions/:agentId/:sid/share {ttlMinutes, redactTools, redactEnv}
+// Renders the session as HTML and returns a read-only link that works
+// without login until it expires (default 24h, at most 7 days). The page
+// is a snapshot: later turns are not shown.
+func (h *globalSessionsHandler) Share(c *gin.Context) {
+	ag, ok := h.exportAgentSession(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		TTLMinutes  int  `json:"ttlMinutes"`
+		RedactTools bool `json:"redactTools"`
+		RedactEnv   bool `json:"redactEnv"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if body.TTLMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ttlMinutes must be positive"})
+		return
+	}
+	sid := c.Param("sid")
+	b, err := buildBundle(ag, sid, body.RedactTools, body.RedactEnv)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := transcript.HTML(b)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	dir := filepath.Join(ag.SessionDir, shareDirName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	pruneSharePages(dir)
+	now := time.Now()
+	path := filepath.Join(dir, sid+"-"+now.Format(sharePageStamp)+".html")
+	if err := persist.AtomicWrite(path, page, 0o600); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	id, token, expiresAt, err := artifact.DefaultTickets.IssueShare(path, time.Duration(body.TTLMinutes)*time.Minute)
+	if err != nil {
+		os.Remove(path)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"url":       strings.TrimRight(h.cfg.Gateway.BaseURL(), "/") + "/api/share?id=" + url.QueryEscape(id) + "&token=" + url.QueryEscape(token),
+		"shareId":   id,
+		"expiresAt": expiresAt.UnixMilli(),
+		"redacted":  b.Redacted,
+	})
+}
+
+// pruneSharePages removes pages no ticket can still point at.
+func pruneSharePages(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	cutoff := time.Now().Add(-artifact.MaxShareTTL)
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && !e.IsDir() && info.ModTime().Before(cutoff) {
+			os.Remove(filepath.Join(dir, e.Name()))
+		}
+	}
+}
+
+// Unshare DELETE /api/sessions/:agentId/:sid/share/:shareId
+// Revokes a share link of this session before it expires.
+func (h *globalSessionsHandler) Unshare(c *gin.Context) {
+	ag, ok := h.exportAgentSession(c)
+	if !ok {
+		return
+	}
+	shareID := c.Param("shareId")
+	path, ok := artifact.DefaultTickets.SharePath(shareID)
+	if !ok || !isSessionSharePage(ag, c.Param("sid"), path) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "share not found"})
+		return
+	}
+	if err := artifact.DefaultTickets.Revoke(shareID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// isSessionSharePage reports whether path is a page Share rendered for
+// session sid of ag ({sid}-{timestamp}.html in its share dir).
+func isSessionSharePage(ag *agent.Agent, sid, path string) bool {
+	if filepath.Dir(path) != filepath.Join(ag.SessionDir, shareDirName) {
+		return false
+	}
+	name := filepath.Base(path)
+	return strings.HasPrefix(name, sid+"-") && len(name) == len(sid+"-"+sharePageStamp+".html")
+}
+
+// Import POST /api/sessions/:agentId/import  (body: JSON bundle from Export)
+// Creates a new session of the agent from an exported bundle.
+func (h *globalSessionsHandler) Import(c *gin.Context) {
+	ag, ok := h.manager.Get(c.Param("agentId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		if IsBodyTooLarge(err) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "bundle too large"})
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	b, err := transcript.Decode(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	store := session.NewStore(ag.SessionDir)
+	sid, err := transcript.Import(store, toolaudit.New(filepath.Dir(ag.WorkspaceDir)), ag.ID, b)
+	if err != nil && sid == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	meta, _ := store.GetMeta(sid)
+	resp := gin.H{"sessionId": sid, "session": meta}
+	if err != nil {
+		resp["warning"] = err.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// shareHandler serves share pages to anyone holding a valid share link.
+type shareHandler struct {
+	manager *agent.Manager
+	tickets *artifact.TicketStore
+}
+
+// ServeShare GET /api/share?id=SHARE_ID&token=TOKEN
+func (h *shareHandler) ServeShare(c *gin.Context) {
+	tickets := h.tickets
+	if tickets == nil {
+		tickets = artifact.DefaultTickets
+	}
+	path, err := tickets.Open(c.Query("id"), c.Query("token"))
+	if err != nil {
+		if errors.Is(err, artifact.ErrExpiredTicket) {
+			c.JSON(http.StatusGone, gin.H{"error": "share link expired"})
+		} else {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid share link"})
+		}
+		return
+	}
+	if !h.isSharePage(path) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not a share page"})
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "share page not found"})
+		return
+	}
+	c.Header("Content-Security-Policy", sharePageCSP)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", data)
+}
+
+// isSharePage reports whether path is a page in some agent's share dir.
+func (h *shareHandler) isSharePage(path string) bool {
+	if h.manager == nil || filepath.Ext(path) != ".html" {
+		return false
+	}
+	for _, ag := range h.manager.List() {
+		if filepath.Dir(path) == filepath.Join(ag.SessionDir, shareDirName) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/artifact"
+	"github.com/Zyling-ai/zyhive/pkg/session"
+	"github.com/gin-gonic/gin"
+)
+
+func TestServeShareOnlyServesSharePages(t *testing.T) {
+	mgr, aliceWS := setupSecurityTestEnv(t)
+	ag, _ := mgr.Get("alice")
+	shareDir := filepath.Join(ag.SessionDir, shareDirName)
+	if err := os.MkdirAll(shareDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(shareDir, "ses-1.html")
+	if err := os.WriteFile(page, []byte("<p>hi</p>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tickets := artifact.NewTicketStore()
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/api/share", (&shareHandler{manager: mgr, tickets: tickets}).ServeShare)
+	get := func(id, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+			"/api/share?id="+url.QueryEscape(id)+"&token="+url.QueryEscape(token), nil))
+		return w
+	}
+
+	id, token, _, err := tickets.IssueShare(page, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ { // reusable, unlike download tickets
+		w := get(id, token)
+		if w.Code != http.StatusOK || w.Body.String() != "<p>hi</p>" {
+			t.Fatalf("open #%d = %d %s", i, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Content-Security-Policy") != sharePageCSP {
+			t.Errorf("csp = %q", w.Header().Get("Content-Security-Policy"))
+		}
+	}
+	if w := get(id, "wrong"); w.Code != http.StatusUnauthorized {
+		t.Errorf("bad token = %d", w.Code)
+	}
+
+	// A share ticket for any other file is refused.
+	id, token, _, _ = tickets.IssueShare(filepath.Join(aliceWS, "ok.md"), time.Hour)
+	if w := get(id, token); w.Code != http.StatusForbidden {
+		t.Errorf("workspace file = %d", w.Code)
+	}
+}
+
+func TestUnshareOnlyRevokesOwnSession(t *testing.T) {
+	mgr, _ := setupSecurityTestEnv(t)
+	ag, _ := mgr.Get("alice")
+	store := session.NewStore(ag.SessionDir)
+	for _, sid := range []string{"ses-1", "ses-2"} {
+		if _, _, err := store.GetOrCreate(sid, "alice"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	shareDir := filepath.Join(ag.SessionDir, shareDirName)
+	if err := os.MkdirAll(shareDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	page := filepath.Join(shareDir, "ses-1-"+time.Now().Format(sharePageStamp)+".html")
+	if err := os.WriteFile(page, []byte("<p>hi</p>"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	id, token, _, err := artifact.DefaultTickets.IssueShare(page, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.DELETE("/api/sessions/:agentId/:sid/share/:shareId", (&globalSessionsHandler{manager: mgr}).Unshare)
+	del := func(sid string) int {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/sessions/alice/"+sid+"/share/"+id, nil))
+		return w.Code
+	}
+	if code := del("ses-2"); code != http.StatusNotFound {
+		t.Errorf("unshare via another session = %d", code)
+	}
+	if _, err := artifact.DefaultTickets.Open(id, token); err != nil {
+		t.Fatalf("share revoked through another session: %v", err)
+	}
+	if code := del("ses-1"); code != http.StatusOK {
+		t.Errorf("unshare = %d", code)
+	}
+	if _, err := artifact.DefaultTickets.Open(id, token); err == nil {
+		t.Error("share still opens after unshare")
+	}
+}
@@ -3,10 +3,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/Zyling-ai/zyhive/pkg/agent"
@@ -17,6 +19,9 @@ import (
 type globalSessionsHandler struct {
 	cfg     *config.Config
 	manager *agent.Manager
+	pool    *agent.Pool // semantic search embedder; nil = keyword only
+
+	workerPool *session.WorkerPool // refuses rewinds while a run is writing; may be nil
 }
 
 // SessionSummary extends SessionIndexEntry with agent display info.
@@ -28,11 +33,13 @@ type SessionSummary struct {
 
 // ParsedMessage is a cleaned-up message for the UI.
 type ParsedMessage struct {
-	Role      string                   `json:"role"`                // "user" | "assistant" | "compaction"
-	Text      string                   `json:"text"`                // plain text extracted from content
+	ID        string                   `json:"id,omitempty"` // message ID for fork / rewind / edit
+	Role      string                   `json:"role"`         // "user" | "assistant" | "compaction"
+	Text      string                   `json:"text"`         // plain text extracted from content
 	Timestamp int64                    `json:"timestamp"`
 	IsCompact bool                     `json:"isCompact,omitempty"` // true for compaction summary entries
 	ToolCalls []session.ToolCallRecord `json:"toolCalls,omitempty"` // tool timeline (display only)
+	RewoundAt int64                    `json:"rewoundAt,omitempty"` // rewind ID; only with ?rewound=1
 }
 
 // List GET /api/sessions?agentId=&limit=50&q=
@@ -84,8 +91,76 @@ func (h *globalSessionsHandler) List(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"sessions": all, "total": len(all)})
 }
 
-// Get GET /api/sessions/:agentId/:sid
+// SearchResult is a session.SearchHit with its agent.
+type SearchResult struct {
+	session.SearchHit
+	AgentID   string `json:"agentId"`
+	AgentName string `json:"agentName"`
+}
+
+// Search GET /api/sessions/search?q=&agentId=&source=&dateFrom=&dateTo=&limit=20&mode=keyword
+// Full-text (and, with an embedding provider, semantic) search over message
+// text across all agents. dateFrom/dateTo are inclusive UTC YYYY-MM-DD;
+// mode=keyword skips embeddings.
+func (h *globalSessionsHandler) Search(c *gin.Context) {
+	text := strings.TrimSpace(c.Query("q"))
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	q := session.SearchQuery{Text: text, Source: c.Query("source"), Limit: parseLimit(c, 20, 100)}
+	if v := c.Query("dateFrom"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dateFrom must be YYYY-MM-DD"})
+			return
+		}
+		q.From = t.UnixMilli()
+	}
+	if v := c.Query("dateTo"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dateTo must be YYYY-MM-DD"})
+			return
+		}
+		q.To = t.AddDate(0, 0, 1).UnixMilli() - 1
+	}
+	if h.pool != nil && c.Query("mode") != "keyword" {
+		q.Embedder = h.pool.SearchEmbedder()
+	}
+
+	filterAgent := c.Query("agentId")
+	results := []SearchResult{}
+	for _, ag := range h.manager.List() {
+		if filterAgent != "" && ag.ID != filterAgent {
+			continue
+		}
+		hits, err := session.NewStore(ag.SessionDir).Search(c.Request.Context(), q)
+		if err != nil {
+			continue
+		}
+		for _, hit := range hits {
+			if strings.HasPrefix(hit.SessionID, "skill-studio-") || strings.HasPrefix(hit.SessionID, "subagent-") {
+				continue
+			}
+			results = append(results, SearchResult{SearchHit: hit, AgentID: ag.ID, AgentName: ag.Name})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Timestamp > results[j].Timestamp
+	})
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results, "total": len(results), "semantic": q.Embedder != nil})
+}
+
+// Get GET /api/sessions/:agentId/:sid?rewound=1
 // Returns session metadata + parsed message list (for conversation viewer).
+// Messages hidden by a rewind are included (with rewoundAt) only when rewound=1.
 func (h *globalSessionsHandler) Get(c *gin.Context) {
 	agentID := c.Param("agentId")
 	sid := c.Param("sid")
@@ -110,7 +185,7 @@ func (h *globalSessionsHandler) Get(c *gin.Context) {
 		return
 	}
 
-	messages := parseMessagesFromJSONL(entries)
+	messages := parseMessagesFromJSONL(entries, c.Query("rewound") == "1")
 
 	c.JSON(http.StatusOK, gin.H{
 		"session":  meta,
@@ -171,11 +246,122 @@ func (h *globalSessionsHandler) Patch(c *gin.Context) {
 	c.JSON(http.StatusOK, meta)
 }
 
+// Fork POST /api/sessions/:agentId/:sid/fork {messageId}
+// Copies the session up to messageId (a visible or rewound message) into a
+// new branch session linked back to this one.
+func (h *globalSessionsHandler) Fork(c *gin.Context) {
+	store, ok := h.branchStore(c, false)
+	if !ok {
+		return
+	}
+	var body struct {
+		MessageID string `json:"messageId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	newID, err := store.Fork(c.Param("sid"), body.MessageID)
+	if err != nil {
+		c.JSON(branchErrStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	meta, _ := store.GetMeta(newID)
+	c.JSON(http.StatusOK, gin.H{"sessionId": newID, "session": meta})
+}
+
+// Rewind POST /api/sessions/:agentId/:sid/rewind {messageId, keep}
+// Hides the messages after messageId (and messageId itself unless keep).
+// Hidden messages stay in the file; the returned rewindId restores them.
+func (h *globalSessionsHandler) Rewind(c *gin.Context) {
+	store, ok := h.branchStore(c, true)
+	if !ok {
+		return
+	}
+	var body struct {
+		MessageID string `json:"messageId" binding:"required"`
+		Keep      *bool  `json:"keep"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	keep := body.Keep == nil || *body.Keep
+	rewindID, err := store.Rewind(c.Param("sid"), body.MessageID, keep)
+	if err != nil {
+		c.JSON(branchErrStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	meta, _ := store.GetMeta(c.Param("sid"))
+	c.JSON(http.StatusOK, gin.H{"rewindId": rewindID, "session": meta})
+}
+
+// Restore POST /api/sessions/:agentId/:sid/restore {rewindId}
+// Brings back the messages of a rewind; 409 once the session has new turns.
+func (h *globalSessionsHandler) Restore(c *gin.Context) {
+	store, ok := h.branchStore(c, true)
+	if !ok {
+		return
+	}
+	var body struct {
+		RewindID int64 `json:"rewindId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Restore(c.Param("sid"), body.RewindID); err != nil {
+		c.JSON(branchErrStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	meta, _ := store.GetMeta(c.Param("sid"))
+	c.JSON(http.StatusOK, gin.H{"ok": true, "session": meta})
+}
+
+// branchStore resolves the agent's store for the branch endpoints. With
+// idle set it refuses sessions whose worker is still generating, since the
+// run would append to the history being rewritten.
+func (h *globalSessionsHandler) branchStore(c *gin.Context, idle bool) (*session.Store, bool) {
+	ag, ok := h.manager.Get(c.Param("agentId"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return nil, false
+	}
+	store := session.NewStore(ag.SessionDir)
+	if _, exists := store.GetMeta(c.Param("sid")); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return nil, false
+	}
+	if idle && h.workerPool != nil {
+		if w := h.workerPool.Get(ag.ID, c.Param("sid")); w != nil && w.IsBusy() {
+			c.JSON(http.StatusConflict, gin.H{"error": "session is generating"})
+			return nil, false
+		}
+	}
+	return store, true
+}
+
+// branchErrStatus maps session branch errors to HTTP status codes.
+func branchErrStatus(err error) int {
+	switch {
+	case errors.Is(err, session.ErrMessageNotFound), errors.Is(err, session.ErrRewindNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, session.ErrInvalidRewindPoint):
+		return http.StatusBadRequest
+	case errors.Is(err, session.ErrRewindConflict):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // parseMessagesFromJSONL converts raw JSONL lines into ParsedMessage slice.
-func parseMessagesFromJSONL(lines []json.RawMessage) []ParsedMessage {
+// Rewound messages are dropped unless withRewound is set.
+func parseMessagesFromJSONL(lines []json.RawMessage, withRewound bool) []ParsedMessage {
 	var result []ParsedMessage
 
-	for _, line := range lines {
+	ids := session.MessageIDs(lines)
+	for i, line := range lines {
 		var base struct {
 			Type string `json:"type"`
 		}
@@ -192,6 +378,7 @@ func parseMessagesFromJSONL(lines []json.RawMessage) []ParsedMessage {
 					ToolCalls []session.ToolCallRecord `json:"toolCalls,omitempty"`
 				} `json:"message"`
 				Timestamp int64 `json:"timestamp"`
+				RewoundAt int64 `json:"rewoundAt"`
 			}
 			if err := json.Unmarshal(line, &entry); err != nil {
 				continue
@@ -199,6 +386,9 @@ func parseMessagesFromJSONL(lines []json.RawMessage) []ParsedMessage {
 			if entry.Message.Role != "user" && entry.Message.Role != "assistant" {
 				continue
 			}
+			if entry.RewoundAt != 0 && !withRewound {
+				continue
+			}
 			// Skip intermediate tool-only messages (tool_use / tool_result exchanges
 			// saved in the agentic loop). They have no display text and the final
 			// assistant message already carries the ToolCalls display records.
@@ -210,10 +400,12 @@ func parseMessagesFromJSONL(lines []json.RawMessage) []ParsedMessage {
 				continue // nothing to show
 			}
 			result = append(result, ParsedMessage{
+				ID:        ids[i],
 				Role:      entry.Message.Role,
 				Text:      text,
 				Timestamp: entry.Timestamp,
 				ToolCalls: entry.Message.ToolCalls,
+				RewoundAt: entry.RewoundAt,
 			})
 
 		case "compaction":
@@ -5,7 +5,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/logging"
 	"github.com/Zyling-ai/zyhive/pkg/subagent"
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
 )
 
 type subagentHandler struct {
@@ -161,6 +163,8 @@ func (h *subagentHandler) Spawn(c *gin.Context) {
 		Attachments:     attachments,
 		ContextSnapshot: contextSnapshot,
 		SharedProjectID: req.SharedProjectID,
+		// No parent span: the task roots its own trace under the request's id.
+		Parent: tracing.SpanRef{TraceID: logging.TraceID(c.Request.Context())},
 	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -0,0 +1,67 @@
+// internal/api/telegram_status.go — per-channel Telegram diagnostics.
+//
+//	GET /api/agents/:id/channels/:chId/telegram-status
+//
+// Reports bot identity, whether the running bot receives updates over the
+// webhook or by long polling, and getWebhookInfo health (registered URL,
+// last delivery error, backlog). Same role as feishu-status.
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/channel"
+	"github.com/gin-gonic/gin"
+)
+
+type telegramStatusHandler struct {
+	mgr     *agent.Manager
+	botCtrl BotControl
+}
+
+// ChannelStatus GET /api/agents/:id/channels/:chId/telegram-status
+func (h *telegramStatusHandler) ChannelStatus(c *gin.Context) {
+	if h.mgr == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "manager not wired"})
+		return
+	}
+	agentID, cid := c.Param("id"), c.Param("chId")
+	ag, ok := h.mgr.Get(agentID)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+		return
+	}
+	token := ""
+	found := false
+	for _, ch := range ag.Channels {
+		if ch.ID == cid && ch.Type == "telegram" {
+			token, found = ch.Config["botToken"], true
+			break
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "telegram channel not found"})
+		return
+	}
+	if token == "" {
+		c.JSON(http.StatusOK, &channel.TelegramProbeResult{Error: "auth_failed", Issues: []string{"未配置 Bot Token"}})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+	// A running bot knows its mode and webhook URL; otherwise probe the token.
+	if h.botCtrl.Driver != nil {
+		if d, ok := h.botCtrl.Driver(agentID, cid); ok {
+			if bot, ok := d.(*channel.TelegramBot); ok {
+				c.JSON(http.StatusOK, bot.Diagnose(ctx))
+				return
+			}
+		}
+	}
+	c.JSON(http.StatusOK, channel.ProbeTelegram(ctx, token, "", ""))
+}
@@ -93,6 +93,12 @@ func (h *toolHandler) Update(c *gin.Context) {
 				if patch.BaseURL != "" {
 					tool.BaseURL = patch.BaseURL
 				}
+				if patch.Model != "" {
+					tool.Model = patch.Model
+				}
+				if patch.Voice != "" {
+					tool.Voice = patch.Voice
+				}
 				tool.Enabled = patch.Enabled
 				if patch.Status != "" {
 					tool.Status = patch.Status
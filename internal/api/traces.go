@@ -0,0 +1,42 @@
+// internal/api/traces.go — read-only view of recorded run traces.
+package api
+
+import (
+	"net/http"
+
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
+	"github.com/gin-gonic/gin"
+)
+
+type traceHandler struct {
+	store *tracing.Store
+}
+
+// Get GET /api/traces/:traceId — every span of the trace, flat (sorted by
+// start) and as a parent/child tree.
+func (h *traceHandler) Get(c *gin.Context) {
+	traceID := c.Param("traceId")
+	spans, err := h.store.Get(traceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(spans) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "trace not found"})
+		return
+	}
+	var durationMs int64
+	start := spans[0].StartMs
+	for _, s := range spans {
+		if end := s.StartMs + s.DurationMs - start; end > durationMs {
+			durationMs = end
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"traceId":    traceID,
+		"startMs":    start,
+		"durationMs": durationMs,
+		"spans":      spans,
+		"tree":       tracing.BuildTree(spans),
+	})
+}
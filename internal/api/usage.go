@@ -2,12 +2,15 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"sync"
 
 	"github.com/gin-gonic/gin"
 	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/budget"
+	"github.com/Zyling-ai/zyhive/pkg/config"
 	"github.com/Zyling-ai/zyhive/pkg/session"
 	"github.com/Zyling-ai/zyhive/pkg/usage"
 )
@@ -15,13 +18,15 @@ import (
 type usageHandler struct {
 	store   *usage.Store
 	manager *agent.Manager
+	cfg     *config.Config
+	budget  *budget.Store // reseeded after a re-price; may be nil
 	// sessionTitleCache is short-lived memoization: per-request we read each
 	// (agentID, sessionID) at most once from disk instead of per-row.
 	titleCacheMu sync.Mutex
 }
 
-func newUsageHandler(store *usage.Store, mgr *agent.Manager) *usageHandler {
-	return &usageHandler{store: store, manager: mgr}
+func newUsageHandler(store *usage.Store, mgr *agent.Manager, cfg *config.Config, budgetStore *budget.Store) *usageHandler {
+	return &usageHandler{store: store, manager: mgr, cfg: cfg, budget: budgetStore}
 }
 
 // parsetime reads a Unix-seconds query param; returns 0 on missing/invalid.
@@ -123,6 +128,55 @@ func (h *usageHandler) Records(c *gin.Context) {
 	})
 }
 
+// GET /api/usage/pricing
+func (h *usageHandler) GetPricing(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"pricing": usage.DefaultPricer().Table()})
+}
+
+// PUT /api/usage/pricing — replaces the pricing table (version is bumped
+// server-side). Existing records keep their cost until re-priced.
+func (h *usageHandler) PutPricing(c *gin.Context) {
+	var t usage.PricingTable
+	if err := c.ShouldBindJSON(&t); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+		return
+	}
+	saved, err := usage.DefaultPricer().Update(t)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pricing": saved})
+}
+
+// POST /api/usage/reprice?from=&to= — recomputes stored costs with the
+// current table and provider overrides, then reseeds today's budget.
+func (h *usageHandler) Reprice(c *gin.Context) {
+	res, err := h.store.Reprice(usage.DefaultPricer(), usage.ConfigOverrides(h.cfg), parsetime(c, "from"), parsetime(c, "to"))
+	if errors.Is(err, usage.ErrMixedCurrency) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "result": res})
+		return
+	}
+	if h.budget != nil {
+		h.budget.Reseed(todaySpend(h.store, h.budget))
+	}
+	c.JSON(http.StatusOK, gin.H{"result": res})
+}
+
+// todaySpend sums today's (budget-day) cost per agent from the usage JSONL.
+func todaySpend(store *usage.Store, b *budget.Store) map[string]float64 {
+	sum := store.Summarize(b.DayStart().Unix(), 0, "", "")
+	out := make(map[string]float64, len(sum.ByAgent))
+	for id, st := range sum.ByAgent {
+		out[id] = st.Cost
+	}
+	return out
+}
+
 // lookupSessionTitle reads the session index for the given agent and returns
 // the title (empty if not found or title not yet auto-generated).
 func lookupSessionTitle(mgr *agent.Manager, agentID, sessionID string) string {
@@ -274,3 +274,20 @@ func TestGlobalChannelTestRejectsTypesWithoutRealProbe(t *testing.T) {
 		t.Fatalf("unexpected test result: status=%q body=%s", cfg.Channels[0].Status, res.Body.String())
 	}
 }
+
+func TestGlobalChannelTestRejectsMaskedSecrets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := config.Default()
+	cfg.Channels = []config.ChannelEntry{{ID: "mail", Type: "email", Status: "untested", Config: map[string]string{
+		"address": "bot@example.com", "smtpHost": "smtp.example.com", "smtpPassword": "abcd***",
+	}}}
+	handler := &channelHandler{cfg: cfg, configPath: filepath.Join(t.TempDir(), "config.json")}
+	router := gin.New()
+	router.POST("/channels/:id/test", handler.Test)
+
+	res := httptest.NewRecorder()
+	router.ServeHTTP(res, httptest.NewRequest(http.MethodPost, "/channels/mail/test", nil))
+	if res.Code != http.StatusBadRequest || !strings.Contains(res.Body.String(), "smtpPassword is masked") {
+		t.Fatalf("status = %d, body=%s", res.Code, res.Body.String())
+	}
+}
@@ -30,6 +30,7 @@ func BuildCapabilitiesContext(reg *tools.Registry, ag *Agent, cfg *config.Config
 
 	ctx := tools.AgentHealthCtx{
 		ModelProvider: resolveModelProvider(ag, cfg),
+		Model:         resolveModelInfo(ag, cfg),
 		ChannelTypes:  collectChannelTypes(ag),
 		ToolAPIKeys:   collectToolKeys(cfg),
 		HasRelations:  hasAnyRelations(wsDir),
@@ -62,6 +63,25 @@ func resolveModelProvider(ag *Agent, cfg *config.Config) string {
 	return ""
 }
 
+// resolveModelInfo 解析 agent 绑定模型的能力（内置能力表 + 手动覆盖）。
+func resolveModelInfo(ag *Agent, cfg *config.Config) tools.ModelInfo {
+	if ag == nil || ag.ModelID == "" {
+		return tools.ModelInfo{}
+	}
+	m := cfg.FindModel(ag.ModelID)
+	if m == nil {
+		return tools.ModelInfo{}
+	}
+	caps := config.ModelCapabilities(m)
+	return tools.ModelInfo{
+		Name:          m.Model,
+		ContextWindow: caps.ContextWindow,
+		MaxOutput:     caps.MaxOutput,
+		Vision:        caps.Vision,
+		Reasoning:     caps.Reasoning,
+	}
+}
+
 func collectChannelTypes(ag *Agent) map[string]bool {
 	out := make(map[string]bool)
 	if ag == nil {
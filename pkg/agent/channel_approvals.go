@@ -0,0 +1,73 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/Zyling-ai/zyhive/pkg/channel"
+	"github.com/Zyling-ai/zyhive/pkg/tools"
+)
+
+// channelApprovalSubs numbers broker subscriptions made by channel drivers.
+var channelApprovalSubs atomic.Int64
+
+// ChannelApprovals adapts the pool's approval broker to channel.Approvals so
+// drivers with interactive buttons (Slack) can resolve tool approvals in
+// chat. Returns nil when no broker is attached.
+func (p *Pool) ChannelApprovals() channel.Approvals {
+	if p.approvalBroker == nil {
+		return nil
+	}
+	return &channelApprovals{broker: p.approvalBroker}
+}
+
+type channelApprovals struct {
+	broker *tools.Broker
+}
+
+// Watch forwards approval_request events until ctx ends.
+func (a *channelApprovals) Watch(ctx context.Context) <-chan channel.ApprovalPrompt {
+	events, unsub := a.broker.Subscribe(fmt.Sprintf("channel-%d", channelApprovalSubs.Add(1)))
+	out := make(chan channel.ApprovalPrompt, 4)
+	go func() {
+		defer close(out)
+		defer unsub()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				if ev.Type != "approval_request" || ev.Request == nil {
+					continue
+				}
+				req := ev.Request
+				select {
+				case out <- channel.ApprovalPrompt{
+					ID:        req.ID,
+					AgentID:   req.AgentID,
+					SessionID: req.SessionID,
+					ToolName:  req.ToolName,
+					Input:     string(req.Input),
+					ExpiresAt: req.ExpiresAt,
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+// Decide resolves a pending request on behalf of a chat user.
+func (a *channelApprovals) Decide(id string, approved bool, by string) error {
+	dec := tools.ApprovalDecision{Approved: approved, By: by}
+	if !approved {
+		dec.Reason = "渠道内拒绝"
+	}
+	return a.broker.Decide(id, dec)
+}
@@ -0,0 +1,218 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// jsonOutputRetries is how many times CallLLMOnceJSON re-asks after a
+// malformed reply.
+const jsonOutputRetries = 2
+
+// parseJSONOutput extracts the JSON value from an LLM reply (tolerating code
+// fences and surrounding prose) and validates it against schema (already
+// unmarshalled; nil skips validation).
+func parseJSONOutput(reply string, schema any) (json.RawMessage, error) {
+	js := extractJSONValue(reply)
+	if js == "" {
+		return nil, fmt.Errorf("no JSON value in reply")
+	}
+	dec := json.NewDecoder(strings.NewReader(js))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if s, ok := schema.(map[string]any); ok {
+		if err := validateSchema(v, s, "$"); err != nil {
+			return nil, err
+		}
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, []byte(js)); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return json.RawMessage(buf.Bytes()), nil
+}
+
+// ValidateJSONReply is parseJSONOutput for callers holding the raw schema
+// (e.g. eval graders). An empty schema only checks that a JSON value exists.
+func ValidateJSONReply(reply string, schema json.RawMessage) (json.RawMessage, error) {
+	var parsed any
+	if len(schema) > 0 {
+		if err := json.Unmarshal(schema, &parsed); err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+	}
+	return parseJSONOutput(reply, parsed)
+}
+
+// extractJSONValue returns the outermost {...} or [...] span of s, whichever
+// opens first.
+func extractJSONValue(s string) string {
+	s = strings.TrimSpace(s)
+	if json.Valid([]byte(s)) {
+		return s
+	}
+	start := strings.IndexAny(s, "{[")
+	if start < 0 {
+		return ""
+	}
+	closer := byte('}')
+	if s[start] == '[' {
+		closer = ']'
+	}
+	end := strings.LastIndexByte(s, closer)
+	if end <= start {
+		return ""
+	}
+	return s[start : end+1]
+}
+
+// validateSchema checks v against the subset of JSON Schema that structured
+// output prompts actually use: type, enum, const, properties, required,
+// additionalProperties:false, items, minItems/maxItems, minimum/maximum and
+// minLength/maxLength. Unknown keywords are ignored.
+func validateSchema(v any, s map[string]any, path string) error {
+	if t, ok := s["type"]; ok && !matchesType(v, t) {
+		return fmt.Errorf("%s: expected %v, got %s", path, t, jsonTypeOf(v))
+	}
+	if enum, ok := s["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if jsonEqual(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value not in enum %v", path, enum)
+		}
+	}
+	if c, ok := s["const"]; ok && !jsonEqual(v, c) {
+		return fmt.Errorf("%s: expected const %v", path, c)
+	}
+
+	switch val := v.(type) {
+	case map[string]any:
+		props, _ := s["properties"].(map[string]any)
+		if req, ok := s["required"].([]any); ok {
+			for _, r := range req {
+				if k, _ := r.(string); k != "" {
+					if _, present := val[k]; !present {
+						return fmt.Errorf("%s: missing required property %q", path, k)
+					}
+				}
+			}
+		}
+		for k, fv := range val {
+			sub, ok := props[k].(map[string]any)
+			if !ok {
+				if ap, isBool := s["additionalProperties"].(bool); isBool && !ap {
+					return fmt.Errorf("%s: unexpected property %q", path, k)
+				}
+				continue
+			}
+			if err := validateSchema(fv, sub, path+"."+k); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if n, ok := schemaInt(s, "minItems"); ok && len(val) < n {
+			return fmt.Errorf("%s: expected at least %d items, got %d", path, n, len(val))
+		}
+		if n, ok := schemaInt(s, "maxItems"); ok && len(val) > n {
+			return fmt.Errorf("%s: expected at most %d items, got %d", path, n, len(val))
+		}
+		if items, ok := s["items"].(map[string]any); ok {
+			for i, item := range val {
+				if err := validateSchema(item, items, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		n := len([]rune(val))
+		if m, ok := schemaInt(s, "minLength"); ok && n < m {
+			return fmt.Errorf("%s: shorter than %d characters", path, m)
+		}
+		if m, ok := schemaInt(s, "maxLength"); ok && n > m {
+			return fmt.Errorf("%s: longer than %d characters", path, m)
+		}
+	case json.Number:
+		f, _ := val.Float64()
+		if m, ok := s["minimum"].(float64); ok && f < m {
+			return fmt.Errorf("%s: %v is below minimum %v", path, val, m)
+		}
+		if m, ok := s["maximum"].(float64); ok && f > m {
+			return fmt.Errorf("%s: %v is above maximum %v", path, val, m)
+		}
+	}
+	return nil
+}
+
+// matchesType accepts a single type name or a list of them.
+func matchesType(v any, t any) bool {
+	switch tt := t.(type) {
+	case string:
+		return typeIs(v, tt)
+	case []any:
+		for _, x := range tt {
+			if name, ok := x.(string); ok && typeIs(v, name) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func typeIs(v any, name string) bool {
+	got := jsonTypeOf(v)
+	if name == "number" && got == "integer" {
+		return true
+	}
+	return got == name
+}
+
+func jsonTypeOf(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	case json.Number:
+		if f, err := val.Float64(); err == nil && f == math.Trunc(f) && !strings.ContainsAny(val.String(), ".eE") {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// jsonEqual compares a decoded value (json.Number for numbers) with a schema
+// literal (float64 for numbers).
+func jsonEqual(v, lit any) bool {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		lf, isNum := lit.(float64)
+		return err == nil && isNum && f == lf
+	}
+	a, _ := json.Marshal(v)
+	b, _ := json.Marshal(lit)
+	return bytes.Equal(a, b)
+}
+
+func schemaInt(s map[string]any, key string) (int, bool) {
+	f, ok := s[key].(float64)
+	return int(f), ok
+}
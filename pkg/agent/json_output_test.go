@@ -0,0 +1,62 @@
+package agent
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONOutput(t *testing.T) {
+	var schema any
+	_ = json.Unmarshal([]byte(`{
+		"type": "object",
+		"properties": {
+			"verdict": {"type": "string", "enum": ["pass", "fail"]},
+			"score":   {"type": "integer", "minimum": 0, "maximum": 10},
+			"tags":    {"type": "array", "items": {"type": "string"}}
+		},
+		"required": ["verdict", "score"],
+		"additionalProperties": false
+	}`), &schema)
+
+	cases := []struct {
+		name  string
+		reply string
+		err   string // substring; "" means valid
+	}{
+		{"plain", `{"verdict":"pass","score":7}`, ""},
+		{"fenced", "Here you go:\n```json\n{\"verdict\": \"fail\", \"score\": 0, \"tags\": [\"x\"]}\n```", ""},
+		{"no json", "I cannot do that.", "no JSON value"},
+		{"broken", `{"verdict":"pass","score":}`, "invalid JSON"},
+		{"missing", `{"verdict":"pass"}`, `missing required property "score"`},
+		{"enum", `{"verdict":"maybe","score":1}`, "$.verdict: value not in enum"},
+		{"type", `{"verdict":"pass","score":7.5}`, "$.score: expected integer"},
+		{"range", `{"verdict":"pass","score":11}`, "above maximum"},
+		{"items", `{"verdict":"pass","score":1,"tags":[1]}`, "$.tags[0]: expected string"},
+		{"extra", `{"verdict":"pass","score":1,"why":"x"}`, `unexpected property "why"`},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := parseJSONOutput(tc.reply, schema)
+			if tc.err == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if !json.Valid(out) || strings.ContainsAny(string(out), " \n") {
+					t.Fatalf("output not compact JSON: %q", out)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("err = %v, want %q", err, tc.err)
+			}
+		})
+	}
+}
+
+func TestParseJSONOutputArrayWithoutSchema(t *testing.T) {
+	out, err := parseJSONOutput("result: [1, 2]", nil)
+	if err != nil || string(out) != "[1,2]" {
+		t.Fatalf("out=%s err=%v", out, err)
+	}
+}
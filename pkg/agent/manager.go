@@ -28,39 +28,43 @@ const SystemConfigAgentID = "__config__"
 
 // Agent represents a single AI agent (employee) managed by the panel.
 type Agent struct {
-	ID            string                  `json:"id"`
-	Name          string                  `json:"name"`
-	Description   string                  `json:"description,omitempty"`
-	Model         string                  `json:"model"`              // legacy: "provider/model"
-	ModelID       string                  `json:"modelId"`            // references Config.Models[].ID
-	Channels      []config.ChannelEntry   `json:"channels,omitempty"` // per-agent channels (own bots)
-	ToolIDs       []string                `json:"toolIds,omitempty"`
-	SkillIDs      []string                `json:"skillIds,omitempty"`
-	AvatarColor   string                  `json:"avatarColor,omitempty"`
-	System        bool                    `json:"system,omitempty"` // built-in, cannot be deleted
-	Env           map[string]string       `json:"env,omitempty"`    // per-agent environment variables for exec tool
-	WorkspaceDir  string                  `json:"workspaceDir"`
-	SessionDir    string                  `json:"sessionDir"`
-	Status        string                  `json:"status"`               // "running" | "stopped" | "idle"
-	Heartbeat     *config.HeartbeatConfig `json:"heartbeat,omitempty"`  // nil = heartbeat disabled
-	ToolPolicyRaw json.RawMessage         `json:"toolPolicy,omitempty"` // nil = inherit global
+	ID            string                   `json:"id"`
+	Name          string                   `json:"name"`
+	Description   string                   `json:"description,omitempty"`
+	Model         string                   `json:"model"`              // legacy: "provider/model"
+	ModelID       string                   `json:"modelId"`            // references Config.Models[].ID
+	Channels      []config.ChannelEntry    `json:"channels,omitempty"` // per-agent channels (own bots)
+	ToolIDs       []string                 `json:"toolIds,omitempty"`
+	SkillIDs      []string                 `json:"skillIds,omitempty"`
+	MCPServerIDs  []string                 `json:"mcpServerIds,omitempty"` // Config.MCPServers ids; "*" = all
+	AvatarColor   string                   `json:"avatarColor,omitempty"`
+	System        bool                     `json:"system,omitempty"` // built-in, cannot be deleted
+	Env           map[string]string        `json:"env,omitempty"`    // per-agent environment variables for exec tool
+	WorkspaceDir  string                   `json:"workspaceDir"`
+	SessionDir    string                   `json:"sessionDir"`
+	Status        string                   `json:"status"`               // "running" | "stopped" | "idle"
+	Heartbeat     *config.HeartbeatConfig  `json:"heartbeat,omitempty"`  // nil = heartbeat disabled
+	ToolPolicyRaw json.RawMessage          `json:"toolPolicy,omitempty"` // nil = inherit global
+	Generation    *config.GenerationParams `json:"generation,omitempty"` // nil = model defaults
 }
 
 // agentConfig is the on-disk config.json format for each agent.
 type agentConfig struct {
-	ID            string                  `json:"id"`
-	Name          string                  `json:"name"`
-	Description   string                  `json:"description,omitempty"`
-	Model         string                  `json:"model,omitempty"` // legacy compat
-	ModelID       string                  `json:"modelId,omitempty"`
-	Channels      []config.ChannelEntry   `json:"channels,omitempty"` // per-agent channels
-	ToolIDs       []string                `json:"toolIds,omitempty"`
-	SkillIDs      []string                `json:"skillIds,omitempty"`
-	AvatarColor   string                  `json:"avatarColor,omitempty"`
-	System        bool                    `json:"system,omitempty"`
-	Env           map[string]string       `json:"env,omitempty"`        // per-agent env vars for exec
-	Heartbeat     *config.HeartbeatConfig `json:"heartbeat,omitempty"`  // nil = disabled
-	ToolPolicyRaw json.RawMessage         `json:"toolPolicy,omitempty"` // nil = inherit global
+	ID            string                   `json:"id"`
+	Name          string                   `json:"name"`
+	Description   string                   `json:"description,omitempty"`
+	Model         string                   `json:"model,omitempty"` // legacy compat
+	ModelID       string                   `json:"modelId,omitempty"`
+	Channels      []config.ChannelEntry    `json:"channels,omitempty"` // per-agent channels
+	ToolIDs       []string                 `json:"toolIds,omitempty"`
+	SkillIDs      []string                 `json:"skillIds,omitempty"`
+	MCPServerIDs  []string                 `json:"mcpServerIds,omitempty"` // Config.MCPServers ids; "*" = all
+	AvatarColor   string                   `json:"avatarColor,omitempty"`
+	System        bool                     `json:"system,omitempty"`
+	Env           map[string]string        `json:"env,omitempty"`        // per-agent env vars for exec
+	Heartbeat     *config.HeartbeatConfig  `json:"heartbeat,omitempty"`  // nil = disabled
+	ToolPolicyRaw json.RawMessage          `json:"toolPolicy,omitempty"` // nil = inherit global
+	Generation    *config.GenerationParams `json:"generation,omitempty"` // nil = model defaults
 }
 
 // Manager manages all agents under a root directory.
@@ -149,6 +153,7 @@ func (m *Manager) LoadAll() error {
 			Channels:      cfg.Channels,
 			ToolIDs:       cfg.ToolIDs,
 			SkillIDs:      cfg.SkillIDs,
+			MCPServerIDs:  cfg.MCPServerIDs,
 			AvatarColor:   cfg.AvatarColor,
 			System:        cfg.System,
 			Env:           cfg.Env,
@@ -157,6 +162,7 @@ func (m *Manager) LoadAll() error {
 			SessionDir:    filepath.Join(agentDir, "sessions"),
 			Status:        "idle",
 			ToolPolicyRaw: cfg.ToolPolicyRaw,
+			Generation:    cfg.Generation,
 		}
 
 		// Migrate flat MEMORY.md → hierarchical memory tree if needed
@@ -249,6 +255,7 @@ type CreateOpts struct {
 	Channels      []config.ChannelEntry `json:"channels,omitempty"` // per-agent channels
 	ToolIDs       []string              `json:"toolIds,omitempty"`
 	SkillIDs      []string              `json:"skillIds,omitempty"`
+	MCPServerIDs  []string              `json:"mcpServerIds,omitempty"`
 	AvatarColor   string                `json:"avatarColor,omitempty"`
 	System        bool                  `json:"system,omitempty"`
 	Env           map[string]string     `json:"env,omitempty"`
@@ -315,6 +322,7 @@ func (m *Manager) CreateWithOpts(opts CreateOpts) (*Agent, error) {
 		Channels:      opts.Channels,
 		ToolIDs:       opts.ToolIDs,
 		SkillIDs:      opts.SkillIDs,
+		MCPServerIDs:  opts.MCPServerIDs,
 		AvatarColor:   opts.AvatarColor,
 		System:        opts.System,
 		Env:           opts.Env,
@@ -346,6 +354,7 @@ func (m *Manager) CreateWithOpts(opts CreateOpts) (*Agent, error) {
 		Channels:      opts.Channels,
 		ToolIDs:       opts.ToolIDs,
 		SkillIDs:      opts.SkillIDs,
+		MCPServerIDs:  opts.MCPServerIDs,
 		AvatarColor:   opts.AvatarColor,
 		System:        opts.System,
 		Env:           opts.Env,
@@ -403,11 +412,14 @@ type UpdateOpts struct {
 	AvatarColor   *string                 `json:"avatarColor,omitempty"`
 	ToolIDs       []string                `json:"toolIds"`
 	SkillIDs      []string                `json:"skillIds"`
+	MCPServerIDs  []string                `json:"mcpServerIds"`
 	Env           map[string]string       `json:"env"` // nil = leave unchanged; non-nil (even empty) = replace
 	HeartbeatSet  bool                    // true = apply Heartbeat (even if nil = clear)
 	Heartbeat     *config.HeartbeatConfig // nil = disable heartbeat
 	ToolPolicySet bool                    // true = apply ToolPolicyRaw (even if nil/empty = clear policy)
 	ToolPolicyRaw json.RawMessage         // raw JSON for toolPolicy; nil = no policy
+	GenerationSet bool                    // true = apply Generation (even if nil = model defaults)
+	Generation    *config.GenerationParams
 }
 
 // UpdateAgent patches an agent's config fields and persists to disk.
@@ -461,6 +473,10 @@ func (m *Manager) UpdateAgent(agentID string, opts UpdateOpts) error {
 		cfg.SkillIDs = opts.SkillIDs
 		candidate.SkillIDs = append([]string(nil), opts.SkillIDs...)
 	}
+	if opts.MCPServerIDs != nil {
+		cfg.MCPServerIDs = opts.MCPServerIDs
+		candidate.MCPServerIDs = append([]string(nil), opts.MCPServerIDs...)
+	}
 	if opts.Env != nil {
 		cfg.Env = opts.Env
 		candidate.Env = cloneStringMap(opts.Env)
@@ -473,6 +489,10 @@ func (m *Manager) UpdateAgent(agentID string, opts UpdateOpts) error {
 		cfg.ToolPolicyRaw = opts.ToolPolicyRaw
 		candidate.ToolPolicyRaw = append(json.RawMessage(nil), opts.ToolPolicyRaw...)
 	}
+	if opts.GenerationSet {
+		cfg.Generation = opts.Generation
+		candidate.Generation = opts.Generation
+	}
 
 	out, err := json.MarshalIndent(cfg, "", "  ")
 	if err != nil {
@@ -619,7 +639,14 @@ func cloneChannels(source []config.ChannelEntry) []config.ChannelEntry {
 // excluding excludeAgentID (so an agent can update its own token without false conflict).
 // Returns nil if no other agent uses this token.
 func (m *Manager) FindAgentByBotToken(token, excludeAgentID string) (*Agent, string) {
-	if token == "" {
+	return m.FindAgentByChannelKey("telegram", "botToken", token, excludeAgentID)
+}
+
+// FindAgentByChannelKey generalizes FindAgentByBotToken to any channel type:
+// it returns the agent (other than excludeAgentID) and channel name whose
+// channel of channelType has Config[key] == value, or nil.
+func (m *Manager) FindAgentByChannelKey(channelType, key, value, excludeAgentID string) (*Agent, string) {
+	if value == "" {
 		return nil, ""
 	}
 	m.mu.RLock()
@@ -629,7 +656,7 @@ func (m *Manager) FindAgentByBotToken(token, excludeAgentID string) (*Agent, str
 			continue
 		}
 		for _, ch := range ag.Channels {
-			if ch.Type == "telegram" && ch.Config["botToken"] == token {
+			if ch.Type == channelType && ch.Config[key] == value {
 				return ag, ch.Name
 			}
 		}
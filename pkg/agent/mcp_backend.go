@@ -0,0 +1,255 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
+	"github.com/Zyling-ai/zyhive/pkg/memory"
+	"github.com/Zyling-ai/zyhive/pkg/safefs"
+	"github.com/Zyling-ai/zyhive/pkg/session"
+)
+
+// maxMCPResourceBytes caps a single project file served as an MCP resource.
+const maxMCPResourceBytes = 1 << 20
+
+// maxMCPProjectFiles caps the files listed per project in resources/list.
+const maxMCPProjectFiles = 200
+
+// mcpSessionPrefix marks sessions created by MCP clients.
+const mcpSessionPrefix = "mcp-"
+
+// MCPBackend adapts the pool to mcp.Backend so external MCP clients can talk
+// to agents. Runs go through RunStreamEvents, so usage recording, budget
+// checks and the tool audit log apply exactly as for channel messages.
+// System agents (config assistant) are never exposed.
+func (p *Pool) MCPBackend() mcp.Backend {
+	return &mcpBackend{pool: p}
+}
+
+type mcpBackend struct {
+	pool *Pool
+}
+
+func (b *mcpBackend) agent(id string) (*Agent, error) {
+	ag, ok := b.pool.manager.Get(id)
+	if !ok || ag.System {
+		return nil, fmt.Errorf("agent %q not found", id)
+	}
+	return ag, nil
+}
+
+func (b *mcpBackend) ListAgents() []mcp.AgentInfo {
+	var out []mcp.AgentInfo
+	for _, ag := range b.pool.manager.List() {
+		if ag.System {
+			continue
+		}
+		out = append(out, mcp.AgentInfo{ID: ag.ID, Name: ag.Name, Description: ag.Description})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Ask runs one turn. MCP clients may only continue sessions they started
+// (ids with mcpSessionPrefix), never channel, web or panel sessions.
+func (b *mcpBackend) Ask(ctx context.Context, agentID, sessionID, message string) (mcp.AskResult, error) {
+	if _, err := b.agent(agentID); err != nil {
+		return mcp.AskResult{}, err
+	}
+	if sessionID == "" {
+		sessionID = fmt.Sprintf("%s%d", mcpSessionPrefix, time.Now().UnixMilli())
+	} else if err := safefs.ValidateResourceID(sessionID); err != nil {
+		return mcp.AskResult{}, fmt.Errorf("invalid sessionId: %w", err)
+	} else if !strings.HasPrefix(sessionID, mcpSessionPrefix) {
+		return mcp.AskResult{}, fmt.Errorf("sessionId %q was not started over MCP", sessionID)
+	}
+	events, err := b.pool.RunStreamEvents(ctx, agentID, message, sessionID, nil, nil)
+	if err != nil {
+		return mcp.AskResult{}, err
+	}
+	var reply strings.Builder
+	var runErr error
+	for ev := range events {
+		switch ev.Type {
+		case "text_delta":
+			reply.WriteString(ev.Text)
+		case "error":
+			runErr = ev.Err
+		}
+	}
+	if runErr != nil {
+		return mcp.AskResult{}, runErr
+	}
+	if err := ctx.Err(); err != nil {
+		return mcp.AskResult{}, err
+	}
+	return mcp.AskResult{Reply: reply.String(), SessionID: sessionID}, nil
+}
+
+func (b *mcpBackend) ListSessions(agentID string) ([]mcp.SessionInfo, error) {
+	ag, err := b.agent(agentID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := session.NewStore(ag.SessionDir).ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].LastAt > entries[j].LastAt })
+	out := make([]mcp.SessionInfo, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, mcp.SessionInfo{ID: e.ID, Title: e.Title})
+	}
+	return out, nil
+}
+
+// ReadSession renders the transcript as markdown (text only; tool calls are
+// summarised by name).
+func (b *mcpBackend) ReadSession(agentID, sessionID string) (string, error) {
+	ag, err := b.agent(agentID)
+	if err != nil {
+		return "", err
+	}
+	store := session.NewStore(ag.SessionDir)
+	meta, ok := store.GetMeta(sessionID)
+	if !ok {
+		return "", fmt.Errorf("session %q not found", sessionID)
+	}
+	msgs, _, err := store.ReadHistory(sessionID)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	title := meta.Title
+	if title == "" {
+		title = sessionID
+	}
+	fmt.Fprintf(&sb, "# %s\n", title)
+	for _, m := range msgs {
+		text := extractTextFromContent(m.Content)
+		if text == "" && len(m.ToolCalls) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n## %s\n\n", m.Role)
+		if text != "" {
+			sb.WriteString(text + "\n")
+		}
+		for _, tc := range m.ToolCalls {
+			fmt.Fprintf(&sb, "\n_tool: %s_\n", tc.Name)
+		}
+	}
+	return sb.String(), nil
+}
+
+func (b *mcpBackend) ListMemory(agentID string) ([]string, error) {
+	ag, err := b.agent(agentID)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := memory.NewMemoryTree(ag.WorkspaceDir).ListTree()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	var walk func([]memory.FileNode)
+	walk = func(nodes []memory.FileNode) {
+		for _, n := range nodes {
+			if n.IsDir {
+				walk(n.Children)
+			} else {
+				out = append(out, n.Path)
+			}
+		}
+	}
+	walk(nodes)
+	return out, nil
+}
+
+func (b *mcpBackend) ReadMemory(agentID, path string) (string, error) {
+	ag, err := b.agent(agentID)
+	if err != nil {
+		return "", err
+	}
+	return memory.NewMemoryTree(ag.WorkspaceDir).GetFile(path)
+}
+
+func (b *mcpBackend) ListProjects() []mcp.ProjectInfo {
+	if b.pool.projectMgr == nil {
+		return nil
+	}
+	var out []mcp.ProjectInfo
+	for _, proj := range b.pool.projectMgr.List() {
+		out = append(out, mcp.ProjectInfo{ID: proj.ID, Name: proj.Name, Description: proj.Description})
+	}
+	return out
+}
+
+func (b *mcpBackend) ListProjectFiles(projectID string) ([]string, error) {
+	if b.pool.projectMgr == nil {
+		return nil, errors.New("projects not available")
+	}
+	proj, ok := b.pool.projectMgr.Get(projectID)
+	if !ok {
+		return nil, fmt.Errorf("project %q not found", projectID)
+	}
+	var out []string
+	err := filepath.WalkDir(proj.FilesDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || d.Name() == "meta.json" || !d.Type().IsRegular() {
+			return nil
+		}
+		if len(out) >= maxMCPProjectFiles {
+			return filepath.SkipAll
+		}
+		if rel, err := filepath.Rel(proj.FilesDir, path); err == nil {
+			out = append(out, filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	return out, err
+}
+
+// ReadProjectFile returns a text file from a project; binary and oversized
+// files are refused rather than mangled.
+func (b *mcpBackend) ReadProjectFile(projectID, path string) (string, error) {
+	if b.pool.projectMgr == nil {
+		return "", errors.New("projects not available")
+	}
+	proj, ok := b.pool.projectMgr.Get(projectID)
+	if !ok {
+		return "", fmt.Errorf("project %q not found", projectID)
+	}
+	if filepath.Base(path) == "meta.json" {
+		return "", errors.New("reserved")
+	}
+	absPath, err := safefs.ConfineToBase(proj.FilesDir, path)
+	if err != nil {
+		return "", errors.New("path escapes project")
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a file", path)
+	}
+	if info.Size() > maxMCPResourceBytes {
+		return "", fmt.Errorf("%s is too large (%d bytes)", path, info.Size())
+	}
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data[:min(len(data), 512)], 0) >= 0 {
+		return "", fmt.Errorf("%s is a binary file", path)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,21 @@
+package agent
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMCPAskRefusesNonMCPSessions(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	if _, err := manager.Create("helper", "Helper", "test-model"); err != nil {
+		t.Fatal(err)
+	}
+	backend := (&Pool{manager: manager}).MCPBackend()
+	for _, sid := range []string{"feishu-oc_123", "web-abc", "session-1"} {
+		_, err := backend.Ask(context.Background(), "helper", sid, "hi")
+		if err == nil || !strings.Contains(err.Error(), "not started over MCP") {
+			t.Errorf("Ask(%q) err = %v, want refusal", sid, err)
+		}
+	}
+}
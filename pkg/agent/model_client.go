@@ -0,0 +1,139 @@
+package agent
+
+import (
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/session"
+	"github.com/Zyling-ai/zyhive/pkg/usage"
+)
+
+// NewModelClient returns the llm.Client for model entry m, using the caller's
+// already-resolved apiKey + baseURL for the primary. When m declares
+// fallbacks, the primary and every usable fallback are chained through
+// llm.WithFailover; otherwise it's the plain provider client, as before.
+//
+// Fallbacks are skipped when they don't resolve to a model, have no key, were
+// last tested as broken (model or provider status "error"), or can't call
+// tools while the primary can — the history may already hold tool_use blocks.
+func NewModelClient(cfg *config.Config, m *config.ModelEntry, apiKey, baseURL string) llm.Client {
+	primary := LLMClient(m, baseURL)
+	if len(m.Fallbacks) == 0 {
+		return primary
+	}
+	targets := []llm.FailoverTarget{{
+		Provider: m.Provider,
+		Model:    m.ProviderModel(),
+		APIKey:   apiKey,
+		BaseURL:  baseURL,
+		Client:   primary,
+	}}
+	seen := map[string]bool{m.ID: true}
+	needTools := config.ModelSupportsTools(m)
+	for _, id := range m.Fallbacks {
+		fb := cfg.FindModel(id)
+		if fb == nil || seen[fb.ID] {
+			continue
+		}
+		seen[fb.ID] = true
+		if fb.Status == "error" || (needTools && !config.ModelSupportsTools(fb)) {
+			continue
+		}
+		if p := cfg.FindProvider(fb.ProviderID); p != nil && p.Status == "error" {
+			continue
+		}
+		fbKey, fbBase := config.ResolveCredentials(fb, cfg.Providers)
+		if fbKey == "" && llm.RequiresAPIKey(fb.Provider) {
+			continue
+		}
+		targets = append(targets, llm.FailoverTarget{
+			Provider: fb.Provider,
+			Model:    fb.ProviderModel(),
+			APIKey:   fbKey,
+			BaseURL:  fbBase,
+			Client:   LLMClient(fb, fbBase),
+		})
+	}
+	if len(targets) == 1 {
+		return primary
+	}
+	return llm.WithFailover(targets)
+}
+
+// LLMClient returns the plain (no failover) provider client for m, honouring
+// its API selection (e.g. the OpenAI Responses API).
+func LLMClient(m *config.ModelEntry, baseURL string) llm.Client {
+	return llm.NewClientWithOptions(m.Provider, baseURL, llm.ClientOptions{
+		API:            config.ModelAPI(m),
+		ChainResponses: m.ChainResponses,
+	})
+}
+
+// ApplyGeneration copies g onto req without overriding anything the caller
+// already set on the request.
+func ApplyGeneration(req *llm.ChatRequest, g *config.GenerationParams) {
+	if g == nil {
+		return
+	}
+	if req.Temperature == nil && g.Temperature != nil {
+		t := *g.Temperature
+		req.Temperature = &t
+	}
+	if req.TopP == nil && g.TopP != nil {
+		p := *g.TopP
+		req.TopP = &p
+	}
+	if len(req.Stop) == 0 && len(g.Stop) > 0 {
+		req.Stop = append([]string(nil), g.Stop...)
+	}
+	if req.ThinkingBudget == 0 {
+		req.ThinkingBudget = g.ThinkingBudget
+	}
+}
+
+// GenerationHook returns a runner.Config.PrepareRequest applying m's
+// generation defaults overlaid with ag's (ag may be nil), or nil when
+// neither sets anything.
+func GenerationHook(m *config.ModelEntry, ag *Agent) func(*llm.ChatRequest) {
+	var over *config.GenerationParams
+	if ag != nil {
+		over = ag.Generation
+	}
+	if m.Generation == nil && over == nil {
+		return nil
+	}
+	g := config.MergeGeneration(m.Generation, over)
+	return func(req *llm.ChatRequest) { ApplyGeneration(req, g) }
+}
+
+// CompactionThreshold is the runner.Config.CompactionThreshold for model m:
+// a fixed share of its context window (see session.CompactionThresholdFor).
+func CompactionThreshold(m *config.ModelEntry) int {
+	caps := config.ModelCapabilities(m)
+	return session.CompactionThresholdFor(caps.ContextWindow, caps.MaxOutput)
+}
+
+// NewUsageRecord builds the usage.Record for one runner turn: the provider's
+// own reported cost when it sent one (OpenRouter), otherwise the active
+// pricing table with the model's ProviderEntry overrides.
+func NewUsageRecord(cfg *config.Config, u llm.Usage, provider, model, agentID, sessionID string) usage.Record {
+	rec := usage.Record{
+		ID:               usage.NewID(),
+		AgentID:          agentID,
+		SessionID:        sessionID,
+		Provider:         provider,
+		Model:            model,
+		InputTokens:      u.InputTokens,
+		OutputTokens:     u.OutputTokens,
+		CacheReadTokens:  u.CacheReadTokens,
+		CacheWriteTokens: u.CacheWriteTokens,
+		ReasoningTokens:  u.ReasoningTokens,
+		ProviderID:       usage.ResolveProviderID(cfg, provider, model),
+		CreatedAt:        timeNow(),
+	}
+	if u.Cost > 0 {
+		rec.Cost, rec.CostSource = u.Cost, usage.CostSourceProvider
+		return rec
+	}
+	usage.DefaultPricer().PriceRecord(&rec, usage.ConfigOverrides(cfg)(rec))
+	return rec
+}
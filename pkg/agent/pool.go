@@ -27,7 +27,9 @@ import (
 	"github.com/Zyling-ai/zyhive/pkg/channel"
 	"github.com/Zyling-ai/zyhive/pkg/config"
 	"github.com/Zyling-ai/zyhive/pkg/cron"
+	"github.com/Zyling-ai/zyhive/pkg/knowledge"
 	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
 	"github.com/Zyling-ai/zyhive/pkg/memory"
 	"github.com/Zyling-ai/zyhive/pkg/project"
 	"github.com/Zyling-ai/zyhive/pkg/runner"
@@ -36,23 +38,27 @@ import (
 	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
 	"github.com/Zyling-ai/zyhive/pkg/tools"
 	"github.com/Zyling-ai/zyhive/pkg/usage"
+	"github.com/Zyling-ai/zyhive/pkg/voice"
 )
 
 // Pool manages multiple concurrent agent runners (one per agent).
 type Pool struct {
-	manager     *Manager
-	cfg         *config.Config
-	projectMgr  *project.Manager    // shared project workspace (may be nil)
-	SubagentMgr *subagent.Manager   // background task manager (set after NewPool)
-	workerPool  *session.WorkerPool // session worker pool for subagent broadcast (may be nil)
-	browserMgr  *browser.Manager    // shared headless browser (lazy-init, may be nil if disabled)
-	runners     map[string]*runner.Runner
-	mu          sync.Mutex
+	manager      *Manager
+	cfg          *config.Config
+	projectMgr   *project.Manager    // shared project workspace (may be nil)
+	knowledgeMgr *knowledge.Manager  // knowledge bases (may be nil)
+	SubagentMgr  *subagent.Manager   // background task manager (set after NewPool)
+	workerPool   *session.WorkerPool // session worker pool for subagent broadcast (may be nil)
+	browserMgr   *browser.Manager    // shared headless browser (lazy-init, may be nil if disabled)
+	runners      map[string]*runner.Runner
+	mu           sync.Mutex
 
 	// messageSenderFn returns a MessageSenderFunc for the given agentID.
 	// Used to inject the send_message tool so agents can proactively push notifications
 	// (e.g. from isolated cron sessions with delivery=none).
 	messageSenderFn func(agentID string) tools.MessageSenderFunc
+	// emailSenderFn returns the email_send sender for the given agentID.
+	emailSenderFn func(agentID string) tools.EmailSenderFunc
 
 	usageStore *usage.Store // records LLM API usage; nil = disabled
 
@@ -100,6 +106,10 @@ type Pool struct {
 
 	// ACP agents (external coding CLIs) — injected from config.
 	acpAgents []config.ACPAgentEntry
+
+	// mcpMgr — shared MCP client connections; agents mount the servers
+	// listed in their mcpServerIds. May be nil.
+	mcpMgr *mcp.Manager
 }
 
 // NewPool creates a new multi-agent runner pool.
@@ -162,6 +172,17 @@ func (p *Pool) SetApprovalBroker(b *tools.Broker) {
 	p.approvalBroker = b
 }
 
+// SetMCPManager attaches the shared MCP client manager so agents can mount
+// external MCP server tools.
+func (p *Pool) SetMCPManager(m *mcp.Manager) {
+	p.mcpMgr = m
+}
+
+// MCPManager returns the shared MCP client manager (may be nil).
+func (p *Pool) MCPManager() *mcp.Manager {
+	return p.mcpMgr
+}
+
 // ── Heartbeat ────────────────────────────────────────────────────────────────
 
 const defaultHeartbeatPrompt = "Read HEARTBEAT.md if it exists. Follow it strictly. Do not infer or repeat old tasks from prior chats. If nothing needs attention, reply HEARTBEAT_OK."
@@ -324,6 +345,14 @@ func (p *Pool) SetProjectManager(mgr *project.Manager) {
 	p.projectMgr = mgr
 }
 
+// SetKnowledgeManager attaches the knowledge bases: agents get kb_search
+// for the ones attached to them, and auto-retrieval for those with
+// AutoRetrieve on. The manager embeds with the pool's embedder.
+func (p *Pool) SetKnowledgeManager(mgr *knowledge.Manager) {
+	mgr.SetEmbedder(p.resolveEmbedder)
+	p.knowledgeMgr = mgr
+}
+
 // poolSessionAdapter aggregates session data across all agents for the sessions_* tools.
 type poolSessionAdapter struct {
 	pool *Pool
@@ -440,6 +469,16 @@ func (a *poolSessionAdapter) GetMeta(sessionID string) (session.SessionIndexEntr
 	return session.SessionIndexEntry{}, false
 }
 
+// SearchSessions implements tools.SessionSearcher over one agent's store.
+func (a *poolSessionAdapter) SearchSessions(ctx context.Context, agentID string, q session.SearchQuery) ([]session.SearchHit, error) {
+	ag, ok := a.pool.manager.Get(agentID)
+	if !ok {
+		return nil, fmt.Errorf("agent %q not found", agentID)
+	}
+	q.Embedder = a.pool.SearchEmbedder()
+	return session.NewStore(ag.SessionDir).Search(ctx, q)
+}
+
 // poolSessionSender sends a message to another agent using the pool.
 type poolSessionSender struct {
 	pool *Pool
@@ -460,6 +499,11 @@ func (p *Pool) GetProjectMgr() *project.Manager {
 	return p.projectMgr
 }
 
+// GetKnowledgeMgr returns the knowledge base manager (may be nil).
+func (p *Pool) GetKnowledgeMgr() *knowledge.Manager {
+	return p.knowledgeMgr
+}
+
 // SetMessageSenderFn wires the proactive message-sending capability into the pool.
 // fn(agentID) returns a MessageSenderFunc that routes to the agent's active channel.
 // Called from main.go after the bot pool is available.
@@ -467,6 +511,11 @@ func (p *Pool) SetMessageSenderFn(fn func(agentID string) tools.MessageSenderFun
 	p.messageSenderFn = fn
 }
 
+// SetEmailSenderFn wires the email_send tool (agents with an email channel).
+func (p *Pool) SetEmailSenderFn(fn func(agentID string) tools.EmailSenderFunc) {
+	p.emailSenderFn = fn
+}
+
 // SetUsageStore wires up the usage recorder. Call once from main after NewPool.
 func (p *Pool) SetUsageStore(s *usage.Store) { p.usageStore = s }
 
@@ -587,24 +636,13 @@ func (p *Pool) budgetChecker() func(agentID string) runner.BudgetCheckResult {
 }
 
 // usageRecorder returns a recorder func for use in runner.Config.
-func (p *Pool) usageRecorder() func(in, out int, provider, model, agentID, sessionID string) {
+func (p *Pool) usageRecorder() func(u llm.Usage, provider, model, agentID, sessionID string) {
 	if p.usageStore == nil {
 		return nil
 	}
 	store := p.usageStore
-	return func(in, out int, provider, model, agentID, sessionID string) {
-		rec := usage.Record{
-			ID:           usage.NewID(),
-			AgentID:      agentID,
-			SessionID:    sessionID,
-			Provider:     provider,
-			Model:        model,
-			InputTokens:  in,
-			OutputTokens: out,
-			Cost:         usage.EstimateCost(model, in, out),
-			CreatedAt:    timeNow(),
-		}
-		_ = store.Append(rec)
+	return func(u llm.Usage, provider, model, agentID, sessionID string) {
+		_ = store.Append(NewUsageRecord(p.cfg, u, provider, model, agentID, sessionID))
 	}
 }
 
@@ -656,6 +694,11 @@ func (p *Pool) configureToolRegistry(reg *tools.Registry, ag *Agent, fileSender
 	embedder, embedAPIKey := p.resolveEmbedder()
 	reg.WithMemorySearch(memTree, embedder, embedAPIKey)
 
+	// Register kb_search over the knowledge bases attached to this agent.
+	if p.knowledgeMgr != nil {
+		reg.WithKnowledgeSearch(p.knowledgeMgr, ag.ID)
+	}
+
 	// Register browser automation tools (headless Chrome; lazy-starts on first use).
 	if p.browserMgr != nil {
 		reg.WithBrowser(p.browserMgr, ag.WorkspaceDir)
@@ -671,7 +714,7 @@ func (p *Pool) configureToolRegistry(reg *tools.Registry, ag *Agent, fileSender
 	// Register image vision tool — uses the agent's configured model for analysis.
 	if modelEntry, err := p.resolveModel(ag); err == nil {
 		resolvedAPIKey, resolvedBaseURL := config.ResolveCredentials(modelEntry, p.cfg.Providers)
-		visionClient := llm.NewClient(modelEntry.Provider, resolvedBaseURL)
+		visionClient := LLMClient(modelEntry, resolvedBaseURL)
 		caller := tools.BuildVisionCaller(visionClient, modelEntry.Model, resolvedAPIKey)
 		reg.WithVisionCaller(caller)
 	}
@@ -684,6 +727,14 @@ func (p *Pool) configureToolRegistry(reg *tools.Registry, ag *Agent, fileSender
 		}
 	}
 
+	// Register tts when a TTS provider (openai_tts / elevenlabs) is configured.
+	if _, tts := voice.FromTools(p.cfg.Tools); tts != nil {
+		reg.WithTTS(func(ctx context.Context, text, v string) ([]byte, string, error) {
+			audio, ct, err := tts.Synthesize(ctx, text, v)
+			return audio, voice.Ext(ct), err
+		})
+	}
+
 	// Register cron_list/add/remove + self_schedule tools if cron engine is
 	// available. self_schedule is the AI-friendly one-shot reminder front-end;
 	// it must be registered AFTER WithCronEngine because it depends on
@@ -698,6 +749,7 @@ func (p *Pool) configureToolRegistry(reg *tools.Registry, ag *Agent, fileSender
 	sessAdapter := p.buildSessionAdapter()
 	sessionSender := &poolSessionSender{pool: p}
 	reg.WithSessionTools(sessAdapter, sessAdapter, sessionSender, sessAdapter)
+	reg.WithSessionSearch(sessAdapter)
 
 	// Register ACP tools (acp_list + acp_spawn) if any ACP agents are configured.
 	if len(p.acpAgents) > 0 {
@@ -705,6 +757,11 @@ func (p *Pool) configureToolRegistry(reg *tools.Registry, ag *Agent, fileSender
 		reg.WithACPAgents(func() []config.ACPAgentEntry { return acpAgents })
 	}
 
+	// Mount tools from the MCP servers this agent opted into.
+	if p.mcpMgr != nil && len(ag.MCPServerIDs) > 0 {
+		reg.WithMCP(p.mcpMgr, ag.MCPServerIDs)
+	}
+
 	// Register Feishu tools if the agent has a Feishu channel configured.
 	for _, ch := range ag.Channels {
 		if ch.Type == "feishu" && ch.Enabled && ch.Config["appId"] != "" && ch.Config["appSecret"] != "" {
@@ -713,6 +770,30 @@ func (p *Pool) configureToolRegistry(reg *tools.Registry, ag *Agent, fileSender
 		}
 	}
 
+	// Register DingTalk / WeCom tools from the first channel of each type.
+	for _, ch := range ag.Channels {
+		if ch.Type == "dingtalk" && ch.Enabled {
+			reg.WithDingTalk(ch.Config["appKey"], ch.Config["appSecret"], ch.Config["robotCode"])
+			break
+		}
+	}
+	for _, ch := range ag.Channels {
+		if ch.Type == "wecom" && ch.Enabled {
+			reg.WithWeCom(ch.Config["corpId"], ch.Config["secret"], ch.Config["agentId"])
+			break
+		}
+	}
+
+	// Register email_send if the agent has an email channel configured.
+	if p.emailSenderFn != nil {
+		for _, ch := range ag.Channels {
+			if ch.Type == "email" && ch.Enabled {
+				reg.WithEmailSender(p.emailSenderFn(ag.ID))
+				break
+			}
+		}
+	}
+
 }
 
 // finalizeToolRegistry applies governance only after every dynamic tool has
@@ -748,6 +829,21 @@ func (p *Pool) resolveEmbedder() (*llm.Embedder, string) {
 	return nil, ""
 }
 
+// SearchEmbedder returns the embedder for semantic session search, or nil
+// (keyword search only) when no provider supports embeddings.
+func (p *Pool) SearchEmbedder() *session.SearchEmbedder {
+	embedder, apiKey := p.resolveEmbedder()
+	if embedder == nil {
+		return nil
+	}
+	return &session.SearchEmbedder{
+		Model: embedder.Model(),
+		Embed: func(ctx context.Context, texts []string) ([][]float32, error) {
+			return embedder.Embed(ctx, apiKey, texts)
+		},
+	}
+}
+
 // buildProjectContext returns the shared project context string for system prompt injection.
 func (p *Pool) buildProjectContext(agentID string) string {
 	if p.projectMgr == nil {
@@ -756,6 +852,15 @@ func (p *Pool) buildProjectContext(agentID string) string {
 	return runner.BuildProjectContext(p.projectMgr, agentID)
 }
 
+// knowledgeRetriever returns the per-turn auto-retrieval hook for agentID,
+// or nil when none of its knowledge bases has AutoRetrieve on.
+func (p *Pool) knowledgeRetriever(agentID string) func(ctx context.Context, query string) string {
+	if p.knowledgeMgr == nil {
+		return nil
+	}
+	return p.knowledgeMgr.Retriever(agentID)
+}
+
 // resolveModel finds the model entry for an agent, falling back to default.
 func (p *Pool) resolveModel(ag *Agent) (*config.ModelEntry, error) {
 	// 系统 config agent 始终跟随当前默认模型，避免创建后模型不更新
@@ -812,7 +917,7 @@ func (p *Pool) ConsolidateMemory(ctx context.Context, agentID string) (string, e
 		FocusHint: memCfg.FocusHint,
 	}
 
-	llmClient := llm.NewClient(modelEntry.Provider, resolvedBaseURL)
+	llmClient := NewModelClient(p.cfg, modelEntry, apiKey, resolvedBaseURL)
 	callLLM := func(ctx context.Context, system, user string) (string, error) {
 		userJSON, _ := json.Marshal(user)
 		req := &llm.ChatRequest{
@@ -875,10 +980,57 @@ func (p *Pool) ConsolidateMemory(ctx context.Context, agentID string) (string, e
 	return "✅ 记忆整理完成", nil
 }
 
-// CallLLMOnce runs a single system+user completion using an agent's default
-// model — no tools, no history. Used by SkillOpt's critic/evolver (mirrors the
-// inline caller in ConsolidateMemory).
-func (p *Pool) CallLLMOnce(ctx context.Context, agentID, system, user string) (string, error) {
+// CallLLMOnceJSON runs a single system+user structured-output completion
+// using an agent's default model — no tools, no history. Used by SkillOpt's
+// critic/evolver. The request carries schema as a json_schema response format
+// at temperature 0, and the reply is extracted, checked against schema and
+// returned as raw JSON. Malformed replies are fed back to the model with the
+// validation error, up to jsonOutputRetries more attempts.
+func (p *Pool) CallLLMOnceJSON(ctx context.Context, agentID, system, user string, schema json.RawMessage) (json.RawMessage, error) {
+	var parsed any
+	if len(schema) > 0 {
+		if err := json.Unmarshal(schema, &parsed); err != nil {
+			return nil, fmt.Errorf("invalid schema: %w", err)
+		}
+	}
+	userJSON, _ := json.Marshal(user)
+	msgs := []llm.ChatMessage{{Role: "user", Content: userJSON}}
+	rf := &llm.ResponseFormat{Type: llm.ResponseFormatJSONObject}
+	if len(schema) > 0 {
+		rf = &llm.ResponseFormat{Type: llm.ResponseFormatJSONSchema, Name: "response", Schema: schema}
+	}
+	var lastErr error
+	for attempt := 0; attempt <= jsonOutputRetries; attempt++ {
+		zero := 0.0
+		reply, err := p.completeOnce(ctx, agentID, &llm.ChatRequest{
+			System:         system,
+			Messages:       msgs,
+			MaxTokens:      2048,
+			ResponseFormat: rf,
+			Temperature:    &zero,
+		})
+		if err != nil {
+			return nil, err
+		}
+		out, verr := parseJSONOutput(reply, parsed)
+		if verr == nil {
+			return out, nil
+		}
+		lastErr = verr
+		log.Printf("[agent] CallLLMOnceJSON agent=%s attempt=%d: %v", agentID, attempt+1, verr)
+		replyJSON, _ := json.Marshal(reply)
+		fixJSON, _ := json.Marshal(fmt.Sprintf("上一次输出不符合要求：%v\n请只输出符合 schema 的 JSON，不要附加任何说明或代码块标记。", verr))
+		msgs = append(msgs,
+			llm.ChatMessage{Role: "assistant", Content: replyJSON},
+			llm.ChatMessage{Role: "user", Content: fixJSON},
+		)
+	}
+	return nil, fmt.Errorf("model did not return valid JSON after %d attempts: %w", jsonOutputRetries+1, lastErr)
+}
+
+// completeOnce streams req against agentID's default model (Model / APIKey
+// filled in here, generation defaults applied) and returns the collected text.
+func (p *Pool) completeOnce(ctx context.Context, agentID string, req *llm.ChatRequest) (string, error) {
 	ag, ok := p.manager.Get(agentID)
 	if !ok {
 		return "", fmt.Errorf("agent %q not found", agentID)
@@ -891,14 +1043,11 @@ func (p *Pool) CallLLMOnce(ctx context.Context, agentID, system, user string) (s
 	if apiKey == "" && llm.RequiresAPIKey(modelEntry.Provider) {
 		return "", fmt.Errorf("no API key for model: %s", modelEntry.ProviderModel())
 	}
-	llmClient := llm.NewClient(modelEntry.Provider, resolvedBaseURL)
-	userJSON, _ := json.Marshal(user)
-	req := &llm.ChatRequest{
-		Model:     modelEntry.ProviderModel(),
-		APIKey:    apiKey,
-		System:    system,
-		Messages:  []llm.ChatMessage{{Role: "user", Content: userJSON}},
-		MaxTokens: 2048,
+	llmClient := NewModelClient(p.cfg, modelEntry, apiKey, resolvedBaseURL)
+	req.Model = modelEntry.ProviderModel()
+	req.APIKey = apiKey
+	if hook := GenerationHook(modelEntry, ag); hook != nil {
+		hook(req)
 	}
 	ch, err := llmClient.Stream(ctx, req)
 	if err != nil {
@@ -940,7 +1089,7 @@ func (p *Pool) Run(ctx context.Context, agentID, message string) (string, error)
 	}
 
 	// Create a fresh runner for this invocation
-	llmClient := llm.NewClient(modelEntry.Provider, resolvedBaseURL)
+	llmClient := NewModelClient(p.cfg, modelEntry, apiKey, resolvedBaseURL)
 	toolRegistry := tools.New(ag.WorkspaceDir, filepath.Dir(ag.WorkspaceDir), ag.ID)
 	p.configureToolRegistry(toolRegistry, ag, nil)
 	toolOwnerSessionID := fmt.Sprintf("run-%d", time.Now().UnixNano())
@@ -958,9 +1107,12 @@ func (p *Pool) Run(ctx context.Context, agentID, message string) (string, error)
 		SupportsTools:       config.ModelSupportsTools(modelEntry),
 		Session:             store,
 		ProjectContext:      p.buildProjectContext(ag.ID),
+		Retrieve:            p.knowledgeRetriever(ag.ID),
 		AgentEnv:            ag.Env,
 		UsageRecorder:       p.usageRecorder(),
 		BudgetCheck:         p.budgetChecker(),
+		PrepareRequest:      GenerationHook(modelEntry, ag),
+		CompactionThreshold: CompactionThreshold(modelEntry),
 		CapabilitiesContext: BuildCapabilitiesContext(toolRegistry, ag, p.cfg, ag.WorkspaceDir),
 		ToolAudit:           toolaudit.New(filepath.Dir(ag.WorkspaceDir)),
 	})
@@ -1002,7 +1154,7 @@ func (p *Pool) RunStreamEvents(ctx context.Context, agentID, message, sessionID
 		return nil, fmt.Errorf("no API key configured for model: %s", model)
 	}
 
-	llmClient := llm.NewClient(modelEntry.Provider, resolvedBaseURL)
+	llmClient := NewModelClient(p.cfg, modelEntry, apiKey, resolvedBaseURL)
 	toolRegistry := tools.New(ag.WorkspaceDir, filepath.Dir(ag.WorkspaceDir), ag.ID)
 	p.configureToolRegistry(toolRegistry, ag, fileSender)
 	p.finalizeToolRegistry(toolRegistry, ag, sessionID)
@@ -1047,9 +1199,12 @@ func (p *Pool) RunStreamEvents(ctx context.Context, agentID, message, sessionID
 		SessionID:             sessionID,
 		Images:                images,
 		ProjectContext:        p.buildProjectContext(ag.ID),
+		Retrieve:              p.knowledgeRetriever(ag.ID),
 		AgentEnv:              ag.Env,
 		UsageRecorder:         p.usageRecorder(),
 		BudgetCheck:           p.budgetChecker(),
+		PrepareRequest:        GenerationHook(modelEntry, ag),
+		CompactionThreshold:   CompactionThreshold(modelEntry),
 		CapabilitiesContext:   BuildCapabilitiesContext(toolRegistry, ag, p.cfg, ag.WorkspaceDir),
 		CurrentSessionContext: BuildSessionContext(store, sessionID),
 		ExtraContext:          strings.Join(extraSystemContext, "\n"),
@@ -1064,6 +1219,12 @@ func (p *Pool) RunStreamEvents(ctx context.Context, agentID, message, sessionID
 			switch ev.Type {
 			case "text_delta":
 				out <- channel.StreamEvent{Type: "text_delta", Text: ev.Text}
+			case "tool_call":
+				if ev.ToolCall != nil {
+					out <- channel.StreamEvent{Type: "tool_call", ToolCallID: ev.ToolCall.ID, ToolName: ev.ToolCall.Name, ToolInput: string(ev.ToolCall.Input)}
+				}
+			case "tool_result":
+				out <- channel.StreamEvent{Type: "tool_result", Text: ev.Text, ToolCallID: ev.ToolCallID}
 			case "error":
 				if ev.Error != nil {
 					out <- channel.StreamEvent{Type: "error", Err: ev.Error}
@@ -1093,7 +1254,7 @@ func (p *Pool) RunStream(ctx context.Context, agentID, message, sessionID string
 		return nil, fmt.Errorf("no API key configured for model: %s", model)
 	}
 
-	llmClient := llm.NewClient(modelEntry.Provider, resolvedBaseURL)
+	llmClient := NewModelClient(p.cfg, modelEntry, apiKey, resolvedBaseURL)
 	toolRegistry := tools.New(ag.WorkspaceDir, filepath.Dir(ag.WorkspaceDir), ag.ID)
 	p.configureToolRegistry(toolRegistry, ag, nil)
 	p.finalizeToolRegistry(toolRegistry, ag, sessionID)
@@ -1111,9 +1272,12 @@ func (p *Pool) RunStream(ctx context.Context, agentID, message, sessionID string
 		Session:               store,
 		SessionID:             sessionID,
 		ProjectContext:        p.buildProjectContext(ag.ID),
+		Retrieve:              p.knowledgeRetriever(ag.ID),
 		AgentEnv:              ag.Env,
 		UsageRecorder:         p.usageRecorder(),
 		BudgetCheck:           p.budgetChecker(),
+		PrepareRequest:        GenerationHook(modelEntry, ag),
+		CompactionThreshold:   CompactionThreshold(modelEntry),
 		CapabilitiesContext:   BuildCapabilitiesContext(toolRegistry, ag, p.cfg, ag.WorkspaceDir),
 		CurrentSessionContext: BuildSessionContext(store, sessionID),
 		ToolAudit:             toolaudit.New(filepath.Dir(ag.WorkspaceDir)),
@@ -1122,6 +1286,213 @@ func (p *Pool) RunStream(ctx context.Context, agentID, message, sessionID string
 	return r.Run(ctx, message), nil
 }
 
+// ReplayOpts configures Pool.Replay.
+type ReplayOpts struct {
+	// ModelID selects a cfg.Models entry; "" = the agent's own model.
+	ModelID string
+	// SessionID keys the replay session: turns sharing it (and Sandbox)
+	// see each other's history.
+	SessionID string
+	// Sandbox is a scratch directory the replay runs in. The agent's
+	// workspace is copied to Sandbox/workspace on first use, and the
+	// session and tool audit are written under Sandbox too, so tools never
+	// touch the live agent. Turns sharing a Sandbox share the copy; the
+	// caller removes it. "" = a one-off sandbox removed after the turn.
+	Sandbox string
+	// LLM replaces the model client (e.g. a scripted fake for offline
+	// runs). No API key is needed and usage / budget are not touched.
+	LLM llm.Client
+}
+
+// ReplayResult is the outcome of one replayed turn.
+type ReplayResult struct {
+	Text         string         `json:"text"`
+	ToolCalls    []llm.ToolCall `json:"toolCalls,omitempty"`
+	InputTokens  int            `json:"inputTokens"`
+	OutputTokens int            `json:"outputTokens"`
+	Model        string         `json:"model"` // provider/model that answered
+}
+
+// Replay runs one user turn against agentID in an isolated session — the
+// eval harness entry point. Tools run for real, but against a copy of the
+// workspace in opts.Sandbox, and are written to the sandbox's tool audit
+// under opts.SessionID so graders can assert on them. Tools that would act
+// outside the sandbox are stubbed (see stubReplayTools); their calls are
+// still audited.
+func (p *Pool) Replay(ctx context.Context, agentID, message string, opts ReplayOpts) (ReplayResult, error) {
+	var res ReplayResult
+	ag, ok := p.manager.Get(agentID)
+	if !ok {
+		return res, fmt.Errorf("agent %q not found", agentID)
+	}
+	var modelEntry *config.ModelEntry
+	if opts.ModelID != "" {
+		if modelEntry = p.cfg.FindModel(opts.ModelID); modelEntry == nil && opts.LLM == nil {
+			return res, fmt.Errorf("model %q not found", opts.ModelID)
+		}
+	} else if m, err := p.resolveModel(ag); err == nil {
+		modelEntry = m
+	} else if opts.LLM == nil {
+		return res, err
+	}
+	if modelEntry == nil {
+		// Offline run with no matching model configured.
+		modelEntry = &config.ModelEntry{ID: opts.ModelID, Provider: "scripted", Model: "scripted"}
+	}
+	res.Model = modelEntry.ProviderModel()
+
+	llmClient := opts.LLM
+	var apiKey string
+	usageRecorder, budgetCheck := p.usageRecorder(), p.budgetChecker()
+	if llmClient == nil {
+		var baseURL string
+		apiKey, baseURL = config.ResolveCredentials(modelEntry, p.cfg.Providers)
+		if apiKey == "" && llm.RequiresAPIKey(modelEntry.Provider) {
+			return res, fmt.Errorf("no API key configured for model: %s", res.Model)
+		}
+		llmClient = NewModelClient(p.cfg, modelEntry, apiKey, baseURL)
+	} else {
+		usageRecorder, budgetCheck = nil, nil
+	}
+
+	sandbox := opts.Sandbox
+	if sandbox == "" {
+		dir, err := os.MkdirTemp("", "zyhive-replay-")
+		if err != nil {
+			return res, fmt.Errorf("create replay sandbox: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		sandbox = dir
+	}
+	workspace, err := replayWorkspace(sandbox, ag.WorkspaceDir)
+	if err != nil {
+		return res, err
+	}
+	// Everything below sees the copy, never the live workspace.
+	sb := *ag
+	sb.WorkspaceDir = workspace
+	ag = &sb
+
+	store := session.NewStore(filepath.Join(sandbox, "sessions"))
+	if opts.SessionID != "" {
+		if _, _, err := store.GetOrCreate(opts.SessionID, agentID); err != nil {
+			return res, err
+		}
+	}
+	toolRegistry := tools.New(ag.WorkspaceDir, sandbox, ag.ID)
+	p.configureToolRegistry(toolRegistry, ag, nil)
+	stubReplayTools(toolRegistry)
+	p.finalizeToolRegistry(toolRegistry, ag, opts.SessionID)
+
+	r := runner.New(runner.Config{
+		AgentID:             ag.ID,
+		WorkspaceDir:        ag.WorkspaceDir,
+		Model:               res.Model,
+		APIKey:              apiKey,
+		Provider:            modelEntry.Provider,
+		LLM:                 llmClient,
+		Tools:               toolRegistry,
+		SupportsTools:       config.ModelSupportsTools(modelEntry),
+		Session:             store,
+		SessionID:           opts.SessionID,
+		ProjectContext:      p.buildProjectContext(ag.ID),
+		Retrieve:            p.knowledgeRetriever(ag.ID),
+		AgentEnv:            ag.Env,
+		UsageRecorder:       usageRecorder,
+		BudgetCheck:         budgetCheck,
+		PrepareRequest:      GenerationHook(modelEntry, ag),
+		CompactionThreshold: CompactionThreshold(modelEntry),
+		CapabilitiesContext: BuildCapabilitiesContext(toolRegistry, ag, p.cfg, ag.WorkspaceDir),
+		ToolAudit:           toolaudit.New(sandbox),
+	})
+
+	var text strings.Builder
+	for ev := range r.Run(ctx, message) {
+		switch ev.Type {
+		case "text_delta":
+			text.WriteString(ev.Text)
+		case "tool_call":
+			if ev.ToolCall != nil {
+				res.ToolCalls = append(res.ToolCalls, *ev.ToolCall)
+			}
+		case "model_switch":
+			if ev.ModelSwitch != nil {
+				res.Model = ev.ModelSwitch.ToModel
+			}
+		case "done":
+			res.InputTokens, res.OutputTokens = ev.InputTokens, ev.OutputTokens
+		case "error":
+			if ev.Error != nil {
+				res.Text = text.String()
+				return res, ev.Error
+			}
+		}
+	}
+	res.Text = text.String()
+	return res, nil
+}
+
+// replayStubbed names the tools whose effects escape a replay sandbox:
+// outbound messages, the live scheduler and sessions, the agent's persisted
+// config, shared projects and background agents.
+var replayStubbed = map[string]bool{
+	"send_message":    true,
+	"send_file":       true,
+	"email_send":      true,
+	"cron_add":        true,
+	"cron_remove":     true,
+	"self_schedule":   true,
+	"sessions_send":   true,
+	"session_rename":  true,
+	"self_set_env":    true,
+	"self_delete_env": true,
+	"project_create":  true,
+	"project_write":   true,
+	"agent_spawn":     true,
+	"agent_kill":      true,
+	"acp_spawn":       true,
+}
+
+// replayStubbedPrefixes covers tool families that talk to external
+// services: IM platform APIs, the shared browser and MCP servers.
+var replayStubbedPrefixes = []string{"feishu_", "dingtalk_", "wecom_", "browser_", "mcp__"}
+
+// stubReplayTools swaps the handlers of side-effecting tools for one that
+// does nothing. The definitions stay, so the model behaves as in production
+// and graders can still assert the call through the tool audit.
+func stubReplayTools(reg *tools.Registry) {
+	for _, def := range reg.Definitions() {
+		name := def.Name
+		stub := replayStubbed[name]
+		for _, prefix := range replayStubbedPrefixes {
+			stub = stub || strings.HasPrefix(name, prefix)
+		}
+		if !stub {
+			continue
+		}
+		reg.Stub(name, func(context.Context, json.RawMessage) (string, error) {
+			return fmt.Sprintf("（评测回放：%s 未实际执行）", name), nil
+		})
+	}
+}
+
+// replayWorkspace returns sandbox/workspace, seeding it with a copy of the
+// live workspace on first use.
+func replayWorkspace(sandbox, live string) (string, error) {
+	dst := filepath.Join(sandbox, "workspace")
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+	if _, err := os.Stat(live); os.IsNotExist(err) {
+		return dst, os.MkdirAll(dst, 0o755)
+	}
+	if err := os.CopyFS(dst, os.DirFS(live)); err != nil {
+		os.RemoveAll(dst)
+		return "", fmt.Errorf("copy workspace into replay sandbox: %w", err)
+	}
+	return dst, nil
+}
+
 // normalizeVisionContentType maps raw Content-Type values (from Telegram CDN or elsewhere)
 // to the set accepted by Anthropic Vision: image/jpeg, image/png, image/gif, image/webp,
 // or application/pdf. Returns "" for unsupported types.
@@ -1202,7 +1573,7 @@ func (p *Pool) subagentRunFuncExt() subagent.RunFuncExt {
 			}
 
 			supportsTools := config.ModelSupportsTools(modelEntry)
-			llmClient := llm.NewClient(modelEntry.Provider, resolvedBaseURL)
+			llmClient := NewModelClient(p.cfg, modelEntry, apiKey, resolvedBaseURL)
 			subSessionDir := filepath.Join(ag.SessionDir, "subagent")
 			if err := os.MkdirAll(subSessionDir, 0755); err != nil {
 				out <- subagent.RunEvent{Type: "error", Error: fmt.Errorf("create subagent session dir: %w", err)}
@@ -1269,9 +1640,12 @@ func (p *Pool) subagentRunFuncExt() subagent.RunFuncExt {
 				SupportsTools:         supportsTools,
 				Session:               store,
 				ProjectContext:        p.buildProjectContext(ag.ID),
+				Retrieve:              p.knowledgeRetriever(ag.ID),
 				AgentEnv:              ag.Env,
 				UsageRecorder:         p.usageRecorder(),
 				BudgetCheck:           p.budgetChecker(),
+				PrepareRequest:        GenerationHook(modelEntry, ag),
+				CompactionThreshold:   CompactionThreshold(modelEntry),
 				CapabilitiesContext:   BuildCapabilitiesContext(toolRegistry, ag, p.cfg, ag.WorkspaceDir),
 				CurrentSessionContext: BuildSessionContext(store, task.SessionID),
 				ToolAudit:             toolaudit.New(filepath.Dir(ag.WorkspaceDir)),
@@ -1320,7 +1694,7 @@ func (p *Pool) SubagentRunFunc() subagent.RunFunc {
 			}
 
 			supportsToolsLegacy := config.ModelSupportsTools(modelEntry)
-			llmClient := llm.NewClient(modelEntry.Provider, resolvedBaseURL)
+			llmClient := NewModelClient(p.cfg, modelEntry, apiKey, resolvedBaseURL)
 			// Subagent gets its own isolated session store (separate dir)
 			subSessionDir := filepath.Join(ag.SessionDir, "subagent")
 			if err := os.MkdirAll(subSessionDir, 0755); err != nil {
@@ -1357,9 +1731,12 @@ func (p *Pool) SubagentRunFunc() subagent.RunFunc {
 				SupportsTools:         supportsToolsLegacy,
 				Session:               store,
 				ProjectContext:        p.buildProjectContext(ag.ID),
+				Retrieve:              p.knowledgeRetriever(ag.ID),
 				AgentEnv:              ag.Env,
 				UsageRecorder:         p.usageRecorder(),
 				BudgetCheck:           p.budgetChecker(),
+				PrepareRequest:        GenerationHook(modelEntry, ag),
+				CompactionThreshold:   CompactionThreshold(modelEntry),
 				CapabilitiesContext:   BuildCapabilitiesContext(toolRegistry, ag, p.cfg, ag.WorkspaceDir),
 				CurrentSessionContext: BuildSessionContext(store, sessionID),
 				ToolAudit:             toolaudit.New(filepath.Dir(ag.WorkspaceDir)),
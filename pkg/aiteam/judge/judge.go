@@ -75,6 +75,9 @@ type Signals struct {
 	CallCount    int     // number of LLM calls
 	ErrorCount   int     // recorded errors (currently always 0; reserved)
 	Notes        string  // free-form (e.g. owner thumbs-down)
+	// Rubric is optional operator-written grading criteria (eval harness).
+	// Unlike Notes it is trusted and placed outside the untrusted wrapper.
+	Rubric string
 }
 
 // Scorer is the pluggable evaluator interface. Implementations should
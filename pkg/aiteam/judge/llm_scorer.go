@@ -110,6 +110,11 @@ func llmJudgeUserPrompt(sig Signals, wrappedTranscript string) string {
 	if sig.ErrorCount > 0 {
 		fmt.Fprintf(&b, "Recorded errors: %d.\n", sig.ErrorCount)
 	}
+	if sig.Rubric != "" {
+		b.WriteString("\nGrading rubric (from the operator; score completion and quality against it):\n")
+		b.WriteString(sig.Rubric)
+		b.WriteString("\n")
+	}
 	b.WriteString("\nTranscript / work product:\n")
 	b.WriteString(wrappedTranscript)
 	b.WriteString("\n\nRespond with the single JSON object now.")
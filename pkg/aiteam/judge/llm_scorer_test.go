@@ -155,3 +155,18 @@ func Test_AITeam_LLMJudge_SystemPromptMentionsDefence(t *testing.T) {
 		t.Error("system prompt must specify JSON output")
 	}
 }
+
+func Test_AITeam_LLMJudge_RubricOutsideEnvelope(t *testing.T) {
+	var capturedUser string
+	scorer := newLLMScorer(func(_, user string) (string, error) {
+		capturedUser = user
+		return `{"completion":8,"quality":8,"communication":8,"creativity":8,"cost":8,"rationale":"ok"}`, nil
+	})
+	scorer.Score(Signals{AgentID: "alice", Notes: "reply", Rubric: "must cite the order id"})
+
+	rubricAt := strings.Index(capturedUser, "must cite the order id")
+	envelopeAt := strings.Index(capturedUser, "<untrusted_external_content")
+	if rubricAt < 0 || envelopeAt < 0 || rubricAt > envelopeAt {
+		t.Fatalf("rubric should precede the transcript envelope; got: %s", capturedUser)
+	}
+}
@@ -25,22 +25,18 @@
 package revenue
 
 import (
-	"crypto/hmac"
-	"crypto/sha256"
-	"crypto/subtle"
-	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
-	"sync"
 	"time"
 
 	"github.com/shopspring/decimal"
 
 	"github.com/Zyling-ai/zyhive/pkg/aiteam/audit"
+	"github.com/Zyling-ai/zyhive/pkg/hooksig"
 )
 
 // IncomingPayload is the canonical JSON body the market POSTs to us.
@@ -83,9 +79,7 @@ type Ingester struct {
 	wallet   WalletCredit
 	audit    *audit.Log
 
-	mu          sync.Mutex
-	seenNonces  map[string]time.Time
-	nonceOrder  []string // FIFO for cache eviction
+	nonces   *hooksig.NonceCache
 }
 
 // New constructs an Ingester. dir is created (0o700) if missing.
@@ -111,7 +105,7 @@ func New(dir string, cfg Config, wallet WalletCredit, log *audit.Log) (*Ingester
 		dir:        dir,
 		wallet:     wallet,
 		audit:      log,
-		seenNonces: map[string]time.Time{},
+		nonces:     hooksig.NewNonceCache(cfg.NonceCacheSize),
 	}, nil
 }
 
@@ -158,10 +152,7 @@ func (i *Ingester) Accept(rawBody []byte, signature string) (*Result, error) {
 		return nil, fmt.Errorf("revenue: nil ingester")
 	}
 	// 1. HMAC verify.
-	mac := hmac.New(sha256.New, i.cfg.Secret)
-	mac.Write(rawBody)
-	want := hex.EncodeToString(mac.Sum(nil))
-	if subtle.ConstantTimeCompare([]byte(signature), []byte(want)) != 1 {
+	if !hooksig.Verify(i.cfg.Secret, rawBody, signature) {
 		return &Result{Reason: ErrBadSignature.Error()}, ErrBadSignature
 	}
 
@@ -172,8 +163,8 @@ func (i *Ingester) Accept(rawBody []byte, signature string) (*Result, error) {
 	}
 
 	// 3. Freshness.
-	now := time.Now().Unix()
-	if abs(now-p.Timestamp) > int64(i.cfg.FreshnessWindow.Seconds()) {
+	if !hooksig.Fresh(p.Timestamp, i.cfg.FreshnessWindow) {
+		now := time.Now().Unix()
 		return &Result{Reason: ErrStaleTimestamp.Error() + " (now=" + strconv.FormatInt(now, 10) + ", ts=" + strconv.FormatInt(p.Timestamp, 10) + ")"}, ErrStaleTimestamp
 	}
 
@@ -181,7 +172,7 @@ func (i *Ingester) Accept(rawBody []byte, signature string) (*Result, error) {
 	if p.Nonce == "" {
 		return &Result{Reason: "missing nonce"}, fmt.Errorf("revenue: missing nonce")
 	}
-	if err := i.recordNonce(p.Nonce); err != nil {
+	if !i.nonces.Record(p.Nonce) {
 		return &Result{Reason: ErrReplayedNonce.Error()}, ErrReplayedNonce
 	}
 
@@ -270,24 +261,6 @@ func (i *Ingester) Accept(rawBody []byte, signature string) (*Result, error) {
 	}, nil
 }
 
-// recordNonce returns an error if the nonce was already seen, otherwise
-// inserts it (with simple FIFO eviction to bound memory).
-func (i *Ingester) recordNonce(nonce string) error {
-	i.mu.Lock()
-	defer i.mu.Unlock()
-	if _, ok := i.seenNonces[nonce]; ok {
-		return ErrReplayedNonce
-	}
-	i.seenNonces[nonce] = time.Now()
-	i.nonceOrder = append(i.nonceOrder, nonce)
-	for len(i.nonceOrder) > i.cfg.NonceCacheSize {
-		evict := i.nonceOrder[0]
-		i.nonceOrder = i.nonceOrder[1:]
-		delete(i.seenNonces, evict)
-	}
-	return nil
-}
-
 // persist writes one row to <dir>/<period>.jsonl using the current UTC
 // date as period. Append-only.
 func (i *Ingester) persist(p *IncomingPayload, total decimal.Decimal, shares []ShareResult) error {
@@ -322,14 +295,5 @@ func (i *Ingester) persist(p *IncomingPayload, total decimal.Decimal, shares []S
 // testing; the production market generates its own signatures with
 // the shared secret.
 func SignFor(secret, rawBody []byte) string {
-	mac := hmac.New(sha256.New, secret)
-	mac.Write(rawBody)
-	return hex.EncodeToString(mac.Sum(nil))
-}
-
-func abs(x int64) int64 {
-	if x < 0 {
-		return -x
-	}
-	return x
+	return hooksig.Sign(secret, rawBody)
 }
@@ -1,5 +1,7 @@
 // Package artifact issues short-lived, one-time download credentials for
-// files that have already passed a caller's workspace policy.
+// files that have already passed a caller's workspace policy, and longer
+// lived read-only share credentials that may be opened repeatedly until
+// they expire.
 package artifact
 
 import (
@@ -7,6 +9,7 @@ import (
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
@@ -15,11 +18,16 @@ import (
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/persist"
 )
 
 const (
 	DefaultTicketTTL = 10 * time.Minute
 	MaxTicketTTL     = time.Hour
+
+	DefaultShareTTL = 24 * time.Hour
+	MaxShareTTL     = 7 * 24 * time.Hour
 )
 
 var (
@@ -31,14 +39,25 @@ type ticket struct {
 	path      string
 	tokenHash [sha256.Size]byte
 	expiresAt time.Time
+	share     bool // opened with Open, never consumed
 }
 
 // TicketStore keeps only token hashes and canonical file paths in memory.
-// Tickets are consumed exactly once and disappear after expiration.
+// Download tickets are consumed exactly once; share tickets can be opened
+// until they expire. Both disappear after expiration or a process restart,
+// unless PersistShares keeps the share tickets on disk.
 type TicketStore struct {
-	mu      sync.Mutex
-	tickets map[string]ticket
-	now     func() time.Time
+	mu         sync.Mutex
+	tickets    map[string]ticket
+	now        func() time.Time
+	sharesPath string // "" = share tickets are memory-only
+}
+
+// shareRecord is the on-disk form of a share ticket.
+type shareRecord struct {
+	Path      string    `json:"path"`
+	TokenHash string    `json:"tokenHash"` // hex SHA-256 of the token
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
 func NewTicketStore() *TicketStore {
@@ -52,23 +71,99 @@ func NewTicketStore() *TicketStore {
 // self-hosted runtime.
 var DefaultTickets = NewTicketStore()
 
+// PersistShares keeps share tickets in path so links outlive a restart: the
+// live tickets saved there are loaded now, and the file is rewritten on
+// every share issue and revoke. Only token hashes are written; download
+// tickets stay in memory.
+func (s *TicketStore) PersistShares(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sharesPath = path
+	if len(data) == 0 {
+		return nil
+	}
+	var saved map[string]shareRecord
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return fmt.Errorf("artifact: parse %s: %w", path, err)
+	}
+	now := s.now()
+	for artifactID, r := range saved {
+		hash, err := hex.DecodeString(r.TokenHash)
+		if err != nil || len(hash) != sha256.Size || !r.ExpiresAt.After(now) {
+			continue
+		}
+		entry := ticket{path: r.Path, expiresAt: r.ExpiresAt, share: true}
+		copy(entry.tokenHash[:], hash)
+		s.tickets[artifactID] = entry
+	}
+	return nil
+}
+
+// saveSharesLocked writes the live share tickets to sharesPath.
+func (s *TicketStore) saveSharesLocked() error {
+	if s.sharesPath == "" {
+		return nil
+	}
+	saved := make(map[string]shareRecord)
+	for artifactID, entry := range s.tickets {
+		if entry.share {
+			saved[artifactID] = shareRecord{
+				Path:      entry.path,
+				TokenHash: hex.EncodeToString(entry.tokenHash[:]),
+				ExpiresAt: entry.expiresAt,
+			}
+		}
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.sharesPath), 0o700); err != nil {
+		return err
+	}
+	return persist.AtomicWrite(s.sharesPath, data, 0o600)
+}
+
 // Issue registers a regular file and returns an opaque artifact ID, a
 // one-time credential, and its expiration time.
 func (s *TicketStore) Issue(path string, ttl time.Duration) (string, string, time.Time, error) {
 	if s == nil {
 		return "", "", time.Time{}, errors.New("artifact: nil ticket store")
 	}
-	canonical, err := canonicalRegularFile(path)
-	if err != nil {
-		return "", "", time.Time{}, err
-	}
 	if ttl <= 0 {
 		ttl = DefaultTicketTTL
 	}
 	if ttl > MaxTicketTTL {
 		ttl = MaxTicketTTL
 	}
+	return s.issue(path, ttl, false)
+}
+
+// IssueShare registers a regular file for read-only sharing. Unlike Issue
+// the credential survives being opened; it is valid until expiration
+// (default DefaultShareTTL, at most MaxShareTTL) or Revoke.
+func (s *TicketStore) IssueShare(path string, ttl time.Duration) (string, string, time.Time, error) {
+	if s == nil {
+		return "", "", time.Time{}, errors.New("artifact: nil ticket store")
+	}
+	if ttl <= 0 {
+		ttl = DefaultShareTTL
+	}
+	if ttl > MaxShareTTL {
+		ttl = MaxShareTTL
+	}
+	return s.issue(path, ttl, true)
+}
 
+func (s *TicketStore) issue(path string, ttl time.Duration, share bool) (string, string, time.Time, error) {
+	canonical, err := canonicalRegularFile(path)
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
 	artifactID, err := randomHex(16)
 	if err != nil {
 		return "", "", time.Time{}, err
@@ -86,6 +181,13 @@ func (s *TicketStore) Issue(path string, ttl time.Duration) (string, string, tim
 		path:      canonical,
 		tokenHash: sha256.Sum256([]byte(token)),
 		expiresAt: expiresAt,
+		share:     share,
+	}
+	if share {
+		if err := s.saveSharesLocked(); err != nil {
+			delete(s.tickets, artifactID)
+			return "", "", time.Time{}, fmt.Errorf("artifact: save share tickets: %w", err)
+		}
 	}
 	return artifactID, token, expiresAt, nil
 }
@@ -121,7 +223,7 @@ func (s *TicketStore) Consume(artifactID, token string) (string, error) {
 	defer s.mu.Unlock()
 
 	entry, ok := s.tickets[artifactID]
-	if !ok {
+	if !ok || entry.share {
 		return "", ErrInvalidTicket
 	}
 	now := s.now()
@@ -137,6 +239,60 @@ func (s *TicketStore) Consume(artifactID, token string) (string, error) {
 	return entry.path, nil
 }
 
+// Open validates a share ticket without consuming it. Download tickets are
+// rejected so a one-time credential cannot be replayed through a share route.
+func (s *TicketStore) Open(artifactID, token string) (string, error) {
+	if s == nil || artifactID == "" || token == "" {
+		return "", ErrInvalidTicket
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.tickets[artifactID]
+	if !ok || !entry.share {
+		return "", ErrInvalidTicket
+	}
+	if !entry.expiresAt.After(s.now()) {
+		delete(s.tickets, artifactID)
+		return "", ErrExpiredTicket
+	}
+	actualHash := sha256.Sum256([]byte(token))
+	if subtle.ConstantTimeCompare(actualHash[:], entry.tokenHash[:]) != 1 {
+		return "", ErrInvalidTicket
+	}
+	return entry.path, nil
+}
+
+// SharePath returns the file a live share ticket points at.
+func (s *TicketStore) SharePath(artifactID string) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entry, ok := s.tickets[artifactID]
+	if !ok || !entry.share || !entry.expiresAt.After(s.now()) {
+		return "", false
+	}
+	return entry.path, true
+}
+
+// Revoke drops a share ticket before it expires.
+func (s *TicketStore) Revoke(artifactID string) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.tickets[artifactID]; ok && entry.share {
+		delete(s.tickets, artifactID)
+		if err := s.saveSharesLocked(); err != nil {
+			return fmt.Errorf("artifact: save share tickets: %w", err)
+		}
+	}
+	return nil
+}
+
 func (s *TicketStore) cleanupLocked(now time.Time) {
 	for artifactID, entry := range s.tickets {
 		if !entry.expiresAt.After(now) {
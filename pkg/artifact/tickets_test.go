@@ -106,3 +106,78 @@ func TestIssueRejectsDirectories(t *testing.T) {
 		t.Fatal("directory should not be registered as an artifact")
 	}
 }
+
+func TestShareTicketOpensUntilExpiry(t *testing.T) {
+	store := NewTicketStore()
+	now := time.Date(2026, 7, 31, 8, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	artifactID, token, expiresAt, err := store.IssueShare(testArtifactFile(t), 30*24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !expiresAt.Equal(now.Add(MaxShareTTL)) {
+		t.Fatalf("expiresAt=%v, want capped at MaxShareTTL", expiresAt)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := store.Open(artifactID, token); err != nil {
+			t.Fatalf("open #%d: %v", i+1, err)
+		}
+	}
+	if _, err := store.Consume(artifactID, token); !errors.Is(err, ErrInvalidTicket) {
+		t.Fatalf("share ticket consumed as download: %v", err)
+	}
+	now = now.Add(MaxShareTTL + time.Second)
+	if _, err := store.Open(artifactID, token); !errors.Is(err, ErrExpiredTicket) {
+		t.Fatalf("expired share should fail, got %v", err)
+	}
+}
+
+func TestOpenRejectsDownloadTicketAndRevoked(t *testing.T) {
+	store := NewTicketStore()
+	artifactID, token, _, err := store.Issue(testArtifactFile(t), time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Open(artifactID, token); !errors.Is(err, ErrInvalidTicket) {
+		t.Fatalf("download ticket opened as share: %v", err)
+	}
+	shareID, shareToken, _, err := store.IssueShare(testArtifactFile(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Revoke(shareID)
+	if _, err := store.Open(shareID, shareToken); !errors.Is(err, ErrInvalidTicket) {
+		t.Fatalf("revoked share should fail, got %v", err)
+	}
+}
+
+func TestPersistedSharesSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shares.json")
+	store := NewTicketStore()
+	if err := store.PersistShares(path); err != nil {
+		t.Fatal(err)
+	}
+	keptID, keptToken, _, err := store.IssueShare(testArtifactFile(t), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedID, revokedToken, _, _ := store.IssueShare(testArtifactFile(t), 0)
+	if err := store.Revoke(revokedID); err != nil {
+		t.Fatal(err)
+	}
+	downloadID, downloadToken, _, _ := store.Issue(testArtifactFile(t), time.Minute)
+
+	restarted := NewTicketStore()
+	if err := restarted.PersistShares(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.Open(keptID, keptToken); err != nil {
+		t.Errorf("share lost on restart: %v", err)
+	}
+	if _, err := restarted.Open(revokedID, revokedToken); err == nil {
+		t.Error("revoked share came back")
+	}
+	if _, err := restarted.Consume(downloadID, downloadToken); err == nil {
+		t.Error("download ticket persisted")
+	}
+}
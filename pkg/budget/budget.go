@@ -84,6 +84,14 @@ func (s *Store) todayKey() string {
 	return time.Now().In(s.tz).Format("2006-01-02")
 }
 
+// DayStart returns the start of the current budget day in the store's TZ.
+// Callers seeding Charge from persisted usage (e.g. a second process such as
+// `aipanel mcp`) use it as the lower bound of the replay window.
+func (s *Store) DayStart() time.Time {
+	now := time.Now().In(s.tz)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.tz)
+}
+
 // rotateIfNeededLocked clears day-scoped state when the date has rolled.
 // Caller must hold s.mu.
 func (s *Store) rotateIfNeededLocked() {
@@ -143,6 +151,27 @@ func (s *Store) Charge(agentID string, costUSD float64) {
 	s.global += costUSD
 }
 
+// Reseed replaces today's spend with byAgent (agent_id → USD), e.g. after
+// `aipanel usage reprice` rewrote historical costs. Limits and today's
+// topups are kept.
+func (s *Store) Reseed(byAgent map[string]float64) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rotateIfNeededLocked()
+	s.agents = map[string]float64{}
+	s.global = 0
+	for id, usd := range byAgent {
+		if usd <= 0 {
+			continue
+		}
+		s.agents[id] = usd
+		s.global += usd
+	}
+}
+
 // Topup adds emergency credit for the current day. Pass agentID="" for a
 // global topup. The credit lapses at next day rollover.
 func (s *Store) Topup(agentID string, addUSD float64) {
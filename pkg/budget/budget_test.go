@@ -184,3 +184,23 @@ func TestRotateClearsTopupOnDayChange(t *testing.T) {
 		t.Fatalf("after rollover Topup=%v want 0", got)
 	}
 }
+
+// TestReseed_ReplacesSpendKeepsTopup — Reseed swaps today's totals for the
+// re-priced ones without dropping emergency credit.
+func TestReseed_ReplacesSpendKeepsTopup(t *testing.T) {
+	s := NewStore(Config{Enabled: true, DefaultAgentDailyUSD: 1.0})
+	s.Charge("alice", 2.0)
+	s.Topup("alice", 0.5)
+	s.Reseed(map[string]float64{"alice": 0.4, "bob": 0.1})
+
+	snap := s.SnapshotFor([]string{"alice"})
+	if got := snap.GlobalUsed; got < 0.499 || got > 0.501 {
+		t.Fatalf("GlobalUsed = %v, want ~0.5", got)
+	}
+	if len(snap.Agents) != 1 || snap.Agents[0].Used != 0.4 || snap.Agents[0].Topup != 0.5 {
+		t.Fatalf("alice = %+v", snap.Agents)
+	}
+	if res := s.BeforeRun("alice"); !res.Allowed {
+		t.Fatalf("alice should be under cap after reseed, got %+v", res)
+	}
+}
@@ -1,30 +1,25 @@
-// Package channel — BotPool manages the lifecycle of running TelegramBot instances.
-// Supports hot-add, hot-update, and hot-remove of Telegram channels without restarting
-// the entire process.
+// Package channel — BotPool manages the lifecycle of running channel drivers.
+// Supports hot-add, hot-update, and hot-remove of any registered channel type
+// without restarting the entire process.
 package channel
 
 import (
 	"context"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 )
 
-// BotPool tracks one running *TelegramBot per (agentID, channelID).
-// Also manages FeishuBot instances in a parallel map.
+// BotPool tracks one running Driver per (agentID, channelID).
 type BotPool struct {
 	mu      sync.Mutex
 	bots    map[string]*botEntry
-	feishu  map[string]*feishuEntry
 	rootCtx context.Context
 }
 
 type botEntry struct {
-	bot    *TelegramBot
-	cancel context.CancelFunc
-}
-
-type feishuEntry struct {
-	bot    *FeishuBot
+	driver Driver
 	cancel context.CancelFunc
 }
 
@@ -32,48 +27,17 @@ type feishuEntry struct {
 func NewBotPool(ctx context.Context) *BotPool {
 	return &BotPool{
 		bots:    make(map[string]*botEntry),
-		feishu:  make(map[string]*feishuEntry),
 		rootCtx: ctx,
 	}
 }
 
-// StartFeishuBot starts (or restarts) a FeishuBot for the given (agentID, channelID).
-func (p *BotPool) StartFeishuBot(agentID, channelID string, bot *FeishuBot) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	k := poolKey(agentID, channelID)
-	if e, ok := p.feishu[k]; ok {
-		e.cancel()
-		delete(p.feishu, k)
-		log.Printf("[botpool] stopped old feishu bot agent=%s channel=%s", agentID, channelID)
-	}
-
-	ctx, cancel := context.WithCancel(p.rootCtx)
-	p.feishu[k] = &feishuEntry{bot: bot, cancel: cancel}
-	go bot.Start(ctx)
-	log.Printf("[botpool] started feishu bot agent=%s channel=%s", agentID, channelID)
-}
-
-// StopFeishuBot stops a FeishuBot.
-func (p *BotPool) StopFeishuBot(agentID, channelID string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	k := poolKey(agentID, channelID)
-	if e, ok := p.feishu[k]; ok {
-		e.cancel()
-		delete(p.feishu, k)
-		log.Printf("[botpool] stopped feishu bot agent=%s channel=%s", agentID, channelID)
-	}
-}
-
 func poolKey(agentID, channelID string) string {
 	return agentID + "/" + channelID
 }
 
-// StartBot starts (or restarts) a bot for the given (agentID, channelID).
+// Start starts (or restarts) a driver for the given (agentID, channelID).
 // Safe to call if already running — stops the old instance first.
-func (p *BotPool) StartBot(agentID, channelID string, bot *TelegramBot) {
+func (p *BotPool) Start(agentID, channelID string, d Driver) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -81,17 +45,17 @@ func (p *BotPool) StartBot(agentID, channelID string, bot *TelegramBot) {
 	if e, ok := p.bots[k]; ok {
 		e.cancel()
 		delete(p.bots, k)
-		log.Printf("[botpool] stopped old bot agent=%s channel=%s", agentID, channelID)
+		log.Printf("[botpool] stopped old %s bot agent=%s channel=%s", e.driver.Type(), agentID, channelID)
 	}
 
 	ctx, cancel := context.WithCancel(p.rootCtx)
-	p.bots[k] = &botEntry{bot: bot, cancel: cancel}
-	go bot.Start(ctx)
-	log.Printf("[botpool] started bot agent=%s channel=%s", agentID, channelID)
+	p.bots[k] = &botEntry{driver: d, cancel: cancel}
+	go d.Start(ctx)
+	log.Printf("[botpool] started %s bot agent=%s channel=%s", d.Type(), agentID, channelID)
 }
 
-// StopBot stops the bot for the given (agentID, channelID) if running.
-func (p *BotPool) StopBot(agentID, channelID string) {
+// Stop stops the driver for the given (agentID, channelID) if running.
+func (p *BotPool) Stop(agentID, channelID string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
@@ -99,11 +63,26 @@ func (p *BotPool) StopBot(agentID, channelID string) {
 	if e, ok := p.bots[k]; ok {
 		e.cancel()
 		delete(p.bots, k)
-		log.Printf("[botpool] stopped bot agent=%s channel=%s", agentID, channelID)
+		log.Printf("[botpool] stopped %s bot agent=%s channel=%s", e.driver.Type(), agentID, channelID)
+	}
+}
+
+// StopAgent stops every driver of an agent (agent deleted).
+func (p *BotPool) StopAgent(agentID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	prefix := agentID + "/"
+	for k, e := range p.bots {
+		if strings.HasPrefix(k, prefix) {
+			e.cancel()
+			delete(p.bots, k)
+			log.Printf("[botpool] stopped %s bot agent=%s channel=%s", e.driver.Type(), agentID, k[len(prefix):])
+		}
 	}
 }
 
-// IsRunning returns true if a bot is currently running for the given pair.
+// IsRunning returns true if a driver is currently running for the given pair.
 func (p *BotPool) IsRunning(agentID, channelID string) bool {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -111,28 +90,39 @@ func (p *BotPool) IsRunning(agentID, channelID string) bool {
 	return ok
 }
 
-// GetBot returns the running TelegramBot for the given (agentID, channelID), if any.
-func (p *BotPool) GetBot(agentID, channelID string) (*TelegramBot, bool) {
+// Get returns the running driver for the given (agentID, channelID), if any.
+func (p *BotPool) Get(agentID, channelID string) (Driver, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	e, ok := p.bots[poolKey(agentID, channelID)]
 	if !ok {
 		return nil, false
 	}
-	return e.bot, true
+	return e.driver, true
+}
+
+// First returns the agent's first running driver (lowest channelID, so the
+// choice is stable). Useful when the caller only knows the agentID and
+// there's typically one bot per agent.
+func (p *BotPool) First(agentID string) (Driver, string, bool) {
+	return p.FirstOfType(agentID, "")
 }
 
-// GetFirstBot returns the first running bot for the given agentID (any channelID).
-// Useful when the caller only knows the agentID and there's typically one bot per agent.
-func (p *BotPool) GetFirstBot(agentID string) (*TelegramBot, string, bool) {
+// FirstOfType is First restricted to one channel type ("" = any).
+func (p *BotPool) FirstOfType(agentID, channelType string) (Driver, string, bool) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	prefix := agentID + "/"
+	var ids []string
 	for k, e := range p.bots {
-		if len(k) > len(prefix) && k[:len(prefix)] == prefix {
-			channelID := k[len(prefix):]
-			return e.bot, channelID, true
+		if len(k) > len(prefix) && strings.HasPrefix(k, prefix) &&
+			(channelType == "" || e.driver.Type() == channelType) {
+			ids = append(ids, k[len(prefix):])
 		}
 	}
-	return nil, "", false
+	if len(ids) == 0 {
+		return nil, "", false
+	}
+	sort.Strings(ids)
+	return p.bots[prefix+ids[0]].driver, ids[0], true
 }
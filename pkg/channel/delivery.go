@@ -0,0 +1,672 @@
+// pkg/channel/delivery.go — durable outbound delivery queue.
+//
+// Final replies, notify answers, cron announcements and send_message pushes
+// go through DeliveryQueue instead of straight to Driver.Send: the message
+// is written to {agents.dir}/.outbox/{id}.json before the first attempt,
+// split into platform-sized chunks (Capabilities.MaxText), paced per chat
+// by a Throttle and retried with backoff — longer after a rate-limit error
+// (IsRateLimitError). What still fails after maxDeliveryAttempts stays as a
+// dead letter for GET /api/outbox. The id is derived from an idempotency
+// key, so a message that was already delivered is never sent twice, and a
+// delivery interrupted by a crash resumes at its first unsent chunk.
+// Proactive sends through a RecipientSender are tracked per recipient, so
+// a retry only reaches those who did not get the chunk yet. OnSettled
+// tells the sender how a message it saw queued finally ended.
+//
+// Streamed drafts (Edit) stay direct: the final text replaces them anyway.
+package channel
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+)
+
+// Delivery status values.
+const (
+	DeliveryPending = "pending" // waiting for (another) attempt
+	DeliverySent    = "sent"    // every chunk delivered; kept for idempotency
+	DeliveryDead    = "dead"    // gave up; waits for retry / discard
+)
+
+const (
+	// inlineAttempts are made by the sender before Send returns; later
+	// attempts run in the background (Run).
+	inlineAttempts = 3
+	// maxDeliveryAttempts turns a message into a dead letter.
+	maxDeliveryAttempts = 8
+	// deliveryRetention is how long sent records are kept, i.e. the window
+	// in which an idempotency key suppresses a second send.
+	deliveryRetention = 24 * time.Hour
+	// deliveryPoll is the background retry scan interval.
+	deliveryPoll = 5 * time.Second
+)
+
+var (
+	// ErrDeliveryQueued means the message was not delivered yet but stays
+	// queued for background retries.
+	ErrDeliveryQueued = errors.New("delivery queued for retry")
+	// ErrDeliveryDead means every attempt failed; see GET /api/outbox.
+	ErrDeliveryDead = errors.New("delivery failed")
+	// ErrDeliveryNotFound is returned by Retry / Discard for unknown ids.
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// retryBackoff is the wait before attempt n+1 after n failed attempts:
+// 1s doubling, from 5s after a rate-limit error, capped at 5 minutes.
+var retryBackoff = func(attempts int, err error) time.Duration {
+	base := time.Second
+	if IsRateLimitError(err) {
+		base = 5 * time.Second
+	}
+	d := base << min(attempts-1, 10)
+	if d > 5*time.Minute {
+		d = 5 * time.Minute
+	}
+	return d
+}
+
+// Delivery is one queued outbound message.
+type Delivery struct {
+	ID          string `json:"id"`
+	Key         string `json:"key,omitempty"` // idempotency key ("" = none)
+	AgentID     string `json:"agentId"`
+	ChannelID   string `json:"channelId"`
+	ChannelType string `json:"channelType"`
+	// Chat is the target conversation; nil = Driver.ProactiveSend to the
+	// bot's known recipients.
+	Chat    *ChatRef `json:"chat,omitempty"`
+	ReplyTo string   `json:"replyTo,omitempty"` // quoted by the first chunk
+	// Recipients are the RecipientSender targets of a proactive send,
+	// fixed when it is queued; Reached lists those that already got
+	// chunk Done.
+	Recipients []string `json:"recipients,omitempty"`
+	Reached    []string `json:"reached,omitempty"`
+	Chunks     []string `json:"chunks"`
+	Done       int      `json:"done"` // chunks delivered so far
+	MessageIDs []string `json:"messageIds,omitempty"`
+	Status     string   `json:"status"`
+	Attempts   int      `json:"attempts"`
+	LastError  string   `json:"lastError,omitempty"`
+	CreatedAt  int64    `json:"createdAt"` // Unix ms
+	UpdatedAt  int64    `json:"updatedAt"`
+	NextAt     int64    `json:"nextAt,omitempty"` // next background attempt
+}
+
+// DeliveryQueue persists and retries outbound messages. One per process;
+// drivers reach it through SetDeliveryQueue.
+type DeliveryQueue struct {
+	dir     string
+	resolve func(agentID, channelID string) (Driver, bool)
+
+	mu        sync.Mutex
+	items     map[string]*Delivery
+	busy      map[string]bool // an attempt is in flight
+	throttles map[string]Throttle
+	settled   map[string]func(Delivery) // OnSettled, by key prefix
+	wake      chan struct{}
+}
+
+// NewDeliveryQueue loads the queue stored in dir. resolve finds the live
+// driver of a channel for background retries (BotPool.Get).
+func NewDeliveryQueue(dir string, resolve func(agentID, channelID string) (Driver, bool)) *DeliveryQueue {
+	q := &DeliveryQueue{
+		dir:       dir,
+		resolve:   resolve,
+		items:     make(map[string]*Delivery),
+		busy:      make(map[string]bool),
+		throttles: make(map[string]Throttle),
+		settled:   make(map[string]func(Delivery)),
+		wake:      make(chan struct{}, 1),
+	}
+	q.load()
+	return q
+}
+
+var activeDelivery atomic.Pointer[DeliveryQueue]
+
+// SetDeliveryQueue installs the queue every Pipeline sends final messages
+// through (nil = send directly).
+func SetDeliveryQueue(q *DeliveryQueue) { activeDelivery.Store(q) }
+
+// DeliveryID is the queue id for an idempotency key.
+func DeliveryID(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:10])
+}
+
+// Send queues m (AgentID, ChannelID, Chat, ReplyTo, Chunks, optional Key)
+// and delivers it through d. It returns nil once every chunk is sent — or
+// was already sent under the same key — ErrDeliveryQueued when retries
+// continue in the background, and ErrDeliveryDead when none are left.
+func (q *DeliveryQueue) Send(ctx context.Context, d Driver, m Delivery) error {
+	if m.Key != "" {
+		m.ID = DeliveryID(m.Key)
+	} else {
+		m.ID = randomDeliveryID()
+	}
+	now := time.Now().UnixMilli()
+	q.mu.Lock()
+	if old, ok := q.items[m.ID]; ok && old.Status != DeliveryDead {
+		status := old.Status
+		q.mu.Unlock()
+		if status == DeliverySent {
+			return nil
+		}
+		return ErrDeliveryQueued
+	}
+	m.ChannelType = d.Type()
+	m.Status = DeliveryPending
+	m.Done, m.Attempts, m.MessageIDs, m.LastError, m.NextAt = 0, 0, nil, "", 0
+	m.Recipients, m.Reached = nil, nil
+	if rs, ok := d.(RecipientSender); ok && m.Chat == nil {
+		m.Recipients = rs.ProactiveRecipients()
+	}
+	m.CreatedAt, m.UpdatedAt = now, now
+	q.items[m.ID] = &m
+	q.busy[m.ID] = true
+	q.saveLocked(&m)
+	q.mu.Unlock()
+	defer q.release(m.ID)
+
+	for {
+		err := q.attempt(ctx, d, m.ID)
+		if err == nil {
+			return nil
+		}
+		q.mu.Lock()
+		it := *q.items[m.ID]
+		q.mu.Unlock()
+		if it.Status == DeliveryDead {
+			return fmt.Errorf("%w: %v", ErrDeliveryDead, err)
+		}
+		if it.Attempts >= inlineAttempts {
+			return fmt.Errorf("%w: %v", ErrDeliveryQueued, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ErrDeliveryQueued, err)
+		case <-time.After(retryBackoff(it.Attempts, err)):
+		}
+	}
+}
+
+// Proactive queues text for d.ProactiveSend (cron announcements,
+// send_message), chunked to the driver's MaxText.
+func (q *DeliveryQueue) Proactive(ctx context.Context, agentID, channelID string, d Driver, key, text string) error {
+	return q.Send(ctx, d, Delivery{
+		Key:       key,
+		AgentID:   agentID,
+		ChannelID: channelID,
+		Chunks:    splitText(text, d.Capabilities().MaxText),
+	})
+}
+
+// OnSettled registers fn for deliveries whose idempotency key starts with
+// prefix. It runs when a background retry (Run, or Retry from /api/outbox)
+// leaves one of them sent or dead, i.e. after Send returned
+// ErrDeliveryQueued; it must not block.
+func (q *DeliveryQueue) OnSettled(prefix string, fn func(Delivery)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.settled[prefix] = fn
+}
+
+// Run retries due deliveries until ctx is cancelled.
+func (q *DeliveryQueue) Run(ctx context.Context) {
+	t := time.NewTicker(deliveryPoll)
+	defer t.Stop()
+	for {
+		q.retryDue(ctx)
+		q.prune()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// List returns the deliveries with the given status ("" = pending and
+// dead), newest first.
+func (q *DeliveryQueue) List(status string) []Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := []Delivery{}
+	for _, it := range q.items {
+		if (status == "" && it.Status != DeliverySent) || it.Status == status {
+			out = append(out, *it)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UpdatedAt > out[j].UpdatedAt })
+	return out
+}
+
+// Retry schedules a pending or dead delivery for an immediate attempt with
+// a fresh attempt budget.
+func (q *DeliveryQueue) Retry(id string) error {
+	q.mu.Lock()
+	it, ok := q.items[id]
+	if !ok || it.Status == DeliverySent {
+		q.mu.Unlock()
+		return ErrDeliveryNotFound
+	}
+	it.Status, it.Attempts, it.NextAt = DeliveryPending, 0, 0
+	it.UpdatedAt = time.Now().UnixMilli()
+	q.saveLocked(it)
+	q.mu.Unlock()
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Discard drops an undelivered message.
+func (q *DeliveryQueue) Discard(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, ok := q.items[id]
+	if !ok || it.Status == DeliverySent {
+		return ErrDeliveryNotFound
+	}
+	if q.busy[id] {
+		return fmt.Errorf("delivery %s is being sent", id)
+	}
+	delete(q.items, id)
+	if err := os.Remove(q.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// attempt sends the remaining chunks of one delivery; the caller holds its
+// busy flag.
+func (q *DeliveryQueue) attempt(ctx context.Context, d Driver, id string) error {
+	q.mu.Lock()
+	m := *q.items[id]
+	q.mu.Unlock()
+	th := q.throttle(m.AgentID, m.ChannelID, d)
+	chat := chatKey(m.Chat)
+	for i := m.Done; i < len(m.Chunks); i++ {
+		if err := ctx.Err(); err != nil {
+			return q.fail(id, err)
+		}
+		var reached []string
+		if i == m.Done {
+			reached = m.Reached
+		}
+		if rs, ok := d.(RecipientSender); ok && m.Chat == nil && len(m.Recipients) > 0 {
+			if err := q.sendToRecipients(ctx, rs, th, id, m.Recipients, reached, m.Chunks[i]); err != nil {
+				return q.fail(id, err)
+			}
+			q.update(id, func(it *Delivery) {
+				it.Done, it.Reached = i+1, nil
+				if it.Done == len(it.Chunks) {
+					it.Status, it.LastError, it.NextAt = DeliverySent, "", 0
+				}
+			})
+			continue
+		}
+		th.Wait(chat)
+		var msgID string
+		var err error
+		if m.Chat == nil {
+			err = d.ProactiveSend(m.Chunks[i])
+		} else {
+			replyTo := ""
+			if i == 0 {
+				replyTo = m.ReplyTo
+			}
+			msgID, err = d.Send(ctx, *m.Chat, m.Chunks[i], replyTo)
+		}
+		th.OnResponse(chat, err)
+		if err != nil {
+			return q.fail(id, err)
+		}
+		q.update(id, func(it *Delivery) {
+			it.Done = i + 1
+			if msgID != "" {
+				it.MessageIDs = append(it.MessageIDs, msgID)
+			}
+			if it.Done == len(it.Chunks) {
+				it.Status, it.LastError, it.NextAt = DeliverySent, "", 0
+			}
+		})
+	}
+	if len(m.Chunks) == 0 {
+		q.update(id, func(it *Delivery) { it.Status = DeliverySent })
+	}
+	return nil
+}
+
+// sendToRecipients delivers one chunk to every recipient not in reached,
+// recording each success so a retry skips it.
+func (q *DeliveryQueue) sendToRecipients(ctx context.Context, rs RecipientSender, th Throttle, id string, recipients, reached []string, chunk string) error {
+	done := make(map[string]bool, len(reached))
+	for _, r := range reached {
+		done[r] = true
+	}
+	var lastErr error
+	for _, r := range recipients {
+		if done[r] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		key := chatKey(&ChatRef{ID: r})
+		th.Wait(key)
+		err := rs.ProactiveSendTo(ctx, r, chunk)
+		th.OnResponse(key, err)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", r, err)
+			continue
+		}
+		q.update(id, func(it *Delivery) { it.Reached = append(it.Reached, r) })
+	}
+	return lastErr
+}
+
+// fail records a failed attempt and schedules the next one (or gives up).
+func (q *DeliveryQueue) fail(id string, err error) error {
+	q.update(id, func(it *Delivery) {
+		it.Attempts++
+		it.LastError = err.Error()
+		if it.Attempts >= maxDeliveryAttempts {
+			it.Status, it.NextAt = DeliveryDead, 0
+			log.Printf("[delivery] %s %s/%s dead after %d attempts: %v", it.ID, it.AgentID, it.ChannelID, it.Attempts, err)
+			return
+		}
+		it.NextAt = time.Now().Add(retryBackoff(it.Attempts, err)).UnixMilli()
+	})
+	return err
+}
+
+func (q *DeliveryQueue) update(id string, fn func(*Delivery)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	it, ok := q.items[id]
+	if !ok {
+		return
+	}
+	fn(it)
+	it.UpdatedAt = time.Now().UnixMilli()
+	q.saveLocked(it)
+}
+
+func (q *DeliveryQueue) release(id string) {
+	q.mu.Lock()
+	delete(q.busy, id)
+	q.mu.Unlock()
+}
+
+// retryDue starts a background attempt for every pending delivery whose
+// backoff has elapsed.
+func (q *DeliveryQueue) retryDue(ctx context.Context) {
+	now := time.Now().UnixMilli()
+	q.mu.Lock()
+	var due []Delivery
+	for id, it := range q.items {
+		if it.Status == DeliveryPending && !q.busy[id] && it.NextAt <= now {
+			q.busy[id] = true
+			due = append(due, *it)
+		}
+	}
+	q.mu.Unlock()
+	for _, it := range due {
+		go func(it Delivery) {
+			defer q.release(it.ID)
+			if d, ok := q.resolve(it.AgentID, it.ChannelID); !ok {
+				_ = q.fail(it.ID, fmt.Errorf("channel %s of agent %s is not running", it.ChannelID, it.AgentID))
+			} else if err := q.attempt(ctx, d, it.ID); err != nil {
+				log.Printf("[delivery] %s %s/%s retry failed: %v", it.ID, it.AgentID, it.ChannelID, err)
+			}
+			q.notifySettled(it.ID)
+		}(it)
+	}
+}
+
+// notifySettled runs the OnSettled hooks matching a delivery that is now
+// sent or dead.
+func (q *DeliveryQueue) notifySettled(id string) {
+	q.mu.Lock()
+	it, ok := q.items[id]
+	if !ok || it.Key == "" || (it.Status != DeliverySent && it.Status != DeliveryDead) {
+		q.mu.Unlock()
+		return
+	}
+	snapshot := *it
+	var fns []func(Delivery)
+	for prefix, fn := range q.settled {
+		if strings.HasPrefix(it.Key, prefix) {
+			fns = append(fns, fn)
+		}
+	}
+	q.mu.Unlock()
+	for _, fn := range fns {
+		fn(snapshot)
+	}
+}
+
+// prune forgets sent deliveries older than deliveryRetention.
+func (q *DeliveryQueue) prune() {
+	cutoff := time.Now().Add(-deliveryRetention).UnixMilli()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for id, it := range q.items {
+		if it.Status == DeliverySent && it.UpdatedAt < cutoff && !q.busy[id] {
+			delete(q.items, id)
+			_ = os.Remove(q.path(id))
+		}
+	}
+}
+
+// throttle returns the per-channel pacer; its interval follows the
+// driver's edit cadence (1s by default).
+func (q *DeliveryQueue) throttle(agentID, channelID string, d Driver) Throttle {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	k := poolKey(agentID, channelID)
+	th, ok := q.throttles[k]
+	if !ok {
+		every := d.Capabilities().EditEvery
+		if every <= 0 {
+			every = time.Second
+		}
+		th = NewFixedThrottle(every)
+		q.throttles[k] = th
+	}
+	return th
+}
+
+// chatKey maps a chat to the Throttle's int64 key (0 = proactive).
+func chatKey(chat *ChatRef) int64 {
+	if chat == nil {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(chat.ID + "/" + chat.ThreadID))
+	return int64(h.Sum64() >> 1)
+}
+
+func (q *DeliveryQueue) path(id string) string {
+	return filepath.Join(q.dir, id+".json")
+}
+
+func (q *DeliveryQueue) saveLocked(it *Delivery) {
+	if q.dir == "" {
+		return
+	}
+	data, err := json.MarshalIndent(it, "", "  ")
+	if err == nil {
+		err = persist.AtomicWrite(q.path(it.ID), data, 0o600)
+	}
+	if err != nil {
+		log.Printf("[delivery] save %s: %v", it.ID, err)
+	}
+}
+
+func (q *DeliveryQueue) load() {
+	if q.dir == "" {
+		return
+	}
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(q.dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var it Delivery
+		if json.Unmarshal(data, &it) != nil || it.ID+".json" != e.Name() {
+			log.Printf("[delivery] skipping unreadable %s", e.Name())
+			continue
+		}
+		q.items[it.ID] = &it
+	}
+}
+
+func randomDeliveryID() string {
+	var b [10]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// outlet sends a driver's final messages: through the installed
+// DeliveryQueue, or straight to the driver without one.
+type outlet struct {
+	d         Driver
+	agentID   string
+	channelID string
+}
+
+// send delivers chunks to chat; key is the idempotency key ("" = none).
+func (o outlet) send(ctx context.Context, chat ChatRef, replyTo, key string, chunks []string) error {
+	if q := activeDelivery.Load(); q != nil && o.agentID != "" {
+		return q.Send(ctx, o.d, Delivery{
+			Key:       key,
+			AgentID:   o.agentID,
+			ChannelID: o.channelID,
+			Chat:      &chat,
+			ReplyTo:   replyTo,
+			Chunks:    chunks,
+		})
+	}
+	for i, c := range chunks {
+		if i > 0 {
+			replyTo = ""
+		}
+		if _, err := o.d.Send(ctx, chat, c, replyTo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitText cuts text into pieces of at most max runes (max <= 0 = no
+// limit), preferring paragraph, line and word boundaries. A ``` fence
+// left open by one piece is closed there and reopened in the next.
+func splitText(text string, max int) []string {
+	if max <= 0 || utf8.RuneCountInString(text) <= max {
+		return []string{text}
+	}
+	var out []string
+	fence := ""
+	for text != "" {
+		prefix := ""
+		if fence != "" {
+			prefix = fence + "\n"
+		}
+		limit := max - utf8.RuneCountInString(prefix)
+		if strings.Contains(text, "```") {
+			limit -= len("\n```") // room to close a fence
+		}
+		if limit < max/2 {
+			limit = max / 2
+		}
+		piece := text
+		text = ""
+		if utf8.RuneCountInString(piece) > limit {
+			cut := runeOffset(piece, limit)
+			window := piece[:cut]
+			if i := strings.LastIndex(window, "\n\n"); i > cut/2 {
+				cut = i
+			} else if i := strings.LastIndexByte(window, '\n'); i > cut/2 {
+				cut = i
+			} else if i := strings.LastIndexByte(window, ' '); i > cut/2 {
+				cut = i
+			}
+			piece, text = piece[:cut], piece[cut:]
+			text = strings.TrimLeft(text, "\n")
+			if strings.HasPrefix(text, " ") && !strings.HasSuffix(piece, "\n") {
+				text = text[1:]
+			}
+		}
+		chunk := prefix + piece
+		fence = openFence(chunk)
+		if fence != "" && text != "" {
+			chunk = strings.TrimRight(chunk, "\n") + "\n```"
+		}
+		out = append(out, chunk)
+	}
+	return out
+}
+
+// openFence returns the opening line of a ``` block left open in s.
+func openFence(s string) string {
+	open := ""
+	for _, line := range strings.Split(s, "\n") {
+		t := strings.TrimSpace(line)
+		if !strings.HasPrefix(t, "```") {
+			continue
+		}
+		if open == "" {
+			open = t
+		} else {
+			open = ""
+		}
+	}
+	return open
+}
+
+// runeOffset is the byte offset of the n-th rune of s.
+func runeOffset(s string, n int) int {
+	for i := range s {
+		if n == 0 {
+			return i
+		}
+		n--
+	}
+	return len(s)
+}
+
+// clipRunes trims s to at most max runes.
+func clipRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	return s[:runeOffset(s, max)]
+}
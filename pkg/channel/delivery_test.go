@@ -0,0 +1,255 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+// flakyDriver fails the first `fails` sends with err.
+type flakyDriver struct {
+	fakeDriver
+	mu2       sync.Mutex
+	fails     int
+	err       error
+	proactive []string
+}
+
+func (f *flakyDriver) fail() error {
+	f.mu2.Lock()
+	defer f.mu2.Unlock()
+	if f.fails != 0 {
+		f.fails--
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyDriver) Send(ctx context.Context, chat ChatRef, text, replyTo string) (string, error) {
+	if err := f.fail(); err != nil {
+		return "", err
+	}
+	return f.fakeDriver.Send(ctx, chat, text, replyTo)
+}
+
+func (f *flakyDriver) ProactiveSend(text string) error {
+	if err := f.fail(); err != nil {
+		return err
+	}
+	f.mu2.Lock()
+	defer f.mu2.Unlock()
+	f.proactive = append(f.proactive, text)
+	return nil
+}
+
+func fastRetries(t *testing.T) {
+	old := retryBackoff
+	retryBackoff = func(int, error) time.Duration { return time.Millisecond }
+	t.Cleanup(func() { retryBackoff = old })
+}
+
+func TestSplitText(t *testing.T) {
+	if got := splitText("short", 10); len(got) != 1 || got[0] != "short" {
+		t.Errorf("short = %q", got)
+	}
+	long := strings.Repeat("第一段内容。", 30) + "\n\n" + strings.Repeat("word ", 60)
+	chunks := splitText(long, 100)
+	for _, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > 100 {
+			t.Errorf("chunk of %d runes: %q", n, c)
+		}
+	}
+	if joined := strings.Join(chunks, ""); strings.Count(joined, "第一段内容。") != 30 || strings.Count(joined, "word") != 60 {
+		t.Errorf("text lost: %q", chunks)
+	}
+
+	code := "intro\n```go\n" + strings.Repeat("x := 1\n", 40) + "```\nafter"
+	chunks = splitText(code, 120)
+	if len(chunks) < 2 {
+		t.Fatalf("code chunks = %q", chunks)
+	}
+	for i, c := range chunks {
+		if openFence(c) != "" {
+			t.Errorf("chunk %d leaves a fence open: %q", i, c)
+		}
+		if i > 0 && i < len(chunks)-1 && !strings.HasPrefix(c, "```go\n") {
+			t.Errorf("chunk %d does not reopen the fence: %q", i, c)
+		}
+	}
+}
+
+func TestDeliveryQueueRetriesAndIdempotency(t *testing.T) {
+	fastRetries(t)
+	dir := t.TempDir()
+	d := &flakyDriver{fakeDriver: fakeDriver{caps: Capabilities{EditEvery: time.Millisecond}}, fails: 2, err: errors.New("429 Too Many Requests")}
+	q := NewDeliveryQueue(dir, nil)
+	m := Delivery{Key: "reply/a/c/7", AgentID: "a", ChannelID: "c", Chat: &ChatRef{ID: "42"}, Chunks: []string{"one", "two"}}
+
+	if err := q.Send(context.Background(), d, m); err != nil {
+		t.Fatalf("send after two rate limits: %v", err)
+	}
+	if strings.Join(d.sent, "|") != "one|two" {
+		t.Fatalf("sent = %v", d.sent)
+	}
+	// Same key again, also after a restart: delivered once.
+	if err := q.Send(context.Background(), d, m); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewDeliveryQueue(dir, nil).Send(context.Background(), d, m); err != nil {
+		t.Fatal(err)
+	}
+	if len(d.sent) != 2 {
+		t.Errorf("duplicate send: %v", d.sent)
+	}
+	if got := q.List(DeliverySent); len(got) != 1 || got[0].Attempts != 2 || len(got[0].MessageIDs) != 2 {
+		t.Errorf("sent record = %+v", got)
+	}
+}
+
+func TestDeliveryQueueDeadLetterAndRetry(t *testing.T) {
+	fastRetries(t)
+	dir := t.TempDir()
+	d := &flakyDriver{fakeDriver: fakeDriver{caps: Capabilities{EditEvery: time.Millisecond}}, fails: -1, err: errors.New("connection reset")}
+	q := NewDeliveryQueue(dir, func(agentID, channelID string) (Driver, bool) { return d, true })
+
+	err := q.Proactive(context.Background(), "a", "c", d, "cron/run-1", "report")
+	if !errors.Is(err, ErrDeliveryQueued) {
+		t.Fatalf("err = %v, want queued", err)
+	}
+	for i := 0; i < maxDeliveryAttempts; i++ {
+		time.Sleep(2 * time.Millisecond)
+		q.retryDue(context.Background())
+		waitIdle(t, q)
+	}
+	dead := q.List(DeliveryDead)
+	if len(dead) != 1 || dead[0].Attempts != maxDeliveryAttempts || dead[0].LastError != "connection reset" || dead[0].Chat != nil {
+		t.Fatalf("dead letters = %+v", dead)
+	}
+	id := dead[0].ID
+
+	// Fixed upstream: a manual retry delivers it.
+	d.mu2.Lock()
+	d.fails = 0
+	d.mu2.Unlock()
+	if err := q.Retry(id); err != nil {
+		t.Fatal(err)
+	}
+	q.retryDue(context.Background())
+	waitIdle(t, q)
+	if len(q.List("")) != 0 || strings.Join(d.proactive, "|") != "report" {
+		t.Errorf("after retry: queue=%+v proactive=%v", q.List(""), d.proactive)
+	}
+	if err := q.Discard(id); !errors.Is(err, ErrDeliveryNotFound) {
+		t.Errorf("discard of a sent message = %v", err)
+	}
+}
+
+func TestDeliveryQueueResumesAfterRestart(t *testing.T) {
+	fastRetries(t)
+	dir := t.TempDir()
+	first := NewDeliveryQueue(dir, nil)
+	first.items["x"] = &Delivery{ID: "x", AgentID: "a", ChannelID: "c", Chat: &ChatRef{ID: "42"}, Chunks: []string{"one", "two"}, Done: 1, Status: DeliveryPending}
+	first.saveLocked(first.items["x"])
+
+	d := &flakyDriver{fakeDriver: fakeDriver{caps: Capabilities{EditEvery: time.Millisecond}}}
+	q := NewDeliveryQueue(dir, func(agentID, channelID string) (Driver, bool) { return d, agentID == "a" && channelID == "c" })
+	q.retryDue(context.Background())
+	waitIdle(t, q)
+	if strings.Join(d.sent, "|") != "two" {
+		t.Errorf("resumed sends = %v", d.sent)
+	}
+
+	// Discard drops an undelivered message and its file.
+	q.items["y"] = &Delivery{ID: "y", Status: DeliveryDead}
+	q.saveLocked(q.items["y"])
+	if err := q.Discard("y"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "y.json")); !os.IsNotExist(err) {
+		t.Errorf("discarded file still there: %v", err)
+	}
+}
+
+// recipientDriver is a RecipientSender whose sends to bad fail `fails` times.
+type recipientDriver struct {
+	fakeDriver
+	mu2   sync.Mutex
+	bad   string
+	fails int
+	got   map[string][]string
+}
+
+func (f *recipientDriver) ProactiveRecipients() []string { return []string{"u1", "u2", "u3"} }
+
+func (f *recipientDriver) ProactiveSendTo(_ context.Context, to, text string) error {
+	f.mu2.Lock()
+	defer f.mu2.Unlock()
+	if to == f.bad && f.fails > 0 {
+		f.fails--
+		return errors.New("timeout")
+	}
+	f.got[to] = append(f.got[to], text)
+	return nil
+}
+
+func TestDeliveryQueueRetriesOnlyMissedRecipients(t *testing.T) {
+	fastRetries(t)
+	d := &recipientDriver{fakeDriver: fakeDriver{caps: Capabilities{EditEvery: time.Millisecond}}, bad: "u2", fails: inlineAttempts, got: map[string][]string{}}
+	q := NewDeliveryQueue(t.TempDir(), func(agentID, channelID string) (Driver, bool) { return d, true })
+	settled := make(chan Delivery, 1)
+	q.OnSettled("cron/", func(m Delivery) { settled <- m })
+
+	if err := q.Proactive(context.Background(), "a", "c", d, "cron/run-1", "report"); !errors.Is(err, ErrDeliveryQueued) {
+		t.Fatalf("err = %v, want queued", err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	q.retryDue(context.Background())
+	waitIdle(t, q)
+	for _, u := range []string{"u1", "u2", "u3"} {
+		if got := d.got[u]; len(got) != 1 {
+			t.Errorf("%s received %v, want the report once", u, got)
+		}
+	}
+	select {
+	case m := <-settled:
+		if m.Status != DeliverySent || m.Key != "cron/run-1" {
+			t.Errorf("settled = %+v", m)
+		}
+	default:
+		t.Error("OnSettled hook not called")
+	}
+}
+
+func TestStreamReplySplitsLongReplies(t *testing.T) {
+	ctx := context.Background()
+	d := &fakeDriver{caps: Capabilities{Edit: true, EditEvery: time.Hour, Placeholder: "...", MaxText: 10}}
+	_, err := StreamReply(ctx, d, ChatRef{ID: "c"}, "", streamOf(
+		StreamEvent{Type: "text_delta", Text: "aaaa bbbb cccc dddd"},
+		StreamEvent{Type: "done"},
+	))
+	if err != nil || strings.Join(d.sent, "|") != "...|cccc dddd" || strings.Join(d.edit, "|") != "aaaa bbbb" {
+		t.Errorf("err=%v sent=%v edit=%v", err, d.sent, d.edit)
+	}
+}
+
+// waitIdle waits for background attempts to finish.
+func waitIdle(t *testing.T, q *DeliveryQueue) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		q.mu.Lock()
+		n := len(q.busy)
+		q.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("delivery attempts still running")
+}
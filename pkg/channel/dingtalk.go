@@ -0,0 +1,866 @@
+// Package channel — DingTalk (钉钉) enterprise-internal robot integration.
+//   - Stream mode: POST /v1.0/gateway/connections/open, then a websocket to
+//     the returned endpoint — no public callback URL needed
+//   - Replies go to the message's sessionWebhook (valid ~90 min); after it
+//     expires, and for proactive pushes, the robot OpenAPI is used instead
+//   - Group chats: only messages that @mention the robot (isInAtList)
+//   - Sessions: "dingtalk-{conversationId}"; allowlist entries are staff
+//     userids (senderStaffId)
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+	"github.com/Zyling-ai/zyhive/pkg/network"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	dingtalkAPIBase  = "https://api.dingtalk.com"
+	dingtalkOAPIBase = "https://oapi.dingtalk.com"
+	// dingtalkBotTopic is the Stream topic robot messages arrive on.
+	dingtalkBotTopic = "/v1.0/im/bot/messages/get"
+	// dingtalkMaxFileBytes caps one downloaded attachment.
+	dingtalkMaxFileBytes = 20 << 20
+	// dingtalkMaxContent is the markdown size DingTalk accepts (bytes, ~20KB).
+	dingtalkMaxContent = 18000
+	// dingtalkReadTimeout drops a Stream connection with no traffic (the
+	// client pings every dingtalkPingEvery).
+	dingtalkReadTimeout = 90 * time.Second
+	dingtalkPingEvery   = 30 * time.Second
+)
+
+// ── DingTalk API types ────────────────────────────────────────────────────
+
+// dingtalkFrame is one Stream protocol message (both directions).
+type dingtalkFrame struct {
+	SpecVersion string            `json:"specVersion"`
+	Type        string            `json:"type"` // SYSTEM / EVENT / CALLBACK
+	Headers     map[string]string `json:"headers"`
+	Data        string            `json:"data"`
+}
+
+// dingtalkMessage is a robot message (Stream CALLBACK data).
+type dingtalkMessage struct {
+	MsgID             string `json:"msgId"`
+	MsgType           string `json:"msgtype"` // text / richText / picture / audio / video / file
+	ConversationID    string `json:"conversationId"`
+	ConversationType  string `json:"conversationType"` // "1" single chat, "2" group
+	ConversationTitle string `json:"conversationTitle"`
+	SenderID          string `json:"senderId"`
+	SenderStaffID     string `json:"senderStaffId"`
+	SenderNick        string `json:"senderNick"`
+	ChatbotUserID     string `json:"chatbotUserId"`
+	IsInAtList        bool   `json:"isInAtList"`
+	RobotCode         string `json:"robotCode"`
+	// SessionWebhook accepts replies without an access token until
+	// SessionWebhookExpiredTime (unix ms).
+	SessionWebhook            string `json:"sessionWebhook"`
+	SessionWebhookExpiredTime int64  `json:"sessionWebhookExpiredTime"`
+	Text                      struct {
+		Content string `json:"content"`
+	} `json:"text"`
+	Content json.RawMessage `json:"content"`
+}
+
+// dingtalkContent is the "content" of non-text messages.
+type dingtalkContent struct {
+	DownloadCode string `json:"downloadCode"`
+	FileName     string `json:"fileName"`
+	Recognition  string `json:"recognition"` // audio: DingTalk's own transcript
+	RichText     []struct {
+		Text         string `json:"text"`
+		Type         string `json:"type"`
+		DownloadCode string `json:"downloadCode"`
+	} `json:"richText"`
+}
+
+// dingtalkHook is a conversation's latest sessionWebhook.
+type dingtalkHook struct {
+	URL     string
+	Expires time.Time
+}
+
+// ── DingTalkBot ───────────────────────────────────────────────────────────
+
+type DingTalkBot struct {
+	appKey       string
+	appSecret    string
+	robotCode    string
+	agentID      string
+	agentDir     string
+	channelID    string
+	getAllowFrom func() []string
+
+	streamFunc   StreamFunc
+	pendingStore *PendingStoreStr
+	panelBaseURL string
+	onConnected  func(name string)
+
+	apiBase  string
+	oapiBase string
+	client   *http.Client
+	// dialWS connects to the Stream endpoint (tests swap in a plain dialer).
+	dialWS func(ctx context.Context, wsURL string) (*websocket.Conn, error)
+
+	tokMu    sync.Mutex
+	token    string
+	tokenExp time.Time
+
+	runMu  sync.Mutex
+	runCtx context.Context
+
+	seenMu sync.Mutex
+	seen   map[string]time.Time // msgId dedup (Stream redelivers unacked callbacks)
+
+	// hooks maps conversationId → dingtalkHook; peers maps a single chat's
+	// conversationId → the staff userid on the other side (OpenAPI sends
+	// address users, not conversations).
+	hooks sync.Map
+	peers sync.Map
+	// avatars dedupes profile lookups (once per user per process).
+	avatars sync.Map
+	// chatMu serializes processing per session to avoid concurrent runs.
+	chatMu sync.Map
+	// inflight tracks message handlers; Start returns once they finish.
+	inflight sync.WaitGroup
+}
+
+// NewDingTalkBotWithStream creates a DingTalkBot. robotCode defaults to
+// appKey (the robot of an enterprise-internal app shares its key).
+func NewDingTalkBotWithStream(appKey, appSecret, robotCode, agentID, agentDir, channelID string, getAllowFrom func() []string, sf StreamFunc, pending *PendingStoreStr) *DingTalkBot {
+	if robotCode == "" {
+		robotCode = appKey
+	}
+	return &DingTalkBot{
+		appKey:       appKey,
+		appSecret:    appSecret,
+		robotCode:    robotCode,
+		agentID:      agentID,
+		agentDir:     agentDir,
+		channelID:    channelID,
+		getAllowFrom: getAllowFrom,
+		streamFunc:   sf,
+		pendingStore: pending,
+		apiBase:      dingtalkAPIBase,
+		oapiBase:     dingtalkOAPIBase,
+		client:       netguard.NewSafeClient(15 * time.Second),
+		dialWS:       dialDingTalkStream,
+		runCtx:       context.Background(),
+		seen:         make(map[string]time.Time),
+	}
+}
+
+// SetOnConnected sets a callback fired once the credentials are verified.
+func (b *DingTalkBot) SetOnConnected(fn func(name string)) {
+	b.onConnected = fn
+}
+
+// SetPanelBaseURL sets the ZyHive panel URL shown in pairing messages.
+func (b *DingTalkBot) SetPanelBaseURL(url string) {
+	b.panelBaseURL = url
+}
+
+// Start verifies the app credentials, then keeps a Stream connection up
+// until ctx is cancelled.
+func (b *DingTalkBot) Start(ctx context.Context) {
+	log.Printf("[dingtalk] starting agent=%s", b.agentID)
+	b.runMu.Lock()
+	b.runCtx = ctx
+	b.runMu.Unlock()
+	defer b.inflight.Wait()
+
+	for {
+		if _, err := b.accessToken(ctx); err == nil {
+			break
+		} else {
+			log.Printf("[dingtalk] accessToken error: %v — retrying in 5s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+	log.Printf("[dingtalk] credentials ok appKey=%s", b.appKey)
+	if b.onConnected != nil {
+		b.onConnected(b.appKey)
+	}
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := b.runOnce(ctx); err != nil {
+			log.Printf("[dingtalk] stream error: %v — reconnecting in 5s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (b *DingTalkBot) ctx() context.Context {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+	return b.runCtx
+}
+
+func (b *DingTalkBot) spawn(fn func()) {
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		fn()
+	}()
+}
+
+// markSeen reports whether msgID was already handled, recording it if not.
+func (b *DingTalkBot) markSeen(msgID string) bool {
+	b.seenMu.Lock()
+	defer b.seenMu.Unlock()
+	if _, dup := b.seen[msgID]; dup {
+		return true
+	}
+	b.seen[msgID] = time.Now()
+	if len(b.seen) > 2000 {
+		cutoff := time.Now().Add(-2 * time.Hour)
+		for k, t := range b.seen {
+			if t.Before(cutoff) {
+				delete(b.seen, k)
+			}
+		}
+	}
+	return false
+}
+
+// ── Stream mode ───────────────────────────────────────────────────────────
+
+// openStream registers a Stream connection for robot messages and returns
+// the websocket URL (endpoint + one-time ticket).
+func (b *DingTalkBot) openStream(ctx context.Context) (string, error) {
+	var out struct {
+		Endpoint string `json:"endpoint"`
+		Ticket   string `json:"ticket"`
+	}
+	body := map[string]any{
+		"clientId":      b.appKey,
+		"clientSecret":  b.appSecret,
+		"subscriptions": []map[string]string{{"type": "CALLBACK", "topic": dingtalkBotTopic}},
+		"ua":            "zyhive",
+	}
+	if err := b.post(ctx, b.apiBase+"/v1.0/gateway/connections/open", "", body, &out); err != nil {
+		return "", fmt.Errorf("connections/open: %w", err)
+	}
+	if out.Endpoint == "" || out.Ticket == "" {
+		return "", errors.New("connections/open: empty endpoint or ticket")
+	}
+	sep := "?"
+	if strings.Contains(out.Endpoint, "?") {
+		sep = "&"
+	}
+	return out.Endpoint + sep + "ticket=" + url.QueryEscape(out.Ticket), nil
+}
+
+// runOnce holds one Stream connection until it drops. A nil error means
+// ctx was cancelled.
+func (b *DingTalkBot) runOnce(ctx context.Context) error {
+	wsURL, err := b.openStream(ctx)
+	if err != nil {
+		return err
+	}
+	ws, err := b.dialWS(ctx, wsURL)
+	if err != nil {
+		return fmt.Errorf("ws dial: %w", err)
+	}
+	defer ws.Close()
+
+	// Acks are written by the read loop, pings by the ticker below.
+	var wmu sync.Mutex
+	write := func(v any) error {
+		wmu.Lock()
+		defer wmu.Unlock()
+		_ = ws.SetWriteDeadline(time.Now().Add(10 * time.Second))
+		return ws.WriteJSON(v)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		wmu.Lock()
+		_ = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		wmu.Unlock()
+		_ = ws.Close()
+	})
+	defer stop()
+
+	_ = ws.SetReadDeadline(time.Now().Add(dingtalkReadTimeout))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(dingtalkReadTimeout))
+	})
+	pingCtx, pingCancel := context.WithCancel(ctx)
+	defer pingCancel()
+	go func() {
+		t := time.NewTicker(dingtalkPingEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-pingCtx.Done():
+				return
+			case <-t.C:
+			}
+			wmu.Lock()
+			err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(5*time.Second))
+			wmu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+	log.Printf("[dingtalk] stream connected agent=%s", b.agentID)
+
+	for {
+		var f dingtalkFrame
+		if err := ws.ReadJSON(&f); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("ws read: %w", err)
+		}
+		_ = ws.SetReadDeadline(time.Now().Add(dingtalkReadTimeout))
+		if err := b.handleFrame(ctx, f, write); err != nil {
+			return err
+		}
+	}
+}
+
+// dingtalkAck builds the reply to a frame: same messageId, status 200.
+func dingtalkAck(f dingtalkFrame, data string) map[string]any {
+	return map[string]any{
+		"code":    200,
+		"message": "OK",
+		"headers": map[string]string{"contentType": "application/json", "messageId": f.Headers["messageId"]},
+		"data":    data,
+	}
+}
+
+// handleFrame acks one frame and routes robot messages. Messages run on
+// their own goroutine so the read loop never stalls behind an agent run.
+func (b *DingTalkBot) handleFrame(ctx context.Context, f dingtalkFrame, write func(any) error) error {
+	topic := f.Headers["topic"]
+	switch f.Type {
+	case "SYSTEM":
+		switch topic {
+		case "ping":
+			return write(dingtalkAck(f, f.Data))
+		case "disconnect":
+			return errors.New("disconnect requested")
+		}
+	case "EVENT":
+		return write(dingtalkAck(f, `{"status":"SUCCESS","message":"success"}`))
+	case "CALLBACK":
+		if err := write(dingtalkAck(f, `{"response":null}`)); err != nil {
+			return err
+		}
+		if topic != dingtalkBotTopic {
+			return nil
+		}
+		var m dingtalkMessage
+		if err := json.Unmarshal([]byte(f.Data), &m); err != nil {
+			log.Printf("[dingtalk] bad robot message: %v", err)
+			return nil
+		}
+		if m.MsgID != "" && b.markSeen(m.MsgID) {
+			return nil
+		}
+		b.spawn(func() { b.handleMessage(ctx, &m) })
+	}
+	return nil
+}
+
+// dialDingTalkStream dials the Stream endpoint through netguard.
+func dialDingTalkStream(ctx context.Context, wsURL string) (*websocket.Conn, error) {
+	if err := netguard.ValidateWebSocketURL(ctx, wsURL); err != nil {
+		return nil, fmt.Errorf("ws endpoint blocked: %w", err)
+	}
+	dialer := *websocket.DefaultDialer
+	dialer.Proxy = nil
+	dialer.NetDialContext = netguard.DialContext
+	conn, _, err := dialer.DialContext(ctx, wsURL, nil)
+	return conn, err
+}
+
+// ── Inbound messages ──────────────────────────────────────────────────────
+
+// dingtalkChatRef converts a message's conversation to a ChatRef.
+func dingtalkChatRef(m *dingtalkMessage) ChatRef {
+	if m.ConversationType == "2" {
+		return ChatRef{ID: m.ConversationID, Type: "group", Title: m.ConversationTitle}
+	}
+	return ChatRef{ID: m.ConversationID, Type: "private"}
+}
+
+// dingtalkSenderID is the sender's staff userid (usable with the OpenAPI
+// and the allowlist), falling back to the opaque senderId for users
+// outside the organization.
+func dingtalkSenderID(m *dingtalkMessage) string {
+	if m.SenderStaffID != "" {
+		return m.SenderStaffID
+	}
+	return m.SenderID
+}
+
+func (b *DingTalkBot) handleMessage(ctx context.Context, m *dingtalkMessage) {
+	senderID := dingtalkSenderID(m)
+	if senderID == "" || m.ConversationID == "" {
+		return
+	}
+	chat := dingtalkChatRef(m)
+	if chat.IsGroup() && !m.IsInAtList {
+		return // groups: only respond when @mentioned
+	}
+	if m.SessionWebhook != "" {
+		b.hooks.Store(m.ConversationID, dingtalkHook{URL: m.SessionWebhook, Expires: time.UnixMilli(m.SessionWebhookExpiredTime)})
+	}
+	if !chat.IsGroup() && m.SenderStaffID != "" {
+		b.peers.Store(m.ConversationID, m.SenderStaffID)
+	}
+
+	pipe := b.pipeline()
+	sessionID := pipe.SessionID(chat)
+	muVal, _ := b.chatMu.LoadOrStore(sessionID, &sync.Mutex{})
+	mu := muVal.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	sender := Sender{ID: senderID, Name: m.SenderNick}
+	if res := pipe.Check(sender, true); res != AccessAllowed {
+		log.Printf("[dingtalk] access %v — user=%s conversation=%s", res, senderID, m.ConversationID)
+		_, _ = b.Send(ctx, chat, b.pairingReply(res, senderID), "")
+		return
+	}
+
+	text, media := b.messageContent(ctx, m)
+	if text == "" && len(media) == 0 {
+		return
+	}
+	log.Printf("[dingtalk] message from user=%s conversation=%s text=%q", senderID, m.ConversationID, truncateStr(text, 60))
+
+	finalText := text
+	if chat.IsGroup() {
+		finalText = fmt.Sprintf("[%s]: %s", m.SenderNick, text)
+	}
+	in := InboundMessage{
+		ChannelType: "dingtalk",
+		ChannelID:   b.channelID,
+		MessageID:   m.MsgID,
+		Chat:        chat,
+		Sender:      sender,
+		Text:        finalText,
+		Media:       media,
+		ExtraContext: []string{fmt.Sprintf("当前钉钉用户信息：userid=%s，昵称=%s，conversation_id=%s",
+			senderID, m.SenderNick, m.ConversationID)},
+	}
+	pipe.LogInbound(in, text)
+	pipe.Dispatch(ctx, in)
+}
+
+// pairingReply guides a sender that is not on the allowlist to the panel.
+func (b *DingTalkBot) pairingReply(res AccessResult, userID string) string {
+	where := "ZyHive 管理面板"
+	if b.panelBaseURL != "" {
+		where = b.panelBaseURL + "/#/agents/" + b.agentID + "/channels"
+	}
+	if res == AccessPairing {
+		return fmt.Sprintf("👋 你好！此机器人尚未完成配对，请管理员在以下地址授权（你的钉钉 userid：`%s`）：\n%s", userID, where)
+	}
+	return fmt.Sprintf("👋 你好！你的申请已收到（钉钉 userid：`%s`），等待管理员在以下地址审核：\n%s", userID, where)
+}
+
+// messageContent extracts the text of a message and downloads its images
+// (and PDF files) as MediaInput; other files become "[📎 name]".
+func (b *DingTalkBot) messageContent(ctx context.Context, m *dingtalkMessage) (string, []MediaInput) {
+	if m.MsgType == "text" || m.MsgType == "" {
+		return strings.TrimSpace(m.Text.Content), nil
+	}
+	var c dingtalkContent
+	_ = json.Unmarshal(m.Content, &c)
+	var media []MediaInput
+	fetch := func(code, name string) bool {
+		if code == "" {
+			return false
+		}
+		data, ct, err := b.downloadFile(ctx, code)
+		if err != nil {
+			log.Printf("[dingtalk] download msg=%s: %v", m.MsgID, err)
+			return false
+		}
+		media = append(media, MediaInput{Data: data, ContentType: ct, FileName: name})
+		return true
+	}
+	switch m.MsgType {
+	case "picture":
+		if fetch(c.DownloadCode, "image.jpg") {
+			return "[📷 图片]", media
+		}
+		return "[📷 图片（下载失败）]", nil
+	case "richText":
+		var parts []string
+		for _, item := range c.RichText {
+			switch {
+			case item.Text != "":
+				parts = append(parts, item.Text)
+			case item.Type == "picture" && len(media) < 5:
+				fetch(item.DownloadCode, "image.jpg")
+			}
+		}
+		return strings.TrimSpace(strings.Join(parts, "")), media
+	case "audio":
+		if c.Recognition != "" {
+			return strings.TrimSpace(c.Recognition), nil
+		}
+		return "[🎤 语音]", nil
+	case "file":
+		ext := strings.ToLower(filepath.Ext(c.FileName))
+		vision := ext == ".pdf" || strings.HasPrefix(mime.TypeByExtension(ext), "image/")
+		if vision && fetch(c.DownloadCode, c.FileName) {
+			return "[📎 " + c.FileName + "]", media
+		}
+		return "[📎 " + c.FileName + "]", nil
+	case "video":
+		return "[🎬 视频]", nil
+	}
+	return "", nil
+}
+
+// downloadFile exchanges a message downloadCode for a temporary URL and
+// fetches the file.
+func (b *DingTalkBot) downloadFile(ctx context.Context, code string) ([]byte, string, error) {
+	var out struct {
+		DownloadURL string `json:"downloadUrl"`
+	}
+	if err := b.api(ctx, "/v1.0/robot/messageFiles/download", map[string]string{"downloadCode": code, "robotCode": b.robotCode}, &out); err != nil {
+		return nil, "", err
+	}
+	if out.DownloadURL == "" {
+		return nil, "", errors.New("empty downloadUrl")
+	}
+	return b.fetch(ctx, out.DownloadURL)
+}
+
+// fetch GETs a (signed) file URL, capped at dingtalkMaxFileBytes.
+func (b *DingTalkBot) fetch(ctx context.Context, fileURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, dingtalkMaxFileBytes))
+	if err != nil {
+		return nil, "", err
+	}
+	ct := resp.Header.Get("Content-Type")
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	if ct == "" || ct == "application/octet-stream" {
+		ct = http.DetectContentType(data)
+		if i := strings.IndexByte(ct, ';'); i >= 0 {
+			ct = ct[:i]
+		}
+	}
+	return data, ct, nil
+}
+
+// fetchAvatar caches a staff member's avatar in the contact book (once
+// per user per process), via /topapi/v2/user/get.
+func (b *DingTalkBot) fetchAvatar(userID, contactID string) {
+	if _, attempted := b.avatars.LoadOrStore(userID, true); attempted || b.agentDir == "" {
+		return
+	}
+	b.spawn(func() {
+		ctx, cancel := context.WithTimeout(b.ctx(), 30*time.Second)
+		defer cancel()
+		var user struct {
+			Avatar string `json:"avatar"`
+		}
+		if err := b.oapi(ctx, "/topapi/v2/user/get", map[string]string{"userid": userID}, &user); err != nil {
+			log.Printf("[dingtalk/avatar] user=%s: %v", userID, err)
+			return
+		}
+		if user.Avatar == "" {
+			return
+		}
+		data, ct, err := b.fetch(ctx, user.Avatar)
+		if err != nil || len(data) > network.MaxAvatarBytes {
+			log.Printf("[dingtalk/avatar] download user=%s: %v", userID, err)
+			return
+		}
+		store := network.NewStore(filepath.Join(b.agentDir, "workspace"))
+		if err := store.SaveAvatar(contactID, data, ct); err != nil {
+			log.Printf("[dingtalk/avatar] save user=%s: %v", userID, err)
+		}
+	})
+}
+
+// ── Outbound ──────────────────────────────────────────────────────────────
+
+// dingtalkMarkdown returns the title (first line, shown in notifications)
+// and the clipped body of a markdown message.
+func dingtalkMarkdown(text string) (string, string) {
+	title, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	title = strings.TrimLeft(title, "#> *")
+	if title == "" {
+		title = "新消息"
+	}
+	if len(text) > dingtalkMaxContent {
+		// ToValidUTF8 drops a rune split by the byte cut.
+		text = strings.ToValidUTF8(text[:dingtalkMaxContent], "") + "…"
+	}
+	return truncateStr(title, 20), text
+}
+
+// replyHook returns the conversation's sessionWebhook while it is valid.
+func (b *DingTalkBot) replyHook(conversationID string) (string, bool) {
+	v, ok := b.hooks.Load(conversationID)
+	if !ok {
+		return "", false
+	}
+	h := v.(dingtalkHook)
+	if time.Now().Add(time.Minute).After(h.Expires) {
+		b.hooks.Delete(conversationID)
+		return "", false
+	}
+	return h.URL, true
+}
+
+// sendWebhook posts a markdown reply to a sessionWebhook.
+func (b *DingTalkBot) sendWebhook(ctx context.Context, hookURL, text string) error {
+	title, body := dingtalkMarkdown(text)
+	var out struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	msg := map[string]any{"msgtype": "markdown", "markdown": map[string]string{"title": title, "text": body}}
+	if err := b.post(ctx, hookURL, "", msg, &out); err != nil {
+		return err
+	}
+	if out.ErrCode != 0 {
+		return fmt.Errorf("dingtalk webhook: %d %s", out.ErrCode, out.ErrMsg)
+	}
+	return nil
+}
+
+// sendRobot sends a robot message through the OpenAPI: to a group
+// (openConversationId) or to users (batchSend). msgKey is a DingTalk
+// message template, e.g. sampleMarkdown or sampleFile.
+func (b *DingTalkBot) sendRobot(ctx context.Context, chat ChatRef, userIDs []string, msgKey string, param any) (string, error) {
+	p, _ := json.Marshal(param)
+	body := map[string]any{"robotCode": b.robotCode, "msgKey": msgKey, "msgParam": string(p)}
+	var out struct {
+		ProcessQueryKey string `json:"processQueryKey"`
+	}
+	path := "/v1.0/robot/oToMessages/batchSend"
+	if chat.IsGroup() {
+		path = "/v1.0/robot/groupMessages/send"
+		body["openConversationId"] = chat.ID
+	} else {
+		body["userIds"] = userIDs
+	}
+	if err := b.api(ctx, path, body, &out); err != nil {
+		return "", err
+	}
+	return out.ProcessQueryKey, nil
+}
+
+// chatUsers resolves a single chat to the staff userid to send to: the
+// peer seen in that conversation, else chat.ID itself (a userid, as
+// passed by ProactiveSend and notify).
+func (b *DingTalkBot) chatUsers(chat ChatRef) []string {
+	if v, ok := b.peers.Load(chat.ID); ok {
+		return []string{v.(string)}
+	}
+	return []string{chat.ID}
+}
+
+// uploadMedia uploads a file (oapi /media/upload) and returns its media id.
+func (b *DingTalkBot) uploadMedia(ctx context.Context, path, kind string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("media", filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	tok, err := b.accessToken(ctx)
+	if err != nil {
+		return "", err
+	}
+	u := b.oapiBase + "/media/upload?type=" + kind + "&access_token=" + url.QueryEscape(tok)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, &buf)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var out struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		MediaID string `json:"media_id"`
+	}
+	if err := b.do(req, &out); err != nil {
+		return "", err
+	}
+	if out.ErrCode != 0 {
+		return "", fmt.Errorf("dingtalk media/upload: %d %s", out.ErrCode, out.ErrMsg)
+	}
+	return out.MediaID, nil
+}
+
+// ── HTTP helpers ──────────────────────────────────────────────────────────
+
+// accessToken returns the cached app access token, refreshing it 5
+// minutes before expiry.
+func (b *DingTalkBot) accessToken(ctx context.Context) (string, error) {
+	b.tokMu.Lock()
+	defer b.tokMu.Unlock()
+	if b.token != "" && time.Now().Before(b.tokenExp) {
+		return b.token, nil
+	}
+	var out struct {
+		AccessToken string `json:"accessToken"`
+		ExpireIn    int    `json:"expireIn"`
+	}
+	body := map[string]string{"appKey": b.appKey, "appSecret": b.appSecret}
+	if err := b.post(ctx, b.apiBase+"/v1.0/oauth2/accessToken", "", body, &out); err != nil {
+		return "", fmt.Errorf("accessToken: %w", err)
+	}
+	if out.AccessToken == "" {
+		return "", errors.New("accessToken: empty token")
+	}
+	if out.ExpireIn <= 0 {
+		out.ExpireIn = 7200
+	}
+	b.token = out.AccessToken
+	b.tokenExp = time.Now().Add(time.Duration(out.ExpireIn-300) * time.Second)
+	return b.token, nil
+}
+
+// api POSTs to the v1.0 OpenAPI with the access token header.
+func (b *DingTalkBot) api(ctx context.Context, path string, body, out any) error {
+	tok, err := b.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	return b.post(ctx, b.apiBase+path, tok, body, out)
+}
+
+// oapi POSTs to the legacy oapi host and unwraps {errcode, errmsg, result}.
+func (b *DingTalkBot) oapi(ctx context.Context, path string, body, out any) error {
+	tok, err := b.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		ErrCode int             `json:"errcode"`
+		ErrMsg  string          `json:"errmsg"`
+		Result  json.RawMessage `json:"result"`
+	}
+	if err := b.post(ctx, b.oapiBase+path+"?access_token="+url.QueryEscape(tok), "", body, &resp); err != nil {
+		return err
+	}
+	if resp.ErrCode != 0 {
+		return fmt.Errorf("dingtalk %s: %d %s", path, resp.ErrCode, resp.ErrMsg)
+	}
+	if out != nil && len(resp.Result) > 0 {
+		return json.Unmarshal(resp.Result, out)
+	}
+	return nil
+}
+
+// post sends a JSON body (with the access token header when tok is set)
+// and decodes the response into out.
+func (b *DingTalkBot) post(ctx context.Context, u, tok string, body, out any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if tok != "" {
+		req.Header.Set("x-acs-dingtalk-access-token", tok)
+	}
+	return b.do(req, out)
+}
+
+func (b *DingTalkBot) do(req *http.Request, out any) error {
+	where := req.URL.Path
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("dingtalk %s: %w", where, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("dingtalk %s: %w", where, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(raw, &apiErr) == nil && apiErr.Message != "" {
+			return fmt.Errorf("dingtalk %s: HTTP %d: %s (%s)", where, resp.StatusCode, apiErr.Message, apiErr.Code)
+		}
+		return fmt.Errorf("dingtalk %s: HTTP %d: %s", where, resp.StatusCode, truncateStr(string(raw), 200))
+	}
+	if out != nil && len(raw) > 0 {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// TestDingTalkBot verifies the app credentials and that Stream mode is
+// enabled for the app; returns the appKey.
+func TestDingTalkBot(ctx context.Context, appKey, appSecret string) (string, error) {
+	b := NewDingTalkBotWithStream(appKey, appSecret, "", "", "", "", nil, nil, nil)
+	b.client = netguard.NewSafeClient(8 * time.Second)
+	if _, err := b.accessToken(ctx); err != nil {
+		return "", err
+	}
+	if _, err := b.openStream(ctx); err != nil {
+		return "", err
+	}
+	return appKey, nil
+}
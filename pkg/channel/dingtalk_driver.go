@@ -0,0 +1,162 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DingTalkBot implements Driver and Notifier.
+var (
+	_ Driver   = (*DingTalkBot)(nil)
+	_ Notifier = (*DingTalkBot)(nil)
+)
+
+// Staff userids are strings, so DingTalk keeps its pending / approved
+// users in the string-keyed stores.
+func init() {
+	RegisterDriver(DriverSpec{
+		Type:      "dingtalk",
+		Required:  []string{"appKey", "appSecret"},
+		UniqueKey: "appKey",
+		New:       newDingTalkDriver,
+		Test: func(ctx context.Context, cfg map[string]string) (string, error) {
+			return TestDingTalkBot(ctx, cfg["appKey"], cfg["appSecret"])
+		},
+	})
+}
+
+func newDingTalkDriver(env DriverEnv) (Driver, error) {
+	var pending *PendingStoreStr
+	if dir := env.PendingDir(); dir != "" {
+		pending = NewPendingStoreStr(dir, env.ChannelID)
+	}
+	getAllowFrom := env.AllowFrom
+	if getAllowFrom == nil {
+		getAllowFrom = func() []string { return nil }
+	}
+	bot := NewDingTalkBotWithStream(env.Config["appKey"], env.Config["appSecret"], env.Config["robotCode"],
+		env.AgentID, env.AgentDir, env.ChannelID, getAllowFrom, env.Stream, pending)
+	bot.SetPanelBaseURL(env.PanelBaseURL)
+	bot.SetOnConnected(env.OnConnected)
+	return bot, nil
+}
+
+// pipeline returns the shared message path bound to this bot.
+func (b *DingTalkBot) pipeline() *Pipeline {
+	return &Pipeline{
+		Env: DriverEnv{
+			AgentID:      b.agentID,
+			AgentDir:     b.agentDir,
+			ChannelID:    b.channelID,
+			Stream:       b.streamFunc,
+			AllowFrom:    b.getAllowFrom,
+			PanelBaseURL: b.panelBaseURL,
+		},
+		Driver:       b,
+		Pending:      b.pendingStore.Recorder(),
+		OnNewContact: b.fetchAvatar,
+	}
+}
+
+// Type implements Driver.
+func (b *DingTalkBot) Type() string { return "dingtalk" }
+
+// Capabilities implements Driver. Robot messages cannot be edited, so
+// each reply is sent once, complete (split at the markdown size limit).
+func (b *DingTalkBot) Capabilities() Capabilities {
+	return Capabilities{Files: true, MaxText: 5000}
+}
+
+// Send implements Driver: a markdown message through the conversation's
+// sessionWebhook while it is valid, else through the robot OpenAPI.
+// replyTo is not used (robot messages cannot quote).
+func (b *DingTalkBot) Send(ctx context.Context, chat ChatRef, text, _ string) (string, error) {
+	if hook, ok := b.replyHook(chat.ID); ok {
+		return "", b.sendWebhook(ctx, hook, text)
+	}
+	title, body := dingtalkMarkdown(text)
+	return b.sendRobot(ctx, chat, b.chatUsers(chat), "sampleMarkdown", map[string]string{"title": title, "text": body})
+}
+
+// Edit implements Driver (unsupported).
+func (b *DingTalkBot) Edit(ctx context.Context, chat ChatRef, msgID, text string) error {
+	return errors.New("dingtalk: edit not supported")
+}
+
+// Typing implements Driver (no-op; robots have no typing indicator).
+func (b *DingTalkBot) Typing(ctx context.Context, chat ChatRef) error { return nil }
+
+// SendFile implements Driver: upload, then a sampleFile robot message.
+func (b *DingTalkBot) SendFile(ctx context.Context, chat ChatRef, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	mediaID, err := b.uploadMedia(ctx, path, "file")
+	if err != nil {
+		return "", err
+	}
+	name := filepath.Base(path)
+	fileType := strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+	param := map[string]string{"mediaId": mediaID, "fileName": name, "fileType": fileType}
+	if _, err := b.sendRobot(ctx, chat, b.chatUsers(chat), "sampleFile", param); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("✅ 文件 %s 已发送（%d 字节）", name, info.Size()), nil
+}
+
+// ProactiveSend implements Driver: one batchSend to every allowlisted
+// userid (at most 20 per call).
+func (b *DingTalkBot) ProactiveSend(text string) error {
+	users := b.ProactiveRecipients()
+	if len(users) == 0 {
+		return errors.New("dingtalk: no recipient in allowedFrom")
+	}
+	var lastErr error
+	for len(users) > 0 {
+		n := min(len(users), 20)
+		if err := b.sendMarkdownTo(b.ctx(), users[:n], text); err != nil {
+			lastErr = err
+		}
+		users = users[n:]
+	}
+	return lastErr
+}
+
+// ProactiveRecipients implements RecipientSender: the allowlisted userids.
+func (b *DingTalkBot) ProactiveRecipients() []string {
+	var users []string
+	for _, id := range b.getAllowFrom() {
+		if id = strings.TrimSpace(id); id != "" {
+			users = append(users, id)
+		}
+	}
+	return users
+}
+
+// ProactiveSendTo implements RecipientSender.
+func (b *DingTalkBot) ProactiveSendTo(ctx context.Context, userID, text string) error {
+	return b.sendMarkdownTo(ctx, []string{userID}, text)
+}
+
+// sendMarkdownTo posts text as one markdown batchSend to users.
+func (b *DingTalkBot) sendMarkdownTo(ctx context.Context, users []string, text string) error {
+	title, body := dingtalkMarkdown(text)
+	param := map[string]string{"title": title, "text": body}
+	_, err := b.sendRobot(ctx, ChatRef{Type: "private"}, users, "sampleMarkdown", param)
+	return err
+}
+
+// Notify runs the agent on prompt in the chat's session and posts the
+// reply. A chat without Type is a single chat (chat.ID a conversationId
+// seen before, or a userid).
+func (b *DingTalkBot) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	if chat.Type == "" {
+		chat.Type = "private"
+	}
+	return b.pipeline().Notify(ctx, chat, prompt)
+}
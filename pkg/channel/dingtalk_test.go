@@ -0,0 +1,297 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeDingTalk is a minimal DingTalk OpenAPI + Stream endpoint stand-in.
+type fakeDingTalk struct {
+	t   *testing.T
+	srv *httptest.Server
+	mu  sync.Mutex
+	// calls records HTTP requests as "METHOD /path" with decoded JSON bodies.
+	calls []discordCall
+	// streamIn receives every frame the bot writes; frames are pushed to it.
+	streamIn chan map[string]any
+	frames   chan string
+	ticket   chan string
+}
+
+func newFakeDingTalk(t *testing.T) *fakeDingTalk {
+	f := &fakeDingTalk{t: t, streamIn: make(chan map[string]any, 16), frames: make(chan string, 8), ticket: make(chan string, 4)}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeDingTalk) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/stream" {
+		f.ticket <- r.URL.Query().Get("ticket")
+		f.serveStream(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/file/") {
+		w.Header().Set("Content-Type", "image/png")
+		_, _ = io.WriteString(w, "PNGDATA")
+		return
+	}
+	route := r.Method + " " + r.URL.Path
+	body := map[string]any{}
+	raw, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(raw, &body)
+	if tok := r.Header.Get("x-acs-dingtalk-access-token"); tok != "" {
+		body["_token"] = tok
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, discordCall{Route: route, Body: body})
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch route {
+	case "POST /v1.0/oauth2/accessToken":
+		_, _ = io.WriteString(w, `{"accessToken":"AT","expireIn":7200}`)
+	case "POST /v1.0/gateway/connections/open":
+		_, _ = io.WriteString(w, `{"endpoint":"ws`+strings.TrimPrefix(f.srv.URL, "http")+`/stream","ticket":"tk 1"}`)
+	case "POST /v1.0/robot/messageFiles/download":
+		_, _ = io.WriteString(w, `{"downloadUrl":"`+f.srv.URL+`/file/`+body["downloadCode"].(string)+`"}`)
+	case "POST /v1.0/robot/oToMessages/batchSend", "POST /v1.0/robot/groupMessages/send":
+		_, _ = io.WriteString(w, `{"processQueryKey":"pq"}`)
+	case "POST /topapi/v2/user/get":
+		_, _ = io.WriteString(w, `{"errcode":0,"result":{"name":"Alice"}}`)
+	default:
+		_, _ = io.WriteString(w, `{"errcode":0,"errmsg":"ok"}`)
+	}
+}
+
+func (f *fakeDingTalk) serveStream(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var m map[string]any
+			if err := conn.ReadJSON(&m); err != nil {
+				return
+			}
+			f.streamIn <- m
+		}
+	}()
+	for {
+		select {
+		case frame := <-f.frames:
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// ack returns the bot's reply to the frame with messageId id.
+func (f *fakeDingTalk) ack(id string) map[string]any {
+	f.t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case m := <-f.streamIn:
+			if h, _ := m["headers"].(map[string]any); h["messageId"] == id {
+				return m
+			}
+		case <-timeout:
+			f.t.Fatalf("no ack for %s", id)
+		}
+	}
+}
+
+// wait returns the calls once one matches route (or fails after 3s).
+func (f *fakeDingTalk) wait(route string) (discordCall, []discordCall) {
+	f.t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		f.mu.Lock()
+		calls := append([]discordCall(nil), f.calls...)
+		f.mu.Unlock()
+		for _, c := range calls {
+			if c.Route == route {
+				return c, calls
+			}
+		}
+		if time.Now().After(deadline) {
+			f.t.Fatalf("no %s; calls = %+v", route, calls)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestDingTalkBot(t *testing.T, f *fakeDingTalk, allow []string, runs chan<- testRun) *DingTalkBot {
+	t.Helper()
+	stream := func(_ context.Context, _, text, sessionID string, media []MediaInput, _ FileSenderFunc, _ ...string) (<-chan StreamEvent, error) {
+		if runs != nil {
+			runs <- testRun{session: sessionID, text: text, media: media}
+		}
+		return streamOf(StreamEvent{Type: "text_delta", Text: "## 好的\n细节"}, StreamEvent{Type: "done"}), nil
+	}
+	b := NewDingTalkBotWithStream("ak", "as", "", "a1", t.TempDir(), "dingtalk-1", func() []string { return allow }, stream, nil)
+	b.apiBase = f.srv.URL
+	b.oapiBase = f.srv.URL
+	b.client = f.srv.Client()
+	b.dialWS = func(ctx context.Context, wsURL string) (*websocket.Conn, error) {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		return conn, err
+	}
+	t.Cleanup(b.inflight.Wait) // runs write into agentDir until they finish
+	return b
+}
+
+// dingtalkCallback wraps a robot message into a Stream CALLBACK frame.
+func dingtalkCallback(id, msg string) string {
+	data, _ := json.Marshal(msg)
+	return `{"specVersion":"1.0","type":"CALLBACK","headers":{"messageId":"` + id +
+		`","topic":"/v1.0/im/bot/messages/get","contentType":"application/json"},"data":` + string(data) + `}`
+}
+
+func TestDingTalkStreamAckAndGroupGating(t *testing.T) {
+	f := newFakeDingTalk(t)
+	runs := make(chan testRun, 4)
+	b := newTestDingTalkBot(t, f, []string{"staff1"}, runs)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() { errs <- b.runOnce(ctx) }()
+	if tk := <-f.ticket; tk != "tk 1" {
+		t.Errorf("ticket = %q", tk)
+	}
+	open, _ := f.wait("POST /v1.0/gateway/connections/open")
+	if open.Body["clientId"] != "ak" || open.Body["clientSecret"] != "as" {
+		t.Errorf("connections/open = %+v", open.Body)
+	}
+
+	f.frames <- `{"specVersion":"1.0","type":"SYSTEM","headers":{"messageId":"p1","topic":"ping"},"data":"{\"opaque\":\"x\"}"}`
+	if ack := f.ack("p1"); ack["code"] != float64(200) || ack["data"] != `{"opaque":"x"}` {
+		t.Errorf("ping ack = %+v", ack)
+	}
+
+	hook := f.srv.URL + "/hook/g1"
+	expires := time.Now().Add(time.Hour).UnixMilli()
+	group := func(msgID string, at bool) string {
+		m, _ := json.Marshal(map[string]any{
+			"msgId": msgID, "msgtype": "text", "text": map[string]string{"content": " 帮我看看"},
+			"conversationId": "cidG", "conversationType": "2", "conversationTitle": "研发群",
+			"senderStaffId": "staff1", "senderNick": "Alice", "isInAtList": at,
+			"sessionWebhook": hook, "sessionWebhookExpiredTime": expires,
+		})
+		return string(m)
+	}
+	// Not @mentioned: acked, but ignored.
+	f.frames <- dingtalkCallback("c1", group("m1", false))
+	if ack := f.ack("c1"); ack["data"] != `{"response":null}` {
+		t.Errorf("callback ack = %+v", ack)
+	}
+	f.frames <- dingtalkCallback("c2", group("m2", true))
+	f.ack("c2")
+	run := <-runs
+	if run.session != "dingtalk-cidG" || run.text != "[Alice]: 帮我看看" {
+		t.Errorf("group run = %+v", run)
+	}
+	reply, _ := f.wait("POST /hook/g1")
+	md, _ := reply.Body["markdown"].(map[string]any)
+	if reply.Body["msgtype"] != "markdown" || md["title"] != "好的" || md["text"] != "## 好的\n细节" {
+		t.Errorf("webhook reply = %+v", reply.Body)
+	}
+	// A redelivered callback (same msgId) is not handled twice.
+	f.frames <- dingtalkCallback("c3", group("m2", true))
+	f.ack("c3")
+
+	f.frames <- `{"specVersion":"1.0","type":"SYSTEM","headers":{"messageId":"d1","topic":"disconnect"},"data":""}`
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "disconnect") {
+		t.Fatalf("runOnce after disconnect = %v", err)
+	}
+	b.inflight.Wait()
+	select {
+	case extra := <-runs:
+		t.Errorf("unexpected extra run %+v", extra)
+	default:
+	}
+}
+
+func TestDingTalkMediaFallbackAndPairing(t *testing.T) {
+	f := newFakeDingTalk(t)
+	runs := make(chan testRun, 4)
+	b := newTestDingTalkBot(t, f, []string{"staff1", "staff2"}, runs)
+	b.pendingStore = NewPendingStoreStr(t.TempDir(), "dingtalk-1")
+	ctx := context.Background()
+
+	// A picture in a single chat whose sessionWebhook has expired: the
+	// image is downloaded and the reply goes through batchSend.
+	b.handleMessage(ctx, &dingtalkMessage{
+		MsgID: "m1", MsgType: "picture", Content: json.RawMessage(`{"downloadCode":"dc1"}`),
+		ConversationID: "cid1", ConversationType: "1", SenderStaffID: "staff1", SenderNick: "Alice",
+		SessionWebhook: f.srv.URL + "/hook/old", SessionWebhookExpiredTime: time.Now().Add(-time.Minute).UnixMilli(),
+	})
+	run := <-runs
+	if run.session != "dingtalk-cid1" || len(run.media) != 1 || string(run.media[0].Data) != "PNGDATA" || run.media[0].ContentType != "image/png" {
+		t.Errorf("picture run = %+v", run)
+	}
+	dl, _ := f.wait("POST /v1.0/robot/messageFiles/download")
+	if dl.Body["robotCode"] != "ak" || dl.Body["_token"] != "AT" {
+		t.Errorf("download = %+v", dl.Body)
+	}
+	send, _ := f.wait("POST /v1.0/robot/oToMessages/batchSend")
+	if ids, _ := send.Body["userIds"].([]any); len(ids) != 1 || ids[0] != "staff1" || send.Body["msgKey"] != "sampleMarkdown" {
+		t.Errorf("batchSend = %+v", send.Body)
+	}
+
+	// A stranger is recorded as pending and told their userid.
+	b.handleMessage(ctx, &dingtalkMessage{
+		MsgID: "m2", MsgType: "text", ConversationID: "cidG", ConversationType: "2", IsInAtList: true,
+		SenderStaffID: "staff9", SenderNick: "Mallory",
+	})
+	group, _ := f.wait("POST /v1.0/robot/groupMessages/send")
+	if group.Body["openConversationId"] != "cidG" || !strings.Contains(group.Body["msgParam"].(string), "staff9") {
+		t.Errorf("pairing reply = %+v", group.Body)
+	}
+	if p := b.pendingStore.List(); len(p) != 1 || p[0].ID != "staff9" {
+		t.Errorf("pending = %+v", p)
+	}
+
+	// ProactiveSend batches every allowlisted user into one call.
+	if err := b.ProactiveSend("早报"); err != nil {
+		t.Fatal(err)
+	}
+	_, calls := f.wait("POST /v1.0/robot/oToMessages/batchSend")
+	var last discordCall
+	for _, c := range calls {
+		if c.Route == "POST /v1.0/robot/oToMessages/batchSend" {
+			last = c
+		}
+	}
+	if ids, _ := last.Body["userIds"].([]any); len(ids) != 2 {
+		t.Errorf("proactive batchSend = %+v", last.Body)
+	}
+	// The access token was fetched once and reused.
+	n := 0
+	for _, c := range calls {
+		if c.Route == "POST /v1.0/oauth2/accessToken" {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("accessToken calls = %d", n)
+	}
+}
@@ -0,0 +1,903 @@
+// Package channel — Discord bot integration.
+//   - Gateway websocket (v10): heartbeat with zombie detection, RESUME after
+//     drops, intents GUILDS | GUILD_MESSAGES | DIRECT_MESSAGES | MESSAGE_CONTENT
+//   - REST API for sends, edits (streamed drafts), typing and file uploads
+//   - DMs always answered; guild channels only when @mentioned or replied to
+//   - Threads map to sessions: "discord-{parentChannel}-{thread}"
+//   - Allowlist entries: "{user}" (anywhere), "{guild}/{user}" (one guild),
+//     "guild:{guild}" (everyone in a guild) — see discord_driver.go
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+	"github.com/Zyling-ai/zyhive/pkg/network"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	discordAPIBase = "https://discord.com/api/v10"
+	discordCDNBase = "https://cdn.discordapp.com"
+	// discordMaxFileBytes caps one downloaded attachment.
+	discordMaxFileBytes = 20 << 20
+	// discordMaxContent is Discord's per-message content limit (characters).
+	discordMaxContent = 2000
+)
+
+// Gateway opcodes.
+const (
+	discordOpDispatch       = 0
+	discordOpHeartbeat      = 1
+	discordOpIdentify       = 2
+	discordOpResume         = 6
+	discordOpReconnect      = 7
+	discordOpInvalidSession = 9
+	discordOpHello          = 10
+	discordOpHeartbeatAck   = 11
+)
+
+// discordIntents = GUILDS | GUILD_MESSAGES | DIRECT_MESSAGES | MESSAGE_CONTENT.
+// MESSAGE_CONTENT is privileged: it must be enabled in the developer portal.
+const discordIntents = 1<<0 | 1<<9 | 1<<12 | 1<<15
+
+// Channel types that are threads (announcement / public / private).
+const (
+	discordChanAnnouncementThread = 10
+	discordChanPublicThread       = 11
+	discordChanPrivateThread      = 12
+)
+
+// errDiscordFatal marks gateway closes that reconnecting will not fix
+// (bad token, disallowed intents).
+var errDiscordFatal = errors.New("discord: fatal gateway close")
+
+// ── Discord API types ─────────────────────────────────────────────────────
+
+type discordPayload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s"`
+	T  string          `json:"t"`
+}
+
+type discordUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name"`
+	Avatar     string `json:"avatar"`
+	Bot        bool   `json:"bot"`
+}
+
+type discordAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
+
+type discordMessage struct {
+	ID        string      `json:"id"`
+	ChannelID string      `json:"channel_id"`
+	GuildID   string      `json:"guild_id"`
+	Type      int         `json:"type"` // 0 default, 19 reply
+	Author    discordUser `json:"author"`
+	Member    *struct {
+		Nick string `json:"nick"`
+	} `json:"member"`
+	Content           string              `json:"content"`
+	Mentions          []discordUser       `json:"mentions"`
+	Attachments       []discordAttachment `json:"attachments"`
+	ReferencedMessage *struct {
+		Author discordUser `json:"author"`
+	} `json:"referenced_message"`
+}
+
+type discordChannel struct {
+	ID       string `json:"id"`
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	ParentID string `json:"parent_id"`
+	GuildID  string `json:"guild_id"`
+}
+
+func (c discordChannel) isThread() bool {
+	switch c.Type {
+	case discordChanAnnouncementThread, discordChanPublicThread, discordChanPrivateThread:
+		return true
+	}
+	return false
+}
+
+type discordGuild struct {
+	ID       string           `json:"id"`
+	Name     string           `json:"name"`
+	Channels []discordChannel `json:"channels"`
+	Threads  []discordChannel `json:"threads"`
+}
+
+// ── DiscordBot ────────────────────────────────────────────────────────────
+
+type DiscordBot struct {
+	token        string
+	agentID      string
+	agentDir     string
+	channelID    string
+	getAllowFrom func() []string
+
+	streamFunc   StreamFunc
+	pendingStore *PendingStoreStr
+	panelBaseURL string
+	onConnected  func(name string)
+
+	apiBase string
+	cdnBase string
+	client  *http.Client
+	// dialWS connects to the gateway (tests swap in a plain dialer).
+	dialWS func(ctx context.Context, wsURL string) (*websocket.Conn, error)
+
+	identMu   sync.RWMutex
+	botUserID string
+	botName   string
+
+	runMu  sync.Mutex
+	runCtx context.Context
+
+	// Gateway resume state: a dropped connection resumes the session (and
+	// replays missed events) instead of identifying again.
+	gwMu       sync.Mutex
+	gatewayURL string
+	sessionID  string
+	resumeURL  string
+	seq        atomic.Int64
+
+	cacheMu  sync.RWMutex
+	channels map[string]discordChannel
+	guilds   map[string]string // guild id → name
+
+	// avatars maps user id → avatar hash, fed by message authors.
+	avatars sync.Map
+	// chatMu serializes processing per session to avoid concurrent runs.
+	chatMu sync.Map
+	// inflight tracks message handlers; Start returns once they finish.
+	inflight sync.WaitGroup
+}
+
+// NewDiscordBotWithStream creates a DiscordBot.
+func NewDiscordBotWithStream(token, agentID, agentDir, channelID string, getAllowFrom func() []string, sf StreamFunc, pending *PendingStoreStr) *DiscordBot {
+	return &DiscordBot{
+		token:        token,
+		agentID:      agentID,
+		agentDir:     agentDir,
+		channelID:    channelID,
+		getAllowFrom: getAllowFrom,
+		streamFunc:   sf,
+		pendingStore: pending,
+		apiBase:      discordAPIBase,
+		cdnBase:      discordCDNBase,
+		client:       netguard.NewSafeClient(15 * time.Second),
+		dialWS:       dialDiscordGateway,
+		runCtx:       context.Background(),
+		channels:     make(map[string]discordChannel),
+		guilds:       make(map[string]string),
+	}
+}
+
+// SetOnConnected sets a callback fired once the token is verified.
+func (b *DiscordBot) SetOnConnected(fn func(name string)) {
+	b.onConnected = fn
+}
+
+// SetPanelBaseURL sets the ZyHive panel URL shown in pairing messages.
+func (b *DiscordBot) SetPanelBaseURL(url string) {
+	b.panelBaseURL = url
+}
+
+// Start verifies the token, then keeps a gateway connection up until ctx
+// is cancelled, resuming the session after drops.
+func (b *DiscordBot) Start(ctx context.Context) {
+	log.Printf("[discord] starting agent=%s", b.agentID)
+	b.runMu.Lock()
+	b.runCtx = ctx
+	b.runMu.Unlock()
+	defer b.inflight.Wait()
+
+	for {
+		if err := b.identify(ctx); err == nil {
+			break
+		} else {
+			log.Printf("[discord] /users/@me error: %v — retrying in 5s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		wait := 5 * time.Second
+		if err := b.runOnce(ctx); err != nil {
+			if errors.Is(err, errDiscordFatal) {
+				wait = time.Minute
+			}
+			log.Printf("[discord] gateway error: %v — reconnecting in %s", err, wait)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// identify fetches the bot user (GET /users/@me).
+func (b *DiscordBot) identify(ctx context.Context) error {
+	var me discordUser
+	if err := b.rest(ctx, http.MethodGet, "/users/@me", nil, &me); err != nil {
+		return err
+	}
+	b.identMu.Lock()
+	b.botUserID, b.botName = me.ID, me.Username
+	b.identMu.Unlock()
+	log.Printf("[discord] bot id=%s name=%s", me.ID, me.Username)
+	if b.onConnected != nil {
+		b.onConnected(me.Username)
+	}
+	return nil
+}
+
+func (b *DiscordBot) selfID() string {
+	b.identMu.RLock()
+	defer b.identMu.RUnlock()
+	return b.botUserID
+}
+
+func (b *DiscordBot) ctx() context.Context {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+	return b.runCtx
+}
+
+// ── Gateway ───────────────────────────────────────────────────────────────
+
+// gatewayEndpoint returns the URL to dial and whether to RESUME there.
+func (b *DiscordBot) gatewayEndpoint(ctx context.Context) (string, bool, error) {
+	b.gwMu.Lock()
+	defer b.gwMu.Unlock()
+	if b.sessionID != "" && b.resumeURL != "" {
+		return b.resumeURL, true, nil
+	}
+	if b.gatewayURL == "" {
+		var out struct {
+			URL string `json:"url"`
+		}
+		if err := b.rest(ctx, http.MethodGet, "/gateway/bot", nil, &out); err != nil {
+			return "", false, fmt.Errorf("gateway/bot: %w", err)
+		}
+		b.gatewayURL = out.URL
+	}
+	return b.gatewayURL, false, nil
+}
+
+// resetSession forgets the resume state so the next connect identifies.
+func (b *DiscordBot) resetSession() {
+	b.gwMu.Lock()
+	b.sessionID, b.resumeURL = "", ""
+	b.gwMu.Unlock()
+	b.seq.Store(0)
+}
+
+// discordConn serializes writes to one gateway connection (heartbeats
+// run on their own goroutine).
+type discordConn struct {
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (c *discordConn) send(op int, d any) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	return c.conn.WriteJSON(map[string]any{"op": op, "d": d})
+}
+
+// runOnce holds one gateway connection until it drops. A nil error means
+// ctx was cancelled.
+func (b *DiscordBot) runOnce(ctx context.Context) error {
+	endpoint, resume, err := b.gatewayEndpoint(ctx)
+	if err != nil {
+		return err
+	}
+	ws, err := b.dialWS(ctx, strings.TrimRight(endpoint, "/")+"/?v=10&encoding=json")
+	if err != nil {
+		return fmt.Errorf("ws dial: %w", err)
+	}
+	defer ws.Close()
+	conn := &discordConn{conn: ws}
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.mu.Lock()
+		_ = ws.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		conn.mu.Unlock()
+		_ = ws.Close()
+	})
+	defer stop()
+
+	// The first frame is Hello with the heartbeat interval.
+	var hello discordPayload
+	if err := ws.ReadJSON(&hello); err != nil {
+		return fmt.Errorf("read hello: %w", err)
+	}
+	if hello.Op != discordOpHello {
+		return fmt.Errorf("expected hello, got op %d", hello.Op)
+	}
+	var hd struct {
+		HeartbeatInterval int64 `json:"heartbeat_interval"`
+	}
+	_ = json.Unmarshal(hello.D, &hd)
+	if hd.HeartbeatInterval <= 0 {
+		return errors.New("hello without heartbeat_interval")
+	}
+
+	if resume {
+		b.gwMu.Lock()
+		sid := b.sessionID
+		b.gwMu.Unlock()
+		err = conn.send(discordOpResume, map[string]any{"token": b.token, "session_id": sid, "seq": b.seq.Load()})
+	} else {
+		err = conn.send(discordOpIdentify, map[string]any{
+			"token":   b.token,
+			"intents": discordIntents,
+			"properties": map[string]string{
+				"os": "linux", "browser": "zyhive", "device": "zyhive",
+			},
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("identify: %w", err)
+	}
+
+	hbCtx, hbCancel := context.WithCancel(ctx)
+	defer hbCancel()
+	var acked atomic.Bool
+	acked.Store(true)
+	go b.heartbeat(hbCtx, conn, time.Duration(hd.HeartbeatInterval)*time.Millisecond, &acked)
+
+	for {
+		var p discordPayload
+		if err := ws.ReadJSON(&p); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return b.closeError(err)
+		}
+		if p.S != nil {
+			b.seq.Store(*p.S)
+		}
+		switch p.Op {
+		case discordOpDispatch:
+			b.handleDispatch(ctx, p.T, p.D)
+		case discordOpHeartbeat:
+			if err := conn.send(discordOpHeartbeat, b.seqValue()); err != nil {
+				return fmt.Errorf("heartbeat: %w", err)
+			}
+		case discordOpHeartbeatAck:
+			acked.Store(true)
+		case discordOpReconnect:
+			return errors.New("reconnect requested")
+		case discordOpInvalidSession:
+			var resumable bool
+			_ = json.Unmarshal(p.D, &resumable)
+			if !resumable {
+				b.resetSession()
+			}
+			// Discord asks for a random 1–5s pause before re-identifying.
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Second + rand.N(4*time.Second)):
+			}
+			return fmt.Errorf("invalid session (resumable=%v)", resumable)
+		}
+	}
+}
+
+// heartbeat sends op 1 every interval (the first one after a random
+// fraction of it). A beat without an ACK for the previous one means the
+// connection is a zombie: close it so runOnce reconnects and resumes.
+func (b *DiscordBot) heartbeat(ctx context.Context, conn *discordConn, interval time.Duration, acked *atomic.Bool) {
+	timer := time.NewTimer(time.Duration(rand.Float64() * float64(interval)))
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if !acked.Swap(false) {
+			log.Printf("[discord] heartbeat not acknowledged — reconnecting agent=%s", b.agentID)
+			_ = conn.conn.Close()
+			return
+		}
+		if err := conn.send(discordOpHeartbeat, b.seqValue()); err != nil {
+			return
+		}
+		timer.Reset(interval)
+	}
+}
+
+// seqValue is the heartbeat payload: the last sequence number or null.
+func (b *DiscordBot) seqValue() any {
+	if s := b.seq.Load(); s > 0 {
+		return s
+	}
+	return nil
+}
+
+// closeError maps a gateway close to an error, dropping the resume state
+// for codes where resuming is not allowed.
+func (b *DiscordBot) closeError(err error) error {
+	var ce *websocket.CloseError
+	if !errors.As(err, &ce) {
+		return fmt.Errorf("ws read: %w", err)
+	}
+	switch ce.Code {
+	case 4004:
+		b.resetSession()
+		return fmt.Errorf("%w: authentication failed (check botToken)", errDiscordFatal)
+	case 4013, 4014:
+		b.resetSession()
+		return fmt.Errorf("%w: intents not allowed — enable MESSAGE CONTENT INTENT in the developer portal", errDiscordFatal)
+	case 4007, 4009, 4010, 4011, 4012:
+		b.resetSession()
+	}
+	return fmt.Errorf("gateway closed: %d %s", ce.Code, ce.Text)
+}
+
+// dialDiscordGateway dials the gateway through netguard (public hosts only).
+func dialDiscordGateway(ctx context.Context, wsURL string) (*websocket.Conn, error) {
+	if err := netguard.ValidateWebSocketURL(ctx, wsURL); err != nil {
+		return nil, fmt.Errorf("ws endpoint blocked: %w", err)
+	}
+	dialer := *websocket.DefaultDialer
+	dialer.Proxy = nil
+	dialer.NetDialContext = netguard.DialContext
+	conn, _, err := dialer.DialContext(ctx, wsURL, nil)
+	return conn, err
+}
+
+// handleDispatch handles op 0 events. Messages run on their own goroutine
+// so the read loop (and heartbeat ACKs) never stall behind an agent run.
+func (b *DiscordBot) handleDispatch(ctx context.Context, event string, raw json.RawMessage) {
+	switch event {
+	case "READY":
+		var ready struct {
+			SessionID        string      `json:"session_id"`
+			ResumeGatewayURL string      `json:"resume_gateway_url"`
+			User             discordUser `json:"user"`
+		}
+		if err := json.Unmarshal(raw, &ready); err != nil {
+			return
+		}
+		b.gwMu.Lock()
+		b.sessionID, b.resumeURL = ready.SessionID, ready.ResumeGatewayURL
+		b.gwMu.Unlock()
+		if ready.User.ID != "" {
+			b.identMu.Lock()
+			b.botUserID, b.botName = ready.User.ID, ready.User.Username
+			b.identMu.Unlock()
+		}
+		log.Printf("[discord] gateway ready agent=%s session=%s", b.agentID, ready.SessionID)
+	case "RESUMED":
+		log.Printf("[discord] gateway resumed agent=%s", b.agentID)
+	case "GUILD_CREATE":
+		var g discordGuild
+		if err := json.Unmarshal(raw, &g); err != nil {
+			return
+		}
+		b.cacheMu.Lock()
+		b.guilds[g.ID] = g.Name
+		for _, list := range [][]discordChannel{g.Channels, g.Threads} {
+			for _, c := range list {
+				if c.GuildID == "" {
+					c.GuildID = g.ID
+				}
+				b.channels[c.ID] = c
+			}
+		}
+		b.cacheMu.Unlock()
+	case "CHANNEL_CREATE", "CHANNEL_UPDATE", "THREAD_CREATE", "THREAD_UPDATE":
+		var c discordChannel
+		if err := json.Unmarshal(raw, &c); err == nil && c.ID != "" {
+			b.cacheMu.Lock()
+			b.channels[c.ID] = c
+			b.cacheMu.Unlock()
+		}
+	case "MESSAGE_CREATE":
+		var m discordMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return
+		}
+		b.inflight.Add(1)
+		go func() {
+			defer b.inflight.Done()
+			b.handleMessage(ctx, &m)
+		}()
+	}
+}
+
+// ── Inbound messages ──────────────────────────────────────────────────────
+
+// channelInfo returns a channel from the cache, else GET /channels/{id}.
+func (b *DiscordBot) channelInfo(ctx context.Context, id string) (discordChannel, bool) {
+	b.cacheMu.RLock()
+	c, ok := b.channels[id]
+	b.cacheMu.RUnlock()
+	if ok {
+		return c, true
+	}
+	if err := b.rest(ctx, http.MethodGet, "/channels/"+id, nil, &c); err != nil {
+		log.Printf("[discord] channel %s: %v", id, err)
+		return discordChannel{ID: id}, false
+	}
+	b.cacheMu.Lock()
+	b.channels[id] = c
+	b.cacheMu.Unlock()
+	return c, true
+}
+
+// chatRef converts a message's channel to a ChatRef. Threads are separate
+// channels on Discord; they become ThreadID under their parent so the
+// parent channel keeps one contact-book profile.
+func (b *DiscordBot) chatRef(ctx context.Context, m *discordMessage) ChatRef {
+	if m.GuildID == "" {
+		return ChatRef{ID: m.ChannelID, Type: "private"}
+	}
+	ref := ChatRef{ID: m.ChannelID, Type: "group"}
+	c, _ := b.channelInfo(ctx, m.ChannelID)
+	name := c.Name
+	if c.isThread() && c.ParentID != "" {
+		ref.ID, ref.ThreadID = c.ParentID, m.ChannelID
+		if parent, ok := b.channelInfo(ctx, c.ParentID); ok {
+			name = parent.Name
+		}
+	}
+	b.cacheMu.RLock()
+	guild := b.guilds[m.GuildID]
+	b.cacheMu.RUnlock()
+	switch {
+	case guild != "" && name != "":
+		ref.Title = guild + " #" + name
+	case name != "":
+		ref.Title = "#" + name
+	}
+	return ref
+}
+
+// isAddressedToBot reports whether a guild message targets this bot: an
+// @mention or a reply to one of its messages (as for Telegram groups).
+func (b *DiscordBot) isAddressedToBot(m *discordMessage) bool {
+	self := b.selfID()
+	if self == "" {
+		return false
+	}
+	for _, u := range m.Mentions {
+		if u.ID == self {
+			return true
+		}
+	}
+	return m.ReferencedMessage != nil && m.ReferencedMessage.Author.ID == self
+}
+
+// discordDisplayName prefers the guild nickname, then the global name.
+func discordDisplayName(m *discordMessage) string {
+	if m.Member != nil && m.Member.Nick != "" {
+		return m.Member.Nick
+	}
+	if m.Author.GlobalName != "" {
+		return m.Author.GlobalName
+	}
+	return m.Author.Username
+}
+
+func (b *DiscordBot) handleMessage(ctx context.Context, m *discordMessage) {
+	self := b.selfID()
+	if m.Author.ID == "" || m.Author.Bot || m.Author.ID == self {
+		return
+	}
+	if m.Type != 0 && m.Type != 19 {
+		return // joins, pins, thread starters, ...
+	}
+	if m.GuildID != "" && !b.isAddressedToBot(m) {
+		return // guilds: only respond when @mentioned or replied to
+	}
+	text := m.Content
+	if self != "" {
+		text = strings.NewReplacer("<@"+self+">", "", "<@!"+self+">", "").Replace(text)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" && len(m.Attachments) == 0 {
+		return
+	}
+	if m.Author.Avatar != "" {
+		b.avatars.Store(m.Author.ID, m.Author.Avatar)
+	}
+
+	chat := b.chatRef(ctx, m)
+	pipe := b.pipeline()
+	sessionID := pipe.SessionID(chat)
+	muVal, _ := b.chatMu.LoadOrStore(sessionID, &sync.Mutex{})
+	mu := muVal.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	senderName := discordDisplayName(m)
+	sender := Sender{ID: m.Author.ID, Name: senderName, Username: m.Author.Username}
+	if res := b.checkAccess(m.GuildID, sender); res != AccessAllowed {
+		key := discordAccessKey(m.GuildID, m.Author.ID)
+		log.Printf("[discord] access %v — key=%s channel=%s", res, key, m.ChannelID)
+		_, _ = b.Send(ctx, chat, b.pairingReply(res, key), m.ID)
+		return
+	}
+
+	media, extras := b.downloadAttachments(ctx, m.Attachments)
+	if text == "" {
+		text = strings.Join(extras, " ")
+	}
+	log.Printf("[discord] message from user=%s channel=%s text=%q", m.Author.ID, m.ChannelID, truncateStr(text, 60))
+
+	finalText := text
+	replyTo := ""
+	if chat.IsGroup() {
+		finalText = fmt.Sprintf("[%s]: %s", senderName, text)
+		replyTo = m.ID
+	}
+	in := InboundMessage{
+		ChannelType: "discord",
+		ChannelID:   b.channelID,
+		MessageID:   m.ID,
+		Chat:        chat,
+		Sender:      sender,
+		Text:        finalText,
+		Media:       media,
+		ReplyTo:     replyTo,
+		ExtraContext: []string{fmt.Sprintf("当前 Discord 用户信息：user_id=%s，username=%s，guild_id=%s，channel_id=%s",
+			m.Author.ID, m.Author.Username, m.GuildID, m.ChannelID)},
+	}
+	pipe.LogInbound(in, text)
+	pipe.Dispatch(ctx, in)
+}
+
+// pairingReply guides a sender that is not on the allowlist to the panel.
+func (b *DiscordBot) pairingReply(res AccessResult, key string) string {
+	where := "ZyHive 管理面板"
+	if b.panelBaseURL != "" {
+		where = b.panelBaseURL + "/#/agents/" + b.agentID + "/channels"
+	}
+	if res == AccessPairing {
+		return fmt.Sprintf("👋 你好！此 Bot 尚未完成配对，请管理员在以下地址授权（你的 Discord ID：`%s`）：\n%s", key, where)
+	}
+	return fmt.Sprintf("👋 你好！你的申请已收到（Discord ID：`%s`），等待管理员在以下地址审核：\n%s", key, where)
+}
+
+// downloadAttachments fetches image / PDF attachments (max 5) as
+// MediaInput; other files become "[📎 name]" placeholders.
+func (b *DiscordBot) downloadAttachments(ctx context.Context, atts []discordAttachment) ([]MediaInput, []string) {
+	const maxFiles = 5
+	var media []MediaInput
+	var extras []string
+	for _, a := range atts {
+		ct := a.ContentType
+		if i := strings.IndexByte(ct, ';'); i >= 0 {
+			ct = ct[:i]
+		}
+		vision := strings.HasPrefix(ct, "image/") || ct == "application/pdf"
+		if !vision || len(media) >= maxFiles || a.Size > discordMaxFileBytes || a.URL == "" {
+			extras = append(extras, "[📎 "+a.Filename+"]")
+			continue
+		}
+		data, err := b.download(ctx, a.URL)
+		if err != nil {
+			log.Printf("[discord] download attachment=%s: %v", a.ID, err)
+			extras = append(extras, "[📎 "+a.Filename+"]")
+			continue
+		}
+		media = append(media, MediaInput{Data: data, ContentType: ct, FileName: a.Filename})
+	}
+	if len(media) > 0 && len(extras) == 0 {
+		extras = append(extras, "[📷 图片]")
+	}
+	return media, extras
+}
+
+// download fetches a CDN URL (attachments are signed; no auth header).
+func (b *DiscordBot) download(ctx context.Context, fileURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, discordMaxFileBytes))
+}
+
+// fetchAvatar caches the sender's avatar in the contact book (once per
+// user per process; the hash comes from the author of a message).
+func (b *DiscordBot) fetchAvatar(userID, contactID string) {
+	v, ok := b.avatars.LoadAndDelete(userID)
+	if !ok || b.agentDir == "" {
+		return
+	}
+	hash := v.(string)
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		ctx, cancel := context.WithTimeout(b.ctx(), 30*time.Second)
+		defer cancel()
+		data, err := b.download(ctx, fmt.Sprintf("%s/avatars/%s/%s.png?size=256", b.cdnBase, userID, hash))
+		if err != nil {
+			log.Printf("[discord/avatar] download user=%s: %v", userID, err)
+			return
+		}
+		if len(data) > network.MaxAvatarBytes {
+			return
+		}
+		store := network.NewStore(filepath.Join(b.agentDir, "workspace"))
+		if err := store.SaveAvatar(contactID, data, "image/png"); err != nil {
+			log.Printf("[discord/avatar] save user=%s: %v", userID, err)
+		}
+	}()
+}
+
+// ProactiveSend DMs every allowlisted user (cron announcements, send_message).
+func (b *DiscordBot) ProactiveSend(text string) error {
+	ctx := b.ctx()
+	var lastErr error
+	for _, userID := range b.ProactiveRecipients() {
+		if err := b.ProactiveSendTo(ctx, userID, text); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// ProactiveRecipients implements RecipientSender: the allowlisted users
+// (guild entries name no recipient).
+func (b *DiscordBot) ProactiveRecipients() []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, entry := range b.getAllowFrom() {
+		userID, ok := discordAllowUser(entry)
+		if !ok || seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		out = append(out, userID)
+	}
+	return out
+}
+
+// ProactiveSendTo implements RecipientSender: a DM to one user.
+func (b *DiscordBot) ProactiveSendTo(ctx context.Context, userID, text string) error {
+	var dm discordChannel
+	if err := b.rest(ctx, http.MethodPost, "/users/@me/channels", map[string]string{"recipient_id": userID}, &dm); err != nil {
+		return err
+	}
+	_, err := b.Send(ctx, ChatRef{ID: dm.ID, Type: "private"}, text, "")
+	return err
+}
+
+// ── REST helpers ──────────────────────────────────────────────────────────
+
+// rest sends a JSON request (body nil = none) and decodes the response
+// into out (nil = discard).
+func (b *DiscordBot) rest(ctx context.Context, method, path string, body any, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	return b.restRaw(ctx, method, path, "application/json", data, out)
+}
+
+// restRaw performs one API call, waiting out up to two 429 rate limits.
+func (b *DiscordBot) restRaw(ctx context.Context, method, path, contentType string, data []byte, out any) error {
+	for attempt := 0; ; attempt++ {
+		var body io.Reader
+		if data != nil {
+			body = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, b.apiBase+path, body)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bot "+b.token)
+		req.Header.Set("User-Agent", "DiscordBot (https://github.com/Zyling-ai/zyhive, 1.0)")
+		if data != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp, err := b.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("discord %s %s: %w", method, path, err)
+		}
+		raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("discord %s %s: %w", method, path, err)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && attempt < 2 {
+			var rl struct {
+				RetryAfter float64 `json:"retry_after"`
+			}
+			_ = json.Unmarshal(raw, &rl)
+			wait := time.Duration(rl.RetryAfter * float64(time.Second))
+			if wait <= 0 || wait > 30*time.Second {
+				wait = time.Second
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			var apiErr struct {
+				Message string `json:"message"`
+				Code    int    `json:"code"`
+			}
+			if json.Unmarshal(raw, &apiErr) == nil && apiErr.Message != "" {
+				return fmt.Errorf("discord %s %s: HTTP %d: %s (code %d)", method, path, resp.StatusCode, apiErr.Message, apiErr.Code)
+			}
+			return fmt.Errorf("discord %s %s: HTTP %d: %s", method, path, resp.StatusCode, truncateStr(string(raw), 200))
+		}
+		if out != nil && len(raw) > 0 {
+			return json.Unmarshal(raw, out)
+		}
+		return nil
+	}
+}
+
+// TestDiscordBot verifies a bot token and returns the bot's username.
+func TestDiscordBot(ctx context.Context, token string) (string, error) {
+	b := &DiscordBot{token: token, apiBase: discordAPIBase, client: netguard.NewSafeClient(8 * time.Second)}
+	var me discordUser
+	if err := b.rest(ctx, http.MethodGet, "/users/@me", nil, &me); err != nil {
+		return "", err
+	}
+	return me.Username, nil
+}
+
+// discordClip trims text to Discord's 2000-character message limit.
+func discordClip(text string) string {
+	runes := []rune(text)
+	if len(runes) <= discordMaxContent {
+		return text
+	}
+	return string(runes[:discordMaxContent-1]) + "…"
+}
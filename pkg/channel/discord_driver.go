@@ -0,0 +1,204 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DiscordBot implements Driver and Notifier.
+var (
+	_ Driver   = (*DiscordBot)(nil)
+	_ Notifier = (*DiscordBot)(nil)
+)
+
+// Snowflake ids exceed JavaScript's safe integers, so Discord keeps its
+// pending / approved users in the string-keyed stores.
+func init() {
+	RegisterDriver(DriverSpec{
+		Type:      "discord",
+		Required:  []string{"botToken"},
+		UniqueKey: "botToken",
+		New:       newDiscordDriver,
+		Test: func(ctx context.Context, cfg map[string]string) (string, error) {
+			return TestDiscordBot(ctx, cfg["botToken"])
+		},
+	})
+}
+
+func newDiscordDriver(env DriverEnv) (Driver, error) {
+	var pending *PendingStoreStr
+	if dir := env.PendingDir(); dir != "" {
+		pending = NewPendingStoreStr(dir, env.ChannelID)
+	}
+	getAllowFrom := env.AllowFrom
+	if getAllowFrom == nil {
+		getAllowFrom = func() []string { return nil }
+	}
+	bot := NewDiscordBotWithStream(env.Config["botToken"], env.AgentID, env.AgentDir, env.ChannelID, getAllowFrom, env.Stream, pending)
+	bot.SetPanelBaseURL(env.PanelBaseURL)
+	bot.SetOnConnected(env.OnConnected)
+	return bot, nil
+}
+
+// pipeline returns the shared message path bound to this bot. Access is
+// checked by checkAccess (guild-scoped keys), not Pipeline.Check.
+func (b *DiscordBot) pipeline() *Pipeline {
+	return &Pipeline{
+		Env: DriverEnv{
+			AgentID:      b.agentID,
+			AgentDir:     b.agentDir,
+			ChannelID:    b.channelID,
+			Stream:       b.streamFunc,
+			AllowFrom:    b.getAllowFrom,
+			PanelBaseURL: b.panelBaseURL,
+		},
+		Driver:       b,
+		OnNewContact: b.fetchAvatar,
+	}
+}
+
+// ── Allowlist ─────────────────────────────────────────────────────────────
+
+// discordAccessKey is the pending / allowlist key of a sender: the user id
+// in DMs, "{guild}/{user}" in a guild so approvals apply per guild.
+func discordAccessKey(guildID, userID string) string {
+	if guildID == "" {
+		return userID
+	}
+	return guildID + "/" + userID
+}
+
+// discordAllowUser extracts the user id from an allowlist entry
+// ("guild:{id}" entries name no user).
+func discordAllowUser(entry string) (string, bool) {
+	if strings.HasPrefix(entry, "guild:") {
+		return "", false
+	}
+	if i := strings.LastIndexByte(entry, '/'); i >= 0 {
+		entry = entry[i+1:]
+	}
+	return entry, entry != ""
+}
+
+// checkAccess is Pipeline.Check with guild scoping: a sender is allowed by
+// their bare user id, by "{guild}/{user}" or by "guild:{guild}". Refused
+// senders are recorded under their access key.
+func (b *DiscordBot) checkAccess(guildID string, s Sender) AccessResult {
+	allow := b.getAllowFrom()
+	key := discordAccessKey(guildID, s.ID)
+	res := AccessDenied
+	if len(allow) == 0 {
+		res = AccessPairing
+	}
+	for _, a := range allow {
+		if a == s.ID || a == key || (guildID != "" && a == "guild:"+guildID) {
+			res = AccessAllowed
+			break
+		}
+	}
+	if rec := b.pendingStore.Recorder(); rec != nil {
+		if res == AccessAllowed {
+			rec.Remove(key)
+		} else {
+			rec.Add(Sender{ID: key, Name: s.Name, Username: s.Username})
+		}
+	}
+	return res
+}
+
+// ── Driver ────────────────────────────────────────────────────────────────
+
+// Type implements Driver.
+func (b *DiscordBot) Type() string { return "discord" }
+
+// Capabilities implements Driver. Message edits share the per-channel
+// limit of 5 requests / 5s with sends.
+func (b *DiscordBot) Capabilities() Capabilities {
+	return Capabilities{Edit: true, Typing: true, Files: true, Threads: true, ThreadSessions: true, EditEvery: 1200 * time.Millisecond, MaxText: discordMaxContent}
+}
+
+// discordTarget is the channel a message goes to: the thread when set.
+func discordTarget(chat ChatRef) string {
+	if chat.ThreadID != "" {
+		return chat.ThreadID
+	}
+	return chat.ID
+}
+
+// discordNoPings stops model output from pinging @everyone / roles / users.
+var discordNoPings = map[string]any{"parse": []string{}}
+
+// Send implements Driver; replyTo quotes the user's message.
+func (b *DiscordBot) Send(ctx context.Context, chat ChatRef, text, replyTo string) (string, error) {
+	body := map[string]any{"content": discordClip(text), "allowed_mentions": discordNoPings}
+	if replyTo != "" {
+		body["message_reference"] = map[string]any{"message_id": replyTo, "fail_if_not_exists": false}
+	}
+	var out struct {
+		ID string `json:"id"`
+	}
+	if err := b.rest(ctx, http.MethodPost, "/channels/"+discordTarget(chat)+"/messages", body, &out); err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+// Edit implements Driver.
+func (b *DiscordBot) Edit(ctx context.Context, chat ChatRef, msgID, text string) error {
+	body := map[string]any{"content": discordClip(text), "allowed_mentions": discordNoPings}
+	return b.rest(ctx, http.MethodPatch, "/channels/"+discordTarget(chat)+"/messages/"+msgID, body, nil)
+}
+
+// Typing implements Driver (the indicator lasts ~10s).
+func (b *DiscordBot) Typing(ctx context.Context, chat ChatRef) error {
+	return b.rest(ctx, http.MethodPost, "/channels/"+discordTarget(chat)+"/typing", nil, nil)
+}
+
+// SendFile implements Driver: one multipart message with files[0].
+func (b *DiscordBot) SendFile(ctx context.Context, chat ChatRef, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	name := filepath.Base(path)
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	payload, _ := json.Marshal(map[string]any{
+		"attachments":      []map[string]any{{"id": 0, "filename": name}},
+		"allowed_mentions": discordNoPings,
+	})
+	if err := mw.WriteField("payload_json", string(payload)); err != nil {
+		return "", err
+	}
+	fw, err := mw.CreateFormFile("files[0]", name)
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	if err := b.restRaw(ctx, http.MethodPost, "/channels/"+discordTarget(chat)+"/messages", mw.FormDataContentType(), buf.Bytes(), nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("✅ 文件 %s 已发送（%d 字节）", name, len(data)), nil
+}
+
+// Notify runs the agent on prompt in the chat's (thread's) session and
+// posts the reply. A chat without Type is treated as a DM.
+func (b *DiscordBot) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	if chat.Type == "" {
+		chat.Type = "private"
+	}
+	return b.pipeline().Notify(ctx, chat, prompt)
+}
@@ -0,0 +1,386 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/network"
+	"github.com/gorilla/websocket"
+)
+
+// fakeDiscord is a minimal Discord REST API + gateway stand-in.
+type fakeDiscord struct {
+	t   *testing.T
+	srv *httptest.Server
+	mu  sync.Mutex
+	// calls records REST requests as "METHOD /path" with decoded JSON bodies.
+	calls []discordCall
+	// gwIn receives every payload the bot sends on the gateway; frames are
+	// pushed to it; drop closes the current connection with code 4000.
+	gwIn   chan discordPayload
+	frames chan string
+	drop   chan struct{}
+}
+
+type discordCall struct {
+	Route string
+	Body  map[string]any
+}
+
+func newFakeDiscord(t *testing.T) *fakeDiscord {
+	f := &fakeDiscord{t: t, gwIn: make(chan discordPayload, 16), frames: make(chan string, 8), drop: make(chan struct{}, 1)}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeDiscord) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/gw/" {
+		f.serveGateway(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, "/cdn/") {
+		_, _ = io.WriteString(w, "PNGDATA")
+		return
+	}
+	route := r.Method + " " + strings.TrimPrefix(r.URL.Path, "/api")
+	body := map[string]any{}
+	raw, _ := io.ReadAll(r.Body)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		_ = json.Unmarshal(raw, &body)
+	} else if len(raw) > 0 {
+		body["raw"] = string(raw)
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, discordCall{Route: route, Body: body})
+	n := len(f.calls)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case route == "GET /users/@me":
+		_, _ = io.WriteString(w, `{"id":"BOT","username":"zybot"}`)
+	case route == "GET /gateway/bot":
+		_, _ = io.WriteString(w, `{"url":"ws`+strings.TrimPrefix(f.srv.URL, "http")+`/gw"}`)
+	case route == "GET /channels/T1":
+		_, _ = io.WriteString(w, `{"id":"T1","type":11,"name":"help","parent_id":"C1","guild_id":"G1"}`)
+	case route == "GET /channels/C1":
+		_, _ = io.WriteString(w, `{"id":"C1","type":0,"name":"general","guild_id":"G1"}`)
+	case route == "POST /users/@me/channels":
+		_, _ = io.WriteString(w, `{"id":"DM-`+body["recipient_id"].(string)+`","type":1}`)
+	case strings.HasSuffix(route, "/messages") && r.Method == http.MethodPost:
+		_, _ = io.WriteString(w, `{"id":"m`+strconv.Itoa(n)+`"}`)
+	case strings.HasSuffix(route, "/typing"):
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		_, _ = io.WriteString(w, `{}`)
+	}
+}
+
+func (f *fakeDiscord) serveGateway(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var wmu sync.Mutex
+	write := func(s string) error {
+		wmu.Lock()
+		defer wmu.Unlock()
+		return conn.WriteMessage(websocket.TextMessage, []byte(s))
+	}
+	_ = write(`{"op":10,"d":{"heartbeat_interval":40}}`)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			var p discordPayload
+			if err := conn.ReadJSON(&p); err != nil {
+				return
+			}
+			if p.Op == discordOpHeartbeat {
+				_ = write(`{"op":11}`)
+			}
+			f.gwIn <- p
+		}
+	}()
+	for {
+		select {
+		case frame := <-f.frames:
+			if err := write(frame); err != nil {
+				return
+			}
+		case <-f.drop:
+			wmu.Lock()
+			_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4000, "unknown error"))
+			wmu.Unlock()
+			return
+		case <-done:
+			return
+		}
+	}
+}
+
+// next returns the next gateway payload with op (skipping others).
+func (f *fakeDiscord) next(op int) discordPayload {
+	f.t.Helper()
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case p := <-f.gwIn:
+			if p.Op == op {
+				return p
+			}
+		case <-timeout:
+			f.t.Fatalf("no gateway op %d", op)
+		}
+	}
+}
+
+// wait returns the calls once one matches route (or fails after 3s).
+func (f *fakeDiscord) wait(route string) (discordCall, []discordCall) {
+	f.t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		f.mu.Lock()
+		calls := append([]discordCall(nil), f.calls...)
+		f.mu.Unlock()
+		for _, c := range calls {
+			if c.Route == route {
+				return c, calls
+			}
+		}
+		if time.Now().After(deadline) {
+			f.t.Fatalf("no %s; calls = %+v", route, calls)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// newTestDiscordBot wires a bot to the fake server; runs record session
+// ids and media.
+func newTestDiscordBot(t *testing.T, f *fakeDiscord, allow []string, runs chan<- testRun) *DiscordBot {
+	t.Helper()
+	stream := func(_ context.Context, _, text, sessionID string, media []MediaInput, _ FileSenderFunc, _ ...string) (<-chan StreamEvent, error) {
+		if runs != nil {
+			runs <- testRun{session: sessionID, text: text, media: media}
+		}
+		return streamOf(StreamEvent{Type: "text_delta", Text: "hi @everyone"}, StreamEvent{Type: "done"}), nil
+	}
+	b := NewDiscordBotWithStream("tok", "a1", t.TempDir(), "discord-1", func() []string { return allow }, stream, nil)
+	b.apiBase = f.srv.URL + "/api"
+	b.cdnBase = f.srv.URL + "/cdn"
+	b.client = f.srv.Client()
+	b.dialWS = func(ctx context.Context, wsURL string) (*websocket.Conn, error) {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		return conn, err
+	}
+	if err := b.identify(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(b.inflight.Wait) // runs write into agentDir until they finish
+	return b
+}
+
+type testRun struct {
+	session string
+	text    string
+	media   []MediaInput
+}
+
+// deliver feeds a MESSAGE_CREATE to the bot as the gateway would.
+func deliver(b *DiscordBot, msg string) {
+	b.handleDispatch(context.Background(), "MESSAGE_CREATE", json.RawMessage(msg))
+}
+
+func TestDiscordGatewayIdentifyHeartbeatResume(t *testing.T) {
+	f := newFakeDiscord(t)
+	b := newTestDiscordBot(t, f, nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() { errs <- b.runOnce(ctx) }()
+	id := f.next(discordOpIdentify)
+	var ident struct {
+		Token   string `json:"token"`
+		Intents int    `json:"intents"`
+	}
+	_ = json.Unmarshal(id.D, &ident)
+	if ident.Token != "tok" || ident.Intents != discordIntents {
+		t.Errorf("identify = %s", id.D)
+	}
+	f.frames <- `{"op":0,"t":"READY","s":1,"d":{"session_id":"sess-1","resume_gateway_url":"ws` +
+		strings.TrimPrefix(f.srv.URL, "http") + `/gw","user":{"id":"BOT","username":"zybot"}}}`
+	f.frames <- `{"op":0,"t":"GUILD_CREATE","s":2,"d":{"id":"G1","name":"Hive","channels":[{"id":"C1","type":0,"name":"general"}]}}`
+	// Heartbeats carry the last sequence number.
+	deadline := time.After(3 * time.Second)
+	for seq := int64(0); seq != 2; {
+		select {
+		case p := <-f.gwIn:
+			if p.Op == discordOpHeartbeat {
+				_ = json.Unmarshal(p.D, &seq)
+			}
+		case <-deadline:
+			t.Fatal("no heartbeat with seq 2")
+		}
+	}
+
+	f.drop <- struct{}{}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "4000") {
+		t.Fatalf("runOnce after drop = %v", err)
+	}
+	go func() { errs <- b.runOnce(ctx) }()
+	res := f.next(discordOpResume)
+	var resume struct {
+		SessionID string `json:"session_id"`
+		Seq       int64  `json:"seq"`
+	}
+	_ = json.Unmarshal(res.D, &resume)
+	if resume.SessionID != "sess-1" || resume.Seq != 2 {
+		t.Errorf("resume = %s", res.D)
+	}
+	if ch, _ := b.channelInfo(ctx, "C1"); ch.GuildID != "G1" || ch.Name != "general" {
+		t.Errorf("cached channel = %+v", ch)
+	}
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("runOnce after cancel = %v", err)
+	}
+}
+
+func TestDiscordMentionGatingAndThreads(t *testing.T) {
+	f := newFakeDiscord(t)
+	runs := make(chan testRun, 4)
+	b := newTestDiscordBot(t, f, []string{"U1"}, runs)
+
+	// Guild message without a mention is ignored.
+	deliver(b, `{"id":"1","channel_id":"C1","guild_id":"G1","author":{"id":"U1","username":"alice"},"content":"hello all"}`)
+	// Mention inside a thread: thread session, reply goes to the thread.
+	deliver(b, `{"id":"2","channel_id":"T1","guild_id":"G1","author":{"id":"U1","username":"alice","global_name":"Alice"},
+		"content":"<@BOT> help me","mentions":[{"id":"BOT"}]}`)
+	run := <-runs
+	if run.session != "discord-C1-T1" || run.text != "[Alice]: help me" {
+		t.Errorf("thread run = %+v", run)
+	}
+	post, _ := f.wait("POST /channels/T1/messages")
+	ref, _ := post.Body["message_reference"].(map[string]any)
+	if post.Body["content"] != "hi @everyone" || ref["message_id"] != "2" || post.Body["allowed_mentions"] == nil {
+		t.Errorf("reply = %+v", post.Body)
+	}
+
+	// A reply to the bot counts as addressed, even without a mention.
+	deliver(b, `{"id":"3","type":19,"channel_id":"C1","guild_id":"G1","author":{"id":"U1","username":"alice"},
+		"content":"and then?","referenced_message":{"author":{"id":"BOT"}}}`)
+	if run := <-runs; run.session != "discord-C1" {
+		t.Errorf("reply run = %+v", run)
+	}
+
+	// DMs need no mention.
+	deliver(b, `{"id":"4","channel_id":"D1","author":{"id":"U1","username":"alice"},"content":"ping"}`)
+	if run := <-runs; run.session != "discord-D1" || run.text != "ping" {
+		t.Errorf("dm run = %+v", run)
+	}
+	b.inflight.Wait()
+	select {
+	case extra := <-runs:
+		t.Errorf("unexpected extra run %+v", extra)
+	default:
+	}
+
+	// Sender and guild channel were filed into the contact book.
+	store := network.NewStore(filepath.Join(b.agentDir, "workspace"))
+	if c, err := store.Get(network.MakeID(network.SourceDiscord, "U1")); err != nil || c == nil {
+		t.Errorf("contact not filed: %v", err)
+	}
+	if c, err := store.GetChat(network.MakeID(network.SourceDiscord, "C1")); err != nil || c == nil || c.Title != "#general" {
+		t.Errorf("chat profile = %+v, %v", c, err)
+	}
+}
+
+func TestDiscordGuildAllowlist(t *testing.T) {
+	f := newFakeDiscord(t)
+	runs := make(chan testRun, 4)
+	b := newTestDiscordBot(t, f, []string{"G1/U1", "guild:G2", "U3"}, runs)
+	b.pendingStore = NewPendingStoreStr(t.TempDir(), "discord-1")
+
+	cases := []struct {
+		guild, user string
+		want        AccessResult
+	}{
+		{"G1", "U1", AccessAllowed},
+		{"G9", "U1", AccessDenied}, // approved for G1 only
+		{"", "U1", AccessDenied},
+		{"G2", "U7", AccessAllowed}, // whole guild
+		{"G9", "U3", AccessAllowed}, // bare id: anywhere
+	}
+	for _, c := range cases {
+		if got := b.checkAccess(c.guild, Sender{ID: c.user, Name: c.user}); got != c.want {
+			t.Errorf("checkAccess(%q, %q) = %v, want %v", c.guild, c.user, got, c.want)
+		}
+	}
+	var pending []string
+	for _, p := range b.pendingStore.List() {
+		pending = append(pending, p.ID)
+	}
+	if got := strings.Join(pending, ","); got != "G9/U1,U1" && got != "U1,G9/U1" {
+		t.Errorf("pending = %q", got)
+	}
+
+	// A refused guild sender gets the pairing hint with their scoped key.
+	deliver(b, `{"id":"5","channel_id":"C1","guild_id":"G9","author":{"id":"U1","username":"alice"},"content":"<@BOT> hi","mentions":[{"id":"BOT"}]}`)
+	post, _ := f.wait("POST /channels/C1/messages")
+	if !strings.Contains(post.Body["content"].(string), "G9/U1") {
+		t.Errorf("pairing reply = %v", post.Body["content"])
+	}
+
+	// ProactiveSend DMs each allowlisted user once.
+	if err := b.ProactiveSend("news"); err != nil {
+		t.Fatal(err)
+	}
+	_, calls := f.wait("POST /channels/DM-U3/messages")
+	var opened []string
+	for _, c := range calls {
+		if c.Route == "POST /users/@me/channels" {
+			opened = append(opened, c.Body["recipient_id"].(string))
+		}
+	}
+	if strings.Join(opened, ",") != "U1,U3" {
+		t.Errorf("opened DMs = %v", opened)
+	}
+}
+
+func TestDiscordAttachmentsAndEdit(t *testing.T) {
+	f := newFakeDiscord(t)
+	runs := make(chan testRun, 4)
+	b := newTestDiscordBot(t, f, []string{"U1"}, runs)
+
+	deliver(b, `{"id":"6","channel_id":"D1","author":{"id":"U1","username":"alice"},"content":"",
+		"attachments":[{"id":"a1","filename":"cat.png","content_type":"image/png","size":7,"url":"`+f.srv.URL+`/cdn/cat.png"},
+		{"id":"a2","filename":"notes.zip","content_type":"application/zip","size":9,"url":"`+f.srv.URL+`/cdn/notes.zip"}]}`)
+	run := <-runs
+	if len(run.media) != 1 || string(run.media[0].Data) != "PNGDATA" || run.media[0].ContentType != "image/png" {
+		t.Errorf("media = %+v", run.media)
+	}
+	if run.text != "[📎 notes.zip]" {
+		t.Errorf("text = %q", run.text)
+	}
+
+	long := strings.Repeat("x", 2100)
+	if err := b.Edit(context.Background(), ChatRef{ID: "C1", ThreadID: "T1"}, "m9", long); err != nil {
+		t.Fatal(err)
+	}
+	edit, _ := f.wait("PATCH /channels/T1/messages/m9")
+	if n := len([]rune(edit.Body["content"].(string))); n != discordMaxContent {
+		t.Errorf("edited content length = %d", n)
+	}
+}
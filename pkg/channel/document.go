@@ -0,0 +1,98 @@
+// pkg/channel/document.go — files sent into a chat.
+//
+// Documents are filed under the agent workspace and their text is
+// extracted (pkg/docextract) into the user turn, so every model can read a
+// PDF or spreadsheet, and the agent can `read` further pages later from
+// the saved copy.
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/docextract"
+)
+
+// docChars caps the extracted text put into the user turn; the rest stays
+// reachable through the read tool (pages / sheet).
+const docChars = 20000
+
+// IsDocument reports whether a file is worth downloading for extraction.
+func IsDocument(name, contentType string) bool {
+	return docextract.Detect(name, contentType, nil) != ""
+}
+
+// Document files an inbound document and returns the user-turn text for
+// it: "[📎 文件 · media/files/{session}/{name} · 12 页]" followed by the
+// extracted text. keepMedia is true for a PDF without a text layer (a
+// scan), which the caller should still pass to the model as MediaInput.
+func (p *Pipeline) Document(_ context.Context, chat ChatRef, file MediaInput) (text string, keepMedia bool) {
+	name := file.FileName
+	if name == "" {
+		name = "文件"
+	}
+	ref := ""
+	if rel := p.saveDocument(chat, file); rel != "" {
+		ref = " · " + rel
+	}
+	res, err := docextract.Extract(file.Data, file.FileName, file.ContentType, docextract.Options{MaxChars: docChars})
+	switch {
+	case errors.Is(err, docextract.ErrNoText):
+		return "[📎 文件: " + name + ref + "，无文字层（扫描件）]", docextract.Detect(file.FileName, file.ContentType, file.Data) == docextract.FormatPDF
+	case errors.Is(err, docextract.ErrUnsupported):
+		return "[📎 文件: " + name + ref + "]", false
+	case err != nil:
+		log.Printf("[%s] extract %s: %v", p.Driver.Type(), name, err)
+		return "[📎 文件: " + name + ref + "，解析失败]", false
+	}
+	head := "[📎 文件: " + name + ref
+	if res.Parts > 0 {
+		head += fmt.Sprintf(" · %d %s", res.Parts, partUnit(res.Format))
+	}
+	head += "]"
+	if res.Truncated {
+		return head + "\n" + res.Text + "\n…（内容较长已截断，可用 read 工具按 pages / sheet 读取其余部分）", false
+	}
+	return head + "\n" + res.Text, false
+}
+
+func partUnit(format string) string {
+	switch format {
+	case docextract.FormatPPTX:
+		return "张幻灯片"
+	case docextract.FormatXLSX:
+		return "个工作表"
+	}
+	return "页"
+}
+
+// saveDocument writes the file under {AgentDir}/workspace/media/files and
+// returns its workspace-relative path ("" when not saved).
+func (p *Pipeline) saveDocument(chat ChatRef, file MediaInput) string {
+	if p.Env.AgentDir == "" || len(file.Data) == 0 {
+		return ""
+	}
+	clean := strings.NewReplacer("/", "_", `\`, "_", "..", "_")
+	session := clean.Replace(p.SessionID(chat))
+	name := clean.Replace(filepath.Base(file.FileName))
+	if name == "" || name == "." {
+		name = "file"
+	}
+	rel := filepath.Join("media", "files", session, time.Now().Format("20060102-150405")+"-"+name)
+	abs := filepath.Join(p.Env.AgentDir, "workspace", rel)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		log.Printf("[%s] save document: %v", p.Driver.Type(), err)
+		return ""
+	}
+	if err := os.WriteFile(abs, file.Data, 0o644); err != nil {
+		log.Printf("[%s] save document: %v", p.Driver.Type(), err)
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
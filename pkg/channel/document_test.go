@@ -0,0 +1,34 @@
+package channel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestPipelineDocument(t *testing.T) {
+	dir := t.TempDir()
+	p := &Pipeline{Env: DriverEnv{AgentDir: dir}, Driver: &fakeDriver{}}
+	page := MediaInput{Data: []byte("<html><body><nav>菜单</nav><p>会议纪要：周五发布。</p></body></html>"), ContentType: "application/octet-stream", FileName: "纪要.html"}
+
+	text, keep := p.Document(context.Background(), ChatRef{ID: "7"}, page)
+	m := regexp.MustCompile(`^\[📎 文件: 纪要\.html · (media/files/fake-7/[0-9-]+-纪要\.html)\]\n会议纪要：周五发布。$`).FindStringSubmatch(text)
+	if m == nil || keep {
+		t.Fatalf("document = %q (keep %v)", text, keep)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "workspace", m[1])); err != nil || string(data) != string(page.Data) {
+		t.Errorf("saved file = %q, %v", data, err)
+	}
+
+	// Scanned PDF: placeholder, and the caller keeps the file as media.
+	scan := MediaInput{Data: []byte("%PDF-1.4\n1 0 obj\n<< /Type /Page /Contents 2 0 R >>\nendobj\n2 0 obj\n<< /Length 8 >>\nstream\n/Im1 Do\nendstream\nendobj\n"), ContentType: "application/pdf", FileName: "scan.pdf"}
+	if text, keep := p.Document(context.Background(), ChatRef{ID: "7"}, scan); !keep || !regexp.MustCompile(`无文字层`).MatchString(text) {
+		t.Errorf("scan = %q (keep %v)", text, keep)
+	}
+
+	if text, _ := p.Document(context.Background(), ChatRef{ID: "7"}, MediaInput{Data: []byte("x"), FileName: "a.bin"}); !regexp.MustCompile(`^\[📎 文件: a\.bin · media/files/\S+\]$`).MatchString(text) {
+		t.Errorf("unsupported = %q", text)
+	}
+}
@@ -0,0 +1,330 @@
+// pkg/channel/driver.go — platform-neutral channel driver contract + registry.
+//
+// Every messaging platform (Telegram, Feishu, ...) is a Driver registered
+// under its ChannelEntry.Type. The gateway never switches on the type
+// string: it looks the DriverSpec up, builds the driver with a DriverEnv
+// (agent, allowlist, stream func) and hands it to BotPool. Shared behavior
+// — allowlist / pending users, contact filing, conversation logging,
+// streamed draft edits — lives in Pipeline (pipeline.go), so a driver only
+// translates platform events into InboundMessage and implements the
+// outbound primitives below.
+package channel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Capabilities advertises what a driver's outbound side supports.
+// Pipeline adapts to them (e.g. no Edit → one final message, no draft).
+type Capabilities struct {
+	Edit    bool // Edit can rewrite a sent message (streamed drafts)
+	Typing  bool // Typing shows a "typing…" indicator
+	Files   bool // SendFile delivers files; enables the send_file tool
+	Threads bool // ChatRef.ThreadID is meaningful
+	// ThreadSessions gives every thread its own agent session
+	// ("{type}-{chatID}-{threadID}") instead of one per chat.
+	ThreadSessions bool
+	// EditEvery is the minimum interval between draft edits; 0 = 1s.
+	EditEvery time.Duration
+	// Placeholder, when set, is sent before the first token so the user
+	// sees the reply is coming (Feishu "thinking" card). Requires Edit.
+	Placeholder string
+	// MaxText is the longest text (in characters) one Send accepts;
+	// longer replies are split into several messages. 0 = no limit.
+	MaxText int
+}
+
+// ChatRef addresses a conversation on the platform. IDs are strings so
+// every platform fits; drivers convert to their native types.
+type ChatRef struct {
+	ID       string `json:"id"`
+	ThreadID string `json:"threadId,omitempty"`
+	Type     string `json:"type,omitempty"` // platform chat type: private / group / supergroup / channel / p2p
+	Title    string `json:"title,omitempty"`
+}
+
+// IsGroup reports whether the chat has more than one human participant.
+func (c ChatRef) IsGroup() bool {
+	switch c.Type {
+	case "group", "supergroup", "channel":
+		return true
+	}
+	return false
+}
+
+// Sender identifies who wrote an inbound message.
+type Sender struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// InboundMessage is the normalized form of a platform message, produced
+// by drivers and consumed by Pipeline.
+type InboundMessage struct {
+	ChannelType string
+	ChannelID   string // ChannelEntry.ID
+	MessageID   string
+	Chat        ChatRef
+	Sender      Sender
+	Text        string       // cleaned text (mentions stripped, context added)
+	Media       []MediaInput // already downloaded attachments
+	// ReplyTo is the platform message the answer should quote ("" = none).
+	ReplyTo string
+	// ExtraContext is appended to the system prompt (invisible to users).
+	ExtraContext []string
+	// Voice marks a voice note; with voiceReply "auto" the answer is
+	// spoken back too (see Pipeline.Dispatch).
+	Voice bool
+}
+
+// SessionIDFor builds the per-chat session id for a channel type:
+// "{type}-{chatID}", the key Telegram ("telegram-123") and Feishu
+// ("feishu-oc_x") always used.
+func SessionIDFor(channelType, chatID string) string {
+	return channelType + "-" + chatID
+}
+
+// SessionIDForChat is SessionIDFor, plus the thread for drivers with
+// Capabilities.ThreadSessions.
+func SessionIDForChat(channelType string, caps Capabilities, chat ChatRef) string {
+	if caps.ThreadSessions && chat.ThreadID != "" {
+		return SessionIDFor(channelType, chat.ID+"-"+chat.ThreadID)
+	}
+	return SessionIDFor(channelType, chat.ID)
+}
+
+// Driver is one running bot on one platform.
+type Driver interface {
+	// Type returns the ChannelEntry.Type the driver serves.
+	Type() string
+	Capabilities() Capabilities
+	// Start connects and receives messages until ctx is cancelled.
+	Start(ctx context.Context)
+	// Send posts text, optionally quoting replyTo, and returns the new
+	// message id ("" when the platform gives none).
+	Send(ctx context.Context, chat ChatRef, text, replyTo string) (string, error)
+	// Edit rewrites a message sent by Send. Only called with Capabilities.Edit.
+	Edit(ctx context.Context, chat ChatRef, msgID, text string) error
+	// Typing shows a typing indicator once. Only called with Capabilities.Typing.
+	Typing(ctx context.Context, chat ChatRef) error
+	// SendFile uploads a local file and returns a short status line.
+	SendFile(ctx context.Context, chat ChatRef, path string) (string, error)
+	// ProactiveSend pushes text to the bot's known recipients (cron
+	// announcements, send_message tool). Drivers without a recipient
+	// list may return an error.
+	ProactiveSend(text string) error
+}
+
+// RecipientSender is implemented by drivers whose ProactiveSend fans out
+// to several recipients one message at a time. DeliveryQueue sends to each
+// recipient on its own, so a retry skips those who already got the text.
+type RecipientSender interface {
+	// ProactiveRecipients lists the current ProactiveSend targets.
+	ProactiveRecipients() []string
+	// ProactiveSendTo pushes text to one of them.
+	ProactiveSendTo(ctx context.Context, recipient, text string) error
+}
+
+// Notifier is implemented by drivers that can run the agent on a prompt
+// in a chat's session and deliver the reply (POST /agents/:id/notify).
+type Notifier interface {
+	Notify(ctx context.Context, chat ChatRef, prompt string) error
+}
+
+// WebhookHandler is implemented by drivers that also receive platform
+// events over HTTP (POST /channels/:agentId/:channelId/webhook; GET is
+// routed too, for URL verification handshakes). The route has no admin
+// auth: the driver must verify the platform's signature.
+type WebhookHandler interface {
+	ServeWebhook(w http.ResponseWriter, r *http.Request)
+}
+
+// VoiceSender is implemented by drivers that can post a voice message
+// (used by the per-channel "reply as voice" mode).
+type VoiceSender interface {
+	// SendVoice posts Ogg/Opus audio as a voice message.
+	SendVoice(ctx context.Context, chat ChatRef, audio []byte, contentType string) error
+}
+
+// ApprovalPrompt is a tool call waiting for a human decision (a view of
+// tools.ApprovalRequest, kept here so channel does not import tools).
+type ApprovalPrompt struct {
+	ID        string
+	AgentID   string
+	SessionID string
+	ToolName  string
+	Input     string // raw JSON
+	ExpiresAt time.Time
+}
+
+// Approvals lets drivers with interactive buttons resolve tool approvals.
+type Approvals interface {
+	// Watch streams new approval requests until ctx ends.
+	Watch(ctx context.Context) <-chan ApprovalPrompt
+	// Decide resolves a pending request; by identifies the decider.
+	Decide(id string, approved bool, by string) error
+}
+
+// OutboxDraft is an outgoing message held for admin approval.
+type OutboxDraft struct {
+	ID         string   `json:"id"`
+	ThreadKey  string   `json:"threadKey"`
+	To         []string `json:"to"`
+	Subject    string   `json:"subject"`
+	Body       string   `json:"body"`
+	MessageID  string   `json:"messageId,omitempty"`
+	InReplyTo  string   `json:"inReplyTo,omitempty"`
+	References []string `json:"references,omitempty"`
+	CreatedAt  int64    `json:"createdAt"` // unix ms
+}
+
+// Outbox is implemented by drivers that can hold replies until an admin
+// approves them (GET /agents/:id/channels/:chId/outbox).
+type Outbox interface {
+	Drafts() []OutboxDraft
+	// ApproveDraft sends a draft; wholeThread approves later replies in
+	// the same thread too.
+	ApproveDraft(ctx context.Context, id string, wholeThread bool) error
+	DiscardDraft(id string) error
+}
+
+// DriverEnv is everything a driver needs from the gateway.
+type DriverEnv struct {
+	AgentID   string
+	AgentDir  string // agents/{id}
+	ChannelID string
+	Config    map[string]string // ChannelEntry.Config
+	Stream    StreamFunc
+	// AllowFrom returns the live allowlist (ChannelEntry.Config["allowedFrom"]);
+	// it is called per message so approvals apply without a restart.
+	AllowFrom    func() []string
+	PanelBaseURL string
+	// PublicURL is gateway.publicUrl ("" = not reachable from the
+	// internet); drivers that can take webhooks register under it.
+	PublicURL string
+	// OnConnected is called once the platform accepted the credentials.
+	OnConnected func(name string)
+	// Approvals is the tool-approval broker (nil = not wired).
+	Approvals Approvals
+	// Voice holds the speech providers (voice.go).
+	Voice VoiceOptions
+}
+
+// PendingDir is where the channel's pending / approved user stores live.
+func (e DriverEnv) PendingDir() string {
+	if e.AgentDir == "" {
+		return ""
+	}
+	return filepath.Join(e.AgentDir, "channels-pending")
+}
+
+// DriverSpec registers a platform.
+type DriverSpec struct {
+	Type string
+	// Required config keys; a channel missing any of them is not started.
+	Required []string
+	// UniqueKey is the config key identifying the bot account (e.g.
+	// "botToken"); one account may only be bound to one agent. "" = no check.
+	UniqueKey string
+	// New builds a driver; it must not block or touch the network.
+	New func(env DriverEnv) (Driver, error)
+	// Test verifies credentials and returns the bot's display name.
+	Test func(ctx context.Context, cfg map[string]string) (string, error)
+	// NumericIDs marks platforms whose user IDs are int64 (Telegram):
+	// pending / approved users live in PendingStore / ApprovedStore instead
+	// of the string-keyed *Str stores.
+	NumericIDs bool
+}
+
+// Ready reports whether cfg has every required key set (masked "***"
+// placeholders from the UI do not count).
+func (s DriverSpec) Ready(cfg map[string]string) bool {
+	for _, k := range s.Required {
+		v := cfg[k]
+		if v == "" || strings.HasSuffix(v, "***") {
+			return false
+		}
+	}
+	return true
+}
+
+// restartExempt are config keys a running driver does not read at start:
+// botName is display-only (written back by Test) and allowedFrom is read
+// through DriverEnv.AllowFrom on every message.
+var restartExempt = map[string]bool{"botName": true, "allowedFrom": true}
+
+// ConfigChanged reports whether a restart is needed between two configs:
+// any key other than the restartExempt ones was added, removed or changed.
+// Optional keys count too (e.g. Slack's appToken switches transports).
+func (s DriverSpec) ConfigChanged(old, cur map[string]string) bool {
+	for k, v := range cur {
+		if !restartExempt[k] && old[k] != v {
+			return true
+		}
+	}
+	for k, v := range old {
+		if !restartExempt[k] && cur[k] != v {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	driversMu sync.RWMutex
+	drivers   = map[string]DriverSpec{}
+)
+
+// RegisterDriver adds a platform; drivers call it from init. Registering
+// the same type twice panics (programming error).
+func RegisterDriver(spec DriverSpec) {
+	if spec.Type == "" || spec.New == nil {
+		panic("channel: RegisterDriver needs Type and New")
+	}
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if _, dup := drivers[spec.Type]; dup {
+		panic(fmt.Sprintf("channel: driver %q registered twice", spec.Type))
+	}
+	drivers[spec.Type] = spec
+}
+
+// LookupDriver returns the spec registered for a channel type.
+func LookupDriver(channelType string) (DriverSpec, bool) {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	s, ok := drivers[channelType]
+	return s, ok
+}
+
+// DriverTypes lists the registered channel types, sorted.
+func DriverTypes() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	out := make([]string, 0, len(drivers))
+	for t := range drivers {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NewDriver builds the driver for env using the registered spec.
+func NewDriver(channelType string, env DriverEnv) (Driver, error) {
+	spec, ok := LookupDriver(channelType)
+	if !ok {
+		return nil, fmt.Errorf("unknown channel type %q", channelType)
+	}
+	if !spec.Ready(env.Config) {
+		return nil, fmt.Errorf("%s channel needs %s", channelType, strings.Join(spec.Required, ", "))
+	}
+	return spec.New(env)
+}
@@ -0,0 +1,158 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDriver records outbound calls.
+type fakeDriver struct {
+	caps Capabilities
+	mu   sync.Mutex
+	sent []string
+	edit []string
+}
+
+func (f *fakeDriver) Type() string               { return "fake" }
+func (f *fakeDriver) Capabilities() Capabilities { return f.caps }
+func (f *fakeDriver) Start(ctx context.Context)  { <-ctx.Done() }
+func (f *fakeDriver) Send(_ context.Context, _ ChatRef, text, _ string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, text)
+	return "m1", nil
+}
+func (f *fakeDriver) Edit(_ context.Context, _ ChatRef, _, text string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.edit = append(f.edit, text)
+	return nil
+}
+func (f *fakeDriver) Typing(context.Context, ChatRef) error { return nil }
+func (f *fakeDriver) SendFile(context.Context, ChatRef, string) (string, error) {
+	return "", errors.New("unsupported")
+}
+func (f *fakeDriver) ProactiveSend(string) error { return nil }
+
+func TestBuiltinDriversRegistered(t *testing.T) {
+	types := strings.Join(DriverTypes(), ",")
+	if !strings.Contains(types, "feishu") || !strings.Contains(types, "telegram") || !strings.Contains(types, "slack") || !strings.Contains(types, "discord") || !strings.Contains(types, "email") ||
+		!strings.Contains(types, "dingtalk") || !strings.Contains(types, "wecom") || !strings.Contains(types, "webhook") {
+		t.Fatalf("DriverTypes = %s", types)
+	}
+	spec, _ := LookupDriver("feishu")
+	if spec.Ready(map[string]string{"appId": "a"}) || spec.Ready(map[string]string{"appId": "a", "appSecret": "ab***"}) {
+		t.Error("feishu ready without a real appSecret")
+	}
+	if !spec.ConfigChanged(map[string]string{"appId": "a"}, map[string]string{"appId": "b"}) ||
+		!spec.ConfigChanged(map[string]string{"appId": "a", "x": "1"}, map[string]string{"appId": "a", "x": "2"}) ||
+		!spec.ConfigChanged(map[string]string{"appId": "a", "x": "1"}, map[string]string{"appId": "a"}) {
+		t.Error("ConfigChanged should see optional keys")
+	}
+	if spec.ConfigChanged(map[string]string{"appId": "a", "botName": "old"}, map[string]string{"appId": "a", "botName": "new", "allowedFrom": "1"}) {
+		t.Error("ConfigChanged should ignore botName / allowedFrom")
+	}
+	if _, err := NewDriver("telegram", DriverEnv{}); err == nil {
+		t.Error("telegram driver built without botToken")
+	}
+	if _, err := NewDriver("slack", DriverEnv{Config: map[string]string{"botToken": "xoxb"}}); err == nil {
+		t.Error("slack driver built without appToken or signingSecret")
+	}
+	if _, err := NewDriver("nope", DriverEnv{}); err == nil {
+		t.Error("unknown type accepted")
+	}
+	d, err := NewDriver("telegram", DriverEnv{AgentID: "a1", ChannelID: "c1", Config: map[string]string{"botToken": "t"}})
+	if err != nil || d.Type() != "telegram" {
+		t.Fatalf("NewDriver telegram = %v, %v", d, err)
+	}
+}
+
+type recordedPending struct{ added, removed []string }
+
+func (r *recordedPending) Add(s Sender)     { r.added = append(r.added, s.ID) }
+func (r *recordedPending) Remove(id string) { r.removed = append(r.removed, id) }
+
+func TestPipelineCheck(t *testing.T) {
+	var allow []string
+	rec := &recordedPending{}
+	p := &Pipeline{Env: DriverEnv{AllowFrom: func() []string { return allow }}, Pending: rec}
+
+	if got := p.Check(Sender{ID: "1"}, true); got != AccessPairing {
+		t.Errorf("empty allowlist = %v, want pairing", got)
+	}
+	allow = []string{"2"}
+	if got := p.Check(Sender{ID: "1"}, true); got != AccessDenied {
+		t.Errorf("stranger = %v, want denied", got)
+	}
+	if got := p.Check(Sender{ID: "2"}, true); got != AccessAllowed {
+		t.Errorf("allowed = %v", got)
+	}
+	p.Check(Sender{ID: "3"}, false)
+	if strings.Join(rec.added, ",") != "1,1" || strings.Join(rec.removed, ",") != "2" {
+		t.Errorf("pending added=%v removed=%v", rec.added, rec.removed)
+	}
+}
+
+func streamOf(evs ...StreamEvent) <-chan StreamEvent {
+	ch := make(chan StreamEvent, len(evs))
+	for _, ev := range evs {
+		ch <- ev
+	}
+	close(ch)
+	return ch
+}
+
+func TestStreamReply(t *testing.T) {
+	ctx := context.Background()
+
+	// No Edit capability: a single final message.
+	plain := &fakeDriver{}
+	final, err := StreamReply(ctx, plain, ChatRef{ID: "c"}, "", streamOf(
+		StreamEvent{Type: "text_delta", Text: "hel"},
+		StreamEvent{Type: "text_delta", Text: "lo "},
+		StreamEvent{Type: "done"},
+	))
+	if err != nil || final != "hello " || strings.Join(plain.sent, "|") != "hello" || len(plain.edit) != 0 {
+		t.Errorf("plain: final=%q err=%v sent=%v edit=%v", final, err, plain.sent, plain.edit)
+	}
+
+	// Placeholder + edit; runner errors are surfaced in the text.
+	card := &fakeDriver{caps: Capabilities{Edit: true, EditEvery: time.Hour, Placeholder: "..."}}
+	boom := errors.New("boom")
+	_, err = StreamReply(ctx, card, ChatRef{ID: "c"}, "", streamOf(
+		StreamEvent{Type: "text_delta", Text: "partial"},
+		StreamEvent{Type: "error", Err: boom},
+	))
+	if !errors.Is(err, boom) || strings.Join(card.sent, "|") != "..." || len(card.edit) != 1 || card.edit[0] != "partial\n⚠️ boom" {
+		t.Errorf("card: err=%v sent=%v edit=%v", err, card.sent, card.edit)
+	}
+
+	// Nothing produced.
+	empty := &fakeDriver{}
+	if _, _ = StreamReply(ctx, empty, ChatRef{ID: "c"}, "", streamOf()); strings.Join(empty.sent, "|") != "(no response)" {
+		t.Errorf("empty: sent=%v", empty.sent)
+	}
+}
+
+func TestBotPoolGeneric(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	p := NewBotPool(ctx)
+	p.Start("a1", "tg-2", &fakeDriver{})
+	p.Start("a1", "tg-1", &fakeDriver{})
+	p.Start("a2", "x", &fakeDriver{})
+	if _, id, ok := p.First("a1"); !ok || id != "tg-1" {
+		t.Errorf("First = %s %v", id, ok)
+	}
+	if _, _, ok := p.FirstOfType("a1", "telegram"); ok {
+		t.Error("FirstOfType matched a fake driver")
+	}
+	p.StopAgent("a1")
+	if p.IsRunning("a1", "tg-1") || !p.IsRunning("a2", "x") {
+		t.Error("StopAgent stopped the wrong bots")
+	}
+}
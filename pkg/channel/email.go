@@ -0,0 +1,740 @@
+// Package channel — email inbox integration.
+//   - Inbound: polls IMAP (UNSEEN → \Seen) or a local maildir (new/ → cur/)
+//   - Conversations thread by Message-ID / In-Reply-To / References; each
+//     thread is one session: "email-{threadKey}"
+//   - text/plain (else text/html rendered to text) is the user turn; image
+//     and PDF attachments become MediaInput; quoted history is dropped
+//   - Replies go out over SMTP with In-Reply-To / References, so they land
+//     in the sender's thread
+//   - Allowlist entries are addresses or "@domain"; strangers, bounces and
+//     auto-replies are never answered (no backscatter, no mail loops)
+//   - approveReplies holds outgoing mail in an outbox until an admin
+//     approves it — once, or for the whole thread
+package channel
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+)
+
+// emailMaxRefs caps the References kept per thread (root + recent ids).
+const emailMaxRefs = 20
+
+// emailConfig is the parsed ChannelEntry.Config of an email channel.
+type emailConfig struct {
+	Address     string // the inbox address replies are sent from
+	DisplayName string
+
+	IMAPAddr     string // host:port ("" = use Maildir)
+	IMAPTLS      bool
+	IMAPUser     string
+	IMAPPassword string
+	Mailbox      string
+	Maildir      string
+
+	SMTPAddr     string // host:port; 465 = implicit TLS, else STARTTLS when offered
+	SMTPUser     string
+	SMTPPassword string
+
+	PollEvery      time.Duration
+	ApproveReplies bool
+}
+
+// parseEmailConfig reads the channel config keys (see docs/user-guide/channels.md).
+func parseEmailConfig(cfg map[string]string) (emailConfig, error) {
+	c := emailConfig{
+		Address:        strings.ToLower(strings.TrimSpace(cfg["address"])),
+		DisplayName:    cfg["displayName"],
+		IMAPAddr:       cfg["imapHost"],
+		IMAPTLS:        cfg["imapTLS"] != "false",
+		IMAPUser:       cfg["imapUser"],
+		IMAPPassword:   cfg["imapPassword"],
+		Mailbox:        cfg["mailbox"],
+		Maildir:        cfg["maildir"],
+		SMTPAddr:       cfg["smtpHost"],
+		SMTPUser:       cfg["smtpUser"],
+		SMTPPassword:   cfg["smtpPassword"],
+		PollEvery:      time.Minute,
+		ApproveReplies: cfg["approveReplies"] == "true",
+	}
+	if _, err := mail.ParseAddress(c.Address); err != nil {
+		return c, fmt.Errorf("email channel: invalid address %q", c.Address)
+	}
+	if c.IMAPAddr == "" && c.Maildir == "" {
+		return c, errors.New("email channel needs imapHost or maildir")
+	}
+	if c.IMAPAddr != "" {
+		c.IMAPAddr = withDefaultPort(c.IMAPAddr, map[bool]string{true: "993", false: "143"}[c.IMAPTLS])
+	}
+	c.SMTPAddr = withDefaultPort(c.SMTPAddr, "587")
+	if c.IMAPUser == "" {
+		c.IMAPUser = c.Address
+	}
+	if c.SMTPUser == "" {
+		c.SMTPUser = c.IMAPUser
+	}
+	if c.SMTPPassword == "" {
+		c.SMTPPassword = c.IMAPPassword
+	}
+	if s := cfg["pollSeconds"]; s != "" {
+		if d, err := time.ParseDuration(s + "s"); err == nil && d >= 15*time.Second {
+			c.PollEvery = d
+		}
+	}
+	return c, nil
+}
+
+func withDefaultPort(hostport, port string) string {
+	if hostport == "" {
+		return ""
+	}
+	if _, _, err := net.SplitHostPort(hostport); err == nil {
+		return hostport
+	}
+	return net.JoinHostPort(hostport, port)
+}
+
+// emailThread is the reply state of one conversation.
+type emailThread struct {
+	Key      string   `json:"key"`
+	Subject  string   `json:"subject"`
+	Peer     string   `json:"peer"` // address replies go to
+	PeerName string   `json:"peerName,omitempty"`
+	LastID   string   `json:"lastId"` // Message-ID the next reply answers
+	Refs     []string `json:"refs"`   // root first, then recent ids (ours and theirs)
+	// Approved lets replies in this thread skip the outbox.
+	Approved bool  `json:"approved,omitempty"`
+	Updated  int64 `json:"updated"`
+}
+
+// addRef appends id to the thread's references (root kept, cap emailMaxRefs).
+func (t *emailThread) addRef(id string) {
+	if id == "" {
+		return
+	}
+	for _, r := range t.Refs {
+		if r == id {
+			return
+		}
+	}
+	t.Refs = append(t.Refs, id)
+	if len(t.Refs) > emailMaxRefs {
+		t.Refs = append(t.Refs[:1], t.Refs[len(t.Refs)-emailMaxRefs+1:]...)
+	}
+}
+
+// ── EmailBot ──────────────────────────────────────────────────────────────
+
+type EmailBot struct {
+	cfg          emailConfig
+	agentID      string
+	agentDir     string
+	channelID    string
+	getAllowFrom func() []string
+
+	streamFunc   StreamFunc
+	pendingStore *PendingStoreStr
+	onConnected  func(name string)
+
+	source mailSource
+	dial   func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	runMu  sync.Mutex
+	runCtx context.Context
+
+	// threads are persisted so replies keep threading across restarts;
+	// byID indexes every known Message-ID → thread key.
+	threadsMu   sync.Mutex
+	threads     map[string]*emailThread
+	byID        map[string]string
+	threadsPath string
+
+	outboxMu   sync.Mutex
+	outbox     []OutboxDraft
+	outboxPath string
+
+	// chatMu serializes processing per thread to avoid concurrent runs.
+	chatMu sync.Map
+	// inflight tracks message handlers; Start returns once they finish.
+	inflight sync.WaitGroup
+}
+
+// NewEmailBotWithStream creates an EmailBot. stateDir holds the thread and
+// outbox files ("" = in memory only).
+func NewEmailBotWithStream(cfg emailConfig, agentID, agentDir, channelID, stateDir string, getAllowFrom func() []string, sf StreamFunc, pending *PendingStoreStr) *EmailBot {
+	b := &EmailBot{
+		cfg:          cfg,
+		agentID:      agentID,
+		agentDir:     agentDir,
+		channelID:    channelID,
+		getAllowFrom: getAllowFrom,
+		streamFunc:   sf,
+		pendingStore: pending,
+		dial:         netguard.DialContext,
+		runCtx:       context.Background(),
+		threads:      make(map[string]*emailThread),
+		byID:         make(map[string]string),
+	}
+	if cfg.Maildir != "" {
+		b.source = &maildirSource{dir: cfg.Maildir}
+	} else {
+		b.source = &imapSource{
+			addr: cfg.IMAPAddr, useTLS: cfg.IMAPTLS, user: cfg.IMAPUser, password: cfg.IMAPPassword,
+			mailbox: cfg.Mailbox, dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return b.dial(ctx, network, addr)
+			},
+		}
+	}
+	if stateDir != "" {
+		b.threadsPath = channelStorePath(stateDir, channelID, "-email-threads.json")
+		b.outboxPath = channelStorePath(stateDir, channelID, "-outbox.json")
+		b.load()
+	}
+	return b
+}
+
+// SetOnConnected sets a callback fired after the first successful poll.
+func (b *EmailBot) SetOnConnected(fn func(name string)) {
+	b.onConnected = fn
+}
+
+// Start polls the inbox every PollEvery until ctx is cancelled.
+func (b *EmailBot) Start(ctx context.Context) {
+	log.Printf("[email] starting agent=%s address=%s", b.agentID, b.cfg.Address)
+	b.runMu.Lock()
+	b.runCtx = ctx
+	b.runMu.Unlock()
+	defer b.inflight.Wait()
+
+	connected := false
+	for {
+		if err := b.source.Poll(ctx, func(raw []byte) error { return b.handleRaw(ctx, raw) }); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("[email] poll error agent=%s: %v", b.agentID, err)
+		} else if !connected {
+			connected = true
+			if b.onConnected != nil {
+				b.onConnected(b.cfg.Address)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(b.cfg.PollEvery):
+		}
+	}
+}
+
+func (b *EmailBot) ctx() context.Context {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+	return b.runCtx
+}
+
+// handleRaw filters and threads one message, then processes it on its own
+// goroutine. Returning nil marks the message seen, so only transient local
+// failures return an error.
+func (b *EmailBot) handleRaw(ctx context.Context, raw []byte) error {
+	m, err := parseEmail(raw)
+	if err != nil {
+		log.Printf("[email] unparsable message skipped: %v", err)
+		return nil
+	}
+	if m.From == "" || m.From == b.cfg.Address {
+		return nil
+	}
+	if m.AutoGenerated {
+		log.Printf("[email] auto-generated mail from %s skipped", m.From)
+		return nil
+	}
+	sender := Sender{ID: m.From, Name: m.FromName, Username: m.From}
+	if res := b.checkAccess(sender); res != AccessAllowed {
+		// No reply to strangers: answering unknown senders is backscatter.
+		log.Printf("[email] access %v — from=%s subject=%q", res, m.From, truncateStr(m.Subject, 60))
+		return nil
+	}
+	if m.MessageID == "" {
+		m.MessageID = newMessageID(m.From)
+	}
+	t, dup := b.threadFor(m)
+	if dup {
+		return nil
+	}
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		b.process(ctx, m, t)
+	}()
+	return nil
+}
+
+// emailAllowed reports whether addr matches an allowlist entry (address
+// or "@domain").
+func emailAllowed(allow []string, addr string) bool {
+	for _, a := range allow {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == addr || (strings.HasPrefix(a, "@") && strings.HasSuffix(addr, a)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAccess is Pipeline.Check with "@domain" entries.
+func (b *EmailBot) checkAccess(s Sender) AccessResult {
+	allow := b.getAllowFrom()
+	res := AccessDenied
+	if len(allow) == 0 {
+		res = AccessPairing
+	} else if emailAllowed(allow, s.ID) {
+		res = AccessAllowed
+	}
+	if rec := b.pendingStore.Recorder(); rec != nil {
+		if res == AccessAllowed {
+			rec.Remove(s.ID)
+		} else {
+			rec.Add(s)
+		}
+	}
+	return res
+}
+
+// threadFor finds (or starts) the message's thread and records it as the
+// latest turn. dup reports a Message-ID already seen.
+func (b *EmailBot) threadFor(m *emailMessage) (t emailThread, dup bool) {
+	b.threadsMu.Lock()
+	defer b.threadsMu.Unlock()
+	if _, seen := b.byID[m.MessageID]; seen {
+		return emailThread{}, true
+	}
+	var th *emailThread
+	for _, id := range append([]string{m.InReplyTo}, m.References...) {
+		if key, ok := b.byID[id]; ok && id != "" {
+			th = b.threads[key]
+			break
+		}
+	}
+	if th == nil {
+		root := m.MessageID
+		if len(m.References) > 0 {
+			root = m.References[0]
+		} else if m.InReplyTo != "" {
+			root = m.InReplyTo
+		}
+		sum := sha256.Sum256([]byte(root))
+		key := hex.EncodeToString(sum[:8])
+		th = b.threads[key]
+		if th == nil {
+			th = &emailThread{Key: key, Subject: m.Subject}
+			th.addRef(root)
+			b.threads[key] = th
+		}
+	}
+	for _, id := range m.References {
+		b.byID[id] = th.Key
+	}
+	th.addRef(m.MessageID)
+	b.byID[m.MessageID] = th.Key
+	th.Peer, th.PeerName, th.LastID = m.From, m.FromName, m.MessageID
+	if th.Subject == "" {
+		th.Subject = m.Subject
+	}
+	th.Updated = time.Now().UnixMilli()
+	b.saveThreadsLocked()
+	return *th, false
+}
+
+func (b *EmailBot) process(ctx context.Context, m *emailMessage, t emailThread) {
+	chat := ChatRef{ID: t.Key, Type: "private", Title: t.Subject}
+	pipe := b.pipeline()
+	sessionID := pipe.SessionID(chat)
+	muVal, _ := b.chatMu.LoadOrStore(sessionID, &sync.Mutex{})
+	mu := muVal.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	media, extras := emailMedia(m.Files)
+	body := m.Text
+	if len(extras) > 0 {
+		body = strings.TrimSpace(body + "\n\n" + strings.Join(extras, " "))
+	}
+	if body == "" && len(media) == 0 {
+		body = "（空邮件）"
+	}
+	log.Printf("[email] message from=%s thread=%s subject=%q", m.From, t.Key, truncateStr(m.Subject, 60))
+	in := InboundMessage{
+		ChannelType: "email",
+		ChannelID:   b.channelID,
+		MessageID:   m.MessageID,
+		Chat:        chat,
+		Sender:      Sender{ID: m.From, Name: m.FromName, Username: m.From},
+		Text:        fmt.Sprintf("主题：%s\n\n%s", m.Subject, body),
+		Media:       media,
+		ExtraContext: []string{fmt.Sprintf("当前邮件信息：from=%s，subject=%s，message_id=%s。你的回复会作为纯文本邮件正文回复给发件人，不要写邮件头。",
+			m.From, m.Subject, m.MessageID)},
+	}
+	pipe.LogInbound(in, body)
+	pipe.Dispatch(ctx, in)
+}
+
+// emailMedia keeps image / PDF attachments (max 5) as MediaInput; other
+// files become "[📎 name]" placeholders.
+func emailMedia(files []emailAttachment) ([]MediaInput, []string) {
+	const maxFiles = 5
+	var media []MediaInput
+	var extras []string
+	for _, f := range files {
+		vision := strings.HasPrefix(f.ContentType, "image/") || f.ContentType == "application/pdf"
+		if !vision || len(media) >= maxFiles || len(f.Data) == 0 {
+			extras = append(extras, "[📎 "+f.Name+"]")
+			continue
+		}
+		media = append(media, MediaInput{Data: f.Data, ContentType: f.ContentType, FileName: f.Name})
+	}
+	return media, extras
+}
+
+// ── Outgoing mail ─────────────────────────────────────────────────────────
+
+func (b *EmailBot) from() mail.Address {
+	return mail.Address{Name: b.cfg.DisplayName, Address: b.cfg.Address}
+}
+
+// reply sends (or queues) text as the next message of thread key.
+func (b *EmailBot) reply(ctx context.Context, key, text string) (string, error) {
+	b.threadsMu.Lock()
+	th, ok := b.threads[key]
+	var t emailThread
+	if ok {
+		t = *th
+		t.Refs = append([]string(nil), th.Refs...)
+	}
+	b.threadsMu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("email: unknown thread %q", key)
+	}
+	e := &outgoingEmail{
+		From:       b.from(),
+		To:         []string{t.Peer},
+		Subject:    replySubject(t.Subject),
+		Body:       text,
+		InReplyTo:  t.LastID,
+		References: t.Refs,
+	}
+	id, _, err := b.submit(ctx, key, e, t.Approved)
+	return id, err
+}
+
+// SendNew starts a new thread (email_send tool) and returns the Message-ID,
+// or the draft id with queued=true. Replies thread into "email-{key}".
+func (b *EmailBot) SendNew(ctx context.Context, to []string, subject, body string) (id string, queued bool, err error) {
+	var rcpts []string
+	for _, addr := range to {
+		a, err := mail.ParseAddress(addr)
+		if err != nil {
+			return "", false, fmt.Errorf("email: invalid recipient %q", addr)
+		}
+		rcpts = append(rcpts, strings.ToLower(a.Address))
+	}
+	if len(rcpts) == 0 {
+		return "", false, errors.New("email: no recipient")
+	}
+	e := &outgoingEmail{From: b.from(), To: rcpts, Subject: subject, Body: body, MessageID: newMessageID(b.cfg.Address)}
+	sum := sha256.Sum256([]byte(e.MessageID))
+	key := hex.EncodeToString(sum[:8])
+	b.threadsMu.Lock()
+	th := &emailThread{Key: key, Subject: subject, Peer: rcpts[0], LastID: e.MessageID, Updated: time.Now().UnixMilli()}
+	th.addRef(e.MessageID)
+	b.threads[key] = th
+	b.byID[e.MessageID] = key
+	b.saveThreadsLocked()
+	b.threadsMu.Unlock()
+	return b.submit(ctx, key, e, false)
+}
+
+// submit delivers e, or queues it in the outbox when approveReplies is on
+// and the thread is not approved. Returns the Message-ID, or the draft id
+// with queued=true.
+func (b *EmailBot) submit(ctx context.Context, key string, e *outgoingEmail, approved bool) (string, bool, error) {
+	if b.cfg.ApproveReplies && !approved {
+		d := OutboxDraft{
+			ID:         "draft-" + uuid.New().String()[:8],
+			ThreadKey:  key,
+			To:         e.To,
+			Subject:    e.Subject,
+			Body:       e.Body,
+			MessageID:  e.MessageID,
+			InReplyTo:  e.InReplyTo,
+			References: e.References,
+			CreatedAt:  time.Now().UnixMilli(),
+		}
+		b.outboxMu.Lock()
+		b.outbox = append(b.outbox, d)
+		b.saveOutboxLocked()
+		b.outboxMu.Unlock()
+		log.Printf("[email] reply queued for approval draft=%s thread=%s to=%v", d.ID, key, e.To)
+		return d.ID, true, nil
+	}
+	if err := b.deliver(ctx, e); err != nil {
+		return "", false, err
+	}
+	return e.MessageID, false, nil
+}
+
+// deliver sends e over SMTP and records its Message-ID in the thread.
+func (b *EmailBot) deliver(ctx context.Context, e *outgoingEmail) error {
+	if e.MessageID == "" {
+		e.MessageID = newMessageID(b.cfg.Address)
+	}
+	msg := composeEmail(e, time.Now())
+	if err := b.smtpSend(ctx, e.From.Address, e.To, msg); err != nil {
+		return fmt.Errorf("email: smtp: %w", err)
+	}
+	b.threadsMu.Lock()
+	if key, ok := b.byID[e.InReplyTo]; ok {
+		if th := b.threads[key]; th != nil {
+			th.addRef(e.MessageID)
+			th.Updated = time.Now().UnixMilli()
+			b.byID[e.MessageID] = key
+			b.saveThreadsLocked()
+		}
+	}
+	b.threadsMu.Unlock()
+	log.Printf("[email] sent to=%v subject=%q", e.To, truncateStr(e.Subject, 60))
+	return nil
+}
+
+// ── Outbox (approveReplies) ───────────────────────────────────────────────
+
+// Drafts implements Outbox.
+func (b *EmailBot) Drafts() []OutboxDraft {
+	b.outboxMu.Lock()
+	defer b.outboxMu.Unlock()
+	out := append([]OutboxDraft(nil), b.outbox...)
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt < out[j].CreatedAt })
+	return out
+}
+
+// takeDraft removes and returns draft id.
+func (b *EmailBot) takeDraft(id string) (OutboxDraft, bool) {
+	b.outboxMu.Lock()
+	defer b.outboxMu.Unlock()
+	for i, d := range b.outbox {
+		if d.ID == id {
+			b.outbox = append(b.outbox[:i], b.outbox[i+1:]...)
+			b.saveOutboxLocked()
+			return d, true
+		}
+	}
+	return OutboxDraft{}, false
+}
+
+// ApproveDraft implements Outbox: sends the draft; wholeThread also lets
+// later replies in its thread go out without approval.
+func (b *EmailBot) ApproveDraft(ctx context.Context, id string, wholeThread bool) error {
+	d, ok := b.takeDraft(id)
+	if !ok {
+		return fmt.Errorf("draft %q not found", id)
+	}
+	e := &outgoingEmail{
+		From: b.from(), To: d.To, Subject: d.Subject, Body: d.Body,
+		MessageID: d.MessageID, InReplyTo: d.InReplyTo, References: d.References,
+	}
+	if err := b.deliver(ctx, e); err != nil {
+		b.outboxMu.Lock()
+		b.outbox = append(b.outbox, d)
+		b.saveOutboxLocked()
+		b.outboxMu.Unlock()
+		return err
+	}
+	if wholeThread {
+		b.threadsMu.Lock()
+		if th := b.threads[d.ThreadKey]; th != nil {
+			th.Approved = true
+			b.saveThreadsLocked()
+		}
+		b.threadsMu.Unlock()
+	}
+	return nil
+}
+
+// DiscardDraft implements Outbox.
+func (b *EmailBot) DiscardDraft(id string) error {
+	if _, ok := b.takeDraft(id); !ok {
+		return fmt.Errorf("draft %q not found", id)
+	}
+	return nil
+}
+
+// ── Persistence ───────────────────────────────────────────────────────────
+
+func (b *EmailBot) load() {
+	if data, err := os.ReadFile(b.threadsPath); err == nil {
+		var list []*emailThread
+		if json.Unmarshal(data, &list) == nil {
+			for _, t := range list {
+				b.threads[t.Key] = t
+				for _, id := range t.Refs {
+					b.byID[id] = t.Key
+				}
+			}
+		}
+	}
+	if data, err := os.ReadFile(b.outboxPath); err == nil {
+		_ = json.Unmarshal(data, &b.outbox)
+	}
+}
+
+// saveThreadsLocked persists threads; callers hold threadsMu. Only the
+// 500 most recent threads are kept.
+func (b *EmailBot) saveThreadsLocked() {
+	if b.threadsPath == "" {
+		return
+	}
+	list := make([]*emailThread, 0, len(b.threads))
+	for _, t := range b.threads {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Updated > list[j].Updated })
+	if len(list) > 500 {
+		for _, t := range list[500:] {
+			delete(b.threads, t.Key)
+		}
+		list = list[:500]
+	}
+	data, _ := json.MarshalIndent(list, "", "  ")
+	_ = os.WriteFile(b.threadsPath, data, 0600)
+}
+
+func (b *EmailBot) saveOutboxLocked() {
+	if b.outboxPath == "" {
+		return
+	}
+	data, _ := json.MarshalIndent(b.outbox, "", "  ")
+	_ = os.WriteFile(b.outboxPath, data, 0600)
+}
+
+// ── SMTP ──────────────────────────────────────────────────────────────────
+
+// smtpSend submits msg: implicit TLS on port 465, else STARTTLS when the
+// server offers it; AUTH PLAIN when a user is configured (net/smtp refuses
+// PLAIN over cleartext except to localhost).
+func (b *EmailBot) smtpSend(ctx context.Context, from string, to []string, msg []byte) error {
+	c, err := b.smtpClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := c.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("rcpt %s: %w", rcpt, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// smtpClient dials, secures and authenticates an SMTP session.
+func (b *EmailBot) smtpClient(ctx context.Context) (*smtp.Client, error) {
+	host, port, err := net.SplitHostPort(b.cfg.SMTPAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := b.dial(ctx, "tcp", b.cfg.SMTPAddr)
+	if err != nil {
+		return nil, err
+	}
+	_ = conn.SetDeadline(time.Now().Add(time.Minute))
+	tlsCfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if port == "465" {
+		conn = tls.Client(conn, tlsCfg)
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	helo := "localhost"
+	if i := strings.LastIndexByte(b.cfg.Address, '@'); i >= 0 {
+		helo = b.cfg.Address[i+1:]
+	}
+	if err := c.Hello(helo); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok && port != "465" {
+		if err := c.StartTLS(tlsCfg); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("starttls: %w", err)
+		}
+	}
+	if b.cfg.SMTPUser != "" && b.cfg.SMTPPassword != "" {
+		if err := c.Auth(smtp.PlainAuth("", b.cfg.SMTPUser, b.cfg.SMTPPassword, host)); err != nil {
+			c.Close()
+			return nil, fmt.Errorf("auth: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// TestEmailChannel verifies the inbox and SMTP credentials and returns the
+// channel's address.
+func TestEmailChannel(ctx context.Context, cfg map[string]string) (string, error) {
+	c, err := parseEmailConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	b := NewEmailBotWithStream(c, "", "", "", "", func() []string { return nil }, nil, nil)
+	if src, ok := b.source.(*imapSource); ok {
+		conn, err := src.connect(ctx)
+		if err != nil {
+			return "", fmt.Errorf("imap: %w", err)
+		}
+		defer conn.conn.Close()
+		if _, err := conn.cmd("LOGIN " + imapQuote(src.user) + " " + imapQuote(src.password)); err != nil {
+			return "", fmt.Errorf("imap login: %w", err)
+		}
+		_, _ = conn.cmd("LOGOUT")
+	} else if _, err := os.Stat(filepath.Join(c.Maildir, "new")); err != nil {
+		return "", fmt.Errorf("maildir: %w", err)
+	}
+	sc, err := b.smtpClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("smtp: %w", err)
+	}
+	_ = sc.Quit()
+	return c.Address, nil
+}
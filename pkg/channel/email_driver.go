@@ -0,0 +1,114 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// EmailBot implements Driver, Notifier and Outbox.
+var (
+	_ Driver   = (*EmailBot)(nil)
+	_ Notifier = (*EmailBot)(nil)
+	_ Outbox   = (*EmailBot)(nil)
+)
+
+// Senders are addresses, so email keeps its pending / approved senders in
+// the string-keyed stores.
+func init() {
+	RegisterDriver(DriverSpec{
+		Type:      "email",
+		Required:  []string{"address", "smtpHost"},
+		UniqueKey: "address",
+		New:       newEmailDriver,
+		Test:      TestEmailChannel,
+	})
+}
+
+func newEmailDriver(env DriverEnv) (Driver, error) {
+	cfg, err := parseEmailConfig(env.Config)
+	if err != nil {
+		return nil, err
+	}
+	var pending *PendingStoreStr
+	if dir := env.PendingDir(); dir != "" {
+		pending = NewPendingStoreStr(dir, env.ChannelID)
+	}
+	getAllowFrom := env.AllowFrom
+	if getAllowFrom == nil {
+		getAllowFrom = func() []string { return nil }
+	}
+	bot := NewEmailBotWithStream(cfg, env.AgentID, env.AgentDir, env.ChannelID, env.PendingDir(), getAllowFrom, env.Stream, pending)
+	bot.SetOnConnected(env.OnConnected)
+	return bot, nil
+}
+
+// pipeline returns the shared message path bound to this bot. Access is
+// checked by checkAccess ("@domain" entries), not Pipeline.Check.
+func (b *EmailBot) pipeline() *Pipeline {
+	return &Pipeline{
+		Env: DriverEnv{
+			AgentID:   b.agentID,
+			AgentDir:  b.agentDir,
+			ChannelID: b.channelID,
+			Stream:    b.streamFunc,
+			AllowFrom: b.getAllowFrom,
+		},
+		Driver: b,
+	}
+}
+
+// ── Driver ────────────────────────────────────────────────────────────────
+
+// Type implements Driver.
+func (b *EmailBot) Type() string { return "email" }
+
+// Capabilities implements Driver. Mail cannot be edited, so each reply is
+// sent once, complete.
+func (b *EmailBot) Capabilities() Capabilities { return Capabilities{} }
+
+// Send implements Driver: replies in the thread chat.ID (the session's
+// thread key). With approveReplies the reply may be held as a draft.
+func (b *EmailBot) Send(ctx context.Context, chat ChatRef, text, replyTo string) (string, error) {
+	return b.reply(ctx, chat.ID, text)
+}
+
+// Edit implements Driver (unsupported).
+func (b *EmailBot) Edit(ctx context.Context, chat ChatRef, msgID, text string) error {
+	return errors.New("email: edit not supported")
+}
+
+// Typing implements Driver (no-op).
+func (b *EmailBot) Typing(ctx context.Context, chat ChatRef) error { return nil }
+
+// SendFile implements Driver (unsupported; replies are plain text).
+func (b *EmailBot) SendFile(ctx context.Context, chat ChatRef, path string) (string, error) {
+	return "", errors.New("email: file attachments not supported")
+}
+
+// ProactiveSend implements Driver: a new mail to every plain address in
+// the allowlist ("@domain" entries name no recipient). The first line of
+// text becomes the subject.
+func (b *EmailBot) ProactiveSend(text string) error {
+	var to []string
+	for _, a := range b.getAllowFrom() {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a != "" && !strings.HasPrefix(a, "@") {
+			to = append(to, a)
+		}
+	}
+	if len(to) == 0 {
+		return errors.New("email: no recipient in allowedFrom")
+	}
+	subject, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	_, _, err := b.SendNew(b.ctx(), to, truncateStr(subject, 60), text)
+	return err
+}
+
+// Notify runs the agent on prompt in a thread's session and mails the reply.
+func (b *EmailBot) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	if chat.Type == "" {
+		chat.Type = "private"
+	}
+	return b.pipeline().Notify(ctx, chat, prompt)
+}
@@ -0,0 +1,264 @@
+// pkg/channel/email_imap.go — inbound mail sources: a minimal IMAP4rev1
+// client (LOGIN / SELECT / UID SEARCH / UID FETCH / UID STORE) and a local
+// maildir reader.
+package channel
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// emailMaxPerPoll caps how many messages one poll takes in.
+const emailMaxPerPoll = 20
+
+// mailSource delivers unseen messages. Poll calls handle for each; a nil
+// return marks the message processed (IMAP \Seen, maildir new/ → cur/).
+type mailSource interface {
+	Poll(ctx context.Context, handle func(raw []byte) error) error
+}
+
+// ── IMAP ──────────────────────────────────────────────────────────────────
+
+type imapSource struct {
+	addr     string // host:port
+	useTLS   bool   // implicit TLS (993)
+	user     string
+	password string
+	mailbox  string
+	dial     func(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// imapResp is one untagged response; literals ({n} payloads) are cut out
+// of Line and kept in order.
+type imapResp struct {
+	Line     string
+	Literals [][]byte
+}
+
+type imapConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	tag  int
+}
+
+func (s *imapSource) connect(ctx context.Context) (*imapConn, error) {
+	raw, err := s.dial(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, err
+	}
+	if s.useTLS {
+		host, _, _ := net.SplitHostPort(s.addr)
+		tc := tls.Client(raw, &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			raw.Close()
+			return nil, fmt.Errorf("imap tls: %w", err)
+		}
+		raw = tc
+	}
+	_ = raw.SetDeadline(time.Now().Add(2 * time.Minute))
+	c := &imapConn{conn: raw, r: bufio.NewReader(raw)}
+	greeting, err := c.readLine()
+	if err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("imap greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		raw.Close()
+		return nil, fmt.Errorf("imap greeting: %s", greeting)
+	}
+	return c, nil
+}
+
+// Poll implements mailSource.
+func (s *imapSource) Poll(ctx context.Context, handle func(raw []byte) error) error {
+	c, err := s.connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.conn.Close()
+	stop := context.AfterFunc(ctx, func() { _ = c.conn.Close() })
+	defer stop()
+
+	if _, err := c.cmd("LOGIN " + imapQuote(s.user) + " " + imapQuote(s.password)); err != nil {
+		return fmt.Errorf("imap login: %w", err)
+	}
+	mailbox := s.mailbox
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	if _, err := c.cmd("SELECT " + imapQuote(mailbox)); err != nil {
+		return fmt.Errorf("imap select %s: %w", mailbox, err)
+	}
+	resps, err := c.cmd("UID SEARCH UNSEEN")
+	if err != nil {
+		return fmt.Errorf("imap search: %w", err)
+	}
+	var uids []uint64
+	for _, r := range resps {
+		if rest, ok := strings.CutPrefix(r.Line, "* SEARCH"); ok {
+			for _, f := range strings.Fields(rest) {
+				if n, err := strconv.ParseUint(f, 10, 32); err == nil {
+					uids = append(uids, n)
+				}
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	if len(uids) > emailMaxPerPoll {
+		uids = uids[:emailMaxPerPoll]
+	}
+	for _, uid := range uids {
+		resps, err := c.cmd(fmt.Sprintf("UID FETCH %d (BODY.PEEK[])", uid))
+		if err != nil {
+			return fmt.Errorf("imap fetch %d: %w", uid, err)
+		}
+		var body []byte
+		for _, r := range resps {
+			if strings.Contains(r.Line, " FETCH ") && len(r.Literals) > 0 {
+				body = r.Literals[0]
+			}
+		}
+		if body == nil {
+			continue
+		}
+		if err := handle(body); err != nil {
+			continue // left unseen; retried next poll
+		}
+		if _, err := c.cmd(fmt.Sprintf(`UID STORE %d +FLAGS.SILENT (\Seen)`, uid)); err != nil {
+			return fmt.Errorf("imap store %d: %w", uid, err)
+		}
+	}
+	_, _ = c.cmd("LOGOUT")
+	return nil
+}
+
+// cmd sends one tagged command and collects untagged responses until the
+// tagged completion; NO / BAD become errors.
+func (c *imapConn) cmd(command string) ([]imapResp, error) {
+	c.tag++
+	tag := fmt.Sprintf("Z%03d", c.tag)
+	if _, err := io.WriteString(c.conn, tag+" "+command+"\r\n"); err != nil {
+		return nil, err
+	}
+	var out []imapResp
+	for {
+		r, err := c.readResp()
+		if err != nil {
+			return out, err
+		}
+		if rest, ok := strings.CutPrefix(r.Line, tag+" "); ok {
+			if strings.HasPrefix(rest, "OK") {
+				return out, nil
+			}
+			return out, errors.New(rest)
+		}
+		out = append(out, r)
+	}
+}
+
+// readResp reads one response line, following {n} literals.
+func (c *imapConn) readResp() (imapResp, error) {
+	var r imapResp
+	var sb strings.Builder
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return r, err
+		}
+		n, ok := imapLiteralSize(line)
+		if !ok {
+			sb.WriteString(line)
+			r.Line = sb.String()
+			return r, nil
+		}
+		sb.WriteString(line[:strings.LastIndexByte(line, '{')])
+		if n > emailMaxPartBytes*2 {
+			return r, fmt.Errorf("imap literal too large (%d bytes)", n)
+		}
+		lit := make([]byte, n)
+		if _, err := io.ReadFull(c.r, lit); err != nil {
+			return r, err
+		}
+		r.Literals = append(r.Literals, lit)
+	}
+}
+
+func (c *imapConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// imapLiteralSize parses a trailing "{n}" literal marker.
+func imapLiteralSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	i := strings.LastIndexByte(line, '{')
+	if i < 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[i+1:len(line)-1], "+"))
+	return n, err == nil && n >= 0
+}
+
+// imapQuote renders s as an IMAP quoted string.
+func imapQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// ── maildir ───────────────────────────────────────────────────────────────
+
+// maildirSource reads new/ of a local maildir (e.g. delivered by an MTA)
+// and moves processed messages to cur/ flagged seen.
+type maildirSource struct {
+	dir string
+}
+
+// Poll implements mailSource.
+func (s *maildirSource) Poll(ctx context.Context, handle func(raw []byte) error) error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, "new"))
+	if err != nil {
+		return fmt.Errorf("maildir: %w", err)
+	}
+	n := 0
+	for _, e := range entries {
+		if ctx.Err() != nil || n >= emailMaxPerPoll {
+			break
+		}
+		if e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		n++
+		src := filepath.Join(s.dir, "new", e.Name())
+		raw, err := os.ReadFile(src)
+		if err != nil {
+			continue
+		}
+		if err := handle(raw); err != nil {
+			continue
+		}
+		name := e.Name()
+		if !strings.Contains(name, ":2,") {
+			name += ":2,S"
+		}
+		_ = os.MkdirAll(filepath.Join(s.dir, "cur"), 0o700)
+		if err := os.Rename(src, filepath.Join(s.dir, "cur", name)); err != nil {
+			return fmt.Errorf("maildir: %w", err)
+		}
+	}
+	return nil
+}
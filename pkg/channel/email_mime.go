@@ -0,0 +1,283 @@
+// pkg/channel/email_mime.go — RFC 5322 / MIME parsing and composing for
+// the email channel.
+package channel
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+// emailMaxPartBytes caps one decoded MIME part.
+const emailMaxPartBytes = 20 << 20
+
+// emailMessage is a parsed inbound mail.
+type emailMessage struct {
+	MessageID  string
+	InReplyTo  string
+	References []string
+	From       string // lower-cased address
+	FromName   string
+	Subject    string
+	Text       string // text/plain body, else text/html rendered to text
+	Files      []emailAttachment
+	// AutoGenerated marks bounces, vacation replies and list traffic
+	// (RFC 3834 Auto-Submitted, Precedence) — never answered.
+	AutoGenerated bool
+}
+
+type emailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// emailWordDecoder decodes RFC 2047 header words in any charset htmlindex knows.
+var emailWordDecoder = &mime.WordDecoder{CharsetReader: emailCharsetReader}
+
+func emailCharsetReader(charset string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "utf8", "us-ascii", "ascii":
+		return r, nil
+	}
+	enc, err := htmlindex.Get(charset)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported charset %q", charset)
+	}
+	return enc.NewDecoder().Reader(r), nil
+}
+
+// parseEmail parses a raw RFC 5322 message.
+func parseEmail(raw []byte) (*emailMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	h := msg.Header
+	m := &emailMessage{
+		MessageID:  emailFirstID(h.Get("Message-Id")),
+		InReplyTo:  emailFirstID(h.Get("In-Reply-To")),
+		References: emailIDs(h.Get("References")),
+	}
+	if subj, err := emailWordDecoder.DecodeHeader(h.Get("Subject")); err == nil {
+		m.Subject = strings.TrimSpace(subj)
+	} else {
+		m.Subject = strings.TrimSpace(h.Get("Subject"))
+	}
+	addrParser := mail.AddressParser{WordDecoder: emailWordDecoder}
+	if from, err := addrParser.Parse(h.Get("From")); err == nil {
+		m.From, m.FromName = strings.ToLower(from.Address), from.Name
+	}
+	auto := strings.ToLower(h.Get("Auto-Submitted"))
+	prec := strings.ToLower(h.Get("Precedence"))
+	m.AutoGenerated = (auto != "" && auto != "no") || prec == "bulk" || prec == "list" || prec == "junk" ||
+		h.Get("List-Id") != "" || h.Get("X-Autoreply") != "" || strings.HasPrefix(strings.ToLower(m.From), "mailer-daemon@")
+
+	var plain, htmlBody string
+	walkEmailPart(h, msg.Body, 0, func(ct, name string, data []byte) {
+		switch {
+		case name == "" && ct == "text/plain" && plain == "":
+			plain = string(data)
+		case name == "" && ct == "text/html" && htmlBody == "":
+			htmlBody = string(data)
+		case name != "" || !strings.HasPrefix(ct, "text/"):
+			if name == "" {
+				name = "attachment"
+			}
+			m.Files = append(m.Files, emailAttachment{Name: name, ContentType: ct, Data: data})
+		}
+	})
+	if plain != "" {
+		m.Text = plain
+	} else if htmlBody != "" {
+		m.Text = emailHTMLText(htmlBody)
+	}
+	m.Text = emailStripQuoted(strings.ReplaceAll(m.Text, "\r\n", "\n"))
+	return m, nil
+}
+
+// partHeader is mail.Header or textproto.MIMEHeader.
+type partHeader interface{ Get(string) string }
+
+// walkEmailPart decodes one MIME entity (recursing into multipart/*) and
+// reports leaves as (media type, attachment file name or "", decoded bytes).
+// Text parts are converted to UTF-8.
+func walkEmailPart(h partHeader, body io.Reader, depth int, leaf func(ct, name string, data []byte)) {
+	ct, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		ct, params = "text/plain", map[string]string{}
+	}
+	if strings.HasPrefix(ct, "multipart/") {
+		if depth > 8 || params["boundary"] == "" {
+			return
+		}
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextRawPart()
+			if err != nil {
+				return
+			}
+			walkEmailPart(part.Header, part, depth+1, leaf)
+		}
+	}
+	switch strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(io.LimitReader(body, emailMaxPartBytes))
+	if err != nil {
+		return
+	}
+	name := ""
+	if _, dp, err := mime.ParseMediaType(h.Get("Content-Disposition")); err == nil {
+		name = dp["filename"]
+	}
+	if name == "" {
+		name = params["name"]
+	}
+	if name != "" {
+		if dec, err := emailWordDecoder.DecodeHeader(name); err == nil {
+			name = dec
+		}
+	}
+	if strings.HasPrefix(ct, "text/") && name == "" {
+		if r, err := emailCharsetReader(params["charset"], bytes.NewReader(data)); err == nil {
+			if utf, err := io.ReadAll(r); err == nil {
+				data = utf
+			}
+		}
+	}
+	leaf(ct, name, data)
+}
+
+var (
+	emailIDRe        = regexp.MustCompile(`<[^<>\s]+>`)
+	emailDropBlockRe = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	emailBreakRe     = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|tr|h[1-6])>`)
+	emailTagRe       = regexp.MustCompile(`<[^>]*>`)
+	emailBlankRe     = regexp.MustCompile(`\n{3,}`)
+	// emailQuoteHeadRe matches the "On <date>, <name> wrote:" line mail
+	// clients put above a quoted reply (English and Chinese clients).
+	emailQuoteHeadRe = regexp.MustCompile(`(?m)^(On .+ wrote:|在.+写道：|-+ ?Original Message ?-+|-+ ?原始邮件 ?-+)\s*$`)
+)
+
+// emailFirstID returns the first <msg-id> in a header ("" = none).
+func emailFirstID(v string) string {
+	if ids := emailIDs(v); len(ids) > 0 {
+		return ids[0]
+	}
+	return ""
+}
+
+// emailIDs extracts every <msg-id> from a References-style header.
+func emailIDs(v string) []string {
+	return emailIDRe.FindAllString(v, -1)
+}
+
+// emailHTMLText renders an HTML body to plain text (good enough for mail:
+// blocks become lines, tags are dropped, entities unescaped).
+func emailHTMLText(s string) string {
+	s = emailDropBlockRe.ReplaceAllString(s, "")
+	s = emailBreakRe.ReplaceAllString(s, "\n")
+	s = emailTagRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	return strings.TrimSpace(emailBlankRe.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// emailStripQuoted drops the quoted history under a reply: everything from
+// an "On … wrote:" line, and trailing "> " lines. The thread's earlier turns
+// are already in the session.
+func emailStripQuoted(text string) string {
+	if loc := emailQuoteHeadRe.FindStringIndex(text); loc != nil && loc[0] > 0 {
+		text = text[:loc[0]]
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n "), "\n")
+	for len(lines) > 0 && strings.HasPrefix(strings.TrimSpace(lines[len(lines)-1]), ">") {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// outgoingEmail is a plain-text message to compose.
+type outgoingEmail struct {
+	From       mail.Address
+	To         []string
+	Subject    string
+	Body       string
+	MessageID  string // set by composeEmail when empty
+	InReplyTo  string
+	References []string
+}
+
+// newMessageID returns a fresh <random@domain> id for the sender's domain.
+func newMessageID(from string) string {
+	domain := "zyhive.local"
+	if i := strings.LastIndexByte(from, '@'); i >= 0 && i < len(from)-1 {
+		domain = from[i+1:]
+	}
+	var b [12]byte
+	_, _ = rand.Read(b[:])
+	return "<" + hex.EncodeToString(b[:]) + "@" + domain + ">"
+}
+
+// composeEmail renders e as RFC 5322 bytes (UTF-8, quoted-printable).
+// Replies are marked Auto-Submitted so other robots do not answer them.
+func composeEmail(e *outgoingEmail, now time.Time) []byte {
+	if e.MessageID == "" {
+		e.MessageID = newMessageID(e.From.Address)
+	}
+	var buf bytes.Buffer
+	hdr := func(k, v string) {
+		if v != "" {
+			fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+		}
+	}
+	hdr("From", e.From.String())
+	hdr("To", strings.Join(e.To, ", "))
+	hdr("Subject", mime.QEncoding.Encode("utf-8", e.Subject))
+	hdr("Date", now.Format(time.RFC1123Z))
+	hdr("Message-ID", e.MessageID)
+	hdr("In-Reply-To", e.InReplyTo)
+	hdr("References", strings.Join(e.References, " "))
+	if e.InReplyTo != "" {
+		hdr("Auto-Submitted", "auto-replied")
+	}
+	hdr("MIME-Version", "1.0")
+	hdr("Content-Type", "text/plain; charset=utf-8")
+	hdr("Content-Transfer-Encoding", "quoted-printable")
+	buf.WriteString("\r\n")
+	qp := quotedprintable.NewWriter(&buf)
+	_, _ = qp.Write([]byte(strings.ReplaceAll(e.Body, "\n", "\r\n")))
+	_ = qp.Close()
+	return buf.Bytes()
+}
+
+// replySubject prefixes "Re: " unless the subject already has it.
+func replySubject(s string) string {
+	if s == "" {
+		return "Re:"
+	}
+	if l := strings.ToLower(s); strings.HasPrefix(l, "re:") || strings.HasPrefix(s, "回复：") {
+		return s
+	}
+	return "Re: " + s
+}
@@ -0,0 +1,411 @@
+package channel
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+// fakeIMAP is a minimal IMAP4rev1 server: LOGIN, SELECT, UID SEARCH UNSEEN,
+// UID FETCH BODY.PEEK[], UID STORE \Seen and LOGOUT.
+type fakeIMAP struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	msgs map[int]string
+	seen map[int]bool
+}
+
+func newFakeIMAP(t *testing.T, msgs ...string) *fakeIMAP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeIMAP{ln: ln, msgs: map[int]string{}, seen: map[int]bool{}}
+	for i, m := range msgs {
+		f.msgs[i+1] = m
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeIMAP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK fake IMAP ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		tag, cmd, _ := strings.Cut(strings.TrimRight(line, "\r\n"), " ")
+		var uid int
+		switch {
+		case strings.HasPrefix(cmd, "LOGIN "):
+			if cmd != `LOGIN "bot@example.com" "secret"` {
+				fmt.Fprintf(conn, "%s NO bad credentials\r\n", tag)
+				continue
+			}
+		case strings.HasPrefix(cmd, "SELECT "):
+			fmt.Fprintf(conn, "* %d EXISTS\r\n", len(f.msgs))
+		case cmd == "UID SEARCH UNSEEN":
+			f.mu.Lock()
+			var uids []string
+			for u := 1; u <= len(f.msgs); u++ {
+				if !f.seen[u] {
+					uids = append(uids, fmt.Sprint(u))
+				}
+			}
+			f.mu.Unlock()
+			fmt.Fprintf(conn, "* SEARCH %s\r\n", strings.Join(uids, " "))
+		case fmtScan(cmd, "UID FETCH %d (BODY.PEEK[])", &uid):
+			f.mu.Lock()
+			raw := f.msgs[uid]
+			f.mu.Unlock()
+			fmt.Fprintf(conn, "* %d FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", uid, uid, len(raw), raw)
+		case fmtScan(cmd, `UID STORE %d +FLAGS.SILENT (\Seen)`, &uid):
+			f.mu.Lock()
+			f.seen[uid] = true
+			f.mu.Unlock()
+		case cmd == "LOGOUT":
+			fmt.Fprintf(conn, "* BYE\r\n%s OK LOGOUT done\r\n", tag)
+			return
+		}
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+	}
+}
+
+func (f *fakeIMAP) isSeen(uid int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seen[uid]
+}
+
+func fmtScan(s, format string, n *int) bool {
+	_, err := fmt.Sscanf(s, format, n)
+	return err == nil
+}
+
+// fakeSMTP accepts EHLO / MAIL / RCPT / DATA / QUIT and records messages.
+type fakeSMTP struct {
+	ln   net.Listener
+	sent chan smtpMail
+}
+
+type smtpMail struct {
+	from string
+	to   []string
+	msg  *mail.Message
+	raw  string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeSMTP{ln: ln, sent: make(chan smtpMail, 10)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	_ = tp.PrintfLine("220 fake ESMTP")
+	var cur smtpMail
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO":
+			_ = tp.PrintfLine("250-fake\r\n250 8BITMIME")
+		case "MAIL":
+			cur = smtpMail{from: smtpPath(line)}
+			_ = tp.PrintfLine("250 ok")
+		case "RCPT":
+			cur.to = append(cur.to, smtpPath(line))
+			_ = tp.PrintfLine("250 ok")
+		case "DATA":
+			_ = tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			cur.raw = string(data)
+			cur.msg, _ = mail.ReadMessage(strings.NewReader(cur.raw))
+			f.sent <- cur
+			_ = tp.PrintfLine("250 queued")
+		case "QUIT":
+			_ = tp.PrintfLine("221 bye")
+			return
+		default:
+			_ = tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+// smtpPath extracts the <address> of a MAIL / RCPT command.
+func smtpPath(line string) string {
+	_, rest, _ := strings.Cut(line, "<")
+	addr, _, _ := strings.Cut(rest, ">")
+	return addr
+}
+
+func (f *fakeSMTP) next(t *testing.T) smtpMail {
+	t.Helper()
+	select {
+	case m := <-f.sent:
+		return m
+	case <-time.After(3 * time.Second):
+		t.Fatal("no mail sent")
+		return smtpMail{}
+	}
+}
+
+func (f *fakeSMTP) none(t *testing.T) {
+	t.Helper()
+	select {
+	case m := <-f.sent:
+		t.Fatalf("unexpected mail to %v: %s", m.to, m.msg.Header.Get("Subject"))
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// newTestEmailBot wires a bot to the stand-ins; runs record session ids.
+func newTestEmailBot(t *testing.T, cfg emailConfig, stateDir string, allow []string, runs chan<- testRun) *EmailBot {
+	t.Helper()
+	stream := func(_ context.Context, _, text, sessionID string, media []MediaInput, _ FileSenderFunc, _ ...string) (<-chan StreamEvent, error) {
+		if runs != nil {
+			runs <- testRun{session: sessionID, text: text, media: media}
+		}
+		return streamOf(StreamEvent{Type: "text_delta", Text: "好的，收到。"}, StreamEvent{Type: "done"}), nil
+	}
+	cfg.Address = "bot@example.com"
+	cfg.IMAPUser, cfg.IMAPPassword = "bot@example.com", "secret"
+	pending := NewPendingStoreStr(stateDir, "email-1")
+	b := NewEmailBotWithStream(cfg, "a1", t.TempDir(), "email-1", stateDir, func() []string { return allow }, stream, pending)
+	b.dial = (&net.Dialer{}).DialContext
+	t.Cleanup(b.inflight.Wait) // runs write into agentDir until they finish
+	return b
+}
+
+func poll(t *testing.T, b *EmailBot) {
+	t.Helper()
+	if err := b.source.Poll(context.Background(), func(raw []byte) error { return b.handleRaw(context.Background(), raw) }); err != nil {
+		t.Fatal(err)
+	}
+	b.inflight.Wait()
+}
+
+func rawMail(headers map[string]string, body string) string {
+	var sb strings.Builder
+	for k, v := range headers {
+		sb.WriteString(k + ": " + v + "\r\n")
+	}
+	sb.WriteString("\r\n" + strings.ReplaceAll(body, "\n", "\r\n"))
+	return sb.String()
+}
+
+func TestEmailIMAPThreadingAndReplies(t *testing.T) {
+	first := rawMail(map[string]string{
+		"From": "Alice <Alice@Example.org>", "To": "bot@example.com", "Subject": "报价单",
+		"Message-ID": "<a1@example.org>",
+	}, "请发一下最新报价。\n\nOn Mon, Bob wrote:\n> old text")
+	stranger := rawMail(map[string]string{
+		"From": "eve@evil.test", "Subject": "hi", "Message-ID": "<e1@evil.test>",
+	}, "let me in")
+	bounce := rawMail(map[string]string{
+		"From": "alice@example.org", "Subject": "Out of office", "Message-ID": "<ooo@example.org>",
+		"Auto-Submitted": "auto-replied",
+	}, "I am away")
+	imap := newFakeIMAP(t, first, stranger, bounce)
+	smtpSrv := newFakeSMTP(t)
+	runs := make(chan testRun, 4)
+	stateDir := t.TempDir()
+	b := newTestEmailBot(t, emailConfig{IMAPAddr: imap.ln.Addr().String(), SMTPAddr: smtpSrv.ln.Addr().String()},
+		stateDir, []string{"@example.org"}, runs)
+
+	poll(t, b)
+	for uid := 1; uid <= 3; uid++ {
+		if !imap.isSeen(uid) {
+			t.Errorf("uid %d not marked seen", uid)
+		}
+	}
+	run := <-runs
+	if !strings.HasPrefix(run.session, "email-") || !strings.Contains(run.text, "主题：报价单") || strings.Contains(run.text, "old text") {
+		t.Fatalf("run = %+v", run)
+	}
+	if len(runs) != 0 {
+		t.Fatal("stranger or auto-reply was dispatched")
+	}
+	if p := b.pendingStore.List(); len(p) != 1 || p[0].ID != "eve@evil.test" {
+		t.Fatalf("pending = %+v", p)
+	}
+
+	reply := smtpSrv.next(t)
+	h := reply.msg.Header
+	if reply.to[0] != "alice@example.org" || h.Get("In-Reply-To") != "<a1@example.org>" ||
+		h.Get("References") != "<a1@example.org>" || h.Get("Auto-Submitted") != "auto-replied" {
+		t.Fatalf("reply headers = %v to=%v", h, reply.to)
+	}
+	if subj, _ := emailWordDecoder.DecodeHeader(h.Get("Subject")); subj != "Re: 报价单" {
+		t.Fatalf("subject = %q", subj)
+	}
+	smtpSrv.none(t) // nothing sent to the stranger
+
+	// A client that only sets In-Reply-To (our Message-ID) stays in the thread.
+	followUp := rawMail(map[string]string{
+		"From": "alice@example.org", "Subject": "Re: 报价单", "Message-ID": "<a2@example.org>",
+		"In-Reply-To": h.Get("Message-ID"),
+	}, "谢谢，再问一下交期？")
+	b2 := newTestEmailBot(t, emailConfig{IMAPAddr: newFakeIMAP(t, followUp).ln.Addr().String(), SMTPAddr: smtpSrv.ln.Addr().String()},
+		stateDir, []string{"@example.org"}, runs) // reloads threads from stateDir
+	poll(t, b2)
+	if run2 := <-runs; run2.session != run.session {
+		t.Fatalf("follow-up session %q, want %q", run2.session, run.session)
+	}
+	refs := smtpSrv.next(t).msg.Header.Get("References")
+	if !strings.HasPrefix(refs, "<a1@example.org>") || !strings.HasSuffix(refs, "<a2@example.org>") {
+		t.Fatalf("References = %q", refs)
+	}
+}
+
+func TestEmailMaildirApproveReplies(t *testing.T) {
+	dir := t.TempDir()
+	for _, sub := range []string{"new", "cur", "tmp"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, raw string) {
+		if err := os.WriteFile(filepath.Join(dir, "new", name), []byte(raw), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("1.eml", rawMail(map[string]string{
+		"From": "carol@example.com", "Subject": "Invoice", "Message-ID": "<c1@example.com>",
+	}, "Where is my invoice?"))
+	smtpSrv := newFakeSMTP(t)
+	runs := make(chan testRun, 4)
+	stateDir := t.TempDir()
+	b := newTestEmailBot(t, emailConfig{Maildir: dir, SMTPAddr: smtpSrv.ln.Addr().String(), ApproveReplies: true},
+		stateDir, []string{"carol@example.com"}, runs)
+
+	poll(t, b)
+	<-runs
+	if _, err := os.Stat(filepath.Join(dir, "cur", "1.eml:2,S")); err != nil {
+		t.Fatalf("maildir message not moved to cur: %v", err)
+	}
+	smtpSrv.none(t)
+	drafts := b.Drafts()
+	if len(drafts) != 1 || drafts[0].To[0] != "carol@example.com" || drafts[0].InReplyTo != "<c1@example.com>" {
+		t.Fatalf("drafts = %+v", drafts)
+	}
+
+	// Drafts survive a restart; approving the whole thread sends it.
+	b = newTestEmailBot(t, emailConfig{Maildir: dir, SMTPAddr: smtpSrv.ln.Addr().String(), ApproveReplies: true},
+		stateDir, []string{"carol@example.com"}, runs)
+	if err := b.ApproveDraft(context.Background(), drafts[0].ID, true); err != nil {
+		t.Fatal(err)
+	}
+	if m := smtpSrv.next(t); m.msg.Header.Get("In-Reply-To") != "<c1@example.com>" || m.from != "bot@example.com" {
+		t.Fatalf("approved mail = %v", m.msg.Header)
+	}
+	if len(b.Drafts()) != 0 {
+		t.Fatal("draft not removed")
+	}
+
+	// Later replies in the approved thread go straight out.
+	write("2.eml", rawMail(map[string]string{
+		"From": "carol@example.com", "Subject": "Re: Invoice", "Message-ID": "<c2@example.com>",
+		"In-Reply-To": "<c1@example.com>", "References": "<c1@example.com>",
+	}, "Thanks!"))
+	poll(t, b)
+	<-runs
+	smtpSrv.next(t)
+
+	// New threads (email_send) still need approval; discarding drops them.
+	id, queued, err := b.SendNew(context.Background(), []string{"dave@example.com"}, "Hello", "Hi Dave")
+	if err != nil || !queued {
+		t.Fatalf("SendNew = %q %v %v", id, queued, err)
+	}
+	if err := b.DiscardDraft(id); err != nil {
+		t.Fatal(err)
+	}
+	smtpSrv.none(t)
+}
+
+func TestParseEmailMIME(t *testing.T) {
+	gbkSubject, _ := simplifiedchinese.GBK.NewEncoder().String("会议纪要")
+	gbkBody, _ := simplifiedchinese.GBK.NewEncoder().String("<p>大家好，</p><p>附件是&nbsp;纪要。</p><style>p{}</style>")
+	raw := "From: =?UTF-8?B?5byg5LiJ?= <zhang@example.cn>\r\n" +
+		"Subject: =?GBK?B?" + b64(gbkSubject) + "?=\r\n" +
+		"Message-ID: <m1@example.cn>\r\n" +
+		"References: <r0@example.cn> <r1@example.cn>\r\n" +
+		"Content-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+		"--outer\r\nContent-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+		"--inner\r\nContent-Type: text/html; charset=gbk\r\nContent-Transfer-Encoding: base64\r\n\r\n" + b64(gbkBody) + "\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\nContent-Type: image/png; name=\"chart.png\"\r\nContent-Disposition: attachment; filename=\"chart.png\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" + b64("\x89PNG") + "\r\n" +
+		"--outer\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Disposition: attachment; filename=\"notes.txt\"\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\ncaf=C3=A9\r\n" +
+		"--outer--\r\n"
+	m, err := parseEmail([]byte(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "会议纪要" || m.From != "zhang@example.cn" || m.FromName != "张三" {
+		t.Fatalf("headers = %q %q %q", m.Subject, m.From, m.FromName)
+	}
+	if m.Text != "大家好，\n附件是 纪要。" {
+		t.Fatalf("text = %q", m.Text)
+	}
+	if len(m.References) != 2 || m.References[0] != "<r0@example.cn>" || m.AutoGenerated {
+		t.Fatalf("refs = %v auto=%v", m.References, m.AutoGenerated)
+	}
+	if len(m.Files) != 2 || m.Files[0].Name != "chart.png" || string(m.Files[0].Data) != "\x89PNG" || string(m.Files[1].Data) != "caf\xc3\xa9" {
+		t.Fatalf("files = %+v", m.Files)
+	}
+	media, extras := emailMedia(m.Files)
+	if len(media) != 1 || media[0].ContentType != "image/png" || len(extras) != 1 || extras[0] != "[📎 notes.txt]" {
+		t.Fatalf("media = %+v extras = %v", media, extras)
+	}
+}
+
+func b64(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
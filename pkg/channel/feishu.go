@@ -14,6 +14,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
@@ -23,7 +24,6 @@ import (
 	"time"
 
 	"github.com/Zyling-ai/zyhive/pkg/netguard"
-	"github.com/Zyling-ai/zyhive/pkg/network"
 	"github.com/Zyling-ai/zyhive/pkg/safefs"
 	"github.com/gorilla/websocket"
 )
@@ -125,6 +125,8 @@ type FeishuBot struct {
 
 	// chatMu serializes processing per chatID to avoid concurrent LLM calls for the same chat
 	chatMu sync.Map // chatID → *sync.Mutex
+
+	voice VoiceOptions // speech providers + voiceReply mode (voice.go)
 }
 
 // NewFeishuBotWithStream creates a FeishuBot.
@@ -149,6 +151,13 @@ func (b *FeishuBot) SetOnConnected(fn func(name string)) {
 	b.onConnected = fn
 }
 
+// SetVoice sets the speech providers and the voiceReply mode
+// ("" | "auto" | "always").
+func (b *FeishuBot) SetVoice(v VoiceOptions, reply string) {
+	v.Reply = reply
+	b.voice = v
+}
+
 // SetPanelBaseURL sets the ZyHive panel URL shown in pairing messages.
 func (b *FeishuBot) SetPanelBaseURL(url string) {
 	b.panelBaseURL = url
@@ -340,10 +349,12 @@ func (b *FeishuBot) handleMessageEvent(ctx context.Context, ev *feishuMessageEve
 	msg := &ev.Message
 	senderOpenID := ev.Sender.SenderID.OpenID
 
-	// Accept text / image / post (rich text with images). Everything else
-	// (audio / file / sticker / ...) is still ignored — vision models don't
-	// consume those anyway.
-	if msg.MessageType != "text" && msg.MessageType != "image" && msg.MessageType != "post" {
+	// Accept text / image / post (rich text with images) / audio (voice
+	// notes, transcribed below) / file (documents, text extracted below).
+	// Everything else (sticker / media / ...) is still ignored.
+	switch msg.MessageType {
+	case "text", "image", "post", "audio", "file":
+	default:
 		return
 	}
 
@@ -357,8 +368,12 @@ func (b *FeishuBot) handleMessageEvent(ctx context.Context, ev *feishuMessageEve
 	//   text  → {"text":"hello"}
 	//   image → {"image_key":"img_v3_..."}
 	//   post  → {"title":"t","content":[[{tag:"text",text:"..."}, {tag:"img",image_key:"..."}, ...], ...]}
+	//   audio → {"file_key":"file_v3_...","duration":2000}
+	//   file  → {"file_key":"file_v3_...","file_name":"报告.pdf"}
 	var text string
 	var imageKeys []string
+	var audioKey string
+	var fileKey, fileName string
 	switch msg.MessageType {
 	case "text":
 		var c struct {
@@ -411,6 +426,25 @@ func (b *FeishuBot) handleMessageEvent(ctx context.Context, ev *feishuMessageEve
 		if text == "" && len(imageKeys) > 0 {
 			text = "[图片]"
 		}
+	case "audio":
+		var c struct {
+			FileKey string `json:"file_key"`
+		}
+		if err := json.Unmarshal([]byte(msg.Content), &c); err != nil || c.FileKey == "" {
+			return
+		}
+		audioKey = c.FileKey
+		text = "[🎤 语音]" // replaced by the transcript after access control
+	case "file":
+		var c struct {
+			FileKey  string `json:"file_key"`
+			FileName string `json:"file_name"`
+		}
+		if err := json.Unmarshal([]byte(msg.Content), &c); err != nil || c.FileKey == "" {
+			return
+		}
+		fileKey, fileName = c.FileKey, c.FileName
+		text = "[📎 文件: " + fileName + "]" // replaced by the extracted text after access control
 	}
 
 	// Group chat handling
@@ -453,70 +487,63 @@ func (b *FeishuBot) handleMessageEvent(ctx context.Context, ev *feishuMessageEve
 		return
 	}
 
-	// Access control
-	currentAllowFrom := b.getAllowFrom()
-	if len(currentAllowFrom) == 0 {
-		// Pairing mode — guide user to authorize via panel
-		log.Printf("[feishu] pairing mode — user open_id=%s", senderOpenID)
-		if b.pendingStore != nil {
-			b.pendingStore.Add(senderOpenID, senderOpenID)
-		}
+	// Access control — pairing mode and unauthorized users are both guided
+	// to the panel; Pipeline.Check keeps the pending list in sync.
+	pipe := b.pipeline()
+	if res := pipe.Check(Sender{ID: senderOpenID}, true); res != AccessAllowed {
 		authURL := b.panelBaseURL
 		if authURL == "" {
 			authURL = "ZyHive 管理面板"
 		} else {
 			authURL = authURL + "/#/agents/" + b.agentID + "/channels"
 		}
-		reply := fmt.Sprintf("👋 您好！请前往管理面板授权后即可开始对话：\n\n%s\n\n授权完成后直接发消息即可。", authURL)
+		var reply string
+		if res == AccessPairing {
+			// Pairing mode — guide user to authorize via panel
+			log.Printf("[feishu] pairing mode — user open_id=%s", senderOpenID)
+			reply = fmt.Sprintf("👋 您好！请前往管理面板授权后即可开始对话：\n\n%s\n\n授权完成后直接发消息即可。", authURL)
+		} else {
+			log.Printf("[feishu] unauthorized user open_id=%s", senderOpenID)
+			reply = fmt.Sprintf("👋 您好！您尚未获得访问授权，请联系管理员在以下地址为您开通：\n\n%s\n\n授权完成后直接发消息即可。", authURL)
+		}
 		_, _ = b.sendText(msg.ChatID, reply)
 		return
 	}
 
-	allowed := false
-	for _, id := range currentAllowFrom {
-		if id == senderOpenID {
-			allowed = true
-			break
+	if audioKey != "" {
+		data, ct, derr := b.downloadMessageResource(msg.MessageID, audioKey, "file")
+		if derr != nil {
+			log.Printf("[feishu] download audio file_key=%s: %v", audioKey, derr)
+		} else {
+			text = pipe.Transcribe(ctx, ChatRef{ID: msg.ChatID, Type: msg.ChatType}, MediaInput{Data: data, ContentType: ct, FileName: "voice.opus"})
 		}
 	}
-	if !allowed {
-		// Unauthorized — add to pending and guide to authorize
-		log.Printf("[feishu] unauthorized user open_id=%s", senderOpenID)
-		if b.pendingStore != nil {
-			b.pendingStore.Add(senderOpenID, senderOpenID)
-		}
-		authURL := b.panelBaseURL
-		if authURL == "" {
-			authURL = "ZyHive 管理面板"
+	var fileMedia []MediaInput
+	if fileKey != "" && IsDocument(fileName, "") {
+		data, ct, derr := b.downloadMessageResource(msg.MessageID, fileKey, "file")
+		if derr != nil {
+			log.Printf("[feishu] download file file_key=%s: %v", fileKey, derr)
 		} else {
-			authURL = authURL + "/#/agents/" + b.agentID + "/channels"
+			doc := MediaInput{Data: data, ContentType: ct, FileName: fileName}
+			var keep bool
+			text, keep = pipe.Document(ctx, ChatRef{ID: msg.ChatID, Type: msg.ChatType}, doc)
+			if keep {
+				fileMedia = append(fileMedia, doc)
+			}
 		}
-		reply := fmt.Sprintf("👋 您好！您尚未获得访问授权，请联系管理员在以下地址为您开通：\n\n%s\n\n授权完成后直接发消息即可。", authURL)
-		_, _ = b.sendText(msg.ChatID, reply)
-		return
-	}
-
-	// Authorized user — remove from pending if present
-	if b.pendingStore != nil {
-		b.pendingStore.Remove(senderOpenID)
 	}
 
 	log.Printf("[feishu] message from open_id=%s chat=%s text=%q", senderOpenID, msg.ChatID, truncateStr(text, 60))
 
-	runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
-
-	// Use chatID as sessionID so conversations persist per Feishu chat
-	// Prefix with "feishu-" to namespace from other channel sessions
-	feishuSessionID := "feishu-" + msg.ChatID
+	// getSenderName may return "" (new friend / member list not fetched yet);
+	// Pipeline falls back to FallbackDisplayName when filing the contact.
+	senderName := b.getSenderName(senderOpenID)
 
 	// Build the message text with sender attribution for group chats
 	// For group chats: prepend sender name so AI knows who is speaking
 	// For DMs: just use the original text
 	finalText := text
 	if isGroup {
-		// Try to get sender's display name (cached)
-		senderName := b.getSenderName(senderOpenID)
 		if senderName != "" {
 			finalText = fmt.Sprintf("[%s]: %s", senderName, text)
 		} else {
@@ -524,46 +551,6 @@ func (b *FeishuBot) handleMessageEvent(ctx context.Context, ev *feishuMessageEve
 		}
 	}
 
-	// Inject sender identity as extra system context (NOT in the user message — invisible to users)
-	extraCtx := fmt.Sprintf("当前飞书用户信息：open_id=%s，chat_id=%s，chat_type=%s",
-		senderOpenID, msg.ChatID, msg.ChatType)
-
-	// ── Network (contact book + chat profile) — resolve feishu sender and chat,
-	// then append Layer-2 summaries to extraCtx. Group chat summary first
-	// (broader context), then sender contact (individual context).
-	if b.agentDir != "" {
-		wsDir := filepath.Join(b.agentDir, "workspace")
-		store := network.NewStore(wsDir)
-
-		// 1. Group chat profile (only for group chats — private/p2p covered by contact alone).
-		// Feishu 不在消息事件里给群名 — 用 "" 让 defaultChatBody 兜底, 后续 AI 用 chat_note 自补.
-		if isGroup && msg.ChatID != "" {
-			if _, cerr := store.ResolveChat(network.SourceFeishu, msg.ChatID, "", msg.ChatType); cerr != nil {
-				log.Printf("[feishu] network.ResolveChat warning: %v", cerr)
-			} else if cs := store.ChatSummary(network.MakeID(network.SourceFeishu, msg.ChatID)); cs != "" {
-				extraCtx = extraCtx + "\n\n" + cs
-			}
-		}
-
-		// 2. Sender contact (existing behavior).
-		if senderOpenID != "" {
-			// Bug 2 fix: getSenderName 在刚加好友 / 群聊成员列表未拉取时会返回 "",
-			// 直接落盘会导致 UI 显示空白. 走 FallbackDisplayName 链.
-			displayName := network.FallbackDisplayName(senderOpenID, b.getSenderName(senderOpenID))
-			if c, nerr := store.Resolve(network.SourceFeishu, senderOpenID, displayName); nerr != nil {
-				log.Printf("[feishu] network.Resolve warning: %v", nerr)
-			} else {
-				if summary := store.Summary(c.ID); summary != "" {
-					extraCtx = extraCtx + "\n\n" + summary
-				}
-				// E-01 (26.5.12v1): async fetch avatar if not yet cached.
-				if c.AvatarPath == "" {
-					b.fetchAndCacheFeishuAvatar(senderOpenID, c.ID)
-				}
-			}
-		}
-	}
-
 	// Download any attached images and hand them to the model as MediaInput.
 	// Cap at 5 images per message to protect token budget / vision limits.
 	var media []MediaInput
@@ -590,63 +577,25 @@ func (b *FeishuBot) handleMessageEvent(ctx context.Context, ev *feishuMessageEve
 			log.Printf("[feishu] attached %d images to agent turn", len(media))
 		}
 	}
-
-	events, err := b.streamFunc(runCtx, b.agentID, finalText, feishuSessionID, media, nil, extraCtx)
-	if err != nil {
-		_, _ = b.sendText(msg.ChatID, "⚠️ 出错了："+err.Error())
-		return
-	}
-
-	// Immediately send a "typing" placeholder card so the user sees a response is coming
-	var accumulated strings.Builder
-	sentMsgID, _ := b.sendCard(msg.ChatID, "⌛ 正在思考...")
-	lastPatched := ""
-
-	throttle := time.NewTicker(1200 * time.Millisecond)
-	defer throttle.Stop()
-
-	patchCard := func(text string) {
-		if text == "" || text == lastPatched || sentMsgID == "" {
-			return
-		}
-		lastPatched = text
-		_ = b.patchCard(sentMsgID, text)
-	}
-
-	for {
-		select {
-		case ev, ok := <-events:
-			if !ok {
-				goto done
-			}
-			switch ev.Type {
-			case "text_delta":
-				accumulated.WriteString(ev.Text)
-			case "error":
-				if ev.Err != nil {
-					accumulated.WriteString("\n⚠️ " + ev.Err.Error())
-				}
-			case "done":
-				goto done
-			}
-		case <-throttle.C:
-			patchCard(accumulated.String())
-		}
-	}
-done:
-	// Final update with complete text
-	replyText := strings.TrimSpace(accumulated.String())
-	if replyText == "" {
-		replyText = "(no response)"
-	}
-	if sentMsgID == "" {
-		// Placeholder failed — send directly
-		if _, err := b.sendCard(msg.ChatID, replyText); err != nil {
-			log.Printf("[feishu] sendCard error: %v", err)
-		}
-	} else {
-		patchCard(replyText)
-	}
+	media = append(media, fileMedia...) // scanned PDFs
+
+	// Session "feishu-{chatID}" keeps conversations per Feishu chat. Feishu
+	// 不在消息事件里给群名 — Title 留空让 defaultChatBody 兜底, 后续 AI 用 chat_note 自补.
+	in := InboundMessage{
+		ChannelType: "feishu",
+		ChannelID:   b.channelID,
+		MessageID:   msg.MessageID,
+		Chat:        ChatRef{ID: msg.ChatID, Type: msg.ChatType},
+		Sender:      Sender{ID: senderOpenID, Name: senderName},
+		Text:        finalText,
+		Media:       media,
+		Voice:       audioKey != "",
+		// Inject sender identity as extra system context (NOT in the user message — invisible to users)
+		ExtraContext: []string{fmt.Sprintf("当前飞书用户信息：open_id=%s，chat_id=%s，chat_type=%s",
+			senderOpenID, msg.ChatID, msg.ChatType)},
+	}
+	pipe.LogInbound(in, text)
+	pipe.Dispatch(ctx, in)
 }
 
 // ProactiveSend sends a message to the first chat we've interacted with (for cron notifications).
@@ -820,9 +769,12 @@ func (b *FeishuBot) downloadMessageResource(messageID, fileKey, resourceType str
 		return nil, "", fmt.Errorf("read feishu resource: %w", err)
 	}
 	if len(data) > maxBytes {
-		return nil, "", fmt.Errorf("feishu image exceeds %d bytes (skipped)", maxBytes)
+		return nil, "", fmt.Errorf("feishu %s exceeds %d bytes (skipped)", resourceType, maxBytes)
 	}
 	ct := resp.Header.Get("Content-Type")
+	if resourceType != "image" {
+		return data, ct, nil
+	}
 	if ct == "" || !strings.HasPrefix(ct, "image/") {
 		// Feishu sometimes returns application/octet-stream for images.
 		// Sniff from magic bytes; default to jpeg which vision providers accept.
@@ -916,6 +868,82 @@ func (b *FeishuBot) sendText(chatID, text string) (string, error) {
 	return result.Data.MessageID, nil
 }
 
+// sendAudio uploads Ogg/Opus audio (im/v1/files, file_type=opus) and posts
+// it to the chat as a voice message.
+func (b *FeishuBot) sendAudio(ctx context.Context, chatID string, audio []byte) error {
+	token, err := b.refreshToken()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	_ = mw.WriteField("file_type", "opus")
+	_ = mw.WriteField("file_name", "reply.opus")
+	fw, err := mw.CreateFormFile("file", "reply.opus")
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(audio); err != nil {
+		return err
+	}
+	mw.Close()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.apiBase()+"/im/v1/files", &buf)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	var up struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data struct {
+			FileKey string `json:"file_key"`
+		} `json:"data"`
+	}
+	if err := b.doJSON(req, &up); err != nil {
+		return fmt.Errorf("upload audio: %w", err)
+	}
+	if up.Code != 0 {
+		return fmt.Errorf("upload audio error %d: %s", up.Code, up.Msg)
+	}
+
+	contentJSON, _ := json.Marshal(map[string]string{"file_key": up.Data.FileKey})
+	payload, _ := json.Marshal(map[string]string{
+		"receive_id": chatID,
+		"msg_type":   "audio",
+		"content":    string(contentJSON),
+	})
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost,
+		b.apiBase()+"/im/v1/messages?receive_id_type=chat_id", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	var sent struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	if err := b.doJSON(req, &sent); err != nil {
+		return err
+	}
+	if sent.Code != 0 {
+		return fmt.Errorf("send audio error %d: %s", sent.Code, sent.Msg)
+	}
+	return nil
+}
+
+// doJSON sends req and decodes the (small) JSON response into out.
+func (b *FeishuBot) doJSON(req *http.Request, out any) error {
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return json.Unmarshal(body, out)
+}
+
 // patchText updates an existing message content.
 func (b *FeishuBot) patchText(messageID, text string) error {
 	token, err := b.refreshToken()
@@ -0,0 +1,99 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FeishuBot implements Driver, Notifier and VoiceSender.
+var (
+	_ Driver      = (*FeishuBot)(nil)
+	_ Notifier    = (*FeishuBot)(nil)
+	_ VoiceSender = (*FeishuBot)(nil)
+)
+
+func init() {
+	RegisterDriver(DriverSpec{
+		Type:      "feishu",
+		Required:  []string{"appId", "appSecret"},
+		UniqueKey: "appId",
+		New:       newFeishuDriver,
+		Test: func(_ context.Context, cfg map[string]string) (string, error) {
+			return TestFeishuBot(cfg["appId"], cfg["appSecret"])
+		},
+	})
+}
+
+func newFeishuDriver(env DriverEnv) (Driver, error) {
+	var pending *PendingStoreStr
+	if dir := env.PendingDir(); dir != "" {
+		pending = NewPendingStoreStr(dir, env.ChannelID)
+	}
+	getAllowFrom := env.AllowFrom
+	if getAllowFrom == nil {
+		getAllowFrom = func() []string { return nil }
+	}
+	bot := NewFeishuBotWithStream(env.Config["appId"], env.Config["appSecret"], env.AgentID, env.AgentDir, env.ChannelID, getAllowFrom, env.Stream, pending)
+	bot.SetPanelBaseURL(env.PanelBaseURL)
+	bot.SetOnConnected(env.OnConnected)
+	bot.SetVoice(env.Voice, env.Config["voiceReply"])
+	return bot, nil
+}
+
+// pipeline returns the shared message path bound to this bot.
+func (b *FeishuBot) pipeline() *Pipeline {
+	return &Pipeline{
+		Env: DriverEnv{
+			AgentID:      b.agentID,
+			AgentDir:     b.agentDir,
+			ChannelID:    b.channelID,
+			Stream:       b.streamFunc,
+			AllowFrom:    b.getAllowFrom,
+			PanelBaseURL: b.panelBaseURL,
+			Voice:        b.voice,
+		},
+		Driver:       b,
+		Pending:      b.pendingStore.Recorder(),
+		OnNewContact: b.fetchAndCacheFeishuAvatar,
+	}
+}
+
+// Type implements Driver.
+func (b *FeishuBot) Type() string { return "feishu" }
+
+// Capabilities implements Driver. Replies are interactive cards: a
+// "thinking" placeholder is patched in place as the answer streams. Card
+// text is cut at 4000 characters, so longer replies continue in new cards.
+func (b *FeishuBot) Capabilities() Capabilities {
+	return Capabilities{Edit: true, EditEvery: 1200 * time.Millisecond, Placeholder: "⌛ 正在思考...", MaxText: 4000}
+}
+
+// Send implements Driver (replyTo is not used; Feishu cards are posted to the chat).
+func (b *FeishuBot) Send(_ context.Context, chat ChatRef, text, _ string) (string, error) {
+	return b.sendCard(chat.ID, text)
+}
+
+// Edit implements Driver.
+func (b *FeishuBot) Edit(_ context.Context, _ ChatRef, msgID, text string) error {
+	return b.patchCard(msgID, text)
+}
+
+// Typing implements Driver; Feishu has no typing indicator.
+func (b *FeishuBot) Typing(context.Context, ChatRef) error { return nil }
+
+// SendFile implements Driver; file delivery is not supported yet.
+func (b *FeishuBot) SendFile(context.Context, ChatRef, string) (string, error) {
+	return "", errors.New("feishu: file delivery not supported")
+}
+
+// SendVoice implements VoiceSender: an "audio" message (Opus upload).
+func (b *FeishuBot) SendVoice(ctx context.Context, chat ChatRef, audio []byte, _ string) error {
+	return b.sendAudio(ctx, chat.ID, audio)
+}
+
+// Notify runs the agent on prompt in the chat's "feishu-{chatID}" session
+// and posts the reply as a card.
+func (b *FeishuBot) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	return b.pipeline().Notify(ctx, chat, prompt)
+}
@@ -1,15 +1,18 @@
 // Package channel manages inbound/outbound messaging channels.
 package channel
 
-// Hub routes inbound messages to the correct agent runner.
-type Hub struct {
-	telegramBot *TelegramBot
-}
+import (
+	"context"
 
-// NewHub creates a new channel hub.
-func NewHub() *Hub { return &Hub{} }
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
+)
 
-// SetTelegramBot registers a Telegram bot with the hub.
-func (h *Hub) SetTelegramBot(bot *TelegramBot) {
-	h.telegramBot = bot
+// startDispatchSpan opens the channel span for one inbound message. The
+// agent run started with the returned ctx is recorded as its child.
+func startDispatchSpan(ctx context.Context, channelType, channelID, agentID, sessionID string) (context.Context, *tracing.ActiveSpan) {
+	ctx, span := tracing.Start(ctx, tracing.KindChannel, channelType+" dispatch")
+	span.SetAgent(agentID, sessionID)
+	span.SetAttr("channel_type", channelType)
+	span.SetAttr("channel_id", channelID)
+	return ctx, span
 }
@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -357,6 +358,7 @@ func channelStorePath(dir, channelID, suffix string) string {
 func RemoveChannelStores(dir, channelID string) {
 	for _, suffix := range []string{
 		"-pending.json", "-approved.json", "-pending-str.json", "-approved-str.json",
+		"-email-threads.json", "-outbox.json",
 	} {
 		path := channelStorePath(dir, channelID, suffix)
 		_ = os.Remove(path)
@@ -367,3 +369,49 @@ func RemoveChannelStores(dir, channelID string) {
 		}
 	}
 }
+
+// ── PendingRecorder adapters (used by Pipeline.Check) ─────────────────────
+
+type pendingRecorder struct{ ps *PendingStore }
+
+// Recorder adapts the int64-keyed store to Pipeline; non-numeric sender
+// IDs are ignored. Returns nil for a nil store.
+func (ps *PendingStore) Recorder() PendingRecorder {
+	if ps == nil {
+		return nil
+	}
+	return pendingRecorder{ps}
+}
+
+func (r pendingRecorder) Add(s Sender) {
+	if id, err := strconv.ParseInt(s.ID, 10, 64); err == nil {
+		r.ps.Add(id, s.Username, s.Name)
+	}
+}
+
+func (r pendingRecorder) Remove(id string) {
+	if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+		r.ps.Remove(n)
+	}
+}
+
+type pendingRecorderStr struct{ ps *PendingStoreStr }
+
+// Recorder adapts the string-keyed store to Pipeline. Returns nil for a
+// nil store.
+func (ps *PendingStoreStr) Recorder() PendingRecorder {
+	if ps == nil {
+		return nil
+	}
+	return pendingRecorderStr{ps}
+}
+
+func (r pendingRecorderStr) Add(s Sender) {
+	name := s.Name
+	if name == "" {
+		name = s.ID
+	}
+	r.ps.Add(s.ID, name)
+}
+
+func (r pendingRecorderStr) Remove(id string) { r.ps.Remove(id) }
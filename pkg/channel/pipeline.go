@@ -0,0 +1,404 @@
+// pkg/channel/pipeline.go — shared inbound/outbound middleware for drivers.
+//
+// Before the Driver split, Telegram and Feishu each carried their own copy
+// of: allowlist + pending-user bookkeeping, contact-book filing, convlog /
+// chatlog writes, the 5-minute run timeout + dispatch span, and the
+// send-then-edit draft loop. Pipeline owns all of that; a driver builds an
+// InboundMessage and calls Check → LogInbound → Dispatch.
+package channel
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/chatlog"
+	"github.com/Zyling-ai/zyhive/pkg/convlog"
+	"github.com/Zyling-ai/zyhive/pkg/network"
+)
+
+// dispatchTimeout caps one agent run triggered by a channel message.
+const dispatchTimeout = 5 * time.Minute
+
+// typingEvery refreshes the typing indicator (Telegram expires it after ~5s).
+const typingEvery = 4 * time.Second
+
+// AccessResult is the allowlist verdict for a sender.
+type AccessResult int
+
+const (
+	AccessAllowed AccessResult = iota
+	AccessPairing              // allowlist empty: the bot is not paired yet
+	AccessDenied               // sender not on the allowlist
+)
+
+// PendingRecorder receives senders that knocked but are not allowed yet.
+// *PendingStore and *PendingStoreStr provide one via Recorder().
+type PendingRecorder interface {
+	Add(s Sender)
+	Remove(id string)
+}
+
+// Pipeline is the shared message path of one driver instance.
+type Pipeline struct {
+	Env     DriverEnv
+	Driver  Driver
+	Pending PendingRecorder // optional
+	// OnNewContact is called when the sender's contact has no cached
+	// avatar yet, so the driver can fetch one (asynchronously).
+	OnNewContact func(senderID, contactID string)
+}
+
+// Check returns the allowlist verdict for s. With record, non-allowed
+// senders are added to the pending list and allowed ones removed from it.
+func (p *Pipeline) Check(s Sender, record bool) AccessResult {
+	var allow []string
+	if p.Env.AllowFrom != nil {
+		allow = p.Env.AllowFrom()
+	}
+	res := AccessDenied
+	if len(allow) == 0 {
+		res = AccessPairing
+	}
+	for _, id := range allow {
+		if id == s.ID {
+			res = AccessAllowed
+			break
+		}
+	}
+	if record && p.Pending != nil {
+		if res == AccessAllowed {
+			p.Pending.Remove(s.ID)
+		} else {
+			p.Pending.Add(s)
+		}
+	}
+	return res
+}
+
+// LogInbound writes the user turn to the admin convlog and the AI-visible
+// chatlog. content overrides in.Text (e.g. "[📷 图片]" for media-only turns).
+func (p *Pipeline) LogInbound(in InboundMessage, content string) {
+	if content == "" {
+		content = in.Text
+	}
+	sender := in.Sender.Name
+	if in.Sender.ID != "" {
+		sender = fmt.Sprintf("%s (%s)", in.Sender.Name, in.Sender.ID)
+	}
+	p.logTurn(in.ChannelType, p.SessionID(in.Chat), "user", content, sender)
+}
+
+// SessionID is the agent session for chat (see SessionIDForChat).
+func (p *Pipeline) SessionID(chat ChatRef) string {
+	return SessionIDForChat(p.Driver.Type(), p.Driver.Capabilities(), chat)
+}
+
+func (p *Pipeline) logTurn(channelType, key, role, content, sender string) {
+	if p.Env.AgentDir == "" || content == "" {
+		return
+	}
+	ts := time.Now().UTC().Format(time.RFC3339)
+	_ = convlog.New(p.Env.AgentDir, key).Append(convlog.Entry{
+		Timestamp:   ts,
+		Role:        role,
+		Content:     content,
+		ChannelID:   key,
+		ChannelType: channelType,
+		Sender:      sender,
+	})
+	_ = chatlog.NewManager(filepath.Join(p.Env.AgentDir, "workspace")).Append(chatlog.Entry{
+		Ts:          ts,
+		SessionID:   key,
+		ChannelID:   key,
+		ChannelType: channelType,
+		Role:        role,
+		Content:     content,
+		Sender:      sender,
+	})
+}
+
+// Dispatch runs the agent on in (per-chat session) and streams the reply
+// back through the driver, then speaks it per the voiceReply mode (see
+// voice.go). Blocks until the reply is delivered.
+func (p *Pipeline) Dispatch(ctx context.Context, in InboundMessage) {
+	runCtx, cancel := context.WithTimeout(ctx, dispatchTimeout)
+	defer cancel()
+
+	sessionID := p.SessionID(in.Chat)
+	runCtx, span := startDispatchSpan(runCtx, in.ChannelType, p.Env.ChannelID, p.Env.AgentID, sessionID)
+	var dispatchErr error
+	defer func() { span.End(dispatchErr) }()
+
+	stopTyping := p.keepTyping(runCtx, in.Chat)
+	defer stopTyping()
+
+	extra := append([]string(nil), in.ExtraContext...)
+	if s := p.fileContacts(in); s != "" {
+		extra = append(extra, s)
+	}
+	var extraArgs []string
+	if len(extra) > 0 {
+		extraArgs = []string{strings.Join(extra, "\n\n")}
+	}
+
+	// The reply's idempotency key: a redelivered inbound message is not
+	// answered twice.
+	key := ""
+	if in.MessageID != "" {
+		key = "reply/" + poolKey(p.Env.AgentID, p.Env.ChannelID) + "/" + in.Chat.ID + "/" + in.MessageID
+	}
+	events, err := p.Env.Stream(runCtx, p.Env.AgentID, in.Text, sessionID, in.Media, p.fileSender(runCtx, in.Chat), extraArgs...)
+	if err != nil {
+		dispatchErr = err
+		stopTyping()
+		_ = p.outlet().send(runCtx, in.Chat, in.ReplyTo, key, []string{"⚠️ 出错了：" + err.Error()})
+		return
+	}
+	final, runErr := streamReply(runCtx, p.outlet(), in.Chat, in.ReplyTo, key, events)
+	dispatchErr = runErr
+	stopTyping()
+	p.logTurn(in.ChannelType, sessionID, "assistant", final, "")
+	if runErr == nil {
+		p.speak(runCtx, in, final)
+	}
+}
+
+// Notify runs the agent on prompt in chat's session (the prompt is the
+// user turn) and sends the reply, without streaming drafts. Used by
+// POST /agents/:id/notify for context-preserving proactive messages.
+func (p *Pipeline) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	if p.Env.Stream == nil {
+		return fmt.Errorf("notify: no stream func registered")
+	}
+	runCtx, cancel := context.WithTimeout(ctx, dispatchTimeout)
+	defer cancel()
+	sessionID := p.SessionID(chat)
+	events, err := p.Env.Stream(runCtx, p.Env.AgentID, prompt, sessionID, nil, p.fileSender(runCtx, chat))
+	if err != nil {
+		return fmt.Errorf("notify: runner error: %w", err)
+	}
+	var acc strings.Builder
+	for ev := range events {
+		switch ev.Type {
+		case "text_delta":
+			acc.WriteString(ev.Text)
+		case "error":
+			if ev.Err != nil {
+				return fmt.Errorf("notify: runner error: %w", ev.Err)
+			}
+		}
+	}
+	text := acc.String()
+	if text == "" {
+		return nil
+	}
+	if err := p.outlet().send(runCtx, chat, "", "", splitText(text, p.Driver.Capabilities().MaxText)); err != nil {
+		return fmt.Errorf("notify: send error: %w", err)
+	}
+	p.logTurn(p.Driver.Type(), sessionID, "assistant", text, "")
+	return nil
+}
+
+// outlet sends the pipeline's final messages (see delivery.go).
+func (p *Pipeline) outlet() outlet {
+	return outlet{d: p.Driver, agentID: p.Env.AgentID, channelID: p.Env.ChannelID}
+}
+
+func (p *Pipeline) fileSender(ctx context.Context, chat ChatRef) FileSenderFunc {
+	if !p.Driver.Capabilities().Files {
+		return nil
+	}
+	return func(path string) (string, error) {
+		return p.Driver.SendFile(ctx, chat, path)
+	}
+}
+
+// keepTyping refreshes the typing indicator until the returned stop func
+// is called (idempotent) or ctx ends.
+func (p *Pipeline) keepTyping(ctx context.Context, chat ChatRef) func() {
+	if !p.Driver.Capabilities().Typing {
+		return func() {}
+	}
+	ctx, stop := context.WithCancel(ctx)
+	go func() {
+		_ = p.Driver.Typing(ctx, chat)
+		t := time.NewTicker(typingEvery)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.C:
+				_ = p.Driver.Typing(ctx, chat)
+			}
+		}
+	}()
+	return stop
+}
+
+// fileContacts resolves the chat (groups only) and the sender in the
+// agent's contact book and returns their Layer-2 summaries — chat first
+// (broader context), then sender.
+func (p *Pipeline) fileContacts(in InboundMessage) string {
+	if p.Env.AgentDir == "" {
+		return ""
+	}
+	store := network.NewStore(filepath.Join(p.Env.AgentDir, "workspace"))
+	source := in.ChannelType
+	var parts []string
+	if in.Chat.IsGroup() && in.Chat.ID != "" {
+		if _, err := store.ResolveChat(source, in.Chat.ID, in.Chat.Title, in.Chat.Type); err != nil {
+			log.Printf("[%s] network.ResolveChat warning: %v", source, err)
+		} else if cs := store.ChatSummary(network.MakeID(source, in.Chat.ID)); cs != "" {
+			parts = append(parts, cs)
+		}
+	}
+	if in.Sender.ID != "" {
+		// Bug 2 fix: 完整 fallback 链 — name → username → externalID[:8]
+		name := network.FallbackDisplayName(in.Sender.ID, in.Sender.Name, in.Sender.Username)
+		c, err := store.Resolve(source, in.Sender.ID, name)
+		if err != nil {
+			log.Printf("[%s] network.Resolve warning: %v", source, err)
+		} else {
+			if s := store.Summary(c.ID); s != "" {
+				parts = append(parts, s)
+			}
+			// E-01 (26.5.12v1): async fetch avatar if not yet cached.
+			if c.AvatarPath == "" && p.OnNewContact != nil {
+				p.OnNewContact(in.Sender.ID, c.ID)
+			}
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// StreamReply drains a run's events into chat: the first text is sent,
+// later text edits that message at the driver's EditEvery cadence (drivers
+// without Edit get one message at the end). Text beyond Capabilities.MaxText
+// follows as further messages. Returns the accumulated text and the first
+// runner error.
+func StreamReply(ctx context.Context, d Driver, chat ChatRef, replyTo string, events <-chan StreamEvent) (string, error) {
+	return streamReply(ctx, outlet{d: d}, chat, replyTo, "", events)
+}
+
+// streamReply is StreamReply with the final message going through out
+// under the idempotency key.
+func streamReply(ctx context.Context, out outlet, chat ChatRef, replyTo, key string, events <-chan StreamEvent) (string, error) {
+	d := out.d
+	caps := d.Capabilities()
+	interval := caps.EditEvery
+	if interval <= 0 {
+		interval = time.Second
+	}
+
+	var (
+		acc    strings.Builder
+		runErr error
+		msgID  string
+		sent   bool
+		last   string
+	)
+	if caps.Edit && caps.Placeholder != "" {
+		if id, err := d.Send(ctx, chat, caps.Placeholder, replyTo); err == nil && id != "" {
+			msgID, sent = id, true
+		}
+	}
+	// flush updates the draft with the text's first message-sized piece.
+	flush := func(text string) {
+		text = splitText(text, caps.MaxText)[0]
+		if text == "" || text == last {
+			return
+		}
+		if !sent {
+			id, err := d.Send(ctx, chat, text, replyTo)
+			if err != nil {
+				log.Printf("[%s] send error: %v", d.Type(), err)
+				return
+			}
+			msgID, sent, last = id, true, text
+			return
+		}
+		if !caps.Edit || msgID == "" {
+			return
+		}
+		if err := d.Edit(ctx, chat, msgID, text); err != nil {
+			log.Printf("[%s] edit warning: %v", d.Type(), err)
+		}
+		last = text
+	}
+
+	var tick <-chan time.Time
+	if caps.Edit {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+loop:
+	for {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				break loop
+			}
+			switch ev.Type {
+			case "text_delta":
+				acc.WriteString(ev.Text)
+			case "error":
+				if ev.Err != nil {
+					if runErr == nil {
+						runErr = ev.Err
+					}
+					acc.WriteString("\n⚠️ " + ev.Err.Error())
+				}
+			case "done":
+				break loop
+			}
+		case <-tick:
+			flush(acc.String())
+		}
+	}
+
+	final := acc.String()
+	text := strings.TrimSpace(final)
+	if text == "" {
+		text = "(no response)"
+	}
+	chunks := splitText(text, caps.MaxText)
+	if sent && caps.Edit && msgID != "" {
+		// The draft becomes the first message; the rest follows.
+		if err := editFinal(ctx, d, chat, msgID, chunks[0], last); err != nil {
+			log.Printf("[%s] final edit failed, sending reply anew: %v", d.Type(), err)
+		} else {
+			chunks, replyTo = chunks[1:], ""
+		}
+	}
+	if len(chunks) > 0 {
+		if err := out.send(ctx, chat, replyTo, key, chunks); err != nil {
+			log.Printf("[%s] send error: %v", d.Type(), err)
+		}
+	}
+	return final, runErr
+}
+
+// editFinal puts the final text into the draft, waiting out rate limits
+// (the last draft edit often hits one).
+func editFinal(ctx context.Context, d Driver, chat ChatRef, msgID, text, last string) error {
+	if text == last {
+		return nil
+	}
+	for attempt := 1; ; attempt++ {
+		err := d.Edit(ctx, chat, msgID, text)
+		if err == nil || !IsRateLimitError(err) || attempt >= inlineAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(retryBackoff(attempt, err)):
+		}
+	}
+}
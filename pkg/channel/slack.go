@@ -0,0 +1,797 @@
+// Package channel — Slack bot integration.
+//   - Socket Mode (apps.connections.open → WebSocket, no public URL needed)
+//     when an app-level token (xapp-…) is configured
+//   - Events API fallback over POST /channels/:agentId/:channelId/webhook,
+//     verified with the app's signing secret (slack_driver.go)
+//   - Threads map to sessions: "slack-{channel}-{thread_ts}"
+//   - Channels: respond only when @mentioned; DMs always
+//   - Streaming reply: chat.postMessage then chat.update
+//   - send_file via files.getUploadURLExternal / completeUploadExternal
+//   - Slash commands, and Block Kit approve/deny buttons for tool approvals
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+	"github.com/gorilla/websocket"
+)
+
+// slackAPIBase is the Slack Web API root.
+const slackAPIBase = "https://slack.com/api"
+
+// slackMaxFileBytes caps one downloaded attachment (vision providers reject
+// larger images anyway).
+const slackMaxFileBytes = 20 << 20
+
+// ── Slack API types ───────────────────────────────────────────────────────
+
+// slackEnvelope is one Socket Mode frame.
+type slackEnvelope struct {
+	Type       string          `json:"type"` // hello | disconnect | events_api | slash_commands | interactive
+	EnvelopeID string          `json:"envelope_id"`
+	Payload    json.RawMessage `json:"payload"`
+	Reason     string          `json:"reason"`
+}
+
+// slackEventCallback is the Events API body (also the events_api payload
+// in Socket Mode).
+type slackEventCallback struct {
+	Type      string          `json:"type"` // event_callback | url_verification
+	Challenge string          `json:"challenge"`
+	EventID   string          `json:"event_id"`
+	Event     json.RawMessage `json:"event"`
+}
+
+// slackMessageEvent covers the "message" and "app_mention" events.
+type slackMessageEvent struct {
+	Type        string      `json:"type"`
+	Subtype     string      `json:"subtype"`
+	Channel     string      `json:"channel"`
+	ChannelType string      `json:"channel_type"` // im | mpim | channel | group ("" for app_mention)
+	User        string      `json:"user"`
+	BotID       string      `json:"bot_id"`
+	Text        string      `json:"text"`
+	TS          string      `json:"ts"`
+	ThreadTS    string      `json:"thread_ts"`
+	Files       []slackFile `json:"files"`
+}
+
+type slackFile struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Mimetype           string `json:"mimetype"`
+	Size               int64  `json:"size"`
+	URLPrivateDownload string `json:"url_private_download"`
+}
+
+// slackSlashCommand is a slash command invocation (form fields over HTTP,
+// JSON in Socket Mode — same keys).
+type slackSlashCommand struct {
+	Command   string `json:"command"`
+	Text      string `json:"text"`
+	UserID    string `json:"user_id"`
+	UserName  string `json:"user_name"`
+	ChannelID string `json:"channel_id"`
+	ThreadTS  string `json:"thread_ts"`
+}
+
+// slackInteraction is a block_actions payload (button clicks).
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+		Name     string `json:"name"`
+	} `json:"user"`
+	Container struct {
+		ChannelID string `json:"channel_id"`
+		MessageTS string `json:"message_ts"`
+	} `json:"container"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+}
+
+type slackUser struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	RealName string `json:"real_name"`
+	Profile  struct {
+		DisplayName string `json:"display_name"`
+		RealName    string `json:"real_name"`
+	} `json:"profile"`
+}
+
+// ── SlackBot ──────────────────────────────────────────────────────────────
+
+type SlackBot struct {
+	botToken      string // xoxb-…: Web API
+	appToken      string // xapp-…: Socket Mode; "" = Events API webhook only
+	signingSecret string // verifies webhook requests
+	agentID       string
+	agentDir      string
+	channelID     string
+	getAllowFrom  func() []string // Slack user IDs; empty = pairing mode
+
+	streamFunc   StreamFunc
+	pendingStore *PendingStoreStr
+	approvals    Approvals
+	panelBaseURL string
+	onConnected  func(name string)
+
+	apiBase string
+	client  *http.Client
+	// dialWS connects to a Socket Mode URL (tests swap in a plain dialer).
+	dialWS func(ctx context.Context, wsURL string) (*websocket.Conn, error)
+
+	identMu   sync.RWMutex
+	botUserID string // bot's own user id (auth.test)
+	botName   string
+
+	// runCtx is the Start context; webhook events dispatch under it.
+	runMu  sync.Mutex
+	runCtx context.Context
+
+	seenMu sync.Mutex
+	seen   map[string]time.Time // event_id and "channel:ts" dedup
+
+	usersMu sync.Mutex
+	users   map[string]string // user id → display name
+
+	// sessions maps agent session id → chat, so approval prompts of a run
+	// started here are posted back into the same thread.
+	sessions sync.Map
+	// chatMu serializes processing per session to avoid concurrent runs.
+	chatMu sync.Map
+	// inflight tracks handler goroutines; Start returns once they finish.
+	inflight sync.WaitGroup
+}
+
+// NewSlackBotWithStream creates a SlackBot.
+func NewSlackBotWithStream(botToken, appToken, signingSecret, agentID, agentDir, channelID string, getAllowFrom func() []string, sf StreamFunc, pending *PendingStoreStr) *SlackBot {
+	return &SlackBot{
+		botToken:      botToken,
+		appToken:      appToken,
+		signingSecret: signingSecret,
+		agentID:       agentID,
+		agentDir:      agentDir,
+		channelID:     channelID,
+		getAllowFrom:  getAllowFrom,
+		streamFunc:    sf,
+		pendingStore:  pending,
+		apiBase:       slackAPIBase,
+		client:        netguard.NewSafeClient(15 * time.Second),
+		dialWS:        dialSlackWebSocket,
+		runCtx:        context.Background(),
+		seen:          make(map[string]time.Time),
+		users:         make(map[string]string),
+	}
+}
+
+// SetOnConnected sets a callback fired once auth.test succeeds.
+func (b *SlackBot) SetOnConnected(fn func(name string)) {
+	b.onConnected = fn
+}
+
+// SetPanelBaseURL sets the ZyHive panel URL shown in pairing messages.
+func (b *SlackBot) SetPanelBaseURL(url string) {
+	b.panelBaseURL = url
+}
+
+// SetApprovals wires the tool-approval broker for Block Kit buttons.
+func (b *SlackBot) SetApprovals(a Approvals) {
+	b.approvals = a
+}
+
+// Start identifies the bot, then runs Socket Mode (reconnecting on error)
+// or, without an app token, waits for Events API webhooks.
+func (b *SlackBot) Start(ctx context.Context) {
+	log.Printf("[slack] starting agent=%s", b.agentID)
+	b.runMu.Lock()
+	b.runCtx = ctx
+	b.runMu.Unlock()
+	defer b.inflight.Wait()
+
+	if b.approvals != nil {
+		go b.watchApprovals(ctx)
+	}
+	for {
+		if err := b.identify(ctx); err == nil {
+			break
+		} else {
+			log.Printf("[slack] auth.test error: %v — retrying in 5s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+
+	if b.appToken == "" {
+		log.Printf("[slack] no appToken — receiving events via webhook agent=%s channel=%s", b.agentID, b.channelID)
+		<-ctx.Done()
+		return
+	}
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := b.runOnce(ctx); err != nil {
+			log.Printf("[slack] socket mode error: %v — reconnecting in 5s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// identify calls auth.test and records the bot's user id.
+func (b *SlackBot) identify(ctx context.Context) error {
+	var out struct {
+		UserID string `json:"user_id"`
+		User   string `json:"user"`
+	}
+	if err := b.api(ctx, b.botToken, "auth.test", nil, &out); err != nil {
+		return err
+	}
+	b.identMu.Lock()
+	b.botUserID, b.botName = out.UserID, out.User
+	b.identMu.Unlock()
+	log.Printf("[slack] bot user_id=%s name=%s", out.UserID, out.User)
+	if b.onConnected != nil {
+		b.onConnected(out.User)
+	}
+	return nil
+}
+
+func (b *SlackBot) selfID() string {
+	b.identMu.RLock()
+	defer b.identMu.RUnlock()
+	return b.botUserID
+}
+
+func (b *SlackBot) ctx() context.Context {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+	return b.runCtx
+}
+
+// runOnce opens one Socket Mode connection and reads envelopes until it
+// drops. Every envelope is acked immediately; Slack re-delivers otherwise.
+func (b *SlackBot) runOnce(ctx context.Context) error {
+	var open struct {
+		URL string `json:"url"`
+	}
+	if err := b.api(ctx, b.appToken, "apps.connections.open", nil, &open); err != nil {
+		return fmt.Errorf("apps.connections.open: %w", err)
+	}
+	conn, err := b.dialWS(ctx, open.URL)
+	if err != nil {
+		return fmt.Errorf("ws dial: %w", err)
+	}
+	defer conn.Close()
+	log.Printf("[slack] Socket Mode connected agent=%s", b.agentID)
+
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		_ = conn.Close()
+	})
+	defer stop()
+
+	for {
+		_, raw, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("ws read: %w", err)
+		}
+		var env slackEnvelope
+		if err := json.Unmarshal(raw, &env); err != nil {
+			log.Printf("[slack] bad frame: %v", err)
+			continue
+		}
+		switch env.Type {
+		case "hello":
+			continue
+		case "disconnect":
+			return fmt.Errorf("disconnect requested (%s)", env.Reason)
+		}
+		ack := map[string]any{"envelope_id": env.EnvelopeID}
+		switch env.Type {
+		case "events_api":
+			b.handleEventCallback(ctx, env.Payload)
+		case "slash_commands":
+			var cmd slackSlashCommand
+			if err := json.Unmarshal(env.Payload, &cmd); err == nil {
+				if reply := b.handleSlashCommand(ctx, cmd); reply != "" {
+					ack["payload"] = map[string]string{"text": reply}
+				}
+			}
+		case "interactive":
+			b.handleInteraction(ctx, env.Payload)
+		}
+		if env.EnvelopeID != "" {
+			if err := conn.WriteJSON(ack); err != nil {
+				return fmt.Errorf("ack write: %w", err)
+			}
+		}
+	}
+}
+
+// dialSlackWebSocket dials a Socket Mode URL through netguard (public hosts only).
+func dialSlackWebSocket(ctx context.Context, wsURL string) (*websocket.Conn, error) {
+	if err := netguard.ValidateWebSocketURL(ctx, wsURL); err != nil {
+		return nil, fmt.Errorf("ws endpoint blocked: %w", err)
+	}
+	dialer := *websocket.DefaultDialer
+	dialer.Proxy = nil
+	dialer.NetDialContext = netguard.DialContext
+	conn, _, err := dialer.DialContext(ctx, wsURL, nil)
+	return conn, err
+}
+
+// markSeen records key and reports whether it was already seen.
+func (b *SlackBot) markSeen(key string) bool {
+	b.seenMu.Lock()
+	defer b.seenMu.Unlock()
+	if _, dup := b.seen[key]; dup {
+		return true
+	}
+	b.seen[key] = time.Now()
+	if len(b.seen) > 2000 {
+		cutoff := time.Now().Add(-2 * time.Hour)
+		for k, t := range b.seen {
+			if t.Before(cutoff) {
+				delete(b.seen, k)
+			}
+		}
+	}
+	return false
+}
+
+// handleEventCallback routes an event_callback; message handling runs in
+// its own goroutine so the envelope / HTTP request is acked right away.
+func (b *SlackBot) handleEventCallback(ctx context.Context, raw []byte) {
+	var cb slackEventCallback
+	if err := json.Unmarshal(raw, &cb); err != nil || cb.Type != "event_callback" {
+		return
+	}
+	if cb.EventID != "" && b.markSeen("event:"+cb.EventID) {
+		return
+	}
+	var ev slackMessageEvent
+	if err := json.Unmarshal(cb.Event, &ev); err != nil {
+		return
+	}
+	if ev.Type == "message" || ev.Type == "app_mention" {
+		b.spawn(func() { b.handleMessageEvent(ctx, &ev) })
+	}
+}
+
+// spawn runs fn in a goroutine tracked by inflight.
+func (b *SlackBot) spawn(fn func()) {
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		fn()
+	}()
+}
+
+// slackChatRef converts a message event to a ChatRef. In channels every
+// top-level mention starts a thread (the reply goes under it), so the
+// thread is the session; DMs stay one session unless the user threads.
+func slackChatRef(ev *slackMessageEvent) ChatRef {
+	ref := ChatRef{ID: ev.Channel, ThreadID: ev.ThreadTS, Type: slackChatType(ev.Channel, ev.ChannelType)}
+	if ref.ThreadID == "" && ref.Type != "private" {
+		ref.ThreadID = ev.TS
+	}
+	return ref
+}
+
+// slackChatType maps Slack's channel_type to ChatRef.Type. app_mention
+// events carry no channel_type, so fall back to the id prefix (D = DM).
+func slackChatType(channelID, channelType string) string {
+	if channelType == "im" || (channelType == "" && strings.HasPrefix(channelID, "D")) {
+		return "private"
+	}
+	return "group"
+}
+
+func (b *SlackBot) handleMessageEvent(ctx context.Context, ev *slackMessageEvent) {
+	self := b.selfID()
+	if ev.User == "" || ev.BotID != "" || ev.User == self {
+		return
+	}
+	switch ev.Subtype {
+	case "", "file_share", "thread_broadcast":
+	default:
+		return // edits, joins, deletions, ...
+	}
+	// A channel mention arrives twice (message + app_mention) with one ts.
+	if b.markSeen("msg:" + ev.Channel + ":" + ev.TS) {
+		return
+	}
+
+	chat := slackChatRef(ev)
+	text := ev.Text
+	if chat.IsGroup() {
+		mention := "<@" + self + ">"
+		if ev.Type != "app_mention" && (self == "" || !strings.Contains(text, mention)) {
+			return // channels: only respond when @mentioned
+		}
+		text = strings.ReplaceAll(text, mention, "")
+	}
+	text = strings.TrimSpace(slackUnescape(text))
+	if text == "" && len(ev.Files) == 0 {
+		return
+	}
+
+	pipe := b.pipeline()
+	sessionID := pipe.SessionID(chat)
+	muVal, _ := b.chatMu.LoadOrStore(sessionID, &sync.Mutex{})
+	mu := muVal.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	senderName := b.userName(ctx, ev.User)
+	sender := Sender{ID: ev.User, Name: senderName}
+	if res := pipe.Check(sender, true); res != AccessAllowed {
+		log.Printf("[slack] access %v — user=%s channel=%s", res, ev.User, ev.Channel)
+		_, _ = b.Send(ctx, chat, b.pairingReply(res, ev.User), "")
+		return
+	}
+
+	media, extras := b.downloadFiles(ctx, ev.Files)
+	if text == "" {
+		text = strings.Join(extras, " ")
+	}
+	log.Printf("[slack] message from user=%s channel=%s text=%q", ev.User, ev.Channel, truncateStr(text, 60))
+
+	finalText := text
+	if chat.IsGroup() {
+		name := senderName
+		if name == "" {
+			name = ev.User
+		}
+		finalText = fmt.Sprintf("[%s]: %s", name, text)
+	}
+	in := InboundMessage{
+		ChannelType: "slack",
+		ChannelID:   b.channelID,
+		MessageID:   ev.TS,
+		Chat:        chat,
+		Sender:      sender,
+		Text:        finalText,
+		Media:       media,
+		ExtraContext: []string{fmt.Sprintf("当前 Slack 用户信息：user_id=%s，channel=%s，thread_ts=%s",
+			ev.User, ev.Channel, chat.ThreadID)},
+	}
+	b.sessions.Store(sessionID, chat)
+	pipe.LogInbound(in, text)
+	pipe.Dispatch(ctx, in)
+}
+
+// pairingReply guides a sender that is not on the allowlist to the panel.
+func (b *SlackBot) pairingReply(res AccessResult, userID string) string {
+	where := "ZyHive 管理面板"
+	if b.panelBaseURL != "" {
+		where = b.panelBaseURL + "/#/agents/" + b.agentID + "/channels"
+	}
+	if res == AccessPairing {
+		return fmt.Sprintf("👋 你好！此 Bot 尚未完成配对，请管理员在以下地址授权（你的 Slack ID：`%s`）：\n%s", userID, where)
+	}
+	return fmt.Sprintf("👋 你好！你的申请已收到（Slack ID：`%s`），等待管理员在以下地址审核：\n%s", userID, where)
+}
+
+// handleSlashCommand dispatches "/cmd text" as a message in the invoking
+// chat. The returned text is shown to the caller only (the ack).
+func (b *SlackBot) handleSlashCommand(ctx context.Context, cmd slackSlashCommand) string {
+	text := strings.TrimSpace(cmd.Text)
+	if text == "" || text == "help" {
+		return fmt.Sprintf("用法：`%s <问题>` — 在当前会话里向 AI 提问。", cmd.Command)
+	}
+	chat := ChatRef{ID: cmd.ChannelID, ThreadID: cmd.ThreadTS, Type: slackChatType(cmd.ChannelID, "")}
+	sender := Sender{ID: cmd.UserID, Username: cmd.UserName}
+	pipe := b.pipeline()
+	if res := pipe.Check(sender, true); res != AccessAllowed {
+		return b.pairingReply(res, cmd.UserID)
+	}
+	b.spawn(func() {
+		sender.Name = b.userName(ctx, cmd.UserID)
+		sessionID := pipe.SessionID(chat)
+		muVal, _ := b.chatMu.LoadOrStore(sessionID, &sync.Mutex{})
+		mu := muVal.(*sync.Mutex)
+		mu.Lock()
+		defer mu.Unlock()
+		in := InboundMessage{
+			ChannelType: "slack",
+			ChannelID:   b.channelID,
+			Chat:        chat,
+			Sender:      sender,
+			Text:        text,
+			ExtraContext: []string{fmt.Sprintf("当前 Slack 用户信息：user_id=%s，channel=%s，command=%s",
+				cmd.UserID, cmd.ChannelID, cmd.Command)},
+		}
+		b.sessions.Store(sessionID, chat)
+		pipe.LogInbound(in, cmd.Command+" "+text)
+		pipe.Dispatch(ctx, in)
+	})
+	return "⌛ 已收到，正在处理…"
+}
+
+// userName returns the user's display name via users.info (cached; "" on error).
+func (b *SlackBot) userName(ctx context.Context, userID string) string {
+	b.usersMu.Lock()
+	name, ok := b.users[userID]
+	b.usersMu.Unlock()
+	if ok {
+		return name
+	}
+	var out struct {
+		User slackUser `json:"user"`
+	}
+	if err := b.api(ctx, b.botToken, "users.info", url.Values{"user": {userID}}, &out); err != nil {
+		log.Printf("[slack] users.info %s: %v", userID, err)
+		return ""
+	}
+	u := out.User
+	for _, n := range []string{u.Profile.DisplayName, u.Profile.RealName, u.RealName, u.Name} {
+		if n != "" {
+			name = n
+			break
+		}
+	}
+	b.usersMu.Lock()
+	b.users[userID] = name
+	b.usersMu.Unlock()
+	return name
+}
+
+// downloadFiles fetches image / PDF attachments (max 5) as MediaInput;
+// other files become "[📎 name]" placeholders.
+func (b *SlackBot) downloadFiles(ctx context.Context, files []slackFile) ([]MediaInput, []string) {
+	const maxFiles = 5
+	var media []MediaInput
+	var extras []string
+	for _, f := range files {
+		vision := strings.HasPrefix(f.Mimetype, "image/") || f.Mimetype == "application/pdf"
+		if !vision || len(media) >= maxFiles || f.Size > slackMaxFileBytes || f.URLPrivateDownload == "" {
+			extras = append(extras, "[📎 "+f.Name+"]")
+			continue
+		}
+		data, err := b.download(ctx, f.URLPrivateDownload)
+		if err != nil {
+			log.Printf("[slack] download file=%s: %v", f.ID, err)
+			extras = append(extras, "[📎 "+f.Name+"]")
+			continue
+		}
+		media = append(media, MediaInput{Data: data, ContentType: f.Mimetype, FileName: f.Name})
+	}
+	if len(media) > 0 && len(extras) == 0 {
+		extras = append(extras, "[📷 图片]")
+	}
+	return media, extras
+}
+
+func (b *SlackBot) download(ctx context.Context, fileURL string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.botToken)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, slackMaxFileBytes))
+}
+
+// ProactiveSend DMs every allowlisted user (cron announcements, send_message).
+func (b *SlackBot) ProactiveSend(text string) error {
+	ctx := b.ctx()
+	var lastErr error
+	for _, userID := range b.ProactiveRecipients() {
+		if err := b.ProactiveSendTo(ctx, userID, text); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// ProactiveRecipients implements RecipientSender: the allowlisted users.
+func (b *SlackBot) ProactiveRecipients() []string { return b.getAllowFrom() }
+
+// ProactiveSendTo implements RecipientSender: a DM to one user.
+func (b *SlackBot) ProactiveSendTo(ctx context.Context, userID, text string) error {
+	var out struct {
+		Channel struct {
+			ID string `json:"id"`
+		} `json:"channel"`
+	}
+	if err := b.api(ctx, b.botToken, "conversations.open", map[string]any{"users": userID}, &out); err != nil {
+		return err
+	}
+	_, err := b.Send(ctx, ChatRef{ID: out.Channel.ID, Type: "private"}, text, "")
+	return err
+}
+
+// ── Slack Web API helpers ─────────────────────────────────────────────────
+
+// api calls a Web API method. params is url.Values (form-encoded, for
+// methods that do not take JSON), any other value (JSON body) or nil.
+func (b *SlackBot) api(ctx context.Context, token, method string, params any, out any) error {
+	var body io.Reader
+	contentType := "application/json; charset=utf-8"
+	switch p := params.(type) {
+	case nil:
+	case url.Values:
+		body = strings.NewReader(p.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		data, err := json.Marshal(p)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.apiBase+"/"+method, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", contentType)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("slack %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("slack %s: %w", method, err)
+	}
+	var status struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(raw, &status); err != nil {
+		return fmt.Errorf("slack %s: HTTP %d: %s", method, resp.StatusCode, truncateStr(string(raw), 200))
+	}
+	if !status.OK {
+		return fmt.Errorf("slack %s: %s", method, status.Error)
+	}
+	if out != nil {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// postMessage posts text (or blocks) into a chat / thread and returns its ts.
+func (b *SlackBot) postMessage(ctx context.Context, chat ChatRef, text string, blocks []map[string]any) (string, error) {
+	params := map[string]any{"channel": chat.ID, "text": text}
+	if chat.ThreadID != "" {
+		params["thread_ts"] = chat.ThreadID
+	}
+	if blocks != nil {
+		params["blocks"] = blocks
+	}
+	var out struct {
+		TS string `json:"ts"`
+	}
+	if err := b.api(ctx, b.botToken, "chat.postMessage", params, &out); err != nil {
+		return "", err
+	}
+	return out.TS, nil
+}
+
+// updateMessage rewrites a message; blocks nil keeps the text-only layout.
+func (b *SlackBot) updateMessage(ctx context.Context, channelID, ts, text string, blocks []map[string]any) error {
+	params := map[string]any{"channel": channelID, "ts": ts, "text": text}
+	if blocks != nil {
+		params["blocks"] = blocks
+	}
+	return b.api(ctx, b.botToken, "chat.update", params, nil)
+}
+
+// uploadFile runs Slack's external upload flow: reserve an upload URL,
+// POST the bytes, then share the file into the chat / thread.
+func (b *SlackBot) uploadFile(ctx context.Context, chat ChatRef, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	name := filepath.Base(path)
+	var slot struct {
+		UploadURL string `json:"upload_url"`
+		FileID    string `json:"file_id"`
+	}
+	if err := b.api(ctx, b.botToken, "files.getUploadURLExternal", url.Values{
+		"filename": {name},
+		"length":   {fmt.Sprint(len(data))},
+	}, &slot); err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, slot.UploadURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("upload: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("upload: HTTP %d", resp.StatusCode)
+	}
+	files, _ := json.Marshal([]map[string]string{{"id": slot.FileID, "title": name}})
+	complete := url.Values{"files": {string(files)}, "channel_id": {chat.ID}}
+	if chat.ThreadID != "" {
+		complete.Set("thread_ts", chat.ThreadID)
+	}
+	if err := b.api(ctx, b.botToken, "files.completeUploadExternal", complete, nil); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("✅ 文件 %s 已发送（%d 字节）", name, len(data)), nil
+}
+
+// TestSlackBot verifies a bot token with auth.test and returns the bot name.
+func TestSlackBot(ctx context.Context, botToken string) (string, error) {
+	b := &SlackBot{botToken: botToken, apiBase: slackAPIBase, client: netguard.NewSafeClient(8 * time.Second)}
+	var out struct {
+		User string `json:"user"`
+		Team string `json:"team"`
+	}
+	if err := b.api(ctx, botToken, "auth.test", nil, &out); err != nil {
+		return "", err
+	}
+	return out.User, nil
+}
+
+// ── Text formatting ───────────────────────────────────────────────────────
+
+var (
+	slackBoldRe    = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	slackStrikeRe  = regexp.MustCompile(`~~(.+?)~~`)
+	slackLinkRe    = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+	slackHeadingRe = regexp.MustCompile(`(?m)^#{1,6}\s+(.+)$`)
+)
+
+// slackMrkdwn converts the model's Markdown to Slack mrkdwn: escapes
+// &, <, >, then rewrites bold, strike, links and headings. Code spans and
+// blocks use the same backticks in both and pass through.
+func slackMrkdwn(text string) string {
+	text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+	text = slackBoldRe.ReplaceAllString(text, "*$1*")
+	text = slackStrikeRe.ReplaceAllString(text, "~$1~")
+	text = slackLinkRe.ReplaceAllString(text, "<$2|$1>")
+	text = slackHeadingRe.ReplaceAllString(text, "*$1*")
+	return text
+}
+
+// slackUnescape reverses Slack's &, <, > escaping of inbound text.
+func slackUnescape(text string) string {
+	return strings.NewReplacer("&lt;", "<", "&gt;", ">", "&amp;", "&").Replace(text)
+}
@@ -0,0 +1,297 @@
+package channel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SlackBot implements Driver, Notifier and WebhookHandler.
+var (
+	_ Driver         = (*SlackBot)(nil)
+	_ Notifier       = (*SlackBot)(nil)
+	_ WebhookHandler = (*SlackBot)(nil)
+)
+
+// Block Kit action ids of the approval buttons.
+const (
+	slackActionApprove = "zyhive_approve"
+	slackActionDeny    = "zyhive_deny"
+)
+
+// slackMaxSkew is how old a signed webhook request may be (replay guard).
+const slackMaxSkew = 5 * time.Minute
+
+func init() {
+	RegisterDriver(DriverSpec{
+		Type:      "slack",
+		Required:  []string{"botToken"},
+		UniqueKey: "botToken",
+		New:       newSlackDriver,
+		Test: func(ctx context.Context, cfg map[string]string) (string, error) {
+			return TestSlackBot(ctx, cfg["botToken"])
+		},
+	})
+}
+
+func newSlackDriver(env DriverEnv) (Driver, error) {
+	if env.Config["appToken"] == "" && env.Config["signingSecret"] == "" {
+		return nil, errors.New("slack channel needs appToken (Socket Mode) or signingSecret (Events API)")
+	}
+	var pending *PendingStoreStr
+	if dir := env.PendingDir(); dir != "" {
+		pending = NewPendingStoreStr(dir, env.ChannelID)
+	}
+	getAllowFrom := env.AllowFrom
+	if getAllowFrom == nil {
+		getAllowFrom = func() []string { return nil }
+	}
+	bot := NewSlackBotWithStream(env.Config["botToken"], env.Config["appToken"], env.Config["signingSecret"],
+		env.AgentID, env.AgentDir, env.ChannelID, getAllowFrom, env.Stream, pending)
+	bot.SetPanelBaseURL(env.PanelBaseURL)
+	bot.SetOnConnected(env.OnConnected)
+	bot.SetApprovals(env.Approvals)
+	return bot, nil
+}
+
+// pipeline returns the shared message path bound to this bot.
+func (b *SlackBot) pipeline() *Pipeline {
+	return &Pipeline{
+		Env: DriverEnv{
+			AgentID:      b.agentID,
+			AgentDir:     b.agentDir,
+			ChannelID:    b.channelID,
+			Stream:       b.streamFunc,
+			AllowFrom:    b.getAllowFrom,
+			PanelBaseURL: b.panelBaseURL,
+		},
+		Driver:  b,
+		Pending: b.pendingStore.Recorder(),
+	}
+}
+
+// Type implements Driver.
+func (b *SlackBot) Type() string { return "slack" }
+
+// Capabilities implements Driver. chat.update is Tier 3 (~50/min), hence
+// the slower edit cadence; every thread is its own session. Slack
+// truncates message text beyond 40,000 characters.
+func (b *SlackBot) Capabilities() Capabilities {
+	return Capabilities{Edit: true, Files: true, Threads: true, ThreadSessions: true, EditEvery: 1500 * time.Millisecond, MaxText: 40000}
+}
+
+// Send implements Driver; the reply goes into chat.ThreadID (replyTo is
+// not used — Slack quotes by threading).
+func (b *SlackBot) Send(ctx context.Context, chat ChatRef, text, _ string) (string, error) {
+	return b.postMessage(ctx, chat, slackMrkdwn(text), nil)
+}
+
+// Edit implements Driver.
+func (b *SlackBot) Edit(ctx context.Context, chat ChatRef, msgID, text string) error {
+	return b.updateMessage(ctx, chat.ID, msgID, slackMrkdwn(text), nil)
+}
+
+// Typing implements Driver; bot users have no typing indicator.
+func (b *SlackBot) Typing(context.Context, ChatRef) error { return nil }
+
+// SendFile implements Driver.
+func (b *SlackBot) SendFile(ctx context.Context, chat ChatRef, path string) (string, error) {
+	return b.uploadFile(ctx, chat, path)
+}
+
+// Notify runs the agent on prompt in the chat's (thread's) session and
+// posts the reply.
+func (b *SlackBot) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	if chat.Type == "" {
+		chat.Type = slackChatType(chat.ID, "")
+	}
+	return b.pipeline().Notify(ctx, chat, prompt)
+}
+
+// ── Events API webhook ────────────────────────────────────────────────────
+
+// ServeWebhook implements WebhookHandler: Events API callbacks (JSON),
+// slash commands (form) and interactivity (form "payload"), all verified
+// with the app's signing secret.
+func (b *SlackBot) ServeWebhook(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackSignature(b.signingSecret, r.Header, body, time.Now()); err != nil {
+		log.Printf("[slack] webhook rejected agent=%s channel=%s: %v", b.agentID, b.channelID, err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	ctx := b.ctx()
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		if payload := form.Get("payload"); payload != "" {
+			b.handleInteraction(ctx, []byte(payload))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if form.Get("command") != "" {
+			reply := b.handleSlashCommand(ctx, slackSlashCommand{
+				Command:   form.Get("command"),
+				Text:      form.Get("text"),
+				UserID:    form.Get("user_id"),
+				UserName:  form.Get("user_name"),
+				ChannelID: form.Get("channel_id"),
+				ThreadTS:  form.Get("thread_ts"),
+			})
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]string{"response_type": "ephemeral", "text": reply})
+			return
+		}
+		http.Error(w, "unsupported form", http.StatusBadRequest)
+		return
+	}
+
+	var cb slackEventCallback
+	if err := json.Unmarshal(body, &cb); err != nil {
+		http.Error(w, "bad json", http.StatusBadRequest)
+		return
+	}
+	if cb.Type == "url_verification" {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, cb.Challenge)
+		return
+	}
+	b.handleEventCallback(ctx, body)
+	w.WriteHeader(http.StatusOK)
+}
+
+// verifySlackSignature checks X-Slack-Signature:
+// "v0=" + hex(HMAC-SHA256(secret, "v0:{timestamp}:{body}")).
+func verifySlackSignature(secret string, h http.Header, body []byte, now time.Time) error {
+	if secret == "" {
+		return errors.New("no signingSecret configured")
+	}
+	tsStr := h.Get("X-Slack-Request-Timestamp")
+	ts, err := strconv.ParseInt(tsStr, 10, 64)
+	if err != nil {
+		return errors.New("missing timestamp")
+	}
+	if d := now.Sub(time.Unix(ts, 0)); d > slackMaxSkew || d < -slackMaxSkew {
+		return errors.New("stale timestamp")
+	}
+	if !hmac.Equal([]byte(slackSign(secret, ts, body)), []byte(h.Get("X-Slack-Signature"))) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// ── Tool approvals (Block Kit) ────────────────────────────────────────────
+
+// watchApprovals posts approve / deny buttons for approval requests raised
+// by runs this bot started (looked up by session id).
+func (b *SlackBot) watchApprovals(ctx context.Context) {
+	for p := range b.approvals.Watch(ctx) {
+		if p.AgentID != b.agentID {
+			continue
+		}
+		v, ok := b.sessions.Load(p.SessionID)
+		if !ok {
+			continue
+		}
+		chat := v.(ChatRef)
+		if _, err := b.postMessage(ctx, chat, "🔐 工具调用待审批："+p.ToolName, slackApprovalBlocks(p)); err != nil {
+			log.Printf("[slack] approval prompt id=%s: %v", p.ID, err)
+		}
+	}
+}
+
+// slackApprovalBlocks renders an approval request with two buttons whose
+// value is the approval id.
+func slackApprovalBlocks(p ApprovalPrompt) []map[string]any {
+	input := p.Input
+	if len(input) > 1500 {
+		input = input[:1500] + "…"
+	}
+	button := func(label, style, actionID string) map[string]any {
+		return map[string]any{
+			"type":      "button",
+			"text":      map[string]any{"type": "plain_text", "text": label},
+			"style":     style,
+			"action_id": actionID,
+			"value":     p.ID,
+		}
+	}
+	return []map[string]any{
+		{"type": "section", "text": map[string]any{"type": "mrkdwn",
+			"text": fmt.Sprintf("🔐 *工具调用待审批*：`%s`\n```%s```", p.ToolName, input)}},
+		{"type": "context", "elements": []map[string]any{{"type": "mrkdwn",
+			"text": "超时自动拒绝：" + p.ExpiresAt.Local().Format("15:04:05")}}},
+		{"type": "actions", "elements": []map[string]any{
+			button("✅ 允许", "primary", slackActionApprove),
+			button("❌ 拒绝", "danger", slackActionDeny),
+		}},
+	}
+}
+
+// handleInteraction resolves approval button clicks. Only allowlisted
+// users may decide; the prompt is rewritten with the outcome.
+func (b *SlackBot) handleInteraction(ctx context.Context, raw []byte) {
+	var in slackInteraction
+	if err := json.Unmarshal(raw, &in); err != nil || in.Type != "block_actions" {
+		return
+	}
+	for _, a := range in.Actions {
+		if a.ActionID != slackActionApprove && a.ActionID != slackActionDeny {
+			continue
+		}
+		approved := a.ActionID == slackActionApprove
+		id := a.Value
+		b.spawn(func() {
+			if b.approvals == nil {
+				return
+			}
+			if b.pipeline().Check(Sender{ID: in.User.ID}, false) != AccessAllowed {
+				log.Printf("[slack] approval %s: user %s not allowed", id, in.User.ID)
+				_ = b.api(ctx, b.botToken, "chat.postEphemeral", map[string]any{
+					"channel": in.Container.ChannelID, "user": in.User.ID, "text": "⛔ 你没有审批权限。",
+				}, nil)
+				return
+			}
+			status := "✅ 已允许"
+			if !approved {
+				status = "❌ 已拒绝"
+			}
+			if err := b.approvals.Decide(id, approved, "slack:"+in.User.ID); err != nil {
+				status = "⌛ 审批已失效"
+				log.Printf("[slack] approval %s: %v", id, err)
+			}
+			text := fmt.Sprintf("%s（<@%s>）", status, in.User.ID)
+			blocks := []map[string]any{{"type": "section", "text": map[string]any{"type": "mrkdwn", "text": text}}}
+			if err := b.updateMessage(ctx, in.Container.ChannelID, in.Container.MessageTS, text, blocks); err != nil {
+				log.Printf("[slack] approval %s: update prompt: %v", id, err)
+			}
+		})
+	}
+}
+
+// slackSign computes the v0 signature of body sent at ts.
+func slackSign(secret string, ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%d:", ts)
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,389 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/network"
+	"github.com/gorilla/websocket"
+)
+
+// fakeSlack is a minimal Slack Web API (+ Socket Mode) stand-in.
+type fakeSlack struct {
+	t     *testing.T
+	srv   *httptest.Server
+	mu    sync.Mutex
+	calls []slackCall
+	// frames are pushed to the Socket Mode client; acks collects its replies.
+	frames chan string
+	acks   chan map[string]any
+}
+
+type slackCall struct {
+	Method string
+	Params map[string]any
+}
+
+func newFakeSlack(t *testing.T) *fakeSlack {
+	f := &fakeSlack{t: t, frames: make(chan string, 8), acks: make(chan map[string]any, 8)}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeSlack) serve(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/ws" {
+		f.serveSocket(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	params := map[string]any{}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		_ = json.Unmarshal(body, &params)
+	} else if form, err := url.ParseQuery(string(body)); err == nil {
+		for k := range form {
+			params[k] = form.Get(k)
+		}
+	}
+	if method == "/upload" {
+		params["bytes"] = string(body)
+	}
+	f.mu.Lock()
+	f.calls = append(f.calls, slackCall{Method: method, Params: params})
+	n := len(f.calls)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch method {
+	case "auth.test":
+		_, _ = io.WriteString(w, `{"ok":true,"user_id":"UBOT","user":"zybot","team":"T"}`)
+	case "apps.connections.open":
+		_, _ = io.WriteString(w, `{"ok":true,"url":"ws`+strings.TrimPrefix(f.srv.URL, "http")+`/ws"}`)
+	case "users.info":
+		_, _ = io.WriteString(w, `{"ok":true,"user":{"id":"U1","name":"alice","profile":{"display_name":"Alice"}}}`)
+	case "chat.postMessage":
+		_, _ = io.WriteString(w, `{"ok":true,"ts":"900.`+strconv.Itoa(n)+`"}`)
+	case "files.getUploadURLExternal":
+		_, _ = io.WriteString(w, `{"ok":true,"upload_url":"`+f.srv.URL+`/upload","file_id":"F1"}`)
+	case "/upload":
+		w.WriteHeader(http.StatusOK)
+	default:
+		_, _ = io.WriteString(w, `{"ok":true}`)
+	}
+}
+
+func (f *fakeSlack) serveSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"hello"}`))
+	go func() {
+		for {
+			var ack map[string]any
+			if err := conn.ReadJSON(&ack); err != nil {
+				return
+			}
+			f.acks <- ack
+		}
+	}()
+	for frame := range f.frames {
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			return
+		}
+	}
+}
+
+// wait returns the calls once cond holds (or fails after 3s).
+func (f *fakeSlack) wait(cond func([]slackCall) bool) []slackCall {
+	f.t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		f.mu.Lock()
+		calls := append([]slackCall(nil), f.calls...)
+		f.mu.Unlock()
+		if cond(calls) {
+			return calls
+		}
+		if time.Now().After(deadline) {
+			f.t.Fatalf("timed out; calls = %+v", calls)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func hasCall(method string) func([]slackCall) bool {
+	return func(calls []slackCall) bool {
+		for _, c := range calls {
+			if c.Method == method {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func findCall(calls []slackCall, method string) (slackCall, bool) {
+	for _, c := range calls {
+		if c.Method == method {
+			return c, true
+		}
+	}
+	return slackCall{}, false
+}
+
+// newTestSlackBot wires a bot to the fake server; runs record session ids.
+func newTestSlackBot(t *testing.T, f *fakeSlack, allow []string, sessions chan<- string) *SlackBot {
+	t.Helper()
+	stream := func(_ context.Context, _, _, sessionID string, _ []MediaInput, _ FileSenderFunc, _ ...string) (<-chan StreamEvent, error) {
+		if sessions != nil {
+			sessions <- sessionID
+		}
+		return streamOf(StreamEvent{Type: "text_delta", Text: "**hi**"}, StreamEvent{Type: "done"}), nil
+	}
+	b := NewSlackBotWithStream("xoxb-1", "", "sekret", "a1", t.TempDir(), "slack-1",
+		func() []string { return allow }, stream, nil)
+	b.apiBase = f.srv.URL + "/api"
+	b.client = f.srv.Client()
+	if err := b.identify(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(b.inflight.Wait) // runs write into agentDir until they finish
+	return b
+}
+
+// postWebhook sends a signed request to the bot's webhook handler.
+func postWebhook(t *testing.T, b *SlackBot, contentType, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	ts := time.Now().Unix()
+	req := httptest.NewRequest(http.MethodPost, "/channels/a1/slack-1/webhook", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Slack-Signature", slackSign(b.signingSecret, ts, []byte(body)))
+	rec := httptest.NewRecorder()
+	b.ServeWebhook(rec, req)
+	return rec
+}
+
+func eventBody(event string) string {
+	return `{"type":"event_callback","event_id":"Ev` + strconv.FormatInt(time.Now().UnixNano(), 10) + `","event":` + event + `}`
+}
+
+func TestVerifySlackSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"type":"event_callback"}`)
+	h := http.Header{}
+	h.Set("X-Slack-Request-Timestamp", "1700000000")
+	h.Set("X-Slack-Signature", slackSign("s", 1700000000, body))
+	if err := verifySlackSignature("s", h, body, now); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := verifySlackSignature("other", h, body, now); err == nil {
+		t.Error("wrong secret accepted")
+	}
+	if err := verifySlackSignature("s", h, body, now.Add(10*time.Minute)); err == nil {
+		t.Error("stale request accepted")
+	}
+	if err := verifySlackSignature("", h, body, now); err == nil {
+		t.Error("empty secret accepted")
+	}
+}
+
+func TestSlackWebhookMentionGatingAndThreads(t *testing.T) {
+	f := newFakeSlack(t)
+	sessions := make(chan string, 4)
+	b := newTestSlackBot(t, f, []string{"U1"}, sessions)
+
+	rec := postWebhook(t, b, "application/json", `{"type":"url_verification","challenge":"abc"}`)
+	if rec.Body.String() != "abc" {
+		t.Fatalf("challenge = %q", rec.Body.String())
+	}
+	bad := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{}`))
+	bad.Header.Set("X-Slack-Request-Timestamp", strconv.FormatInt(time.Now().Unix(), 10))
+	bad.Header.Set("X-Slack-Signature", "v0=00")
+	badRec := httptest.NewRecorder()
+	b.ServeWebhook(badRec, bad)
+	if badRec.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned request = %d", badRec.Code)
+	}
+
+	// Channel message without a mention is ignored.
+	postWebhook(t, b, "application/json", eventBody(`{"type":"message","channel":"C1","channel_type":"channel","user":"U1","text":"hello all","ts":"100.1"}`))
+	// Mention: message + app_mention for the same ts → one run, in a thread session.
+	postWebhook(t, b, "application/json", eventBody(`{"type":"message","channel":"C1","channel_type":"channel","user":"U1","text":"<@UBOT> help me","ts":"100.2"}`))
+	postWebhook(t, b, "application/json", eventBody(`{"type":"app_mention","channel":"C1","user":"U1","text":"<@UBOT> help me","ts":"100.2"}`))
+	if got := <-sessions; got != "slack-C1-100.2" {
+		t.Errorf("channel session = %q", got)
+	}
+	calls := f.wait(hasCall("chat.postMessage"))
+	post, _ := findCall(calls, "chat.postMessage")
+	if post.Params["thread_ts"] != "100.2" || post.Params["text"] != "*hi*" {
+		t.Errorf("reply = %+v", post.Params)
+	}
+
+	// DM: no mention needed, one session per DM.
+	postWebhook(t, b, "application/json", eventBody(`{"type":"message","channel":"D1","channel_type":"im","user":"U1","text":"ping","ts":"200.1"}`))
+	if got := <-sessions; got != "slack-D1" {
+		t.Errorf("dm session = %q", got)
+	}
+	select {
+	case extra := <-sessions:
+		t.Errorf("unexpected extra run %q", extra)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// The sender was filed into the agent's contact book.
+	store := network.NewStore(filepath.Join(b.agentDir, "workspace"))
+	c, err := store.Get(network.MakeID(network.SourceSlack, "U1"))
+	if err != nil || c == nil {
+		t.Fatalf("contact not filed: %v", err)
+	}
+}
+
+func TestSlackPairingAndSlashCommand(t *testing.T) {
+	f := newFakeSlack(t)
+	sessions := make(chan string, 4)
+	b := newTestSlackBot(t, f, []string{"U1"}, sessions)
+	b.pendingStore = NewPendingStoreStr(t.TempDir(), "slack-1")
+
+	// Stranger in a DM: pairing reply, recorded as pending, no run.
+	postWebhook(t, b, "application/json", eventBody(`{"type":"message","channel":"D9","channel_type":"im","user":"U9","text":"hi","ts":"300.1"}`))
+	f.wait(hasCall("chat.postMessage"))
+	if p := b.pendingStore.List(); len(p) != 1 || p[0].ID != "U9" {
+		t.Errorf("pending = %+v", p)
+	}
+
+	form := url.Values{"command": {"/zy"}, "text": {"summarize"}, "user_id": {"U1"}, "channel_id": {"C2"}}
+	rec := postWebhook(t, b, "application/x-www-form-urlencoded", form.Encode())
+	var ack map[string]string
+	_ = json.Unmarshal(rec.Body.Bytes(), &ack)
+	if ack["response_type"] != "ephemeral" || ack["text"] == "" {
+		t.Errorf("slash ack = %s", rec.Body.String())
+	}
+	if got := <-sessions; got != "slack-C2" {
+		t.Errorf("slash session = %q", got)
+	}
+}
+
+func TestSlackSocketModeAck(t *testing.T) {
+	f := newFakeSlack(t)
+	sessions := make(chan string, 4)
+	b := newTestSlackBot(t, f, []string{"U1"}, sessions)
+	b.appToken = "xapp-1"
+	b.dialWS = func(ctx context.Context, wsURL string) (*websocket.Conn, error) {
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		return conn, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Start(ctx)
+
+	f.frames <- `{"type":"events_api","envelope_id":"env-1","payload":` +
+		eventBody(`{"type":"message","channel":"D1","channel_type":"im","user":"U1","text":"yo","ts":"400.1"}`) + `}`
+	select {
+	case ack := <-f.acks:
+		if ack["envelope_id"] != "env-1" {
+			t.Errorf("ack = %v", ack)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("no ack")
+	}
+	if got := <-sessions; got != "slack-D1" {
+		t.Errorf("session = %q", got)
+	}
+	close(f.frames)
+}
+
+// fakeApprovals is an in-memory channel.Approvals.
+type fakeApprovals struct {
+	prompts chan ApprovalPrompt
+	mu      sync.Mutex
+	decided []string
+}
+
+func (a *fakeApprovals) Watch(context.Context) <-chan ApprovalPrompt { return a.prompts }
+func (a *fakeApprovals) Decide(id string, approved bool, by string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.decided = append(a.decided, id+"/"+strconv.FormatBool(approved)+"/"+by)
+	return nil
+}
+
+func TestSlackApprovalButtons(t *testing.T) {
+	f := newFakeSlack(t)
+	sessions := make(chan string, 4)
+	b := newTestSlackBot(t, f, []string{"U1"}, sessions)
+	appr := &fakeApprovals{prompts: make(chan ApprovalPrompt, 1)}
+	b.SetApprovals(appr)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.watchApprovals(ctx)
+
+	postWebhook(t, b, "application/json", eventBody(`{"type":"message","channel":"D1","channel_type":"im","user":"U1","text":"rm it","ts":"500.1"}`))
+	sid := <-sessions
+	f.wait(hasCall("chat.postMessage"))
+
+	appr.prompts <- ApprovalPrompt{ID: "ap1", AgentID: "a1", SessionID: sid, ToolName: "exec", Input: `{"cmd":"rm"}`, ExpiresAt: time.Now().Add(time.Minute)}
+	f.wait(func(calls []slackCall) bool {
+		for _, c := range calls {
+			if c.Method == "chat.postMessage" && c.Params["blocks"] != nil {
+				return true
+			}
+		}
+		return false
+	})
+
+	payload, _ := json.Marshal(map[string]any{
+		"type":      "block_actions",
+		"user":      map[string]string{"id": "U1"},
+		"container": map[string]string{"channel_id": "D1", "message_ts": "900.9"},
+		"actions":   []map[string]string{{"action_id": slackActionDeny, "value": "ap1"}},
+	})
+	postWebhook(t, b, "application/x-www-form-urlencoded", url.Values{"payload": {string(payload)}}.Encode())
+	f.wait(hasCall("chat.update"))
+	appr.mu.Lock()
+	defer appr.mu.Unlock()
+	if strings.Join(appr.decided, ",") != "ap1/false/slack:U1" {
+		t.Errorf("decided = %v", appr.decided)
+	}
+}
+
+func TestSlackSendFile(t *testing.T) {
+	f := newFakeSlack(t)
+	b := newTestSlackBot(t, f, nil, nil)
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := os.WriteFile(path, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.SendFile(context.Background(), ChatRef{ID: "C1", ThreadID: "1.2"}, path); err != nil {
+		t.Fatal(err)
+	}
+	calls := f.wait(hasCall("files.completeUploadExternal"))
+	up, _ := findCall(calls, "/upload")
+	done, _ := findCall(calls, "files.completeUploadExternal")
+	if up.Params["bytes"] != "data" || done.Params["channel_id"] != "C1" || done.Params["thread_ts"] != "1.2" ||
+		!strings.Contains(done.Params["files"].(string), `"F1"`) {
+		t.Errorf("upload=%+v complete=%+v", up.Params, done.Params)
+	}
+}
+
+func TestSlackMrkdwn(t *testing.T) {
+	got := slackMrkdwn("# Title\n**bold** [docs](https://x.io) a<b")
+	want := "*Title*\n*bold* <https://x.io|docs> a&lt;b"
+	if got != want {
+		t.Errorf("slackMrkdwn = %q, want %q", got, want)
+	}
+}
@@ -17,23 +17,27 @@ import (
 	"log"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	"github.com/Zyling-ai/zyhive/pkg/chatlog"
-	"github.com/Zyling-ai/zyhive/pkg/convlog"
 	"github.com/Zyling-ai/zyhive/pkg/netguard"
-	"github.com/Zyling-ai/zyhive/pkg/network"
 )
 
 // ── Event types ───────────────────────────────────────────────────────────
 
 // StreamEvent is a simplified event emitted during streaming generation.
 type StreamEvent struct {
-	Type string // "text_delta" | "error" | "done"
-	Text string
+	Type string // "text_delta" | "tool_call" | "tool_result" | "error" | "done"
+	Text string // text_delta text, or the tool_result output
 	Err  error
+	// Tool events: ToolCallID pairs a tool_result with its tool_call;
+	// ToolName and ToolInput (raw JSON) are set on tool_call only.
+	// Consumers that only render text ignore both types.
+	ToolCallID string
+	ToolName   string
+	ToolInput  string
 }
 
 // MediaInput represents a downloaded media file to pass to the LLM.
@@ -170,6 +174,8 @@ type mediaGroupEntry struct {
 
 // ── TelegramBot ───────────────────────────────────────────────────────────
 
+const telegramAPIBase = "https://api.telegram.org"
+
 type TelegramBot struct {
 	token        string
 	agentID      string
@@ -177,6 +183,7 @@ type TelegramBot struct {
 	channelID    string         // channel config ID, used for approved user store
 	getAllowFrom func() []int64 // dynamic allowFrom getter — hot-reloads on every message
 	streamFunc   StreamFunc
+	apiBase      string // telegramAPIBase; overridden in tests
 	client       *http.Client
 	offset       int64
 	pendingStore *PendingStore
@@ -199,6 +206,16 @@ type TelegramBot struct {
 	// Only the last message per chatID is kept (for context: threadID, replyTo, etc).
 	pendingMsgsMu sync.Mutex
 	pendingMsgs   map[int64]*TelegramMessage
+
+	// Webhook mode (telegram_webhook.go). webhookURL == "" = long polling.
+	webhookURL    string
+	webhookSecret string
+	hookMu        sync.Mutex
+	mode          string // "webhook" | "polling" once started
+	runCtx        context.Context
+	seenUpdates   map[int64]time.Time // update_id dedup (Telegram retries slow acks)
+
+	voice VoiceOptions // speech providers + voiceReply mode (voice.go)
 }
 
 // NewTelegramBot creates a Telegram bot that supports streaming and group chats.
@@ -233,6 +250,7 @@ func NewTelegramBot(token, agentID, agentDir string, allowFrom []int64, runner R
 		agentDir:     agentDir,
 		getAllowFrom: func() []int64 { return fixedList },
 		streamFunc:   sf,
+		apiBase:      telegramAPIBase,
 		client:       netguard.NewSafeClient(90 * time.Second),
 		pendingStore: pending,
 		mediaGroups:  make(map[string]*mediaGroupEntry),
@@ -248,6 +266,13 @@ func (b *TelegramBot) SetOnConnected(fn func(botUsername string)) {
 	b.onConnected = fn
 }
 
+// SetVoice sets the speech providers and the voiceReply mode
+// ("" | "auto" | "always").
+func (b *TelegramBot) SetVoice(v VoiceOptions, reply string) {
+	v.Reply = reply
+	b.voice = v
+}
+
 // NewTelegramBotWithStream creates a bot that uses a real StreamFunc.
 // getAllowFrom is called on every message so the allowlist can be updated dynamically
 // (e.g. after admin approves a pending user) without restarting the bot.
@@ -259,6 +284,7 @@ func NewTelegramBotWithStream(token, agentID, agentDir, channelID string, getAll
 		channelID:    channelID,
 		getAllowFrom: getAllowFrom,
 		streamFunc:   sf,
+		apiBase:      telegramAPIBase,
 		client:       netguard.NewSafeClient(90 * time.Second),
 		pendingStore: pending,
 		mediaGroups:  make(map[string]*mediaGroupEntry),
@@ -305,7 +331,7 @@ func SendApprovalWelcome(token string, chatID int64, agentName string) error {
 	data, _ := json.Marshal(payload)
 	client := netguard.NewSafeClient(10 * time.Second)
 	resp, err := client.Post(
-		fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token),
+		fmt.Sprintf("%s/bot%s/sendMessage", telegramAPIBase, token),
 		"application/json",
 		bytes.NewReader(data),
 	)
@@ -316,15 +342,8 @@ func SendApprovalWelcome(token string, chatID int64, agentName string) error {
 	return nil
 }
 
-// getConvLog returns a ConvLog for a given chatID, or nil if agentDir is unset.
-func (b *TelegramBot) getConvLog(chatID int64) *convlog.ConvLog {
-	if b.agentDir == "" {
-		return nil
-	}
-	return convlog.New(b.agentDir, fmt.Sprintf("telegram-%d", chatID))
-}
-
-// Start runs the long-poll loop. Fetches bot info first, then polls.
+// Start fetches bot info, then receives updates: over the webhook when a
+// public URL is set and setWebhook succeeds, otherwise by long polling.
 func (b *TelegramBot) Start(ctx context.Context) {
 	// Fetch bot identity
 	if err := b.fetchBotInfo(ctx); err != nil {
@@ -336,11 +355,23 @@ func (b *TelegramBot) Start(ctx context.Context) {
 			b.onConnected(b.botUsername)
 		}
 	}
+	if b.webhookURL != "" {
+		err := b.startWebhook(ctx)
+		if err == nil {
+			<-ctx.Done()
+			b.hookMu.Lock()
+			b.runCtx = nil
+			b.hookMu.Unlock()
+			log.Println("[telegram] Bot stopped")
+			return
+		}
+		log.Printf("[telegram] setWebhook failed, falling back to polling (agent=%s): %v", b.agentID, err)
+	}
 	b.pollLoop(ctx)
 }
 
 func (b *TelegramBot) fetchBotInfo(ctx context.Context) error {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/getMe", b.token)
+	url := fmt.Sprintf("%s/bot%s/getMe", b.apiBase, b.token)
 	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	resp, err := b.client.Do(req)
 	if err != nil {
@@ -363,6 +394,8 @@ func (b *TelegramBot) fetchBotInfo(ctx context.Context) error {
 
 func (b *TelegramBot) pollLoop(ctx context.Context) {
 	log.Printf("[telegram] Polling updates (agent=%s)", b.agentID)
+	b.setMode("polling")
+	b.releaseOwnWebhook(ctx)
 	for {
 		select {
 		case <-ctx.Done():
@@ -390,8 +423,8 @@ func (b *TelegramBot) pollLoop(ctx context.Context) {
 
 func (b *TelegramBot) getUpdates(ctx context.Context) ([]TelegramUpdate, error) {
 	url := fmt.Sprintf(
-		`https://api.telegram.org/bot%s/getUpdates?offset=%d&timeout=30&allowed_updates=["message","callback_query","channel_post"]`,
-		b.token, b.offset)
+		`%s/bot%s/getUpdates?offset=%d&timeout=30&allowed_updates=["message","callback_query","channel_post"]`,
+		b.apiBase, b.token, b.offset)
 	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
 	resp, err := b.client.Do(req)
 	if err != nil {
@@ -472,45 +505,29 @@ func (b *TelegramBot) handleUpdate(ctx context.Context, update TelegramUpdate) {
 	}
 
 	// ── Access control ────────────────────────────────────────────────────
-	// Call getAllowFrom() once per message so admin approvals take effect immediately.
-	currentAllowFrom := b.getAllowFrom()
-	if len(currentAllowFrom) == 0 {
+	// Pipeline.Check reads the live allowlist per message so admin approvals
+	// take effect immediately; it also maintains the pending list.
+	sender := telegramSender(msg.From)
+	switch b.pipeline().Check(sender, true) {
+	case AccessPairing:
 		// Pairing mode: tell user their ID
 		log.Printf("[telegram] Pairing mode — user %d (%s) in chat %d", senderID, msg.From.Username, msg.Chat.ID)
-		if b.pendingStore != nil {
-			b.pendingStore.Add(senderID, msg.From.Username, msg.From.FirstName)
-		}
 		pairMsg := fmt.Sprintf(
 			"👋 你好！此 Bot 尚未完成配对。\n\n请将以下信息发送给管理员，管理员将你加入白名单后即可开始使用：\n\n🔑 你的 Telegram ID：<code>%d</code>",
 			senderID,
 		)
 		_ = b.sendHTML(msg.Chat.ID, pairMsg, 0, 0)
 		return
-	}
-
-	allowed := false
-	for _, id := range currentAllowFrom {
-		if id == senderID {
-			allowed = true
-			break
-		}
-	}
-	if !allowed {
+	case AccessDenied:
 		log.Printf("[telegram] Pending user %d (%s)", senderID, msg.From.Username)
-		if b.pendingStore != nil {
-			b.pendingStore.Add(senderID, msg.From.Username, msg.From.FirstName)
-		}
 		if isStart {
 			_ = b.sendHTML(msg.Chat.ID, "👋 你好！你的申请已收到，等待管理员审核后即可使用。", 0, 0)
 		}
 		return
 	}
 
-	// Allowed user: clean pending entry, cache username, send 👀 reaction
-	if b.pendingStore != nil {
-		b.pendingStore.Remove(senderID)
-	}
-	// Cache username info in approved store so Web UI can display it
+	// Allowed user: cache username info in approved store so Web UI can
+	// display it, then send 👀 reaction
 	if b.agentDir != "" {
 		pendingDir := filepath.Join(b.agentDir, "channels-pending")
 		as := NewApprovedStore(pendingDir, b.channelID)
@@ -554,15 +571,15 @@ func (b *TelegramBot) handleUpdate(ctx context.Context, update TelegramUpdate) {
 	log.Printf("[telegram] Processing: chat=%d user=%s text=%q", msg.Chat.ID, msg.From.Username, truncate(text, 60))
 
 	// Log inbound user message to permanent conversation log (admin-only, agent-blind)
-	// Always log, even for media-only messages (use placeholder if text empty)
-	{
+	// Always log, even for media-only messages (use placeholder if text empty).
+	// Voice / audio turns are logged with their transcript once it exists
+	// (generateAndSendWithMedia).
+	if msg.Voice == nil && msg.Audio == nil {
 		logContent := text
 		if logContent == "" {
 			// media-only message
 			if len(msg.Photo) > 0 {
 				logContent = "[📷 图片]"
-			} else if msg.Voice != nil {
-				logContent = "[🎤 语音]"
 			} else if msg.Video != nil {
 				logContent = "[📹 视频]"
 			} else if msg.Document != nil {
@@ -573,33 +590,11 @@ func (b *TelegramBot) handleUpdate(ctx context.Context, update TelegramUpdate) {
 				logContent = "[媒体消息]"
 			}
 		}
-		tsUser := time.Now().UTC().Format(time.RFC3339)
-		tgChannelID := fmt.Sprintf("telegram-%d", msg.Chat.ID)
-		tgSessionID := fmt.Sprintf("telegram-%d", msg.Chat.ID)
-		senderStr := fmt.Sprintf("%s (%d)", msg.From.FirstName, msg.From.ID)
-		if cl := b.getConvLog(msg.Chat.ID); cl != nil {
-			_ = cl.Append(convlog.Entry{
-				Timestamp:   tsUser,
-				Role:        "user",
-				Content:     logContent,
-				ChannelID:   tgChannelID,
-				ChannelType: "telegram",
-				Sender:      senderStr,
-			})
-		}
-		// Write to AI-visible chatlog
-		if b.agentDir != "" {
-			clMgr := chatlog.NewManager(b.agentDir + "/workspace")
-			_ = clMgr.Append(chatlog.Entry{
-				Ts:          tsUser,
-				SessionID:   tgSessionID,
-				ChannelID:   tgChannelID,
-				ChannelType: "telegram",
-				Role:        "user",
-				Content:     logContent,
-				Sender:      senderStr,
-			})
-		}
+		b.pipeline().LogInbound(InboundMessage{
+			ChannelType: "telegram",
+			Chat:        telegramChatRef(msg.Chat, msg.MessageThreadID),
+			Sender:      sender,
+		}, logContent)
 	}
 
 	// For media messages, skip debouncing and dispatch immediately to avoid losing attachments.
@@ -667,19 +662,9 @@ func (b *TelegramBot) handleCallbackQuery(ctx context.Context, cq *TelegramCallb
 		return
 	}
 
-	// Access control (dynamic — reads live allowlist)
-	cbAllowFrom := b.getAllowFrom()
-	if len(cbAllowFrom) > 0 {
-		allowed := false
-		for _, id := range cbAllowFrom {
-			if id == senderID {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			return
-		}
+	// Access control (dynamic — reads live allowlist; unpaired bots stay open)
+	if b.pipeline().Check(telegramSender(cq.From), false) == AccessDenied {
+		return
 	}
 
 	log.Printf("[telegram] Callback query from user=%d data=%q", senderID, truncate(cq.Data, 60))
@@ -739,16 +724,7 @@ func (b *TelegramBot) bufferMediaGroup(ctx context.Context, msg *TelegramMessage
 
 			// Access control check using the first sender (dynamic — reads live allowlist)
 			first := collected[0]
-			senderID := first.From.ID
-			mgAllowFrom := b.getAllowFrom()
-			isAllowed := len(mgAllowFrom) == 0
-			for _, id := range mgAllowFrom {
-				if id == senderID {
-					isAllowed = true
-					break
-				}
-			}
-			if !isAllowed {
+			if b.pipeline().Check(telegramSender(first.From), false) == AccessDenied {
 				return
 			}
 
@@ -799,194 +775,39 @@ func (b *TelegramBot) generateAndSendWithMedia(ctx context.Context, msg *Telegra
 			fullText = extraText
 		}
 	}
+	if msg.Voice != nil || msg.Audio != nil {
+		b.pipeline().LogInbound(InboundMessage{
+			ChannelType: "telegram",
+			Chat:        telegramChatRef(msg.Chat, msg.MessageThreadID),
+			Sender:      telegramSender(msg.From),
+		}, fullText)
+	}
 	b.generateAndSend(ctx, msg, fullText, replyToMsgID, media)
 }
 
 // generateAndSend runs the agent and streams the response via send+edit draft pattern.
 func (b *TelegramBot) generateAndSend(ctx context.Context, msg *TelegramMessage, message string, replyToMsgID int64, media []MediaInput) {
-	chatID := msg.Chat.ID
-	threadID := msg.MessageThreadID
-
-	runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
-
-	// Start typing indicator (kept alive every 4s)
-	typingCtx, stopTyping := context.WithCancel(runCtx)
-	defer stopTyping()
-	go b.keepTyping(typingCtx, chatID, threadID)
-
-	// Per-chat session ID: gives the agent persistent memory per Telegram conversation.
-	sessionID := fmt.Sprintf("telegram-%d", chatID)
-
-	// File sender: AI can call send_file tool to deliver files to this chat.
-	fileSender := FileSenderFunc(func(filePath string) (string, error) {
-		return b.SendFileToChat(chatID, threadID, filePath)
-	})
-
-	// ── Network (contact book + chat profile) — resolve sender always; resolve
-	// the chat itself only when it's a group/supergroup/channel. Both summaries
-	// concatenated into contactSummary (chat first, sender second).
-	var contactSummary string
-	if b.agentDir != "" && msg != nil {
-		wsDir := filepath.Join(b.agentDir, "workspace")
-		store := network.NewStore(wsDir)
-
-		// 1. Group chat profile (only for groups; private chats covered by contact alone).
-		chatType := msg.Chat.Type
-		isGroupChat := chatType == "group" || chatType == "supergroup" || chatType == "channel"
-		if isGroupChat && msg.Chat.ID != 0 {
-			chatExtID := fmt.Sprintf("%d", msg.Chat.ID)
-			if _, cerr := store.ResolveChat(network.SourceTelegram, chatExtID, msg.Chat.Title, chatType); cerr != nil {
-				log.Printf("[telegram] network.ResolveChat warning: %v", cerr)
-			} else if cs := store.ChatSummary(network.MakeID(network.SourceTelegram, chatExtID)); cs != "" {
-				contactSummary = cs
-			}
-		}
-
-		// 2. Sender contact (existing behavior).
-		if msg.From.ID != 0 {
-			senderID := fmt.Sprintf("%d", msg.From.ID)
-			// Bug 2 fix: 完整 fallback 链 — firstName → username → externalID[:8]
-			displayName := network.FallbackDisplayName(senderID, msg.From.FirstName, msg.From.Username)
-			if c, nerr := store.Resolve(network.SourceTelegram, senderID, displayName); nerr != nil {
-				log.Printf("[telegram] network.Resolve warning: %v", nerr)
-			} else {
-				if senderSummary := store.Summary(c.ID); senderSummary != "" {
-					if contactSummary == "" {
-						contactSummary = senderSummary
-					} else {
-						contactSummary = contactSummary + "\n\n" + senderSummary
-					}
-				}
-				// E-01 (26.5.12v1): async fetch avatar if not yet cached.
-				if c.AvatarPath == "" {
-					b.fetchAndCacheTelegramAvatar(msg.From.ID, c.ID)
-				}
-			}
-		}
+	in := InboundMessage{
+		ChannelType: "telegram",
+		ChannelID:   b.channelID,
+		MessageID:   strconv.FormatInt(msg.MessageID, 10),
+		Chat:        telegramChatRef(msg.Chat, msg.MessageThreadID),
+		Text:        message,
+		Media:       media,
+		Voice:       msg.Voice != nil,
 	}
-
-	var events <-chan StreamEvent
-	var err error
-	if contactSummary != "" {
-		events, err = b.streamFunc(runCtx, b.agentID, message, sessionID, media, fileSender, contactSummary)
-	} else {
-		events, err = b.streamFunc(runCtx, b.agentID, message, sessionID, media, fileSender)
+	if msg.From.ID != 0 {
+		in.Sender = telegramSender(msg.From)
 	}
-	if err != nil {
-		stopTyping()
-		_, _ = b.sendPlain(chatID, "⚠️ 出错了："+err.Error(), replyToMsgID, threadID)
-		return
-	}
-
-	// Draft stream: accumulate text, send first chunk, then edit
-	var accumulated strings.Builder
-	var sentMsgID int64
-	lastSent := ""
-	throttle := time.NewTicker(1 * time.Second)
-	defer throttle.Stop()
-
-	sendOrEdit := func(text string, isFinal bool) {
-		if text == "" || text == lastSent {
-			return
-		}
-		lastSent = text
-		if sentMsgID == 0 {
-			// First send — try HTML first
-			id, err := b.sendHTML2(chatID, markdownToHTML(text), replyToMsgID, threadID)
-			if err != nil {
-				// Fallback to plain
-				id, err = b.sendPlain(chatID, text, replyToMsgID, threadID)
-				if err != nil {
-					log.Printf("[telegram] sendMessage error: %v", err)
-					return
-				}
-			}
-			sentMsgID = id
-			return
-		}
-		// Edit existing message — try HTML first
-		if err := b.editMessageHTML(chatID, sentMsgID, markdownToHTML(text), threadID); err != nil {
-			// Fallback to plain edit
-			if err2 := b.editMessage(chatID, sentMsgID, text, threadID); err2 != nil {
-				log.Printf("[telegram] editMessage warning: %v", err2)
-			}
-		}
-	}
-
-	for {
-		select {
-		case ev, ok := <-events:
-			if !ok {
-				goto done
-			}
-			switch ev.Type {
-			case "text_delta":
-				accumulated.WriteString(ev.Text)
-			case "error":
-				if ev.Err != nil {
-					accumulated.WriteString("\n⚠️ " + ev.Err.Error())
-				}
-			case "done":
-				goto done
-			}
-		case <-throttle.C:
-			sendOrEdit(accumulated.String(), false)
-		}
-	}
-
-done:
-	stopTyping()
-	finalText := accumulated.String()
-	sendOrEdit(finalText, true)
-
-	// Log assistant response to permanent conversation log
-	if finalText != "" {
-		tsAssist := time.Now().UTC().Format(time.RFC3339)
-		tgAssistChannelID := fmt.Sprintf("telegram-%d", chatID)
-		tgAssistSessionID := fmt.Sprintf("telegram-%d", chatID)
-		if cl := b.getConvLog(chatID); cl != nil {
-			_ = cl.Append(convlog.Entry{
-				Timestamp:   tsAssist,
-				Role:        "assistant",
-				Content:     finalText,
-				ChannelID:   tgAssistChannelID,
-				ChannelType: "telegram",
-			})
-		}
-		// Write to AI-visible chatlog
-		if b.agentDir != "" {
-			clMgr := chatlog.NewManager(b.agentDir + "/workspace")
-			_ = clMgr.Append(chatlog.Entry{
-				Ts:          tsAssist,
-				SessionID:   tgAssistSessionID,
-				ChannelID:   tgAssistChannelID,
-				ChannelType: "telegram",
-				Role:        "assistant",
-				Content:     finalText,
-			})
-		}
-	}
-
-	// If nothing was sent at all (edge case), send a fallback
-	if sentMsgID == 0 && accumulated.Len() == 0 {
-		_, _ = b.sendPlain(chatID, "(no response)", replyToMsgID, threadID)
+	if replyToMsgID > 0 {
+		in.ReplyTo = strconv.FormatInt(replyToMsgID, 10)
 	}
+	b.pipeline().Dispatch(ctx, in)
 }
 
-// keepTyping sends "typing" chat action every 4 seconds until ctx is cancelled.
-func (b *TelegramBot) keepTyping(ctx context.Context, chatID int64, threadID int64) {
-	_ = b.sendChatAction(chatID, "typing", threadID)
-	ticker := time.NewTicker(4 * time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			_ = b.sendChatAction(chatID, "typing", threadID)
-		}
-	}
+// telegramSender converts a Telegram user to a Pipeline Sender.
+func telegramSender(u TelegramUser) Sender {
+	return Sender{ID: strconv.FormatInt(u.ID, 10), Name: u.FirstName, Username: u.Username}
 }
 
 // isAddressedToBot returns true if the group message targets this bot.
@@ -12,11 +12,10 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/Zyling-ai/zyhive/pkg/chatlog"
-	"github.com/Zyling-ai/zyhive/pkg/convlog"
 	"github.com/Zyling-ai/zyhive/pkg/netguard"
 )
 
@@ -83,7 +82,7 @@ func (b *TelegramBot) sendReaction(chatID, messageID int64, emoji string) error
 // ── API helpers ───────────────────────────────────────────────────────────
 
 func (b *TelegramBot) apiPost(endpoint string, payload any) ([]byte, error) {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", b.token, endpoint)
+	url := fmt.Sprintf("%s/bot%s/%s", b.apiBase, b.token, endpoint)
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
@@ -108,12 +107,12 @@ func (b *TelegramBot) sendChatAction(chatID int64, action string, threadID int64
 	return err
 }
 
+// telegramMaxText is Telegram's message length limit (characters).
+const telegramMaxText = 4096
+
 // sendPlain sends a plain text message and returns the message ID.
 func (b *TelegramBot) sendPlain(chatID int64, text string, replyToMsgID int64, threadID int64) (int64, error) {
-	// Split if > 4096 chars
-	if len(text) > 4096 {
-		text = text[:4096]
-	}
+	text = clipRunes(text, telegramMaxText)
 	payload := map[string]any{
 		"chat_id": chatID,
 		"text":    text,
@@ -143,9 +142,7 @@ func (b *TelegramBot) sendPlain(chatID int64, text string, replyToMsgID int64, t
 
 // sendHTML sends a message with HTML parse mode and returns the message ID.
 func (b *TelegramBot) sendHTML2(chatID int64, html string, replyToMsgID int64, threadID int64) (int64, error) {
-	if len(html) > 4096 {
-		html = html[:4096]
-	}
+	html = clipRunes(html, telegramMaxText)
 	payload := map[string]any{
 		"chat_id":    chatID,
 		"text":       html,
@@ -185,9 +182,7 @@ func (b *TelegramBot) sendHTML(chatID int64, html string, replyToMsgID int64, th
 
 // editMessageHTML edits an existing message with HTML parse mode.
 func (b *TelegramBot) editMessageHTML(chatID, messageID int64, text string, threadID int64) error {
-	if len(text) > 4096 {
-		text = text[:4096]
-	}
+	text = clipRunes(text, telegramMaxText)
 	payload := map[string]any{
 		"chat_id":    chatID,
 		"message_id": messageID,
@@ -219,9 +214,7 @@ func (b *TelegramBot) editMessageHTML(chatID, messageID int64, text string, thre
 
 // editMessage edits an existing message (plain text fallback).
 func (b *TelegramBot) editMessage(chatID, messageID int64, text string, threadID int64) error {
-	if len(text) > 4096 {
-		text = text[:4096]
-	}
+	text = clipRunes(text, telegramMaxText)
 	payload := map[string]any{
 		"chat_id":    chatID,
 		"message_id": messageID,
@@ -258,103 +251,42 @@ func (b *TelegramBot) SendMessage(chatID int64, text string) error {
 // where the agent itself decides to push a notification via the send_message tool.
 // If no authorised users are configured, the message is silently dropped.
 func (b *TelegramBot) ProactiveSend(text string) error {
-	targets := b.getAllowFrom()
-	if len(targets) == 0 {
-		return nil // no authorised users configured; drop silently
-	}
 	var lastErr error
-	for _, chatID := range targets {
-		if _, err := b.sendHTML2(chatID, markdownToHTML(text), 0, 0); err != nil {
-			// Fallback to plain text if HTML fails
-			if _, err2 := b.sendPlain(chatID, text, 0, 0); err2 != nil {
-				lastErr = err2
-			}
+	for _, to := range b.ProactiveRecipients() {
+		if err := b.ProactiveSendTo(context.Background(), to, text); err != nil {
+			lastErr = err
 		}
 	}
 	return lastErr
 }
 
-// Notify runs the agent with the given prompt in the per-chat session, then sends
-// the response to the Telegram chat. Both the prompt (as user turn) and the response
-// (as assistant turn) are recorded in the session for conversation continuity.
-// chatID: Telegram chat ID to send to.
-// threadID: message thread ID (0 for non-thread chats).
-// prompt: the message injected as the "user" turn (e.g. a cron trigger or system event).
-func (b *TelegramBot) Notify(ctx context.Context, chatID, threadID int64, prompt string) error {
-	if b.streamFunc == nil {
-		return fmt.Errorf("notify: no stream func registered")
-	}
-	sessionID := fmt.Sprintf("telegram-%d", chatID)
-	fileSender := FileSenderFunc(func(filePath string) (string, error) {
-		return b.SendFileToChat(chatID, threadID, filePath)
-	})
-
-	runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
-
-	events, err := b.streamFunc(runCtx, b.agentID, prompt, sessionID, nil, fileSender)
-	if err != nil {
-		return fmt.Errorf("notify: runner error: %w", err)
-	}
-
-	var accumulated strings.Builder
-	for ev := range events {
-		switch ev.Type {
-		case "text_delta":
-			accumulated.WriteString(ev.Text)
-		case "error":
-			if ev.Err != nil {
-				return fmt.Errorf("notify: runner error: %w", ev.Err)
-			}
-		}
-	}
-
-	finalText := accumulated.String()
-	if finalText == "" {
-		return nil
+// ProactiveRecipients implements RecipientSender: the authorised chat ids.
+func (b *TelegramBot) ProactiveRecipients() []string {
+	var out []string
+	for _, id := range b.getAllowFrom() {
+		out = append(out, strconv.FormatInt(id, 10))
 	}
+	return out
+}
 
-	// Send to Telegram (try HTML first, fall back to plain)
-	_, err = b.sendHTML2(chatID, markdownToHTML(finalText), 0, threadID)
+// ProactiveSendTo implements RecipientSender.
+func (b *TelegramBot) ProactiveSendTo(_ context.Context, recipient, text string) error {
+	chatID, err := strconv.ParseInt(recipient, 10, 64)
 	if err != nil {
-		_, err = b.sendPlain(chatID, finalText, 0, threadID)
-		if err != nil {
-			return fmt.Errorf("notify: send error: %w", err)
-		}
+		return fmt.Errorf("telegram: invalid chat id %q", recipient)
 	}
-
-	// Log to permanent conversation audit log
-	tsChatlogNotify := time.Now().UTC().Format(time.RFC3339)
-	chatChannelID := fmt.Sprintf("telegram-%d", chatID)
-	if cl := b.getConvLog(chatID); cl != nil {
-		_ = cl.Append(convlog.Entry{
-			Timestamp:   tsChatlogNotify,
-			Role:        "assistant",
-			Content:     finalText,
-			ChannelID:   chatChannelID,
-			ChannelType: "telegram",
-		})
-	}
-	// Also write to AI-visible chatlog
-	if b.agentDir != "" {
-		clMgr := chatlog.NewManager(b.agentDir + "/workspace")
-		sessionID := fmt.Sprintf("telegram-%d", chatID)
-		_ = clMgr.Append(chatlog.Entry{
-			Ts:          tsChatlogNotify,
-			SessionID:   sessionID,
-			ChannelID:   chatChannelID,
-			ChannelType: "telegram",
-			Role:        "assistant",
-			Content:     finalText,
-		})
+	if _, err := b.sendHTML2(chatID, markdownToHTML(text), 0, 0); err != nil {
+		// Fallback to plain text if HTML fails
+		if _, err2 := b.sendPlain(chatID, text, 0, 0); err2 != nil {
+			return err2
+		}
 	}
-
 	return nil
 }
 
 // TestTelegramBot calls getMe to verify a bot token. Returns the bot username on success.
 func TestTelegramBot(ctx context.Context, token string) (string, error) {
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/getMe", token)
+	url := fmt.Sprintf("%s/bot%s/getMe", telegramAPIBase, token)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
@@ -465,7 +397,7 @@ func (b *TelegramBot) SendFileToChat(chatID, threadID int64, filePath string) (s
 	}
 	mw.Close()
 
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/%s", b.token, sc.method)
+	url := fmt.Sprintf("%s/bot%s/%s", b.apiBase, b.token, sc.method)
 	resp, err := b.client.Post(url, mw.FormDataContentType(), &body)
 	if err != nil {
 		return "", fmt.Errorf("telegram upload: %w", err)
@@ -486,4 +418,46 @@ func (b *TelegramBot) SendFileToChat(chatID, threadID int64, filePath string) (s
 	return fmt.Sprintf("✅ 已发送 %s (%.1f KB)", baseName, float64(len(data))/1024), nil
 }
 
+// sendVoice posts Ogg/Opus audio as a voice message (sendVoice).
+func (b *TelegramBot) sendVoice(ctx context.Context, chatID, threadID int64, audio []byte) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	_ = mw.WriteField("chat_id", fmt.Sprintf("%d", chatID))
+	if threadID > 0 {
+		_ = mw.WriteField("message_thread_id", fmt.Sprintf("%d", threadID))
+	}
+	fw, err := mw.CreateFormFile("voice", "reply.ogg")
+	if err != nil {
+		return fmt.Errorf("create form file: %w", err)
+	}
+	if _, err = fw.Write(audio); err != nil {
+		return fmt.Errorf("write voice data: %w", err)
+	}
+	mw.Close()
+
+	url := fmt.Sprintf("%s/bot%s/sendVoice", b.apiBase, b.token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram sendVoice: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("parse response: %w", err)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram sendVoice failed: %s", result.Description)
+	}
+	return nil
+}
+
 // Ensure RunnerFunc is exported so other packages can reference it cleanly.
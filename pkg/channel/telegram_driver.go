@@ -0,0 +1,183 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// TelegramBot implements Driver, Notifier and VoiceSender.
+var (
+	_ Driver      = (*TelegramBot)(nil)
+	_ Notifier    = (*TelegramBot)(nil)
+	_ VoiceSender = (*TelegramBot)(nil)
+)
+
+func init() {
+	RegisterDriver(DriverSpec{
+		Type:       "telegram",
+		Required:   []string{"botToken"},
+		UniqueKey:  "botToken",
+		NumericIDs: true,
+		New:        newTelegramDriver,
+		Test: func(ctx context.Context, cfg map[string]string) (string, error) {
+			return TestTelegramBot(ctx, cfg["botToken"])
+		},
+	})
+}
+
+func newTelegramDriver(env DriverEnv) (Driver, error) {
+	getAllowFrom := func() []int64 {
+		if env.AllowFrom == nil {
+			return nil
+		}
+		var ids []int64
+		for _, s := range env.AllowFrom() {
+			if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	var pending *PendingStore
+	if dir := env.PendingDir(); dir != "" {
+		pending = NewPendingStore(dir, env.ChannelID)
+	}
+	bot := NewTelegramBotWithStream(env.Config["botToken"], env.AgentID, env.AgentDir, env.ChannelID, getAllowFrom, env.Stream, pending)
+	bot.SetOnConnected(env.OnConnected)
+	bot.SetWebhookURL(telegramWebhookURL(env))
+	bot.SetVoice(env.Voice, env.Config["voiceReply"])
+	return bot, nil
+}
+
+// pipeline returns the shared message path bound to this bot.
+func (b *TelegramBot) pipeline() *Pipeline {
+	return &Pipeline{
+		Env: DriverEnv{
+			AgentID:   b.agentID,
+			AgentDir:  b.agentDir,
+			ChannelID: b.channelID,
+			Stream:    b.streamFunc,
+			Voice:     b.voice,
+			AllowFrom: func() []string {
+				ids := b.getAllowFrom()
+				out := make([]string, len(ids))
+				for i, id := range ids {
+					out[i] = strconv.FormatInt(id, 10)
+				}
+				return out
+			},
+		},
+		Driver:  b,
+		Pending: b.pendingStore.Recorder(),
+		OnNewContact: func(senderID, contactID string) {
+			if id, err := strconv.ParseInt(senderID, 10, 64); err == nil {
+				b.fetchAndCacheTelegramAvatar(id, contactID)
+			}
+		},
+	}
+}
+
+// telegramChatRef converts a Telegram chat (+ forum thread) to a ChatRef.
+func telegramChatRef(chat TelegramChat, threadID int64) ChatRef {
+	ref := ChatRef{ID: strconv.FormatInt(chat.ID, 10), Type: chat.Type, Title: chat.Title}
+	if threadID > 0 {
+		ref.ThreadID = strconv.FormatInt(threadID, 10)
+	}
+	return ref
+}
+
+// telegramIDs parses a ChatRef back into Telegram's numeric chat / thread ids.
+func telegramIDs(chat ChatRef) (chatID, threadID int64, err error) {
+	chatID, err = strconv.ParseInt(chat.ID, 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("telegram: invalid chat id %q", chat.ID)
+	}
+	if chat.ThreadID != "" {
+		threadID, _ = strconv.ParseInt(chat.ThreadID, 10, 64)
+	}
+	return chatID, threadID, nil
+}
+
+// parseMsgID parses an optional Telegram message id ("" = 0).
+func parseMsgID(s string) int64 {
+	id, _ := strconv.ParseInt(s, 10, 64)
+	return id
+}
+
+// Type implements Driver.
+func (b *TelegramBot) Type() string { return "telegram" }
+
+// Capabilities implements Driver.
+func (b *TelegramBot) Capabilities() Capabilities {
+	// MaxText leaves headroom below telegramMaxText for HTML entities.
+	return Capabilities{Edit: true, Typing: true, Files: true, Threads: true, MaxText: 4000}
+}
+
+// Send implements Driver: HTML first, plain text fallback.
+func (b *TelegramBot) Send(_ context.Context, chat ChatRef, text, replyTo string) (string, error) {
+	chatID, threadID, err := telegramIDs(chat)
+	if err != nil {
+		return "", err
+	}
+	replyToMsgID := parseMsgID(replyTo)
+	id, err := b.sendHTML2(chatID, markdownToHTML(text), replyToMsgID, threadID)
+	if err != nil {
+		id, err = b.sendPlain(chatID, text, replyToMsgID, threadID)
+		if err != nil {
+			return "", err
+		}
+	}
+	return strconv.FormatInt(id, 10), nil
+}
+
+// Edit implements Driver: HTML first, plain text fallback.
+func (b *TelegramBot) Edit(_ context.Context, chat ChatRef, msgID, text string) error {
+	chatID, threadID, err := telegramIDs(chat)
+	if err != nil {
+		return err
+	}
+	id := parseMsgID(msgID)
+	if err := b.editMessageHTML(chatID, id, markdownToHTML(text), threadID); err != nil {
+		return b.editMessage(chatID, id, text, threadID)
+	}
+	return nil
+}
+
+// Typing implements Driver.
+func (b *TelegramBot) Typing(_ context.Context, chat ChatRef) error {
+	chatID, threadID, err := telegramIDs(chat)
+	if err != nil {
+		return err
+	}
+	return b.sendChatAction(chatID, "typing", threadID)
+}
+
+// SendFile implements Driver.
+func (b *TelegramBot) SendFile(_ context.Context, chat ChatRef, path string) (string, error) {
+	chatID, threadID, err := telegramIDs(chat)
+	if err != nil {
+		return "", err
+	}
+	return b.SendFileToChat(chatID, threadID, path)
+}
+
+// SendVoice implements VoiceSender (sendVoice takes Ogg/Opus).
+func (b *TelegramBot) SendVoice(ctx context.Context, chat ChatRef, audio []byte, _ string) error {
+	chatID, threadID, err := telegramIDs(chat)
+	if err != nil {
+		return err
+	}
+	return b.sendVoice(ctx, chatID, threadID, audio)
+}
+
+// Notify runs the agent with the given prompt in the per-chat session, then sends
+// the response to the Telegram chat. Both the prompt (as user turn) and the response
+// (as assistant turn) are recorded in the session for conversation continuity.
+// chat.ThreadID is the forum thread ("" for non-thread chats).
+func (b *TelegramBot) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	if _, _, err := telegramIDs(chat); err != nil {
+		return err
+	}
+	return b.pipeline().Notify(ctx, chat, prompt)
+}
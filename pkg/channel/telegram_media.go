@@ -13,8 +13,8 @@ import (
 // ── Media resolution ──────────────────────────────────────────────────────
 
 // resolveMedia downloads relevant media from a message, returning:
-//   - media: list of MediaInput (images/PDFs to pass to LLM)
-//   - extraText: placeholder text for non-downloadable media
+//   - media: list of MediaInput (images / scanned PDFs to pass to LLM)
+//   - extraText: placeholders, transcripts and extracted document text
 func (b *TelegramBot) resolveMedia(ctx context.Context, msg *TelegramMessage) ([]MediaInput, string, error) {
 	var media []MediaInput
 	var extras []string
@@ -53,14 +53,12 @@ func (b *TelegramBot) resolveMedia(ctx context.Context, msg *TelegramMessage) ([
 		extras = append(extras, "[📹 视频消息]")
 	}
 
-	// Audio
+	// Audio / Voice: filed and transcribed (Pipeline.Transcribe)
 	if msg.Audio != nil {
-		extras = append(extras, "[🎵 音频消息]")
+		extras = append(extras, b.transcribeFile(ctx, msg, msg.Audio, "audio.mp3", "[🎵 音频消息]"))
 	}
-
-	// Voice
 	if msg.Voice != nil {
-		extras = append(extras, "[🎤 语音消息]")
+		extras = append(extras, b.transcribeFile(ctx, msg, msg.Voice, "voice.ogg", "[🎤 语音消息]"))
 	}
 
 	// VideoNote
@@ -68,22 +66,30 @@ func (b *TelegramBot) resolveMedia(ctx context.Context, msg *TelegramMessage) ([
 		extras = append(extras, "[🎥 视频笔记]")
 	}
 
-	// Document
+	// Document: PDF / Office / HTML files are filed and their text
+	// extracted (Pipeline.Document); scanned PDFs still go to the model.
 	if msg.Document != nil {
 		doc := msg.Document
-		if doc.MimeType == "application/pdf" {
+		name := doc.FileName
+		if name == "" {
+			name = "文件"
+		}
+		if IsDocument(doc.FileName, doc.MimeType) {
 			data, ct, err := b.downloadFileByID(ctx, doc.FileID)
 			if err != nil {
-				log.Printf("[telegram] pdf download error: %v", err)
-				extras = append(extras, "[📎 文件: "+doc.FileName+"]")
+				log.Printf("[telegram] document download error: %v", err)
+				extras = append(extras, "[📎 文件: "+name+"]")
 			} else {
-				media = append(media, MediaInput{Data: data, ContentType: ct, FileName: doc.FileName})
+				if doc.MimeType != "" {
+					ct = doc.MimeType // downloadTelegramFile guesses image types only
+				}
+				text, keep := b.pipeline().Document(ctx, telegramChatRef(msg.Chat, msg.MessageThreadID), MediaInput{Data: data, ContentType: ct, FileName: doc.FileName})
+				if keep {
+					media = append(media, MediaInput{Data: data, ContentType: ct, FileName: doc.FileName})
+				}
+				extras = append(extras, text)
 			}
 		} else {
-			name := doc.FileName
-			if name == "" {
-				name = "文件"
-			}
 			extras = append(extras, "[📎 文件: "+name+"]")
 		}
 	}
@@ -122,6 +128,24 @@ func (b *TelegramBot) resolveMedia(ctx context.Context, msg *TelegramMessage) ([
 	return media, extraText, nil
 }
 
+// transcribeFile downloads a voice note / audio file and returns its
+// transcript line; placeholder is used when the download fails.
+func (b *TelegramBot) transcribeFile(ctx context.Context, msg *TelegramMessage, f *TelegramFile, defName, placeholder string) string {
+	data, ct, err := b.downloadFileByID(ctx, f.FileID)
+	if err != nil {
+		log.Printf("[telegram] audio download error: %v", err)
+		return placeholder
+	}
+	if f.MimeType != "" {
+		ct = f.MimeType // downloadTelegramFile guesses image types only
+	}
+	name := f.FileName
+	if name == "" {
+		name = defName
+	}
+	return b.pipeline().Transcribe(ctx, telegramChatRef(msg.Chat, msg.MessageThreadID), MediaInput{Data: data, ContentType: ct, FileName: name})
+}
+
 // downloadFileByID uses getFile to get the file path, then downloads it.
 func (b *TelegramBot) downloadFileByID(ctx context.Context, fileID string) ([]byte, string, error) {
 	filePath, err := b.getFilePath(ctx, fileID)
@@ -162,7 +186,7 @@ func (b *TelegramBot) getFilePath(ctx context.Context, fileID string) (string, e
 // downloadTelegramFile fetches a file from Telegram servers.
 // Returns: raw bytes, content-type, error.
 func (b *TelegramBot) downloadTelegramFile(ctx context.Context, filePath string) ([]byte, string, error) {
-	url := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", b.token, filePath)
+	url := fmt.Sprintf("%s/file/bot%s/%s", b.apiBase, b.token, filePath)
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, "", err
@@ -0,0 +1,347 @@
+// pkg/channel/telegram_webhook.go — Telegram webhook mode + diagnostics.
+//
+// With gateway.publicUrl set (https), a Telegram bot registers
+// setWebhook → {publicUrl}/channels/{agentId}/{channelId}/webhook instead
+// of holding a getUpdates long poll. Telegram signs every delivery with
+// X-Telegram-Bot-Api-Secret-Token; the secret is derived from the bot
+// token so restarts re-register the same value. If setWebhook fails the
+// bot falls back to polling, and polling removes a webhook it left behind
+// earlier (getUpdates is refused while any webhook is set).
+package channel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+)
+
+// telegramAllowedUpdates is what both getUpdates and setWebhook subscribe to.
+var telegramAllowedUpdates = []string{"message", "callback_query", "channel_post"}
+
+// TelegramBot implements WebhookHandler.
+var _ WebhookHandler = (*TelegramBot)(nil)
+
+// telegramWebhookPath is the panel route Telegram posts updates to.
+func telegramWebhookPath(agentID, channelID string) string {
+	return "/channels/" + url.PathEscape(agentID) + "/" + url.PathEscape(channelID) + "/webhook"
+}
+
+// telegramWebhookURL returns the webhook URL for a channel, or "" to poll:
+// Telegram only delivers to https, and config mode=polling opts out.
+func telegramWebhookURL(env DriverEnv) string {
+	if env.Config["mode"] == "polling" {
+		return ""
+	}
+	base := strings.TrimRight(env.PublicURL, "/")
+	if !strings.HasPrefix(base, "https://") {
+		if base != "" {
+			log.Printf("[telegram] publicUrl %q is not https; agent=%s channel=%s keeps polling", base, env.AgentID, env.ChannelID)
+		}
+		return ""
+	}
+	return base + telegramWebhookPath(env.AgentID, env.ChannelID)
+}
+
+// telegramWebhookSecret derives the secret_token for a bot + URL
+// (hex, within Telegram's [A-Za-z0-9_-]{1,256}).
+func telegramWebhookSecret(token, hookURL string) string {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte("zyhive-telegram-webhook\n" + hookURL))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SetWebhookURL switches the bot to webhook mode (before Start).
+func (b *TelegramBot) SetWebhookURL(hookURL string) {
+	b.webhookURL = hookURL
+	b.webhookSecret = ""
+	if hookURL != "" {
+		b.webhookSecret = telegramWebhookSecret(b.token, hookURL)
+	}
+}
+
+// Mode returns how the bot receives updates: "webhook", "polling", or ""
+// before Start.
+func (b *TelegramBot) Mode() string {
+	b.hookMu.Lock()
+	defer b.hookMu.Unlock()
+	return b.mode
+}
+
+func (b *TelegramBot) setMode(mode string) {
+	b.hookMu.Lock()
+	b.mode = mode
+	b.hookMu.Unlock()
+}
+
+// startWebhook registers the webhook and arms ServeWebhook. The webhook is
+// kept when ctx ends: a restarted bot re-registers it (deleting it here
+// would race with that) and Telegram queues updates meanwhile.
+func (b *TelegramBot) startWebhook(ctx context.Context) error {
+	body, err := b.apiPost("setWebhook", map[string]any{
+		"url":             b.webhookURL,
+		"secret_token":    b.webhookSecret,
+		"allowed_updates": telegramAllowedUpdates,
+	})
+	if err != nil {
+		return err
+	}
+	if err := telegramResult(body, "setWebhook"); err != nil {
+		return err
+	}
+	b.hookMu.Lock()
+	b.mode = "webhook"
+	b.runCtx = ctx
+	b.seenUpdates = make(map[int64]time.Time)
+	b.hookMu.Unlock()
+	log.Printf("[telegram] Webhook registered: %s (agent=%s)", b.webhookURL, b.agentID)
+	return nil
+}
+
+// releaseOwnWebhook deletes a webhook that points at this channel (left
+// by an earlier webhook-mode run) so getUpdates works. A webhook set by
+// another deployment is left alone and logged.
+func (b *TelegramBot) releaseOwnWebhook(ctx context.Context) {
+	info, err := getTelegramWebhookInfo(ctx, b.client, b.apiBase, b.token)
+	if err != nil || info.URL == "" {
+		return
+	}
+	if !strings.HasSuffix(info.URL, telegramWebhookPath(b.agentID, b.channelID)) {
+		log.Printf("[telegram] Bot has a webhook set elsewhere (%s); getUpdates fails until it is removed (agent=%s)", info.URL, b.agentID)
+		return
+	}
+	body, err := b.apiPost("deleteWebhook", map[string]any{"drop_pending_updates": false})
+	if err == nil {
+		err = telegramResult(body, "deleteWebhook")
+	}
+	if err != nil {
+		log.Printf("[telegram] deleteWebhook: %v", err)
+		return
+	}
+	log.Printf("[telegram] Removed own webhook %s to resume polling (agent=%s)", info.URL, b.agentID)
+}
+
+// ServeWebhook implements WebhookHandler: verifies the secret token and
+// handles the update asynchronously (Telegram only needs the 200).
+func (b *TelegramBot) ServeWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if b.webhookSecret == "" {
+		http.Error(w, "telegram webhook mode is not enabled", http.StatusNotFound)
+		return
+	}
+	got := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(b.webhookSecret)) != 1 {
+		http.Error(w, "invalid secret token", http.StatusUnauthorized)
+		return
+	}
+	var update TelegramUpdate
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&update); err != nil {
+		http.Error(w, "invalid update", http.StatusBadRequest)
+		return
+	}
+
+	b.hookMu.Lock()
+	ctx := b.runCtx
+	dup := ctx != nil && b.markUpdateLocked(update.UpdateID)
+	b.hookMu.Unlock()
+	if ctx == nil {
+		// Stopped or polling: let Telegram keep the update for redelivery.
+		http.Error(w, "telegram bot is not receiving via webhook", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if !dup {
+		go b.handleUpdate(ctx, update)
+	}
+}
+
+// markUpdateLocked reports whether update id was already handled,
+// recording it if not. Caller holds hookMu.
+func (b *TelegramBot) markUpdateLocked(id int64) bool {
+	if b.seenUpdates == nil {
+		b.seenUpdates = make(map[int64]time.Time)
+	}
+	if _, dup := b.seenUpdates[id]; dup {
+		return true
+	}
+	b.seenUpdates[id] = time.Now()
+	if len(b.seenUpdates) > 2000 {
+		cutoff := time.Now().Add(-time.Hour)
+		for k, t := range b.seenUpdates {
+			if t.Before(cutoff) {
+				delete(b.seenUpdates, k)
+			}
+		}
+	}
+	return false
+}
+
+// telegramResult checks a Bot API {"ok":…,"description":…} reply.
+func telegramResult(body []byte, method string) error {
+	var r struct {
+		OK   bool   `json:"ok"`
+		Desc string `json:"description"`
+	}
+	if err := json.Unmarshal(body, &r); err != nil {
+		return fmt.Errorf("%s: parse: %w", method, err)
+	}
+	if !r.OK {
+		return fmt.Errorf("%s: %s", method, r.Desc)
+	}
+	return nil
+}
+
+// ── Diagnostics ───────────────────────────────────────────────────────────
+
+// TelegramWebhookInfo mirrors the Bot API getWebhookInfo result.
+type TelegramWebhookInfo struct {
+	URL                          string   `json:"url"`
+	HasCustomCertificate         bool     `json:"has_custom_certificate"`
+	PendingUpdateCount           int      `json:"pending_update_count"`
+	IPAddress                    string   `json:"ip_address,omitempty"`
+	LastErrorDate                int64    `json:"last_error_date,omitempty"`
+	LastErrorMessage             string   `json:"last_error_message,omitempty"`
+	LastSynchronizationErrorDate int64    `json:"last_synchronization_error_date,omitempty"`
+	MaxConnections               int      `json:"max_connections,omitempty"`
+	AllowedUpdates               []string `json:"allowed_updates,omitempty"`
+}
+
+func getTelegramWebhookInfo(ctx context.Context, client *http.Client, base, token string) (*TelegramWebhookInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/bot%s/getWebhookInfo", base, token), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var result struct {
+		OK     bool                `json:"ok"`
+		Desc   string              `json:"description"`
+		Result TelegramWebhookInfo `json:"result"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("getWebhookInfo: parse: %w", err)
+	}
+	if !result.OK {
+		return nil, fmt.Errorf("getWebhookInfo: %s", result.Desc)
+	}
+	return &result.Result, nil
+}
+
+// TelegramProbeResult is the JSON shape of the per-channel Telegram status
+// panel: bot identity, receive mode and webhook health.
+type TelegramProbeResult struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"` // "" | "auth_failed" | "network" | "unknown"
+
+	Bot struct {
+		ID       int64  `json:"id,omitempty"`
+		Username string `json:"username,omitempty"`
+	} `json:"bot"`
+
+	// Mode is how the running bot receives updates ("" = not running).
+	Mode        string               `json:"mode"`
+	ExpectedURL string               `json:"expectedUrl,omitempty"`
+	Webhook     *TelegramWebhookInfo `json:"webhook,omitempty"`
+
+	Healthy bool     `json:"healthy"`
+	Issues  []string `json:"issues,omitempty"`
+}
+
+// ProbeTelegram checks a bot token and its webhook state. mode and
+// expectedURL describe the local bot ("" when it is not running).
+func ProbeTelegram(ctx context.Context, token, mode, expectedURL string) *TelegramProbeResult {
+	return probeTelegram(ctx, netguard.NewSafeClient(8*time.Second), telegramAPIBase, token, mode, expectedURL)
+}
+
+// Diagnose probes the running bot's token and webhook health.
+func (b *TelegramBot) Diagnose(ctx context.Context) *TelegramProbeResult {
+	return probeTelegram(ctx, b.client, b.apiBase, b.token, b.Mode(), b.webhookURL)
+}
+
+func probeTelegram(ctx context.Context, client *http.Client, base, token, mode, expectedURL string) *TelegramProbeResult {
+	out := &TelegramProbeResult{Mode: mode, ExpectedURL: expectedURL}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/bot%s/getMe", base, token), nil)
+	if err != nil {
+		out.Error = "unknown"
+		return out
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		out.Error = "network"
+		out.Issues = append(out.Issues, "无法连接 Telegram API："+err.Error())
+		return out
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	var me struct {
+		OK     bool         `json:"ok"`
+		Desc   string       `json:"description"`
+		Result TelegramUser `json:"result"`
+	}
+	if json.Unmarshal(body, &me) != nil || !me.OK {
+		out.Error = "auth_failed"
+		out.Issues = append(out.Issues, "Bot Token 无效："+me.Desc)
+		return out
+	}
+	out.Bot.ID, out.Bot.Username = me.Result.ID, me.Result.Username
+
+	info, err := getTelegramWebhookInfo(ctx, client, base, token)
+	if err != nil {
+		out.Error = "network"
+		out.Issues = append(out.Issues, err.Error())
+		return out
+	}
+	out.OK = true
+	out.Webhook = info
+	out.Issues = telegramWebhookIssues(mode, expectedURL, info, time.Now())
+	out.Healthy = len(out.Issues) == 0
+	return out
+}
+
+// telegramWebhookIssues lists problems with the webhook state for a bot
+// running in mode, in the panel's language.
+func telegramWebhookIssues(mode, expectedURL string, info *TelegramWebhookInfo, now time.Time) []string {
+	var issues []string
+	switch {
+	case mode == "webhook" && info.URL != expectedURL:
+		issues = append(issues, fmt.Sprintf("Telegram 记录的 Webhook 地址（%s）与本渠道（%s）不一致，可能被其他部署覆盖", orNone(info.URL), expectedURL))
+	case mode == "polling" && info.URL != "":
+		issues = append(issues, fmt.Sprintf("Bot 在别处设置了 Webhook（%s），长轮询无法收到消息", info.URL))
+	case mode == "" && expectedURL != "" && info.URL != expectedURL:
+		issues = append(issues, "渠道未运行，Webhook 尚未注册")
+	}
+	if info.URL != "" && info.LastErrorDate > 0 && now.Sub(time.Unix(info.LastErrorDate, 0)) < 24*time.Hour {
+		issues = append(issues, fmt.Sprintf("最近一次推送失败（%s）：%s",
+			time.Unix(info.LastErrorDate, 0).Format("01-02 15:04"), info.LastErrorMessage))
+	}
+	if info.PendingUpdateCount > 100 {
+		issues = append(issues, fmt.Sprintf("有 %d 条更新积压未送达", info.PendingUpdateCount))
+	}
+	return issues
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "无"
+	}
+	return s
+}
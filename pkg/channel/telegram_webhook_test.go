@@ -0,0 +1,237 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testTelegramToken = "123:ABC"
+
+// fakeTelegram is a minimal api.telegram.org stand-in.
+type fakeTelegram struct {
+	t   *testing.T
+	srv *httptest.Server
+	mu  sync.Mutex
+	// calls records requests by Bot API method with decoded JSON bodies.
+	calls []discordCall
+	// hookURL is the webhook Telegram has on record.
+	hookURL        string
+	failSetWebhook bool
+}
+
+func newFakeTelegram(t *testing.T) *fakeTelegram {
+	f := &fakeTelegram{t: t}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeTelegram) serve(w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/bot"+testTelegramToken+"/")
+	body := map[string]any{}
+	raw, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(raw, &body)
+	f.mu.Lock()
+	f.calls = append(f.calls, discordCall{Route: method, Body: body})
+	reply := `{"ok":true,"result":true}`
+	switch method {
+	case "getMe":
+		reply = `{"ok":true,"result":{"id":42,"username":"zybot","is_bot":true}}`
+	case "setWebhook":
+		if f.failSetWebhook {
+			reply = `{"ok":false,"description":"Bad Request: bad webhook: Failed to resolve host"}`
+		} else {
+			f.hookURL, _ = body["url"].(string)
+		}
+	case "deleteWebhook":
+		f.hookURL = ""
+	case "getWebhookInfo":
+		info, _ := json.Marshal(TelegramWebhookInfo{URL: f.hookURL, PendingUpdateCount: 0})
+		reply = `{"ok":true,"result":` + string(info) + `}`
+	case "sendMessage":
+		reply = `{"ok":true,"result":{"message_id":7}}`
+	}
+	f.mu.Unlock()
+
+	if method == "getUpdates" {
+		select { // long poll
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+		}
+		reply = `{"ok":true,"result":[]}`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = io.WriteString(w, reply)
+}
+
+func (f *fakeTelegram) routes(method string) []discordCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []discordCall
+	for _, c := range f.calls {
+		if c.Route == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// wait returns the calls to method once there are at least n.
+func (f *fakeTelegram) wait(method string, n int) []discordCall {
+	f.t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if got := f.routes(method); len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			f.t.Fatalf("fewer than %d %s calls", n, method)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startTestTelegramBot starts a bot for agent a1 / channel tg-1 that
+// allows user 1001, answering every turn with "pong".
+func startTestTelegramBot(t *testing.T, f *fakeTelegram, publicURL string, runs chan<- testRun) *TelegramBot {
+	t.Helper()
+	stream := func(_ context.Context, _, text, sessionID string, media []MediaInput, _ FileSenderFunc, _ ...string) (<-chan StreamEvent, error) {
+		runs <- testRun{session: sessionID, text: text, media: media}
+		return streamOf(StreamEvent{Type: "text_delta", Text: "pong"}, StreamEvent{Type: "done"}), nil
+	}
+	// agentDir "" keeps the pipeline off the filesystem.
+	b := NewTelegramBotWithStream(testTelegramToken, "a1", "", "tg-1", func() []int64 { return []int64{1001} }, stream, nil)
+	b.apiBase = f.srv.URL
+	b.client = f.srv.Client()
+	b.SetWebhookURL(telegramWebhookURL(DriverEnv{AgentID: "a1", ChannelID: "tg-1", PublicURL: publicURL}))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go b.Start(ctx)
+	return b
+}
+
+func waitMode(t *testing.T, b *TelegramBot, mode string) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for b.Mode() != mode {
+		if time.Now().After(deadline) {
+			t.Fatalf("mode = %q, want %q", b.Mode(), mode)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTelegramWebhookURL(t *testing.T) {
+	env := DriverEnv{AgentID: "a1", ChannelID: "tg-1", Config: map[string]string{}}
+	if got := telegramWebhookURL(env); got != "" {
+		t.Errorf("no publicUrl: %q", got)
+	}
+	env.PublicURL = "http://panel.example.com"
+	if got := telegramWebhookURL(env); got != "" {
+		t.Errorf("plain http: %q", got)
+	}
+	env.PublicURL = "https://panel.example.com/"
+	if got := telegramWebhookURL(env); got != "https://panel.example.com/channels/a1/tg-1/webhook" {
+		t.Errorf("https: %q", got)
+	}
+	env.Config["mode"] = "polling"
+	if got := telegramWebhookURL(env); got != "" {
+		t.Errorf("mode=polling: %q", got)
+	}
+}
+
+func TestTelegramWebhookMode(t *testing.T) {
+	f := newFakeTelegram(t)
+	runs := make(chan testRun, 4)
+	b := startTestTelegramBot(t, f, "https://panel.example.com", runs)
+
+	set := f.wait("setWebhook", 1)[0].Body
+	if set["url"] != "https://panel.example.com/channels/a1/tg-1/webhook" || set["secret_token"] != b.webhookSecret || b.webhookSecret == "" {
+		t.Errorf("setWebhook = %+v", set)
+	}
+	waitMode(t, b, "webhook")
+
+	update := `{"update_id":900,"message":{"message_id":5,"from":{"id":1001,"first_name":"Ann"},"chat":{"id":1001,"type":"private"},"text":"ping","date":1}}`
+	post := func(secret string) int {
+		r := httptest.NewRequest(http.MethodPost, "/channels/a1/tg-1/webhook", strings.NewReader(update))
+		r.Header.Set("X-Telegram-Bot-Api-Secret-Token", secret)
+		w := httptest.NewRecorder()
+		b.ServeWebhook(w, r)
+		return w.Code
+	}
+	if code := post("wrong"); code != http.StatusUnauthorized {
+		t.Errorf("bad secret = %d", code)
+	}
+	if code := post(b.webhookSecret); code != http.StatusOK {
+		t.Fatalf("update = %d", code)
+	}
+	select {
+	case run := <-runs:
+		if run.session != "telegram-1001" || run.text != "ping" {
+			t.Errorf("run = %+v", run)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("update was not dispatched")
+	}
+	if got := f.wait("sendMessage", 1)[0].Body; got["text"] != "pong" {
+		t.Errorf("reply = %+v", got)
+	}
+
+	// Telegram redelivers when the ack is slow: one run per update_id.
+	if code := post(b.webhookSecret); code != http.StatusOK {
+		t.Errorf("redelivery = %d", code)
+	}
+	select {
+	case extra := <-runs:
+		t.Errorf("duplicate run %+v", extra)
+	case <-time.After(500 * time.Millisecond): // > debounce window
+	}
+	if len(f.routes("getUpdates")) != 0 {
+		t.Error("webhook mode must not poll")
+	}
+
+	diag := b.Diagnose(context.Background())
+	if !diag.OK || !diag.Healthy || diag.Mode != "webhook" || diag.Bot.Username != "zybot" || diag.Webhook.URL != b.webhookURL {
+		t.Errorf("diagnose = %+v", diag)
+	}
+}
+
+func TestTelegramWebhookFallsBackToPolling(t *testing.T) {
+	f := newFakeTelegram(t)
+	f.failSetWebhook = true
+	// An earlier webhook-mode run left this channel's webhook registered.
+	f.hookURL = "https://old.example.com/channels/a1/tg-1/webhook"
+	b := startTestTelegramBot(t, f, "https://panel.example.com", make(chan testRun, 1))
+
+	waitMode(t, b, "polling")
+	f.wait("deleteWebhook", 1)
+	f.wait("getUpdates", 1)
+
+	// A delivery racing the switch is refused, so Telegram keeps the update.
+	r := httptest.NewRequest(http.MethodPost, "/channels/a1/tg-1/webhook", strings.NewReader(`{"update_id":1}`))
+	r.Header.Set("X-Telegram-Bot-Api-Secret-Token", b.webhookSecret)
+	w := httptest.NewRecorder()
+	b.ServeWebhook(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("late delivery = %d", w.Code)
+	}
+
+	// A webhook owned by another deployment is reported, not deleted.
+	issues := telegramWebhookIssues("polling", "", &TelegramWebhookInfo{URL: "https://elsewhere.example.com/hook"}, time.Now())
+	if len(issues) != 1 || !strings.Contains(issues[0], "elsewhere.example.com") {
+		t.Errorf("issues = %q", issues)
+	}
+	issues = telegramWebhookIssues("webhook", "https://a/hook", &TelegramWebhookInfo{
+		URL: "https://a/hook", LastErrorDate: time.Now().Add(-time.Hour).Unix(), LastErrorMessage: "Connection timed out",
+	}, time.Now())
+	if len(issues) != 1 || !strings.Contains(issues[0], "Connection timed out") {
+		t.Errorf("delivery error issues = %q", issues)
+	}
+}
@@ -74,8 +74,8 @@ func (t *FixedThrottle) Wait(chatID int64) time.Duration {
 func (t *FixedThrottle) OnResponse(_ int64, _ error) {}
 
 // IsRateLimitError is a small helper so future AdaptiveThrottle and existing
-// callers agree on what counts as "too fast, back off".
-// Currently unused; exported so tests and AdaptiveThrottle share the rule.
+// callers agree on what counts as "too fast, back off". The delivery queue
+// (delivery.go) uses it to pick the longer retry backoff.
 func IsRateLimitError(err error) bool {
 	if err == nil {
 		return false
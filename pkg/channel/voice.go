@@ -0,0 +1,163 @@
+// pkg/channel/voice.go — speech in and out of channels.
+//
+// Inbound voice notes are filed under the agent workspace and transcribed
+// before the agent runs, so the session keeps the transcript next to a
+// reference to the original clip. Outbound, a channel's voiceReply mode
+// speaks the final answer back through drivers implementing VoiceSender.
+package channel
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// ErrNoSpeechProvider is returned by VoiceOptions funcs when no STT / TTS
+// provider is configured.
+var ErrNoSpeechProvider = errors.New("no speech provider configured")
+
+// Voice reply modes (ChannelEntry.Config["voiceReply"]).
+const (
+	VoiceReplyOff    = ""
+	VoiceReplyAuto   = "auto"   // answer voice notes with voice
+	VoiceReplyAlways = "always" // speak every reply
+)
+
+// voiceTimeout caps one transcription or synthesis call.
+const voiceTimeout = 2 * time.Minute
+
+// VoiceOptions wires speech providers into a channel. The gateway resolves
+// providers from the live config on every call (keys added in the panel
+// apply without restarting the bot); the funcs return ErrNoSpeechProvider
+// when none is configured. Nil funcs behave the same.
+type VoiceOptions struct {
+	Transcribe func(ctx context.Context, audio MediaInput) (string, error)
+	// Synthesize returns Ogg/Opus audio and its content type.
+	Synthesize func(ctx context.Context, text string) ([]byte, string, error)
+	// Reply is the channel's voiceReply mode (VoiceReplyOff / Auto / Always).
+	Reply string
+}
+
+// Transcribe files a voice note and returns the user-turn text for it:
+// "[🎤 语音转写 · media/voice/{session}/{stamp}.ogg] 文字". Without an STT
+// provider, or when transcription fails, only the "[🎤 语音 · ref]"
+// placeholder is returned.
+func (p *Pipeline) Transcribe(ctx context.Context, chat ChatRef, audio MediaInput) string {
+	ref := ""
+	if rel := p.saveVoice(chat, audio); rel != "" {
+		ref = " · " + rel
+	}
+	if p.Env.Voice.Transcribe == nil {
+		return "[🎤 语音" + ref + "]"
+	}
+	tctx, cancel := context.WithTimeout(ctx, voiceTimeout)
+	defer cancel()
+	text, err := p.Env.Voice.Transcribe(tctx, audio)
+	text = strings.TrimSpace(text)
+	switch {
+	case errors.Is(err, ErrNoSpeechProvider):
+		return "[🎤 语音" + ref + "]"
+	case err != nil:
+		log.Printf("[%s] transcribe voice: %v", p.Driver.Type(), err)
+		return "[🎤 语音" + ref + "，转写失败]"
+	case text == "":
+		return "[🎤 语音" + ref + "，未识别到内容]"
+	}
+	return "[🎤 语音转写" + ref + "] " + text
+}
+
+// saveVoice writes the clip under {AgentDir}/workspace/media/voice and
+// returns its workspace-relative path ("" when not saved).
+func (p *Pipeline) saveVoice(chat ChatRef, audio MediaInput) string {
+	if p.Env.AgentDir == "" || len(audio.Data) == 0 {
+		return ""
+	}
+	session := strings.NewReplacer("/", "_", `\`, "_", "..", "_").Replace(p.SessionID(chat))
+	stamp := strings.ReplaceAll(time.Now().Format("20060102-150405.000"), ".", "-")
+	rel := filepath.Join("media", "voice", session, stamp+audioExt(audio))
+	abs := filepath.Join(p.Env.AgentDir, "workspace", rel)
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		log.Printf("[%s] save voice: %v", p.Driver.Type(), err)
+		return ""
+	}
+	if err := os.WriteFile(abs, audio.Data, 0o644); err != nil {
+		log.Printf("[%s] save voice: %v", p.Driver.Type(), err)
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// audioExt picks a file extension from the clip's name or content type.
+func audioExt(m MediaInput) string {
+	if ext := strings.ToLower(filepath.Ext(m.FileName)); ext != "" && len(ext) <= 5 {
+		return ext
+	}
+	ct := strings.ToLower(m.ContentType)
+	switch {
+	case strings.Contains(ct, "ogg"), strings.Contains(ct, "opus"):
+		return ".ogg"
+	case strings.Contains(ct, "mpeg"), strings.Contains(ct, "mp3"):
+		return ".mp3"
+	case strings.Contains(ct, "mp4"), strings.Contains(ct, "m4a"), strings.Contains(ct, "aac"):
+		return ".m4a"
+	case strings.Contains(ct, "wav"):
+		return ".wav"
+	case strings.Contains(ct, "amr"):
+		return ".amr"
+	}
+	return ".audio"
+}
+
+// speak sends the final answer as a voice message too, when the channel's
+// voiceReply mode asks for it and the driver can post voice.
+func (p *Pipeline) speak(ctx context.Context, in InboundMessage, text string) {
+	v := p.Env.Voice
+	vs, ok := p.Driver.(VoiceSender)
+	if !ok || v.Synthesize == nil {
+		return
+	}
+	switch v.Reply {
+	case VoiceReplyAlways:
+	case VoiceReplyAuto:
+		if !in.Voice {
+			return
+		}
+	default:
+		return
+	}
+	text = speakable(text)
+	if text == "" {
+		return
+	}
+	sctx, cancel := context.WithTimeout(ctx, voiceTimeout)
+	defer cancel()
+	audio, ct, err := v.Synthesize(sctx, text)
+	if err != nil {
+		if !errors.Is(err, ErrNoSpeechProvider) {
+			log.Printf("[%s] synthesize voice reply: %v", p.Driver.Type(), err)
+		}
+		return
+	}
+	if err := vs.SendVoice(sctx, in.Chat, audio, ct); err != nil {
+		log.Printf("[%s] send voice reply: %v", p.Driver.Type(), err)
+	}
+}
+
+var (
+	codeFenceRe = regexp.MustCompile("(?s)```.*?```")
+	mdLinkRe    = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+)
+
+// speakable strips markdown that reads badly aloud: code blocks are
+// dropped, links keep their label, emphasis / heading markers go.
+func speakable(text string) string {
+	text = codeFenceRe.ReplaceAllString(text, "")
+	text = mdLinkRe.ReplaceAllString(text, "$1")
+	text = strings.NewReplacer("**", "", "__", "", "`", "", "#", "", "> ", "").Replace(text)
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,92 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+// voiceDriver is a fakeDriver that can post voice messages.
+type voiceDriver struct {
+	fakeDriver
+	voices []string
+}
+
+func (d *voiceDriver) SendVoice(_ context.Context, _ ChatRef, audio []byte, contentType string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.voices = append(d.voices, contentType+":"+string(audio))
+	return nil
+}
+
+func TestPipelineTranscribe(t *testing.T) {
+	dir := t.TempDir()
+	var got MediaInput
+	stt := func(_ context.Context, m MediaInput) (string, error) {
+		got = m
+		return " 明天提醒我开会 ", nil
+	}
+	p := &Pipeline{Env: DriverEnv{AgentDir: dir, Voice: VoiceOptions{Transcribe: stt}}, Driver: &fakeDriver{}}
+	clip := MediaInput{Data: []byte("OggS"), ContentType: "audio/ogg", FileName: "voice.ogg"}
+
+	text := p.Transcribe(context.Background(), ChatRef{ID: "42"}, clip)
+	m := regexp.MustCompile(`^\[🎤 语音转写 · (media/voice/fake-42/[0-9-]+\.ogg)\] 明天提醒我开会$`).FindStringSubmatch(text)
+	if m == nil {
+		t.Fatalf("transcript = %q", text)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "workspace", m[1])); err != nil || string(data) != "OggS" {
+		t.Errorf("saved clip = %q, %v", data, err)
+	}
+	if got.FileName != "voice.ogg" || got.ContentType != "audio/ogg" {
+		t.Errorf("stt got %+v", got)
+	}
+
+	// No provider / failure: placeholder with the clip reference only.
+	p.Env.Voice.Transcribe = func(context.Context, MediaInput) (string, error) { return "", ErrNoSpeechProvider }
+	if text := p.Transcribe(context.Background(), ChatRef{ID: "42"}, clip); !regexp.MustCompile(`^\[🎤 语音 · media/voice/fake-42/\S+\.ogg\]$`).MatchString(text) {
+		t.Errorf("no provider = %q", text)
+	}
+	p.Env.Voice.Transcribe = func(context.Context, MediaInput) (string, error) { return "", errors.New("quota") }
+	if text := p.Transcribe(context.Background(), ChatRef{ID: "42"}, clip); !regexp.MustCompile(`，转写失败\]$`).MatchString(text) {
+		t.Errorf("failure = %q", text)
+	}
+}
+
+func TestPipelineVoiceReply(t *testing.T) {
+	var spoken []string
+	tts := func(_ context.Context, text string) ([]byte, string, error) {
+		spoken = append(spoken, text)
+		return []byte("opus"), "audio/ogg", nil
+	}
+	stream := func(context.Context, string, string, string, []MediaInput, FileSenderFunc, ...string) (<-chan StreamEvent, error) {
+		return streamOf(StreamEvent{Type: "text_delta", Text: "**好的**，见 [文档](https://x.y)\n```go\ncode\n```"}, StreamEvent{Type: "done"}), nil
+	}
+	run := func(mode string, voiceIn bool) *voiceDriver {
+		d := &voiceDriver{}
+		p := &Pipeline{Env: DriverEnv{Stream: stream, Voice: VoiceOptions{Synthesize: tts, Reply: mode}}, Driver: d}
+		p.Dispatch(context.Background(), InboundMessage{ChannelType: "fake", Chat: ChatRef{ID: "c"}, Text: "hi", Voice: voiceIn})
+		if len(d.sent) != 1 {
+			t.Errorf("%s: text reply not sent: %v", mode, d.sent)
+		}
+		return d
+	}
+
+	if d := run(VoiceReplyAuto, true); len(d.voices) != 1 || d.voices[0] != "audio/ogg:opus" {
+		t.Errorf("auto + voice note: voices = %v", d.voices)
+	}
+	if d := run(VoiceReplyAuto, false); len(d.voices) != 0 {
+		t.Errorf("auto + text: voices = %v", d.voices)
+	}
+	if d := run(VoiceReplyAlways, false); len(d.voices) != 1 {
+		t.Errorf("always: voices = %v", d.voices)
+	}
+	if d := run(VoiceReplyOff, true); len(d.voices) != 0 {
+		t.Errorf("off: voices = %v", d.voices)
+	}
+	if len(spoken) != 2 || spoken[0] != "好的，见 文档" {
+		t.Errorf("spoken = %q", spoken)
+	}
+}
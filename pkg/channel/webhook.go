@@ -0,0 +1,560 @@
+// Package channel — generic webhook channel for custom integrations
+// (ticketing, CI, monitoring, ...).
+//   - Inbound: POST /channels/{agentId}/{channelId}/webhook with a JSON
+//     WebhookMessage, signed like the aiteam revenue webhook (pkg/hooksig):
+//     hex HMAC-SHA256 of the raw body with the channel secret in
+//     X-ZyHive-Signature, "ts" (unix seconds, ±5 min) and a single-use
+//     "nonce" in the body. Accepted messages are acked with 202 and run
+//     asynchronously.
+//   - Sessions: "webhook-{conversation}"; the caller picks the key (ticket
+//     id, pipeline, alert fingerprint) and gets one session per key.
+//   - Outbound: WebhookEvents POSTed to callbackUrl through netguard and
+//     signed the same way — "reply" always, "delta" / "tool_call" /
+//     "tool_result" when streamDeltas / toolEvents are on, "message" for
+//     notify / proactive sends. Final events are retried with backoff and
+//     appended to webhook-deadletter/{channelId}.jsonl when every attempt
+//     fails; progress events are tried once.
+package channel
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/hooksig"
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+)
+
+const (
+	// WebhookSignatureHeader carries the hex HMAC-SHA256 of the raw body,
+	// on inbound messages and outbound callbacks alike.
+	WebhookSignatureHeader = "X-ZyHive-Signature"
+	// WebhookEventHeader repeats WebhookEvent.Type on callbacks.
+	WebhookEventHeader = "X-ZyHive-Event"
+
+	// webhookFreshness bounds |now - ts| of an inbound message.
+	webhookFreshness = 5 * time.Minute
+	// webhookNonceCache is how many inbound nonces are remembered (FIFO).
+	webhookNonceCache = 10_000
+	webhookMaxBody    = 1 << 20
+	// webhookDeltaEvery batches text deltas into one "delta" callback.
+	webhookDeltaEvery = time.Second
+)
+
+// webhookRetryDelays are the waits between delivery attempts of a final
+// event (5 attempts over ~1.5 minutes).
+var webhookRetryDelays = []time.Duration{2 * time.Second, 8 * time.Second, 20 * time.Second, time.Minute}
+
+// webhookConversationRe is the accepted conversation key: it becomes part
+// of the session id and file names.
+var webhookConversationRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.@-]{0,127}$`)
+
+// WebhookMessage is the inbound JSON body.
+type WebhookMessage struct {
+	// Timestamp is unix seconds when the caller signed the message.
+	Timestamp int64  `json:"ts"`
+	Nonce     string `json:"nonce"`
+	// Conversation maps to the session "webhook-{conversation}".
+	Conversation string `json:"conversation"`
+	Text         string `json:"text"`
+	// Sender is optional; with an id it is filed in the contact book.
+	Sender Sender `json:"sender"`
+	// Context is appended to the system prompt (invisible in the session).
+	Context string `json:"context,omitempty"`
+	// Metadata is opaque to ZyHive and echoed on every callback of the run.
+	Metadata json.RawMessage `json:"metadata,omitempty"`
+}
+
+// WebhookEvent is one outbound callback.
+type WebhookEvent struct {
+	Type      string `json:"type"` // reply / error / delta / tool_call / tool_result / message / ping
+	Timestamp int64  `json:"ts"`
+	Nonce     string `json:"nonce"` // unique per event, stable across retries
+	AgentID   string `json:"agentId"`
+	ChannelID string `json:"channelId"`
+
+	Conversation string `json:"conversation,omitempty"`
+	SessionID    string `json:"sessionId,omitempty"`
+	// MessageID is the nonce of the inbound message being answered.
+	MessageID string `json:"messageId,omitempty"`
+	// Seq orders the events of one run (1, 2, ...).
+	Seq int `json:"seq,omitempty"`
+	// Text is the reply / message text, the new text of a delta, or the
+	// tool output of a tool_result.
+	Text       string          `json:"text,omitempty"`
+	ToolCallID string          `json:"toolCallId,omitempty"`
+	ToolName   string          `json:"toolName,omitempty"`
+	ToolInput  json.RawMessage `json:"toolInput,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+}
+
+// WebhookBot is a webhook channel instance.
+type WebhookBot struct {
+	secret       string
+	callbackURL  string
+	streamDeltas bool
+	toolEvents   bool
+	agentID      string
+	agentDir     string
+	channelID    string
+	streamFunc   StreamFunc
+	onConnected  func(name string)
+
+	client      *http.Client
+	retryDelays []time.Duration
+
+	// runCtx is the Start context; nil while not running (ServeWebhook 503).
+	runMu  sync.Mutex
+	runCtx context.Context
+
+	nonces *hooksig.NonceCache
+
+	// deadMu serializes dead-letter appends.
+	deadMu sync.Mutex
+	// chatMu serializes processing per session to avoid concurrent runs.
+	chatMu sync.Map
+	// inflight tracks message handlers; Start returns once they finish.
+	inflight sync.WaitGroup
+}
+
+// NewWebhookBotWithStream creates a WebhookBot; callbackURL must be an
+// http(s) URL.
+func NewWebhookBotWithStream(secret, callbackURL, agentID, agentDir, channelID string, sf StreamFunc) (*WebhookBot, error) {
+	u, err := url.Parse(callbackURL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return nil, fmt.Errorf("webhook: invalid callbackUrl %q", callbackURL)
+	}
+	return &WebhookBot{
+		secret:      secret,
+		callbackURL: callbackURL,
+		agentID:     agentID,
+		agentDir:    agentDir,
+		channelID:   channelID,
+		streamFunc:  sf,
+		client:      netguard.NewSafeClient(15 * time.Second),
+		retryDelays: webhookRetryDelays,
+		nonces:      hooksig.NewNonceCache(webhookNonceCache),
+	}, nil
+}
+
+// SetEventOptions turns on "delta" and "tool_call" / "tool_result" callbacks.
+func (b *WebhookBot) SetEventOptions(streamDeltas, toolEvents bool) {
+	b.streamDeltas, b.toolEvents = streamDeltas, toolEvents
+}
+
+// SetOnConnected sets a callback fired once the channel accepts messages.
+func (b *WebhookBot) SetOnConnected(fn func(name string)) {
+	b.onConnected = fn
+}
+
+// Start accepts inbound messages (ServeWebhook) until ctx is cancelled,
+// then waits for running handlers.
+func (b *WebhookBot) Start(ctx context.Context) {
+	log.Printf("[webhook] starting agent=%s channel=%s", b.agentID, b.channelID)
+	b.runMu.Lock()
+	b.runCtx = ctx
+	b.runMu.Unlock()
+	if b.onConnected != nil {
+		b.onConnected(webhookHost(b.callbackURL))
+	}
+	<-ctx.Done()
+	b.runMu.Lock()
+	b.runCtx = nil
+	b.runMu.Unlock()
+	b.inflight.Wait()
+}
+
+func (b *WebhookBot) ctx() context.Context {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+	return b.runCtx
+}
+
+func (b *WebhookBot) spawn(fn func()) {
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		fn()
+	}()
+}
+
+// ── Inbound ───────────────────────────────────────────────────────────────
+
+// ServeWebhook implements WebhookHandler: verify signature, freshness and
+// nonce, ack with 202 {"accepted", "sessionId", "messageId"} and run the
+// agent asynchronously; the reply arrives on the callback URL.
+func (b *WebhookBot) ServeWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx := b.ctx()
+	if ctx == nil {
+		http.Error(w, "channel not running", http.StatusServiceUnavailable)
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, webhookMaxBody+1))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	if len(body) > webhookMaxBody {
+		http.Error(w, "body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	m, status, err := b.verify(body, r.Header.Get(WebhookSignatureHeader))
+	if err != nil {
+		log.Printf("[webhook] message rejected agent=%s channel=%s: %v", b.agentID, b.channelID, err)
+		writeJSON(w, status, map[string]any{"accepted": false, "error": err.Error()})
+		return
+	}
+	chat := ChatRef{ID: m.Conversation}
+	b.spawn(func() { b.handle(ctx, chat, m) })
+	writeJSON(w, http.StatusAccepted, map[string]any{
+		"accepted":  true,
+		"sessionId": SessionIDFor(b.Type(), chat.ID),
+		"messageId": m.Nonce,
+	})
+}
+
+// verify checks an inbound body the way revenue.Ingester.Accept does:
+// HMAC over the raw bytes, then freshness, then nonce uniqueness. It
+// returns the message or the HTTP status to reject it with.
+func (b *WebhookBot) verify(body []byte, signature string) (*WebhookMessage, int, error) {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	if !hooksig.Verify([]byte(b.secret), body, signature) {
+		return nil, http.StatusUnauthorized, errors.New("bad signature")
+	}
+	var m WebhookMessage
+	if err := json.Unmarshal(body, &m); err != nil {
+		return nil, http.StatusBadRequest, errors.New("invalid json")
+	}
+	if !hooksig.Fresh(m.Timestamp, webhookFreshness) {
+		return nil, http.StatusUnauthorized, fmt.Errorf("stale timestamp (ts=%d)", m.Timestamp)
+	}
+	if m.Nonce == "" {
+		return nil, http.StatusBadRequest, errors.New("missing nonce")
+	}
+	if !webhookConversationRe.MatchString(m.Conversation) {
+		return nil, http.StatusBadRequest, errors.New("conversation must be 1-128 of [A-Za-z0-9_.@-]")
+	}
+	m.Text = strings.TrimSpace(m.Text)
+	if m.Text == "" {
+		return nil, http.StatusBadRequest, errors.New("missing text")
+	}
+	// The nonce is recorded last so a rejected message can be fixed and resent.
+	if !b.nonces.Record(m.Nonce) {
+		return nil, http.StatusConflict, errors.New("nonce already seen")
+	}
+	return &m, 0, nil
+}
+
+// handle runs the agent on one accepted message and streams the run to
+// the callback URL. Messages of one conversation run one at a time.
+func (b *WebhookBot) handle(ctx context.Context, chat ChatRef, m *WebhookMessage) {
+	pipe := b.pipeline()
+	sessionID := pipe.SessionID(chat)
+	muVal, _ := b.chatMu.LoadOrStore(sessionID, &sync.Mutex{})
+	mu := muVal.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	in := InboundMessage{
+		ChannelType: b.Type(),
+		ChannelID:   b.channelID,
+		MessageID:   m.Nonce,
+		Chat:        chat,
+		Sender:      m.Sender,
+		Text:        m.Text,
+	}
+	if m.Context != "" {
+		in.ExtraContext = []string{m.Context}
+	}
+	log.Printf("[webhook] message conversation=%s sender=%s text=%q", chat.ID, m.Sender.ID, truncateStr(m.Text, 60))
+	pipe.LogInbound(in, "")
+
+	base := WebhookEvent{Conversation: chat.ID, SessionID: sessionID, MessageID: m.Nonce, Metadata: m.Metadata}
+	final := b.run(ctx, pipe, in, base)
+	pipe.logTurn(b.Type(), sessionID, "assistant", final, "")
+}
+
+// run streams one agent turn: progress callbacks while it runs, then the
+// final "reply" (or "error") delivered with retries. Returns the reply text.
+func (b *WebhookBot) run(ctx context.Context, pipe *Pipeline, in InboundMessage, base WebhookEvent) string {
+	runCtx, cancel := context.WithTimeout(ctx, dispatchTimeout)
+	defer cancel()
+	runCtx, span := startDispatchSpan(runCtx, in.ChannelType, b.channelID, b.agentID, base.SessionID)
+	var runErr error
+	defer func() { span.End(runErr) }()
+
+	seq := 0
+	event := func(typ string) WebhookEvent {
+		seq++
+		ev := base
+		ev.Type, ev.Seq = typ, seq
+		return ev
+	}
+
+	extra := append([]string(nil), in.ExtraContext...)
+	if s := pipe.fileContacts(in); s != "" {
+		extra = append(extra, s)
+	}
+	var extraArgs []string
+	if len(extra) > 0 {
+		extraArgs = []string{strings.Join(extra, "\n\n")}
+	}
+	events, err := b.streamFunc(runCtx, b.agentID, in.Text, base.SessionID, nil, nil, extraArgs...)
+	if err != nil {
+		runErr = err
+		ev := event("error")
+		ev.Error = err.Error()
+		_ = b.deliver(ctx, ev)
+		return ""
+	}
+
+	var acc, pending strings.Builder
+	flushDelta := func() {
+		if pending.Len() == 0 {
+			return
+		}
+		ev := event("delta")
+		ev.Text = pending.String()
+		pending.Reset()
+		b.notify(runCtx, ev)
+	}
+	var tick <-chan time.Time
+	if b.streamDeltas {
+		t := time.NewTicker(webhookDeltaEvery)
+		defer t.Stop()
+		tick = t.C
+	}
+loop:
+	for {
+		select {
+		case sev, ok := <-events:
+			if !ok {
+				break loop
+			}
+			switch sev.Type {
+			case "text_delta":
+				acc.WriteString(sev.Text)
+				if b.streamDeltas {
+					pending.WriteString(sev.Text)
+				}
+			case "tool_call", "tool_result":
+				if !b.toolEvents {
+					continue
+				}
+				flushDelta()
+				ev := event(sev.Type)
+				ev.ToolCallID, ev.ToolName, ev.Text = sev.ToolCallID, sev.ToolName, sev.Text
+				if json.Valid([]byte(sev.ToolInput)) {
+					ev.ToolInput = json.RawMessage(sev.ToolInput)
+				}
+				b.notify(runCtx, ev)
+			case "error":
+				if sev.Err != nil && runErr == nil {
+					runErr = sev.Err
+				}
+			case "done":
+				break loop
+			}
+		case <-tick:
+			flushDelta()
+		}
+	}
+	flushDelta()
+
+	final := acc.String()
+	ev := event("reply")
+	ev.Text = strings.TrimSpace(final)
+	if runErr != nil {
+		ev.Error = runErr.Error()
+		if ev.Text == "" {
+			ev.Type = "error"
+		}
+	}
+	// The run may have hit its timeout; delivery still gets the bot's ctx.
+	_ = b.deliver(ctx, ev)
+	return final
+}
+
+// ── Outbound ──────────────────────────────────────────────────────────────
+
+// WebhookSign returns the hex HMAC-SHA256 of body — the value of
+// X-ZyHive-Signature.
+func WebhookSign(secret string, body []byte) string {
+	return hooksig.Sign([]byte(secret), body)
+}
+
+// stamp fills the envelope fields of an outbound event.
+func (b *WebhookBot) stamp(ev *WebhookEvent) {
+	ev.Timestamp = time.Now().Unix()
+	ev.Nonce = webhookNonce()
+	ev.AgentID, ev.ChannelID = b.agentID, b.channelID
+}
+
+// notify posts a progress event once; failures are only logged.
+func (b *WebhookBot) notify(ctx context.Context, ev WebhookEvent) {
+	b.stamp(&ev)
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	if _, err := b.post(ctx, ev.Type, body); err != nil {
+		log.Printf("[webhook] %s callback dropped channel=%s: %v", ev.Type, b.channelID, err)
+	}
+}
+
+// deliver posts a final event, retrying network errors, 429 and 5xx
+// with backoff. The same signed body (same nonce) is resent so the
+// receiver can dedupe. An event that cannot be delivered is appended to
+// the dead-letter log.
+func (b *WebhookBot) deliver(ctx context.Context, ev WebhookEvent) error {
+	b.stamp(&ev)
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	attempts := 0
+	for {
+		attempts++
+		retry, err := b.post(ctx, ev.Type, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempts > len(b.retryDelays) {
+			b.deadLetter(ev, attempts, err)
+			return err
+		}
+		delay := b.retryDelays[attempts-1]
+		log.Printf("[webhook] %s callback attempt %d failed channel=%s: %v — retrying in %s", ev.Type, attempts, b.channelID, err, delay)
+		select {
+		case <-ctx.Done():
+			b.deadLetter(ev, attempts, err)
+			return err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// post sends one signed callback. retry reports whether a failure is
+// worth retrying (network error, 429, 5xx).
+func (b *WebhookBot) post(ctx context.Context, eventType string, body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookSignatureHeader, WebhookSign(b.secret, body))
+	req.Header.Set(WebhookEventHeader, eventType)
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return !errors.Is(err, netguard.ErrBlocked), err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry = resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retry, fmt.Errorf("callback returned HTTP %d", resp.StatusCode)
+}
+
+// WebhookDeadLetter is one line of the dead-letter log.
+type WebhookDeadLetter struct {
+	FailedAt string       `json:"failedAt"` // RFC3339
+	Attempts int          `json:"attempts"`
+	Error    string       `json:"error"`
+	Event    WebhookEvent `json:"event"`
+}
+
+// webhookDeadLetterPath is agents/{id}/webhook-deadletter/{channelId}.jsonl.
+func webhookDeadLetterPath(agentDir, channelID string) string {
+	return filepath.Join(agentDir, "webhook-deadletter", channelID+".jsonl")
+}
+
+func (b *WebhookBot) deadLetter(ev WebhookEvent, attempts int, cause error) {
+	log.Printf("[webhook] %s callback failed after %d attempt(s) channel=%s conversation=%s: %v",
+		ev.Type, attempts, b.channelID, ev.Conversation, cause)
+	if b.agentDir == "" {
+		return
+	}
+	line, err := json.Marshal(WebhookDeadLetter{
+		FailedAt: time.Now().UTC().Format(time.RFC3339),
+		Attempts: attempts,
+		Error:    cause.Error(),
+		Event:    ev,
+	})
+	if err != nil {
+		return
+	}
+	path := webhookDeadLetterPath(b.agentDir, b.channelID)
+	b.deadMu.Lock()
+	defer b.deadMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		log.Printf("[webhook] dead-letter: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Printf("[webhook] dead-letter: %v", err)
+		return
+	}
+	defer f.Close()
+	_, _ = f.Write(append(line, '\n'))
+}
+
+// TestWebhookCallback POSTs a signed "ping" event to cfg["callbackUrl"]
+// (one attempt) and returns the callback host.
+func TestWebhookCallback(ctx context.Context, cfg map[string]string) (string, error) {
+	b, err := NewWebhookBotWithStream(cfg["secret"], cfg["callbackUrl"], "", "", "", nil)
+	if err != nil {
+		return "", err
+	}
+	b.client = netguard.NewSafeClient(8 * time.Second)
+	ev := WebhookEvent{Type: "ping"}
+	b.stamp(&ev)
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return "", err
+	}
+	if _, err := b.post(ctx, ev.Type, body); err != nil {
+		return "", err
+	}
+	return webhookHost(b.callbackURL), nil
+}
+
+func webhookHost(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Host
+	}
+	return rawURL
+}
+
+func webhookNonce() string {
+	var buf [16]byte
+	_, _ = rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,106 @@
+package channel
+
+import (
+	"context"
+	"errors"
+)
+
+// WebhookBot implements Driver, Notifier and WebhookHandler.
+var (
+	_ Driver         = (*WebhookBot)(nil)
+	_ Notifier       = (*WebhookBot)(nil)
+	_ WebhookHandler = (*WebhookBot)(nil)
+)
+
+// The HMAC secret is the only credential; it authenticates the caller, so
+// the channel has no allowlist or pending users. One callback URL may
+// serve several channels.
+func init() {
+	RegisterDriver(DriverSpec{
+		Type:     "webhook",
+		Required: []string{"secret", "callbackUrl"},
+		New:      newWebhookDriver,
+		Test:     TestWebhookCallback,
+	})
+}
+
+func newWebhookDriver(env DriverEnv) (Driver, error) {
+	c := env.Config
+	bot, err := NewWebhookBotWithStream(c["secret"], c["callbackUrl"], env.AgentID, env.AgentDir, env.ChannelID, env.Stream)
+	if err != nil {
+		return nil, err
+	}
+	bot.SetEventOptions(c["streamDeltas"] == "true", c["toolEvents"] == "true")
+	bot.SetOnConnected(env.OnConnected)
+	return bot, nil
+}
+
+// pipeline returns the shared message path bound to this bot.
+func (b *WebhookBot) pipeline() *Pipeline {
+	return &Pipeline{
+		Env: DriverEnv{
+			AgentID:   b.agentID,
+			AgentDir:  b.agentDir,
+			ChannelID: b.channelID,
+			Stream:    b.streamFunc,
+		},
+		Driver: b,
+	}
+}
+
+// Type implements Driver.
+func (b *WebhookBot) Type() string { return "webhook" }
+
+// Capabilities implements Driver. Callbacks cannot be edited; streaming
+// goes through "delta" events instead (see run).
+func (b *WebhookBot) Capabilities() Capabilities {
+	return Capabilities{}
+}
+
+// Send implements Driver: a "message" event for the conversation,
+// delivered with retries. replyTo becomes messageId.
+func (b *WebhookBot) Send(ctx context.Context, chat ChatRef, text, replyTo string) (string, error) {
+	ev := WebhookEvent{
+		Type:         "message",
+		Conversation: chat.ID,
+		MessageID:    replyTo,
+		Text:         text,
+	}
+	if chat.ID != "" {
+		ev.SessionID = SessionIDFor(b.Type(), chat.ID)
+	}
+	return "", b.deliver(ctx, ev)
+}
+
+// Edit implements Driver (unsupported).
+func (b *WebhookBot) Edit(ctx context.Context, chat ChatRef, msgID, text string) error {
+	return errors.New("webhook: edit not supported")
+}
+
+// Typing implements Driver (no-op).
+func (b *WebhookBot) Typing(ctx context.Context, chat ChatRef) error { return nil }
+
+// SendFile implements Driver (unsupported).
+func (b *WebhookBot) SendFile(ctx context.Context, chat ChatRef, path string) (string, error) {
+	return "", errors.New("webhook: files not supported")
+}
+
+// ProactiveSend implements Driver: a "message" event without a
+// conversation; the receiver decides where it goes.
+func (b *WebhookBot) ProactiveSend(text string) error {
+	ctx := b.ctx()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	_, err := b.Send(ctx, ChatRef{}, text, "")
+	return err
+}
+
+// Notify runs the agent on prompt in the conversation's session (chat.ID
+// is the conversation key) and posts the reply as a "message" event.
+func (b *WebhookBot) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	if !webhookConversationRe.MatchString(chat.ID) {
+		return errors.New("webhook: invalid conversation")
+	}
+	return b.pipeline().Notify(ctx, chat, prompt)
+}
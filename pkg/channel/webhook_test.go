@@ -0,0 +1,236 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testWebhookSecret = "s3cret"
+
+// fakeCallback is a callback endpoint that records verified events.
+type fakeCallback struct {
+	t   *testing.T
+	srv *httptest.Server
+	mu  sync.Mutex
+	// events are the decoded bodies of every attempt, in arrival order.
+	events []WebhookEvent
+	// status returns the HTTP status for the n-th attempt (1-based).
+	status func(n int) int
+}
+
+func newFakeCallback(t *testing.T) *fakeCallback {
+	f := &fakeCallback{t: t, status: func(int) int { return http.StatusOK }}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeCallback) serve(w http.ResponseWriter, r *http.Request) {
+	raw, _ := io.ReadAll(r.Body)
+	if r.Header.Get(WebhookSignatureHeader) != WebhookSign(testWebhookSecret, raw) {
+		f.t.Errorf("callback signature mismatch: %s", raw)
+	}
+	var ev WebhookEvent
+	_ = json.Unmarshal(raw, &ev)
+	if r.Header.Get(WebhookEventHeader) != ev.Type {
+		f.t.Errorf("event header %q for %q", r.Header.Get(WebhookEventHeader), ev.Type)
+	}
+	f.mu.Lock()
+	f.events = append(f.events, ev)
+	status := f.status(len(f.events))
+	f.mu.Unlock()
+	w.WriteHeader(status)
+}
+
+func (f *fakeCallback) setStatus(fn func(n int) int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.status = fn
+}
+
+func (f *fakeCallback) all() []WebhookEvent {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]WebhookEvent(nil), f.events...)
+}
+
+// wait returns the recorded events once one of type typ has arrived.
+func (f *fakeCallback) wait(typ string) []WebhookEvent {
+	f.t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		got := f.all()
+		for _, ev := range got {
+			if ev.Type == typ {
+				return got
+			}
+		}
+		if time.Now().After(deadline) {
+			f.t.Fatalf("no %s callback; got %+v", typ, got)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// startTestWebhookBot starts a webhook channel a1 / hook-1 whose runs
+// call a tool and answer "done: {text}".
+func startTestWebhookBot(t *testing.T, f *fakeCallback, agentDir string, runs chan<- testRun) *WebhookBot {
+	t.Helper()
+	stream := func(_ context.Context, _, text, sessionID string, media []MediaInput, _ FileSenderFunc, _ ...string) (<-chan StreamEvent, error) {
+		if runs != nil {
+			runs <- testRun{session: sessionID, text: text, media: media}
+		}
+		return streamOf(
+			StreamEvent{Type: "text_delta", Text: "done: "},
+			StreamEvent{Type: "tool_call", ToolCallID: "t1", ToolName: "web_search", ToolInput: `{"q":"x"}`},
+			StreamEvent{Type: "tool_result", ToolCallID: "t1", Text: "3 results"},
+			StreamEvent{Type: "text_delta", Text: text},
+			StreamEvent{Type: "done"},
+		), nil
+	}
+	b, err := NewWebhookBotWithStream(testWebhookSecret, f.srv.URL+"/hook", "a1", agentDir, "hook-1", stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.client = f.srv.Client()
+	b.retryDelays = []time.Duration{time.Millisecond, time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() { b.Start(ctx); close(done) }()
+	t.Cleanup(func() { cancel(); <-done })
+	for b.ctx() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	return b
+}
+
+// postHook sends msg signed with secret and returns the response.
+func postHook(b *WebhookBot, secret string, msg map[string]any) *httptest.ResponseRecorder {
+	raw, _ := json.Marshal(msg)
+	r := httptest.NewRequest(http.MethodPost, "/channels/a1/hook-1/webhook", strings.NewReader(string(raw)))
+	r.Header.Set(WebhookSignatureHeader, "sha256="+WebhookSign(secret, raw))
+	w := httptest.NewRecorder()
+	b.ServeWebhook(w, r)
+	return w
+}
+
+func webhookMsg(nonce, conversation, text string) map[string]any {
+	return map[string]any{
+		"ts": time.Now().Unix(), "nonce": nonce, "conversation": conversation, "text": text,
+		"sender": map[string]string{"id": "ci-bot", "name": "CI"}, "metadata": map[string]any{"build": 42},
+	}
+}
+
+func TestWebhookInbound(t *testing.T) {
+	f := newFakeCallback(t)
+	runs := make(chan testRun, 4)
+	b := startTestWebhookBot(t, f, "", runs)
+	b.SetEventOptions(false, true)
+
+	if w := postHook(b, "wrong", webhookMsg("n1", "build-42", "hi")); w.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature = %d", w.Code)
+	}
+	stale := webhookMsg("n1", "build-42", "hi")
+	stale["ts"] = time.Now().Add(-10 * time.Minute).Unix()
+	if w := postHook(b, testWebhookSecret, stale); w.Code != http.StatusUnauthorized {
+		t.Errorf("stale ts = %d", w.Code)
+	}
+	if w := postHook(b, testWebhookSecret, webhookMsg("n1", "../etc", "hi")); w.Code != http.StatusBadRequest {
+		t.Errorf("bad conversation = %d", w.Code)
+	}
+
+	w := postHook(b, testWebhookSecret, webhookMsg("n1", "build-42", "tests failed"))
+	if w.Code != http.StatusAccepted || !strings.Contains(w.Body.String(), `"sessionId":"webhook-build-42"`) {
+		t.Fatalf("accept = %d %s", w.Code, w.Body.String())
+	}
+	if run := <-runs; run.session != "webhook-build-42" || run.text != "tests failed" {
+		t.Errorf("run = %+v", run)
+	}
+	events := f.wait("reply")
+	var types []string
+	for _, ev := range events {
+		types = append(types, ev.Type+"#"+strconv.Itoa(ev.Seq))
+	}
+	if strings.Join(types, ",") != "tool_call#1,tool_result#2,reply#3" {
+		t.Errorf("events = %v", types)
+	}
+	call, reply := events[0], events[2]
+	if call.ToolName != "web_search" || string(call.ToolInput) != `{"q":"x"}` || events[1].Text != "3 results" {
+		t.Errorf("tool events = %+v", events[:2])
+	}
+	if reply.Text != "done: tests failed" || reply.MessageID != "n1" || reply.Conversation != "build-42" ||
+		reply.AgentID != "a1" || string(reply.Metadata) != `{"build":42}` || reply.Nonce == "" || reply.Nonce == "n1" {
+		t.Errorf("reply = %+v", reply)
+	}
+
+	// A replayed message is refused.
+	if w := postHook(b, testWebhookSecret, webhookMsg("n1", "build-42", "tests failed")); w.Code != http.StatusConflict {
+		t.Errorf("replay = %d", w.Code)
+	}
+}
+
+func TestWebhookStreamDeltas(t *testing.T) {
+	f := newFakeCallback(t)
+	b := startTestWebhookBot(t, f, "", nil)
+	b.SetEventOptions(true, false)
+
+	if w := postHook(b, testWebhookSecret, webhookMsg("n2", "c1", "x")); w.Code != http.StatusAccepted {
+		t.Fatalf("accept = %d", w.Code)
+	}
+	events := f.wait("reply")
+	if len(events) != 2 || events[0].Type != "delta" || events[0].Text != "done: x" || events[1].Text != "done: x" {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestWebhookCallbackRetries(t *testing.T) {
+	f := newFakeCallback(t)
+	f.status = func(n int) int {
+		if n < 3 {
+			return http.StatusBadGateway
+		}
+		return http.StatusOK
+	}
+	dir := t.TempDir()
+	b := startTestWebhookBot(t, f, dir, nil)
+
+	// Two 502s, then delivered: three attempts with the same nonce.
+	if _, err := b.Send(context.Background(), ChatRef{ID: "c1"}, "hello", ""); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if got := f.all(); len(got) != 3 || got[0].Nonce != got[2].Nonce || got[2].Text != "hello" {
+		t.Errorf("attempts = %+v", got)
+	}
+
+	// A 4xx is not retried; the event goes to the dead-letter log.
+	f.setStatus(func(int) int { return http.StatusBadRequest })
+	if err := b.ProactiveSend("lost"); err == nil {
+		t.Fatal("4xx callback reported as delivered")
+	}
+	if n := len(f.all()); n != 4 {
+		t.Errorf("4xx retried: %d attempts", n-3)
+	}
+	// Server errors are retried, then dead-lettered too.
+	f.setStatus(func(int) int { return http.StatusServiceUnavailable })
+	_ = b.ProactiveSend("also lost")
+
+	data, err := os.ReadFile(webhookDeadLetterPath(dir, "hook-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var last WebhookDeadLetter
+	_ = json.Unmarshal([]byte(lines[len(lines)-1]), &last)
+	if len(lines) != 2 || last.Attempts != 3 || last.Event.Text != "also lost" || !strings.Contains(last.Error, "503") {
+		t.Errorf("dead letters = %s", data)
+	}
+}
@@ -0,0 +1,827 @@
+// Package channel — WeCom (企业微信) self-built app integration.
+//   - Callback mode: GET verifies the callback URL (echostr), POST carries
+//     AES-encrypted messages; both are signed with the callback Token
+//     (see wecom_crypto.go). Requests are acked at once, runs are async.
+//   - App callbacks (XML) are 1:1 chats; group chats arrive through an AI
+//     bot (智能机器人) pointed at the same URL with the same Token /
+//     EncodingAESKey (JSON callbacks, only when the bot is @mentioned)
+//   - Replies: the AI bot's response_url while unused, else
+//     /cgi-bin/message/send (markdown) with a cached access_token
+//   - Sessions: "wecom-{userid}" for 1:1, "wecom-{chatid}" for groups;
+//     allowlist entries are member userids
+package channel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+	"github.com/Zyling-ai/zyhive/pkg/network"
+)
+
+const (
+	wecomAPIBase = "https://qyapi.weixin.qq.com"
+	// wecomMaxFileBytes caps one downloaded attachment.
+	wecomMaxFileBytes = 20 << 20
+	// wecomMaxMarkdown is the app markdown content limit (bytes); longer
+	// replies are split.
+	wecomMaxMarkdown = 2048
+	// wecomResponseTTL is how long an AI bot response_url stays valid.
+	wecomResponseTTL = time.Hour
+)
+
+// Access token errors: the cached token is dropped and the call retried.
+const (
+	wecomErrInvalidToken = 40014
+	wecomErrTokenExpired = 42001
+)
+
+// ── WeCom API types ───────────────────────────────────────────────────────
+
+// wecomEnvelope is the encrypted request body: XML for app callbacks,
+// JSON {"encrypt": ...} for AI bot callbacks.
+type wecomEnvelope struct {
+	Encrypt string `xml:"Encrypt" json:"encrypt"`
+}
+
+// wecomAppMessage is a decrypted app callback.
+type wecomAppMessage struct {
+	FromUserName string `xml:"FromUserName"`
+	MsgType      string `xml:"MsgType"` // text / image / voice / video / location / link / event
+	Content      string `xml:"Content"`
+	MsgID        string `xml:"MsgId"`
+	MediaID      string `xml:"MediaId"`
+	Event        string `xml:"Event"`
+	Label        string `xml:"Label"`
+	Title        string `xml:"Title"`
+	URL          string `xml:"Url"`
+}
+
+// wecomBotMessage is a decrypted AI bot callback.
+type wecomBotMessage struct {
+	MsgID    string `json:"msgid"`
+	ChatID   string `json:"chatid"`
+	ChatType string `json:"chattype"` // single / group
+	From     struct {
+		UserID string `json:"userid"`
+	} `json:"from"`
+	ResponseURL string        `json:"response_url"`
+	MsgType     string        `json:"msgtype"` // text / image / mixed / event / stream
+	Text        wecomBotText  `json:"text"`
+	Image       wecomBotImage `json:"image"`
+	Mixed       struct {
+		Items []struct {
+			MsgType string        `json:"msgtype"`
+			Text    wecomBotText  `json:"text"`
+			Image   wecomBotImage `json:"image"`
+		} `json:"msg_item"`
+	} `json:"mixed"`
+}
+
+type wecomBotText struct {
+	Content string `json:"content"`
+}
+
+type wecomBotImage struct {
+	URL string `json:"url"` // encrypted with the callback key
+}
+
+// wecomUser is the part of /cgi-bin/user/get the bot uses.
+type wecomUser struct {
+	Name   string `json:"name"`
+	Avatar string `json:"avatar"`
+}
+
+// wecomResponse is an unused AI bot response_url.
+type wecomResponse struct {
+	URL     string
+	Expires time.Time
+}
+
+// ── WeComBot ──────────────────────────────────────────────────────────────
+
+type WeComBot struct {
+	corpID       string
+	appAgentID   string // the WeCom app's AgentId (not the ZyHive agent)
+	secret       string
+	crypt        *wecomCrypt
+	agentID      string
+	agentDir     string
+	channelID    string
+	getAllowFrom func() []string
+
+	streamFunc   StreamFunc
+	pendingStore *PendingStoreStr
+	panelBaseURL string
+	onConnected  func(name string)
+
+	apiBase string
+	client  *http.Client
+
+	tokMu    sync.Mutex
+	token    string
+	tokenExp time.Time
+
+	runMu  sync.Mutex
+	runCtx context.Context
+
+	seenMu sync.Mutex
+	seen   map[string]time.Time // MsgId dedup (WeCom retries unacked callbacks)
+
+	// users caches userid → wecomUser (names are not in app callbacks).
+	users sync.Map
+	// responses maps chat id → wecomResponse from the latest AI bot message.
+	responses sync.Map
+	// avatars dedupes avatar downloads (once per user per process).
+	avatars sync.Map
+	// chatMu serializes processing per session to avoid concurrent runs.
+	chatMu sync.Map
+	// inflight tracks message handlers; Start returns once they finish.
+	inflight sync.WaitGroup
+}
+
+// NewWeComBotWithStream creates a WeComBot; it fails on a malformed
+// EncodingAESKey.
+func NewWeComBotWithStream(corpID, appAgentID, secret, token, encodingAESKey, agentID, agentDir, channelID string, getAllowFrom func() []string, sf StreamFunc, pending *PendingStoreStr) (*WeComBot, error) {
+	crypt, err := newWecomCrypt(token, encodingAESKey, corpID)
+	if err != nil {
+		return nil, err
+	}
+	return &WeComBot{
+		corpID:       corpID,
+		appAgentID:   appAgentID,
+		secret:       secret,
+		crypt:        crypt,
+		agentID:      agentID,
+		agentDir:     agentDir,
+		channelID:    channelID,
+		getAllowFrom: getAllowFrom,
+		streamFunc:   sf,
+		pendingStore: pending,
+		apiBase:      wecomAPIBase,
+		client:       netguard.NewSafeClient(15 * time.Second),
+		runCtx:       context.Background(),
+		seen:         make(map[string]time.Time),
+	}, nil
+}
+
+// SetOnConnected sets a callback fired once the credentials are verified.
+func (b *WeComBot) SetOnConnected(fn func(name string)) {
+	b.onConnected = fn
+}
+
+// SetPanelBaseURL sets the ZyHive panel URL shown in pairing messages.
+func (b *WeComBot) SetPanelBaseURL(url string) {
+	b.panelBaseURL = url
+}
+
+// Start verifies the credentials, then serves webhooks (ServeWebhook)
+// until ctx is cancelled.
+func (b *WeComBot) Start(ctx context.Context) {
+	log.Printf("[wecom] starting agent=%s", b.agentID)
+	b.runMu.Lock()
+	b.runCtx = ctx
+	b.runMu.Unlock()
+	defer b.inflight.Wait()
+
+	for {
+		name, err := b.appName(ctx)
+		if err == nil {
+			log.Printf("[wecom] app ok corp=%s agentid=%s name=%s", b.corpID, b.appAgentID, name)
+			if b.onConnected != nil {
+				b.onConnected(name)
+			}
+			break
+		}
+		log.Printf("[wecom] agent/get error: %v — retrying in 30s", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(30 * time.Second):
+		}
+	}
+	<-ctx.Done()
+}
+
+// appName returns the WeCom app's name (GET /cgi-bin/agent/get).
+func (b *WeComBot) appName(ctx context.Context) (string, error) {
+	var out struct {
+		Name string `json:"name"`
+	}
+	if err := b.call(ctx, http.MethodGet, "/cgi-bin/agent/get", url.Values{"agentid": {b.appAgentID}}, nil, &out); err != nil {
+		return "", err
+	}
+	return out.Name, nil
+}
+
+func (b *WeComBot) ctx() context.Context {
+	b.runMu.Lock()
+	defer b.runMu.Unlock()
+	return b.runCtx
+}
+
+func (b *WeComBot) spawn(fn func()) {
+	b.inflight.Add(1)
+	go func() {
+		defer b.inflight.Done()
+		fn()
+	}()
+}
+
+// markSeen reports whether msgID was already handled, recording it if not.
+func (b *WeComBot) markSeen(msgID string) bool {
+	b.seenMu.Lock()
+	defer b.seenMu.Unlock()
+	if _, dup := b.seen[msgID]; dup {
+		return true
+	}
+	b.seen[msgID] = time.Now()
+	if len(b.seen) > 2000 {
+		cutoff := time.Now().Add(-2 * time.Hour)
+		for k, t := range b.seen {
+			if t.Before(cutoff) {
+				delete(b.seen, k)
+			}
+		}
+	}
+	return false
+}
+
+// ── Callback webhook ──────────────────────────────────────────────────────
+
+// ServeWebhook implements WebhookHandler. GET echoes the decrypted
+// echostr (URL verification); POST decrypts a message, acks with an
+// empty 200 and handles it asynchronously (WeCom retries after 5s).
+func (b *WeComBot) ServeWebhook(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sig, ts, nonce := q.Get("msg_signature"), q.Get("timestamp"), q.Get("nonce")
+
+	if r.Method == http.MethodGet {
+		echo := q.Get("echostr")
+		if !b.crypt.verify(sig, ts, nonce, echo) {
+			log.Printf("[wecom] URL verification rejected agent=%s channel=%s", b.agentID, b.channelID)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		plain, err := b.crypt.decrypt(echo)
+		if err != nil {
+			http.Error(w, "bad echostr", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write(plain)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "read body", http.StatusBadRequest)
+		return
+	}
+	body = bytes.TrimSpace(body)
+	isJSON := len(body) > 0 && body[0] == '{'
+	var env wecomEnvelope
+	if isJSON {
+		err = json.Unmarshal(body, &env)
+	} else {
+		err = xml.Unmarshal(body, &env)
+	}
+	if err != nil || env.Encrypt == "" {
+		http.Error(w, "bad body", http.StatusBadRequest)
+		return
+	}
+	if !b.crypt.verify(sig, ts, nonce, env.Encrypt) {
+		log.Printf("[wecom] webhook rejected agent=%s channel=%s: signature mismatch", b.agentID, b.channelID)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	plain, err := b.crypt.decrypt(env.Encrypt)
+	if err != nil {
+		log.Printf("[wecom] webhook decrypt: %v", err)
+		http.Error(w, "decrypt failed", http.StatusBadRequest)
+		return
+	}
+	ctx := b.ctx()
+	if isJSON {
+		var m wecomBotMessage
+		if err := json.Unmarshal(plain, &m); err == nil && (m.MsgID == "" || !b.markSeen(m.MsgID)) {
+			b.spawn(func() { b.handleBotMessage(ctx, &m) })
+		}
+	} else {
+		var m wecomAppMessage
+		if err := xml.Unmarshal(plain, &m); err == nil && (m.MsgID == "" || !b.markSeen(m.MsgID)) {
+			b.spawn(func() { b.handleAppMessage(ctx, &m) })
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// ── Inbound messages ──────────────────────────────────────────────────────
+
+// handleAppMessage handles a 1:1 message to the app.
+func (b *WeComBot) handleAppMessage(ctx context.Context, m *wecomAppMessage) {
+	if m.FromUserName == "" || m.MsgType == "event" {
+		return // enter_agent, subscribe, ...
+	}
+	var text string
+	var media []MediaInput
+	switch m.MsgType {
+	case "text":
+		text = strings.TrimSpace(m.Content)
+	case "image":
+		text = "[📷 图片]"
+		if data, ct, err := b.downloadMedia(ctx, m.MediaID); err != nil {
+			log.Printf("[wecom] download media=%s: %v", m.MediaID, err)
+			text = "[📷 图片（下载失败）]"
+		} else {
+			media = append(media, MediaInput{Data: data, ContentType: ct, FileName: "image.jpg"})
+		}
+	case "voice":
+		text = "[🎤 语音]"
+	case "video":
+		text = "[🎬 视频]"
+	case "location":
+		text = "[📍 " + m.Label + "]"
+	case "link":
+		text = "[🔗 " + m.Title + "] " + m.URL
+	}
+	if text == "" {
+		return
+	}
+	b.dispatch(ctx, ChatRef{ID: m.FromUserName, Type: "private"}, m.FromUserName, m.MsgID, text, media)
+}
+
+// handleBotMessage handles an AI bot message. Group messages only reach
+// the bot when it is @mentioned; the leading mention is stripped.
+func (b *WeComBot) handleBotMessage(ctx context.Context, m *wecomBotMessage) {
+	userID := m.From.UserID
+	if userID == "" {
+		return
+	}
+	chat := ChatRef{ID: userID, Type: "private"}
+	if m.ChatType == "group" {
+		if m.ChatID == "" {
+			return
+		}
+		chat = ChatRef{ID: m.ChatID, Type: "group"}
+	}
+
+	var parts []string
+	var media []MediaInput
+	addImage := func(img wecomBotImage) {
+		if img.URL == "" || len(media) >= 5 {
+			return
+		}
+		data, ct, err := b.downloadBotImage(ctx, img.URL)
+		if err != nil {
+			log.Printf("[wecom] download bot image: %v", err)
+			return
+		}
+		media = append(media, MediaInput{Data: data, ContentType: ct, FileName: "image.jpg"})
+	}
+	switch m.MsgType {
+	case "text":
+		parts = append(parts, m.Text.Content)
+	case "image":
+		addImage(m.Image)
+	case "mixed":
+		for _, it := range m.Mixed.Items {
+			switch it.MsgType {
+			case "text":
+				parts = append(parts, it.Text.Content)
+			case "image":
+				addImage(it.Image)
+			}
+		}
+	default:
+		return // event / stream refresh
+	}
+	text := strings.TrimSpace(strings.Join(parts, "\n"))
+	if chat.IsGroup() {
+		var mentioned bool
+		if text, mentioned = wecomStripMention(text); !mentioned && len(media) == 0 {
+			return
+		}
+	}
+	if text == "" && len(media) > 0 {
+		text = "[📷 图片]"
+	}
+	if text == "" {
+		return
+	}
+	if m.ResponseURL != "" {
+		b.responses.Store(chat.ID, wecomResponse{URL: m.ResponseURL, Expires: time.Now().Add(wecomResponseTTL)})
+	}
+	b.dispatch(ctx, chat, userID, m.MsgID, text, media)
+}
+
+// wecomStripMention removes the leading "@bot " of a group message (WeCom
+// ends mentions with U+2005) and reports whether there was one.
+func wecomStripMention(text string) (string, bool) {
+	if !strings.HasPrefix(text, "@") {
+		return text, false
+	}
+	if i := strings.IndexAny(text, " \u2005\n"); i >= 0 {
+		return strings.TrimSpace(text[i:]), true
+	}
+	return "", true
+}
+
+// dispatch runs the shared pipeline for one inbound message.
+func (b *WeComBot) dispatch(ctx context.Context, chat ChatRef, userID, msgID, text string, media []MediaInput) {
+	pipe := b.pipeline()
+	sessionID := pipe.SessionID(chat)
+	muVal, _ := b.chatMu.LoadOrStore(sessionID, &sync.Mutex{})
+	mu := muVal.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+
+	name := b.user(ctx, userID).Name
+	sender := Sender{ID: userID, Name: name}
+	if res := pipe.Check(sender, true); res != AccessAllowed {
+		log.Printf("[wecom] access %v — user=%s chat=%s", res, userID, chat.ID)
+		_, _ = b.Send(ctx, chat, b.pairingReply(res, userID), "")
+		return
+	}
+	log.Printf("[wecom] message from user=%s chat=%s text=%q", userID, chat.ID, truncateStr(text, 60))
+
+	finalText := text
+	if chat.IsGroup() {
+		finalText = fmt.Sprintf("[%s]: %s", network.FallbackDisplayName(userID, name, ""), text)
+	}
+	in := InboundMessage{
+		ChannelType: "wecom",
+		ChannelID:   b.channelID,
+		MessageID:   msgID,
+		Chat:        chat,
+		Sender:      sender,
+		Text:        finalText,
+		Media:       media,
+		ExtraContext: []string{fmt.Sprintf("当前企业微信用户信息：userid=%s，姓名=%s，chat_id=%s",
+			userID, name, chat.ID)},
+	}
+	pipe.LogInbound(in, text)
+	pipe.Dispatch(ctx, in)
+}
+
+// pairingReply guides a sender that is not on the allowlist to the panel.
+func (b *WeComBot) pairingReply(res AccessResult, userID string) string {
+	where := "ZyHive 管理面板"
+	if b.panelBaseURL != "" {
+		where = b.panelBaseURL + "/#/agents/" + b.agentID + "/channels"
+	}
+	if res == AccessPairing {
+		return fmt.Sprintf("👋 你好！此应用尚未完成配对，请管理员在以下地址授权（你的企业微信 userid：`%s`）：\n%s", userID, where)
+	}
+	return fmt.Sprintf("👋 你好！你的申请已收到（企业微信 userid：`%s`），等待管理员在以下地址审核：\n%s", userID, where)
+}
+
+// user returns a member's profile (cached; zero value when the app may
+// not read it — apps created after 2022-06 get no names).
+func (b *WeComBot) user(ctx context.Context, userID string) wecomUser {
+	if v, ok := b.users.Load(userID); ok {
+		return v.(wecomUser)
+	}
+	var u wecomUser
+	if err := b.call(ctx, http.MethodGet, "/cgi-bin/user/get", url.Values{"userid": {userID}}, nil, &u); err != nil {
+		log.Printf("[wecom] user/get %s: %v", userID, err)
+	}
+	b.users.Store(userID, u)
+	return u
+}
+
+// fetchAvatar caches a member's avatar in the contact book (once per user
+// per process).
+func (b *WeComBot) fetchAvatar(userID, contactID string) {
+	if _, attempted := b.avatars.LoadOrStore(userID, true); attempted || b.agentDir == "" {
+		return
+	}
+	b.spawn(func() {
+		ctx, cancel := context.WithTimeout(b.ctx(), 30*time.Second)
+		defer cancel()
+		u := b.user(ctx, userID)
+		if u.Avatar == "" {
+			return
+		}
+		data, ct, err := b.fetch(ctx, u.Avatar)
+		if err != nil || len(data) > network.MaxAvatarBytes {
+			log.Printf("[wecom/avatar] download user=%s: %v", userID, err)
+			return
+		}
+		store := network.NewStore(filepath.Join(b.agentDir, "workspace"))
+		if err := store.SaveAvatar(contactID, data, ct); err != nil {
+			log.Printf("[wecom/avatar] save user=%s: %v", userID, err)
+		}
+	})
+}
+
+// downloadMedia fetches a temporary media file (GET /cgi-bin/media/get).
+func (b *WeComBot) downloadMedia(ctx context.Context, mediaID string) ([]byte, string, error) {
+	tok, err := b.accessToken(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	data, ct, err := b.fetch(ctx, b.apiBase+"/cgi-bin/media/get?"+url.Values{"access_token": {tok}, "media_id": {mediaID}}.Encode())
+	if err != nil {
+		return nil, "", err
+	}
+	if ct == "application/json" || ct == "text/plain" {
+		// Errors come back as JSON with HTTP 200.
+		return nil, "", fmt.Errorf("media/get: %s", truncateStr(string(data), 200))
+	}
+	return data, ct, nil
+}
+
+// downloadBotImage fetches an AI bot image and decrypts it.
+func (b *WeComBot) downloadBotImage(ctx context.Context, imageURL string) ([]byte, string, error) {
+	data, _, err := b.fetch(ctx, imageURL)
+	if err != nil {
+		return nil, "", err
+	}
+	plain, err := b.crypt.decryptRaw(data)
+	if err != nil {
+		return nil, "", err
+	}
+	ct := http.DetectContentType(plain)
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	return plain, ct, nil
+}
+
+// fetch GETs a URL, capped at wecomMaxFileBytes.
+func (b *WeComBot) fetch(ctx context.Context, fileURL string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, wecomMaxFileBytes))
+	if err != nil {
+		return nil, "", err
+	}
+	ct := resp.Header.Get("Content-Type")
+	if i := strings.IndexByte(ct, ';'); i >= 0 {
+		ct = ct[:i]
+	}
+	if ct == "" || ct == "application/octet-stream" {
+		ct = http.DetectContentType(data)
+		if i := strings.IndexByte(ct, ';'); i >= 0 {
+			ct = ct[:i]
+		}
+	}
+	return data, ct, nil
+}
+
+// ── Outbound ──────────────────────────────────────────────────────────────
+
+// wecomChunks splits markdown into pieces of at most max bytes, at line
+// breaks where possible.
+func wecomChunks(text string, max int) []string {
+	var out []string
+	for len(text) > max {
+		cut := strings.LastIndexByte(text[:max], '\n')
+		if cut <= 0 {
+			cut = len(strings.ToValidUTF8(text[:max], ""))
+		}
+		out = append(out, text[:cut])
+		text = strings.TrimLeft(text[cut:], "\n")
+	}
+	if text != "" {
+		out = append(out, text)
+	}
+	return out
+}
+
+// takeResponse returns (and consumes) the chat's AI bot response_url.
+func (b *WeComBot) takeResponse(chatID string) (string, bool) {
+	v, ok := b.responses.LoadAndDelete(chatID)
+	if !ok {
+		return "", false
+	}
+	r := v.(wecomResponse)
+	return r.URL, time.Now().Before(r.Expires)
+}
+
+// sendMessage sends one app message: /cgi-bin/appchat/send for groups,
+// /cgi-bin/message/send for users (touser "a|b|c").
+func (b *WeComBot) sendMessage(ctx context.Context, chat ChatRef, msgType string, content any) (string, error) {
+	body := map[string]any{"msgtype": msgType, msgType: content}
+	path := "/cgi-bin/message/send"
+	if chat.IsGroup() {
+		path = "/cgi-bin/appchat/send"
+		body["chatid"] = chat.ID
+	} else {
+		body["touser"] = chat.ID
+		body["agentid"] = b.appAgentID
+	}
+	var out struct {
+		MsgID string `json:"msgid"`
+	}
+	if err := b.call(ctx, http.MethodPost, path, nil, body, &out); err != nil {
+		return "", err
+	}
+	return out.MsgID, nil
+}
+
+// uploadMedia uploads a temporary file (POST /cgi-bin/media/upload).
+func (b *WeComBot) uploadMedia(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	fw, err := mw.CreateFormFile("media", filepath.Base(path))
+	if err != nil {
+		return "", err
+	}
+	if _, err := fw.Write(data); err != nil {
+		return "", err
+	}
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	var out struct {
+		MediaID string `json:"media_id"`
+	}
+	err = b.callRaw(ctx, http.MethodPost, "/cgi-bin/media/upload", url.Values{"type": {"file"}}, mw.FormDataContentType(), buf.Bytes(), &out)
+	return out.MediaID, err
+}
+
+// ── HTTP helpers ──────────────────────────────────────────────────────────
+
+// accessToken returns the cached access_token, refreshing it 5 minutes
+// before expiry (GET /cgi-bin/gettoken).
+func (b *WeComBot) accessToken(ctx context.Context) (string, error) {
+	b.tokMu.Lock()
+	defer b.tokMu.Unlock()
+	if b.token != "" && time.Now().Before(b.tokenExp) {
+		return b.token, nil
+	}
+	u := b.apiBase + "/cgi-bin/gettoken?" + url.Values{"corpid": {b.corpID}, "corpsecret": {b.secret}}.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return "", err
+	}
+	var out struct {
+		ErrCode     int    `json:"errcode"`
+		ErrMsg      string `json:"errmsg"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := b.do(req, &out); err != nil {
+		return "", err
+	}
+	if out.ErrCode != 0 || out.AccessToken == "" {
+		return "", fmt.Errorf("wecom gettoken: %d %s", out.ErrCode, out.ErrMsg)
+	}
+	if out.ExpiresIn <= 0 {
+		out.ExpiresIn = 7200
+	}
+	b.token = out.AccessToken
+	b.tokenExp = time.Now().Add(time.Duration(out.ExpiresIn-300) * time.Second)
+	return b.token, nil
+}
+
+// dropToken forgets a token the API rejected.
+func (b *WeComBot) dropToken(tok string) {
+	b.tokMu.Lock()
+	if b.token == tok {
+		b.token = ""
+	}
+	b.tokMu.Unlock()
+}
+
+// call sends a JSON request (body nil = none) with the access token.
+func (b *WeComBot) call(ctx context.Context, method, path string, query url.Values, body, out any) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	return b.callRaw(ctx, method, path, query, "application/json", data, out)
+}
+
+// callRaw performs one API call and checks errcode; a rejected token is
+// refreshed and the call retried once.
+func (b *WeComBot) callRaw(ctx context.Context, method, path string, query url.Values, contentType string, data []byte, out any) error {
+	for attempt := 0; ; attempt++ {
+		tok, err := b.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		q := url.Values{}
+		for k, v := range query {
+			q[k] = v
+		}
+		q.Set("access_token", tok)
+		var rd io.Reader
+		if data != nil {
+			rd = bytes.NewReader(data)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, b.apiBase+path+"?"+q.Encode(), rd)
+		if err != nil {
+			return err
+		}
+		if data != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+		var raw json.RawMessage
+		if err := b.do(req, &raw); err != nil {
+			return err
+		}
+		var status struct {
+			ErrCode int    `json:"errcode"`
+			ErrMsg  string `json:"errmsg"`
+		}
+		_ = json.Unmarshal(raw, &status)
+		if (status.ErrCode == wecomErrInvalidToken || status.ErrCode == wecomErrTokenExpired) && attempt == 0 {
+			b.dropToken(tok)
+			continue
+		}
+		if status.ErrCode != 0 {
+			return fmt.Errorf("wecom %s: %d %s", path, status.ErrCode, status.ErrMsg)
+		}
+		if out != nil {
+			return json.Unmarshal(raw, out)
+		}
+		return nil
+	}
+}
+
+// postJSON posts to an absolute URL without a token (AI bot response_url).
+func (b *WeComBot) postJSON(ctx context.Context, u string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	var out struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := b.do(req, &out); err != nil {
+		return err
+	}
+	if out.ErrCode != 0 {
+		return fmt.Errorf("wecom response_url: %d %s", out.ErrCode, out.ErrMsg)
+	}
+	return nil
+}
+
+func (b *WeComBot) do(req *http.Request, out any) error {
+	where := req.URL.Path
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("wecom %s: %w", where, err)
+	}
+	defer resp.Body.Close()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("wecom %s: %w", where, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("wecom %s: HTTP %d: %s", where, resp.StatusCode, truncateStr(string(raw), 200))
+	}
+	if out != nil && len(raw) > 0 {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// TestWeComApp verifies corpId + secret and returns the app's name.
+func TestWeComApp(ctx context.Context, corpID, appAgentID, secret string) (string, error) {
+	if corpID == "" || appAgentID == "" || secret == "" {
+		return "", errors.New("wecom corpId, agentId and secret are required")
+	}
+	b := &WeComBot{corpID: corpID, appAgentID: appAgentID, secret: secret, apiBase: wecomAPIBase, client: netguard.NewSafeClient(8 * time.Second)}
+	return b.appName(ctx)
+}
@@ -0,0 +1,124 @@
+// pkg/channel/wecom_crypto.go — WeCom callback encryption ("消息加解密"):
+// AES-256-CBC with the key base64(EncodingAESKey + "="), IV = key[:16],
+// PKCS#7 padding to 32 bytes. Plaintext = 16 random bytes | 4-byte
+// big-endian length | msg | receiveid. Requests are signed:
+// msg_signature = sha1(sort(token, timestamp, nonce, encrypt)).
+package channel
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// wecomBlockSize is the PKCS#7 block size WeCom pads to.
+const wecomBlockSize = 32
+
+type wecomCrypt struct {
+	token     string
+	key       []byte
+	receiveID string // corpId for app callbacks; "" for AI-bot callbacks
+}
+
+// newWecomCrypt validates the 43-character EncodingAESKey.
+func newWecomCrypt(token, encodingAESKey, receiveID string) (*wecomCrypt, error) {
+	if len(encodingAESKey) != 43 {
+		return nil, errors.New("wecom: encodingAESKey must be 43 characters")
+	}
+	key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+	if err != nil || len(key) != 32 {
+		return nil, errors.New("wecom: invalid encodingAESKey")
+	}
+	return &wecomCrypt{token: token, key: key, receiveID: receiveID}, nil
+}
+
+// signature computes msg_signature for a request.
+func (c *wecomCrypt) signature(timestamp, nonce, encrypted string) string {
+	parts := []string{c.token, timestamp, nonce, encrypted}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+// verify checks msg_signature in constant time.
+func (c *wecomCrypt) verify(sig, timestamp, nonce, encrypted string) bool {
+	want := c.signature(timestamp, nonce, encrypted)
+	return subtle.ConstantTimeCompare([]byte(want), []byte(sig)) == 1
+}
+
+// decrypt opens an Encrypt field and checks its receiveid (either the
+// corpId or empty: AI-bot callbacks carry no receiveid).
+func (c *wecomCrypt) decrypt(encrypted string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("wecom: decode: %w", err)
+	}
+	plain, err := c.decryptRaw(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(plain) < 20 {
+		return nil, errors.New("wecom: plaintext too short")
+	}
+	n := binary.BigEndian.Uint32(plain[16:20])
+	if uint64(n) > uint64(len(plain)-20) {
+		return nil, errors.New("wecom: bad message length")
+	}
+	msg, receiveID := plain[20:20+n], string(plain[20+n:])
+	if receiveID != "" && receiveID != c.receiveID {
+		return nil, errors.New("wecom: receiveid mismatch")
+	}
+	return msg, nil
+}
+
+// decryptRaw AES-decrypts and unpads data (also used for AI-bot images,
+// which are downloaded encrypted with the same key).
+func (c *wecomCrypt) decryptRaw(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("wecom: ciphertext is not a multiple of the block size")
+	}
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, c.key[:aes.BlockSize]).CryptBlocks(plain, data)
+	pad := int(plain[len(plain)-1])
+	if pad < 1 || pad > wecomBlockSize || pad > len(plain) {
+		return nil, errors.New("wecom: bad padding")
+	}
+	return plain[:len(plain)-pad], nil
+}
+
+// encrypt seals msg for receiveID — the inverse of decrypt.
+func (c *wecomCrypt) encrypt(msg []byte, receiveID string) (string, error) {
+	var buf bytes.Buffer
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	buf.Write(random)
+	_ = binary.Write(&buf, binary.BigEndian, uint32(len(msg)))
+	buf.Write(msg)
+	buf.WriteString(receiveID)
+	pad := wecomBlockSize - buf.Len()%wecomBlockSize
+	buf.Write(bytes.Repeat([]byte{byte(pad)}, pad))
+
+	block, err := aes.NewCipher(c.key)
+	if err != nil {
+		return "", err
+	}
+	out := make([]byte, buf.Len())
+	cipher.NewCBCEncrypter(block, c.key[:aes.BlockSize]).CryptBlocks(out, buf.Bytes())
+	return base64.StdEncoding.EncodeToString(out), nil
+}
@@ -0,0 +1,149 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WeComBot implements Driver, Notifier and WebhookHandler.
+var (
+	_ Driver         = (*WeComBot)(nil)
+	_ Notifier       = (*WeComBot)(nil)
+	_ WebhookHandler = (*WeComBot)(nil)
+)
+
+// Member userids are strings, so WeCom keeps its pending / approved users
+// in the string-keyed stores. The app secret identifies the app.
+func init() {
+	RegisterDriver(DriverSpec{
+		Type:      "wecom",
+		Required:  []string{"corpId", "agentId", "secret", "token", "encodingAESKey"},
+		UniqueKey: "secret",
+		New:       newWeComDriver,
+		Test: func(ctx context.Context, cfg map[string]string) (string, error) {
+			return TestWeComApp(ctx, cfg["corpId"], cfg["agentId"], cfg["secret"])
+		},
+	})
+}
+
+func newWeComDriver(env DriverEnv) (Driver, error) {
+	var pending *PendingStoreStr
+	if dir := env.PendingDir(); dir != "" {
+		pending = NewPendingStoreStr(dir, env.ChannelID)
+	}
+	getAllowFrom := env.AllowFrom
+	if getAllowFrom == nil {
+		getAllowFrom = func() []string { return nil }
+	}
+	c := env.Config
+	bot, err := NewWeComBotWithStream(c["corpId"], c["agentId"], c["secret"], c["token"], c["encodingAESKey"],
+		env.AgentID, env.AgentDir, env.ChannelID, getAllowFrom, env.Stream, pending)
+	if err != nil {
+		return nil, err
+	}
+	bot.SetPanelBaseURL(env.PanelBaseURL)
+	bot.SetOnConnected(env.OnConnected)
+	return bot, nil
+}
+
+// pipeline returns the shared message path bound to this bot.
+func (b *WeComBot) pipeline() *Pipeline {
+	return &Pipeline{
+		Env: DriverEnv{
+			AgentID:      b.agentID,
+			AgentDir:     b.agentDir,
+			ChannelID:    b.channelID,
+			Stream:       b.streamFunc,
+			AllowFrom:    b.getAllowFrom,
+			PanelBaseURL: b.panelBaseURL,
+		},
+		Driver:       b,
+		Pending:      b.pendingStore.Recorder(),
+		OnNewContact: b.fetchAvatar,
+	}
+}
+
+// Type implements Driver.
+func (b *WeComBot) Type() string { return "wecom" }
+
+// Capabilities implements Driver. App messages cannot be edited, so each
+// reply is sent once, complete.
+func (b *WeComBot) Capabilities() Capabilities {
+	return Capabilities{Files: true}
+}
+
+// Send implements Driver: markdown, split at the 2048-byte limit. The
+// first piece uses the AI bot's response_url when the chat has an unused
+// one. Returns the last message id.
+func (b *WeComBot) Send(ctx context.Context, chat ChatRef, text, _ string) (string, error) {
+	var lastID string
+	for i, part := range wecomChunks(text, wecomMaxMarkdown) {
+		md := map[string]string{"content": part}
+		if i == 0 {
+			if u, ok := b.takeResponse(chat.ID); ok {
+				if err := b.postJSON(ctx, u, map[string]any{"msgtype": "markdown", "markdown": md}); err == nil {
+					continue
+				}
+			}
+		}
+		id, err := b.sendMessage(ctx, chat, "markdown", md)
+		if err != nil {
+			return lastID, err
+		}
+		lastID = id
+	}
+	return lastID, nil
+}
+
+// Edit implements Driver (unsupported).
+func (b *WeComBot) Edit(ctx context.Context, chat ChatRef, msgID, text string) error {
+	return errors.New("wecom: edit not supported")
+}
+
+// Typing implements Driver (no-op).
+func (b *WeComBot) Typing(ctx context.Context, chat ChatRef) error { return nil }
+
+// SendFile implements Driver: upload as temporary media, then a file message.
+func (b *WeComBot) SendFile(ctx context.Context, chat ChatRef, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("read file: %w", err)
+	}
+	mediaID, err := b.uploadMedia(ctx, path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := b.sendMessage(ctx, chat, "file", map[string]string{"media_id": mediaID}); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("✅ 文件 %s 已发送（%d 字节）", filepath.Base(path), info.Size()), nil
+}
+
+// ProactiveSend implements Driver: one app message to every allowlisted
+// userid (touser "a|b|c").
+func (b *WeComBot) ProactiveSend(text string) error {
+	var users []string
+	for _, id := range b.getAllowFrom() {
+		if id = strings.TrimSpace(id); id != "" {
+			users = append(users, id)
+		}
+	}
+	if len(users) == 0 {
+		return errors.New("wecom: no recipient in allowedFrom")
+	}
+	_, err := b.Send(b.ctx(), ChatRef{ID: strings.Join(users, "|"), Type: "private"}, text, "")
+	return err
+}
+
+// Notify runs the agent on prompt in the chat's session and posts the
+// reply. A chat without Type is a member (chat.ID a userid).
+func (b *WeComBot) Notify(ctx context.Context, chat ChatRef, prompt string) error {
+	if chat.Type == "" {
+		chat.Type = "private"
+	}
+	return b.pipeline().Notify(ctx, chat, prompt)
+}
@@ -0,0 +1,264 @@
+package channel
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testAESKey is a valid 43-character EncodingAESKey.
+var testAESKey = strings.TrimRight(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef")), "=")
+
+// fakeWeCom is a minimal qyapi.weixin.qq.com stand-in.
+type fakeWeCom struct {
+	t   *testing.T
+	srv *httptest.Server
+	mu  sync.Mutex
+	// calls records requests as "METHOD /path" with decoded JSON bodies and
+	// the query under "_query".
+	calls []discordCall
+	// expireOnce makes the next message/send fail with 42001.
+	expireOnce bool
+	tokens     int
+}
+
+func newFakeWeCom(t *testing.T) *fakeWeCom {
+	f := &fakeWeCom{t: t}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.srv.Close)
+	return f
+}
+
+func (f *fakeWeCom) serve(w http.ResponseWriter, r *http.Request) {
+	route := r.Method + " " + r.URL.Path
+	body := map[string]any{}
+	raw, _ := io.ReadAll(r.Body)
+	_ = json.Unmarshal(raw, &body)
+	body["_query"] = r.URL.RawQuery
+	f.mu.Lock()
+	f.calls = append(f.calls, discordCall{Route: route, Body: body})
+	expire := false
+	if route == "POST /cgi-bin/message/send" && f.expireOnce {
+		f.expireOnce, expire = false, true
+	}
+	if route == "GET /cgi-bin/gettoken" {
+		f.tokens++
+	}
+	tokens := f.tokens
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case route == "GET /cgi-bin/gettoken":
+		_, _ = io.WriteString(w, `{"errcode":0,"access_token":"AT`+string(rune('0'+tokens))+`","expires_in":7200}`)
+	case expire:
+		_, _ = io.WriteString(w, `{"errcode":42001,"errmsg":"access_token expired"}`)
+	case route == "GET /cgi-bin/user/get":
+		_, _ = io.WriteString(w, `{"errcode":0,"name":"张三"}`)
+	case route == "GET /cgi-bin/agent/get":
+		_, _ = io.WriteString(w, `{"errcode":0,"name":"智能助手"}`)
+	default:
+		_, _ = io.WriteString(w, `{"errcode":0,"errmsg":"ok","msgid":"mid"}`)
+	}
+}
+
+func (f *fakeWeCom) routes(route string) []discordCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []discordCall
+	for _, c := range f.calls {
+		if c.Route == route {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// wait returns the requests to route once there are at least n.
+func (f *fakeWeCom) wait(route string, n int) []discordCall {
+	f.t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		if got := f.routes(route); len(got) >= n {
+			return got
+		}
+		if time.Now().After(deadline) {
+			f.t.Fatalf("fewer than %d %s; calls = %+v", n, route, f.calls)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func newTestWeComBot(t *testing.T, f *fakeWeCom, allow []string, reply string, runs chan<- testRun) *WeComBot {
+	t.Helper()
+	stream := func(_ context.Context, _, text, sessionID string, media []MediaInput, _ FileSenderFunc, _ ...string) (<-chan StreamEvent, error) {
+		if runs != nil {
+			runs <- testRun{session: sessionID, text: text, media: media}
+		}
+		return streamOf(StreamEvent{Type: "text_delta", Text: reply}, StreamEvent{Type: "done"}), nil
+	}
+	b, err := NewWeComBotWithStream("corp1", "1000002", "sec", "tok", testAESKey, "a1", t.TempDir(), "wecom-1",
+		func() []string { return allow }, stream, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.apiBase = f.srv.URL
+	b.client = f.srv.Client()
+	t.Cleanup(b.inflight.Wait) // runs write into agentDir until they finish
+	return b
+}
+
+// signedRequest builds a callback request with a valid msg_signature.
+func signedRequest(b *WeComBot, method, body, encrypted string) *http.Request {
+	q := url.Values{"timestamp": {"1700000000"}, "nonce": {"n1"}}
+	q.Set("msg_signature", b.crypt.signature("1700000000", "n1", encrypted))
+	if method == http.MethodGet {
+		q.Set("echostr", encrypted)
+	}
+	return httptest.NewRequest(method, "/channels/a1/wecom-1/webhook?"+q.Encode(), strings.NewReader(body))
+}
+
+func TestWeComCrypt(t *testing.T) {
+	c, err := newWecomCrypt("tok", testAESKey, "corp1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := c.encrypt([]byte("<xml>hi</xml>"), "corp1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := c.decrypt(enc); err != nil || string(msg) != "<xml>hi</xml>" {
+		t.Errorf("decrypt = %q, %v", msg, err)
+	}
+	// AI bot callbacks carry an empty receiveid; another corp's does not pass.
+	if enc, _ := c.encrypt([]byte("{}"), ""); enc != "" {
+		if _, err := c.decrypt(enc); err != nil {
+			t.Errorf("empty receiveid: %v", err)
+		}
+	}
+	if enc, _ := c.encrypt([]byte("{}"), "corp2"); enc != "" {
+		if _, err := c.decrypt(enc); err == nil {
+			t.Error("foreign receiveid accepted")
+		}
+	}
+	sig := c.signature("1", "n", enc)
+	if !c.verify(sig, "1", "n", enc) || c.verify(sig, "2", "n", enc) {
+		t.Error("signature verification")
+	}
+	if _, err := newWecomCrypt("tok", "short", "corp1"); err == nil {
+		t.Error("short key accepted")
+	}
+}
+
+func TestWeComAppCallback(t *testing.T) {
+	f := newFakeWeCom(t)
+	runs := make(chan testRun, 4)
+	b := newTestWeComBot(t, f, []string{"zhangsan"}, "**收到**", runs)
+
+	// URL verification echoes the decrypted echostr.
+	echo, _ := b.crypt.encrypt([]byte("echo-123"), "corp1")
+	w := httptest.NewRecorder()
+	b.ServeWebhook(w, signedRequest(b, http.MethodGet, "", echo))
+	if w.Code != http.StatusOK || w.Body.String() != "echo-123" {
+		t.Errorf("verify = %d %q", w.Code, w.Body.String())
+	}
+
+	plain := `<xml><ToUserName><![CDATA[corp1]]></ToUserName><FromUserName><![CDATA[zhangsan]]></FromUserName>` +
+		`<MsgType><![CDATA[text]]></MsgType><Content><![CDATA[你好]]></Content><MsgId>42</MsgId><AgentID>1000002</AgentID></xml>`
+	enc, _ := b.crypt.encrypt([]byte(plain), "corp1")
+	body := `<xml><ToUserName><![CDATA[corp1]]></ToUserName><Encrypt><![CDATA[` + enc + `]]></Encrypt></xml>`
+
+	// A tampered signature is rejected.
+	bad := signedRequest(b, http.MethodPost, body, enc)
+	q := bad.URL.Query()
+	q.Set("msg_signature", "0000")
+	bad.URL.RawQuery = q.Encode()
+	w = httptest.NewRecorder()
+	b.ServeWebhook(w, bad)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("bad signature = %d", w.Code)
+	}
+
+	f.expireOnce = true
+	w = httptest.NewRecorder()
+	b.ServeWebhook(w, signedRequest(b, http.MethodPost, body, enc))
+	if w.Code != http.StatusOK {
+		t.Fatalf("callback = %d", w.Code)
+	}
+	run := <-runs
+	if run.session != "wecom-zhangsan" || run.text != "你好" {
+		t.Errorf("run = %+v", run)
+	}
+	// The rejected token was refreshed and the send retried once.
+	sends := f.wait("POST /cgi-bin/message/send", 2)
+	last := sends[len(sends)-1]
+	md, _ := last.Body["markdown"].(map[string]any)
+	if last.Body["touser"] != "zhangsan" || last.Body["agentid"] != "1000002" || md["content"] != "**收到**" ||
+		!strings.Contains(last.Body["_query"].(string), "access_token=AT2") {
+		t.Errorf("send = %+v", last.Body)
+	}
+
+	// WeCom retries an unacked callback: the same MsgId is handled once.
+	w = httptest.NewRecorder()
+	b.ServeWebhook(w, signedRequest(b, http.MethodPost, body, enc))
+	b.inflight.Wait()
+	select {
+	case extra := <-runs:
+		t.Errorf("duplicate run %+v", extra)
+	default:
+	}
+}
+
+func TestWeComBotGroupMention(t *testing.T) {
+	f := newFakeWeCom(t)
+	runs := make(chan testRun, 4)
+	long := strings.Repeat("行\n", 900) // > 2048 bytes: split in two
+	b := newTestWeComBot(t, f, []string{"zhangsan"}, long, runs)
+
+	post := func(msg map[string]any) {
+		raw, _ := json.Marshal(msg)
+		enc, _ := b.crypt.encrypt(raw, "")
+		body, _ := json.Marshal(map[string]string{"encrypt": enc})
+		w := httptest.NewRecorder()
+		b.ServeWebhook(w, signedRequest(b, http.MethodPost, string(body), enc))
+		if w.Code != http.StatusOK {
+			t.Fatalf("bot callback = %d", w.Code)
+		}
+	}
+	msg := func(id, content string) map[string]any {
+		return map[string]any{
+			"msgid": id, "chatid": "chat1", "chattype": "group", "from": map[string]string{"userid": "zhangsan"},
+			"response_url": f.srv.URL + "/resp/" + id, "msgtype": "text", "text": map[string]string{"content": content},
+		}
+	}
+	post(msg("b1", "大家好"))      // no mention: ignored
+	post(msg("b2", "@助手 总结一下")) // mention
+	run := <-runs
+	if run.session != "wecom-chat1" || run.text != "[张三]: 总结一下" {
+		t.Errorf("group run = %+v", run)
+	}
+	b.inflight.Wait()
+	select {
+	case extra := <-runs:
+		t.Errorf("unexpected run %+v", extra)
+	default:
+	}
+	// The first chunk goes to the response_url, the rest via appchat/send.
+	resp := f.wait("POST /resp/b2", 1)[0]
+	md, _ := resp.Body["markdown"].(map[string]any)
+	first, _ := md["content"].(string)
+	rest := f.wait("POST /cgi-bin/appchat/send", 1)[0]
+	md2, _ := rest.Body["markdown"].(map[string]any)
+	second, _ := md2["content"].(string)
+	if len(first) > wecomMaxMarkdown || rest.Body["chatid"] != "chat1" || first+"\n"+second != strings.TrimRight(long, "\n") {
+		t.Errorf("chunks = %d + %d bytes, chat %v", len(first), len(second), rest.Body["chatid"])
+	}
+}
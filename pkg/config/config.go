@@ -29,16 +29,18 @@ func mutexForConfig(cfg *Config) *sync.RWMutex {
 // Config is the top-level configuration.
 // Models/Channels/Tools/Skills are global registries; agents reference them by ID.
 type Config struct {
-	ConfigVersion int             `json:"configVersion,omitempty"` // schema version; 0 = pre-versioning
-	Gateway       GatewayConfig   `json:"gateway"`
-	Agents        AgentsConfig    `json:"agents"`
-	Providers     []ProviderEntry `json:"providers,omitempty"` // API Key 注册表（每个厂商一条）
-	Models        []ModelEntry    `json:"models"`              // global model registry
-	Channels      []ChannelEntry  `json:"channels"`            // global channel registry
-	Tools         []ToolEntry     `json:"tools"`               // global capability registry (API keys etc.)
-	Skills        []SkillEntry    `json:"skills"`              // installed skills
-	ACPAgents     []ACPAgentEntry `json:"acpAgents,omitempty"` // external coding-agent CLIs
-	Auth          AuthConfig      `json:"auth"`
+	ConfigVersion int              `json:"configVersion,omitempty"` // schema version; 0 = pre-versioning
+	Gateway       GatewayConfig    `json:"gateway"`
+	Agents        AgentsConfig     `json:"agents"`
+	Providers     []ProviderEntry  `json:"providers,omitempty"`  // API Key 注册表（每个厂商一条）
+	Models        []ModelEntry     `json:"models"`               // global model registry
+	Channels      []ChannelEntry   `json:"channels"`             // global channel registry
+	Tools         []ToolEntry      `json:"tools"`                // global capability registry (API keys etc.)
+	Skills        []SkillEntry     `json:"skills"`               // installed skills
+	ACPAgents     []ACPAgentEntry  `json:"acpAgents,omitempty"`  // external coding-agent CLIs
+	MCPServers    []MCPServerEntry `json:"mcpServers,omitempty"` // external MCP tool servers
+	Auth          AuthConfig       `json:"auth"`
+	MCPServe      MCPServeConfig   `json:"mcpServe,omitempty"` // ZyHive as an MCP server (aipanel mcp / POST /mcp)
 	// ToolPolicyRaw is stored as raw JSON and interpreted by the tools package to avoid import cycles.
 	ToolPolicyRaw json.RawMessage `json:"toolPolicy,omitempty"` // global tool allow/deny/profile
 
@@ -53,6 +55,11 @@ type Config struct {
 	// enables AIMD per-provider. See pkg/llm/throttle.go.
 	Throttle ThrottleConfig `json:"throttle,omitempty"`
 
+	// Tracing — run-level spans (run / llm / tool / subagent / cron / channel)
+	// persisted to {agentsDir}/.traces and optionally exported via OTLP/HTTP.
+	// On by default; see pkg/tracing.
+	Tracing TracingConfig `json:"tracing,omitempty"`
+
 	// Aiteam — Phase 3 P3-S0: optional experimental subsystem config.
 	// Only consulted when ZYHIVE_EXPERIMENTAL_* env flags are set.
 	Aiteam AiteamConfig `json:"aiteam,omitempty"`
@@ -123,6 +130,26 @@ type ThrottleProviderConfig struct {
 	MaxBackoffMs int `json:"max_backoff_ms,omitempty"`
 }
 
+// TracingConfig configures pkg/tracing.
+//
+//	{
+//	  "tracing": {
+//	    "retention_days": 7,
+//	    "otlp_endpoint": "http://localhost:4318",
+//	    "otlp_headers": {"Authorization": "Bearer ..."}
+//	  }
+//	}
+//
+// Spans are always written to local JSONL unless Disabled. OTLPEndpoint
+// (base URL or full /v1/traces URL) additionally ships them to a collector.
+type TracingConfig struct {
+	Disabled      bool              `json:"disabled,omitempty"`
+	RetentionDays int               `json:"retention_days,omitempty"` // 0 = 7 days
+	OTLPEndpoint  string            `json:"otlp_endpoint,omitempty"`
+	OTLPHeaders   map[string]string `json:"otlp_headers,omitempty"`
+	ServiceName   string            `json:"service_name,omitempty"` // default "zyhive"
+}
+
 // ProviderEntry 代表一个大模型服务商的凭据配置。
 // 一个厂商只需配置一次 APIKey，旗下所有模型共享使用。
 type ProviderEntry struct {
@@ -133,6 +160,20 @@ type ProviderEntry struct {
 	BaseURL    string `json:"baseUrl,omitempty"`    // 留空 = 使用 provider 默认地址
 	EmbedModel string `json:"embedModel,omitempty"` // 覆盖 embedding 默认模型（如 nomic-embed-text）
 	Status     string `json:"status"`               // "ok" | "error" | "untested"
+	// Pricing 覆盖全局价格表中经由该 provider 调用的模型价格（协议价、自建网关等）。
+	Pricing []PriceOverride `json:"pricing,omitempty"`
+}
+
+// PriceOverride 是一条 per-provider 价格（每百万 tokens）。字段语义同 usage.Price：
+// Match 为模型名子串（空 = 该 provider 的所有模型），CacheRead/CacheWrite 为 0 时按 Input 计。
+type PriceOverride struct {
+	Match         string  `json:"match,omitempty"`
+	Input         float64 `json:"input"`
+	Output        float64 `json:"output"`
+	CacheRead     float64 `json:"cacheRead,omitempty"`
+	CacheWrite    float64 `json:"cacheWrite,omitempty"`
+	Currency      string  `json:"currency,omitempty"`      // 空 = 价格表货币（USD）
+	EffectiveFrom string  `json:"effectiveFrom,omitempty"` // YYYY-MM-DD，空 = 一直有效
 }
 
 type GatewayConfig struct {
@@ -184,7 +225,7 @@ type AgentsConfig struct {
 type ModelEntry struct {
 	ID            string `json:"id"`
 	Name          string `json:"name"`
-	Provider      string `json:"provider"`             // "anthropic" | "openai" | "deepseek" | "openrouter" | "custom"
+	Provider      string `json:"provider"`             // "anthropic" | "openai" | "deepseek" | "openrouter" | "gemini" | "custom"
 	Model         string `json:"model"`                // "claude-sonnet-4-6"
 	ProviderID    string `json:"providerId,omitempty"` // 引用 ProviderEntry.ID（优先使用 provider 的 apiKey）
 	APIKey        string `json:"apiKey,omitempty"`     // 兼容旧配置；新建模型用 ProviderID
@@ -192,6 +233,58 @@ type ModelEntry struct {
 	IsDefault     bool   `json:"isDefault"`
 	Status        string `json:"status"`                  // "ok" | "error" | "untested"
 	SupportsTools *bool  `json:"supportsTools,omitempty"` // nil=自动判断; true/false=手动指定
+	// Fallbacks 是按顺序尝试的备用模型 ID（引用其它 ModelEntry.ID）。
+	// 主模型过载 / 鉴权失败且尚未输出任何内容时，依次切换到下一个。
+	Fallbacks []string `json:"fallbacks,omitempty"`
+	// Generation 是该模型的默认采样参数；成员级 Generation 逐项覆盖。
+	Generation *GenerationParams `json:"generation,omitempty"`
+	// Capabilities 覆盖内置能力表（上下文窗口、最大输出、视觉等）；nil = 按模型名推断。
+	Capabilities *CapabilityOverrides `json:"capabilities,omitempty"`
+	// API 选择 OpenAI 接口："chat"（默认，/chat/completions）| "responses"（/responses，
+	// 支持推理摘要；o1-pro / o3-pro / codex 等仅此接口可用）。仅 provider=openai 生效。
+	API string `json:"api,omitempty"`
+	// ChainResponses 让 Responses 接口在工具循环中用 previous_response_id 续接
+	// （store=true，由 OpenAI 保存响应 30 天），只发送新增的工具结果；默认每轮发送完整历史。
+	ChainResponses bool `json:"chainResponses,omitempty"`
+}
+
+// Model API values (ModelEntry.API).
+const (
+	ModelAPIChat      = "chat"
+	ModelAPIResponses = "responses"
+)
+
+// GenerationParams 是可由模型 / 成员设置默认值的采样与推理参数。
+// nil / 零值 = 使用 provider 默认。
+type GenerationParams struct {
+	Temperature    *float64 `json:"temperature,omitempty"`
+	TopP           *float64 `json:"topP,omitempty"`
+	Stop           []string `json:"stop,omitempty"`
+	ThinkingBudget int      `json:"thinkingBudget,omitempty"` // >0 开启扩展思考 / reasoning
+}
+
+// MergeGeneration layers over on top of base field by field (over wins where
+// set). Either may be nil; the result is never nil.
+func MergeGeneration(base, over *GenerationParams) *GenerationParams {
+	out := &GenerationParams{}
+	for _, g := range []*GenerationParams{base, over} {
+		if g == nil {
+			continue
+		}
+		if g.Temperature != nil {
+			out.Temperature = g.Temperature
+		}
+		if g.TopP != nil {
+			out.TopP = g.TopP
+		}
+		if len(g.Stop) > 0 {
+			out.Stop = g.Stop
+		}
+		if g.ThinkingBudget > 0 {
+			out.ThinkingBudget = g.ThinkingBudget
+		}
+	}
+	return out
 }
 
 // ResolveCredentials 从模型或关联 provider 中取出 (apiKey, baseURL)。
@@ -214,34 +307,17 @@ func firstNonEmpty(a, b string) string {
 	return b
 }
 
-// noToolPatterns 是已知不支持工具调用的模型名称关键词（子串匹配，忽略大小写）。
-var noToolPatterns = []string{
-	"reasoner",   // deepseek-reasoner
-	"o1-mini",    // openai o1-mini
-	"o1-preview", // openai o1-preview
-	"o1-2024",    // openai o1 系列
-}
-
 // ModelSupportsTools 判断某个 ModelEntry 是否支持工具调用。
-// 优先使用手动配置，其次按模型名自动判断。
+// 优先使用手动配置（supportsTools，其次 capabilities.tools），再查内置能力表。
 func ModelSupportsTools(m *ModelEntry) bool {
-	if m.SupportsTools != nil {
-		return *m.SupportsTools
-	}
-	name := strings.ToLower(m.Model)
-	for _, p := range noToolPatterns {
-		if strings.Contains(name, p) {
-			return false
-		}
-	}
-	return true
+	return ModelCapabilities(m).Tools
 }
 
 // ChannelEntry — one messaging channel
 type ChannelEntry struct {
 	ID      string            `json:"id"`
 	Name    string            `json:"name"`
-	Type    string            `json:"type"` // "telegram" | "imessage" | "whatsapp"
+	Type    string            `json:"type"` // registered driver type: "telegram" | "feishu" | "slack" | "discord" | "email" | "dingtalk" | "wecom" | ...
 	Config  map[string]string `json:"config"`
 	Enabled bool              `json:"enabled"`
 	Status  string            `json:"status"`
@@ -251,9 +327,12 @@ type ChannelEntry struct {
 type ToolEntry struct {
 	ID      string `json:"id"`
 	Name    string `json:"name"`
-	Type    string `json:"type"` // "brave_search" | "elevenlabs" | "custom"
+	Type    string `json:"type"` // "brave_search" | "openai_stt" | "whisper_cpp" | "openai_tts" | "elevenlabs" | "custom"
 	APIKey  string `json:"apiKey"`
 	BaseURL string `json:"baseUrl,omitempty"`
+	// Model / Voice configure speech providers (pkg/voice); "" = provider default.
+	Model   string `json:"model,omitempty"`
+	Voice   string `json:"voice,omitempty"`
 	Enabled bool   `json:"enabled"`
 	Status  string `json:"status"`
 }
@@ -286,17 +365,37 @@ type ACPAgentEntry struct {
 	Status  string   `json:"status,omitempty"`  // "ok" | "untested" | "error"
 }
 
+// MCPServerEntry configures one external Model Context Protocol server whose
+// tools are mounted into agents as mcp__<id>__<tool>. Agents opt in per
+// server via AgentConfig.MCPServerIDs.
+type MCPServerEntry struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Transport string            `json:"transport"`         // "stdio" | "http"
+	Command   string            `json:"command,omitempty"` // stdio: executable to spawn
+	Args      []string          `json:"args,omitempty"`    // stdio: CLI args
+	Env       []string          `json:"env,omitempty"`     // stdio: KEY=VALUE pairs for the subprocess
+	URL       string            `json:"url,omitempty"`     // http: streamable HTTP endpoint
+	Headers   map[string]string `json:"headers,omitempty"` // http: extra request headers (e.g. Authorization)
+	Enabled   bool              `json:"enabled"`
+	Status    string            `json:"status,omitempty"` // "ok" | "error" | "untested"
+}
+
 // AgentConfig is the on-disk config.json per agent. References global entries by ID.
 type AgentConfig struct {
-	ID          string           `json:"id"`
-	Name        string           `json:"name"`
-	Description string           `json:"description,omitempty"`
-	ModelID     string           `json:"modelId"`
-	Channels    []ChannelEntry   `json:"channels,omitempty"` // per-agent channel config (own bot tokens)
-	ToolIDs     []string         `json:"toolIds,omitempty"`
-	SkillIDs    []string         `json:"skillIds,omitempty"`
-	AvatarColor string           `json:"avatarColor,omitempty"`
-	Heartbeat   *HeartbeatConfig `json:"heartbeat,omitempty"` // nil = heartbeat disabled
+	ID          string         `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	ModelID     string         `json:"modelId"`
+	Channels    []ChannelEntry `json:"channels,omitempty"` // per-agent channel config (own bot tokens)
+	ToolIDs     []string       `json:"toolIds,omitempty"`
+	SkillIDs    []string       `json:"skillIds,omitempty"`
+	// MCPServerIDs lists Config.MCPServers ids mounted for this agent ("*" = all).
+	MCPServerIDs []string         `json:"mcpServerIds,omitempty"`
+	AvatarColor  string           `json:"avatarColor,omitempty"`
+	Heartbeat    *HeartbeatConfig `json:"heartbeat,omitempty"` // nil = heartbeat disabled
+	// Generation overrides the model's GenerationParams for this agent.
+	Generation *GenerationParams `json:"generation,omitempty"`
 	// ToolPolicy is stored as raw JSON and interpreted by the tools package to avoid import cycles.
 	ToolPolicyRaw json.RawMessage `json:"toolPolicy,omitempty"`
 }
@@ -306,6 +405,21 @@ type AuthConfig struct {
 	Token string `json:"token"`
 }
 
+// MCPServeConfig configures the MCP server endpoint. The admin token always
+// has full access; Tokens adds scoped credentials for external MCP clients.
+type MCPServeConfig struct {
+	Tokens []MCPServeToken `json:"tokens,omitempty"`
+}
+
+// MCPServeToken is a scoped MCP credential.
+type MCPServeToken struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name,omitempty"`
+	Token    string   `json:"token"`
+	Agents   []string `json:"agents"`             // agent ids exposed as ask_<id> ("*" = all)
+	Projects bool     `json:"projects,omitempty"` // expose shared project files as resources
+}
+
 // --- Legacy compat types (for migration) ---
 
 type legacyConfig struct {
@@ -401,7 +515,7 @@ func applyMigrations(cfg *Config, path string) {
 
 	// ── v1 → v2 ──────────────────────────────────────────────────────────────
 	// Changes (v0.9.18+):
-	//   - Auto-set supportsTools=false for models matching noToolPatterns
+	//   - Auto-set supportsTools=false for models the capability table marks tool-less
 	//   - Ensure at least one model has isDefault=true (auto-pick first if none)
 	//   - Normalize baseUrl: strip trailing /v1 duplicate if present
 	if cfg.ConfigVersion < 2 {
@@ -817,6 +931,7 @@ func providerDisplayName(provider string) string {
 		"openai":     "OpenAI",
 		"deepseek":   "DeepSeek",
 		"openrouter": "OpenRouter",
+		"gemini":     "Google Gemini",
 		"zhipu":      "智谱 AI",
 		"kimi":       "月之暗面 (Kimi)",
 		"minimax":    "MiniMax",
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"strings"
+)
+
+// ModelCaps 是解析后的模型能力（内置能力表 + ModelEntry.Capabilities 覆盖）。
+// 供 runner 压缩阈值、工具开关和能力提示词使用。
+type ModelCaps struct {
+	ContextWindow int  `json:"contextWindow"` // 输入+输出总上下文（tokens）
+	MaxOutput     int  `json:"maxOutput"`     // 单次最大输出（tokens）
+	Vision        bool `json:"vision"`
+	Tools         bool `json:"tools"`
+	PromptCaching bool `json:"promptCaching"`
+	Reasoning     bool `json:"reasoning"` // 支持扩展思考 / reasoning
+	// ResponsesOnly：仅 OpenAI Responses API 可用（如 o3-pro），api 留空时自动选用。
+	ResponsesOnly bool `json:"responsesOnly"`
+}
+
+// CapabilityOverrides 是 ModelEntry 上可手动覆盖的能力字段；零值 / nil = 沿用内置表。
+type CapabilityOverrides struct {
+	ContextWindow int   `json:"contextWindow,omitempty"`
+	MaxOutput     int   `json:"maxOutput,omitempty"`
+	Vision        *bool `json:"vision,omitempty"`
+	Tools         *bool `json:"tools,omitempty"`
+	PromptCaching *bool `json:"promptCaching,omitempty"`
+	Reasoning     *bool `json:"reasoning,omitempty"`
+	ResponsesOnly *bool `json:"responsesOnly,omitempty"`
+}
+
+// defaultModelCaps 用于完全未知的模型（如自建 ollama）：保守的 32k 窗口。
+var defaultModelCaps = ModelCaps{ContextWindow: 32_768, MaxOutput: 4_096, Tools: true}
+
+// knownModelCaps 是内置能力表，按模型名前缀匹配（最长前缀优先）。
+// 键已归一化：小写、去掉 "provider/" 前缀、"." 换成 "-"（claude-3.5 ≡ claude-3-5）。
+// 数值取官方文档的保守值；个别部署（如 1M beta 窗口）请用 capabilities 覆盖。
+var knownModelCaps = map[string]ModelCaps{
+	// Anthropic
+	"claude":            {ContextWindow: 200_000, MaxOutput: 8_192, Vision: true, Tools: true, PromptCaching: true},
+	"claude-3-opus":     {ContextWindow: 200_000, MaxOutput: 4_096, Vision: true, Tools: true, PromptCaching: true},
+	"claude-3-haiku":    {ContextWindow: 200_000, MaxOutput: 4_096, Vision: true, Tools: true, PromptCaching: true},
+	"claude-3-5-sonnet": {ContextWindow: 200_000, MaxOutput: 8_192, Vision: true, Tools: true, PromptCaching: true},
+	"claude-3-5-haiku":  {ContextWindow: 200_000, MaxOutput: 8_192, Vision: true, Tools: true, PromptCaching: true},
+	"claude-3-7-sonnet": {ContextWindow: 200_000, MaxOutput: 64_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+	"claude-sonnet-4":   {ContextWindow: 200_000, MaxOutput: 64_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+	"claude-haiku-4":    {ContextWindow: 200_000, MaxOutput: 64_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+	"claude-opus-4":     {ContextWindow: 200_000, MaxOutput: 32_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+
+	// OpenAI
+	"gpt-3-5-turbo": {ContextWindow: 16_385, MaxOutput: 4_096, Tools: true},
+	"gpt-4":         {ContextWindow: 8_192, MaxOutput: 8_192, Tools: true},
+	"gpt-4-turbo":   {ContextWindow: 128_000, MaxOutput: 4_096, Vision: true, Tools: true},
+	"gpt-4o":        {ContextWindow: 128_000, MaxOutput: 16_384, Vision: true, Tools: true, PromptCaching: true},
+	"gpt-4-1":       {ContextWindow: 1_047_576, MaxOutput: 32_768, Vision: true, Tools: true, PromptCaching: true},
+	"gpt-5":         {ContextWindow: 400_000, MaxOutput: 128_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+	"gpt-5-pro":     {ContextWindow: 400_000, MaxOutput: 272_000, Vision: true, Tools: true, Reasoning: true, ResponsesOnly: true},
+	"gpt-5-codex":   {ContextWindow: 400_000, MaxOutput: 128_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true, ResponsesOnly: true},
+	"codex-mini":    {ContextWindow: 200_000, MaxOutput: 100_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true, ResponsesOnly: true},
+	"o1":            {ContextWindow: 200_000, MaxOutput: 100_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+	"o1-mini":       {ContextWindow: 128_000, MaxOutput: 65_536, Reasoning: true},
+	"o1-preview":    {ContextWindow: 128_000, MaxOutput: 32_768, Reasoning: true},
+	"o1-pro":        {ContextWindow: 200_000, MaxOutput: 100_000, Vision: true, Tools: true, Reasoning: true, ResponsesOnly: true},
+	"o3":            {ContextWindow: 200_000, MaxOutput: 100_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+	"o3-mini":       {ContextWindow: 200_000, MaxOutput: 100_000, Tools: true, PromptCaching: true, Reasoning: true},
+	"o3-pro":        {ContextWindow: 200_000, MaxOutput: 100_000, Vision: true, Tools: true, Reasoning: true, ResponsesOnly: true},
+	"o4-mini":       {ContextWindow: 200_000, MaxOutput: 100_000, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+
+	// Google
+	"gemini":           {ContextWindow: 1_048_576, MaxOutput: 8_192, Vision: true, Tools: true},
+	"gemini-1-5-pro":   {ContextWindow: 2_097_152, MaxOutput: 8_192, Vision: true, Tools: true},
+	"gemini-2-5-pro":   {ContextWindow: 1_048_576, MaxOutput: 65_536, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+	"gemini-2-5-flash": {ContextWindow: 1_048_576, MaxOutput: 65_536, Vision: true, Tools: true, PromptCaching: true, Reasoning: true},
+
+	// DeepSeek
+	"deepseek":          {ContextWindow: 128_000, MaxOutput: 8_192, Tools: true, PromptCaching: true},
+	"deepseek-chat":     {ContextWindow: 128_000, MaxOutput: 8_192, Tools: true, PromptCaching: true},
+	"deepseek-reasoner": {ContextWindow: 128_000, MaxOutput: 65_536, PromptCaching: true, Reasoning: true},
+
+	// Qwen
+	"qwen":       {ContextWindow: 131_072, MaxOutput: 8_192, Tools: true},
+	"qwen-max":   {ContextWindow: 32_768, MaxOutput: 8_192, Tools: true},
+	"qwen-turbo": {ContextWindow: 1_000_000, MaxOutput: 8_192, Tools: true},
+	"qwen-vl":    {ContextWindow: 131_072, MaxOutput: 8_192, Vision: true, Tools: true},
+	"qwen3":      {ContextWindow: 131_072, MaxOutput: 16_384, Tools: true, Reasoning: true},
+	"qwen3-max":  {ContextWindow: 262_144, MaxOutput: 65_536, Tools: true},
+	"qwq":        {ContextWindow: 131_072, MaxOutput: 8_192, Tools: true, Reasoning: true},
+
+	// Moonshot / Kimi
+	"moonshot-v1-8k":   {ContextWindow: 8_192, MaxOutput: 4_096, Tools: true},
+	"moonshot-v1-32k":  {ContextWindow: 32_768, MaxOutput: 4_096, Tools: true},
+	"moonshot-v1-128k": {ContextWindow: 131_072, MaxOutput: 4_096, Tools: true},
+	"kimi":             {ContextWindow: 131_072, MaxOutput: 8_192, Tools: true, PromptCaching: true},
+	"kimi-k2":          {ContextWindow: 262_144, MaxOutput: 16_384, Tools: true, PromptCaching: true},
+
+	// Zhipu
+	"glm-4":   {ContextWindow: 128_000, MaxOutput: 4_096, Tools: true},
+	"glm-4v":  {ContextWindow: 8_192, MaxOutput: 1_024, Vision: true},
+	"glm-4-5": {ContextWindow: 128_000, MaxOutput: 96_000, Tools: true, Reasoning: true},
+	"glm-4-6": {ContextWindow: 200_000, MaxOutput: 128_000, Tools: true, Reasoning: true},
+
+	// MiniMax
+	"abab6-5":    {ContextWindow: 245_760, MaxOutput: 8_192, Tools: true},
+	"minimax-m1": {ContextWindow: 1_000_000, MaxOutput: 40_000, Tools: true, Reasoning: true},
+	"minimax-m2": {ContextWindow: 204_800, MaxOutput: 128_000, Tools: true, Reasoning: true},
+}
+
+// normalizeModelName 去掉 provider 前缀并统一大小写 / 版本分隔符。
+func normalizeModelName(model string) string {
+	m := strings.ToLower(strings.TrimSpace(model))
+	if i := strings.LastIndex(m, "/"); i >= 0 {
+		m = m[i+1:]
+	}
+	return strings.ReplaceAll(m, ".", "-")
+}
+
+// KnownModelCaps 按模型名查内置能力表（最长前缀匹配），查不到时返回保守默认值。
+func KnownModelCaps(model string) ModelCaps {
+	name := normalizeModelName(model)
+	best, bestLen := defaultModelCaps, -1
+	for prefix, caps := range knownModelCaps {
+		if len(prefix) > bestLen && strings.HasPrefix(name, prefix) {
+			best, bestLen = caps, len(prefix)
+		}
+	}
+	return best
+}
+
+// ModelCapabilities 解析 m 的最终能力：内置表 → capabilities 覆盖 →
+// 旧字段 supportsTools（最高优先级）。
+func ModelCapabilities(m *ModelEntry) ModelCaps {
+	caps := KnownModelCaps(m.Model)
+	if o := m.Capabilities; o != nil {
+		if o.ContextWindow > 0 {
+			caps.ContextWindow = o.ContextWindow
+		}
+		if o.MaxOutput > 0 {
+			caps.MaxOutput = o.MaxOutput
+		}
+		if o.Vision != nil {
+			caps.Vision = *o.Vision
+		}
+		if o.Tools != nil {
+			caps.Tools = *o.Tools
+		}
+		if o.PromptCaching != nil {
+			caps.PromptCaching = *o.PromptCaching
+		}
+		if o.Reasoning != nil {
+			caps.Reasoning = *o.Reasoning
+		}
+		if o.ResponsesOnly != nil {
+			caps.ResponsesOnly = *o.ResponsesOnly
+		}
+	}
+	if m.SupportsTools != nil {
+		caps.Tools = *m.SupportsTools
+	}
+	if caps.MaxOutput > caps.ContextWindow {
+		caps.MaxOutput = caps.ContextWindow
+	}
+	return caps
+}
+
+// ModelAPI 返回 m 实际使用的接口：仅 provider=openai 时可为 responses；
+// api 留空时按能力表（ResponsesOnly）自动选择。
+func ModelAPI(m *ModelEntry) string {
+	if m.Provider != "openai" {
+		return ModelAPIChat
+	}
+	if m.API != "" {
+		return m.API
+	}
+	if ModelCapabilities(m).ResponsesOnly {
+		return ModelAPIResponses
+	}
+	return ModelAPIChat
+}
@@ -0,0 +1,67 @@
+package config
+
+import "testing"
+
+func TestKnownModelCapsLongestPrefix(t *testing.T) {
+	cases := []struct {
+		model        string
+		window       int
+		vision, tool bool
+	}{
+		{"claude-sonnet-4-20250514", 200_000, true, true},
+		{"anthropic/claude-3.5-haiku", 200_000, true, true},
+		{"gpt-4o-mini", 128_000, true, true},
+		{"gpt-4-0613", 8_192, false, true},
+		{"gpt-4.1", 1_047_576, true, true},
+		{"o1-mini", 128_000, false, false},
+		{"deepseek-reasoner", 128_000, false, false},
+		{"gemini-2.5-flash", 1_048_576, true, true},
+		{"llama3:8b", defaultModelCaps.ContextWindow, false, true},
+	}
+	for _, tc := range cases {
+		c := KnownModelCaps(tc.model)
+		if c.ContextWindow != tc.window || c.Vision != tc.vision || c.Tools != tc.tool {
+			t.Errorf("%s: got %+v", tc.model, c)
+		}
+	}
+}
+
+func TestModelCapabilitiesOverrides(t *testing.T) {
+	yes, no := true, false
+	m := &ModelEntry{Model: "llama3:70b", Capabilities: &CapabilityOverrides{ContextWindow: 131_072, Vision: &yes}}
+	c := ModelCapabilities(m)
+	if c.ContextWindow != 131_072 || !c.Vision || !c.Tools {
+		t.Fatalf("override not applied: %+v", c)
+	}
+
+	// Legacy supportsTools beats both the table and capabilities.tools.
+	m = &ModelEntry{Model: "gpt-4o", SupportsTools: &no, Capabilities: &CapabilityOverrides{Tools: &yes}}
+	if ModelSupportsTools(m) {
+		t.Fatal("supportsTools=false should win")
+	}
+	// Tool support comes from the table, dated snapshots included.
+	if !ModelSupportsTools(&ModelEntry{Model: "o1-2024-12-17"}) {
+		t.Fatal("o1 snapshot should support tools")
+	}
+	if ModelSupportsTools(&ModelEntry{Model: "o1-mini-2024-09-12"}) || ModelSupportsTools(&ModelEntry{Model: "deepseek-reasoner"}) {
+		t.Fatal("o1-mini / deepseek-reasoner should not support tools")
+	}
+}
+
+func TestModelAPI(t *testing.T) {
+	cases := []struct {
+		m    ModelEntry
+		want string
+	}{
+		{ModelEntry{Provider: "openai", Model: "gpt-4o"}, ModelAPIChat},
+		{ModelEntry{Provider: "openai", Model: "gpt-5", API: ModelAPIResponses}, ModelAPIResponses},
+		{ModelEntry{Provider: "openai", Model: "o3-pro"}, ModelAPIResponses},               // responses-only by table
+		{ModelEntry{Provider: "openai", Model: "o3-pro", API: ModelAPIChat}, ModelAPIChat}, // explicit wins
+		{ModelEntry{Provider: "openrouter", Model: "openai/o3-pro"}, ModelAPIChat},         // openai provider only
+	}
+	for _, tc := range cases {
+		if got := ModelAPI(&tc.m); got != tc.want {
+			t.Errorf("%s/%s api=%q: got %q, want %q", tc.m.Provider, tc.m.Model, tc.m.API, got, tc.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package cron
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -17,6 +18,7 @@ import (
 	"time"
 
 	"github.com/Zyling-ai/zyhive/pkg/persist"
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
 	"github.com/google/uuid"
 	cron "github.com/robfig/cron/v3"
 )
@@ -29,7 +31,8 @@ type CronRunFunc func(ctx context.Context, agentID, model, jobID, runID, message
 
 // AnnounceFunc delivers the completed job output to the user (e.g. sends a Telegram message).
 // Called only when delivery.mode == "announce" and output is not suppressed.
-type AnnounceFunc func(agentID, jobName, output string)
+// runID doubles as the idempotency key; a nil error means delivery was confirmed.
+type AnnounceFunc func(agentID, jobName, runID, output string) error
 
 // SilentToken — if the agent's output starts with (or equals) this token, the
 // result is recorded but NOT announced. Agents use this to signal "nothing to report".
@@ -94,6 +97,9 @@ type RunRecord struct {
 	Output    string `json:"output"`
 	Error     string `json:"error,omitempty"`
 	Announced bool   `json:"announced,omitempty"` // true if delivered to user
+	// AnnounceError is why an announce was not confirmed (queued for retry or failed).
+	AnnounceError string `json:"announceError,omitempty"`
+	TraceID       string `json:"traceId,omitempty"` // spans of this run: GET /api/traces/:traceId
 }
 
 // ── Engine ────────────────────────────────────────────────────────────────
@@ -124,6 +130,20 @@ type Engine struct {
 	stopping   bool
 	recordMu   sync.Mutex
 	instanceID string
+	// lateAnnounce holds ResolveAnnounce outcomes that arrived before the
+	// run record was written; entries expire after lateAnnounceTTL.
+	lateAnnounce map[string]lateOutcome
+}
+
+// lateAnnounceTTL bounds how long an early ResolveAnnounce outcome waits
+// for its run record. The record is written as soon as the run's announce
+// returns, so an older entry belongs to a run this engine never records.
+const lateAnnounceTTL = 10 * time.Minute
+
+// lateOutcome is a stashed ResolveAnnounce result ("" = announced).
+type lateOutcome struct {
+	announceErr string
+	at          time.Time
 }
 
 type activeRun struct {
@@ -149,14 +169,15 @@ type runClaim struct {
 //   - announce: output delivery callback; may be nil (disables announce mode)
 func NewEngine(dataDir string, runJob CronRunFunc, announce AnnounceFunc) *Engine {
 	return &Engine{
-		cron:       cron.New(cron.WithSeconds()),
-		jobs:       make(map[string]*Job),
-		entryIDs:   make(map[string]cron.EntryID),
-		activeRuns: make(map[string]*activeRun),
-		dataDir:    dataDir,
-		runJob:     runJob,
-		announce:   announce,
-		instanceID: uuid.NewString(),
+		cron:         cron.New(cron.WithSeconds()),
+		jobs:         make(map[string]*Job),
+		entryIDs:     make(map[string]cron.EntryID),
+		activeRuns:   make(map[string]*activeRun),
+		dataDir:      dataDir,
+		runJob:       runJob,
+		announce:     announce,
+		instanceID:   uuid.NewString(),
+		lateAnnounce: make(map[string]lateOutcome),
 	}
 }
 
@@ -692,10 +713,21 @@ func (e *Engine) executeJob(job *Job, runID string, ctx context.Context, schedul
 		agentID = "main"
 	}
 
+	ctx, span := tracing.Start(ctx, tracing.KindCron, "cron "+job.Name)
+	span.SetAgent(agentID, "")
+	span.SetAttr("job_id", job.ID)
+	span.SetAttr("run_id", runID)
+	if scheduled {
+		span.SetAttr("trigger", "schedule")
+	} else {
+		span.SetAttr("trigger", "manual")
+	}
+
 	record := RunRecord{
 		JobID:     job.ID,
 		RunID:     runID,
 		StartedAt: startedAt,
+		TraceID:   tracing.RefFromContext(ctx).TraceID,
 	}
 
 	var output string
@@ -758,10 +790,18 @@ func (e *Engine) executeJob(job *Job, runID string, ctx context.Context, schedul
 	if record.Status == "ok" && job.Delivery.Mode == "announce" && e.announce != nil {
 		trimmed := strings.TrimSpace(output)
 		if !strings.HasPrefix(trimmed, SilentToken) && trimmed != "" {
-			e.announce(agentID, job.Name, trimmed)
-			record.Announced = true
+			if err := e.announce(agentID, job.Name, runID, trimmed); err != nil {
+				record.AnnounceError = err.Error()
+			} else {
+				record.Announced = true
+			}
 		}
 	}
+	var spanErr error
+	if record.Status != "ok" {
+		spanErr = fmt.Errorf("%s: %s", record.Status, record.Error)
+	}
+	span.End(spanErr)
 
 	// Update job state and handle error counting / auto-disable
 	e.jobMu.Lock()
@@ -955,6 +995,10 @@ func (e *Engine) recoverClaimsLocked() error {
 func (e *Engine) appendRunRecord(record RunRecord) {
 	e.recordMu.Lock()
 	defer e.recordMu.Unlock()
+	if late, ok := e.lateAnnounce[record.RunID]; ok {
+		delete(e.lateAnnounce, record.RunID)
+		record.Announced, record.AnnounceError = late.announceErr == "", late.announceErr
+	}
 	runsDir := filepath.Join(e.dataDir, "runs")
 	if err := os.MkdirAll(runsDir, 0700); err != nil {
 		fmt.Printf("cron: failed to create run directory: %v\n", err)
@@ -981,6 +1025,64 @@ func (e *Engine) appendRunRecord(record RunRecord) {
 	}
 }
 
+// ResolveAnnounce records how an announce that AnnounceFunc reported as
+// not confirmed (e.g. queued for retry) finally ended: err == nil marks
+// the run announced, otherwise err becomes its AnnounceError.
+func (e *Engine) ResolveAnnounce(runID string, err error) {
+	announceErr := ""
+	if err != nil {
+		announceErr = err.Error()
+	}
+	e.recordMu.Lock()
+	defer e.recordMu.Unlock()
+	paths, _ := filepath.Glob(filepath.Join(e.dataDir, "runs", "*.jsonl"))
+	for _, path := range paths {
+		found, werr := e.rewriteRunRecord(path, runID, func(r *RunRecord) {
+			r.Announced, r.AnnounceError = announceErr == "", announceErr
+		})
+		if werr != nil {
+			fmt.Printf("cron: failed to update run record %s: %v\n", runID, werr)
+		}
+		if found {
+			return
+		}
+	}
+	now := time.Now()
+	for id, late := range e.lateAnnounce {
+		if now.Sub(late.at) > lateAnnounceTTL {
+			delete(e.lateAnnounce, id)
+		}
+	}
+	e.lateAnnounce[runID] = lateOutcome{announceErr, now}
+}
+
+// rewriteRunRecord applies fn to the record of runID in one runs file and
+// reports whether the file held it. The caller holds recordMu.
+func (e *Engine) rewriteRunRecord(path, runID string, fn func(*RunRecord)) (bool, error) {
+	found := false
+	err := persist.WithFileLock(path, func() error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		lines := bytes.Split(data, []byte("\n"))
+		for i, line := range lines {
+			var r RunRecord
+			if len(line) == 0 || json.Unmarshal(line, &r) != nil || r.RunID != runID {
+				continue
+			}
+			fn(&r)
+			if lines[i], err = json.Marshal(r); err != nil {
+				return err
+			}
+			found = true
+			return persist.AtomicWrite(path, bytes.Join(lines, []byte("\n")), 0600)
+		}
+		return nil
+	})
+	return found, err
+}
+
 var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
 
 func validJobID(id string) bool {
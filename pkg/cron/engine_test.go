@@ -374,6 +374,62 @@ func TestRemoveForAgentChecksOwnershipAndCancelsRun(t *testing.T) {
 	e.runWG.Wait()
 }
 
+func TestEngineAnnouncedOnlyAfterConfirmedDelivery(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	var keys []string
+	var mu sync.Mutex
+	e := NewEngine(t.TempDir(), func(context.Context, string, string, string, string, string) (string, error) {
+		return "report", nil
+	}, func(agentID, jobName, runID, output string) error {
+		mu.Lock()
+		keys = append(keys, runID)
+		mu.Unlock()
+		if fail.Load() {
+			return errors.New("delivery queued for retry: 429")
+		}
+		return nil
+	})
+	job := testJob(Schedule{Kind: "every", EveryMs: 60_000})
+	job.Delivery.Mode = "announce"
+	if err := e.Add(job); err != nil {
+		t.Fatal(err)
+	}
+	runs := func(n int) []RunRecord {
+		var out []RunRecord
+		waitFor(t, 2*time.Second, func() bool {
+			out, _ = e.ListRuns(job.ID)
+			return len(out) == n
+		})
+		return out
+	}
+
+	if err := e.RunNow(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	first := runs(1)[0]
+	if first.Announced || !strings.Contains(first.AnnounceError, "429") {
+		t.Fatalf("failed delivery recorded as %+v", first)
+	}
+
+	fail.Store(false)
+	waitFor(t, 2*time.Second, func() bool { return e.RunNow(job.ID) == nil })
+	var second RunRecord
+	for _, r := range runs(2) {
+		if r.RunID != first.RunID {
+			second = r
+		}
+	}
+	if !second.Announced || second.AnnounceError != "" {
+		t.Fatalf("confirmed delivery recorded as %+v", second)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(keys) != 2 || keys[0] != first.RunID || keys[1] != second.RunID {
+		t.Errorf("announce keys = %v", keys)
+	}
+}
+
 func testJob(schedule Schedule) *Job {
 	return &Job{
 		Name:     "test",
@@ -396,3 +452,35 @@ func waitFor(t *testing.T, timeout time.Duration, ready func() bool) {
 	}
 	t.Fatal("condition was not met before timeout")
 }
+
+// TestEngine_ResolveAnnounce — an announce queued for retry is marked
+// delivered once the retry succeeds, also when the outcome arrives before
+// the run record is written.
+func TestEngine_ResolveAnnounce(t *testing.T) {
+	e := NewEngine(t.TempDir(), nil, nil)
+	e.appendRunRecord(RunRecord{JobID: "j1", RunID: "run-a", Status: "ok"})
+	e.appendRunRecord(RunRecord{JobID: "j1", RunID: "run-b", Status: "ok", AnnounceError: "delivery queued for retry"})
+	e.ResolveAnnounce("run-b", nil)
+	e.ResolveAnnounce("run-c", errors.New("delivery failed: timeout"))
+	e.appendRunRecord(RunRecord{JobID: "j1", RunID: "run-c", Status: "ok", AnnounceError: "delivery queued for retry"})
+
+	runs, err := e.ListRuns("j1")
+	if err != nil || len(runs) != 3 {
+		t.Fatalf("ListRuns = %+v, %v", runs, err)
+	}
+	if runs[0].Announced || runs[0].AnnounceError != "" {
+		t.Errorf("untouched run changed: %+v", runs[0])
+	}
+	if !runs[1].Announced || runs[1].AnnounceError != "" {
+		t.Errorf("retried run = %+v, want announced", runs[1])
+	}
+	if runs[2].Announced || runs[2].AnnounceError != "delivery failed: timeout" {
+		t.Errorf("late outcome = %+v", runs[2])
+	}
+	// Outcomes for runs that are never recorded expire.
+	e.lateAnnounce["run-gone"] = lateOutcome{at: time.Now().Add(-2 * lateAnnounceTTL)}
+	e.ResolveAnnounce("run-d", nil)
+	if _, ok := e.lateAnnounce["run-gone"]; ok || len(e.lateAnnounce) != 1 {
+		t.Errorf("lateAnnounce = %+v, want only run-d", e.lateAnnounce)
+	}
+}
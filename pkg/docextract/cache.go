@@ -0,0 +1,110 @@
+package docextract
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// memEntries bounds the in-process cache.
+const memEntries = 64
+
+// cache keeps recent extractions in memory and, once SetCacheDir has been
+// called, on disk as {dir}/{key}.json so results survive restarts.
+type cache struct {
+	mu    sync.Mutex
+	dir   string
+	order *list.List // front = most recent; values are keys
+	items map[string]*list.Element
+	res   map[string]*Result
+}
+
+var defaultCache = &cache{order: list.New(), items: map[string]*list.Element{}, res: map[string]*Result{}}
+
+// SetCacheDir enables the on-disk cache under dir ("" disables it).
+func SetCacheDir(dir string) {
+	defaultCache.mu.Lock()
+	defaultCache.dir = dir
+	defaultCache.mu.Unlock()
+}
+
+// cacheKey hashes the content together with everything that changes the
+// output, so a different page range is a different entry.
+func cacheKey(data []byte, format, contentType string, opts Options) string {
+	h := sha256.New()
+	h.Write(data)
+	fmt.Fprintf(h, "\x00%s\x00%s\x00%s\x00%s\x00%t\x00%d", format, contentType, opts.Pages, opts.Sheets, opts.CSV, opts.MaxChars)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *cache) get(key string) (*Result, bool) {
+	c.mu.Lock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		res := *c.res[key]
+		c.mu.Unlock()
+		return &res, true
+	}
+	dir := c.dir
+	c.mu.Unlock()
+	if dir == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return nil, false
+	}
+	var res Result
+	if json.Unmarshal(data, &res) != nil {
+		return nil, false
+	}
+	c.remember(key, &res)
+	out := res
+	return &out, true
+}
+
+func (c *cache) put(key string, res *Result) {
+	stored := *res
+	c.remember(key, &stored)
+	c.mu.Lock()
+	dir := c.dir
+	c.mu.Unlock()
+	if dir == "" {
+		return
+	}
+	data, err := json.Marshal(&stored)
+	if err != nil {
+		return
+	}
+	// Best effort: a failed write only costs a re-extraction later.
+	if os.MkdirAll(dir, 0o755) == nil {
+		tmp := filepath.Join(dir, key+".tmp")
+		if os.WriteFile(tmp, data, 0o644) == nil {
+			_ = os.Rename(tmp, filepath.Join(dir, key+".json"))
+		}
+	}
+}
+
+func (c *cache) remember(key string, res *Result) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		c.res[key] = res
+		return
+	}
+	c.items[key] = c.order.PushFront(key)
+	c.res[key] = res
+	for c.order.Len() > memEntries {
+		old := c.order.Back()
+		k := old.Value.(string)
+		c.order.Remove(old)
+		delete(c.items, k)
+		delete(c.res, k)
+	}
+}
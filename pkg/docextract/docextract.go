@@ -0,0 +1,256 @@
+// Package docextract turns documents into plain text for the model: the
+// PDF text layer, DOCX / PPTX body text, XLSX sheets as markdown or CSV
+// tables, and readable HTML with the page chrome stripped.
+//
+// Everything is pure Go (no pdftotext / LibreOffice on the host). Inputs
+// are size-capped, and extracted text is cached by content hash so the
+// read tool, channels and memory indexing never parse the same bytes twice.
+package docextract
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Supported formats (Result.Format).
+const (
+	FormatPDF  = "pdf"
+	FormatDOCX = "docx"
+	FormatPPTX = "pptx"
+	FormatXLSX = "xlsx"
+	FormatHTML = "html"
+)
+
+// Limits.
+const (
+	MaxInputBytes   = 64 << 20  // documents larger than this are refused
+	DefaultMaxChars = 500_000   // Options.MaxChars when unset
+	maxPartBytes    = 128 << 20 // one decompressed zip entry / PDF stream
+	maxSheetRows    = 5000      // rows rendered per XLSX sheet
+)
+
+var (
+	// ErrUnsupported means the bytes are not a format this package reads.
+	ErrUnsupported = errors.New("docextract: unsupported document format")
+	// ErrTooLarge means the input exceeds MaxInputBytes.
+	ErrTooLarge = fmt.Errorf("docextract: document exceeds %d MB", MaxInputBytes>>20)
+	// ErrNoText means the document has no extractable text, typically a
+	// scanned PDF without a text layer.
+	ErrNoText = errors.New("docextract: no text layer")
+	// ErrEncrypted means the PDF is password protected.
+	ErrEncrypted = errors.New("docextract: encrypted PDF")
+)
+
+// Options selects what to extract.
+type Options struct {
+	// Pages selects PDF pages / PPTX slides, 1-based: "3", "1-5", "2,4,9-".
+	// Empty = all.
+	Pages string
+	// Sheets selects XLSX sheets by name or 1-based index, comma separated.
+	// Empty = all.
+	Sheets string
+	// CSV renders XLSX sheets as CSV instead of markdown tables.
+	CSV bool
+	// MaxChars caps the returned text in runes (0 = DefaultMaxChars).
+	MaxChars int
+}
+
+// Result is one extraction.
+type Result struct {
+	Format string `json:"format"`
+	Text   string `json:"text"`
+	// Parts is the document's total page / slide / sheet count (0 for HTML
+	// and DOCX).
+	Parts int `json:"parts,omitempty"`
+	// Title is the HTML <title>, when present.
+	Title     string `json:"title,omitempty"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// Detect returns the format of a document from its name, content type and
+// leading bytes, or "" when it is not one this package handles.
+func Detect(name, contentType string, data []byte) string {
+	ct := strings.ToLower(contentType)
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = strings.TrimSpace(ct[:i])
+	}
+	switch ct {
+	case "application/pdf":
+		return FormatPDF
+	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+		return FormatDOCX
+	case "application/vnd.openxmlformats-officedocument.presentationml.presentation":
+		return FormatPPTX
+	case "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":
+		return FormatXLSX
+	case "text/html", "application/xhtml+xml":
+		return FormatHTML
+	}
+	if f := FormatForName(name); f != "" {
+		return f
+	}
+	switch {
+	case len(data) >= 5 && string(data[:5]) == "%PDF-":
+		return FormatPDF
+	case len(data) >= 4 && string(data[:4]) == "PK\x03\x04":
+		return zipFormat(data)
+	case looksLikeHTML(data):
+		return FormatHTML
+	}
+	return ""
+}
+
+// FormatForName maps a file extension to a format ("" when unsupported).
+func FormatForName(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf":
+		return FormatPDF
+	case ".docx":
+		return FormatDOCX
+	case ".pptx":
+		return FormatPPTX
+	case ".xlsx", ".xlsm":
+		return FormatXLSX
+	case ".html", ".htm", ".xhtml":
+		return FormatHTML
+	}
+	return ""
+}
+
+func looksLikeHTML(data []byte) bool {
+	head := strings.ToLower(strings.TrimSpace(string(data[:min(len(data), 512)])))
+	head = strings.TrimPrefix(head, "\ufeff")
+	return strings.HasPrefix(head, "<!doctype html") || strings.HasPrefix(head, "<html")
+}
+
+// Extract converts data to text. name and contentType are hints for
+// Detect; either may be empty. Results are served from the cache when the
+// same bytes were extracted with the same options before.
+func Extract(data []byte, name, contentType string, opts Options) (*Result, error) {
+	if len(data) > MaxInputBytes {
+		return nil, ErrTooLarge
+	}
+	format := Detect(name, contentType, data)
+	if format == "" {
+		return nil, ErrUnsupported
+	}
+	if opts.MaxChars <= 0 {
+		opts.MaxChars = DefaultMaxChars
+	}
+	key := cacheKey(data, format, contentType, opts)
+	if res, ok := defaultCache.get(key); ok {
+		return res, nil
+	}
+	res, err := extract(data, format, contentType, opts)
+	if err != nil {
+		return nil, err
+	}
+	res.Format = format
+	res.Text, res.Truncated = truncate(res.Text, opts.MaxChars, res.Truncated)
+	defaultCache.put(key, res)
+	return res, nil
+}
+
+func extract(data []byte, format, contentType string, opts Options) (*Result, error) {
+	switch format {
+	case FormatPDF:
+		return extractPDF(data, opts)
+	case FormatDOCX:
+		return extractDOCX(data)
+	case FormatPPTX:
+		return extractPPTX(data, opts)
+	case FormatXLSX:
+		return extractXLSX(data, opts)
+	case FormatHTML:
+		return extractHTML(data, contentType)
+	}
+	return nil, ErrUnsupported
+}
+
+// truncate cuts text to max runes on a line boundary when one is close.
+func truncate(text string, max int, already bool) (string, bool) {
+	text = strings.TrimSpace(text)
+	if utf8.RuneCountInString(text) <= max {
+		return text, already
+	}
+	cut := 0
+	for i := range text {
+		if max == 0 {
+			cut = i
+			break
+		}
+		max--
+	}
+	if nl := strings.LastIndexByte(text[:cut], '\n'); nl > cut*9/10 {
+		cut = nl
+	}
+	return strings.TrimSpace(text[:cut]), true
+}
+
+// selection is a parsed Pages / Sheets spec over n parts.
+type selection struct {
+	all    bool
+	ranges [][2]int // inclusive, 1-based; hi 0 = open
+}
+
+// parsePages parses "1-3,5,8-".
+func parsePages(spec string) (selection, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return selection{all: true}, nil
+	}
+	var sel selection
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(part, "-")
+		a, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil || a < 1 {
+			return sel, fmt.Errorf("docextract: invalid page range %q", part)
+		}
+		b := a
+		if isRange {
+			b = 0
+			if s := strings.TrimSpace(hi); s != "" {
+				if b, err = strconv.Atoi(s); err != nil || b < a {
+					return sel, fmt.Errorf("docextract: invalid page range %q", part)
+				}
+			}
+		}
+		sel.ranges = append(sel.ranges, [2]int{a, b})
+	}
+	if len(sel.ranges) == 0 {
+		sel.all = true
+	}
+	return sel, nil
+}
+
+func (s selection) has(n int) bool {
+	if s.all {
+		return true
+	}
+	for _, r := range s.ranges {
+		if n >= r[0] && (r[1] == 0 || n <= r[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+// first is the lowest selected part number, used in "out of range" errors.
+func (s selection) first() int {
+	if s.all || len(s.ranges) == 0 {
+		return 1
+	}
+	lo := s.ranges[0][0]
+	for _, r := range s.ranges[1:] {
+		lo = min(lo, r[0])
+	}
+	return lo
+}
@@ -0,0 +1,237 @@
+package docextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func deflate(s string) string {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+	return b.String()
+}
+
+// buildPDF assembles a two-page PDF: page 1 uses a WinAnsi Type1 font,
+// page 2 a composite font with a ToUnicode CMap whose font dict lives in a
+// compressed object stream. There is deliberately no xref table.
+func buildPDF(page1, page2 string) []byte {
+	stream := func(num int, body string, extra string) string {
+		z := deflate(body)
+		return fmt.Sprintf("%d 0 obj\n<< /Length %d /Filter /FlateDecode %s>>\nstream\n%s\nendstream\nendobj\n", num, len(z), extra, z)
+	}
+	cmap := "/CIDInit /ProcSet findresource begin\nbegincmap\n1 begincodespacerange <0000> <FFFF> endcodespacerange\n" +
+		"2 beginbfchar <0001> <4F60> <0002> <597D> endbfchar\n1 beginbfrange <0010> <0012> <0041> endbfrange\nendcmap\n"
+	objstm := "6 0 << /Type /Font /Subtype /Type0 /BaseFont /Foo /Encoding /Identity-H /ToUnicode 9 0 R >>"
+	var b strings.Builder
+	b.WriteString("%PDF-1.7\n")
+	b.WriteString("1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n")
+	b.WriteString("2 0 obj\n<< /Type /Pages /Kids [3 0 R 4 0 R] /Count 2 /Resources << /Font << /F1 5 0 R /F2 6 0 R >> >> >>\nendobj\n")
+	b.WriteString("3 0 obj\n<< /Type /Page /Parent 2 0 R /Contents 7 0 R >>\nendobj\n")
+	b.WriteString("4 0 obj\n<< /Type /Page /Parent 2 0 R /Contents [8 0 R] >>\nendobj\n")
+	b.WriteString("5 0 obj\n<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>\nendobj\n")
+	b.WriteString(stream(7, page1, ""))
+	b.WriteString(stream(8, page2, ""))
+	b.WriteString(stream(9, cmap, ""))
+	b.WriteString(stream(10, objstm, "/Type /ObjStm /N 1 /First 4 "))
+	b.WriteString("trailer\n<< /Root 1 0 R >>\n%%EOF\n")
+	return []byte(b.String())
+}
+
+func TestExtractPDF(t *testing.T) {
+	data := buildPDF(
+		"BT /F1 12 Tf 72 700 Td (Hello \\(PDF\\) caf\\351) Tj 0 -14 Td [(Sec) -300 (ond line)] TJ ET",
+		"BT /F2 12 Tf 72 700 Td <00010002> Tj T* <001000110012> Tj ET",
+	)
+	res, err := Extract(data, "report.pdf", "", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- page 1 ---\nHello (PDF) café\nSec ond line\n\n--- page 2 ---\n你好\nABC"
+	if res.Format != FormatPDF || res.Parts != 2 || res.Text != want {
+		t.Errorf("got %s/%d %q\nwant %q", res.Format, res.Parts, res.Text, want)
+	}
+
+	res, err = Extract(data, "", "application/pdf", Options{Pages: "2-"})
+	if err != nil || strings.Contains(res.Text, "Hello") || !strings.Contains(res.Text, "你好") {
+		t.Errorf("pages 2-: %q, %v", res.Text, err)
+	}
+	if _, err := Extract(data, "report.pdf", "", Options{Pages: "5"}); err == nil || !strings.Contains(err.Error(), "out of range (2 pages)") {
+		t.Errorf("out of range: %v", err)
+	}
+	if _, err := Extract(data, "report.pdf", "", Options{Pages: "x"}); err == nil {
+		t.Error("bad page spec accepted")
+	}
+
+	scanned := buildPDF("q 100 0 0 100 0 0 cm /Im1 Do Q", "")
+	if _, err := Extract(scanned, "scan.pdf", "", Options{}); !errors.Is(err, ErrNoText) {
+		t.Errorf("scanned: %v", err)
+	}
+	enc := bytes.Replace(data, []byte("<< /Root 1 0 R >>"), []byte("<< /Root 1 0 R /Encrypt 11 0 R >>"), 1)
+	if _, err := Extract(enc, "locked.pdf", "", Options{}); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("encrypted: %v", err)
+	}
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(body))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestExtractDOCX(t *testing.T) {
+	doc := `<?xml version="1.0"?><w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"><w:body>
+<w:p><w:pPr><w:pStyle w:val="Heading1"/><w:tabs><w:tab w:val="left" w:pos="720"/></w:tabs></w:pPr><w:r><w:t>季度报告</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">收入 </w:t></w:r><w:r><w:t>增长</w:t></w:r><w:r><w:tab/><w:t>12%</w:t></w:r></w:p>
+<w:tbl><w:tr><w:tc><w:p><w:r><w:t>地区</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>金额</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>华东</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>1|2</w:t></w:r></w:p></w:tc></w:tr></w:tbl>
+</w:body></w:document>`
+	data := buildZip(t, map[string]string{"word/document.xml": doc, "[Content_Types].xml": "<Types/>"})
+	res, err := Extract(data, "", "", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "# 季度报告\n收入 增长\t12%\n| 地区 | 金额 |\n| --- | --- |\n| 华东 | 1\\|2 |"
+	if res.Format != FormatDOCX || res.Text != want {
+		t.Errorf("got %s %q\nwant %q", res.Format, res.Text, want)
+	}
+}
+
+func TestExtractPPTX(t *testing.T) {
+	slide := func(text string) string {
+		return `<p:sld xmlns:p="p" xmlns:a="a"><p:cSld><p:spTree><p:sp><p:txBody><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p><a:p><a:r><a:t>要点</a:t></a:r></a:p></p:txBody></p:sp></p:spTree></p:cSld></p:sld>`
+	}
+	data := buildZip(t, map[string]string{
+		"ppt/presentation.xml":   "<p:presentation/>",
+		"ppt/slides/slide1.xml":  slide("封面"),
+		"ppt/slides/slide2.xml":  slide("第二页"),
+		"ppt/slides/slide10.xml": slide("第十页"),
+	})
+	res, err := Extract(data, "deck.pptx", "", Options{Pages: "2-3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "--- slide 2 ---\n第二页\n要点\n\n--- slide 3 ---\n第十页\n要点"
+	if res.Parts != 3 || res.Text != want {
+		t.Errorf("got %d %q\nwant %q", res.Parts, res.Text, want)
+	}
+}
+
+func TestExtractXLSX(t *testing.T) {
+	data := buildZip(t, map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>
+<sheet name="销售" sheetId="1" r:id="rId1"/><sheet name="Notes" sheetId="2" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Target="/xl/worksheets/other.xml"/></Relationships>`,
+		"xl/sharedStrings.xml":       `<sst><si><t>城市</t></si><si><r><t>金</t></r><r><t>额</t></r><rPh><t>きん</t></rPh></si><si><t>上海</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+<row r="1"><c r="A1" t="s"><v>0</v></c><c r="C1" t="s"><v>1</v></c></row>
+<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2" t="b"><v>1</v></c><c r="C2"><v>0.30000000000000004</v></c></row>
+</sheetData></worksheet>`,
+		"xl/worksheets/other.xml": `<worksheet><sheetData><row r="1"><c r="A1" t="inlineStr"><is><t>a,b</t></is></c></row></sheetData></worksheet>`,
+	})
+	res, err := Extract(data, "book.xlsx", "", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "## 销售\n\n| 城市 |  | 金额 |\n| --- | --- | --- |\n| 上海 | TRUE | 0.3 |\n\n## Notes\n\n| a,b |\n| --- |"
+	if res.Parts != 2 || res.Text != want {
+		t.Errorf("got %d %q\nwant %q", res.Parts, res.Text, want)
+	}
+
+	res, err = Extract(data, "book.xlsx", "", Options{Sheets: "2", CSV: true})
+	if err != nil || res.Text != "--- sheet: Notes ---\n\"a,b\"" {
+		t.Errorf("csv sheet 2: %q, %v", res.Text, err)
+	}
+	if _, err := Extract(data, "book.xlsx", "", Options{Sheets: "Missing"}); err == nil || !strings.Contains(err.Error(), "销售, Notes") {
+		t.Errorf("missing sheet: %v", err)
+	}
+}
+
+func TestExtractHTML(t *testing.T) {
+	page := `<html><head><title>新闻标题</title><script>var x = 1;</script></head><body>
+<nav><a href="/">首页</a> <a href="/a">关于</a></nav>
+<div class="sidebar">广告位</div>
+<article><h1>新闻标题</h1><p>第一段   内容，` + strings.Repeat("很长的正文。", 40) + `</p>
+<ul><li>要点一</li><li>要点二</li></ul><pre>code  block
+  indented</pre><p style="display: none">hidden</p></article>
+<footer>版权所有</footer></body></html>`
+	gbk, err := simplifiedchinese.GBK.NewEncoder().String(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Extract([]byte(gbk), "", "text/html; charset=gbk", Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Title != "新闻标题" || !strings.HasPrefix(res.Text, "# 新闻标题\n\n第一段 内容，很长的正文。") {
+		t.Errorf("title / start: %q %q", res.Title, res.Text[:min(len(res.Text), 80)])
+	}
+	for _, want := range []string{"- 要点一\n- 要点二", "```\ncode  block\n  indented\n```"} {
+		if !strings.Contains(res.Text, want) {
+			t.Errorf("missing %q in %q", want, res.Text)
+		}
+	}
+	for _, junk := range []string{"首页", "广告位", "版权所有", "var x", "hidden"} {
+		if strings.Contains(res.Text, junk) {
+			t.Errorf("chrome %q kept: %q", junk, res.Text)
+		}
+	}
+}
+
+func TestExtractLimitsAndCache(t *testing.T) {
+	if _, err := Extract([]byte("plain text"), "notes.txt", "text/plain", Options{}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("txt: %v", err)
+	}
+	if _, err := Extract(make([]byte, MaxInputBytes+1), "big.pdf", "", Options{}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("too large: %v", err)
+	}
+
+	dir := t.TempDir()
+	SetCacheDir(dir)
+	defer SetCacheDir("")
+	page := []byte("<html><body><p>" + strings.Repeat("一二三四五\n", 10) + "</p></body></html>")
+	res, err := Extract(page, "a.html", "", Options{MaxChars: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Truncated || res.Text != "一二三四五 一二三四五" {
+		t.Errorf("truncated = %v %q", res.Truncated, res.Text)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(files) != 1 {
+		t.Fatalf("cache files = %v", files)
+	}
+	// Served from disk after the in-memory entry is gone.
+	defaultCache.mu.Lock()
+	defaultCache.order.Init()
+	clear(defaultCache.items)
+	clear(defaultCache.res)
+	defaultCache.mu.Unlock()
+	if err := os.WriteFile(files[0], []byte(`{"format":"html","text":"cached"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := Extract(page, "a.html", "", Options{MaxChars: 12}); err != nil || res.Text != "cached" {
+		t.Errorf("disk cache: %q, %v", res.Text, err)
+	}
+}
@@ -0,0 +1,252 @@
+package docextract
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// skipped are elements whose content is page chrome or not text at all.
+var skipped = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Select: true, atom.Svg: true,
+	atom.Iframe: true, atom.Canvas: true, atom.Object: true, atom.Head: true,
+}
+
+// blocks start on a new line.
+var blocks = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true,
+	atom.Ul: true, atom.Ol: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Blockquote: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Hr: true, atom.Address: true, atom.Details: true, atom.Summary: true,
+}
+
+// chromeRe matches class / id values of boilerplate containers.
+var chromeRe = regexp.MustCompile(`(?i)(^|[-_ ])(nav|navbar|menu|sidebar|footer|header|breadcrumbs?|cookie|banner|advert|ads?|share|social|comments?|related|subscribe|popup|modal)($|[-_ ])`)
+
+// extractHTML keeps the readable part of a page: the <article> / <main>
+// element when there is one, otherwise <body> minus navigation, scripts and
+// other chrome, rendered as lightweight markdown.
+func extractHTML(data []byte, contentType string) (*Result, error) {
+	r, err := charset.NewReader(bytes.NewReader(data), contentType)
+	if err != nil {
+		r = bytes.NewReader(data)
+	}
+	doc, err := html.Parse(io.LimitReader(r, maxPartBytes))
+	if err != nil {
+		return nil, fmt.Errorf("docextract: parse html: %w", err)
+	}
+	res := &Result{}
+	if t := find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title }); t != nil {
+		res.Title = strings.TrimSpace(collapseSpace(textOf(t)))
+	}
+	root := mainContent(doc)
+	w := &htmlWriter{}
+	w.node(root)
+	res.Text = cleanLines(w.b.String())
+	if res.Title != "" && !strings.Contains(firstLine(res.Text), res.Title) {
+		res.Text = "# " + res.Title + "\n\n" + res.Text
+	}
+	return res, nil
+}
+
+// mainContent picks the largest <article>, then <main> / role=main, then
+// <body>.
+func mainContent(doc *html.Node) *html.Node {
+	var best *html.Node
+	bestLen := 0
+	walk(doc, func(n *html.Node) {
+		if n.DataAtom == atom.Article {
+			if l := len(strings.TrimSpace(textOf(n))); l > bestLen {
+				best, bestLen = n, l
+			}
+		}
+	})
+	if best != nil && bestLen > 200 {
+		return best
+	}
+	if m := find(doc, func(n *html.Node) bool {
+		return n.DataAtom == atom.Main || getAttr(n, "role") == "main"
+	}); m != nil {
+		return m
+	}
+	if b := find(doc, func(n *html.Node) bool { return n.DataAtom == atom.Body }); b != nil {
+		return b
+	}
+	return doc
+}
+
+type htmlWriter struct {
+	b   strings.Builder
+	pre int
+}
+
+func (w *htmlWriter) newline() {
+	s := w.b.String()
+	if len(s) > 0 && !strings.HasSuffix(s, "\n") {
+		w.b.WriteByte('\n')
+	}
+}
+
+func (w *htmlWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.pre > 0 {
+			w.b.WriteString(n.Data)
+		} else {
+			w.b.WriteString(collapseSpace(n.Data))
+		}
+		return
+	case html.ElementNode:
+		if skipped[n.DataAtom] || isHidden(n) {
+			return
+		}
+	case html.DocumentNode:
+	default:
+		return
+	}
+
+	a := n.DataAtom
+	switch {
+	case a == atom.Br:
+		w.b.WriteByte('\n')
+		return
+	case a == atom.H1, a == atom.H2, a == atom.H3, a == atom.H4, a == atom.H5, a == atom.H6:
+		w.newline()
+		w.b.WriteString("\n" + strings.Repeat("#", int(a.String()[1]-'0')) + " ")
+	case a == atom.Li:
+		w.newline()
+		w.b.WriteString("- ")
+	case a == atom.Pre:
+		w.newline()
+		w.b.WriteString("```\n")
+		w.pre++
+	case a == atom.Tr:
+		w.newline()
+		w.b.WriteString("|")
+	case a == atom.Td, a == atom.Th:
+		w.b.WriteByte(' ')
+	case blocks[a]:
+		w.newline()
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.node(c)
+	}
+	switch {
+	case a == atom.Pre:
+		w.pre--
+		w.newline()
+		w.b.WriteString("```\n")
+	case a == atom.Td, a == atom.Th:
+		w.b.WriteString(" |")
+	case a == atom.H1, a == atom.H2, a == atom.H3, a == atom.H4, a == atom.H5, a == atom.H6:
+		w.b.WriteString("\n\n")
+	case a == atom.P:
+		w.b.WriteString("\n\n")
+	case blocks[a], a == atom.Li, a == atom.Tr:
+		w.newline()
+	}
+}
+
+func isHidden(n *html.Node) bool {
+	if _, ok := attrOK(n, "hidden"); ok {
+		return true
+	}
+	if getAttr(n, "aria-hidden") == "true" {
+		return true
+	}
+	style := strings.ReplaceAll(strings.ToLower(getAttr(n, "style")), " ", "")
+	if strings.Contains(style, "display:none") {
+		return true
+	}
+	// Boilerplate containers, but never the <body> / <main> themselves.
+	if n.DataAtom != atom.Body && n.DataAtom != atom.Main && n.DataAtom != atom.Article {
+		if chromeRe.MatchString(getAttr(n, "class")) || chromeRe.MatchString(getAttr(n, "id")) {
+			return true
+		}
+	}
+	return false
+}
+
+func attrOK(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+func getAttr(n *html.Node, key string) string {
+	v, _ := attrOK(n, key)
+	return v
+}
+
+func walk(n *html.Node, fn func(*html.Node)) {
+	fn(n)
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func find(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if f := find(c, match); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// textOf is the raw text content of n, skipping scripts and styles.
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	walk(n, func(c *html.Node) {
+		if c.Type == html.TextNode && (c.Parent == nil || (c.Parent.DataAtom != atom.Script && c.Parent.DataAtom != atom.Style)) {
+			b.WriteString(c.Data)
+		}
+	})
+	return b.String()
+}
+
+var spaceRe = regexp.MustCompile(`[ \t\r\n\f\x{00a0}]+`)
+
+func collapseSpace(s string) string {
+	return spaceRe.ReplaceAllString(s, " ")
+}
+
+// cleanLines trims every line and collapses runs of blank lines, leaving
+// fenced code blocks alone.
+func cleanLines(s string) string {
+	lines := strings.Split(s, "\n")
+	out := make([]string, 0, len(lines))
+	inFence := false
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "```" {
+			inFence = !inFence
+		}
+		if !inFence {
+			l = strings.TrimSpace(l)
+		}
+		if l == "" && len(out) > 0 && out[len(out)-1] == "" {
+			continue
+		}
+		out = append(out, l)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,621 @@
+package docextract
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ── OOXML containers ──────────────────────────────────────────────────────
+
+func openZip(data []byte) (*zip.Reader, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("docextract: open zip: %w", err)
+	}
+	return zr, nil
+}
+
+// zipFormat tells DOCX / PPTX / XLSX apart by their main part.
+func zipFormat(data []byte) string {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return ""
+	}
+	for _, f := range zr.File {
+		switch f.Name {
+		case "word/document.xml":
+			return FormatDOCX
+		case "ppt/presentation.xml":
+			return FormatPPTX
+		case "xl/workbook.xml":
+			return FormatXLSX
+		}
+	}
+	return ""
+}
+
+// readPart reads one zip entry, capped at maxPartBytes (zip bombs).
+func readPart(zr *zip.Reader, name string) ([]byte, error) {
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(io.LimitReader(rc, maxPartBytes+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(data) > maxPartBytes {
+			return nil, fmt.Errorf("docextract: %s exceeds %d MB uncompressed", name, maxPartBytes>>20)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("docextract: missing %s", name)
+}
+
+func attr(se xml.StartElement, local string) string {
+	for _, a := range se.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// ── DOCX ──────────────────────────────────────────────────────────────────
+
+// extractDOCX renders word/document.xml: paragraphs as lines, Heading
+// styles as markdown headings, tables as markdown tables.
+func extractDOCX(data []byte) (*Result, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := readPart(zr, "word/document.xml")
+	if err != nil {
+		return nil, err
+	}
+	var (
+		out     strings.Builder
+		para    strings.Builder
+		heading int
+		rows    [][]string // current table (outermost only)
+		cell    *strings.Builder
+		depth   int // table nesting
+		inText  bool
+		inPPr   bool // paragraph properties: <w:tabs><w:tab> are stops, not text
+	)
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("docextract: parse document.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "p":
+				para.Reset()
+				heading = 0
+			case "pPr":
+				inPPr = true
+			case "pStyle":
+				heading = headingLevel(attr(t, "val"))
+			case "t":
+				inText = true
+			case "tab":
+				if !inPPr {
+					para.WriteByte('\t')
+				}
+			case "br", "cr":
+				para.WriteByte('\n')
+			case "tbl":
+				depth++
+				if depth == 1 {
+					rows = nil
+				}
+			case "tr":
+				if depth == 1 {
+					rows = append(rows, nil)
+				}
+			case "tc":
+				if depth == 1 {
+					cell = &strings.Builder{}
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "pPr":
+				inPPr = false
+			case "t":
+				inText = false
+			case "p":
+				text := strings.TrimRight(para.String(), " \t")
+				if cell != nil {
+					if cell.Len() > 0 && text != "" {
+						cell.WriteByte(' ')
+					}
+					cell.WriteString(text)
+					break
+				}
+				if heading > 0 && text != "" {
+					out.WriteString(strings.Repeat("#", heading) + " ")
+				}
+				out.WriteString(text)
+				out.WriteByte('\n')
+			case "tc":
+				if depth == 1 && cell != nil && len(rows) > 0 {
+					rows[len(rows)-1] = append(rows[len(rows)-1], cell.String())
+					cell = nil
+				}
+			case "tbl":
+				if depth == 1 {
+					out.WriteString(markdownTable(rows))
+					rows = nil
+				}
+				depth--
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	return &Result{Text: collapseBlankLines(out.String())}, nil
+}
+
+// headingLevel maps Word's built-in "Heading1".."Heading6" / "Title" styles.
+func headingLevel(style string) int {
+	s := strings.ToLower(style)
+	if s == "title" {
+		return 1
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(s, "heading")); err == nil && strings.HasPrefix(s, "heading") && n >= 1 && n <= 6 {
+		return n
+	}
+	return 0
+}
+
+// ── PPTX ──────────────────────────────────────────────────────────────────
+
+var slideRe = regexp.MustCompile(`^ppt/slides/slide(\d+)\.xml$`)
+
+// extractPPTX renders the text frames of each slide, in slide order.
+func extractPPTX(data []byte, opts Options) (*Result, error) {
+	sel, err := parsePages(opts.Pages)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	type slide struct {
+		n    int
+		name string
+	}
+	var slides []slide
+	for _, f := range zr.File {
+		if m := slideRe.FindStringSubmatch(f.Name); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			slides = append(slides, slide{n, f.Name})
+		}
+	}
+	sort.Slice(slides, func(i, j int) bool { return slides[i].n < slides[j].n })
+
+	var out strings.Builder
+	picked := 0
+	for i, s := range slides {
+		if !sel.has(i + 1) {
+			continue
+		}
+		picked++
+		part, err := readPart(zr, s.name)
+		if err != nil {
+			return nil, err
+		}
+		text, err := drawingText(part)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&out, "--- slide %d ---\n%s\n\n", i+1, text)
+	}
+	if picked == 0 && len(slides) > 0 {
+		return nil, fmt.Errorf("docextract: slide %d out of range (%d slides)", sel.first(), len(slides))
+	}
+	return &Result{Text: collapseBlankLines(out.String()), Parts: len(slides)}, nil
+}
+
+// drawingText collects DrawingML <a:t> runs, one line per <a:p>.
+func drawingText(part []byte) (string, error) {
+	var out, para strings.Builder
+	inText := false
+	dec := xml.NewDecoder(bytes.NewReader(part))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("docextract: parse slide: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "br":
+				para.WriteByte('\n')
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				if s := strings.TrimSpace(para.String()); s != "" {
+					out.WriteString(s + "\n")
+				}
+				para.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				para.Write(t)
+			}
+		}
+	}
+	return strings.TrimSpace(out.String()), nil
+}
+
+// ── XLSX ──────────────────────────────────────────────────────────────────
+
+// extractXLSX renders each selected sheet as a markdown table (or CSV)
+// under a "## {sheet}" heading.
+func extractXLSX(data []byte, opts Options) (*Result, error) {
+	zr, err := openZip(data)
+	if err != nil {
+		return nil, err
+	}
+	sheets, err := workbookSheets(zr)
+	if err != nil {
+		return nil, err
+	}
+	var shared []string
+	if part, err := readPart(zr, "xl/sharedStrings.xml"); err == nil {
+		if shared, err = sharedStrings(part); err != nil {
+			return nil, err
+		}
+	}
+
+	wanted, err := pickSheets(sheets, opts.Sheets)
+	if err != nil {
+		return nil, err
+	}
+	var out strings.Builder
+	truncated := false
+	for _, s := range wanted {
+		part, err := readPart(zr, s.path)
+		if err != nil {
+			return nil, err
+		}
+		rows, more, err := sheetRows(part, shared)
+		if err != nil {
+			return nil, fmt.Errorf("docextract: sheet %q: %w", s.name, err)
+		}
+		if opts.CSV {
+			fmt.Fprintf(&out, "--- sheet: %s ---\n", s.name)
+			w := csv.NewWriter(&out)
+			_ = w.WriteAll(rows)
+		} else {
+			fmt.Fprintf(&out, "## %s\n\n", s.name)
+			out.WriteString(markdownTable(rows))
+		}
+		if more > 0 {
+			fmt.Fprintf(&out, "…（另有 %d 行未显示）\n", more)
+			truncated = true
+		}
+		out.WriteByte('\n')
+	}
+	return &Result{Text: out.String(), Parts: len(sheets), Truncated: truncated}, nil
+}
+
+type sheetRef struct{ name, path string }
+
+// workbookSheets lists sheets in workbook order with their part paths.
+func workbookSheets(zr *zip.Reader) ([]sheetRef, error) {
+	wb, err := readPart(zr, "xl/workbook.xml")
+	if err != nil {
+		return nil, err
+	}
+	targets := map[string]string{}
+	if rels, err := readPart(zr, "xl/_rels/workbook.xml.rels"); err == nil {
+		var doc struct {
+			Rels []struct {
+				ID     string `xml:"Id,attr"`
+				Target string `xml:"Target,attr"`
+			} `xml:"Relationship"`
+		}
+		if err := xml.Unmarshal(rels, &doc); err != nil {
+			return nil, fmt.Errorf("docextract: parse workbook rels: %w", err)
+		}
+		for _, r := range doc.Rels {
+			t := r.Target
+			if strings.HasPrefix(t, "/") {
+				t = strings.TrimPrefix(t, "/")
+			} else {
+				t = path.Join("xl", t)
+			}
+			targets[r.ID] = t
+		}
+	}
+	var sheets []sheetRef
+	dec := xml.NewDecoder(bytes.NewReader(wb))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("docextract: parse workbook.xml: %w", err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "sheet" {
+			continue
+		}
+		var rid string
+		for _, a := range se.Attr {
+			if a.Name.Local == "id" && a.Name.Space != "" {
+				rid = a.Value
+			}
+		}
+		p := targets[rid]
+		if p == "" {
+			p = fmt.Sprintf("xl/worksheets/sheet%d.xml", len(sheets)+1)
+		}
+		sheets = append(sheets, sheetRef{name: attr(se, "name"), path: p})
+	}
+	return sheets, nil
+}
+
+// pickSheets applies Options.Sheets (names or 1-based indexes).
+func pickSheets(sheets []sheetRef, spec string) ([]sheetRef, error) {
+	if strings.TrimSpace(spec) == "" {
+		return sheets, nil
+	}
+	var out []sheetRef
+	for _, want := range strings.Split(spec, ",") {
+		want = strings.TrimSpace(want)
+		if want == "" {
+			continue
+		}
+		found := false
+		for i, s := range sheets {
+			if strings.EqualFold(s.name, want) || strconv.Itoa(i+1) == want {
+				out = append(out, s)
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(sheets))
+			for i, s := range sheets {
+				names[i] = s.name
+			}
+			return nil, fmt.Errorf("docextract: sheet %q not found (sheets: %s)", want, strings.Join(names, ", "))
+		}
+	}
+	return out, nil
+}
+
+// sharedStrings reads xl/sharedStrings.xml; rich-text runs are joined and
+// phonetic hints (<rPh>) dropped.
+func sharedStrings(part []byte) ([]string, error) {
+	var out []string
+	var cur strings.Builder
+	inT, inPh := false, false
+	dec := xml.NewDecoder(bytes.NewReader(part))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("docextract: parse sharedStrings.xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "si":
+				cur.Reset()
+			case "t":
+				inT = true
+			case "rPh":
+				inPh = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "si":
+				out = append(out, cur.String())
+			case "t":
+				inT = false
+			case "rPh":
+				inPh = false
+			}
+		case xml.CharData:
+			if inT && !inPh {
+				cur.Write(t)
+			}
+		}
+	}
+	return out, nil
+}
+
+// sheetRows decodes a worksheet into a dense grid (trailing empty columns
+// trimmed), keeping at most maxSheetRows rows; more is the number dropped.
+func sheetRows(part []byte, shared []string) (rows [][]string, more int, err error) {
+	var (
+		row      map[int]string
+		col      int
+		typ      string
+		val      strings.Builder
+		inVal    bool
+		maxCol   int
+		rowIndex []map[int]string
+	)
+	dec := xml.NewDecoder(bytes.NewReader(part))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "row":
+				row = map[int]string{}
+				col = -1
+			case "c":
+				typ = attr(t, "t")
+				if c := colIndex(attr(t, "r")); c >= 0 {
+					col = c
+				} else {
+					col++
+				}
+				val.Reset()
+			case "v", "t":
+				inVal = true
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "v", "t":
+				inVal = false
+			case "c":
+				if row == nil {
+					break
+				}
+				if s := cellValue(typ, val.String(), shared); s != "" {
+					row[col] = s
+					maxCol = max(maxCol, col+1)
+				}
+			case "row":
+				if len(rowIndex) >= maxSheetRows {
+					more++
+				} else {
+					rowIndex = append(rowIndex, row)
+				}
+				row = nil
+			}
+		case xml.CharData:
+			if inVal {
+				val.Write(t)
+			}
+		}
+	}
+	// Drop trailing empty rows.
+	for len(rowIndex) > 0 && len(rowIndex[len(rowIndex)-1]) == 0 {
+		rowIndex = rowIndex[:len(rowIndex)-1]
+	}
+	for _, r := range rowIndex {
+		cells := make([]string, maxCol)
+		for c, v := range r {
+			cells[c] = v
+		}
+		rows = append(rows, cells)
+	}
+	return rows, more, nil
+}
+
+func cellValue(typ, raw string, shared []string) string {
+	switch typ {
+	case "s":
+		if i, err := strconv.Atoi(strings.TrimSpace(raw)); err == nil && i >= 0 && i < len(shared) {
+			return shared[i]
+		}
+		return ""
+	case "b":
+		if strings.TrimSpace(raw) == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	case "inlineStr", "str", "e":
+		return raw
+	}
+	// Numbers: drop binary float noise (0.30000000000000004 → 0.3).
+	if f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64); err == nil {
+		f, _ = strconv.ParseFloat(strconv.FormatFloat(f, 'g', 15, 64), 64)
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return raw
+}
+
+// colIndex converts the column letters of a cell reference ("AB12") to a
+// 0-based index; -1 when ref is empty.
+func colIndex(ref string) int {
+	n := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+		letters++
+	}
+	if letters == 0 {
+		return -1
+	}
+	return n - 1
+}
+
+// ── Shared rendering ──────────────────────────────────────────────────────
+
+// markdownTable renders rows with the first row as the header.
+func markdownTable(rows [][]string) string {
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r))
+	}
+	if width == 0 {
+		return ""
+	}
+	var b strings.Builder
+	cellEsc := strings.NewReplacer("|", `\|`, "\r\n", " ", "\n", " ")
+	for i, r := range rows {
+		b.WriteByte('|')
+		for c := 0; c < width; c++ {
+			v := ""
+			if c < len(r) {
+				v = cellEsc.Replace(strings.TrimSpace(r[c]))
+			}
+			b.WriteString(" " + v + " |")
+		}
+		b.WriteByte('\n')
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", width) + "\n")
+		}
+	}
+	return b.String()
+}
+
+var blankRunRe = regexp.MustCompile(`\n{3,}`)
+
+func collapseBlankLines(s string) string {
+	return blankRunRe.ReplaceAllString(s, "\n\n")
+}
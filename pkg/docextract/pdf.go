@@ -0,0 +1,989 @@
+package docextract
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/ascii85"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// A small PDF reader: enough of the object model to walk the page tree,
+// decode content streams and map glyph codes back to text through
+// ToUnicode CMaps or the font's simple encoding. Objects are found by
+// scanning for "N G obj" rather than trusting the xref table, which also
+// copes with the many files whose offsets are broken; object streams
+// (PDF 1.5+) are unpacked after the scan.
+
+type (
+	pdfName    string
+	pdfString  string
+	pdfKeyword string
+	pdfArray   []any
+	pdfDict    map[string]any
+	pdfRef     struct{ num, gen int }
+	pdfStream  struct {
+		dict pdfDict
+		raw  []byte
+	}
+)
+
+type pdfDoc struct {
+	objs    map[int]any
+	trailer pdfDict
+}
+
+var objHeadRe = regexp.MustCompile(`(\d+)\s+(\d+)\s+obj\b`)
+
+func extractPDF(data []byte, opts Options) (*Result, error) {
+	sel, err := parsePages(opts.Pages)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := parsePDF(data)
+	if err != nil {
+		return nil, err
+	}
+	pages := doc.pages()
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("docextract: no pages found")
+	}
+	var out strings.Builder
+	picked, withText := 0, 0
+	for i, pg := range pages {
+		if !sel.has(i + 1) {
+			continue
+		}
+		picked++
+		text := strings.TrimSpace(doc.pageText(pg))
+		if text != "" {
+			withText++
+		}
+		if len(pages) > 1 {
+			fmt.Fprintf(&out, "--- page %d ---\n", i+1)
+		}
+		out.WriteString(text)
+		out.WriteString("\n\n")
+	}
+	if picked == 0 {
+		return nil, fmt.Errorf("docextract: page %d out of range (%d pages)", sel.first(), len(pages))
+	}
+	if withText == 0 {
+		return nil, ErrNoText
+	}
+	return &Result{Text: collapseBlankLines(out.String()), Parts: len(pages)}, nil
+}
+
+func parsePDF(data []byte) (*pdfDoc, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(data[:min(len(data), 1024)], "\x00\t\r\n "), []byte("%PDF-")) && !bytes.Contains(data[:min(len(data), 1024)], []byte("%PDF-")) {
+		return nil, fmt.Errorf("docextract: not a PDF")
+	}
+	d := &pdfDoc{objs: map[int]any{}, trailer: pdfDict{}}
+	pos := 0
+	for pos < len(data) {
+		m := objHeadRe.FindSubmatchIndex(data[pos:])
+		if m == nil {
+			break
+		}
+		num, _ := strconv.Atoi(string(data[pos+m[2] : pos+m[3]]))
+		lx := &lexer{b: data, pos: pos + m[1]}
+		obj := lx.object(0)
+		next := lx.pos
+		if lx.keywordAhead("stream") {
+			start := lx.pos
+			if start < len(data) && data[start] == '\r' {
+				start++
+			}
+			if start < len(data) && data[start] == '\n' {
+				start++
+			}
+			dict, _ := obj.(pdfDict)
+			end := -1
+			if n, ok := dict["Length"].(float64); ok && n >= 0 && start+int(n) <= len(data) {
+				if bytes.HasPrefix(bytes.TrimLeft(data[start+int(n):], "\r\n \t"), []byte("endstream")) {
+					end = start + int(n)
+				}
+			}
+			if end < 0 {
+				i := bytes.Index(data[start:], []byte("endstream"))
+				if i < 0 {
+					break
+				}
+				end = start + i
+				for end > start && (data[end-1] == '\n' || data[end-1] == '\r') {
+					end--
+				}
+			}
+			obj = pdfStream{dict: dict, raw: data[start:end]}
+			next = end
+		}
+		d.objs[num] = obj
+		if s, ok := obj.(pdfStream); ok && s.dict["Type"] == pdfName("XRef") {
+			d.mergeTrailer(s.dict)
+		}
+		pos = max(next, pos+m[1])
+	}
+	// Classic trailers (the last one wins for Root; Encrypt from any).
+	for i := 0; ; {
+		j := bytes.Index(data[i:], []byte("trailer"))
+		if j < 0 {
+			break
+		}
+		lx := &lexer{b: data, pos: i + j + len("trailer")}
+		if t, ok := lx.object(0).(pdfDict); ok {
+			d.mergeTrailer(t)
+		}
+		i += j + len("trailer")
+	}
+	if _, ok := d.trailer["Encrypt"]; ok {
+		return nil, ErrEncrypted
+	}
+	d.unpackObjectStreams()
+	return d, nil
+}
+
+func (d *pdfDoc) mergeTrailer(t pdfDict) {
+	for k, v := range t {
+		d.trailer[k] = v
+	}
+}
+
+// unpackObjectStreams adds the objects stored inside /Type /ObjStm
+// streams. Objects also present uncompressed keep the uncompressed copy
+// (the usual shape of an incremental update).
+func (d *pdfDoc) unpackObjectStreams() {
+	var streams []pdfStream
+	for _, o := range d.objs {
+		if s, ok := o.(pdfStream); ok && s.dict["Type"] == pdfName("ObjStm") {
+			streams = append(streams, s)
+		}
+	}
+	for _, s := range streams {
+		data, err := d.decode(s)
+		if err != nil {
+			continue
+		}
+		n, _ := d.resolve(s.dict["N"]).(float64)
+		first, _ := d.resolve(s.dict["First"]).(float64)
+		lx := &lexer{b: data}
+		type entry struct{ num, off int }
+		var entries []entry
+		for i := 0; i < int(n); i++ {
+			a, ok1 := lx.object(0).(float64)
+			b, ok2 := lx.object(0).(float64)
+			if !ok1 || !ok2 {
+				break
+			}
+			entries = append(entries, entry{int(a), int(b)})
+		}
+		for _, e := range entries {
+			if _, exists := d.objs[e.num]; exists {
+				continue
+			}
+			off := int(first) + e.off
+			if off < 0 || off >= len(data) {
+				continue
+			}
+			d.objs[e.num] = (&lexer{b: data, pos: off}).object(0)
+		}
+	}
+}
+
+func (d *pdfDoc) resolve(o any) any {
+	for i := 0; i < 16; i++ {
+		r, ok := o.(pdfRef)
+		if !ok {
+			return o
+		}
+		o = d.objs[r.num]
+	}
+	return nil
+}
+
+func (d *pdfDoc) dict(o any) pdfDict {
+	switch v := d.resolve(o).(type) {
+	case pdfDict:
+		return v
+	case pdfStream:
+		return v.dict
+	}
+	return nil
+}
+
+// ── Page tree ─────────────────────────────────────────────────────────────
+
+type pdfPage struct {
+	dict      pdfDict
+	resources pdfDict
+}
+
+func (d *pdfDoc) pages() []pdfPage {
+	var out []pdfPage
+	seen := map[any]bool{}
+	var visit func(node any, res pdfDict, depth int)
+	visit = func(node any, res pdfDict, depth int) {
+		if r, ok := node.(pdfRef); ok {
+			if seen[r] {
+				return
+			}
+			seen[r] = true
+		}
+		n := d.dict(node)
+		if n == nil || depth > 64 {
+			return
+		}
+		if r := d.dict(n["Resources"]); r != nil {
+			res = r
+		}
+		if kids, ok := d.resolve(n["Kids"]).(pdfArray); ok {
+			for _, k := range kids {
+				visit(k, res, depth+1)
+			}
+			return
+		}
+		if n["Type"] == pdfName("Page") || n["Contents"] != nil {
+			out = append(out, pdfPage{dict: n, resources: res})
+		}
+	}
+	if root := d.dict(d.trailer["Root"]); root != nil {
+		visit(root["Pages"], nil, 0)
+	}
+	if len(out) == 0 {
+		// No usable catalog: fall back to every /Type /Page in object order.
+		maxNum := 0
+		for n := range d.objs {
+			maxNum = max(maxNum, n)
+		}
+		for n := 0; n <= maxNum; n++ {
+			if p, ok := d.objs[n].(pdfDict); ok && p["Type"] == pdfName("Page") {
+				out = append(out, pdfPage{dict: p, resources: d.dict(p["Resources"])})
+			}
+		}
+	}
+	return out
+}
+
+func (d *pdfDoc) pageText(pg pdfPage) string {
+	var content []byte
+	switch c := d.resolve(pg.dict["Contents"]).(type) {
+	case pdfStream:
+		content, _ = d.decode(c)
+	case pdfArray:
+		for _, part := range c {
+			if s, ok := d.resolve(part).(pdfStream); ok {
+				b, _ := d.decode(s)
+				content = append(content, b...)
+				content = append(content, '\n')
+			}
+		}
+	}
+	tx := &textRun{doc: d, fonts: map[string]*pdfFont{}}
+	tx.run(content, pg.resources, 0)
+	return tx.out.String()
+}
+
+// ── Streams ───────────────────────────────────────────────────────────────
+
+// decode applies the stream's filters. Image-only filters (DCT, JBIG2,
+// CCITT, JPX) and LZW are reported as errors; callers skip those streams.
+func (d *pdfDoc) decode(s pdfStream) ([]byte, error) {
+	var filters []any
+	switch f := d.resolve(s.dict["Filter"]).(type) {
+	case pdfName:
+		filters = []any{f}
+	case pdfArray:
+		filters = f
+	}
+	data := s.raw
+	for _, f := range filters {
+		name, _ := d.resolve(f).(pdfName)
+		var err error
+		switch name {
+		case "FlateDecode", "Fl":
+			data, err = inflate(data)
+		case "ASCIIHexDecode", "AHx":
+			data, err = asciiHex(data)
+		case "ASCII85Decode", "A85":
+			data, err = ascii85Decode(data)
+		default:
+			return nil, fmt.Errorf("docextract: unsupported PDF filter %s", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+func inflate(data []byte) ([]byte, error) {
+	var r io.Reader
+	if zr, err := zlib.NewReader(bytes.NewReader(data)); err == nil {
+		r = zr
+	} else {
+		r = flate.NewReader(bytes.NewReader(data))
+	}
+	out, err := io.ReadAll(io.LimitReader(r, maxPartBytes))
+	// Truncated / slightly corrupt streams are common; keep what inflated.
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("docextract: inflate: %w", err)
+	}
+	return out, nil
+}
+
+func asciiHex(data []byte) ([]byte, error) {
+	var clean []byte
+	for _, c := range data {
+		if c == '>' {
+			break
+		}
+		if isHexDigit(c) {
+			clean = append(clean, c)
+		}
+	}
+	if len(clean)%2 == 1 {
+		clean = append(clean, '0')
+	}
+	out := make([]byte, len(clean)/2)
+	_, err := hex.Decode(out, clean)
+	return out, err
+}
+
+func ascii85Decode(data []byte) ([]byte, error) {
+	data = bytes.TrimPrefix(bytes.TrimSpace(data), []byte("<~"))
+	if i := bytes.Index(data, []byte("~>")); i >= 0 {
+		data = data[:i]
+	}
+	out := make([]byte, len(data)*4/5+4)
+	n, _, err := ascii85.Decode(out, data, true)
+	return out[:n], err
+}
+
+// ── Content streams ───────────────────────────────────────────────────────
+
+// textRun interprets text operators and writes the shown strings, breaking
+// lines on vertical moves and spacing on large horizontal gaps.
+type textRun struct {
+	doc   *pdfDoc
+	fonts map[string]*pdfFont // by font dict identity
+	out   strings.Builder
+	font  *pdfFont
+	lineY float64
+}
+
+func (t *textRun) run(content []byte, res pdfDict, depth int) {
+	if depth > 8 {
+		return
+	}
+	fonts := t.doc.dict(res["Font"])
+	xobjs := t.doc.dict(res["XObject"])
+	lx := &lexer{b: content}
+	var operands []any
+	for {
+		tok, ok := lx.next()
+		if !ok {
+			return
+		}
+		op, isOp := tok.(pdfKeyword)
+		if !isOp || op == "[" || op == "<<" || op == "true" || op == "false" || op == "null" {
+			lx.unread(tok)
+			operands = append(operands, lx.object(0))
+			if len(operands) > 64 {
+				operands = operands[1:]
+			}
+			continue
+		}
+		switch op {
+		case "BT":
+			t.lineY = math.NaN()
+		case "Tf":
+			if len(operands) >= 2 {
+				if name, ok := operands[len(operands)-2].(pdfName); ok {
+					t.font = t.loadFont(fonts, string(name))
+				}
+			}
+		case "Td", "TD":
+			if len(operands) >= 2 {
+				if ty, _ := operands[len(operands)-1].(float64); ty != 0 {
+					t.newline()
+				} else {
+					t.space()
+				}
+			}
+		case "Tm":
+			if len(operands) >= 6 {
+				y, _ := operands[5].(float64)
+				if !math.IsNaN(t.lineY) && math.Abs(y-t.lineY) > 0.5 {
+					t.newline()
+				} else if !math.IsNaN(t.lineY) {
+					t.space()
+				}
+				t.lineY = y
+			}
+		case "T*":
+			t.newline()
+		case "Tj":
+			if len(operands) >= 1 {
+				t.show(operands[len(operands)-1])
+			}
+		case "'":
+			t.newline()
+			if len(operands) >= 1 {
+				t.show(operands[len(operands)-1])
+			}
+		case "\"":
+			t.newline()
+			if len(operands) >= 1 {
+				t.show(operands[len(operands)-1])
+			}
+		case "TJ":
+			if len(operands) >= 1 {
+				if arr, ok := operands[len(operands)-1].(pdfArray); ok {
+					for _, el := range arr {
+						switch v := el.(type) {
+						case pdfString:
+							t.show(v)
+						case float64:
+							if v < -180 {
+								t.space()
+							}
+						}
+					}
+				}
+			}
+		case "Do":
+			if len(operands) >= 1 {
+				if name, ok := operands[len(operands)-1].(pdfName); ok && xobjs != nil {
+					if s, ok := t.doc.resolve(xobjs[string(name)]).(pdfStream); ok && s.dict["Subtype"] == pdfName("Form") {
+						if body, err := t.doc.decode(s); err == nil {
+							sub := res
+							if r := t.doc.dict(s.dict["Resources"]); r != nil {
+								sub = r
+							}
+							t.newline()
+							t.run(body, sub, depth+1)
+							t.newline()
+						}
+					}
+				}
+			}
+		case "BI":
+			lx.skipInlineImage()
+		}
+		operands = operands[:0]
+	}
+}
+
+func (t *textRun) show(o any) {
+	s, ok := o.(pdfString)
+	if !ok {
+		return
+	}
+	if t.font == nil {
+		t.font = &pdfFont{}
+	}
+	t.out.WriteString(t.font.decode([]byte(s)))
+}
+
+func (t *textRun) newline() {
+	if b := t.out.String(); len(b) > 0 && b[len(b)-1] != '\n' {
+		t.out.WriteByte('\n')
+	}
+}
+
+func (t *textRun) space() {
+	if b := t.out.String(); len(b) > 0 && b[len(b)-1] != '\n' && b[len(b)-1] != ' ' {
+		t.out.WriteByte(' ')
+	}
+}
+
+func (t *textRun) loadFont(fonts pdfDict, name string) *pdfFont {
+	if fonts == nil {
+		return &pdfFont{}
+	}
+	ref := fonts[name]
+	key := fmt.Sprint(ref)
+	if r, ok := ref.(pdfRef); ok {
+		key = fmt.Sprintf("ref:%d", r.num)
+	}
+	if f, ok := t.fonts[key]; ok {
+		return f
+	}
+	f := t.doc.newFont(t.doc.dict(ref))
+	t.fonts[key] = f
+	return f
+}
+
+// ── Fonts ─────────────────────────────────────────────────────────────────
+
+type pdfFont struct {
+	width   int               // code width in bytes (1 simple, 2 composite)
+	toUni   map[uint32]string // ToUnicode CMap
+	simple  [256]rune         // simple-font encoding (0 = unknown)
+	utf16   bool              // composite font with a UCS-2 / UTF-16 CMap
+	hasBase bool              // simple table is meaningful
+}
+
+func (d *pdfDoc) newFont(fd pdfDict) *pdfFont {
+	f := &pdfFont{width: 1}
+	if fd == nil {
+		f.simple = winAnsi
+		f.hasBase = true
+		return f
+	}
+	if fd["Subtype"] == pdfName("Type0") {
+		f.width = 2
+		if enc, ok := d.resolve(fd["Encoding"]).(pdfName); ok {
+			e := string(enc)
+			f.utf16 = strings.Contains(e, "UCS2") || strings.Contains(e, "UTF16")
+		}
+	} else {
+		f.simple = winAnsi
+		f.hasBase = true
+		switch enc := d.resolve(fd["Encoding"]).(type) {
+		case pdfName:
+			f.applyBase(string(enc))
+		case pdfDict:
+			if b, ok := d.resolve(enc["BaseEncoding"]).(pdfName); ok {
+				f.applyBase(string(b))
+			}
+			if diffs, ok := d.resolve(enc["Differences"]).(pdfArray); ok {
+				code := 0
+				for _, el := range diffs {
+					switch v := d.resolve(el).(type) {
+					case float64:
+						code = int(v)
+					case pdfName:
+						if code >= 0 && code < 256 {
+							f.simple[code] = glyphRune(string(v))
+						}
+						code++
+					}
+				}
+			}
+		}
+	}
+	if s, ok := d.resolve(fd["ToUnicode"]).(pdfStream); ok {
+		if data, err := d.decode(s); err == nil {
+			f.toUni, f.width = parseCMap(data, f.width)
+		}
+	}
+	return f
+}
+
+func (f *pdfFont) applyBase(name string) {
+	if name == "MacRomanEncoding" {
+		f.simple = macRoman
+	}
+}
+
+func (f *pdfFont) decode(b []byte) string {
+	var sb strings.Builder
+	w := max(f.width, 1)
+	if f.utf16 && f.toUni == nil {
+		u := make([]uint16, 0, len(b)/2)
+		for i := 0; i+1 < len(b); i += 2 {
+			u = append(u, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(u))
+	}
+	for i := 0; i+w <= len(b); i += w {
+		var code uint32
+		for j := 0; j < w; j++ {
+			code = code<<8 | uint32(b[i+j])
+		}
+		if s, ok := f.toUni[code]; ok {
+			sb.WriteString(s)
+			continue
+		}
+		if w == 1 && f.hasBase {
+			if r := f.simple[code]; r != 0 {
+				sb.WriteRune(r)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// parseCMap reads bfchar / bfrange mappings from a ToUnicode CMap. The
+// code width comes from the codespace ranges; def is kept when they are
+// missing.
+func parseCMap(data []byte, def int) (map[uint32]string, int) {
+	m := map[uint32]string{}
+	width := 0
+	lx := &lexer{b: data}
+	var ops []any
+	mode := ""
+	for {
+		tok, ok := lx.next()
+		if !ok {
+			break
+		}
+		if kw, isKw := tok.(pdfKeyword); isKw {
+			switch kw {
+			case "begincodespacerange", "beginbfchar", "beginbfrange":
+				mode = string(kw)
+				ops = ops[:0]
+				continue
+			case "endcodespacerange":
+				for i := 0; i+1 < len(ops); i += 2 {
+					if s, ok := ops[i].(pdfString); ok && width == 0 {
+						width = len(s)
+					}
+				}
+			case "endbfchar":
+				for i := 0; i+1 < len(ops); i += 2 {
+					src, ok1 := ops[i].(pdfString)
+					dst, ok2 := ops[i+1].(pdfString)
+					if ok1 && ok2 {
+						m[codeOf(src)] = utf16BE(dst)
+					}
+				}
+			case "endbfrange":
+				for i := 0; i+2 < len(ops); i += 3 {
+					lo, ok1 := ops[i].(pdfString)
+					hi, ok2 := ops[i+1].(pdfString)
+					if !ok1 || !ok2 {
+						continue
+					}
+					a, b := codeOf(lo), codeOf(hi)
+					if b < a || b-a > 0xFFFF {
+						continue
+					}
+					switch dst := ops[i+2].(type) {
+					case pdfString:
+						u := utf16Units(dst)
+						if len(u) == 0 {
+							continue
+						}
+						for c := a; c <= b; c++ {
+							v := append([]uint16(nil), u...)
+							v[len(v)-1] += uint16(c - a)
+							m[c] = string(utf16.Decode(v))
+						}
+					case pdfArray:
+						for j, el := range dst {
+							if s, ok := el.(pdfString); ok && a+uint32(j) <= b {
+								m[a+uint32(j)] = utf16BE(s)
+							}
+						}
+					}
+				}
+			}
+			mode = ""
+			ops = ops[:0]
+			continue
+		}
+		if mode != "" {
+			lx.unread(tok)
+			ops = append(ops, lx.object(0))
+		}
+	}
+	if width == 0 {
+		width = def
+	}
+	return m, width
+}
+
+func codeOf(s pdfString) uint32 {
+	var c uint32
+	for i := 0; i < len(s) && i < 4; i++ {
+		c = c<<8 | uint32(s[i])
+	}
+	return c
+}
+
+func utf16Units(s pdfString) []uint16 {
+	u := make([]uint16, 0, len(s)/2)
+	for i := 0; i+1 < len(s); i += 2 {
+		u = append(u, uint16(s[i])<<8|uint16(s[i+1]))
+	}
+	if len(s)%2 == 1 {
+		u = append(u, uint16(s[len(s)-1]))
+	}
+	return u
+}
+
+func utf16BE(s pdfString) string {
+	return string(utf16.Decode(utf16Units(s)))
+}
+
+// ── Lexer ─────────────────────────────────────────────────────────────────
+
+type lexer struct {
+	b       []byte
+	pos     int
+	pending []any
+}
+
+func isPDFSpace(c byte) bool {
+	return c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0
+}
+
+func isPDFDelim(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func (l *lexer) unread(tok any) { l.pending = append(l.pending, tok) }
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		if isPDFSpace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.b) && l.b[l.pos] != '\n' && l.b[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+// keywordAhead consumes kw when it is the next token.
+func (l *lexer) keywordAhead(kw string) bool {
+	if len(l.pending) > 0 {
+		return false
+	}
+	l.skipSpace()
+	if bytes.HasPrefix(l.b[l.pos:], []byte(kw)) {
+		end := l.pos + len(kw)
+		if end == len(l.b) || isPDFSpace(l.b[end]) || isPDFDelim(l.b[end]) {
+			l.pos = end
+			return true
+		}
+	}
+	return false
+}
+
+// next returns the next token: float64, pdfName, pdfString, pdfKeyword
+// (operators, true/false/null, and the delimiters "[", "]", "<<", ">>").
+func (l *lexer) next() (any, bool) {
+	if n := len(l.pending); n > 0 {
+		tok := l.pending[n-1]
+		l.pending = l.pending[:n-1]
+		return tok, true
+	}
+	l.skipSpace()
+	if l.pos >= len(l.b) {
+		return nil, false
+	}
+	c := l.b[l.pos]
+	switch {
+	case c == '(':
+		return l.literalString(), true
+	case c == '<':
+		if l.pos+1 < len(l.b) && l.b[l.pos+1] == '<' {
+			l.pos += 2
+			return pdfKeyword("<<"), true
+		}
+		return l.hexString(), true
+	case c == '>':
+		if l.pos+1 < len(l.b) && l.b[l.pos+1] == '>' {
+			l.pos += 2
+			return pdfKeyword(">>"), true
+		}
+		l.pos++
+		return pdfKeyword(">"), true
+	case c == '[' || c == ']' || c == '{' || c == '}' || c == ')':
+		l.pos++
+		return pdfKeyword(string(c)), true
+	case c == '/':
+		l.pos++
+		return pdfName(l.name()), true
+	}
+	start := l.pos
+	for l.pos < len(l.b) && !isPDFSpace(l.b[l.pos]) && !isPDFDelim(l.b[l.pos]) {
+		l.pos++
+	}
+	word := string(l.b[start:l.pos])
+	if f, err := strconv.ParseFloat(word, 64); err == nil && (word[0] == '-' || word[0] == '+' || word[0] == '.' || (word[0] >= '0' && word[0] <= '9')) {
+		return f, true
+	}
+	return pdfKeyword(word), true
+}
+
+func (l *lexer) name() string {
+	var sb strings.Builder
+	for l.pos < len(l.b) && !isPDFSpace(l.b[l.pos]) && !isPDFDelim(l.b[l.pos]) {
+		c := l.b[l.pos]
+		if c == '#' && l.pos+2 < len(l.b) && isHexDigit(l.b[l.pos+1]) && isHexDigit(l.b[l.pos+2]) {
+			v, _ := strconv.ParseUint(string(l.b[l.pos+1:l.pos+3]), 16, 8)
+			sb.WriteByte(byte(v))
+			l.pos += 3
+			continue
+		}
+		sb.WriteByte(c)
+		l.pos++
+	}
+	return sb.String()
+}
+
+func (l *lexer) literalString() pdfString {
+	l.pos++ // (
+	var sb []byte
+	depth := 1
+	for l.pos < len(l.b) {
+		c := l.b[l.pos]
+		l.pos++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pdfString(sb)
+			}
+		case '\\':
+			if l.pos >= len(l.b) {
+				break
+			}
+			e := l.b[l.pos]
+			l.pos++
+			switch e {
+			case 'n':
+				sb = append(sb, '\n')
+			case 'r':
+				sb = append(sb, '\r')
+			case 't':
+				sb = append(sb, '\t')
+			case 'b':
+				sb = append(sb, '\b')
+			case 'f':
+				sb = append(sb, '\f')
+			case '\r':
+				if l.pos < len(l.b) && l.b[l.pos] == '\n' {
+					l.pos++
+				}
+			case '\n':
+			default:
+				if e >= '0' && e <= '7' {
+					v := int(e - '0')
+					for k := 0; k < 2 && l.pos < len(l.b) && l.b[l.pos] >= '0' && l.b[l.pos] <= '7'; k++ {
+						v = v*8 + int(l.b[l.pos]-'0')
+						l.pos++
+					}
+					sb = append(sb, byte(v))
+				} else {
+					sb = append(sb, e)
+				}
+			}
+			continue
+		}
+		sb = append(sb, c)
+	}
+	return pdfString(sb)
+}
+
+func (l *lexer) hexString() pdfString {
+	l.pos++ // <
+	var digits []byte
+	for l.pos < len(l.b) && l.b[l.pos] != '>' {
+		if isHexDigit(l.b[l.pos]) {
+			digits = append(digits, l.b[l.pos])
+		}
+		l.pos++
+	}
+	l.pos++ // >
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	_, _ = hex.Decode(out, digits)
+	return pdfString(out)
+}
+
+// object parses one object starting at the next token; indirect
+// references ("12 0 R") are recognised by lookahead.
+func (l *lexer) object(depth int) any {
+	tok, ok := l.next()
+	if !ok || depth > 64 {
+		return nil
+	}
+	switch v := tok.(type) {
+	case float64:
+		if v == math.Trunc(v) && v >= 0 && len(l.pending) == 0 {
+			save := l.pos
+			if g, ok := l.next(); ok {
+				if gf, isNum := g.(float64); isNum && gf == math.Trunc(gf) {
+					if r, ok := l.next(); ok && r == pdfKeyword("R") {
+						return pdfRef{int(v), int(gf)}
+					}
+				}
+			}
+			l.pos, l.pending = save, l.pending[:0]
+		}
+		return v
+	case pdfKeyword:
+		switch v {
+		case "[":
+			var arr pdfArray
+			for {
+				t, ok := l.next()
+				if !ok || t == pdfKeyword("]") {
+					return arr
+				}
+				l.unread(t)
+				arr = append(arr, l.object(depth+1))
+			}
+		case "<<":
+			d := pdfDict{}
+			for {
+				t, ok := l.next()
+				if !ok || t == pdfKeyword(">>") {
+					return d
+				}
+				key, isName := t.(pdfName)
+				if !isName {
+					continue
+				}
+				d[string(key)] = l.object(depth + 1)
+			}
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return v
+	}
+	return tok
+}
+
+// skipInlineImage moves past "ID <binary> EI" after a BI operator.
+func (l *lexer) skipInlineImage() {
+	i := bytes.Index(l.b[l.pos:], []byte("ID"))
+	if i < 0 {
+		l.pos = len(l.b)
+		return
+	}
+	p := l.pos + i + 3
+	for p < len(l.b) {
+		j := bytes.Index(l.b[p:], []byte("EI"))
+		if j < 0 {
+			l.pos = len(l.b)
+			return
+		}
+		p += j
+		before := p == 0 || isPDFSpace(l.b[p-1])
+		after := p+2 >= len(l.b) || isPDFSpace(l.b[p+2])
+		p += 2
+		if before && after {
+			break
+		}
+	}
+	l.pos = p
+	l.pending = l.pending[:0]
+}
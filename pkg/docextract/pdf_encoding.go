@@ -0,0 +1,65 @@
+package docextract
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Simple-font base encodings. StandardEncoding differs from WinAnsi only
+// in a few quote and dash positions, so WinAnsi stands in for it.
+var winAnsi, macRoman = byteTable(charmap.Windows1252), byteTable(charmap.Macintosh)
+
+func byteTable(cm *charmap.Charmap) [256]rune {
+	var t [256]rune
+	for i := 0x20; i < 256; i++ {
+		if r := cm.DecodeByte(byte(i)); r != '\ufffd' && r != 0x7F {
+			t[i] = r
+		}
+	}
+	t['\t'], t['\n'], t['\r'] = '\t', '\n', '\r'
+	return t
+}
+
+// glyphNames covers the Adobe glyph names common in /Differences arrays
+// beyond single letters, digits and uniXXXX forms.
+var glyphNames = map[string]rune{
+	"space": ' ', "exclam": '!', "quotedbl": '"', "numbersign": '#', "dollar": '$',
+	"percent": '%', "ampersand": '&', "quotesingle": '\'', "quoteright": '’',
+	"quoteleft": '‘', "quotedblleft": '“', "quotedblright": '”', "parenleft": '(',
+	"parenright": ')', "asterisk": '*', "plus": '+', "comma": ',', "hyphen": '-',
+	"minus": '−', "period": '.', "slash": '/', "colon": ':', "semicolon": ';',
+	"less": '<', "equal": '=', "greater": '>', "question": '?', "at": '@',
+	"bracketleft": '[', "backslash": '\\', "bracketright": ']', "asciicircum": '^',
+	"underscore": '_', "grave": '`', "braceleft": '{', "bar": '|', "braceright": '}',
+	"asciitilde": '~', "bullet": '•', "endash": '–', "emdash": '—', "ellipsis": '…',
+	"degree": '°', "copyright": '©', "registered": '®', "trademark": '™',
+	"section": '§', "paragraph": '¶', "dagger": '†', "daggerdbl": '‡',
+	"fi": 'ﬁ', "fl": 'ﬂ', "ff": 'ﬀ', "ffi": 'ﬃ', "ffl": 'ﬄ', "nbspace": ' ',
+	"zero": '0', "one": '1', "two": '2', "three": '3', "four": '4',
+	"five": '5', "six": '6', "seven": '7', "eight": '8', "nine": '9',
+	"Euro": '€', "sterling": '£', "yen": '¥', "cent": '¢', "multiply": '×',
+	"divide": '÷', "plusminus": '±', "germandbls": 'ß', "dotlessi": 'ı',
+}
+
+// glyphRune maps a glyph name to its character (0 when unknown).
+func glyphRune(name string) rune {
+	if i := strings.IndexByte(name, '.'); i > 0 {
+		name = name[:i] // "a.sc", "one.oldstyle"
+	}
+	if r, ok := glyphNames[name]; ok {
+		return r
+	}
+	if len(name) == 1 {
+		return rune(name[0])
+	}
+	for _, prefix := range []string{"uni", "u"} {
+		if hexPart, ok := strings.CutPrefix(name, prefix); ok && len(hexPart) >= 4 && len(hexPart) <= 6 {
+			if v, err := strconv.ParseUint(hexPart[:min(len(hexPart), 6)], 16, 32); err == nil {
+				return rune(v)
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,152 @@
+// Package eval — offline evaluation harness for agents.
+//
+// A Dataset is a list of Cases; each Case replays one or more user turns
+// through agent.Pool (one throwaway sandbox per case: a workspace copy
+// plus its own session and tool audit) and grades the replies against
+// its Expectations:
+//
+//	exact / contains / regex   — reply text
+//	json_schema                — reply is JSON matching a schema
+//	tool_called / tool_not_called — tool audit entries of the case session
+//	llm_judge                  — aiteam/judge.LLMScorer against a rubric
+//
+// A Run stores every case outcome; Compare lines two runs up side by side
+// so a SOUL.md / skill / model change can be checked for regressions.
+// Cases may carry a Script of canned model replies: runs with
+// Model == ScriptedModel use ScriptedClient instead of a real provider,
+// so datasets double as fully offline CI tests.
+//
+// Storage, rooted at {agentsDir}/.evals/:
+//
+//	datasets/{id}.json
+//	runs/{runId}.json
+package eval
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/Zyling-ai/zyhive/pkg/safefs"
+)
+
+// Expectation types.
+const (
+	ExpectExact         = "exact"
+	ExpectContains      = "contains"
+	ExpectRegex         = "regex"
+	ExpectJSONSchema    = "json_schema"
+	ExpectToolCalled    = "tool_called"
+	ExpectToolNotCalled = "tool_not_called"
+	ExpectLLMJudge      = "llm_judge"
+)
+
+// DefaultJudgeMinScore is the llm_judge pass threshold (0–10 average).
+const DefaultJudgeMinScore = 7.0
+
+// Dataset is a named, versionable list of eval cases.
+type Dataset struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	AgentID     string `json:"agentId,omitempty"` // default agent for runs
+	Cases       []Case `json:"cases"`
+	CreatedAt   int64  `json:"createdAt"`
+	UpdatedAt   int64  `json:"updatedAt"`
+}
+
+// Case is one scenario: Turns are sent in order within one session.
+type Case struct {
+	ID     string        `json:"id"`
+	Name   string        `json:"name,omitempty"`
+	Turns  []string      `json:"turns"`
+	Expect []Expectation `json:"expect"`
+	// Script holds the canned model replies used by offline runs, one per
+	// model call (a tool round-trip consumes two).
+	Script []ScriptTurn `json:"script,omitempty"`
+}
+
+// Expectation is one grader applied to a case.
+type Expectation struct {
+	Type string `json:"type"`
+	// Turn selects the reply checked by text graders (1-based; 0 = last).
+	Turn int `json:"turn,omitempty"`
+	// Value: expected text (exact / contains) or pattern (regex).
+	Value  string          `json:"value,omitempty"`
+	Schema json.RawMessage `json:"schema,omitempty"` // json_schema
+	// Tool assertions match audit entries by tool name, optionally
+	// narrowed by substrings of the input / result.
+	Tool           string `json:"tool,omitempty"`
+	InputContains  string `json:"inputContains,omitempty"`
+	ResultContains string `json:"resultContains,omitempty"`
+	MinCalls       int    `json:"minCalls,omitempty"` // tool_called; default 1
+	// Rubric is the llm_judge instruction; MinScore its pass threshold.
+	Rubric   string  `json:"rubric,omitempty"`
+	MinScore float64 `json:"minScore,omitempty"`
+}
+
+// Validate checks ids, turns and that every expectation is well-formed.
+func (d *Dataset) Validate() error {
+	if err := safefs.ValidateResourceID(d.ID); err != nil {
+		return fmt.Errorf("invalid dataset id %q: %w", d.ID, err)
+	}
+	if len(d.Cases) == 0 {
+		return fmt.Errorf("dataset %s has no cases", d.ID)
+	}
+	seen := make(map[string]bool, len(d.Cases))
+	for i, c := range d.Cases {
+		if c.ID == "" {
+			return fmt.Errorf("cases[%d]: id is required", i)
+		}
+		if seen[c.ID] {
+			return fmt.Errorf("cases[%d]: duplicate id %q", i, c.ID)
+		}
+		seen[c.ID] = true
+		if len(c.Turns) == 0 {
+			return fmt.Errorf("case %s: at least one turn is required", c.ID)
+		}
+		for j, e := range c.Expect {
+			if err := e.validate(len(c.Turns)); err != nil {
+				return fmt.Errorf("case %s expect[%d]: %w", c.ID, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Expectation) validate(turns int) error {
+	if e.Turn < 0 || e.Turn > turns {
+		return fmt.Errorf("turn %d out of range 1..%d", e.Turn, turns)
+	}
+	switch e.Type {
+	case ExpectExact, ExpectContains:
+		if e.Type == ExpectContains && e.Value == "" {
+			return fmt.Errorf("contains needs value")
+		}
+	case ExpectRegex:
+		if _, err := regexp.Compile(e.Value); err != nil {
+			return fmt.Errorf("regex: %w", err)
+		}
+	case ExpectJSONSchema:
+		if len(e.Schema) > 0 {
+			var v map[string]any
+			if err := json.Unmarshal(e.Schema, &v); err != nil {
+				return fmt.Errorf("schema must be a JSON object: %w", err)
+			}
+		}
+	case ExpectToolCalled, ExpectToolNotCalled:
+		if e.Tool == "" {
+			return fmt.Errorf("%s needs tool", e.Type)
+		}
+	case ExpectLLMJudge:
+		if e.Rubric == "" {
+			return fmt.Errorf("llm_judge needs rubric")
+		}
+		if e.MinScore < 0 || e.MinScore > 10 {
+			return fmt.Errorf("minScore must be within 0..10")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", e.Type)
+	}
+	return nil
+}
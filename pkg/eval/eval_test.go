@@ -0,0 +1,310 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/aiteam/judge"
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
+)
+
+func TestDatasetValidate(t *testing.T) {
+	ok := Dataset{ID: "smoke", Cases: []Case{{
+		ID:    "c1",
+		Turns: []string{"hi"},
+		Expect: []Expectation{
+			{Type: ExpectContains, Value: "hello"},
+			{Type: ExpectToolCalled, Tool: "read"},
+			{Type: ExpectLLMJudge, Rubric: "polite", MinScore: 6},
+		},
+	}}}
+	if err := ok.Validate(); err != nil {
+		t.Fatalf("valid dataset rejected: %v", err)
+	}
+	for name, mutate := range map[string]func(d *Dataset){
+		"bad id":       func(d *Dataset) { d.ID = "../x" },
+		"no cases":     func(d *Dataset) { d.Cases = nil },
+		"dup case":     func(d *Dataset) { d.Cases = append(d.Cases, d.Cases[0]) },
+		"no turns":     func(d *Dataset) { d.Cases[0].Turns = nil },
+		"turn range":   func(d *Dataset) { d.Cases[0].Expect[0].Turn = 2 },
+		"bad regex":    func(d *Dataset) { d.Cases[0].Expect[0] = Expectation{Type: ExpectRegex, Value: "("} },
+		"tool missing": func(d *Dataset) { d.Cases[0].Expect[1].Tool = "" },
+		"no rubric":    func(d *Dataset) { d.Cases[0].Expect[2].Rubric = "" },
+		"unknown type": func(d *Dataset) { d.Cases[0].Expect[0].Type = "vibes" },
+	} {
+		d := ok
+		d.Cases = []Case{ok.Cases[0]}
+		d.Cases[0].Expect = append([]Expectation(nil), ok.Cases[0].Expect...)
+		mutate(&d)
+		if err := d.Validate(); err == nil {
+			t.Errorf("%s: expected validation error", name)
+		}
+	}
+}
+
+func TestScriptedClientPlaysTurnsInOrder(t *testing.T) {
+	c := NewScriptedClient([]ScriptTurn{
+		{ToolCalls: []ScriptToolCall{{Name: "read"}}, InputTokens: 5},
+		{Text: "done"},
+	})
+	ctx := context.Background()
+	var stops []string
+	for i := 0; i < 2; i++ {
+		ch, err := c.Stream(ctx, &llm.ChatRequest{Model: "m"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for ev := range ch {
+			if ev.Type == llm.EventToolCall && string(ev.ToolCall.Input) != "{}" {
+				t.Errorf("tool input = %s, want {}", ev.ToolCall.Input)
+			}
+			if ev.Type == llm.EventStop {
+				stops = append(stops, ev.StopReason)
+			}
+		}
+	}
+	if strings.Join(stops, ",") != "tool_use,end_turn" {
+		t.Errorf("stops = %v", stops)
+	}
+	if _, err := c.Stream(ctx, &llm.ChatRequest{}); !errors.Is(err, ErrScriptExhausted) {
+		t.Errorf("third call err = %v, want ErrScriptExhausted", err)
+	}
+	if len(c.Requests()) != 3 {
+		t.Errorf("requests = %d", len(c.Requests()))
+	}
+}
+
+// fixedJudge returns a constant LLM-sourced score.
+type fixedJudge struct {
+	avg float64
+	got judge.Signals
+}
+
+func (f *fixedJudge) Score(s judge.Signals) judge.Score {
+	f.got = s
+	return judge.Score{Average: f.avg, Source: "llm", Rationale: "ok"}
+}
+
+func TestGraders(t *testing.T) {
+	turns := []TurnResult{
+		{Input: "q1", Output: ` {"answer": 42} `},
+		{Input: "q2", Output: "Order #123 shipped", ToolCalls: []string{"read"}},
+	}
+	audit := []toolaudit.Entry{
+		{Name: "read", Input: json.RawMessage(`{"file_path":"orders.csv"}`), Result: "123,shipped"},
+		{Name: "read", Input: json.RawMessage(`{"file_path":"notes.md"}`)},
+	}
+	schema := json.RawMessage(`{"type":"object","required":["answer"],"properties":{"answer":{"type":"number"}}}`)
+	j := &fixedJudge{avg: 6.5}
+	c := &Case{Expect: []Expectation{
+		{Type: ExpectContains, Value: "shipped"},              // last turn
+		{Type: ExpectExact, Turn: 1, Value: `{"answer": 42}`}, // trimmed
+		{Type: ExpectRegex, Value: `#\d+`},
+		{Type: ExpectJSONSchema, Turn: 1, Schema: schema},
+		{Type: ExpectJSONSchema, Turn: 2, Schema: schema}, // not JSON
+		{Type: ExpectToolCalled, Tool: "read", MinCalls: 2},
+		{Type: ExpectToolCalled, Tool: "read", InputContains: "orders", ResultContains: "shipped"},
+		{Type: ExpectToolNotCalled, Tool: "exec"},
+		{Type: ExpectToolNotCalled, Tool: "read"},                   // fails
+		{Type: ExpectLLMJudge, Rubric: "mentions the order number"}, // 6.5 < 7
+		{Type: ExpectLLMJudge, Rubric: "mentions the order number", MinScore: 6},
+	}}
+	want := []bool{true, true, true, true, false, true, true, true, false, false, true}
+
+	got := (&Grader{Judge: j}).Grade("a1", c, turns, audit)
+	for i, g := range got {
+		if g.Pass != want[i] {
+			t.Errorf("expect[%d] %s pass=%v want %v (%s)", i, g.Type, g.Pass, want[i], g.Detail)
+		}
+	}
+	if j.got.Rubric != "mentions the order number" || !strings.Contains(j.got.Notes, "Order #123") {
+		t.Errorf("judge signals = %+v", j.got)
+	}
+
+	noJudge := (*Grader)(nil).Grade("a1", &Case{Expect: c.Expect[9:10]}, turns, nil)
+	if noJudge[0].Pass || noJudge[0].Detail != "no judge configured" {
+		t.Errorf("nil grader = %+v", noJudge[0])
+	}
+}
+
+// TestHarnessOfflineRun replays a dataset through a real agent.Pool with
+// the scripted client: a tool round-trip, a plain reply and a case whose
+// script runs out.
+func TestHarnessOfflineRun(t *testing.T) {
+	agentsDir := t.TempDir()
+	mgr := agent.NewManager(agentsDir)
+	if _, err := mgr.CreateWithOpts(agent.CreateOpts{ID: "a1", Name: "A1", Model: "openai/gpt-4o"}); err != nil {
+		t.Fatal(err)
+	}
+	pool := agent.NewPool(&config.Config{}, mgr)
+	h := NewHarness(pool, &Grader{})
+
+	ds := &Dataset{ID: "smoke", AgentID: "a1", Cases: []Case{
+		{
+			ID:    "reads-soul",
+			Turns: []string{"what is in SOUL.md?"},
+			Expect: []Expectation{
+				{Type: ExpectToolCalled, Tool: "read", InputContains: "SOUL.md"},
+				{Type: ExpectContains, Value: "soul"},
+			},
+			Script: []ScriptTurn{
+				{ToolCalls: []ScriptToolCall{{Name: "read", Input: json.RawMessage(`{"file_path":"SOUL.md"}`)}}, InputTokens: 10, OutputTokens: 2},
+				{Text: "It describes my soul.", InputTokens: 20, OutputTokens: 5},
+			},
+		},
+		{
+			ID:     "two-turns",
+			Turns:  []string{"hi", "bye"},
+			Expect: []Expectation{{Type: ExpectExact, Turn: 1, Value: "hello"}, {Type: ExpectToolNotCalled, Tool: "read"}},
+			Script: []ScriptTurn{{Text: "hello"}, {Text: "goodbye"}},
+		},
+		{
+			ID:     "exhausted",
+			Turns:  []string{"hi"},
+			Expect: []Expectation{{Type: ExpectContains, Value: "x"}},
+		},
+	}}
+	if err := ds.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	run := h.Run(context.Background(), ds, RunOpts{Model: ScriptedModel, Label: "base"})
+	if run.Status != RunDone || len(run.Cases) != 3 {
+		t.Fatalf("run = %+v", run)
+	}
+	soul, two, ex := run.Cases[0], run.Cases[1], run.Cases[2]
+	if !soul.Pass || soul.Turns[0].ToolCalls[0] != "read" {
+		t.Errorf("reads-soul = %+v", soul)
+	}
+	if !two.Pass || two.Turns[1].Output != "goodbye" {
+		t.Errorf("two-turns = %+v", two)
+	}
+	if ex.Pass || ex.Error == "" {
+		t.Errorf("exhausted case should error: %+v", ex)
+	}
+	s := run.Summary
+	if s.Total != 3 || s.Passed != 2 || s.Errored != 1 || s.InputTokens != 30 {
+		t.Errorf("summary = %+v", s)
+	}
+
+	// Round-trip through the store, then compare with a run where the
+	// first case regresses and the third no longer exists.
+	store := NewStore(agentsDir)
+	if err := store.SaveRun(run); err != nil {
+		t.Fatal(err)
+	}
+	head := &Run{ID: "head", Cases: []CaseResult{
+		{CaseID: "two-turns", Pass: true},
+		{CaseID: "reads-soul", Pass: false},
+		{CaseID: "new", Pass: true},
+	}}
+	if err := store.SaveRun(head); err != nil {
+		t.Fatal(err)
+	}
+	base, err := store.GetRun(run.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmp := Compare(base, head)
+	changes := map[string]string{}
+	for _, d := range cmp.Cases {
+		changes[d.CaseID] = d.Change
+	}
+	if changes["reads-soul"] != ChangeRegressed || changes["two-turns"] != ChangeSame ||
+		changes["new"] != ChangeAdded || changes["exhausted"] != ChangeRemoved || cmp.Regressions != 1 {
+		t.Errorf("compare = %v (regressions %d)", changes, cmp.Regressions)
+	}
+	runs, _ := store.ListRuns("")
+	if len(runs) != 2 || runs[0].Cases != nil {
+		t.Errorf("ListRuns = %+v", runs)
+	}
+}
+
+// TestHarnessSandboxesReplays checks that tool side effects, the session
+// and the tool audit of a run stay out of the live agent.
+func TestHarnessSandboxesReplays(t *testing.T) {
+	agentsDir := t.TempDir()
+	mgr := agent.NewManager(agentsDir)
+	ag, err := mgr.CreateWithOpts(agent.CreateOpts{ID: "a1", Name: "A1", Model: "openai/gpt-4o"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmp := t.TempDir()
+	h := NewHarness(agent.NewPool(&config.Config{}, mgr), &Grader{})
+	h.TempDir = tmp
+
+	ds := &Dataset{ID: "writes", AgentID: "a1", Cases: []Case{{
+		ID:     "write-note",
+		Turns:  []string{"take a note"},
+		Expect: []Expectation{{Type: ExpectToolCalled, Tool: "write", InputContains: "note.txt"}},
+		Script: []ScriptTurn{
+			{ToolCalls: []ScriptToolCall{{Name: "write", Input: json.RawMessage(`{"file_path":"note.txt","content":"x"}`)}}},
+			{Text: "done"},
+		},
+	}, {
+		// self_set_env would persist into the live config.json; it is
+		// stubbed but still gradeable.
+		ID:     "set-env",
+		Turns:  []string{"remember my token"},
+		Expect: []Expectation{{Type: ExpectToolCalled, Tool: "self_set_env", InputContains: "TOKEN"}},
+		Script: []ScriptTurn{
+			{ToolCalls: []ScriptToolCall{{Name: "self_set_env", Input: json.RawMessage(`{"key":"TOKEN","value":"x"}`)}}},
+			{Text: "done"},
+		},
+	}}}
+	run := h.Run(context.Background(), ds, RunOpts{Model: ScriptedModel})
+	if run.Status != RunDone || !run.Cases[0].Pass || !run.Cases[1].Pass {
+		t.Fatalf("run = %+v", run)
+	}
+	if live, _ := mgr.Get("a1"); live.Env["TOKEN"] != "" {
+		t.Errorf("self_set_env reached the live agent: %v", live.Env)
+	}
+	agentDir := filepath.Dir(ag.WorkspaceDir)
+	for _, p := range []string{
+		filepath.Join(ag.WorkspaceDir, "note.txt"),
+		filepath.Join(agentDir, "tool-audit"),
+		filepath.Join(ag.SessionDir, "eval"),
+	} {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s leaked into the live agent", p)
+		}
+	}
+	if left, _ := os.ReadDir(tmp); len(left) != 0 {
+		t.Errorf("sandbox not removed: %v", left)
+	}
+}
+
+func TestStoreDatasets(t *testing.T) {
+	s := NewStore(t.TempDir())
+	d := &Dataset{ID: "d1", Cases: []Case{{ID: "c", Turns: []string{"x"}}}}
+	if err := s.SaveDataset(d); err != nil {
+		t.Fatal(err)
+	}
+	created := d.CreatedAt
+	if created == 0 || d.Name != "d1" {
+		t.Fatalf("saved = %+v", d)
+	}
+	d2 := &Dataset{ID: "d1", Name: "renamed", Cases: d.Cases}
+	if err := s.SaveDataset(d2); err != nil || d2.CreatedAt != created {
+		t.Fatalf("update kept createdAt? %v %d != %d", err, d2.CreatedAt, created)
+	}
+	if list, _ := s.ListDatasets(); len(list) != 1 || list[0].Name != "renamed" {
+		t.Errorf("list = %+v", list)
+	}
+	if err := s.SaveDataset(&Dataset{ID: "bad"}); err == nil {
+		t.Error("invalid dataset saved")
+	}
+	if err := s.DeleteDataset("d1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetDataset("d1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get deleted = %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package eval
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/aiteam/judge"
+	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
+)
+
+// GradeResult is the outcome of one Expectation.
+type GradeResult struct {
+	Type   string  `json:"type"`
+	Pass   bool    `json:"pass"`
+	Score  float64 `json:"score,omitempty"` // llm_judge average (0–10)
+	Detail string  `json:"detail,omitempty"`
+}
+
+// Grader applies expectations to a finished case.
+type Grader struct {
+	// Judge scores llm_judge expectations, normally a judge.LLMScorer.
+	// nil fails those expectations with "no judge configured".
+	Judge judge.Scorer
+}
+
+// Grade evaluates every expectation of c. audit holds the tool audit
+// entries recorded for the case session.
+func (g *Grader) Grade(agentID string, c *Case, turns []TurnResult, audit []toolaudit.Entry) []GradeResult {
+	out := make([]GradeResult, 0, len(c.Expect))
+	for _, e := range c.Expect {
+		r := GradeResult{Type: e.Type}
+		switch e.Type {
+		case ExpectExact, ExpectContains, ExpectRegex, ExpectJSONSchema:
+			r.Pass, r.Detail = gradeText(e, replyFor(e, turns))
+		case ExpectToolCalled, ExpectToolNotCalled:
+			r.Pass, r.Detail = gradeTool(e, audit)
+		case ExpectLLMJudge:
+			r.Pass, r.Score, r.Detail = g.gradeJudge(agentID, e, turns)
+		default:
+			r.Detail = "unknown expectation type"
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// replyFor returns the reply the expectation targets (Turn, 1-based; 0 = last).
+func replyFor(e Expectation, turns []TurnResult) string {
+	if len(turns) == 0 {
+		return ""
+	}
+	i := len(turns) - 1
+	if e.Turn > 0 && e.Turn <= len(turns) {
+		i = e.Turn - 1
+	}
+	return turns[i].Output
+}
+
+func gradeText(e Expectation, reply string) (bool, string) {
+	switch e.Type {
+	case ExpectExact:
+		if strings.TrimSpace(reply) == strings.TrimSpace(e.Value) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("want %q, got %q", clip(e.Value, 120), clip(reply, 120))
+	case ExpectContains:
+		if strings.Contains(reply, e.Value) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("reply does not contain %q", clip(e.Value, 120))
+	case ExpectRegex:
+		re, err := regexp.Compile(e.Value)
+		if err != nil {
+			return false, "bad regex: " + err.Error()
+		}
+		if re.MatchString(reply) {
+			return true, ""
+		}
+		return false, fmt.Sprintf("reply does not match /%s/", e.Value)
+	case ExpectJSONSchema:
+		if _, err := agent.ValidateJSONReply(reply, e.Schema); err != nil {
+			return false, err.Error()
+		}
+		return true, ""
+	}
+	return false, "not a text expectation"
+}
+
+func gradeTool(e Expectation, audit []toolaudit.Entry) (bool, string) {
+	n := 0
+	for _, en := range audit {
+		if en.Name != e.Tool {
+			continue
+		}
+		if e.InputContains != "" && !strings.Contains(string(en.Input), e.InputContains) {
+			continue
+		}
+		if e.ResultContains != "" && !strings.Contains(en.Result, e.ResultContains) {
+			continue
+		}
+		n++
+	}
+	if e.Type == ExpectToolNotCalled {
+		if n == 0 {
+			return true, ""
+		}
+		return false, fmt.Sprintf("%s called %d time(s)", e.Tool, n)
+	}
+	min := e.MinCalls
+	if min <= 0 {
+		min = 1
+	}
+	if n >= min {
+		return true, fmt.Sprintf("%s called %d time(s)", e.Tool, n)
+	}
+	return false, fmt.Sprintf("%s called %d time(s), want ≥ %d", e.Tool, n, min)
+}
+
+func (g *Grader) gradeJudge(agentID string, e Expectation, turns []TurnResult) (bool, float64, string) {
+	if g == nil || g.Judge == nil {
+		return false, 0, "no judge configured"
+	}
+	sc := g.Judge.Score(judge.Signals{
+		AgentID: agentID,
+		Period:  "eval",
+		Notes:   transcript(turns),
+		Rubric:  e.Rubric,
+	})
+	if sc.Source != "llm" {
+		// LLMScorer fell back to the heuristic: no real verdict.
+		return false, 0, "judge unavailable: " + sc.Rationale
+	}
+	min := e.MinScore
+	if min == 0 {
+		min = DefaultJudgeMinScore
+	}
+	detail := fmt.Sprintf("%.1f/10 (min %.1f) %s", sc.Average, min, sc.Rationale)
+	return sc.Average >= min, sc.Average, detail
+}
+
+// transcript renders the case conversation for the judge.
+func transcript(turns []TurnResult) string {
+	var b strings.Builder
+	for i, t := range turns {
+		fmt.Fprintf(&b, "[turn %d] User: %s\n", i+1, t.Input)
+		if len(t.ToolCalls) > 0 {
+			fmt.Fprintf(&b, "[turn %d] Tools used: %s\n", i+1, strings.Join(t.ToolCalls, ", "))
+		}
+		fmt.Fprintf(&b, "[turn %d] Agent: %s\n\n", i+1, t.Output)
+	}
+	return b.String()
+}
+
+func clip(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n]) + "…"
+}
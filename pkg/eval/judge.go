@@ -0,0 +1,43 @@
+package eval
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/aiteam/judge"
+	"github.com/Zyling-ai/zyhive/pkg/aiteam/promptdef"
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+)
+
+// NewJudge builds the llm_judge scorer from cfg.Aiteam.Judge — the same
+// model, limits and transcript guard the AI-team judge uses. modelID
+// overrides the configured judge model. Returns (nil, nil) when no judge
+// model is configured.
+func NewJudge(cfg *config.Config, modelID string) (judge.Scorer, error) {
+	if modelID == "" {
+		modelID = cfg.Aiteam.Judge.Model
+	}
+	if modelID == "" {
+		return nil, nil
+	}
+	m := cfg.FindModel(modelID)
+	if m == nil {
+		return nil, fmt.Errorf("judge model %q not found", modelID)
+	}
+	apiKey, baseURL := config.ResolveCredentials(m, cfg.Providers)
+	if apiKey == "" && llm.RequiresAPIKey(m.Provider) {
+		return nil, fmt.Errorf("judge model %q has no API key", modelID)
+	}
+	timeout := 30 * time.Second
+	if cfg.Aiteam.Judge.TimeoutMs > 0 {
+		timeout = time.Duration(cfg.Aiteam.Judge.TimeoutMs) * time.Millisecond
+	}
+	return judge.LLMScorer{
+		Call: judge.LLMCallFromClient(agent.LLMClient(m, baseURL), m.Model, apiKey,
+			cfg.Aiteam.Judge.MaxTokens, timeout),
+		PromptGuard: promptdef.New(nil),
+		Fallback:    judge.HeuristicScorer{},
+	}, nil
+}
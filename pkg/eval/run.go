@@ -0,0 +1,297 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Zyling-ai/zyhive/pkg/agent"
+	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
+)
+
+// Run statuses.
+const (
+	RunRunning = "running"
+	RunDone    = "done"
+	RunFailed  = "failed" // the run itself broke (bad agent / model), not a case
+)
+
+// TurnResult is one replayed user turn.
+type TurnResult struct {
+	Input        string   `json:"input"`
+	Output       string   `json:"output"`
+	ToolCalls    []string `json:"toolCalls,omitempty"` // tool names, call order
+	InputTokens  int      `json:"inputTokens,omitempty"`
+	OutputTokens int      `json:"outputTokens,omitempty"`
+	DurationMs   int64    `json:"durationMs"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// CaseResult is the graded outcome of one Case.
+type CaseResult struct {
+	CaseID     string        `json:"caseId"`
+	Name       string        `json:"name,omitempty"`
+	SessionID  string        `json:"sessionId"`
+	Pass       bool          `json:"pass"`
+	Turns      []TurnResult  `json:"turns"`
+	Grades     []GradeResult `json:"grades"`
+	Error      string        `json:"error,omitempty"` // replay error; case counts as errored
+	DurationMs int64         `json:"durationMs"`
+}
+
+// Summary aggregates a run.
+type Summary struct {
+	Total        int     `json:"total"`
+	Passed       int     `json:"passed"`
+	Failed       int     `json:"failed"`
+	Errored      int     `json:"errored"`
+	PassRate     float64 `json:"passRate"` // 0..1
+	InputTokens  int     `json:"inputTokens"`
+	OutputTokens int     `json:"outputTokens"`
+}
+
+// Run is one execution of a dataset against an agent + model.
+type Run struct {
+	ID          string       `json:"id"`
+	DatasetID   string       `json:"datasetId"`
+	DatasetName string       `json:"datasetName,omitempty"`
+	AgentID     string       `json:"agentId"`
+	Model       string       `json:"model,omitempty"` // model id; "" = agent default
+	Label       string       `json:"label,omitempty"` // free text, e.g. "soul v2"
+	Status      string       `json:"status"`
+	StartedAt   int64        `json:"startedAt"`
+	EndedAt     int64        `json:"endedAt,omitempty"`
+	Cases       []CaseResult `json:"cases,omitempty"`
+	Summary     Summary      `json:"summary"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// Replayer sends one turn to an agent; *agent.Pool implements it.
+type Replayer interface {
+	Replay(ctx context.Context, agentID, message string, opts agent.ReplayOpts) (agent.ReplayResult, error)
+}
+
+// RunOpts selects what a run executes.
+type RunOpts struct {
+	AgentID string // "" = Dataset.AgentID
+	Model   string // cfg.Models id, ScriptedModel, or "" for the agent default
+	Label   string
+	CaseIDs []string // subset; empty = all cases
+}
+
+// Harness replays datasets and grades the results. Each case runs in its
+// own throwaway sandbox (see agent.ReplayOpts.Sandbox), removed when the
+// case is graded.
+type Harness struct {
+	Replayer Replayer
+	Grader   *Grader
+	// TempDir is where case sandboxes are created; "" = os.TempDir().
+	TempDir string
+}
+
+// NewHarness wires a harness to the agent pool. grader may be nil
+// (llm_judge expectations then fail).
+func NewHarness(pool *agent.Pool, grader *Grader) *Harness {
+	return &Harness{Replayer: pool, Grader: grader}
+}
+
+// NewRunID returns a sortable, unique run id.
+func NewRunID() string {
+	return time.Now().UTC().Format("20060102-150405") + "-" + uuid.NewString()[:8]
+}
+
+// Run executes ds sequentially, one fresh session per case. It always
+// returns a Run; setup problems are reported in Run.Error / RunFailed.
+func (h *Harness) Run(ctx context.Context, ds *Dataset, opts RunOpts) *Run {
+	return h.RunWithID(ctx, NewRunID(), ds, opts)
+}
+
+// RunWithID is Run with a caller-chosen id (the API hands the id out
+// before the run finishes).
+func (h *Harness) RunWithID(ctx context.Context, id string, ds *Dataset, opts RunOpts) *Run {
+	run := &Run{
+		ID:          id,
+		DatasetID:   ds.ID,
+		DatasetName: ds.Name,
+		AgentID:     opts.AgentID,
+		Model:       opts.Model,
+		Label:       opts.Label,
+		Status:      RunRunning,
+		StartedAt:   time.Now().UnixMilli(),
+	}
+	if run.AgentID == "" {
+		run.AgentID = ds.AgentID
+	}
+	finish := func(status, errMsg string) *Run {
+		run.Status, run.Error = status, errMsg
+		run.EndedAt = time.Now().UnixMilli()
+		run.Summary = summarize(run.Cases)
+		return run
+	}
+	if run.AgentID == "" {
+		return finish(RunFailed, "no agent: set agentId on the dataset or the run")
+	}
+
+	want := make(map[string]bool, len(opts.CaseIDs))
+	for _, id := range opts.CaseIDs {
+		want[id] = true
+	}
+	for i := range ds.Cases {
+		c := &ds.Cases[i]
+		if len(want) > 0 && !want[c.ID] {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return finish(RunFailed, err.Error())
+		}
+		run.Cases = append(run.Cases, h.runCase(ctx, run, c))
+	}
+	if len(want) > 0 && len(run.Cases) == 0 {
+		return finish(RunFailed, "none of the requested cases exist")
+	}
+	return finish(RunDone, "")
+}
+
+func (h *Harness) runCase(ctx context.Context, run *Run, c *Case) CaseResult {
+	start := time.Now()
+	cr := CaseResult{
+		CaseID:    c.ID,
+		Name:      c.Name,
+		SessionID: fmt.Sprintf("eval-%s-%s", run.ID, c.ID),
+	}
+	sandbox, err := os.MkdirTemp(h.TempDir, "zyhive-eval-")
+	if err != nil {
+		cr.Error = fmt.Sprintf("create sandbox: %v", err)
+		return cr
+	}
+	defer os.RemoveAll(sandbox)
+	audit := toolaudit.New(sandbox)
+	opts := agent.ReplayOpts{ModelID: run.Model, SessionID: cr.SessionID, Sandbox: sandbox}
+	if run.Model == ScriptedModel {
+		opts.LLM = NewScriptedClient(c.Script)
+	}
+	for _, input := range c.Turns {
+		t0 := time.Now()
+		res, err := h.Replayer.Replay(ctx, run.AgentID, input, opts)
+		tr := TurnResult{
+			Input:        input,
+			Output:       res.Text,
+			InputTokens:  res.InputTokens,
+			OutputTokens: res.OutputTokens,
+			DurationMs:   time.Since(t0).Milliseconds(),
+		}
+		for _, tc := range res.ToolCalls {
+			tr.ToolCalls = append(tr.ToolCalls, tc.Name)
+		}
+		if err != nil {
+			tr.Error = err.Error()
+			cr.Error = fmt.Sprintf("turn %d: %v", len(cr.Turns)+1, err)
+		}
+		cr.Turns = append(cr.Turns, tr)
+		if err != nil {
+			break
+		}
+	}
+
+	if cr.Error == "" {
+		entries, _ := audit.ListBySession(cr.SessionID, 500)
+		cr.Grades = h.Grader.Grade(run.AgentID, c, cr.Turns, entries)
+		cr.Pass = true
+		for _, g := range cr.Grades {
+			cr.Pass = cr.Pass && g.Pass
+		}
+	}
+	cr.DurationMs = time.Since(start).Milliseconds()
+	return cr
+}
+
+func summarize(cases []CaseResult) Summary {
+	var s Summary
+	for _, c := range cases {
+		s.Total++
+		switch {
+		case c.Error != "":
+			s.Errored++
+		case c.Pass:
+			s.Passed++
+		default:
+			s.Failed++
+		}
+		for _, t := range c.Turns {
+			s.InputTokens += t.InputTokens
+			s.OutputTokens += t.OutputTokens
+		}
+	}
+	if s.Total > 0 {
+		s.PassRate = float64(s.Passed) / float64(s.Total)
+	}
+	return s
+}
+
+// Case comparison outcomes.
+const (
+	ChangeSame      = "same"
+	ChangeFixed     = "fixed"     // failed in base, passes in head
+	ChangeRegressed = "regressed" // passed in base, fails in head
+	ChangeAdded     = "added"     // only in head
+	ChangeRemoved   = "removed"   // only in base
+)
+
+// CaseDiff lines one case up across two runs.
+type CaseDiff struct {
+	CaseID string      `json:"caseId"`
+	Name   string      `json:"name,omitempty"`
+	Base   *CaseResult `json:"base,omitempty"`
+	Head   *CaseResult `json:"head,omitempty"`
+	Change string      `json:"change"`
+}
+
+// Comparison is the side-by-side report of two runs.
+type Comparison struct {
+	Base        Run        `json:"base"` // Cases stripped; see Cases
+	Head        Run        `json:"head"`
+	Cases       []CaseDiff `json:"cases"`
+	Regressions int        `json:"regressions"`
+	Fixes       int        `json:"fixes"`
+}
+
+// Compare diffs two runs case by case (head order first, then cases only
+// present in base). The runs are usually of the same dataset.
+func Compare(base, head *Run) Comparison {
+	cmp := Comparison{Base: *base, Head: *head}
+	cmp.Base.Cases, cmp.Head.Cases = nil, nil
+
+	baseByID := make(map[string]*CaseResult, len(base.Cases))
+	for i := range base.Cases {
+		baseByID[base.Cases[i].CaseID] = &base.Cases[i]
+	}
+	seen := make(map[string]bool, len(head.Cases))
+	for i := range head.Cases {
+		h := &head.Cases[i]
+		seen[h.CaseID] = true
+		d := CaseDiff{CaseID: h.CaseID, Name: h.Name, Head: h, Base: baseByID[h.CaseID]}
+		switch {
+		case d.Base == nil:
+			d.Change = ChangeAdded
+		case d.Base.Pass && !h.Pass:
+			d.Change = ChangeRegressed
+			cmp.Regressions++
+		case !d.Base.Pass && h.Pass:
+			d.Change = ChangeFixed
+			cmp.Fixes++
+		default:
+			d.Change = ChangeSame
+		}
+		cmp.Cases = append(cmp.Cases, d)
+	}
+	for i := range base.Cases {
+		b := &base.Cases[i]
+		if !seen[b.CaseID] {
+			cmp.Cases = append(cmp.Cases, CaseDiff{CaseID: b.CaseID, Name: b.Name, Base: b, Change: ChangeRemoved})
+		}
+	}
+	return cmp
+}
@@ -0,0 +1,104 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+)
+
+// ScriptedModel is the Run.Model value that replays Case.Script through
+// ScriptedClient instead of calling a provider.
+const ScriptedModel = "scripted"
+
+// ErrScriptExhausted is returned once every scripted reply was used.
+var ErrScriptExhausted = errors.New("eval: script exhausted")
+
+// ScriptTurn is one canned model reply.
+type ScriptTurn struct {
+	Text         string           `json:"text,omitempty"`
+	ToolCalls    []ScriptToolCall `json:"toolCalls,omitempty"`
+	Error        string           `json:"error,omitempty"` // simulate a provider failure
+	InputTokens  int              `json:"inputTokens,omitempty"`
+	OutputTokens int              `json:"outputTokens,omitempty"`
+}
+
+// ScriptToolCall is a tool call the scripted model "makes".
+type ScriptToolCall struct {
+	Name  string          `json:"name"`
+	Input json.RawMessage `json:"input,omitempty"`
+}
+
+// ScriptedClient is a deterministic llm.Client: each Stream call plays the
+// next ScriptTurn. Requests are kept so tests can inspect what was sent.
+type ScriptedClient struct {
+	mu       sync.Mutex
+	turns    []ScriptTurn
+	next     int
+	requests []llm.ChatRequest
+}
+
+// NewScriptedClient returns a client replaying turns in order.
+func NewScriptedClient(turns []ScriptTurn) *ScriptedClient {
+	return &ScriptedClient{turns: turns}
+}
+
+// Requests returns the requests received so far.
+func (c *ScriptedClient) Requests() []llm.ChatRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]llm.ChatRequest(nil), c.requests...)
+}
+
+// Stream implements llm.Client.
+func (c *ScriptedClient) Stream(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamEvent, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, *req)
+	if c.next >= len(c.turns) {
+		c.mu.Unlock()
+		return nil, ErrScriptExhausted
+	}
+	turn := c.turns[c.next]
+	n := c.next
+	c.next++
+	c.mu.Unlock()
+
+	ch := make(chan llm.StreamEvent, len(turn.ToolCalls)+4)
+	defer close(ch)
+	if turn.Error != "" {
+		ch <- llm.StreamEvent{Type: llm.EventError, Err: errors.New(turn.Error)}
+		return ch, nil
+	}
+	if turn.Text != "" {
+		ch <- llm.StreamEvent{Type: llm.EventTextDelta, Text: turn.Text}
+	}
+	for i, tc := range turn.ToolCalls {
+		input := tc.Input
+		if len(input) == 0 {
+			input = json.RawMessage(`{}`)
+		}
+		ch <- llm.StreamEvent{Type: llm.EventToolCall, ToolCall: &llm.ToolCall{
+			ID:    fmt.Sprintf("scripted_%d_%d", n, i),
+			Name:  tc.Name,
+			Input: input,
+		}}
+	}
+	if turn.InputTokens > 0 || turn.OutputTokens > 0 {
+		ch <- llm.StreamEvent{Type: llm.EventUsage, Usage: &llm.Usage{
+			InputTokens:  turn.InputTokens,
+			OutputTokens: turn.OutputTokens,
+		}}
+	}
+	stop := "end_turn"
+	if len(turn.ToolCalls) > 0 {
+		stop = "tool_use"
+	}
+	ch <- llm.StreamEvent{Type: llm.EventStop, StopReason: stop}
+	return ch, nil
+}
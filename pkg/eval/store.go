@@ -0,0 +1,169 @@
+package eval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+	"github.com/Zyling-ai/zyhive/pkg/safefs"
+)
+
+// ErrNotFound is returned for unknown dataset / run ids.
+var ErrNotFound = errors.New("eval: not found")
+
+// Store persists datasets and runs as one JSON file each.
+type Store struct {
+	dir string
+}
+
+// NewStore returns the store rooted at {agentsDir}/.evals.
+func NewStore(agentsDir string) *Store {
+	return &Store{dir: filepath.Join(agentsDir, ".evals")}
+}
+
+func (s *Store) datasetPath(id string) string {
+	return filepath.Join(s.dir, "datasets", id+".json")
+}
+
+func (s *Store) runPath(id string) string {
+	return filepath.Join(s.dir, "runs", id+".json")
+}
+
+// ListDatasets returns every dataset, by name.
+func (s *Store) ListDatasets() ([]Dataset, error) {
+	var out []Dataset
+	err := s.each("datasets", func(raw []byte) {
+		var d Dataset
+		if json.Unmarshal(raw, &d) == nil {
+			out = append(out, d)
+		}
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, err
+}
+
+// GetDataset loads one dataset.
+func (s *Store) GetDataset(id string) (*Dataset, error) {
+	var d Dataset
+	if err := s.load(id, s.datasetPath, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// SaveDataset validates and writes d, keeping CreatedAt of an existing copy.
+func (s *Store) SaveDataset(d *Dataset) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+	now := time.Now().UnixMilli()
+	if prev, err := s.GetDataset(d.ID); err == nil {
+		d.CreatedAt = prev.CreatedAt
+	} else if d.CreatedAt == 0 {
+		d.CreatedAt = now
+	}
+	d.UpdatedAt = now
+	if d.Name == "" {
+		d.Name = d.ID
+	}
+	return s.write(s.datasetPath(d.ID), d)
+}
+
+// DeleteDataset removes a dataset; its runs are kept for history.
+func (s *Store) DeleteDataset(id string) error {
+	if err := safefs.ValidateResourceID(id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.datasetPath(id)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// SaveRun writes a run (also used while it is in progress).
+func (s *Store) SaveRun(r *Run) error {
+	if err := safefs.ValidateResourceID(r.ID); err != nil {
+		return fmt.Errorf("invalid run id %q: %w", r.ID, err)
+	}
+	return s.write(s.runPath(r.ID), r)
+}
+
+// GetRun loads one run with its case results.
+func (s *Store) GetRun(id string) (*Run, error) {
+	var r Run
+	if err := s.load(id, s.runPath, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// ListRuns returns run summaries (Cases omitted), newest first,
+// optionally only those of datasetID.
+func (s *Store) ListRuns(datasetID string) ([]Run, error) {
+	var out []Run
+	err := s.each("runs", func(raw []byte) {
+		var r Run
+		if json.Unmarshal(raw, &r) != nil {
+			return
+		}
+		if datasetID != "" && r.DatasetID != datasetID {
+			return
+		}
+		r.Cases = nil
+		out = append(out, r)
+	})
+	sort.Slice(out, func(i, j int) bool { return out[i].StartedAt > out[j].StartedAt })
+	return out, err
+}
+
+func (s *Store) load(id string, path func(string) string, v any) error {
+	if err := safefs.ValidateResourceID(id); err != nil {
+		return err
+	}
+	raw, err := os.ReadFile(path(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return ErrNotFound
+		}
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
+
+func (s *Store) write(path string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return persist.AtomicWrite(path, data, 0o644)
+}
+
+func (s *Store) each(sub string, fn func([]byte)) error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, sub))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(s.dir, sub, e.Name()))
+		if err != nil {
+			continue
+		}
+		fn(raw)
+	}
+	return nil
+}
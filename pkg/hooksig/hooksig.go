@@ -0,0 +1,72 @@
+// Package hooksig is the signing scheme shared by ZyHive's signed webhooks
+// (the aiteam revenue webhook and the webhook channel):
+//   - the signature is the hex HMAC-SHA256 of the raw body;
+//   - the body carries a unix-seconds timestamp checked against a
+//     freshness window;
+//   - the body carries a single-use nonce, remembered in a bounded FIFO.
+package hooksig
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Sign returns the hex HMAC-SHA256 of body under secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is Sign(secret, body), in constant time.
+func Verify(secret, body []byte, signature string) bool {
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(Sign(secret, body))) == 1
+}
+
+// Fresh reports whether the unix-seconds ts lies within window of now.
+func Fresh(ts int64, window time.Duration) bool {
+	d := time.Since(time.Unix(ts, 0))
+	return d <= window && d >= -window
+}
+
+// NonceCache remembers the last size nonces. Older nonces are evicted
+// first; replays that old must be caught by the Fresh check. Safe for
+// concurrent use.
+type NonceCache struct {
+	mu    sync.Mutex
+	size  int
+	seen  map[string]struct{}
+	order []string // FIFO for eviction
+}
+
+// NewNonceCache returns a cache holding up to size nonces.
+func NewNonceCache(size int) *NonceCache {
+	return &NonceCache{size: size, seen: make(map[string]struct{})}
+}
+
+// Record reports false if nonce was already seen, otherwise remembers it.
+func (c *NonceCache) Record(nonce string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.seen[nonce]; ok {
+		return false
+	}
+	c.seen[nonce] = struct{}{}
+	c.order = append(c.order, nonce)
+	for len(c.order) > c.size {
+		delete(c.seen, c.order[0])
+		c.order = c.order[1:]
+	}
+	return true
+}
+
+// Len returns how many nonces are remembered.
+func (c *NonceCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.order)
+}
@@ -0,0 +1,42 @@
+package hooksig
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSignVerify(t *testing.T) {
+	secret, body := []byte("s3cret"), []byte(`{"ts":1}`)
+	sig := Sign(secret, body)
+	if len(sig) != 64 || !Verify(secret, body, sig) {
+		t.Fatalf("Sign/Verify round trip failed: %q", sig)
+	}
+	if Verify([]byte("other"), body, sig) || Verify(secret, []byte(`{"ts":2}`), sig) {
+		t.Error("Verify accepted a wrong secret or body")
+	}
+}
+
+func TestFresh(t *testing.T) {
+	now := time.Now().Unix()
+	if !Fresh(now, time.Minute) || !Fresh(now+30, time.Minute) {
+		t.Error("recent timestamps should be fresh")
+	}
+	if Fresh(now-120, time.Minute) || Fresh(now+120, time.Minute) {
+		t.Error("timestamps outside the window should be stale")
+	}
+}
+
+func TestNonceCacheEvictsOldest(t *testing.T) {
+	c := NewNonceCache(2)
+	for _, n := range []string{"a", "b", "c"} {
+		if !c.Record(n) {
+			t.Fatalf("first Record(%q) = false", n)
+		}
+	}
+	if c.Record("c") {
+		t.Error("replayed nonce accepted")
+	}
+	if c.Len() != 2 || !c.Record("a") {
+		t.Errorf("oldest nonce should have been evicted (len %d)", c.Len())
+	}
+}
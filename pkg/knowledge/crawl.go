@@ -0,0 +1,182 @@
+package knowledge
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+
+	"github.com/Zyling-ai/zyhive/pkg/docextract"
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+)
+
+const (
+	// MaxCrawlDepth is the deepest same-site link depth a web source follows.
+	MaxCrawlDepth = 2
+	maxCrawlPages = 50 // pages fetched per web source
+	userAgent     = "ZyHive-Knowledge/1.0"
+)
+
+// normalizeURL validates a web source URL and drops its fragment.
+func normalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid url %q: must be http(s)", raw)
+	}
+	u.Fragment = ""
+	return u.String(), nil
+}
+
+// crawl fetches src.URL and, up to src.Depth hops, the pages it links to
+// on the same host, storing each under dir/web/. Pages that are not HTML,
+// markdown, text or a document docextract reads are skipped. It returns the
+// stored pages; an error only when the start page itself failed.
+func (m *Manager) crawl(ctx context.Context, dir string, src *WebSource) ([]WebPage, error) {
+	start, err := url.Parse(src.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, webDir), 0700); err != nil {
+		return nil, err
+	}
+	type item struct {
+		u     *url.URL
+		depth int
+	}
+	queue := []item{{start, 0}}
+	seen := map[string]bool{start.String(): true}
+	var pages []WebPage
+	for len(queue) > 0 && len(pages) < maxCrawlPages {
+		it := queue[0]
+		queue = queue[1:]
+		data, ext, err := m.fetch(ctx, it.u.String())
+		if err != nil {
+			if it.depth == 0 {
+				return nil, err
+			}
+			continue
+		}
+		file := pageFile(it.u.String(), ext)
+		if err := persist.AtomicWrite(filepath.Join(dir, webDir, file), data, 0600); err != nil {
+			return nil, err
+		}
+		pages = append(pages, WebPage{URL: it.u.String(), File: file})
+		if ext != ".html" || it.depth >= src.Depth {
+			continue
+		}
+		for _, link := range pageLinks(data, it.u) {
+			if link.Host != start.Host || seen[link.String()] {
+				continue
+			}
+			seen[link.String()] = true
+			queue = append(queue, item{link, it.depth + 1})
+		}
+	}
+	return pages, nil
+}
+
+// fetch downloads one page and returns it with the extension it is stored
+// under, which tells the indexer how to read it.
+func (m *Manager) fetch(ctx context.Context, rawURL string) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("fetch %s: HTTP %d", rawURL, resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, docextract.MaxInputBytes+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("fetch %s: %w", rawURL, err)
+	}
+	if len(data) > docextract.MaxInputBytes {
+		return nil, "", fmt.Errorf("fetch %s: %w", rawURL, docextract.ErrTooLarge)
+	}
+	ct := resp.Header.Get("Content-Type")
+	if format := docextract.Detect(filepath.Base(resp.Request.URL.Path), ct, data); format != "" {
+		return data, "." + format, nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(ct)
+	switch {
+	case mediaType == "text/markdown" || strings.HasSuffix(resp.Request.URL.Path, ".md"):
+		return data, ".md", nil
+	case mediaType == "text/plain":
+		return data, ".txt", nil
+	}
+	return nil, "", fmt.Errorf("fetch %s: unsupported content type %q", rawURL, ct)
+}
+
+// pageFile names the stored copy of a page after its URL.
+func pageFile(rawURL, ext string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:8]) + ext
+}
+
+// pageLinks returns the http(s) links of an HTML page, resolved against
+// base and without fragments.
+func pageLinks(data []byte, base *url.URL) []*url.URL {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	var out []*url.URL
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			for _, a := range n.Attr {
+				if a.Key != "href" {
+					continue
+				}
+				u, err := base.Parse(strings.TrimSpace(a.Val))
+				if err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+					u.Fragment = ""
+					out = append(out, u)
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return out
+}
+
+// removePages deletes stored pages no remaining web source refers to.
+func (m *Manager) removePages(id string, pages []WebPage, keep []*WebSource) {
+	dir, err := m.dir(id)
+	if err != nil {
+		return
+	}
+	used := map[string]bool{}
+	for _, w := range keep {
+		for _, p := range w.Pages {
+			used[p.File] = true
+		}
+	}
+	for _, p := range pages {
+		if !used[p.File] {
+			os.Remove(filepath.Join(dir, webDir, p.File))
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Zyling-ai/zyhive/pkg/memory"
+)
+
+// autoRetrieveTimeout bounds the retrieval done before each turn.
+const autoRetrieveTimeout = 10 * time.Second
+
+// Passage is one search hit with its citation.
+type Passage struct {
+	KB     string  `json:"kb"`
+	KBName string  `json:"kbName"`
+	Source string  `json:"source"` // files/…, projects/<id>/… or the page URL
+	Line   int     `json:"line"`   // 1-indexed; in the extracted text for documents
+	Page   int     `json:"page,omitempty"`
+	Text   string  `json:"text"`
+	Score  float64 `json:"score"`
+}
+
+// Citation formats where the passage comes from: path:line, or the page
+// for paged documents (PDF pages, PPTX slides).
+func (p Passage) Citation() string {
+	switch {
+	case p.Page > 0:
+		return fmt.Sprintf("%s（第 %d 页）", p.Source, p.Page)
+	case strings.Contains(p.Source, "://"):
+		return fmt.Sprintf("%s（第 %d 行）", p.Source, p.Line)
+	}
+	return fmt.Sprintf("%s:%d", p.Source, p.Line)
+}
+
+// FormatPassages renders passages as numbered, cited blocks.
+func FormatPassages(ps []Passage) string {
+	var sb strings.Builder
+	for i, p := range ps {
+		fmt.Fprintf(&sb, "[%d] %s · %s\n%s\n\n", i+1, p.KBName, p.Citation(), strings.TrimSpace(p.Text))
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (m *Manager) lock(id string) *sync.Mutex {
+	v, _ := m.locks.LoadOrStore(id, &sync.Mutex{})
+	return v.(*sync.Mutex)
+}
+
+// Reindex brings a knowledge base's index up to date. Web sources are
+// fetched when they never were, or again when refreshWeb is set; then only
+// documents whose content changed are re-chunked and re-embedded.
+func (m *Manager) Reindex(ctx context.Context, id string, refreshWeb bool) (memory.IndexStats, error) {
+	l := m.lock(id)
+	l.Lock()
+	defer l.Unlock()
+	return m.reindex(ctx, id, refreshWeb)
+}
+
+// ReindexIfStale updates the index in the background when documents were
+// added, changed or removed since it was built. Non-blocking.
+func (m *Manager) ReindexIfStale(id string) {
+	go func() {
+		l := m.lock(id)
+		if !l.TryLock() {
+			return // an update is already running
+		}
+		defer l.Unlock()
+		kb, ok := m.Get(id)
+		if !ok {
+			return
+		}
+		dir, err := m.dir(id)
+		if err != nil {
+			return
+		}
+		idx, err := memory.LoadIndexFile(filepath.Join(dir, indexFile))
+		if err != nil || !m.stale(kb, dir, idx) {
+			return
+		}
+		st, err := m.reindex(context.Background(), id, false)
+		if err != nil {
+			log.Printf("[knowledge] reindex %s: %v", id, err)
+			return
+		}
+		log.Printf("[knowledge] reindexed %s: files +%d ~%d -%d, embedded=%d", id, st.Added, st.Changed, st.Removed, st.Embedded)
+	}()
+}
+
+// reindex does Reindex with the knowledge base's lock held.
+func (m *Manager) reindex(ctx context.Context, id string, refreshWeb bool) (memory.IndexStats, error) {
+	kb, ok := m.Get(id)
+	if !ok {
+		return memory.IndexStats{}, ErrNotFound
+	}
+	dir, err := m.dir(id)
+	if err != nil {
+		return memory.IndexStats{}, err
+	}
+	for _, w := range kb.URLs {
+		if refreshWeb || w.CrawledAt.IsZero() {
+			m.crawlSource(ctx, id, dir, w)
+		}
+	}
+	if kb, ok = m.Get(id); !ok {
+		return memory.IndexStats{}, ErrNotFound
+	}
+
+	idxPath := filepath.Join(dir, indexFile)
+	old, err := memory.LoadIndexFile(idxPath)
+	if err != nil {
+		old = nil
+	}
+	embedder, apiKey := m.resolveEmbedder()
+	idx, st, err := memory.UpdateFileIndex(ctx, m.sources(kb, dir), old, embedder, apiKey)
+	if err == nil {
+		err = memory.SaveIndexFile(idxPath, idx)
+	}
+	status := kb.Index
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status = IndexStatus{IndexedAt: time.Now(), Files: len(idx.Files), Chunks: idx.Len(), Model: idx.Model}
+	}
+	if _, serr := m.update(id, false, func(kb *KB) error { kb.Index = status; return nil }); err == nil {
+		err = serr
+	}
+	return st, err
+}
+
+// crawlSource re-fetches one web source and records the result. A failed
+// crawl keeps the pages fetched before.
+func (m *Manager) crawlSource(ctx context.Context, id, dir string, w *WebSource) {
+	pages, err := m.crawl(ctx, dir, w)
+	var stale []WebPage
+	kb, _ := m.update(id, false, func(kb *KB) error {
+		for _, cur := range kb.URLs {
+			if cur.URL != w.URL {
+				continue
+			}
+			cur.CrawledAt = time.Now()
+			if err != nil {
+				cur.Error = err.Error()
+				return nil
+			}
+			stale = cur.Pages
+			cur.Pages, cur.Error = pages, ""
+		}
+		return nil
+	})
+	if kb != nil && len(stale) > 0 {
+		m.removePages(id, stale, kb.URLs)
+	}
+}
+
+// sources lists the documents of a knowledge base keyed by citation:
+// files/<path> for uploads, projects/<id>/<path> for project folders and
+// the page URL for crawled pages.
+func (m *Manager) sources(kb *KB, dir string) map[string]memory.SourceFile {
+	files := memory.ScanDir(filepath.Join(dir, filesDir), filesDir)
+	for _, pid := range kb.Projects {
+		if m.projects == nil {
+			break
+		}
+		if p, ok := m.projects.Get(pid); ok {
+			maps.Copy(files, memory.ScanDir(p.FilesDir, path.Join("projects", pid)))
+		}
+	}
+	for _, w := range kb.URLs {
+		for _, pg := range w.Pages {
+			abs := filepath.Join(dir, webDir, pg.File)
+			if info, err := os.Stat(abs); err == nil {
+				files[pg.URL] = memory.SourceFile{Abs: abs, ModTime: info.ModTime(), Size: info.Size()}
+			}
+		}
+	}
+	return files
+}
+
+// stale reports whether the index misses documents or web sources.
+func (m *Manager) stale(kb *KB, dir string, idx *memory.SearchIndex) bool {
+	for _, w := range kb.URLs {
+		if w.CrawledAt.IsZero() {
+			return true
+		}
+	}
+	return idx.StaleFor(m.sources(kb, dir))
+}
+
+// Search searches the knowledge bases ids and merges the hits by score,
+// best first. A knowledge base never indexed is indexed first; a stale one
+// is searched as is and updated in the background.
+func (m *Manager) Search(ctx context.Context, ids []string, query string, topK int) ([]Passage, error) {
+	return m.search(ctx, ids, query, topK, true)
+}
+
+func (m *Manager) search(ctx context.Context, ids []string, query string, topK int, build bool) ([]Passage, error) {
+	if topK <= 0 {
+		topK = 5
+	}
+	type target struct {
+		kb  *KB
+		idx *memory.SearchIndex
+	}
+	var targets []target
+	for _, id := range ids {
+		kb, ok := m.Get(id)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+		}
+		dir, err := m.dir(id)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := memory.LoadIndexFile(filepath.Join(dir, indexFile))
+		if err != nil {
+			return nil, err
+		}
+		if idx.IndexedAt == 0 && build {
+			if _, err := m.Reindex(ctx, id, false); err != nil {
+				return nil, fmt.Errorf("index %s: %w", id, err)
+			}
+			if idx, err = memory.LoadIndexFile(filepath.Join(dir, indexFile)); err != nil {
+				return nil, err
+			}
+		} else if m.stale(kb, dir, idx) {
+			m.ReindexIfStale(id)
+		}
+		targets = append(targets, target{kb, idx})
+	}
+
+	// Embed the query once, for the indexes built with the current model.
+	var queryVec []float32
+	embedder, apiKey := m.resolveEmbedder()
+	if embedder != nil && slices.ContainsFunc(targets, func(t target) bool {
+		return t.idx.HasVectors() && t.idx.Model == embedder.Model()
+	}) {
+		if vecs, err := embedder.Embed(ctx, apiKey, []string{query}); err == nil && len(vecs) > 0 {
+			queryVec = vecs[0]
+		}
+	}
+
+	var out []Passage
+	for _, t := range targets {
+		vec := queryVec
+		if embedder == nil || t.idx.Model != embedder.Model() {
+			vec = nil // BM25 for indexes of another model
+		}
+		for _, r := range t.idx.Rank(vec, query, topK, 0) {
+			out = append(out, Passage{
+				KB: t.kb.ID, KBName: t.kb.Name, Source: r.Source, Line: r.Line, Page: r.Page,
+				Text: r.Text, Score: r.Score,
+			})
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Passage) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		}
+		return 0
+	})
+	if len(out) > topK {
+		out = out[:topK]
+	}
+	return out, nil
+}
+
+// Retriever returns the runner hook that injects passages from the agent's
+// knowledge bases with AutoRetrieve on, or nil when it has none. The hook
+// never builds an index, so a turn is not held up by indexing.
+func (m *Manager) Retriever(agentID string) func(ctx context.Context, query string) string {
+	var ids []string
+	topK := 0
+	for _, kb := range m.ForAgent(agentID) {
+		if kb.AutoRetrieve {
+			ids = append(ids, kb.ID)
+			topK = max(topK, kb.autoTopK())
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return func(ctx context.Context, query string) string {
+		if utf8.RuneCountInString(strings.TrimSpace(query)) < 2 {
+			return ""
+		}
+		ctx, cancel := context.WithTimeout(ctx, autoRetrieveTimeout)
+		defer cancel()
+		ps, err := m.search(ctx, ids, query, topK, false)
+		if err != nil || len(ps) == 0 {
+			return ""
+		}
+		return "## 知识库参考资料\n" +
+			"以下段落按用户消息从知识库自动检索，未必都相关；引用时注明来源（路径与行号或页码）。\n\n" +
+			FormatPassages(ps)
+	}
+}
@@ -0,0 +1,542 @@
+// Package knowledge manages knowledge bases: named collections of documents
+// (uploaded files, shared project folders, crawled web pages) that are
+// chunked, embedded and indexed for retrieval by the agents they are
+// attached to.
+package knowledge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+	"github.com/Zyling-ai/zyhive/pkg/project"
+	"github.com/Zyling-ai/zyhive/pkg/safefs"
+)
+
+const (
+	metaFile  = "meta.json"
+	indexFile = "index.gob"
+	filesDir  = "files" // uploaded documents
+	webDir    = "web"   // crawled pages
+
+	// DefaultAutoTopK is the number of passages auto-retrieval injects.
+	DefaultAutoTopK = 3
+	maxAutoTopK     = 10
+)
+
+// ErrNotFound is returned for an unknown knowledge base ID.
+var ErrNotFound = errors.New("knowledge base not found")
+
+// KB is one knowledge base.
+type KB struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description,omitempty"`
+	AgentIDs    []string     `json:"agentIds"`           // agents that can search it
+	Projects    []string     `json:"projects,omitempty"` // project IDs whose folders are indexed
+	URLs        []*WebSource `json:"urls,omitempty"`
+	// AutoRetrieve injects the top AutoTopK passages for each user message
+	// into the system prompt of the attached agents.
+	AutoRetrieve bool        `json:"autoRetrieve,omitempty"`
+	AutoTopK     int         `json:"autoTopK,omitempty"` // 0 = DefaultAutoTopK
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+	Index        IndexStatus `json:"index"`
+}
+
+// WebSource is a crawled URL: the page itself plus same-site links up to
+// Depth hops away.
+type WebSource struct {
+	URL       string    `json:"url"`
+	Depth     int       `json:"depth,omitempty"`
+	Pages     []WebPage `json:"pages,omitempty"`
+	CrawledAt time.Time `json:"crawledAt,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// WebPage is one fetched page, stored under web/.
+type WebPage struct {
+	URL  string `json:"url"`
+	File string `json:"file"`
+}
+
+// IndexStatus describes the last index update.
+type IndexStatus struct {
+	IndexedAt time.Time `json:"indexedAt,omitempty"`
+	Files     int       `json:"files"`
+	Chunks    int       `json:"chunks"`
+	Model     string    `json:"model,omitempty"` // "" = BM25 only
+	Error     string    `json:"error,omitempty"`
+}
+
+// HasAgent reports whether agentID is attached to the knowledge base.
+func (kb *KB) HasAgent(agentID string) bool {
+	return slices.Contains(kb.AgentIDs, agentID)
+}
+
+func (kb *KB) autoTopK() int {
+	if kb.AutoTopK <= 0 {
+		return DefaultAutoTopK
+	}
+	return min(kb.AutoTopK, maxAutoTopK)
+}
+
+func (kb *KB) clone() *KB {
+	c := *kb
+	c.AgentIDs = slices.Clone(kb.AgentIDs)
+	c.Projects = slices.Clone(kb.Projects)
+	c.URLs = make([]*WebSource, len(kb.URLs))
+	for i, w := range kb.URLs {
+		wc := *w
+		wc.Pages = slices.Clone(w.Pages)
+		c.URLs[i] = &wc
+	}
+	return &c
+}
+
+// EmbedderFunc returns the embedding client and its API key, or nil for
+// BM25-only indexes.
+type EmbedderFunc func() (*llm.Embedder, string)
+
+// Manager manages all knowledge bases under a root directory, one
+// subdirectory per knowledge base.
+type Manager struct {
+	rootDir  string
+	projects *project.Manager // may be nil
+	embedder EmbedderFunc     // may be nil
+	client   *http.Client     // crawler client
+
+	mu  sync.RWMutex
+	kbs map[string]*KB
+
+	locks sync.Map // kb id -> *sync.Mutex, serialises index updates
+}
+
+// NewManager creates a Manager rooted at rootDir. projects resolves project
+// sources and may be nil.
+func NewManager(rootDir string, projects *project.Manager) *Manager {
+	if abs, err := filepath.Abs(rootDir); err == nil {
+		rootDir = abs
+	}
+	return &Manager{
+		rootDir:  rootDir,
+		projects: projects,
+		client:   netguard.NewSafeClient(30 * time.Second),
+		kbs:      make(map[string]*KB),
+	}
+}
+
+// SetEmbedder sets how index updates and searches get their embedder.
+func (m *Manager) SetEmbedder(fn EmbedderFunc) {
+	m.embedder = fn
+}
+
+func (m *Manager) resolveEmbedder() (*llm.Embedder, string) {
+	if m.embedder == nil {
+		return nil, ""
+	}
+	return m.embedder()
+}
+
+// LoadAll loads every knowledge base's meta.json.
+func (m *Manager) LoadAll() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.MkdirAll(m.rootDir, 0700); err != nil {
+		return fmt.Errorf("create knowledge dir: %w", err)
+	}
+	entries, err := os.ReadDir(m.rootDir)
+	if err != nil {
+		return fmt.Errorf("read knowledge dir: %w", err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || safefs.ValidateResourceID(e.Name()) != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(m.rootDir, e.Name(), metaFile))
+		if err != nil {
+			continue
+		}
+		var kb KB
+		if err := json.Unmarshal(data, &kb); err != nil || kb.ID != e.Name() {
+			continue
+		}
+		if kb.AgentIDs == nil {
+			kb.AgentIDs = []string{}
+		}
+		m.kbs[kb.ID] = &kb
+	}
+	return nil
+}
+
+// List returns copies of all knowledge bases, newest first.
+func (m *Manager) List() []*KB {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]*KB, 0, len(m.kbs))
+	for _, kb := range m.kbs {
+		out = append(out, kb.clone())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// ForAgent returns copies of the knowledge bases attached to agentID.
+func (m *Manager) ForAgent(agentID string) []*KB {
+	var out []*KB
+	for _, kb := range m.List() {
+		if kb.HasAgent(agentID) {
+			out = append(out, kb)
+		}
+	}
+	return out
+}
+
+// Get returns a copy of one knowledge base.
+func (m *Manager) Get(id string) (*KB, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	kb, ok := m.kbs[id]
+	if !ok {
+		return nil, false
+	}
+	return kb.clone(), true
+}
+
+// CreateOpts holds options for creating a knowledge base.
+type CreateOpts struct {
+	ID           string // empty = generated
+	Name         string
+	Description  string
+	AgentIDs     []string
+	Projects     []string
+	AutoRetrieve bool
+	AutoTopK     int
+}
+
+// Create creates an empty knowledge base. Documents are added with
+// AddFile / AddURL or come from Projects, and indexed by Reindex.
+func (m *Manager) Create(opts CreateOpts) (*KB, error) {
+	if opts.ID == "" {
+		opts.ID = "kb-" + uuid.New().String()[:8]
+	}
+	if err := safefs.ValidateResourceID(opts.ID); err != nil {
+		return nil, fmt.Errorf("invalid knowledge base id %q: %w", opts.ID, err)
+	}
+	if strings.TrimSpace(opts.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if err := m.checkProjects(opts.Projects); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, exists := m.kbs[opts.ID]; exists {
+		return nil, fmt.Errorf("knowledge base %q already exists", opts.ID)
+	}
+	dir, err := safefs.ConfineResource(m.rootDir, opts.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, filesDir), 0700); err != nil {
+		return nil, fmt.Errorf("create knowledge base dir: %w", err)
+	}
+	now := time.Now()
+	kb := &KB{
+		ID:           opts.ID,
+		Name:         strings.TrimSpace(opts.Name),
+		Description:  opts.Description,
+		AgentIDs:     dedupe(opts.AgentIDs),
+		Projects:     dedupe(opts.Projects),
+		AutoRetrieve: opts.AutoRetrieve,
+		AutoTopK:     opts.AutoTopK,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := m.saveLocked(kb); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	m.kbs[kb.ID] = kb
+	return kb.clone(), nil
+}
+
+// UpdateOpts is a patch: nil fields are left unchanged.
+type UpdateOpts struct {
+	Name         *string
+	Description  *string
+	AgentIDs     *[]string
+	Projects     *[]string
+	AutoRetrieve *bool
+	AutoTopK     *int
+}
+
+// Update patches a knowledge base. Changing Projects takes effect in the
+// search results at the next Reindex.
+func (m *Manager) Update(id string, opts UpdateOpts) (*KB, error) {
+	if opts.Projects != nil {
+		if err := m.checkProjects(*opts.Projects); err != nil {
+			return nil, err
+		}
+	}
+	return m.modify(id, func(kb *KB) error {
+		if opts.Name != nil {
+			if strings.TrimSpace(*opts.Name) == "" {
+				return fmt.Errorf("name is required")
+			}
+			kb.Name = strings.TrimSpace(*opts.Name)
+		}
+		if opts.Description != nil {
+			kb.Description = *opts.Description
+		}
+		if opts.AgentIDs != nil {
+			kb.AgentIDs = dedupe(*opts.AgentIDs)
+		}
+		if opts.Projects != nil {
+			kb.Projects = dedupe(*opts.Projects)
+		}
+		if opts.AutoRetrieve != nil {
+			kb.AutoRetrieve = *opts.AutoRetrieve
+		}
+		if opts.AutoTopK != nil {
+			kb.AutoTopK = *opts.AutoTopK
+		}
+		return nil
+	})
+}
+
+// Delete removes a knowledge base with its documents and index. It waits
+// for a running index update to finish.
+func (m *Manager) Delete(id string) error {
+	l := m.lock(id)
+	l.Lock()
+	defer l.Unlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.kbs[id]; !ok {
+		return ErrNotFound
+	}
+	dir, err := safefs.ConfineResource(m.rootDir, id)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	delete(m.kbs, id)
+	return nil
+}
+
+// FileInfo describes an uploaded document.
+type FileInfo struct {
+	Path    string    `json:"path"` // relative to files/
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+}
+
+// Files lists the uploaded documents of a knowledge base.
+func (m *Manager) Files(id string) ([]FileInfo, error) {
+	dir, err := m.filesDir(id)
+	if err != nil {
+		return nil, err
+	}
+	out := []FileInfo{}
+	err = filepath.WalkDir(dir, func(abs string, d os.DirEntry, walkErr error) error {
+		if walkErr != nil || d.IsDir() || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, abs)
+		out = append(out, FileInfo{Path: filepath.ToSlash(rel), Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return out, err
+}
+
+// AddFile stores an uploaded document at rel (relative to files/),
+// replacing any previous version. It is indexed by the next Reindex.
+func (m *Manager) AddFile(id, rel string, data []byte) error {
+	abs, err := m.filePath(id, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0700); err != nil {
+		return err
+	}
+	if err := persist.AtomicWrite(abs, data, 0600); err != nil {
+		return err
+	}
+	_, err = m.modify(id, func(*KB) error { return nil })
+	return err
+}
+
+// RemoveFile deletes an uploaded document.
+func (m *Manager) RemoveFile(id, rel string) error {
+	abs, err := m.filePath(id, rel)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(abs); err != nil {
+		return err
+	}
+	_, err = m.modify(id, func(*KB) error { return nil })
+	return err
+}
+
+// AddURL adds a web source; its pages are fetched by the next Reindex.
+// depth is the number of same-site link hops followed (0..MaxCrawlDepth).
+func (m *Manager) AddURL(id, rawURL string, depth int) (*KB, error) {
+	u, err := normalizeURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if depth < 0 || depth > MaxCrawlDepth {
+		return nil, fmt.Errorf("depth must be between 0 and %d", MaxCrawlDepth)
+	}
+	return m.modify(id, func(kb *KB) error {
+		for _, w := range kb.URLs {
+			if w.URL == u {
+				if w.Depth != depth {
+					w.Depth, w.CrawledAt = depth, time.Time{} // re-crawl
+				}
+				return nil
+			}
+		}
+		kb.URLs = append(kb.URLs, &WebSource{URL: u, Depth: depth})
+		return nil
+	})
+}
+
+// RemoveURL removes a web source and its fetched pages.
+func (m *Manager) RemoveURL(id, rawURL string) (*KB, error) {
+	var removed *WebSource
+	kb, err := m.modify(id, func(kb *KB) error {
+		for i, w := range kb.URLs {
+			if w.URL == rawURL {
+				removed = w
+				kb.URLs = slices.Delete(kb.URLs, i, i+1)
+				return nil
+			}
+		}
+		return fmt.Errorf("url %q is not a source of this knowledge base", rawURL)
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.removePages(id, removed.Pages, kb.URLs)
+	return kb, nil
+}
+
+// modify applies a user edit to the knowledge base.
+func (m *Manager) modify(id string, fn func(kb *KB) error) (*KB, error) {
+	return m.update(id, true, fn)
+}
+
+// update applies fn to a copy of the knowledge base under the lock and
+// saves it; touch bumps UpdatedAt.
+func (m *Manager) update(id string, touch bool, fn func(kb *KB) error) (*KB, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cur, ok := m.kbs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	kb := cur.clone()
+	if err := fn(kb); err != nil {
+		return nil, err
+	}
+	if touch {
+		kb.UpdatedAt = time.Now()
+	}
+	if err := m.saveLocked(kb); err != nil {
+		return nil, err
+	}
+	m.kbs[id] = kb
+	return kb.clone(), nil
+}
+
+func (m *Manager) saveLocked(kb *KB) error {
+	data, err := json.MarshalIndent(kb, "", "  ")
+	if err != nil {
+		return err
+	}
+	return persist.AtomicWrite(filepath.Join(m.rootDir, kb.ID, metaFile), data, 0600)
+}
+
+// dir returns the directory of an existing knowledge base.
+func (m *Manager) dir(id string) (string, error) {
+	m.mu.RLock()
+	_, ok := m.kbs[id]
+	m.mu.RUnlock()
+	if !ok {
+		return "", ErrNotFound
+	}
+	return safefs.ConfineResource(m.rootDir, id)
+}
+
+func (m *Manager) filesDir(id string) (string, error) {
+	dir, err := m.dir(id)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filesDir), nil
+}
+
+// filePath resolves rel inside files/; hidden names are refused because
+// the index skips them.
+func (m *Manager) filePath(id, rel string) (string, error) {
+	dir, err := m.filesDir(id)
+	if err != nil {
+		return "", err
+	}
+	rel = strings.TrimPrefix(filepath.ToSlash(rel), "/")
+	if rel == "" {
+		return "", fmt.Errorf("file path is required")
+	}
+	for _, part := range strings.Split(rel, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", fmt.Errorf("invalid file path %q", rel)
+		}
+	}
+	return safefs.ConfineToBase(dir, rel)
+}
+
+func (m *Manager) checkProjects(ids []string) error {
+	for _, id := range ids {
+		if m.projects == nil {
+			return fmt.Errorf("projects are not available")
+		}
+		if _, ok := m.projects.Get(id); !ok {
+			return fmt.Errorf("project %q not found", id)
+		}
+	}
+	return nil
+}
+
+func dedupe(ids []string) []string {
+	out := []string{}
+	for _, id := range ids {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(out, id) {
+			out = append(out, id)
+		}
+	}
+	return out
+}
@@ -0,0 +1,153 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/project"
+)
+
+func setup(t *testing.T) (*Manager, *project.Project) {
+	t.Helper()
+	projects := project.NewManager(t.TempDir())
+	p, err := projects.Create(project.CreateOpts{ID: "handbook", Name: "手册"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(p.FilesDir, "deploy.md"),
+		[]byte("# Deploy\n\nThe canary rollout waits thirty minutes before promoting.\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	m := NewManager(t.TempDir(), projects)
+	if err := m.LoadAll(); err != nil {
+		t.Fatal(err)
+	}
+	return m, p
+}
+
+func TestReindexAndSearchCiteSources(t *testing.T) {
+	m, _ := setup(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		switch r.URL.Path {
+		case "/":
+			fmt.Fprint(w, `<html><body><p>Welcome to the support portal.</p><a href="/refunds#top">refunds</a><a href="https://elsewhere.example/">x</a></body></html>`)
+		case "/refunds":
+			fmt.Fprint(w, `<html><body><p>Refunds are issued within fourteen days of the request.</p></body></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	m.client = srv.Client() // the safe client refuses loopback
+
+	kb, err := m.Create(CreateOpts{Name: "支持", AgentIDs: []string{"alice"}, Projects: []string{"handbook"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddFile(kb.ID, "policies/warranty.txt", []byte("The warranty covers hardware faults for two years.\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.AddURL(kb.ID, srv.URL+"/", 1); err != nil {
+		t.Fatal(err)
+	}
+	st, err := m.Reindex(context.Background(), kb.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Added != 5 { // upload, project README + deploy.md, start page, linked page
+		t.Fatalf("added = %d", st.Added)
+	}
+	got, _ := m.Get(kb.ID)
+	if got.Index.Files != 5 || got.Index.IndexedAt.IsZero() || len(got.URLs[0].Pages) != 2 {
+		t.Fatalf("status = %+v pages = %+v", got.Index, got.URLs[0].Pages)
+	}
+
+	for query, want := range map[string]string{
+		"warranty hardware": "files/policies/warranty.txt:1",
+		"canary rollout":    "projects/handbook/deploy.md:3",
+		"refunds issued":    srv.URL + "/refunds（第 ",
+	} {
+		ps, err := m.Search(context.Background(), []string{kb.ID}, query, 1)
+		if err != nil || len(ps) != 1 {
+			t.Fatalf("search %q = %v, %v", query, ps, err)
+		}
+		if !strings.HasPrefix(ps[0].Citation(), want) {
+			t.Errorf("search %q cites %q, want %q", query, ps[0].Citation(), want)
+		}
+	}
+
+	// Only the changed upload is re-chunked.
+	if err := m.AddFile(kb.ID, "policies/warranty.txt", []byte("The warranty covers hardware faults for three years.\n")); err != nil {
+		t.Fatal(err)
+	}
+	if st, err = m.Reindex(context.Background(), kb.ID, false); err != nil || st.Changed != 1 || st.Added != 0 {
+		t.Fatalf("incremental = %+v, %v", st, err)
+	}
+
+	// Removing the web source drops its pages from disk and the index.
+	if _, err := m.RemoveURL(kb.ID, srv.URL+"/"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(m.rootDir, kb.ID, webDir)); len(entries) != 0 {
+		t.Errorf("web pages left: %d", len(entries))
+	}
+	if st, err = m.Reindex(context.Background(), kb.ID, false); err != nil || st.Removed != 2 {
+		t.Fatalf("after RemoveURL = %+v, %v", st, err)
+	}
+}
+
+func TestRetrieverOnlyForAutoRetrieveAgents(t *testing.T) {
+	m, _ := setup(t)
+	kb, err := m.Create(CreateOpts{Name: "部署", AgentIDs: []string{"alice", "bob"}, Projects: []string{"handbook"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Retriever("alice") != nil {
+		t.Fatal("retriever without AutoRetrieve")
+	}
+	on := true
+	if _, err := m.Update(kb.ID, UpdateOpts{AutoRetrieve: &on, AgentIDs: &[]string{"alice"}}); err != nil {
+		t.Fatal(err)
+	}
+	if m.Retriever("bob") != nil {
+		t.Fatal("retriever for a detached agent")
+	}
+	retrieve := m.Retriever("alice")
+	if retrieve == nil {
+		t.Fatal("no retriever")
+	}
+	// Auto-retrieval never builds an index itself.
+	if got := retrieve(context.Background(), "canary rollout"); got != "" {
+		t.Errorf("retrieved before indexing: %q", got)
+	}
+	if _, err := m.Reindex(context.Background(), kb.ID, false); err != nil {
+		t.Fatal(err)
+	}
+	got := retrieve(context.Background(), "canary rollout")
+	if !strings.Contains(got, "[1] 部署 · projects/handbook/deploy.md:3") || !strings.Contains(got, "thirty minutes") {
+		t.Errorf("retrieved = %q", got)
+	}
+}
+
+func TestFilePathRejectsEscapes(t *testing.T) {
+	m, _ := setup(t)
+	kb, err := m.Create(CreateOpts{Name: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rel := range []string{"", "../meta.json", "a/../../index.gob", ".hidden.md", "docs/.git/config"} {
+		if err := m.AddFile(kb.ID, rel, []byte("x")); err == nil {
+			t.Errorf("AddFile(%q) accepted", rel)
+		}
+	}
+	if _, err := m.Create(CreateOpts{Name: "y", Projects: []string{"missing"}}); err == nil {
+		t.Error("unknown project accepted")
+	}
+}
@@ -17,6 +17,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 )
 
 const anthropicAPIBaseDefault = "https://api.anthropic.com/v1"
@@ -26,6 +27,13 @@ const anthropicVersion = "2023-06-01"
 type AnthropicClient struct {
 	httpClient *http.Client
 	baseURL    string // 自定义转发地址，空则用官方默认
+
+	// Extended thinking + tool use: the API requires the assistant turn's
+	// thinking blocks (with signatures) to be sent back verbatim, but the
+	// runner's history only keeps text + tool_use. Keep them here keyed by
+	// tool_use ID (same approach as GeminiClient's thought signatures).
+	mu       sync.Mutex
+	thinking map[string][]json.RawMessage
 }
 
 // NewAnthropicClient creates a new Anthropic streaming client.
@@ -40,13 +48,18 @@ func NewAnthropicClient(baseURL string) *AnthropicClient {
 	if !strings.HasSuffix(baseURL, "/v1") && !strings.Contains(baseURL, "/v1/") {
 		baseURL = baseURL + "/v1"
 	}
-	return &AnthropicClient{httpClient: newStreamingHTTPClient(), baseURL: baseURL}
+	return &AnthropicClient{httpClient: newStreamingHTTPClient(), baseURL: baseURL, thinking: map[string][]json.RawMessage{}}
 }
 
 // Stream sends a streaming Messages API request and emits events.
 // Reference: anthropic.js → streamAnthropic → client.messages.stream()
 // 使用带超时/重试的 HTTP client；流式读取增加 keepalive 心跳检测。
 func (c *AnthropicClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	if req.ThinkingBudget > 0 {
+		r := *req
+		r.Messages = c.restoreThinking(req.Messages)
+		req = &r
+	}
 	body, err := buildAnthropicRequest(req)
 	if err != nil {
 		return nil, fmt.Errorf("build request: %w", err)
@@ -88,12 +101,64 @@ func (c *AnthropicClient) Stream(ctx context.Context, req *ChatRequest) (<-chan
 		defer keepCancel()
 		kr := newKeepaliveReader(resp.Body, streamKeepaliveTimeout, keepCancel)
 		defer kr.Stop()
-		parseAnthropicSSE(keepCtx, kr, events)
+		parseAnthropicSSE(keepCtx, kr, events, c.rememberThinking)
 	}()
 
 	return events, nil
 }
 
+func (c *AnthropicClient) rememberThinking(toolID string, blocks []json.RawMessage) {
+	c.mu.Lock()
+	c.thinking[toolID] = blocks
+	c.mu.Unlock()
+}
+
+// restoreThinking prepends remembered thinking blocks to assistant messages
+// whose tool_use they preceded. Messages are copied, never mutated.
+func (c *AnthropicClient) restoreThinking(msgs []ChatMessage) []ChatMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.thinking) == 0 {
+		return msgs
+	}
+	out := make([]ChatMessage, len(msgs))
+	copy(out, msgs)
+	for i, m := range out {
+		if m.Role != "assistant" || len(m.Content) == 0 || m.Content[0] != '[' {
+			continue
+		}
+		var blocks []json.RawMessage
+		if err := json.Unmarshal(m.Content, &blocks); err != nil || len(blocks) == 0 {
+			continue
+		}
+		var saved []json.RawMessage
+		for _, b := range blocks {
+			var probe struct {
+				Type string `json:"type"`
+				ID   string `json:"id"`
+			}
+			if json.Unmarshal(b, &probe) != nil {
+				continue
+			}
+			if probe.Type == "thinking" || probe.Type == "redacted_thinking" {
+				saved = nil // already carries its own thinking
+				break
+			}
+			if probe.Type == "tool_use" && saved == nil {
+				saved = c.thinking[probe.ID]
+			}
+		}
+		if len(saved) == 0 {
+			continue
+		}
+		merged, err := json.Marshal(append(append([]json.RawMessage{}, saved...), blocks...))
+		if err == nil {
+			out[i].Content = merged
+		}
+	}
+	return out
+}
+
 // buildAnthropicRequest converts our generic ChatRequest to Anthropic JSON.
 // sanitizeContentBlocks walks a JSON content array and replaces any
 // text blocks with empty "text" field with a single space, preventing
@@ -179,20 +244,73 @@ func buildAnthropicRequest(req *ChatRequest) ([]byte, error) {
 	}
 
 	payload := map[string]any{
-		"model":      normaliseAnthropicModel(req.Model),
-		"max_tokens": maxTokens,
-		"stream":     true,
-		"messages":   messages,
+		"model":    normaliseAnthropicModel(req.Model),
+		"stream":   true,
+		"messages": messages,
+	}
+	system := req.System
+	if req.ResponseFormat != nil {
+		// No response_format on the Messages API: the schema rides in the system prompt.
+		system = appendSystem(system, jsonInstruction(req.ResponseFormat))
+	}
+	if system != "" {
+		payload["system"] = system
 	}
-	if req.System != "" {
-		payload["system"] = req.System
+	if len(req.Stop) > 0 {
+		payload["stop_sequences"] = req.Stop
 	}
+	thinking := req.ThinkingBudget > 0
+	if thinking {
+		// budget_tokens must be ≥1024 and below max_tokens. While thinking is
+		// on, temperature is fixed and top_p may only be 0.95–1.
+		budget := max(req.ThinkingBudget, 1024)
+		if maxTokens <= budget {
+			maxTokens = budget + 4096
+		}
+		payload["thinking"] = map[string]any{"type": "enabled", "budget_tokens": budget}
+		if req.TopP != nil && *req.TopP >= 0.95 {
+			payload["top_p"] = *req.TopP
+		}
+	} else {
+		if req.Temperature != nil {
+			payload["temperature"] = *req.Temperature
+		}
+		if req.TopP != nil {
+			payload["top_p"] = *req.TopP
+		}
+	}
+	payload["max_tokens"] = maxTokens
 	if len(req.Tools) > 0 {
 		payload["tools"] = req.Tools
+		if tc := anthropicToolChoice(req.ToolChoice, thinking); tc != nil {
+			payload["tool_choice"] = tc
+		}
 	}
 	return json.Marshal(payload)
 }
 
+// anthropicToolChoice maps ToolChoice; nil means "leave the API default (auto)".
+// Forced choices (any / tool) are incompatible with extended thinking and
+// degrade to auto.
+func anthropicToolChoice(tc *ToolChoice, thinking bool) map[string]any {
+	if tc == nil {
+		return nil
+	}
+	switch tc.Type {
+	case ToolChoiceNone:
+		return map[string]any{"type": "none"}
+	case ToolChoiceAny:
+		if !thinking {
+			return map[string]any{"type": "any"}
+		}
+	case ToolChoiceTool:
+		if !thinking {
+			return map[string]any{"type": "tool", "name": tc.Name}
+		}
+	}
+	return map[string]any{"type": "auto"}
+}
+
 // normaliseAnthropicModel strips the "anthropic/" provider prefix.
 func normaliseAnthropicModel(model string) string {
 	return strings.TrimPrefix(model, "anthropic/")
@@ -208,15 +326,22 @@ func normaliseAnthropicModel(model string) string {
 //   content_block_stop      → block complete
 //   message_delta           → stop_reason + usage
 //   message_stop            → stream end
-func parseAnthropicSSE(ctx context.Context, body io.Reader, events chan<- StreamEvent) {
+//
+// remember (optional) receives the message's thinking blocks, ready to be
+// replayed, for every tool_use that follows them.
+func parseAnthropicSSE(ctx context.Context, body io.Reader, events chan<- StreamEvent, remember func(toolID string, blocks []json.RawMessage)) {
 	scanner := bufio.NewScanner(body)
 	scanner.Buffer(make([]byte, 512*1024), 512*1024)
 
 	var (
-		currentBlockType string // "text" | "tool_use"
+		currentBlockType string // "text" | "tool_use" | "thinking" | "redacted_thinking"
 		currentToolID    string
 		currentToolName  string
 		toolInputBuf     strings.Builder
+		thinkingBuf      strings.Builder
+		signature        string
+		redactedData     string
+		thinkingBlocks   []json.RawMessage
 	)
 
 	for scanner.Scan() {
@@ -243,7 +368,9 @@ func parseAnthropicSSE(ctx context.Context, body io.Reader, events chan<- Stream
 			// message_start → contains input token count
 			Message struct {
 				Usage struct {
-					InputTokens int `json:"input_tokens"`
+					InputTokens              int `json:"input_tokens"`
+					CacheCreationInputTokens int `json:"cache_creation_input_tokens"`
+					CacheReadInputTokens     int `json:"cache_read_input_tokens"`
 				} `json:"usage"`
 			} `json:"message"`
 			// content_block_start
@@ -251,6 +378,7 @@ func parseAnthropicSSE(ctx context.Context, body io.Reader, events chan<- Stream
 				Type  string `json:"type"`
 				ID    string `json:"id"`
 				Name  string `json:"name"`
+				Data  string `json:"data"` // redacted_thinking
 			} `json:"content_block"`
 			// error event
 			Error struct {
@@ -264,17 +392,30 @@ func parseAnthropicSSE(ctx context.Context, body io.Reader, events chan<- Stream
 
 		switch event.Type {
 		case "message_start":
-			// Emit input token count from message_start
-			if in := event.Message.Usage.InputTokens; in > 0 {
-				events <- StreamEvent{Type: EventUsage, Usage: &Usage{InputTokens: in}}
+			// Emit input token count from message_start. Anthropic's
+			// input_tokens excludes cached tokens; Usage.InputTokens is the
+			// whole prompt, so add them back.
+			u := event.Message.Usage
+			if in := u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens; in > 0 {
+				events <- StreamEvent{Type: EventUsage, Usage: &Usage{
+					InputTokens:      in,
+					CacheReadTokens:  u.CacheReadInputTokens,
+					CacheWriteTokens: u.CacheCreationInputTokens,
+				}}
 			}
 
 		case "content_block_start":
 			currentBlockType = event.ContentBlock.Type
-			if currentBlockType == "tool_use" {
+			switch currentBlockType {
+			case "tool_use":
 				currentToolID = event.ContentBlock.ID
 				currentToolName = event.ContentBlock.Name
 				toolInputBuf.Reset()
+			case "thinking":
+				thinkingBuf.Reset()
+				signature = ""
+			case "redacted_thinking":
+				redactedData = event.ContentBlock.Data
 			}
 
 		case "content_block_delta":
@@ -282,6 +423,7 @@ func parseAnthropicSSE(ctx context.Context, body io.Reader, events chan<- Stream
 				Type        string `json:"type"`
 				Text        string `json:"text"`
 				Thinking    string `json:"thinking"`
+				Signature   string `json:"signature"`
 				PartialJSON string `json:"partial_json"`
 			}
 			if err := json.Unmarshal(event.Delta, &delta); err != nil {
@@ -291,13 +433,29 @@ func parseAnthropicSSE(ctx context.Context, body io.Reader, events chan<- Stream
 			case "text_delta":
 				events <- StreamEvent{Type: EventTextDelta, Text: delta.Text}
 			case "thinking_delta":
+				thinkingBuf.WriteString(delta.Thinking)
 				events <- StreamEvent{Type: EventThinkingDelta, Text: delta.Thinking}
+			case "signature_delta":
+				signature += delta.Signature
 			case "input_json_delta":
 				toolInputBuf.WriteString(delta.PartialJSON)
 				events <- StreamEvent{Type: EventToolDelta, ToolDelta: delta.PartialJSON}
 			}
 
 		case "content_block_stop":
+			switch currentBlockType {
+			case "thinking":
+				if b, err := json.Marshal(map[string]string{"type": "thinking", "thinking": thinkingBuf.String(), "signature": signature}); err == nil {
+					thinkingBlocks = append(thinkingBlocks, b)
+				}
+			case "redacted_thinking":
+				if b, err := json.Marshal(map[string]string{"type": "redacted_thinking", "data": redactedData}); err == nil {
+					thinkingBlocks = append(thinkingBlocks, b)
+				}
+			}
+			if currentBlockType == "tool_use" && remember != nil && len(thinkingBlocks) > 0 {
+				remember(currentToolID, thinkingBlocks)
+			}
 			if currentBlockType == "tool_use" {
 				input := toolInputBuf.String()
 				if input == "" {
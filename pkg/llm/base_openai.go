@@ -24,6 +24,9 @@ type openAIBase struct {
 	// parseSSE 是 SSE 流解析钩子，各 provider 可自定义（如 DeepSeek reasoning）。
 	// nil = 使用默认实现。
 	parseSSE func(ctx context.Context, body io.Reader, out chan<- StreamEvent)
+	// buildBody 是请求体构建钩子，各 provider 可追加私有参数（如 Qwen enable_thinking）。
+	// nil = 使用 buildOpenAIRequestBody。
+	buildBody func(req *ChatRequest) ([]byte, error)
 }
 
 func newOpenAIBaseForProvider(provider, baseURL string, extra map[string]string) openAIBase {
@@ -49,7 +52,11 @@ func (b *openAIBase) stream(ctx context.Context, req *ChatRequest) (<-chan Strea
 	if b.httpClient == nil {
 		return nil, fmt.Errorf("provider HTTP client is unavailable")
 	}
-	body, err := buildOpenAIRequestBody(req)
+	buildFn := buildOpenAIRequestBody
+	if b.buildBody != nil {
+		buildFn = b.buildBody
+	}
+	body, err := buildFn(req)
 	if err != nil {
 		return nil, fmt.Errorf("build request: %w", err)
 	}
@@ -127,6 +134,41 @@ func buildOpenAIRequestBody(req *ChatRequest) ([]byte, error) {
 	if req.MaxTokens > 0 {
 		payload["max_tokens"] = req.MaxTokens
 	}
+	// o-series / gpt-5 reject sampling params and take reasoning_effort instead.
+	if isOpenAIReasoningModel(model) {
+		if req.ThinkingBudget > 0 {
+			payload["reasoning_effort"] = reasoningEffort(req.ThinkingBudget)
+		}
+	} else {
+		if req.Temperature != nil {
+			payload["temperature"] = *req.Temperature
+		}
+		if req.TopP != nil {
+			payload["top_p"] = *req.TopP
+		}
+	}
+	if len(req.Stop) > 0 {
+		payload["stop"] = req.Stop
+	}
+	if rf := req.ResponseFormat; rf != nil {
+		switch rf.Type {
+		case ResponseFormatJSONSchema:
+			name := rf.Name
+			if name == "" {
+				name = "response"
+			}
+			payload["response_format"] = map[string]any{
+				"type": "json_schema",
+				"json_schema": map[string]any{
+					"name":   name,
+					"schema": rf.Schema,
+					"strict": rf.Strict,
+				},
+			}
+		case ResponseFormatJSONObject:
+			payload["response_format"] = map[string]any{"type": "json_object"}
+		}
+	}
 
 	if len(req.Tools) > 0 {
 		tools := make([]map[string]any, 0, len(req.Tools))
@@ -141,12 +183,28 @@ func buildOpenAIRequestBody(req *ChatRequest) ([]byte, error) {
 			})
 		}
 		payload["tools"] = tools
-		payload["tool_choice"] = "auto"
+		payload["tool_choice"] = openAIToolChoice(req.ToolChoice)
 	}
 
 	return json.Marshal(payload)
 }
 
+// openAIToolChoice maps ToolChoice onto the Chat Completions tool_choice field.
+func openAIToolChoice(tc *ToolChoice) any {
+	if tc == nil {
+		return "auto"
+	}
+	switch tc.Type {
+	case ToolChoiceAny:
+		return "required"
+	case ToolChoiceNone:
+		return "none"
+	case ToolChoiceTool:
+		return map[string]any{"type": "function", "function": map[string]any{"name": tc.Name}}
+	}
+	return "auto"
+}
+
 // ── 默认 SSE 解析（共享）──────────────────────────────────────────────────────
 
 type openAIChunk struct {
@@ -168,8 +226,18 @@ type openAIChunk struct {
 	// OpenAI streams usage in the last chunk (stream_options.include_usage=true)
 	// or in a final chunk with empty choices.
 	Usage *struct {
-		PromptTokens     int `json:"prompt_tokens"`
-		CompletionTokens int `json:"completion_tokens"`
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens     int `json:"cached_tokens"`
+			CacheWriteTokens int `json:"cache_write_tokens"` // OpenRouter
+		} `json:"prompt_tokens_details"`
+		CompletionTokensDetails struct {
+			ReasoningTokens int `json:"reasoning_tokens"`
+		} `json:"completion_tokens_details"`
+		PromptCacheHitTokens int      `json:"prompt_cache_hit_tokens"` // DeepSeek
+		CachedTokens         int      `json:"cached_tokens"`           // Moonshot
+		Cost                 *float64 `json:"cost"`                    // OpenRouter, USD
 	} `json:"usage"`
 }
 
@@ -207,11 +275,18 @@ func defaultParseOpenAISSE(ctx context.Context, body io.Reader, out chan<- Strea
 			continue
 		}
 		// Emit usage if present (may arrive in the last chunk with empty choices)
-		if chunk.Usage != nil && (chunk.Usage.PromptTokens+chunk.Usage.CompletionTokens) > 0 {
-			out <- StreamEvent{Type: EventUsage, Usage: &Usage{
-				InputTokens:  chunk.Usage.PromptTokens,
-				OutputTokens: chunk.Usage.CompletionTokens,
-			}}
+		if u := chunk.Usage; u != nil && (u.PromptTokens+u.CompletionTokens) > 0 {
+			ev := &Usage{
+				InputTokens:      u.PromptTokens,
+				OutputTokens:     u.CompletionTokens,
+				CacheReadTokens:  max(u.PromptTokensDetails.CachedTokens, u.PromptCacheHitTokens, u.CachedTokens),
+				CacheWriteTokens: u.PromptTokensDetails.CacheWriteTokens,
+				ReasoningTokens:  u.CompletionTokensDetails.ReasoningTokens,
+			}
+			if u.Cost != nil {
+				ev.Cost = *u.Cost
+			}
+			out <- StreamEvent{Type: EventUsage, Usage: ev}
 		}
 		if len(chunk.Choices) == 0 {
 			continue
@@ -28,7 +28,7 @@ func NewDeepSeekClient(baseURL string) *DeepSeekClient {
 }
 
 func (c *DeepSeekClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
-	return c.stream(ctx, req)
+	return c.stream(ctx, jsonObjectOnly(req))
 }
 
 // parseDeepSeekSSE 在标准 OpenAI SSE 基础上额外处理 reasoning_content。
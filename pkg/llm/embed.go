@@ -1,5 +1,6 @@
 // pkg/llm/embed.go — Embedding API support (OpenAI-compatible /v1/embeddings).
-// Supports: openai, zhipu, minimax, and any custom OpenAI-compatible baseURL.
+// Supports: openai, zhipu, minimax, and any custom OpenAI-compatible baseURL;
+// gemini uses the native batchEmbedContents endpoint.
 // Returns nil from NewEmbedder when the provider doesn't support embeddings.
 package llm
 
@@ -28,6 +29,17 @@ var knownEmbedProviders = map[string]embedProviderSpec{
 	// Ollama runs locally; no API key required.
 	// Default model: nomic-embed-text (popular open embedding model, pull with `ollama pull nomic-embed-text`)
 	"ollama": {"http://localhost:11434/v1", "nomic-embed-text"},
+	// Gemini: native API (not OpenAI-compatible), see embedGemini.
+	"gemini": {geminiDefaultBase, "gemini-embedding-001"},
+}
+
+// embedProviderAlias folds provider aliases onto knownEmbedProviders keys.
+func embedProviderAlias(provider string) string {
+	provider = strings.ToLower(provider)
+	if provider == "google" {
+		return "gemini"
+	}
+	return provider
 }
 
 // noKeyProviders lists providers that don't require an API key (e.g. local services).
@@ -42,6 +54,7 @@ func RequiresAPIKey(provider string) bool {
 
 // Embedder calls an OpenAI-compatible /v1/embeddings endpoint.
 type Embedder struct {
+	provider  string
 	baseURL   string
 	model     string
 	client    *http.Client
@@ -54,7 +67,7 @@ type Embedder struct {
 // embedModel: override default embedding model (empty = use provider default)
 // Returns nil if the provider is not known and no baseURL is provided.
 func NewEmbedder(provider, baseURL, embedModel string) *Embedder {
-	provider = strings.ToLower(provider)
+	provider = embedProviderAlias(provider)
 	spec, known := knownEmbedProviders[provider]
 	if !known && baseURL == "" {
 		return nil
@@ -64,9 +77,11 @@ func NewEmbedder(provider, baseURL, embedModel string) *Embedder {
 	if effectiveURL == "" {
 		effectiveURL = spec.DefaultBaseURL
 	}
-	// Normalize: strip trailing slash, ensure /v1 suffix
+	// Normalize: strip trailing slash, ensure /v1 suffix (Gemini: /v1beta)
 	effectiveURL = strings.TrimRight(effectiveURL, "/")
-	if !strings.HasSuffix(effectiveURL, "/v1") {
+	if provider == "gemini" {
+		effectiveURL = normalizeGeminiBase(effectiveURL)
+	} else if !strings.HasSuffix(effectiveURL, "/v1") {
 		if !strings.Contains(effectiveURL[max(0, len(effectiveURL)-20):], "/v1") {
 			effectiveURL += "/v1"
 		}
@@ -83,6 +98,7 @@ func NewEmbedder(provider, baseURL, embedModel string) *Embedder {
 
 	client, clientErr := NewProviderHTTPClient(provider, effectiveURL, 60*time.Second)
 	return &Embedder{
+		provider:  provider,
 		baseURL:   effectiveURL,
 		model:     model,
 		client:    client,
@@ -105,6 +121,9 @@ func (e *Embedder) Embed(ctx context.Context, apiKey string, texts []string) ([]
 	if e.client == nil {
 		return nil, fmt.Errorf("embedding HTTP client is unavailable")
 	}
+	if e.provider == "gemini" {
+		return e.embedGemini(ctx, apiKey, texts)
+	}
 
 	payload := map[string]interface{}{
 		"model": e.model,
@@ -152,8 +171,66 @@ func (e *Embedder) Embed(ctx context.Context, apiKey string, texts []string) ([]
 	return vecs, nil
 }
 
+// geminiEmbedBatch is the batchEmbedContents per-request limit.
+const geminiEmbedBatch = 100
+
+// embedGemini calls models/{model}:batchEmbedContents, chunked to the API's
+// per-request limit. Output order matches input order.
+func (e *Embedder) embedGemini(ctx context.Context, apiKey string, texts []string) ([][]float32, error) {
+	model := geminiModelName(e.model)
+	endpoint := e.baseURL + "/models/" + model + ":batchEmbedContents"
+	vecs := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += geminiEmbedBatch {
+		end := min(start+geminiEmbedBatch, len(texts))
+		requests := make([]map[string]any, 0, end-start)
+		for _, t := range texts[start:end] {
+			requests = append(requests, map[string]any{
+				"model":   "models/" + model,
+				"content": map[string]any{"parts": []map[string]string{{"text": t}}},
+			})
+		}
+		body, err := json.Marshal(map[string]any{"requests": requests})
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("x-goog-api-key", apiKey)
+
+		resp, err := e.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("embed request: %w", err)
+		}
+		var result struct {
+			Embeddings []struct {
+				Values []float32 `json:"values"`
+			} `json:"embeddings"`
+		}
+		if resp.StatusCode != 200 {
+			errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+			resp.Body.Close()
+			return nil, fmt.Errorf("embed API %d: %s", resp.StatusCode, string(errBody))
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("decode embed response: %w", err)
+		}
+		if len(result.Embeddings) != end-start {
+			return nil, fmt.Errorf("embed API returned %d vectors for %d inputs", len(result.Embeddings), end-start)
+		}
+		for _, emb := range result.Embeddings {
+			vecs = append(vecs, emb.Values)
+		}
+	}
+	return vecs, nil
+}
+
 // SupportsEmbedding reports whether the given provider name has a known embedding endpoint.
 func SupportsEmbedding(provider string) bool {
-	_, ok := knownEmbedProviders[provider]
+	_, ok := knownEmbedProviders[embedProviderAlias(provider)]
 	return ok
 }
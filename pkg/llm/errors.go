@@ -21,7 +21,7 @@ import (
 // context length, content filter) return false.
 //
 // Detection is heuristic-based: we match on:
-//  1. Stable HTTP status codes (429 / 500 / 502 / 503 / 504)
+//  1. Stable HTTP status codes (429 / 500 / 502 / 503 / 504 / 529)
 //  2. Go stdlib net error interfaces (net.Error.Timeout, *net.OpError etc.)
 //  3. Common substrings across provider error messages
 func IsTransient(err error) bool {
@@ -53,6 +53,7 @@ func IsTransient(err error) bool {
 		"503", "service unavailable", "temporarily unavailable",
 		"504", "gateway timeout",
 		"500", "internal server error",
+		"529", "overloaded", // Anthropic overloaded_error
 	}
 	for _, needle := range transientStatuses {
 		if strings.Contains(msg, needle) {
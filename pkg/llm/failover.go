@@ -0,0 +1,227 @@
+// pkg/llm/failover.go — Cross-model fallback chain for Client.Stream.
+//
+// RetryClient covers "the same provider hiccupped"; FailoverClient covers
+// "this provider is down or our key is bad — try the next configured model".
+//
+// Design constraints:
+//   - Every target is wrapped in its own throttle + retry, so a switch only
+//     happens once that target's retry schedule is exhausted.
+//   - Switches only happen BEFORE any content (text / thinking / tool call)
+//     has streamed. After that the error is surfaced as-is, same rule as
+//     RetryClient, to avoid half an answer from one model and the rest from
+//     another.
+//   - Only IsTransient / IsAuthFailure errors switch. Context-length or
+//     malformed-request errors would fail the same way on the next model.
+//   - Targets known to be down (throttle cooldown, fresh failed ping) are
+//     moved to the back rather than dropped, so a stale health signal can
+//     never make the whole chain unusable.
+//   - A successful switch is sticky for the client's lifetime (one runner
+//     turn): later tool-loop iterations go straight to the model that
+//     answered instead of waiting out the primary's retries again.
+package llm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Zyling-ai/zyhive/pkg/logging"
+)
+
+// FailoverTarget is one model in a fallback chain.
+type FailoverTarget struct {
+	Provider string // provider type, e.g. "anthropic"; keys throttle + health lookups
+	Model    string // value sent as ChatRequest.Model
+	APIKey   string
+	BaseURL  string
+	Client   Client // bare provider client; WithFailover adds throttle + retry
+}
+
+// ModelSwitch is the payload of an EventModelSwitch event.
+type ModelSwitch struct {
+	FromProvider string `json:"from_provider"`
+	FromModel    string `json:"from_model"`
+	ToProvider   string `json:"to_provider"`
+	ToModel      string `json:"to_model"`
+	Reason       string `json:"reason"`
+}
+
+// FailoverClient tries an ordered list of targets. When the answering target
+// differs from the one the previous call used, the stream is prefixed with an
+// EventModelSwitch so callers can surface the switch and attribute usage to
+// the model that actually answered.
+type FailoverClient struct {
+	targets []FailoverTarget
+
+	mu      sync.Mutex
+	current int // index of the target the caller currently believes is answering
+}
+
+// WithFailover builds a FailoverClient. targets[0] is the primary model. Each
+// target's Client is wrapped with the global throttle (keyed by its Provider)
+// and WithRetry; callers should NOT wrap the result again.
+func WithFailover(targets []FailoverTarget) *FailoverClient {
+	wrapped := make([]FailoverTarget, len(targets))
+	for i, t := range targets {
+		if th := GlobalThrottle(); th != nil {
+			t.Client = WithThrottle(t.Client, th, t.Provider)
+		}
+		t.Client = WithRetry(t.Client)
+		wrapped[i] = t
+	}
+	return &FailoverClient{targets: wrapped}
+}
+
+// Stream implements Client.
+func (f *FailoverClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	f.mu.Lock()
+	from := f.current
+	f.mu.Unlock()
+
+	order := f.order(from)
+	var lastErr error
+	for n, idx := range order {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		t := f.targets[idx]
+		r := *req
+		r.Model = t.Model
+		r.APIKey = t.APIKey
+
+		last := n == len(order)-1
+		ch, err := t.Client.Stream(ctx, &r)
+		if err != nil {
+			if last || !shouldFailover(ctx, err) {
+				return nil, err
+			}
+			f.logSwitch(ctx, t, err)
+			lastErr = err
+			continue
+		}
+		head, leadErr := awaitContent(ch)
+		if leadErr != nil && !last && shouldFailover(ctx, leadErr) {
+			f.logSwitch(ctx, t, leadErr)
+			lastErr = leadErr
+			continue
+		}
+
+		var sw *ModelSwitch
+		if idx != from {
+			prev := f.targets[from]
+			reason := "unhealthy, skipped"
+			if lastErr != nil {
+				reason = truncateReason(lastErr.Error())
+			}
+			sw = &ModelSwitch{
+				FromProvider: prev.Provider,
+				FromModel:    prev.Model,
+				ToProvider:   t.Provider,
+				ToModel:      t.Model,
+				Reason:       reason,
+			}
+			f.mu.Lock()
+			f.current = idx
+			f.mu.Unlock()
+		}
+		out := make(chan StreamEvent, 16)
+		go func() {
+			defer close(out)
+			if sw != nil {
+				out <- StreamEvent{Type: EventModelSwitch, Switch: sw}
+			}
+			for _, ev := range head {
+				out <- ev
+			}
+			if leadErr != nil {
+				out <- StreamEvent{Type: EventError, Err: leadErr}
+				return
+			}
+			for ev := range ch {
+				out <- ev
+			}
+		}()
+		return out, nil
+	}
+	return nil, lastErr
+}
+
+// order returns target indices to try: starting at the current target and
+// wrapping around, with known-down targets moved (stably) to the back.
+func (f *FailoverClient) order(start int) []int {
+	var healthy, down []int
+	for i := range f.targets {
+		idx := (start + i) % len(f.targets)
+		if targetKnownDown(f.targets[idx]) {
+			down = append(down, idx)
+		} else {
+			healthy = append(healthy, idx)
+		}
+	}
+	return append(healthy, down...)
+}
+
+func (f *FailoverClient) logSwitch(ctx context.Context, t FailoverTarget, err error) {
+	logging.FromContext(ctx).Warn("llm failover",
+		"provider", t.Provider,
+		"model", t.Model,
+		"err", err.Error(),
+	)
+}
+
+// targetKnownDown consults the two health signals we already keep: the
+// adaptive throttle's Retry-After cooldown and the cached provider ping.
+// Neither probes the network.
+func targetKnownDown(t FailoverTarget) bool {
+	if snap, ok := GlobalThrottle().(interface {
+		Snapshot() []ThrottleStateSnapshot
+	}); ok {
+		for _, s := range snap.Snapshot() {
+			if s.ProviderID == t.Provider && s.CooldownRemainingS > 0 {
+				return true
+			}
+		}
+	}
+	if res, ok := PingStatus(t.Provider, t.APIKey, t.BaseURL); ok && !res.OK {
+		return true
+	}
+	return false
+}
+
+// shouldFailover reports whether err is worth trying the next model for.
+func shouldFailover(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	return IsTransient(err) || IsAuthFailure(err)
+}
+
+// awaitContent buffers the leading events of a stream until the first one
+// that carries user-visible output (or the stop event). A leading error is
+// returned separately so the caller can still fail over; the remainder of ch
+// is drained in the background so the producer goroutine can exit.
+func awaitContent(ch <-chan StreamEvent) ([]StreamEvent, error) {
+	var head []StreamEvent
+	for ev := range ch {
+		if ev.Type == EventError && ev.Err != nil {
+			go func() {
+				for range ch {
+				}
+			}()
+			return head, ev.Err
+		}
+		head = append(head, ev)
+		switch ev.Type {
+		case EventTextDelta, EventThinkingDelta, EventToolCall, EventToolDelta, EventStop:
+			return head, nil
+		}
+	}
+	return head, nil
+}
+
+func truncateReason(s string) string {
+	const max = 200
+	if r := []rune(s); len(r) > max {
+		return string(r[:max]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// scriptedClient replays a fixed outcome and records the request it saw.
+type scriptedClient struct {
+	err    error
+	events []StreamEvent
+	calls  int
+	seen   ChatRequest
+}
+
+func (s *scriptedClient) Stream(_ context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	s.calls++
+	s.seen = *req
+	if s.err != nil {
+		return nil, s.err
+	}
+	ch := make(chan StreamEvent, len(s.events))
+	for _, ev := range s.events {
+		ch <- ev
+	}
+	close(ch)
+	return ch, nil
+}
+
+func collect(t *testing.T, c Client) []StreamEvent {
+	t.Helper()
+	ch, err := c.Stream(context.Background(), &ChatRequest{Model: "ignored", APIKey: "ignored"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []StreamEvent
+	for ev := range ch {
+		out = append(out, ev)
+	}
+	return out
+}
+
+func TestFailoverSwitchesOnAuthFailure(t *testing.T) {
+	primary := &scriptedClient{err: errors.New("401 unauthorized")}
+	backup := &scriptedClient{events: []StreamEvent{
+		{Type: EventTextDelta, Text: "hi"},
+		{Type: EventUsage, Usage: &Usage{InputTokens: 3, OutputTokens: 1}},
+		{Type: EventStop, StopReason: "end_turn"},
+	}}
+	c := WithFailover([]FailoverTarget{
+		{Provider: "anthropic", Model: "anthropic/claude", APIKey: "a", Client: primary},
+		{Provider: "deepseek", Model: "deepseek/deepseek-chat", APIKey: "d", Client: backup},
+	})
+
+	evs := collect(t, c)
+	if len(evs) != 4 || evs[0].Type != EventModelSwitch || evs[1].Text != "hi" {
+		t.Fatalf("events = %+v", evs)
+	}
+	sw := evs[0].Switch
+	if sw.FromModel != "anthropic/claude" || sw.ToProvider != "deepseek" || sw.ToModel != "deepseek/deepseek-chat" || sw.Reason == "" {
+		t.Fatalf("switch = %+v", sw)
+	}
+	if backup.seen.Model != "deepseek/deepseek-chat" || backup.seen.APIKey != "d" {
+		t.Fatalf("backup got model=%q key=%q", backup.seen.Model, backup.seen.APIKey)
+	}
+
+	// Sticky: the next call goes straight to the backup, with no new switch.
+	evs = collect(t, c)
+	if primary.calls != 1 || backup.calls != 2 || evs[0].Type == EventModelSwitch {
+		t.Fatalf("primary=%d backup=%d first=%s", primary.calls, backup.calls, evs[0].Type)
+	}
+}
+
+func TestFailoverSwitchesOnLeadingStreamError(t *testing.T) {
+	primary := &scriptedClient{events: []StreamEvent{
+		{Type: EventStart},
+		{Type: EventError, Err: errors.New("overloaded_error: 529")},
+	}}
+	backup := &scriptedClient{events: []StreamEvent{{Type: EventTextDelta, Text: "ok"}}}
+	evs := collect(t, WithFailover([]FailoverTarget{
+		{Provider: "anthropic", Model: "p", Client: primary},
+		{Provider: "openai", Model: "b", Client: backup},
+	}))
+	if len(evs) != 2 || evs[0].Type != EventModelSwitch || evs[1].Text != "ok" {
+		t.Fatalf("events = %+v", evs)
+	}
+}
+
+func TestFailoverKeepsErrorsAfterContent(t *testing.T) {
+	midErr := errors.New("503 service unavailable")
+	primary := &scriptedClient{events: []StreamEvent{
+		{Type: EventTextDelta, Text: "par"},
+		{Type: EventError, Err: midErr},
+	}}
+	backup := &scriptedClient{}
+	evs := collect(t, WithFailover([]FailoverTarget{
+		{Provider: "anthropic", Model: "p", Client: primary},
+		{Provider: "openai", Model: "b", Client: backup},
+	}))
+	if backup.calls != 0 || len(evs) != 2 || evs[1].Err != midErr {
+		t.Fatalf("backup calls=%d events=%+v", backup.calls, evs)
+	}
+}
+
+func TestFailoverDoesNotSwitchOnBadRequest(t *testing.T) {
+	primary := &scriptedClient{err: errors.New("400 context_length_exceeded")}
+	backup := &scriptedClient{}
+	_, err := WithFailover([]FailoverTarget{
+		{Provider: "anthropic", Model: "p", Client: primary},
+		{Provider: "openai", Model: "b", Client: backup},
+	}).Stream(context.Background(), &ChatRequest{})
+	if err == nil || backup.calls != 0 {
+		t.Fatalf("err=%v backup calls=%d", err, backup.calls)
+	}
+}
+
+func TestFailoverSkipsKnownDownProvider(t *testing.T) {
+	ClearPingCache()
+	defer ClearPingCache()
+	pingCacheMu.Lock()
+	pingCache[pingCacheKey("anthropic", "a", "")] = &PingResult{OK: false, StatusCode: 529, CheckedAt: time.Now()}
+	pingCacheMu.Unlock()
+
+	primary := &scriptedClient{events: []StreamEvent{{Type: EventTextDelta, Text: "primary"}}}
+	backup := &scriptedClient{events: []StreamEvent{{Type: EventTextDelta, Text: "backup"}}}
+	evs := collect(t, WithFailover([]FailoverTarget{
+		{Provider: "anthropic", Model: "p", APIKey: "a", Client: primary},
+		{Provider: "openai", Model: "b", APIKey: "b", Client: backup},
+	}))
+	if primary.calls != 0 || len(evs) != 2 || evs[0].Switch == nil || evs[1].Text != "backup" {
+		t.Fatalf("primary calls=%d events=%+v", primary.calls, evs)
+	}
+}
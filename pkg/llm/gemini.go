@@ -0,0 +1,457 @@
+// pkg/llm/gemini.go — Google Gemini 原生客户端（generateContent / streamGenerateContent）。
+//
+// 与 OpenAI-compatible 兼容层相比，原生 API 保留了：
+//   - functionCall / functionResponse（工具调用，含 thoughtSignature 回传）
+//   - inlineData（图片 / PDF 视觉输入）
+//   - usageMetadata.cachedContentTokenCount（隐式缓存命中，计入 CacheReadTokens）
+//   - thought 部分（thinking 模型的思考摘要 → EventThinkingDelta）
+//
+// Runner 中存储的是 Anthropic 风格的 content blocks，这里负责双向转换。
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const geminiDefaultBase = "https://generativelanguage.googleapis.com/v1beta"
+
+// GeminiClient implements Client for the native Gemini API.
+type GeminiClient struct {
+	baseURL    string
+	httpClient *http.Client
+	clientErr  error
+
+	// signatures maps tool call IDs to the thoughtSignature Gemini attached
+	// to the functionCall part. Thinking models require the signature to be
+	// echoed back with the call in the follow-up request of the same turn;
+	// the runner keeps one client per run, so in-memory is enough.
+	mu         sync.Mutex
+	signatures map[string]string
+}
+
+// NewGeminiClient creates a Gemini client. baseURL 为空时使用官方 v1beta 地址。
+func NewGeminiClient(baseURL string) *GeminiClient {
+	baseURL = normalizeGeminiBase(baseURL)
+	client, err := NewProviderHTTPClient("gemini", baseURL, 0)
+	return &GeminiClient{
+		baseURL:    baseURL,
+		httpClient: client,
+		clientErr:  err,
+		signatures: make(map[string]string),
+	}
+}
+
+// normalizeGeminiBase 补全版本前缀：用户常填 https://generativelanguage.googleapis.com。
+func normalizeGeminiBase(baseURL string) string {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		return geminiDefaultBase
+	}
+	if !strings.HasSuffix(baseURL, "/v1beta") && !strings.HasSuffix(baseURL, "/v1") {
+		baseURL += "/v1beta"
+	}
+	return baseURL
+}
+
+// geminiModelName strips provider prefixes: "gemini/gemini-2.5-flash" and
+// "models/gemini-2.5-flash" both become "gemini-2.5-flash".
+func geminiModelName(model string) string {
+	model = strings.TrimPrefix(model, "models/")
+	if idx := strings.LastIndex(model, "/"); idx >= 0 {
+		model = model[idx+1:]
+	}
+	return model
+}
+
+// Stream sends a streamGenerateContent request (SSE) and emits events.
+func (c *GeminiClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	if c.clientErr != nil {
+		return nil, fmt.Errorf("invalid provider endpoint: %w", c.clientErr)
+	}
+	if c.httpClient == nil {
+		return nil, fmt.Errorf("provider HTTP client is unavailable")
+	}
+	body, err := c.buildRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	endpoint := c.baseURL + "/models/" + url.PathEscape(geminiModelName(req.Model)) + ":streamGenerateContent?alt=sse"
+
+	makeReq := func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("x-goog-api-key", req.APIKey)
+		return httpReq, nil
+	}
+
+	resp, err := doWithRetry(ctx, c.httpClient, makeReq)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("gemini api error: status %d: %s", resp.StatusCode, string(errBody))
+	}
+
+	events := make(chan StreamEvent, 32)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		keepCtx, keepCancel := context.WithCancel(ctx)
+		defer keepCancel()
+		kr := newKeepaliveReader(resp.Body, streamKeepaliveTimeout, keepCancel)
+		defer kr.Stop()
+		c.parseSSE(keepCtx, kr, events)
+	}()
+	return events, nil
+}
+
+// ── 请求构建 ──────────────────────────────────────────────────────────────────
+
+type geminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	ThoughtSignature string                  `json:"thoughtSignature,omitempty"`
+	InlineData       *geminiInlineData       `json:"inlineData,omitempty"`
+	FunctionCall     *geminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *geminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+type geminiInlineData struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type geminiFunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty"`
+}
+
+type geminiFunctionResponse struct {
+	ID       string         `json:"id,omitempty"`
+	Name     string         `json:"name"`
+	Response map[string]any `json:"response"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+// geminiBlock is the subset of Anthropic content blocks the runner stores.
+type geminiBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+	Source    *struct {
+		Type      string `json:"type"`
+		MediaType string `json:"media_type"`
+		Data      string `json:"data"`
+	} `json:"source,omitempty"`
+}
+
+func (c *GeminiClient) buildRequest(req *ChatRequest) ([]byte, error) {
+	contents, err := c.convertMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	payload := map[string]any{"contents": contents}
+	if req.System != "" {
+		payload["systemInstruction"] = geminiContent{Parts: []geminiPart{{Text: req.System}}}
+	}
+	genCfg := map[string]any{}
+	if req.MaxTokens > 0 {
+		genCfg["maxOutputTokens"] = req.MaxTokens
+	}
+	if req.Temperature != nil {
+		genCfg["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		genCfg["topP"] = *req.TopP
+	}
+	if len(req.Stop) > 0 {
+		genCfg["stopSequences"] = req.Stop
+	}
+	if rf := req.ResponseFormat; rf != nil {
+		genCfg["responseMimeType"] = "application/json"
+		if rf.Type == ResponseFormatJSONSchema && len(rf.Schema) > 0 {
+			genCfg["responseJsonSchema"] = rf.Schema
+		}
+	}
+	if req.ThinkingBudget > 0 {
+		genCfg["thinkingConfig"] = map[string]any{"thinkingBudget": req.ThinkingBudget, "includeThoughts": true}
+	}
+	if len(genCfg) > 0 {
+		payload["generationConfig"] = genCfg
+	}
+	if len(req.Tools) > 0 {
+		decls := make([]map[string]any, 0, len(req.Tools))
+		for _, t := range req.Tools {
+			decl := map[string]any{"name": t.Name, "description": t.Description}
+			// parametersJsonSchema accepts full JSON Schema, so tool schemas
+			// (additionalProperties, $defs, …) pass through unchanged.
+			if len(t.InputSchema) > 0 {
+				decl["parametersJsonSchema"] = t.InputSchema
+			}
+			decls = append(decls, decl)
+		}
+		payload["tools"] = []map[string]any{{"functionDeclarations": decls}}
+		if tc := req.ToolChoice; tc != nil {
+			fc := map[string]any{"mode": "AUTO"}
+			switch tc.Type {
+			case ToolChoiceAny:
+				fc["mode"] = "ANY"
+			case ToolChoiceNone:
+				fc["mode"] = "NONE"
+			case ToolChoiceTool:
+				fc["mode"] = "ANY"
+				fc["allowedFunctionNames"] = []string{tc.Name}
+			}
+			payload["toolConfig"] = map[string]any{"functionCallingConfig": fc}
+		}
+	}
+	return json.Marshal(payload)
+}
+
+// convertMessages maps Anthropic-style history to Gemini contents.
+// assistant → "model"; tool_result blocks become functionResponse parts,
+// which need the function name — recovered from the matching tool_use.
+func (c *GeminiClient) convertMessages(msgs []ChatMessage) ([]geminiContent, error) {
+	toolNames := make(map[string]string)
+	out := make([]geminiContent, 0, len(msgs))
+	for _, m := range msgs {
+		role := "user"
+		if m.Role == "assistant" {
+			role = "model"
+		}
+		var parts []geminiPart
+		var s string
+		if err := json.Unmarshal(m.Content, &s); err == nil {
+			if s != "" {
+				parts = append(parts, geminiPart{Text: s})
+			}
+		} else {
+			var blocks []geminiBlock
+			if err := json.Unmarshal(m.Content, &blocks); err != nil {
+				return nil, fmt.Errorf("unsupported message content: %w", err)
+			}
+			for _, b := range blocks {
+				switch b.Type {
+				case "text":
+					if b.Text != "" {
+						parts = append(parts, geminiPart{Text: b.Text})
+					}
+				case "image", "document":
+					if b.Source != nil && b.Source.Type == "base64" && b.Source.Data != "" {
+						parts = append(parts, geminiPart{InlineData: &geminiInlineData{MimeType: b.Source.MediaType, Data: b.Source.Data}})
+					}
+				case "tool_use":
+					toolNames[b.ID] = b.Name
+					args := b.Input
+					if len(args) == 0 || string(args) == "null" {
+						args = json.RawMessage("{}")
+					}
+					parts = append(parts, geminiPart{
+						FunctionCall:     &geminiFunctionCall{ID: c.remoteCallID(b.ID), Name: b.Name, Args: args},
+						ThoughtSignature: c.signature(b.ID),
+					})
+				case "tool_result":
+					result := toolResultText(b.Content)
+					key := "output"
+					if b.IsError {
+						key = "error"
+					}
+					parts = append(parts, geminiPart{FunctionResponse: &geminiFunctionResponse{
+						ID:       c.remoteCallID(b.ToolUseID),
+						Name:     toolNames[b.ToolUseID],
+						Response: map[string]any{key: result},
+					}})
+				}
+			}
+		}
+		if len(parts) == 0 {
+			parts = []geminiPart{{Text: "."}} // Gemini rejects empty parts
+		}
+		out = append(out, geminiContent{Role: role, Parts: parts})
+	}
+	return out, nil
+}
+
+// toolResultText flattens a tool_result content (string or text blocks).
+func toolResultText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var blocks []geminiBlock
+	if json.Unmarshal(raw, &blocks) == nil {
+		var sb strings.Builder
+		for _, b := range blocks {
+			if b.Type == "text" {
+				sb.WriteString(b.Text)
+			}
+		}
+		return sb.String()
+	}
+	return string(raw)
+}
+
+// Tool call IDs: Gemini may or may not return functionCall.id. We always hand
+// the runner a non-empty ID ("gemini-call-{uuid}" when synthesised) and only
+// send real Gemini IDs back. Synthetic IDs must be unique across clients: a
+// new client is built per run, and the IDs end up in the session history
+// (other providers reject duplicate tool_use IDs) and in the tool audit.
+const geminiSyntheticIDPrefix = "gemini-call-"
+
+func (c *GeminiClient) remoteCallID(id string) string {
+	if strings.HasPrefix(id, geminiSyntheticIDPrefix) {
+		return ""
+	}
+	return id
+}
+
+func (c *GeminiClient) signature(id string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.signatures[id]
+}
+
+func (c *GeminiClient) rememberCall(id, signature string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if id == "" {
+		id = geminiSyntheticIDPrefix + uuid.NewString()
+	}
+	if signature != "" {
+		c.signatures[id] = signature
+	}
+	return id
+}
+
+// ── SSE 解析 ──────────────────────────────────────────────────────────────────
+
+type geminiChunk struct {
+	Candidates []struct {
+		Content      geminiContent `json:"content"`
+		FinishReason string        `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback *struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata *struct {
+		PromptTokenCount        int `json:"promptTokenCount"`
+		CandidatesTokenCount    int `json:"candidatesTokenCount"`
+		CachedContentTokenCount int `json:"cachedContentTokenCount"`
+		ThoughtsTokenCount      int `json:"thoughtsTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// parseSSE reads "data: {GenerateContentResponse}" lines. usageMetadata is
+// cumulative per chunk, so only the last one is emitted.
+func (c *GeminiClient) parseSSE(ctx context.Context, body io.Reader, out chan<- StreamEvent) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20) // inline images / signatures can be large
+	var (
+		usage       *Usage
+		finish      string
+		sawToolCall bool
+	)
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var chunk geminiChunk
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk); err != nil {
+			continue
+		}
+		if chunk.PromptFeedback != nil && chunk.PromptFeedback.BlockReason != "" {
+			out <- StreamEvent{Type: EventError, Err: fmt.Errorf("gemini blocked the prompt: %s", chunk.PromptFeedback.BlockReason)}
+			return
+		}
+		if u := chunk.UsageMetadata; u != nil {
+			usage = &Usage{
+				InputTokens:     u.PromptTokenCount,
+				OutputTokens:    u.CandidatesTokenCount + u.ThoughtsTokenCount,
+				CacheReadTokens: u.CachedContentTokenCount,
+				ReasoningTokens: u.ThoughtsTokenCount,
+			}
+		}
+		if len(chunk.Candidates) == 0 {
+			continue
+		}
+		cand := chunk.Candidates[0]
+		for _, p := range cand.Content.Parts {
+			switch {
+			case p.FunctionCall != nil:
+				args := p.FunctionCall.Args
+				if len(args) == 0 || string(args) == "null" {
+					args = json.RawMessage("{}")
+				}
+				sawToolCall = true
+				out <- StreamEvent{Type: EventToolCall, ToolCall: &ToolCall{
+					ID:    c.rememberCall(p.FunctionCall.ID, p.ThoughtSignature),
+					Name:  p.FunctionCall.Name,
+					Input: args,
+				}}
+			case p.Thought && p.Text != "":
+				out <- StreamEvent{Type: EventThinkingDelta, Text: p.Text}
+			case p.Text != "":
+				out <- StreamEvent{Type: EventTextDelta, Text: p.Text}
+			}
+		}
+		if cand.FinishReason != "" {
+			finish = cand.FinishReason
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		out <- StreamEvent{Type: EventError, Err: err}
+		return
+	}
+	if usage != nil && usage.InputTokens+usage.OutputTokens > 0 {
+		out <- StreamEvent{Type: EventUsage, Usage: usage}
+	}
+	switch finish {
+	case "", "STOP":
+		if sawToolCall {
+			out <- StreamEvent{Type: EventStop, StopReason: "tool_use"}
+		} else {
+			out <- StreamEvent{Type: EventStop, StopReason: "end_turn"}
+		}
+	case "MAX_TOKENS":
+		out <- StreamEvent{Type: EventStop, StopReason: "max_tokens"}
+	case "SAFETY", "RECITATION", "BLOCKLIST", "PROHIBITED_CONTENT", "SPII", "MALFORMED_FUNCTION_CALL":
+		out <- StreamEvent{Type: EventError, Err: fmt.Errorf("gemini stopped: %s", finish)}
+	default:
+		out <- StreamEvent{Type: EventStop, StopReason: strings.ToLower(finish)}
+	}
+}
@@ -0,0 +1,190 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestGeminiClient points a GeminiClient at an httptest server; the
+// loopback guard is bypassed by swapping in the server's own client.
+func newTestGeminiClient(srv *httptest.Server) *GeminiClient {
+	c := NewGeminiClient(srv.URL)
+	c.httpClient = srv.Client()
+	c.clientErr = nil
+	return c
+}
+
+func TestGeminiStreamToolCallRoundTrip(t *testing.T) {
+	var secondBody map[string]any
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path != "/v1beta/models/gemini-2.5-flash:streamGenerateContent" || r.URL.Query().Get("alt") != "sse" {
+			t.Errorf("unexpected URL %s", r.URL)
+		}
+		if r.Header.Get("x-goog-api-key") != "k" {
+			t.Errorf("missing api key header")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		if calls == 1 {
+			fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"pondering","thought":true}]}}]}`+"\n\n")
+			fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"functionCall":{"name":"read","args":{"path":"a.txt"}},"thoughtSignature":"sig-1"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":120,"candidatesTokenCount":8,"thoughtsTokenCount":4,"cachedContentTokenCount":100}}`+"\n\n")
+			return
+		}
+		_ = json.NewDecoder(r.Body).Decode(&secondBody)
+		fmt.Fprint(w, `data: {"candidates":[{"content":{"role":"model","parts":[{"text":"done"}]},"finishReason":"STOP"}],"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":1}}`+"\n\n")
+	}))
+	defer srv.Close()
+	c := newTestGeminiClient(srv)
+
+	req := &ChatRequest{
+		Model:  "gemini/gemini-2.5-flash",
+		APIKey: "k",
+		System: "be brief",
+		Tools:  []ToolDef{{Name: "read", Description: "read a file", InputSchema: json.RawMessage(`{"type":"object","additionalProperties":false}`)}},
+		Messages: []ChatMessage{{Role: "user", Content: json.RawMessage(
+			`[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBOR"}},{"type":"text","text":"what is in a.txt?"}]`)}},
+	}
+	events, err := c.Stream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var (
+		thinking string
+		call     *ToolCall
+		usage    *Usage
+		stop     string
+	)
+	for ev := range events {
+		switch ev.Type {
+		case EventThinkingDelta:
+			thinking += ev.Text
+		case EventToolCall:
+			call = ev.ToolCall
+		case EventUsage:
+			usage = ev.Usage
+		case EventStop:
+			stop = ev.StopReason
+		case EventError:
+			t.Fatal(ev.Err)
+		}
+	}
+	if thinking != "pondering" || call == nil || call.Name != "read" || call.ID == "" || string(call.Input) != `{"path":"a.txt"}` {
+		t.Fatalf("thinking=%q call=%+v", thinking, call)
+	}
+	if stop != "tool_use" {
+		t.Fatalf("stop reason = %q", stop)
+	}
+	if usage == nil || usage.InputTokens != 120 || usage.OutputTokens != 12 || usage.CacheReadTokens != 100 {
+		t.Fatalf("usage = %+v", usage)
+	}
+
+	// Second turn: the runner appends the tool_use + tool_result in Anthropic form.
+	req.Messages = append(req.Messages,
+		ChatMessage{Role: "assistant", Content: json.RawMessage(fmt.Sprintf(`[{"type":"tool_use","id":%q,"name":"read","input":{"path":"a.txt"}}]`, call.ID))},
+		ChatMessage{Role: "user", Content: json.RawMessage(fmt.Sprintf(`[{"type":"tool_result","tool_use_id":%q,"content":"hello"}]`, call.ID))},
+	)
+	events, err = c.Stream(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text string
+	for ev := range events {
+		text += ev.Text
+	}
+	if text != "done" {
+		t.Fatalf("text = %q", text)
+	}
+
+	raw, _ := json.Marshal(secondBody)
+	body := string(raw)
+	for _, want := range []string{
+		`"systemInstruction":{"parts":[{"text":"be brief"}]}`,
+		`"inlineData":{"data":"iVBOR","mimeType":"image/png"}`,
+		`"role":"model"`,
+		`"thoughtSignature":"sig-1"`,
+		`"functionResponse":{"name":"read","response":{"output":"hello"}}`,
+		`"parametersJsonSchema":{"additionalProperties":false,"type":"object"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("second request missing %s\nbody: %s", want, body)
+		}
+	}
+}
+
+// TestGeminiSyntheticCallIDsAreUnique — a new client is built per run, so
+// synthetic IDs must not repeat across clients within one session history.
+func TestGeminiSyntheticCallIDsAreUnique(t *testing.T) {
+	a, b := NewGeminiClient(""), NewGeminiClient("")
+	ids := map[string]bool{}
+	for _, c := range []*GeminiClient{a, b, a, b} {
+		id := c.rememberCall("", "")
+		if ids[id] || c.remoteCallID(id) != "" {
+			t.Fatalf("synthetic id %q repeated or sent upstream", id)
+		}
+		ids[id] = true
+	}
+	if got := a.rememberCall("real-id", ""); got != "real-id" || a.remoteCallID(got) != "real-id" {
+		t.Errorf("real Gemini ids must pass through, got %q", got)
+	}
+}
+
+func TestGeminiStreamReportsBlockedPrompt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `data: {"promptFeedback":{"blockReason":"SAFETY"}}`+"\n\n")
+	}))
+	defer srv.Close()
+	events, err := newTestGeminiClient(srv).Stream(context.Background(), &ChatRequest{
+		Model:    "gemini-2.5-flash",
+		Messages: []ChatMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gotErr error
+	for ev := range events {
+		if ev.Type == EventError {
+			gotErr = ev.Err
+		}
+	}
+	if gotErr == nil || !strings.Contains(gotErr.Error(), "SAFETY") {
+		t.Fatalf("expected blocked-prompt error, got %v", gotErr)
+	}
+}
+
+func TestGeminiEmbedder(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1beta/models/gemini-embedding-001:batchEmbedContents" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req struct {
+			Requests []json.RawMessage `json:"requests"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		out := make([]map[string]any, len(req.Requests))
+		for i := range out {
+			out[i] = map[string]any{"values": []float32{float32(i), 1}}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"embeddings": out})
+	}))
+	defer srv.Close()
+
+	if !SupportsEmbedding("google") {
+		t.Fatal("google alias should support embeddings")
+	}
+	e := NewEmbedder("gemini", srv.URL, "")
+	e.client = srv.Client()
+	e.clientErr = nil
+	vecs, err := e.Embed(context.Background(), "k", []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != 2 || vecs[1][0] != 1 {
+		t.Fatalf("vecs = %v", vecs)
+	}
+}
@@ -83,6 +83,18 @@ func Ping(ctx context.Context, provider, apiKey, baseURL string, forceRefresh bo
 	return result
 }
 
+// PingStatus returns the cached ping result for provider + key + baseURL if
+// it is still fresh. It never probes; ok is false on a cache miss.
+func PingStatus(provider, apiKey, baseURL string) (*PingResult, bool) {
+	pingCacheMu.RLock()
+	defer pingCacheMu.RUnlock()
+	cached, ok := pingCache[pingCacheKey(provider, apiKey, baseURL)]
+	if !ok || cached == nil || time.Since(cached.CheckedAt) >= pingCacheTTL {
+		return nil, false
+	}
+	return cached, true
+}
+
 // runPing actually makes the HTTP request. Separated from Ping() so tests can
 // bypass the cache.
 func runPing(ctx context.Context, provider, apiKey, baseURL string) *PingResult {
@@ -107,6 +119,8 @@ func pingProvider(ctx context.Context, provider, apiKey, baseURL string) (bool,
 	switch strings.ToLower(provider) {
 	case "anthropic":
 		return pingAnthropic(ctx, apiKey, baseURL)
+	case "gemini", "google":
+		return pingGemini(ctx, apiKey, baseURL)
 	case "openai", "deepseek", "moonshot", "kimi", "zhipu", "qwen", "openrouter", "minimax", "custom", "ollama":
 		// OpenAI-compatible: /v1/chat/completions with max_tokens=1
 		return pingOpenAICompat(ctx, provider, apiKey, baseURL, defaultModelForProvider(provider))
@@ -173,6 +187,33 @@ func pingAnthropic(ctx context.Context, apiKey, baseURL string) (bool, int, stri
 	return false, resp.StatusCode, fmt.Sprintf("HTTP %d", resp.StatusCode)
 }
 
+// pingGemini lists one model instead of generating: free, and still proves
+// the endpoint is reachable and the key is accepted.
+func pingGemini(ctx context.Context, apiKey, baseURL string) (bool, int, string) {
+	baseURL = normalizeGeminiBase(baseURL)
+	req, _ := http.NewRequestWithContext(ctx, "GET", baseURL+"/models?pageSize=1", nil)
+	req.Header.Set("x-goog-api-key", apiKey)
+	client, clientErr := NewProviderHTTPClient("gemini", baseURL, 8*time.Second)
+	if clientErr != nil {
+		return false, 0, clientErr.Error()
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, 0, err.Error()
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == 200:
+		return true, 200, ""
+	case resp.StatusCode == 400 || resp.StatusCode == 401 || resp.StatusCode == 403:
+		// Gemini answers an invalid key with 400 API_KEY_INVALID.
+		return false, resp.StatusCode, "authentication failed"
+	case resp.StatusCode == 429:
+		return false, resp.StatusCode, "rate limited"
+	}
+	return false, resp.StatusCode, fmt.Sprintf("HTTP %d", resp.StatusCode)
+}
+
 func pingOpenAICompat(ctx context.Context, provider, apiKey, baseURL, model string) (bool, int, string) {
 	if baseURL == "" {
 		if strings.EqualFold(provider, "ollama") {
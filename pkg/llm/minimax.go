@@ -20,5 +20,5 @@ func NewMinimaxClient(baseURL string) *MinimaxClient {
 }
 
 func (c *MinimaxClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
-	return c.stream(ctx, req)
+	return c.stream(ctx, jsonObjectOnly(req))
 }
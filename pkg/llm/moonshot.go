@@ -19,5 +19,5 @@ func NewMoonshotClient(baseURL string) *MoonshotClient {
 }
 
 func (c *MoonshotClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
-	return c.stream(ctx, req)
+	return c.stream(ctx, jsonObjectOnly(req))
 }
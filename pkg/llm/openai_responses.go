@@ -0,0 +1,485 @@
+// pkg/llm/openai_responses.go — OpenAI Responses API 客户端（/v1/responses）。
+//
+// o 系列 / gpt-5 的推理摘要、更细粒度的工具调用流式事件只在 Responses API
+// 提供；/chat/completions 仍是默认（ModelEntry.api = "responses" 时选用本客户端）。
+//
+// 与 Chat Completions 的差异：
+//   - 输入是 item 列表：message / function_call / function_call_output / reasoning
+//   - 推理摘要流（response.reasoning_summary_text.delta）→ EventThinkingDelta
+//   - usage.output_tokens_details.reasoning_tokens → Usage.ReasoningTokens
+//   - 推理模型在工具循环中需要回传上一轮的 reasoning item：
+//     store=false（默认）时请求 reasoning.encrypted_content 并按 tool call ID
+//     缓存、下轮原样回传（同 AnthropicClient 的 thinking 块）；
+//     chain=true 时改用 previous_response_id 续接，只发送新增的工具结果。
+//   - 不支持 stop 序列，Stop 被忽略。
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// OpenAIResponsesClient implements Client for the OpenAI Responses API.
+type OpenAIResponsesClient struct {
+	openAIBase
+	chain bool // 用 previous_response_id 续接工具循环（store=true）
+
+	// Both maps are keyed by tool call ID; the runner keeps one client per
+	// run, so in-memory is enough.
+	mu        sync.Mutex
+	reasoning map[string][]json.RawMessage // reasoning items preceding the call (store=false replay)
+	responses map[string]string            // response that issued the call (chain mode)
+}
+
+// NewOpenAIResponsesClient creates a Responses API client. baseURL 为空时使用
+// 官方地址；chain 见 ModelEntry.chainResponses。
+func NewOpenAIResponsesClient(baseURL string, chain bool) *OpenAIResponsesClient {
+	if baseURL == "" {
+		baseURL = openAIDefaultBase
+	}
+	return &OpenAIResponsesClient{
+		openAIBase: newOpenAIBase(baseURL, nil),
+		chain:      chain,
+		reasoning:  map[string][]json.RawMessage{},
+		responses:  map[string]string{},
+	}
+}
+
+func (c *OpenAIResponsesClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
+	if c.clientErr != nil {
+		return nil, fmt.Errorf("invalid provider endpoint: %w", c.clientErr)
+	}
+	if c.httpClient == nil {
+		return nil, fmt.Errorf("provider HTTP client is unavailable")
+	}
+	body, err := c.buildRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+
+	makeReq := func() (*http.Request, error) {
+		httpReq, err := http.NewRequestWithContext(ctx, "POST",
+			c.baseURL+"/responses", bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer "+req.APIKey)
+		for k, v := range c.extraHeaders {
+			httpReq.Header.Set(k, v)
+		}
+		return httpReq, nil
+	}
+
+	resp, err := doWithRetry(ctx, c.httpClient, makeReq)
+	if err != nil {
+		return nil, fmt.Errorf("http request: %w", err)
+	}
+	if resp.StatusCode != 200 {
+		defer resp.Body.Close()
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("openai responses api error: status %d: %s", resp.StatusCode, string(errBody))
+	}
+
+	events := make(chan StreamEvent, 32)
+	go func() {
+		defer close(events)
+		defer resp.Body.Close()
+		keepCtx, keepCancel := context.WithCancel(ctx)
+		defer keepCancel()
+		kr := newKeepaliveReader(resp.Body, streamKeepaliveTimeout, keepCancel)
+		defer kr.Stop()
+		c.parseSSE(keepCtx, kr, events)
+	}()
+	return events, nil
+}
+
+// ── 请求构建 ──────────────────────────────────────────────────────────────────
+
+func (c *OpenAIResponsesClient) buildRequest(req *ChatRequest) ([]byte, error) {
+	model := req.Model
+	if idx := strings.LastIndex(model, "/"); idx >= 0 {
+		model = model[idx+1:]
+	}
+	reasoningModel := isOpenAIReasoningModel(model)
+
+	msgs := req.Messages
+	prevID := ""
+	if c.chain {
+		prevID, msgs = c.continuation(req.Messages)
+	}
+	input, err := c.convertMessages(msgs, reasoningModel && !c.chain)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]any{
+		"model":  model,
+		"input":  input,
+		"stream": true,
+		"store":  c.chain,
+	}
+	if req.System != "" {
+		// instructions are not carried over by previous_response_id; always send.
+		payload["instructions"] = req.System
+	}
+	if prevID != "" {
+		payload["previous_response_id"] = prevID
+	}
+	if req.MaxTokens > 0 {
+		payload["max_output_tokens"] = req.MaxTokens
+	}
+	if reasoningModel {
+		r := map[string]any{"summary": "auto"}
+		if req.ThinkingBudget > 0 {
+			r["effort"] = reasoningEffort(req.ThinkingBudget)
+		}
+		payload["reasoning"] = r
+		if !c.chain {
+			payload["include"] = []string{"reasoning.encrypted_content"}
+		}
+	} else {
+		if req.Temperature != nil {
+			payload["temperature"] = *req.Temperature
+		}
+		if req.TopP != nil {
+			payload["top_p"] = *req.TopP
+		}
+	}
+	if rf := req.ResponseFormat; rf != nil {
+		switch rf.Type {
+		case ResponseFormatJSONSchema:
+			name := rf.Name
+			if name == "" {
+				name = "response"
+			}
+			payload["text"] = map[string]any{"format": map[string]any{
+				"type":   "json_schema",
+				"name":   name,
+				"schema": rf.Schema,
+				"strict": rf.Strict,
+			}}
+		case ResponseFormatJSONObject:
+			payload["text"] = map[string]any{"format": map[string]any{"type": "json_object"}}
+		}
+	}
+	if len(req.Tools) > 0 {
+		tools := make([]map[string]any, 0, len(req.Tools))
+		for _, t := range req.Tools {
+			tools = append(tools, map[string]any{
+				"type":        "function",
+				"name":        t.Name,
+				"description": t.Description,
+				"parameters":  t.InputSchema,
+				"strict":      false,
+			})
+		}
+		payload["tools"] = tools
+		payload["tool_choice"] = responsesToolChoice(req.ToolChoice)
+	}
+	return json.Marshal(payload)
+}
+
+// responsesToolChoice maps ToolChoice onto the Responses API tool_choice.
+func responsesToolChoice(tc *ToolChoice) any {
+	if tc == nil {
+		return "auto"
+	}
+	switch tc.Type {
+	case ToolChoiceAny:
+		return "required"
+	case ToolChoiceNone:
+		return "none"
+	case ToolChoiceTool:
+		return map[string]any{"type": "function", "name": tc.Name}
+	}
+	return "auto"
+}
+
+// continuation finds the response the history can be continued from: the
+// last assistant message's tool calls must all come from one remembered
+// response, and something must follow it. Returns ("", msgs) otherwise.
+func (c *OpenAIResponsesClient) continuation(msgs []ChatMessage) (string, []ChatMessage) {
+	last := -1
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "assistant" {
+			last = i
+			break
+		}
+	}
+	if last < 0 || last == len(msgs)-1 {
+		return "", msgs
+	}
+	var blocks []geminiBlock
+	if json.Unmarshal(msgs[last].Content, &blocks) != nil {
+		return "", msgs
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := ""
+	for _, b := range blocks {
+		if b.Type != "tool_use" {
+			continue
+		}
+		r := c.responses[b.ID]
+		if r == "" || (id != "" && r != id) {
+			return "", msgs
+		}
+		id = r
+	}
+	if id == "" {
+		return "", msgs
+	}
+	return id, msgs[last+1:]
+}
+
+// convertMessages maps Anthropic-style history (geminiBlock is the shared
+// block shape) onto Responses input items. With replay, remembered reasoning
+// items are put back in front of the function calls they preceded.
+func (c *OpenAIResponsesClient) convertMessages(msgs []ChatMessage, replay bool) ([]any, error) {
+	items := make([]any, 0, len(msgs))
+	for _, m := range msgs {
+		var s string
+		if err := json.Unmarshal(m.Content, &s); err == nil {
+			items = append(items, map[string]any{"role": m.Role, "content": s})
+			continue
+		}
+		var blocks []geminiBlock
+		if err := json.Unmarshal(m.Content, &blocks); err != nil {
+			return nil, fmt.Errorf("unsupported message content: %w", err)
+		}
+		var (
+			parts   []map[string]any // user content parts
+			text    strings.Builder  // assistant text
+			calls   []any
+			outputs []any
+			replays []json.RawMessage
+		)
+		for _, b := range blocks {
+			switch b.Type {
+			case "text":
+				if m.Role == "assistant" {
+					text.WriteString(b.Text)
+				} else if b.Text != "" {
+					parts = append(parts, map[string]any{"type": "input_text", "text": b.Text})
+				}
+			case "image":
+				if b.Source != nil && b.Source.Type == "base64" && b.Source.Data != "" {
+					parts = append(parts, map[string]any{
+						"type":      "input_image",
+						"image_url": "data:" + b.Source.MediaType + ";base64," + b.Source.Data,
+					})
+				}
+			case "document":
+				if b.Source != nil && b.Source.Type == "base64" && b.Source.Data != "" {
+					parts = append(parts, map[string]any{
+						"type":      "input_file",
+						"filename":  "document.pdf",
+						"file_data": "data:" + b.Source.MediaType + ";base64," + b.Source.Data,
+					})
+				}
+			case "tool_use":
+				if replay && len(calls) == 0 {
+					replays = c.rememberedReasoning(b.ID)
+				}
+				args := string(b.Input)
+				if args == "" || args == "null" {
+					args = "{}"
+				}
+				calls = append(calls, map[string]any{
+					"type":      "function_call",
+					"call_id":   b.ID,
+					"name":      b.Name,
+					"arguments": args,
+				})
+			case "tool_result":
+				outputs = append(outputs, map[string]any{
+					"type":    "function_call_output",
+					"call_id": b.ToolUseID,
+					"output":  toolResultText(b.Content),
+				})
+			}
+		}
+		items = append(items, outputs...)
+		for _, r := range replays {
+			items = append(items, r)
+		}
+		if m.Role == "assistant" {
+			if text.Len() > 0 {
+				items = append(items, map[string]any{"role": "assistant", "content": text.String()})
+			}
+		} else if len(parts) > 0 {
+			items = append(items, map[string]any{"role": m.Role, "content": parts})
+		}
+		items = append(items, calls...)
+	}
+	return items, nil
+}
+
+func (c *OpenAIResponsesClient) rememberedReasoning(callID string) []json.RawMessage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reasoning[callID]
+}
+
+func (c *OpenAIResponsesClient) rememberCall(callID, responseID string, reasoning []json.RawMessage) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if responseID != "" {
+		c.responses[callID] = responseID
+	}
+	if len(reasoning) > 0 {
+		c.reasoning[callID] = reasoning
+	}
+}
+
+// ── SSE 解析 ──────────────────────────────────────────────────────────────────
+
+type responsesItem struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type responsesEvent struct {
+	Type         string          `json:"type"`
+	Delta        string          `json:"delta"`
+	OutputIndex  int             `json:"output_index"`
+	SummaryIndex int             `json:"summary_index"`
+	Item         json.RawMessage `json:"item"`
+	Message      string          `json:"message"` // type "error"
+	Code         string          `json:"code"`
+	Response     *struct {
+		ID                string `json:"id"`
+		Status            string `json:"status"`
+		IncompleteDetails *struct {
+			Reason string `json:"reason"`
+		} `json:"incomplete_details"`
+		Error *struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+		Usage *struct {
+			InputTokens        int `json:"input_tokens"`
+			InputTokensDetails struct {
+				CachedTokens int `json:"cached_tokens"`
+			} `json:"input_tokens_details"`
+			OutputTokens        int `json:"output_tokens"`
+			OutputTokensDetails struct {
+				ReasoningTokens int `json:"reasoning_tokens"`
+			} `json:"output_tokens_details"`
+		} `json:"usage"`
+	} `json:"response"`
+}
+
+// parseSSE reads "data: {event}" lines; the event name is repeated in the
+// JSON "type" field, so "event:" lines are skipped.
+func (c *OpenAIResponsesClient) parseSSE(ctx context.Context, body io.Reader, out chan<- StreamEvent) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20) // encrypted reasoning items can be large
+	var (
+		responseID  string
+		reasoning   []json.RawMessage // reasoning items of this response so far
+		sawToolCall bool
+	)
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		var ev responsesEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &ev); err != nil {
+			continue
+		}
+		switch ev.Type {
+		case "response.created", "response.in_progress":
+			if ev.Response != nil && ev.Response.ID != "" {
+				responseID = ev.Response.ID
+			}
+		case "response.output_text.delta", "response.refusal.delta":
+			if ev.Delta != "" {
+				out <- StreamEvent{Type: EventTextDelta, Text: ev.Delta}
+			}
+		case "response.reasoning_summary_part.added":
+			if ev.SummaryIndex > 0 {
+				out <- StreamEvent{Type: EventThinkingDelta, Text: "\n\n"}
+			}
+		case "response.reasoning_summary_text.delta":
+			if ev.Delta != "" {
+				out <- StreamEvent{Type: EventThinkingDelta, Text: ev.Delta}
+			}
+		case "response.function_call_arguments.delta":
+			if ev.Delta != "" {
+				out <- StreamEvent{Type: EventToolDelta, ToolDelta: ev.Delta}
+			}
+		case "response.output_item.done":
+			var item responsesItem
+			if json.Unmarshal(ev.Item, &item) != nil {
+				continue
+			}
+			switch item.Type {
+			case "reasoning":
+				reasoning = append(reasoning, append(json.RawMessage(nil), ev.Item...))
+			case "function_call":
+				args := json.RawMessage("{}")
+				if strings.TrimSpace(item.Arguments) != "" {
+					args = json.RawMessage(item.Arguments)
+				}
+				c.rememberCall(item.CallID, responseID, reasoning)
+				sawToolCall = true
+				out <- StreamEvent{Type: EventToolCall, ToolCall: &ToolCall{ID: item.CallID, Name: item.Name, Input: args}}
+			}
+		case "response.completed", "response.incomplete":
+			r := ev.Response
+			if r == nil {
+				continue
+			}
+			if u := r.Usage; u != nil {
+				out <- StreamEvent{Type: EventUsage, Usage: &Usage{
+					InputTokens:     u.InputTokens,
+					OutputTokens:    u.OutputTokens,
+					CacheReadTokens: u.InputTokensDetails.CachedTokens,
+					ReasoningTokens: u.OutputTokensDetails.ReasoningTokens,
+				}}
+			}
+			stop := "end_turn"
+			switch {
+			case sawToolCall:
+				stop = "tool_use"
+			case r.IncompleteDetails != nil && r.IncompleteDetails.Reason == "max_output_tokens":
+				stop = "max_tokens"
+			case r.IncompleteDetails != nil && r.IncompleteDetails.Reason != "":
+				stop = r.IncompleteDetails.Reason
+			}
+			out <- StreamEvent{Type: EventStop, StopReason: stop}
+			return
+		case "response.failed":
+			msg := "response failed"
+			if ev.Response != nil && ev.Response.Error != nil {
+				msg = ev.Response.Error.Code + ": " + ev.Response.Error.Message
+			}
+			out <- StreamEvent{Type: EventError, Err: fmt.Errorf("openai responses api error: %s", msg)}
+			return
+		case "error":
+			out <- StreamEvent{Type: EventError, Err: fmt.Errorf("openai responses api error: %s %s", ev.Code, ev.Message)}
+			return
+		}
+	}
+	if err := scanner.Err(); err != nil && err != io.EOF {
+		out <- StreamEvent{Type: EventError, Err: err}
+	}
+}
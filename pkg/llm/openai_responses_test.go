@@ -0,0 +1,201 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const responsesToolStream = `event: response.created
+data: {"type":"response.created","response":{"id":"resp_1","status":"in_progress"}}
+
+data: {"type":"response.reasoning_summary_part.added","summary_index":0}
+
+data: {"type":"response.reasoning_summary_text.delta","summary_index":0,"delta":"Need the file"}
+
+data: {"type":"response.reasoning_summary_part.added","summary_index":1}
+
+data: {"type":"response.reasoning_summary_text.delta","summary_index":1,"delta":"then answer"}
+
+data: {"type":"response.output_item.done","output_index":0,"item":{"type":"reasoning","id":"rs_1","summary":[],"encrypted_content":"ENC"}}
+
+data: {"type":"response.output_item.added","output_index":1,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"read","arguments":""}}
+
+data: {"type":"response.function_call_arguments.delta","output_index":1,"delta":"{\"path\":"}
+
+data: {"type":"response.function_call_arguments.delta","output_index":1,"delta":"\"a.txt\"}"}
+
+data: {"type":"response.output_item.done","output_index":1,"item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"read","arguments":"{\"path\":\"a.txt\"}"}}
+
+data: {"type":"response.completed","response":{"id":"resp_1","status":"completed","usage":{"input_tokens":900,"input_tokens_details":{"cached_tokens":512},"output_tokens":300,"output_tokens_details":{"reasoning_tokens":256}}}}
+
+`
+
+func runResponsesSSE(c *OpenAIResponsesClient, sse string) []StreamEvent {
+	ch := make(chan StreamEvent, 64)
+	go func() {
+		c.parseSSE(context.Background(), strings.NewReader(sse), ch)
+		close(ch)
+	}()
+	var evs []StreamEvent
+	for ev := range ch {
+		evs = append(evs, ev)
+	}
+	return evs
+}
+
+func TestResponsesStreamEvents(t *testing.T) {
+	c := NewOpenAIResponsesClient("", false)
+	var thinking string
+	var call *ToolCall
+	var usage *Usage
+	var stop string
+	for _, ev := range runResponsesSSE(c, responsesToolStream) {
+		switch ev.Type {
+		case EventThinkingDelta:
+			thinking += ev.Text
+		case EventToolCall:
+			call = ev.ToolCall
+		case EventUsage:
+			usage = ev.Usage
+		case EventStop:
+			stop = ev.StopReason
+		}
+	}
+	if thinking != "Need the file\n\nthen answer" {
+		t.Errorf("thinking = %q", thinking)
+	}
+	if call == nil || call.ID != "call_1" || call.Name != "read" || string(call.Input) != `{"path":"a.txt"}` {
+		t.Fatalf("tool call = %+v", call)
+	}
+	if usage == nil || usage.InputTokens != 900 || usage.CacheReadTokens != 512 || usage.OutputTokens != 300 || usage.ReasoningTokens != 256 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if stop != "tool_use" {
+		t.Fatalf("stop = %q", stop)
+	}
+}
+
+// toolLoopHistory is the runner's history after the call above was executed.
+var toolLoopHistory = []ChatMessage{
+	{Role: "user", Content: json.RawMessage(`"summarise a.txt"`)},
+	{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_use","id":"call_1","name":"read","input":{"path":"a.txt"}}]`)},
+	{Role: "user", Content: json.RawMessage(`[{"type":"tool_result","tool_use_id":"call_1","content":"hello"}]`)},
+}
+
+func TestResponsesBodyReplaysReasoning(t *testing.T) {
+	c := NewOpenAIResponsesClient("", false)
+	runResponsesSSE(c, responsesToolStream)
+	body := decodeBody(t)(c.buildRequest(&ChatRequest{
+		Model:          "openai/o4-mini",
+		System:         "be brief",
+		Messages:       toolLoopHistory,
+		Tools:          []ToolDef{{Name: "read", InputSchema: json.RawMessage(`{"type":"object"}`)}},
+		Temperature:    f64(0.3),
+		ThinkingBudget: 10000,
+	}))
+	if body["model"] != "o4-mini" || body["instructions"] != "be brief" || body["store"] != false {
+		t.Fatalf("model/instructions/store = %v %v %v", body["model"], body["instructions"], body["store"])
+	}
+	if _, ok := body["temperature"]; ok {
+		t.Error("reasoning models must not get temperature")
+	}
+	if r := body["reasoning"].(map[string]any); r["effort"] != "high" || r["summary"] != "auto" {
+		t.Errorf("reasoning = %v", r)
+	}
+	if inc := body["include"].([]any); len(inc) != 1 || inc[0] != "reasoning.encrypted_content" {
+		t.Errorf("include = %v", inc)
+	}
+	tool := body["tools"].([]any)[0].(map[string]any)
+	if tool["type"] != "function" || tool["name"] != "read" {
+		t.Errorf("tool = %v", tool)
+	}
+	var types []string
+	for _, it := range body["input"].([]any) {
+		m := it.(map[string]any)
+		typ, _ := m["type"].(string)
+		if typ == "" {
+			typ = "message:" + m["role"].(string)
+		}
+		types = append(types, typ)
+		if typ == "reasoning" && m["encrypted_content"] != "ENC" {
+			t.Errorf("replayed reasoning = %v", m)
+		}
+		if typ == "function_call_output" && (m["call_id"] != "call_1" || m["output"] != "hello") {
+			t.Errorf("output = %v", m)
+		}
+	}
+	if got := strings.Join(types, ","); got != "message:user,reasoning,function_call,function_call_output" {
+		t.Fatalf("input items = %s", got)
+	}
+}
+
+func TestResponsesChainUsesPreviousResponseID(t *testing.T) {
+	c := NewOpenAIResponsesClient("", true)
+	runResponsesSSE(c, responsesToolStream)
+	body := decodeBody(t)(c.buildRequest(&ChatRequest{Model: "o4-mini", Messages: toolLoopHistory}))
+	if body["previous_response_id"] != "resp_1" || body["store"] != true {
+		t.Fatalf("previous_response_id/store = %v %v", body["previous_response_id"], body["store"])
+	}
+	if _, ok := body["include"]; ok {
+		t.Error("chained requests need no encrypted reasoning")
+	}
+	input := body["input"].([]any)
+	if len(input) != 1 || input[0].(map[string]any)["type"] != "function_call_output" {
+		t.Fatalf("input = %v", input)
+	}
+	// A fresh turn (history ends with a plain user message after text) sends everything.
+	body = decodeBody(t)(c.buildRequest(&ChatRequest{Model: "o4-mini", Messages: toolLoopHistory[:1]}))
+	if _, ok := body["previous_response_id"]; ok {
+		t.Fatal("no continuation expected without a remembered tool call")
+	}
+}
+
+func TestResponsesClientEndpoint(t *testing.T) {
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte(`data: {"type":"response.created","response":{"id":"resp_2"}}
+
+data: {"type":"response.output_text.delta","delta":"Hi"}
+
+data: {"type":"response.incomplete","response":{"id":"resp_2","status":"incomplete","incomplete_details":{"reason":"max_output_tokens"}}}
+
+`))
+	}))
+	defer srv.Close()
+	c := NewOpenAIResponsesClient(srv.URL+"/v1", false)
+	c.httpClient, c.clientErr = srv.Client(), nil
+
+	ch, err := c.Stream(context.Background(), &ChatRequest{Model: "gpt-5", Messages: toolLoopHistory[:1]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text, stop string
+	for ev := range ch {
+		switch ev.Type {
+		case EventTextDelta:
+			text += ev.Text
+		case EventStop:
+			stop = ev.StopReason
+		case EventError:
+			t.Fatal(ev.Err)
+		}
+	}
+	if path != "/v1/responses" || text != "Hi" || stop != "max_tokens" {
+		t.Fatalf("path=%q text=%q stop=%q", path, text, stop)
+	}
+}
+
+func TestNewClientWithOptionsSelectsResponses(t *testing.T) {
+	if _, ok := NewClientWithOptions("openai", "", ClientOptions{API: APIResponses}).(*OpenAIResponsesClient); !ok {
+		t.Error("openai + responses should use the Responses client")
+	}
+	if _, ok := NewClientWithOptions("deepseek", "", ClientOptions{API: APIResponses}).(*OpenAIResponsesClient); ok {
+		t.Error("responses is openai-only")
+	}
+}
@@ -3,7 +3,10 @@
 // 需要额外 header：HTTP-Referer（标识来源）和 X-Title（应用名称）。
 package llm
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
 const openrouterDefaultBase = "https://openrouter.ai/api/v1"
 
@@ -16,12 +19,30 @@ func NewOpenRouterClient(baseURL string) *OpenRouterClient {
 	if baseURL == "" {
 		baseURL = openrouterDefaultBase
 	}
-	return &OpenRouterClient{
+	c := &OpenRouterClient{
 		openAIBase: newOpenAIBase(baseURL, map[string]string{
 			"HTTP-Referer": "https://hive.zyling.ai",
 			"X-Title":      "ZyHive",
 		}),
 	}
+	c.buildBody = buildOpenRouterRequest
+	return c
+}
+
+// buildOpenRouterRequest is the OpenAI body plus usage accounting, so the
+// final chunk's usage carries OpenRouter's actual charge ("cost", USD) and
+// cached-token breakdown.
+func buildOpenRouterRequest(req *ChatRequest) ([]byte, error) {
+	body, err := buildOpenAIRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	var payload map[string]any
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	payload["usage"] = map[string]any{"include": true}
+	return json.Marshal(payload)
 }
 
 func (c *OpenRouterClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
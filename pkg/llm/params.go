@@ -0,0 +1,111 @@
+// pkg/llm/params.go — Sampling / structured-output / reasoning knobs.
+//
+// ChatRequest carries these provider-agnostically; each request builder maps
+// them onto its own wire format:
+//
+//	                 Anthropic              OpenAI-compat            Gemini
+//	Temperature/TopP temperature/top_p      temperature/top_p        generationConfig
+//	Stop             stop_sequences         stop                     stopSequences
+//	ResponseFormat   system instruction     response_format          responseMimeType + responseJsonSchema
+//	ToolChoice       tool_choice            tool_choice              toolConfig.functionCallingConfig
+//	ThinkingBudget   thinking.budget_tokens reasoning_effort (o*/gpt-5) thinkingConfig.thinkingBudget
+//
+// The OpenAI Responses API (openai_responses.go) uses the same mappings under
+// its own names: text.format, reasoning.effort, flat function tool_choice.
+//
+// Providers that only accept {"type":"json_object"} (DeepSeek, Moonshot, Zhipu,
+// MiniMax, Qwen) get json_schema downgraded via jsonObjectOnly, with the schema
+// moved into the system prompt. Callers that need guaranteed shape should
+// still validate — see agent.Pool.CallLLMOnceJSON.
+package llm
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ResponseFormat types.
+const (
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// ResponseFormat requests structured (JSON) output.
+type ResponseFormat struct {
+	Type   string          `json:"type"`             // "json_object" | "json_schema"
+	Name   string          `json:"name,omitempty"`   // schema name; defaults to "response"
+	Schema json.RawMessage `json:"schema,omitempty"` // JSON Schema, for "json_schema"
+	Strict bool            `json:"strict,omitempty"` // OpenAI strict mode
+}
+
+// ToolChoice types.
+const (
+	ToolChoiceAuto = "auto"
+	ToolChoiceAny  = "any" // must call some tool
+	ToolChoiceNone = "none"
+	ToolChoiceTool = "tool" // must call ToolChoice.Name
+)
+
+// ToolChoice constrains whether / which tool the model calls.
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+// jsonInstruction is the system-prompt fallback for providers without native
+// schema support.
+func jsonInstruction(rf *ResponseFormat) string {
+	if rf == nil {
+		return ""
+	}
+	if rf.Type == ResponseFormatJSONSchema && len(rf.Schema) > 0 {
+		return "Respond with a single JSON value that conforms to this JSON Schema, and nothing else (no prose, no code fences):\n" + string(rf.Schema)
+	}
+	return "Respond with a single valid JSON object and nothing else (no prose, no code fences)."
+}
+
+// appendSystem returns system with extra appended as a new paragraph.
+func appendSystem(system, extra string) string {
+	if system == "" {
+		return extra
+	}
+	return system + "\n\n" + extra
+}
+
+// jsonObjectOnly adapts req for providers that accept response_format
+// {"type":"json_object"} but not json_schema: the format is downgraded and
+// the schema goes into the system prompt. Returns req unchanged otherwise.
+func jsonObjectOnly(req *ChatRequest) *ChatRequest {
+	if req.ResponseFormat == nil || req.ResponseFormat.Type != ResponseFormatJSONSchema {
+		return req
+	}
+	r := *req
+	r.System = appendSystem(req.System, jsonInstruction(req.ResponseFormat))
+	r.ResponseFormat = &ResponseFormat{Type: ResponseFormatJSONObject}
+	return &r
+}
+
+// isOpenAIReasoningModel reports whether model (prefix already stripped) is
+// an OpenAI reasoning model: these take reasoning_effort and reject
+// temperature / top_p.
+func isOpenAIReasoningModel(model string) bool {
+	m := strings.ToLower(model)
+	for _, p := range []string{"o1", "o3", "o4", "gpt-5"} {
+		if strings.HasPrefix(m, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// reasoningEffort maps a thinking token budget onto OpenAI's coarse levels.
+func reasoningEffort(budget int) string {
+	switch {
+	case budget <= 2048:
+		return "low"
+	case budget <= 8192:
+		return "medium"
+	default:
+		return "high"
+	}
+}
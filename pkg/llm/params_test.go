@@ -0,0 +1,170 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func f64(v float64) *float64 { return &v }
+
+// decodeBody is used as decodeBody(t)(buildX(req)).
+func decodeBody(t *testing.T) func([]byte, error) map[string]any {
+	return func(b []byte, err error) map[string]any {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]any
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+}
+
+var paramsSchema = json.RawMessage(`{"type":"object","properties":{"ok":{"type":"boolean"}},"required":["ok"]}`)
+
+func TestOpenAIBodyMapsParams(t *testing.T) {
+	body := decodeBody(t)(buildOpenAIRequestBody(&ChatRequest{
+		Model:          "openai/gpt-4o",
+		Temperature:    f64(0.2),
+		TopP:           f64(0.9),
+		Stop:           []string{"END"},
+		ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONSchema, Schema: paramsSchema},
+		Tools:          []ToolDef{{Name: "read", InputSchema: json.RawMessage(`{"type":"object"}`)}},
+		ToolChoice:     &ToolChoice{Type: ToolChoiceTool, Name: "read"},
+	}))
+	if body["temperature"] != 0.2 || body["top_p"] != 0.9 || body["stop"].([]any)[0] != "END" {
+		t.Fatalf("sampling = %v %v %v", body["temperature"], body["top_p"], body["stop"])
+	}
+	rf := body["response_format"].(map[string]any)
+	js := rf["json_schema"].(map[string]any)
+	if rf["type"] != "json_schema" || js["name"] != "response" || js["schema"] == nil {
+		t.Fatalf("response_format = %v", rf)
+	}
+	tc := body["tool_choice"].(map[string]any)
+	if tc["function"].(map[string]any)["name"] != "read" {
+		t.Fatalf("tool_choice = %v", tc)
+	}
+
+	// Reasoning models take reasoning_effort and must not get temperature.
+	body = decodeBody(t)(buildOpenAIRequestBody(&ChatRequest{
+		Model: "openai/o3-mini", Temperature: f64(0.2), ThinkingBudget: 16000,
+	}))
+	if _, ok := body["temperature"]; ok || body["reasoning_effort"] != "high" {
+		t.Fatalf("reasoning body = %v", body)
+	}
+}
+
+func TestJSONObjectOnlyMovesSchemaToSystem(t *testing.T) {
+	req := &ChatRequest{System: "sys", ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONSchema, Schema: paramsSchema}}
+	got := jsonObjectOnly(req)
+	if got == req || got.ResponseFormat.Type != ResponseFormatJSONObject || !strings.Contains(got.System, `"required":["ok"]`) {
+		t.Fatalf("got %+v", got)
+	}
+	if req.ResponseFormat.Type != ResponseFormatJSONSchema || req.System != "sys" {
+		t.Fatal("original request mutated")
+	}
+}
+
+func TestQwenBodyThinking(t *testing.T) {
+	body := decodeBody(t)(buildQwenRequest(&ChatRequest{Model: "qwen/qwen3-max", ThinkingBudget: 2000}))
+	if body["enable_thinking"] != true || body["thinking_budget"] != float64(2000) {
+		t.Fatalf("qwen body = %v", body)
+	}
+}
+
+func TestAnthropicBodyThinking(t *testing.T) {
+	body := decodeBody(t)(buildAnthropicRequest(&ChatRequest{
+		Model:          "anthropic/claude-sonnet-4",
+		MaxTokens:      1000,
+		Temperature:    f64(0.3),
+		ThinkingBudget: 500,
+		Stop:           []string{"###"},
+		ToolChoice:     &ToolChoice{Type: ToolChoiceAny},
+		Tools:          []ToolDef{{Name: "read", InputSchema: json.RawMessage(`{"type":"object"}`)}},
+		ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONObject},
+	}))
+	th := body["thinking"].(map[string]any)
+	if th["budget_tokens"] != float64(1024) || body["max_tokens"].(float64) <= 1024 {
+		t.Fatalf("thinking = %v max_tokens = %v", th, body["max_tokens"])
+	}
+	if _, ok := body["temperature"]; ok {
+		t.Fatal("temperature must be dropped while thinking")
+	}
+	if body["tool_choice"].(map[string]any)["type"] != "auto" {
+		t.Fatalf("forced tool_choice should degrade to auto: %v", body["tool_choice"])
+	}
+	if body["stop_sequences"].([]any)[0] != "###" || !strings.Contains(string(mustJSON(body["system"])), "JSON") {
+		t.Fatalf("stop/system = %v %v", body["stop_sequences"], body["system"])
+	}
+}
+
+func TestAnthropicThinkingReplayedForToolUse(t *testing.T) {
+	sse := strings.Join([]string{
+		`data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+		`data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"sig"}}`,
+		`data: {"type":"content_block_stop","index":0}`,
+		`data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"tu_1","name":"read"}}`,
+		`data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{}"}}`,
+		`data: {"type":"content_block_stop","index":1}`,
+		`data: {"type":"message_stop"}`,
+	}, "\n\n") + "\n\n"
+
+	c := NewAnthropicClient("")
+	events := make(chan StreamEvent, 32)
+	go func() {
+		defer close(events)
+		parseAnthropicSSE(context.Background(), strings.NewReader(sse), events, c.rememberThinking)
+	}()
+	for range events {
+	}
+
+	msgs := []ChatMessage{
+		{Role: "user", Content: json.RawMessage(`"go"`)},
+		{Role: "assistant", Content: json.RawMessage(`[{"type":"tool_use","id":"tu_1","name":"read","input":{}}]`)},
+	}
+	out := c.restoreThinking(msgs)
+	var blocks []map[string]any
+	if err := json.Unmarshal(out[1].Content, &blocks); err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 2 || blocks[0]["type"] != "thinking" || blocks[0]["signature"] != "sig" || blocks[0]["thinking"] != "hmm" {
+		t.Fatalf("blocks = %v", blocks)
+	}
+	if string(msgs[1].Content) == string(out[1].Content) {
+		t.Fatal("input messages mutated")
+	}
+}
+
+func TestGeminiBodyMapsParams(t *testing.T) {
+	c := NewGeminiClient("")
+	body := decodeBody(t)(c.buildRequest(&ChatRequest{
+		Model:          "gemini/gemini-2.5-flash",
+		Messages:       []ChatMessage{{Role: "user", Content: json.RawMessage(`"hi"`)}},
+		TopP:           f64(0.5),
+		ThinkingBudget: 256,
+		ResponseFormat: &ResponseFormat{Type: ResponseFormatJSONSchema, Schema: paramsSchema},
+		Tools:          []ToolDef{{Name: "read", InputSchema: json.RawMessage(`{"type":"object"}`)}},
+		ToolChoice:     &ToolChoice{Type: ToolChoiceTool, Name: "read"},
+	}))
+	gc := body["generationConfig"].(map[string]any)
+	if gc["topP"] != 0.5 || gc["responseMimeType"] != "application/json" || gc["responseJsonSchema"] == nil {
+		t.Fatalf("generationConfig = %v", gc)
+	}
+	if gc["thinkingConfig"].(map[string]any)["thinkingBudget"] != float64(256) {
+		t.Fatalf("thinkingConfig = %v", gc["thinkingConfig"])
+	}
+	fc := body["toolConfig"].(map[string]any)["functionCallingConfig"].(map[string]any)
+	if fc["mode"] != "ANY" || fc["allowedFunctionNames"].([]any)[0] != "read" {
+		t.Fatalf("functionCallingConfig = %v", fc)
+	}
+}
+
+func mustJSON(v any) []byte {
+	b, _ := json.Marshal(v)
+	return b
+}
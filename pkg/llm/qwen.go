@@ -21,16 +21,17 @@ func NewQwenClient(baseURL string) *QwenClient {
 		baseURL = qwenDefaultBase
 	}
 	c := &QwenClient{openAIBase: newOpenAIBase(baseURL, nil)}
+	c.buildBody = buildQwenRequest
 	return c
 }
 
 func (c *QwenClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
-	// DashScope compatible 模式与标准 OpenAI 完全兼容，直接复用
-	return c.stream(ctx, req)
+	// DashScope compatible 模式只支持 json_object，json_schema 降级为 system 指令
+	return c.stream(ctx, jsonObjectOnly(req))
 }
 
-// buildQwenRequest 在标准 OpenAI 请求基础上添加 DashScope 特有参数。
-// 目前 DashScope compatible 模式完全兼容标准格式，无需额外处理。
+// buildQwenRequest 在标准 OpenAI 请求基础上添加 DashScope 特有参数：
+// ThinkingBudget → enable_thinking + thinking_budget（qwen3 / qwen-plus 等混合思考模型）。
 func buildQwenRequest(req *ChatRequest) ([]byte, error) {
 	payload, err := buildOpenAIRequestBody(req)
 	if err != nil {
@@ -49,6 +50,10 @@ func buildQwenRequest(req *ChatRequest) ([]byte, error) {
 			m["model"] = model[idx+1:]
 		}
 	}
+	if req.ThinkingBudget > 0 {
+		m["enable_thinking"] = true
+		m["thinking_budget"] = req.ThinkingBudget
+	}
 
 	return json.Marshal(m)
 }
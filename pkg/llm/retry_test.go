@@ -19,6 +19,7 @@ func TestIsTransientMatches(t *testing.T) {
 		{"502", errors.New("bad gateway 502"), true},
 		{"503 str", errors.New("service unavailable: 503"), true},
 		{"504", errors.New("504 gateway timeout"), true},
+		{"529 overloaded", errors.New(`529 {"type":"overloaded_error","message":"Overloaded"}`), true},
 		{"connection reset", errors.New("read tcp: connection reset by peer"), true},
 		{"connection refused", errors.New("dial tcp: connection refused"), true},
 		{"eof mid-stream", errors.New("unexpected EOF"), true},
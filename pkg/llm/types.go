@@ -22,6 +22,18 @@ type ChatRequest struct {
 	CacheRetention string `json:"-"` // "none" | "short" | "long"
 	// Extra beta headers
 	BetaHeaders []string `json:"-"`
+
+	// Sampling. nil / empty = provider default.
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+	// ResponseFormat asks for JSON output; nil = free text.
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// ToolChoice constrains tool use; nil = auto.
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+	// ThinkingBudget > 0 turns on extended thinking / reasoning with roughly
+	// this many tokens (mapped to each provider's own knob, see params.go).
+	ThinkingBudget int `json:"thinking_budget,omitempty"`
 }
 
 // ChatMessage is one turn in the conversation history.
@@ -66,6 +78,9 @@ const (
 	EventUsage         StreamEventType = "usage"
 	EventStop          StreamEventType = "stop"
 	EventError         StreamEventType = "error"
+	// EventModelSwitch is emitted by FailoverClient before the first event of
+	// a fallback model's stream.
+	EventModelSwitch StreamEventType = "model_switch"
 )
 
 // StreamEvent is one item emitted by the streaming LLM response.
@@ -82,14 +97,24 @@ type StreamEvent struct {
 	StopReason string `json:"stop_reason,omitempty"`
 	// error
 	Err error `json:"-"`
+	// model_switch
+	Switch *ModelSwitch `json:"switch,omitempty"`
 }
 
 // Usage holds token counts for a single API call.
+//
+// InputTokens is the whole prompt, cached parts included; CacheReadTokens /
+// CacheWriteTokens are the subsets served from / written to the provider's
+// prompt cache. ReasoningTokens is the hidden-reasoning subset of
+// OutputTokens. Cost is the provider-reported charge in USD when the
+// provider returns one (OpenRouter), else 0.
 type Usage struct {
-	InputTokens      int `json:"input_tokens"`
-	OutputTokens     int `json:"output_tokens"`
-	CacheReadTokens  int `json:"cache_read_tokens"`
-	CacheWriteTokens int `json:"cache_write_tokens"`
+	InputTokens      int     `json:"input_tokens"`
+	OutputTokens     int     `json:"output_tokens"`
+	CacheReadTokens  int     `json:"cache_read_tokens"`
+	CacheWriteTokens int     `json:"cache_write_tokens"`
+	ReasoningTokens  int     `json:"reasoning_tokens,omitempty"`
+	Cost             float64 `json:"cost,omitempty"`
 }
 
 // ---- Client interface -----------------------------------------------------
@@ -101,6 +126,24 @@ type Client interface {
 	Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error)
 }
 
+// APIResponses selects the OpenAI Responses API (ClientOptions.API).
+const APIResponses = "responses"
+
+// ClientOptions are per-model client choices on top of the provider type.
+type ClientOptions struct {
+	API            string // "" / "chat" = Chat Completions; APIResponses (openai only)
+	ChainResponses bool   // Responses API: continue tool loops via previous_response_id
+}
+
+// NewClientWithOptions is NewClient plus per-model options; unknown or
+// inapplicable options fall back to NewClient.
+func NewClientWithOptions(provider, baseURL string, opts ClientOptions) Client {
+	if strings.EqualFold(provider, "openai") && opts.API == APIResponses {
+		return NewOpenAIResponsesClient(baseURL, opts.ChainResponses)
+	}
+	return NewClient(provider, baseURL)
+}
+
 // NewClient 根据 provider 返回对应的专用客户端。
 // baseURL 为空时使用各 provider 默认地址。
 func NewClient(provider, baseURL string) Client {
@@ -123,6 +166,8 @@ func NewClient(provider, baseURL string) Client {
 		return NewOpenRouterClient(baseURL)
 	case "ollama":
 		return NewOllamaClient(baseURL)
+	case "gemini", "google":
+		return NewGeminiClient(baseURL)
 	default:
 		// 自定义或未知 provider → 通用 OpenAI-compatible 客户端
 		return NewCustomClient(baseURL)
@@ -0,0 +1,74 @@
+package llm
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// collectUsage sums the EventUsage events a parser emits.
+func collectUsage(parse func(chan<- StreamEvent)) Usage {
+	ch := make(chan StreamEvent, 64)
+	go func() {
+		parse(ch)
+		close(ch)
+	}()
+	var u Usage
+	for ev := range ch {
+		if ev.Type == EventUsage && ev.Usage != nil {
+			u.InputTokens += ev.Usage.InputTokens
+			u.OutputTokens += ev.Usage.OutputTokens
+			u.CacheReadTokens += ev.Usage.CacheReadTokens
+			u.CacheWriteTokens += ev.Usage.CacheWriteTokens
+			u.Cost += ev.Usage.Cost
+		}
+	}
+	return u
+}
+
+func TestAnthropicUsageIncludesCacheTokens(t *testing.T) {
+	sse := `event: message_start
+data: {"type":"message_start","message":{"usage":{"input_tokens":20,"cache_creation_input_tokens":1000,"cache_read_input_tokens":3000}}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":50}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+	u := collectUsage(func(ch chan<- StreamEvent) {
+		parseAnthropicSSE(context.Background(), strings.NewReader(sse), ch, nil)
+	})
+	if u.InputTokens != 4020 || u.CacheReadTokens != 3000 || u.CacheWriteTokens != 1000 || u.OutputTokens != 50 {
+		t.Fatalf("usage = %+v", u)
+	}
+}
+
+func TestOpenAIUsageCachedTokensAndCost(t *testing.T) {
+	cases := map[string]struct {
+		usage    string
+		cacheHit int
+		cost     float64
+	}{
+		"openai":     {`{"prompt_tokens":2000,"completion_tokens":10,"prompt_tokens_details":{"cached_tokens":1536}}`, 1536, 0},
+		"deepseek":   {`{"prompt_tokens":2000,"completion_tokens":10,"prompt_cache_hit_tokens":1024,"prompt_cache_miss_tokens":976}`, 1024, 0},
+		"openrouter": {`{"prompt_tokens":2000,"completion_tokens":10,"prompt_tokens_details":{"cached_tokens":512},"cost":0.0031}`, 512, 0.0031},
+	}
+	for name, tc := range cases {
+		sse := "data: {\"choices\":[],\"usage\":" + tc.usage + "}\n\ndata: [DONE]\n\n"
+		u := collectUsage(func(ch chan<- StreamEvent) {
+			defaultParseOpenAISSE(context.Background(), strings.NewReader(sse), ch)
+		})
+		if u.InputTokens != 2000 || u.OutputTokens != 10 || u.CacheReadTokens != tc.cacheHit || u.Cost != tc.cost {
+			t.Errorf("%s: usage = %+v", name, u)
+		}
+	}
+}
+
+func TestOpenRouterBodyRequestsUsageAccounting(t *testing.T) {
+	body := decodeBody(t)(buildOpenRouterRequest(&ChatRequest{Model: "openrouter/anthropic/claude-sonnet-4"}))
+	if inc, _ := body["usage"].(map[string]any); inc["include"] != true {
+		t.Fatalf("usage = %v", body["usage"])
+	}
+}
@@ -20,5 +20,5 @@ func NewZhipuClient(baseURL string) *ZhipuClient {
 }
 
 func (c *ZhipuClient) Stream(ctx context.Context, req *ChatRequest) (<-chan StreamEvent, error) {
-	return c.stream(ctx, req)
+	return c.stream(ctx, jsonObjectOnly(req))
 }
@@ -0,0 +1,156 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+)
+
+// transport is the wire layer shared by stdio and streamable HTTP.
+type transport interface {
+	call(ctx context.Context, method string, params any) (json.RawMessage, error)
+	notify(ctx context.Context, method string, params any) error
+	alive() bool
+	close() error
+}
+
+// Client is an initialized session with one MCP server.
+type Client struct {
+	t            transport
+	Server       ServerInfo
+	Instructions string
+}
+
+// Connect opens the transport described by entry and performs the
+// initialize handshake. The caller owns the returned client and must Close it.
+func Connect(ctx context.Context, entry config.MCPServerEntry) (*Client, error) {
+	var (
+		t   transport
+		err error
+	)
+	switch entry.Transport {
+	case "", "stdio":
+		t, err = newStdioTransport(entry.Command, entry.Args, entry.Env)
+	case "http", "streamable-http":
+		t, err = newHTTPTransport(entry.URL, entry.Headers)
+	default:
+		return nil, fmt.Errorf("mcp: unknown transport %q", entry.Transport)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{t: t}
+	if err := c.initialize(ctx); err != nil {
+		_ = t.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	raw, err := c.t.call(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "zyhive", "version": "1"},
+	})
+	if err != nil {
+		return fmt.Errorf("mcp: initialize: %w", err)
+	}
+	var res initializeResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return fmt.Errorf("mcp: decode initialize result: %w", err)
+	}
+	c.Server = res.ServerInfo
+	c.Instructions = res.Instructions
+	return c.t.notify(ctx, "notifications/initialized", nil)
+}
+
+// ListTools returns every tool the server offers, following pagination.
+func (c *Client) ListTools(ctx context.Context) ([]Tool, error) {
+	var (
+		all    []Tool
+		cursor string
+	)
+	for page := 0; page < 100; page++ {
+		var params any
+		if cursor != "" {
+			params = map[string]string{"cursor": cursor}
+		}
+		raw, err := c.t.call(ctx, "tools/list", params)
+		if err != nil {
+			return nil, fmt.Errorf("mcp: tools/list: %w", err)
+		}
+		var res listToolsResult
+		if err := json.Unmarshal(raw, &res); err != nil {
+			return nil, fmt.Errorf("mcp: decode tools/list: %w", err)
+		}
+		all = append(all, res.Tools...)
+		if res.NextCursor == "" {
+			break
+		}
+		cursor = res.NextCursor
+	}
+	return all, nil
+}
+
+// CallTool invokes tools/call. A tool-level failure (isError=true) is
+// returned as a result, not as a Go error; protocol failures are errors.
+func (c *Client) CallTool(ctx context.Context, name string, args json.RawMessage) (*CallResult, error) {
+	if len(args) == 0 || string(args) == "null" {
+		args = json.RawMessage(`{}`)
+	}
+	raw, err := c.t.call(ctx, "tools/call", map[string]any{
+		"name":      name,
+		"arguments": args,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var res CallResult
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, fmt.Errorf("mcp: decode tools/call: %w", err)
+	}
+	return &res, nil
+}
+
+// alive reports whether the underlying transport can still carry requests.
+func (c *Client) alive() bool {
+	return c.t.alive()
+}
+
+// Close ends the session and releases the transport (kills stdio servers).
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+// Text flattens a tool result into the plain string handed back to the LLM.
+// Binary blocks are summarised rather than inlined.
+func (r *CallResult) Text() string {
+	var parts []string
+	for _, block := range r.Content {
+		switch block.Type {
+		case "text":
+			parts = append(parts, block.Text)
+		case "image", "audio":
+			parts = append(parts, fmt.Sprintf("[%s: %s, %d bytes base64]", block.Type, block.MimeType, len(block.Data)))
+		case "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource: %s %s]", block.Name, block.URI))
+		case "resource":
+			if block.Resource == nil {
+				continue
+			}
+			if block.Resource.Text != "" {
+				parts = append(parts, block.Resource.Text)
+			} else {
+				parts = append(parts, fmt.Sprintf("[resource: %s]", block.Resource.URI))
+			}
+		}
+	}
+	if len(parts) == 0 && len(r.StructuredContent) > 0 {
+		return string(r.StructuredContent)
+	}
+	return strings.Join(parts, "\n")
+}
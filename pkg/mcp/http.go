@@ -0,0 +1,246 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+)
+
+// maxHTTPResponse caps a single JSON (non-SSE) response body.
+const maxHTTPResponse = 16 << 20
+
+// httpTransport implements the MCP "streamable HTTP" transport: every
+// message is a POST; the server answers with either application/json or a
+// text/event-stream carrying the response (plus optional notifications).
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+	nextID  atomic.Int64
+
+	mu        sync.Mutex
+	sessionID string // Mcp-Session-Id assigned by the server at initialize
+}
+
+// newHTTPTransport builds the transport with a netguard client. Loopback
+// endpoints (a locally run MCP server) are allowed only for their exact
+// origin; everything else must resolve to a public address.
+func newHTTPTransport(rawURL string, headers map[string]string) (*httpTransport, error) {
+	if rawURL == "" {
+		return nil, fmt.Errorf("mcp: http server requires a url")
+	}
+	client, err := netguard.NewExactLoopbackClient(0, rawURL)
+	if err != nil {
+		client = netguard.NewSafeClient(0)
+	}
+	return &httpTransport{url: rawURL, headers: headers, client: client}, nil
+}
+
+func (t *httpTransport) newRequest(ctx context.Context, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	req.Header.Set("MCP-Protocol-Version", ProtocolVersion)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	t.mu.Lock()
+	if t.sessionID != "" {
+		req.Header.Set("Mcp-Session-Id", t.sessionID)
+	}
+	t.mu.Unlock()
+	return req, nil
+}
+
+func (t *httpTransport) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	raw, err := marshalParams(params)
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: raw})
+	if err != nil {
+		return nil, err
+	}
+	req, err := t.newRequest(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("mcp: %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if sid := resp.Header.Get("Mcp-Session-Id"); sid != "" {
+		t.mu.Lock()
+		t.sessionID = sid
+		t.mu.Unlock()
+	}
+	if resp.StatusCode != http.StatusOK {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("mcp: %s: HTTP %d: %s", method, resp.StatusCode, strings.TrimSpace(string(snippet)))
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	var msg *rpcMessage
+	if mediaType == "text/event-stream" {
+		msg, err = readSSEResponse(resp.Body, id)
+	} else {
+		msg, err = readJSONResponse(resp.Body, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp: %s: %w", method, err)
+	}
+	if msg.Error != nil {
+		return nil, msg.Error
+	}
+	return msg.Result, nil
+}
+
+func (t *httpTransport) notify(ctx context.Context, method string, params any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: raw})
+	if err != nil {
+		return err
+	}
+	req, err := t.newRequest(ctx, body)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("mcp: %s: %w", method, err)
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("mcp: %s: HTTP %d", method, resp.StatusCode)
+	}
+	return nil
+}
+
+// alive is always true: every request is an independent POST, so a server
+// restart surfaces as a per-call error rather than a dead transport.
+func (t *httpTransport) alive() bool { return true }
+
+// close terminates the server-side session (best effort).
+func (t *httpTransport) close() error {
+	t.mu.Lock()
+	sid := t.sessionID
+	t.mu.Unlock()
+	if sid == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Mcp-Session-Id", sid)
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// readJSONResponse decodes a single JSON-RPC response (or a batch containing it).
+func readJSONResponse(r io.Reader, id int64) (*rpcMessage, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxHTTPResponse))
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '[' {
+		var batch []rpcMessage
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, fmt.Errorf("decode response batch: %w", err)
+		}
+		for i := range batch {
+			if matchesID(&batch[i], id) {
+				return &batch[i], nil
+			}
+		}
+		return nil, fmt.Errorf("response for id %d missing from batch", id)
+	}
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return &msg, nil
+}
+
+// readSSEResponse scans an event stream until the response for id arrives.
+// Interleaved notifications and server requests are skipped.
+func readSSEResponse(r io.Reader, id int64) (*rpcMessage, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStdioLine)
+	var data strings.Builder
+	flush := func() *rpcMessage {
+		defer data.Reset()
+		if data.Len() == 0 {
+			return nil
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal([]byte(data.String()), &msg); err != nil {
+			return nil
+		}
+		if matchesID(&msg, id) {
+			return &msg
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if msg := flush(); msg != nil {
+				return msg, nil
+			}
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "data:"); ok {
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(strings.TrimPrefix(v, " "))
+		}
+	}
+	if msg := flush(); msg != nil {
+		return msg, nil
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("event stream ended before response for id %d", id)
+}
+
+func matchesID(msg *rpcMessage, id int64) bool {
+	if !msg.isResponse() {
+		return false
+	}
+	got, err := strconv.ParseInt(string(msg.ID), 10, 64)
+	return err == nil && got == id
+}
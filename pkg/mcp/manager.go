@@ -0,0 +1,353 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+)
+
+// connectTimeout bounds initialize + tools/list for one server at startup.
+const connectTimeout = 30 * time.Second
+
+// reconnectBackoff is the minimum gap between reconnect attempts for a
+// server that failed or exited.
+const reconnectBackoff = 30 * time.Second
+
+// maxToolNameLen is the provider limit for function names (Anthropic and
+// OpenAI both cap at 64 characters of [a-zA-Z0-9_-]).
+const maxToolNameLen = 64
+
+// MountedTool is one remote tool as exposed to agents.
+type MountedTool struct {
+	Name     string // namespaced tool name, see ToolName
+	ServerID string
+	Tool     Tool
+}
+
+// ServerStatus is a snapshot of one configured server for diagnostics.
+type ServerStatus struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Transport string `json:"transport"`
+	Connected bool   `json:"connected"`
+	Tools     int    `json:"tools"`
+	Error     string `json:"error,omitempty"`
+}
+
+type serverState struct {
+	connMu      sync.Mutex // serialises reconnect attempts
+	entry       config.MCPServerEntry
+	client      *Client
+	tools       []MountedTool
+	err         error
+	lastAttempt time.Time
+}
+
+// Manager owns the connections to every configured MCP server. Tool lists
+// are discovered once at Start (and on reconnect) and cached; agents read the
+// cache when their tool registry is built.
+type Manager struct {
+	mu      sync.RWMutex
+	servers map[string]*serverState
+	order   []string
+	gen     map[string]uint64 // bumped per id by Restart/Remove; stale connects are dropped
+}
+
+// NewManager creates an empty manager. Call Start to connect servers.
+func NewManager() *Manager {
+	return &Manager{servers: make(map[string]*serverState), gen: make(map[string]uint64)}
+}
+
+// Start connects every enabled entry concurrently and discovers its tools.
+// Failures are logged and kept in Status; they never abort startup.
+// Calling Start again replaces the previous server set (closing old clients).
+func (m *Manager) Start(ctx context.Context, entries []config.MCPServerEntry) {
+	next := make(map[string]*serverState, len(entries))
+	var order []string
+	for _, e := range entries {
+		if !e.Enabled || e.ID == "" {
+			continue
+		}
+		if _, dup := next[e.ID]; dup {
+			log.Printf("[mcp] ignoring duplicate server id %q", e.ID)
+			continue
+		}
+		next[e.ID] = &serverState{entry: e}
+		order = append(order, e.ID)
+	}
+
+	var wg sync.WaitGroup
+	for _, st := range next {
+		wg.Add(1)
+		go func(st *serverState) {
+			defer wg.Done()
+			st.lastAttempt = time.Now()
+			st.client, st.tools, st.err = connect(ctx, st.entry)
+		}(st)
+	}
+	wg.Wait()
+
+	m.mu.Lock()
+	old := m.servers
+	m.servers = next
+	m.order = order
+	for id := range m.gen {
+		m.gen[id]++
+	}
+	m.mu.Unlock()
+	for _, st := range old {
+		if st.client != nil {
+			_ = st.client.Close()
+		}
+	}
+}
+
+// Restart reconnects a single server after its config entry changed and
+// swaps it in, leaving every other server connected. The old client keeps
+// serving calls until the new one is ready. A disabled entry is removed.
+// When Restart or Remove is called again for the same id before this
+// connect finishes, the newer call wins and this result is discarded.
+func (m *Manager) Restart(ctx context.Context, entry config.MCPServerEntry) {
+	if !entry.Enabled {
+		m.Remove(entry.ID)
+		return
+	}
+	m.mu.Lock()
+	m.gen[entry.ID]++
+	gen := m.gen[entry.ID]
+	m.mu.Unlock()
+
+	st := &serverState{entry: entry, lastAttempt: time.Now()}
+	st.client, st.tools, st.err = connect(ctx, entry)
+
+	m.mu.Lock()
+	if m.gen[entry.ID] != gen {
+		m.mu.Unlock()
+		if st.client != nil {
+			_ = st.client.Close()
+		}
+		return
+	}
+	old, existed := m.servers[entry.ID]
+	m.servers[entry.ID] = st
+	if !existed {
+		m.order = append(m.order, entry.ID)
+	}
+	m.mu.Unlock()
+	if old != nil && old.client != nil {
+		_ = old.client.Close()
+	}
+}
+
+// Remove disconnects one server and forgets it.
+func (m *Manager) Remove(serverID string) {
+	m.mu.Lock()
+	m.gen[serverID]++
+	st, ok := m.servers[serverID]
+	if ok {
+		delete(m.servers, serverID)
+		for i, id := range m.order {
+			if id == serverID {
+				m.order = append(m.order[:i:i], m.order[i+1:]...)
+				break
+			}
+		}
+	}
+	m.mu.Unlock()
+	if st != nil && st.client != nil {
+		_ = st.client.Close()
+	}
+}
+
+// connect establishes a session with entry and discovers its tools.
+func connect(ctx context.Context, entry config.MCPServerEntry) (*Client, []MountedTool, error) {
+	ctx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+	client, err := Connect(ctx, entry)
+	if err != nil {
+		log.Printf("[mcp] server %s: connect failed: %v", entry.ID, err)
+		return nil, nil, err
+	}
+	tools, err := client.ListTools(ctx)
+	if err != nil {
+		_ = client.Close()
+		log.Printf("[mcp] server %s: tools/list failed: %v", entry.ID, err)
+		return nil, nil, err
+	}
+	mounted := make([]MountedTool, 0, len(tools))
+	for _, t := range tools {
+		mounted = append(mounted, MountedTool{Name: ToolName(entry.ID, t.Name), ServerID: entry.ID, Tool: t})
+	}
+	log.Printf("[mcp] server %s (%s): %d tools", entry.ID, client.Server.Name, len(mounted))
+	return client, mounted, nil
+}
+
+// Tools returns the cached tools of the selected servers. serverIDs
+// containing "*" selects every connected server; an empty selection
+// returns nothing (agents opt in explicitly).
+func (m *Manager) Tools(serverIDs []string) []MountedTool {
+	if len(serverIDs) == 0 {
+		return nil
+	}
+	all := false
+	want := make(map[string]bool, len(serverIDs))
+	for _, id := range serverIDs {
+		if id == "*" {
+			all = true
+		}
+		want[id] = true
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var out []MountedTool
+	for _, id := range m.order {
+		if all || want[id] {
+			out = append(out, m.servers[id].tools...)
+		}
+	}
+	return out
+}
+
+// Call invokes tool on serverID and flattens the result to text. A result
+// with isError=true is returned as an error so the runner reports it to the
+// model as a failed tool call.
+func (m *Manager) Call(ctx context.Context, serverID, tool string, args json.RawMessage) (string, error) {
+	client, err := m.client(ctx, serverID)
+	if err != nil {
+		return "", err
+	}
+	res, err := client.CallTool(ctx, tool, args)
+	if err != nil {
+		return "", err
+	}
+	text := res.Text()
+	if res.IsError {
+		if text == "" {
+			text = "tool reported an error"
+		}
+		return "", errors.New(text)
+	}
+	return text, nil
+}
+
+// client returns a live client, reconnecting a dead server at most once per
+// reconnectBackoff.
+func (m *Manager) client(ctx context.Context, serverID string) (*Client, error) {
+	m.mu.RLock()
+	st, ok := m.servers[serverID]
+	var client *Client
+	if ok {
+		client = st.client
+	}
+	m.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("mcp server %q is not configured or disabled", serverID)
+	}
+	if client != nil && client.alive() {
+		return client, nil
+	}
+
+	st.connMu.Lock()
+	defer st.connMu.Unlock()
+	m.mu.RLock()
+	client, lastAttempt, lastErr := st.client, st.lastAttempt, st.err
+	m.mu.RUnlock()
+	if client != nil && client.alive() {
+		return client, nil
+	}
+	if time.Since(lastAttempt) < reconnectBackoff {
+		if lastErr == nil {
+			lastErr = errors.New("server exited")
+		}
+		return nil, fmt.Errorf("mcp server %q unavailable: %w", serverID, lastErr)
+	}
+	if client != nil {
+		_ = client.Close()
+	}
+	fresh, tools, err := connect(ctx, st.entry)
+	m.mu.Lock()
+	st.lastAttempt = time.Now()
+	st.client, st.err = fresh, err
+	if err == nil {
+		st.tools = tools
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %q unavailable: %w", serverID, err)
+	}
+	return fresh, nil
+}
+
+// Status reports every configured server in config order.
+func (m *Manager) Status() []ServerStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	out := make([]ServerStatus, 0, len(m.order))
+	for _, id := range m.order {
+		st := m.servers[id]
+		s := ServerStatus{
+			ID:        id,
+			Name:      st.entry.Name,
+			Transport: st.entry.Transport,
+			Connected: st.client != nil && st.client.alive(),
+			Tools:     len(st.tools),
+		}
+		if st.err != nil {
+			s.Error = st.err.Error()
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// Close disconnects every server.
+func (m *Manager) Close() {
+	m.mu.Lock()
+	servers := m.servers
+	m.servers = make(map[string]*serverState)
+	m.order = nil
+	m.mu.Unlock()
+	for _, st := range servers {
+		if st.client != nil {
+			_ = st.client.Close()
+		}
+	}
+}
+
+// ToolName builds the namespaced name mcp__<server>__<tool>.
+//
+// The "mcp:server:tool" spelling reads better but both Anthropic and OpenAI
+// reject ':' in function names (^[a-zA-Z0-9_-]{1,64}$), so double
+// underscores are used as the separator and other characters are mapped to
+// '_'. Names longer than 64 characters keep a hash suffix to stay unique.
+func ToolName(serverID, tool string) string {
+	name := "mcp__" + sanitizeName(serverID) + "__" + sanitizeName(tool)
+	if len(name) <= maxToolNameLen {
+		return name
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(serverID + "\x00" + tool))
+	suffix := fmt.Sprintf("_%08x", h.Sum32())
+	return name[:maxToolNameLen-len(suffix)] + suffix
+}
+
+func sanitizeName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,233 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+)
+
+// TestMain doubles as a tiny stdio MCP server: when MCP_TEST_SERVER=1 the
+// test binary re-executed by stdioEntry serves JSON-RPC on stdin/stdout.
+func TestMain(m *testing.M) {
+	if os.Getenv("MCP_TEST_SERVER") == "1" {
+		serveTestStdio()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func serveTestStdio() {
+	scanner := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	fmt.Println("server booting (non-JSON noise on stdout must be ignored)")
+	for scanner.Scan() {
+		var req rpcMessage
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || len(req.ID) == 0 {
+			continue // notifications
+		}
+		result, rpcErr := handleTestRequest(req.Method, req.Params)
+		reply := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		if rpcErr != nil {
+			reply["error"] = rpcErr
+		} else {
+			reply["result"] = result
+		}
+		_ = out.Encode(reply)
+	}
+}
+
+// handleTestRequest implements initialize, tools/list and tools/call for a
+// server exposing "echo" and "fail".
+func handleTestRequest(method string, params json.RawMessage) (any, *RPCError) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]string{"name": "test-server", "version": "0.1"},
+		}, nil
+	case "tools/list":
+		return map[string]any{"tools": []map[string]any{
+			{"name": "echo", "description": "echo text", "inputSchema": json.RawMessage(`{"type":"object","properties":{"text":{"type":"string"}}}`)},
+			{"name": "fail", "description": "always fails", "inputSchema": json.RawMessage(`{"type":"object"}`)},
+		}}, nil
+	case "tools/call":
+		var p struct {
+			Name      string `json:"name"`
+			Arguments struct {
+				Text string `json:"text"`
+			} `json:"arguments"`
+		}
+		_ = json.Unmarshal(params, &p)
+		switch p.Name {
+		case "echo":
+			return map[string]any{"content": []map[string]string{{"type": "text", "text": "echo: " + p.Arguments.Text}}}, nil
+		case "fail":
+			return map[string]any{"isError": true, "content": []map[string]string{{"type": "text", "text": "boom"}}}, nil
+		}
+		return nil, &RPCError{Code: -32602, Message: "unknown tool " + p.Name}
+	}
+	return nil, &RPCError{Code: -32601, Message: "method not found"}
+}
+
+func stdioEntry(t *testing.T, id string) config.MCPServerEntry {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return config.MCPServerEntry{
+		ID:        id,
+		Transport: "stdio",
+		Command:   exe,
+		Args:      []string{"-test.run=^$"},
+		Env:       []string{"MCP_TEST_SERVER=1"},
+		Enabled:   true,
+	}
+}
+
+func TestManagerDiscoversAndCallsStdioTools(t *testing.T) {
+	mgr := NewManager()
+	defer mgr.Close()
+	mgr.Start(context.Background(), []config.MCPServerEntry{
+		stdioEntry(t, "local"),
+		{ID: "off", Transport: "stdio", Command: "does-not-exist", Enabled: false},
+	})
+
+	status := mgr.Status()
+	if len(status) != 1 || !status[0].Connected || status[0].Tools != 2 {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if got := mgr.Tools(nil); len(got) != 0 {
+		t.Fatalf("empty selection must mount nothing, got %d tools", len(got))
+	}
+	tools := mgr.Tools([]string{"*"})
+	if len(tools) != 2 || tools[0].Name != "mcp__local__echo" {
+		t.Fatalf("unexpected tools: %+v", tools)
+	}
+
+	out, err := mgr.Call(context.Background(), "local", "echo", json.RawMessage(`{"text":"hi"}`))
+	if err != nil || out != "echo: hi" {
+		t.Fatalf("echo = %q, %v", out, err)
+	}
+	if _, err := mgr.Call(context.Background(), "local", "fail", nil); err == nil || err.Error() != "boom" {
+		t.Fatalf("isError result should surface as error, got %v", err)
+	}
+	if _, err := mgr.Call(context.Background(), "off", "echo", nil); err == nil {
+		t.Fatal("disabled server must not be callable")
+	}
+}
+
+func TestManagerRecordsConnectFailure(t *testing.T) {
+	mgr := NewManager()
+	defer mgr.Close()
+	mgr.Start(context.Background(), []config.MCPServerEntry{
+		{ID: "broken", Transport: "stdio", Command: "/nonexistent/mcp-server", Enabled: true},
+	})
+	status := mgr.Status()
+	if len(status) != 1 || status[0].Connected || status[0].Error == "" {
+		t.Fatalf("failed server should be reported, got %+v", status)
+	}
+	if len(mgr.Tools([]string{"broken"})) != 0 {
+		t.Fatal("failed server must not contribute tools")
+	}
+}
+
+func TestStreamableHTTPTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcMessage
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if req.Method != "initialize" && r.Header.Get("Mcp-Session-Id") != "sess-1" {
+			http.Error(w, "missing session", http.StatusBadRequest)
+			return
+		}
+		result, rpcErr := handleTestRequest(req.Method, req.Params)
+		reply, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result, "error": rpcErr})
+		w.Header().Set("Mcp-Session-Id", "sess-1")
+		if req.Method == "tools/call" {
+			// Answer via SSE with a notification first.
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprintf(w, "data: {\"jsonrpc\":\"2.0\",\"method\":\"notifications/progress\"}\n\n")
+			fmt.Fprintf(w, "data: %s\n\n", reply)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(reply)
+	}))
+	defer srv.Close()
+
+	client, err := Connect(context.Background(), config.MCPServerEntry{ID: "remote", Transport: "http", URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	if client.Server.Name != "test-server" {
+		t.Fatalf("server info = %+v", client.Server)
+	}
+	res, err := client.CallTool(context.Background(), "echo", json.RawMessage(`{"text":"sse"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Text() != "echo: sse" {
+		t.Fatalf("text = %q", res.Text())
+	}
+}
+
+func TestToolNameIsProviderSafe(t *testing.T) {
+	if got := ToolName("git hub", "create:issue"); got != "mcp__git_hub__create_issue" {
+		t.Fatalf("ToolName = %q", got)
+	}
+	long := ToolName("server", strings.Repeat("x", 100))
+	if len(long) != maxToolNameLen {
+		t.Fatalf("long name length = %d", len(long))
+	}
+	if long == ToolName("server", strings.Repeat("x", 101)) {
+		t.Fatal("truncated names must stay distinct")
+	}
+}
+
+func TestManagerRestartLeavesOtherServersRunning(t *testing.T) {
+	mgr := NewManager()
+	defer mgr.Close()
+	mgr.Start(context.Background(), []config.MCPServerEntry{stdioEntry(t, "a"), stdioEntry(t, "b")})
+	mgr.mu.RLock()
+	clientA := mgr.servers["a"].client
+	mgr.mu.RUnlock()
+
+	broken := config.MCPServerEntry{ID: "b", Transport: "stdio", Command: "/nonexistent/mcp-server", Enabled: true}
+	mgr.Restart(context.Background(), broken)
+	mgr.Restart(context.Background(), stdioEntry(t, "c"))
+
+	mgr.mu.RLock()
+	sameA := mgr.servers["a"].client == clientA
+	mgr.mu.RUnlock()
+	if !sameA || !clientA.alive() {
+		t.Fatal("restarting b must not reconnect a")
+	}
+	status := mgr.Status()
+	if len(status) != 3 || status[0].ID != "a" || status[1].ID != "b" || status[1].Connected || status[2].ID != "c" || !status[2].Connected {
+		t.Fatalf("unexpected status after restart: %+v", status)
+	}
+
+	mgr.Remove("b")
+	broken.Enabled = false
+	mgr.Restart(context.Background(), broken) // disabled → stays removed
+	if status := mgr.Status(); len(status) != 2 || status[1].ID != "c" {
+		t.Fatalf("unexpected status after remove: %+v", status)
+	}
+}
@@ -0,0 +1,105 @@
+// Package mcp implements a Model Context Protocol client so external MCP
+// servers (stdio subprocesses or streamable HTTP endpoints) can be mounted
+// into agents as ordinary tools, and a server (server.go) that exposes
+// ZyHive agents to external MCP clients.
+//
+// Client side: only the tool surface is consumed: initialize → notifications/initialized
+// → tools/list → tools/call. Server-initiated requests (sampling, roots,
+// elicitation) are answered with "method not found".
+package mcp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ProtocolVersion is the MCP revision sent in initialize and in the
+// MCP-Protocol-Version header of HTTP requests.
+const ProtocolVersion = "2025-06-18"
+
+// rpcRequest is a JSON-RPC 2.0 request or notification (ID == nil).
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcMessage is any inbound JSON-RPC 2.0 message. Responses carry
+// Result/Error; server-initiated requests carry Method (+ ID).
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+}
+
+// isResponse reports whether the message answers one of our requests.
+func (m *rpcMessage) isResponse() bool {
+	return m.Method == "" && len(m.ID) > 0
+}
+
+// RPCError is a JSON-RPC error object returned by the server.
+type RPCError struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("mcp rpc error %d: %s", e.Code, e.Message)
+}
+
+// Tool is one entry of a tools/list result.
+type Tool struct {
+	Name        string          `json:"name"`
+	Title       string          `json:"title,omitempty"`
+	Description string          `json:"description,omitempty"`
+	InputSchema json.RawMessage `json:"inputSchema,omitempty"`
+}
+
+// Content is one block of a tools/call result.
+type Content struct {
+	Type     string           `json:"type"` // "text" | "image" | "audio" | "resource" | "resource_link"
+	Text     string           `json:"text,omitempty"`
+	MimeType string           `json:"mimeType,omitempty"`
+	Data     string           `json:"data,omitempty"` // base64 for image/audio
+	URI      string           `json:"uri,omitempty"`  // resource_link
+	Name     string           `json:"name,omitempty"`
+	Resource *ResourceContent `json:"resource,omitempty"`
+}
+
+// ResourceContent is an embedded resource inside a Content block.
+type ResourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType,omitempty"`
+	Text     string `json:"text,omitempty"`
+	Blob     string `json:"blob,omitempty"`
+}
+
+// CallResult is the result of tools/call.
+type CallResult struct {
+	Content           []Content       `json:"content"`
+	StructuredContent json.RawMessage `json:"structuredContent,omitempty"`
+	IsError           bool            `json:"isError,omitempty"`
+}
+
+// ServerInfo identifies the remote server (from the initialize result).
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string          `json:"protocolVersion"`
+	Capabilities    json.RawMessage `json:"capabilities,omitempty"`
+	ServerInfo      ServerInfo      `json:"serverInfo"`
+	Instructions    string          `json:"instructions,omitempty"`
+}
+
+type listToolsResult struct {
+	Tools      []Tool `json:"tools"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"crypto/subtle"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+)
+
+// Scope limits what one MCP client may see and call.
+type Scope struct {
+	AllAgents bool
+	Agents    map[string]bool
+	Projects  bool // shared project files as resources
+}
+
+// FullScope is the admin-token scope.
+func FullScope() Scope {
+	return Scope{AllAgents: true, Projects: true}
+}
+
+// AllowsAgent reports whether agentID is exposed to this scope.
+func (s Scope) AllowsAgent(agentID string) bool {
+	return s.AllAgents || s.Agents[agentID]
+}
+
+// ResolveScope maps a bearer token to its scope. The admin token (auth.token)
+// gets FullScope; mcpServe.tokens get their configured agents/projects.
+// Comparisons are constant-time; an empty token never matches.
+func ResolveScope(cfg *config.Config, token string) (Scope, bool) {
+	if token == "" {
+		return Scope{}, false
+	}
+	if tokenEqual(token, cfg.Auth.Token) {
+		return FullScope(), true
+	}
+	for _, t := range cfg.MCPServe.Tokens {
+		if !tokenEqual(token, t.Token) {
+			continue
+		}
+		scope := Scope{Agents: make(map[string]bool), Projects: t.Projects}
+		for _, id := range t.Agents {
+			if id == "*" {
+				scope.AllAgents = true
+			}
+			scope.Agents[id] = true
+		}
+		return scope, true
+	}
+	return Scope{}, false
+}
+
+func tokenEqual(got, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,450 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ── Server side ──────────────────────────────────────────────────────────────
+//
+// Server exposes ZyHive agents to external MCP clients (IDEs, other agent
+// runtimes). Each agent becomes a tool ask_<agentId>; session transcripts,
+// memory files and shared project files are readable as resources:
+//
+//	zyhive://agents/{agentId}/sessions/{sessionId}
+//	zyhive://agents/{agentId}/memory/{path}
+//	zyhive://projects/{projectId}/files/{path}
+//
+// Every request is evaluated against a Scope (see ResolveScope), so a scoped
+// token only ever sees the agents — and their resources — it was granted.
+
+// AgentInfo describes one agent exposed as an ask_<agentId> tool.
+type AgentInfo struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// SessionInfo describes one session transcript resource.
+type SessionInfo struct {
+	ID    string
+	Title string
+}
+
+// ProjectInfo describes one shared project.
+type ProjectInfo struct {
+	ID          string
+	Name        string
+	Description string
+}
+
+// AskResult is the outcome of one agent turn.
+type AskResult struct {
+	Reply     string `json:"reply"`
+	SessionID string `json:"sessionId"`
+}
+
+// Backend is implemented by the agent pool (see agent.Pool.MCPBackend);
+// it is an interface here because pkg/agent already depends on this package.
+type Backend interface {
+	ListAgents() []AgentInfo
+	// Ask runs one turn through the normal pool path (usage + budget
+	// accounting). An empty sessionID starts a new session.
+	Ask(ctx context.Context, agentID, sessionID, message string) (AskResult, error)
+	ListSessions(agentID string) ([]SessionInfo, error)
+	ReadSession(agentID, sessionID string) (string, error)
+	ListMemory(agentID string) ([]string, error)
+	ReadMemory(agentID, path string) (string, error)
+	ListProjects() []ProjectInfo
+	ListProjectFiles(projectID string) ([]string, error)
+	ReadProjectFile(projectID, path string) (string, error)
+}
+
+// maxListedSessions caps session resources per agent in resources/list;
+// older sessions stay reachable through the resource template.
+const maxListedSessions = 50
+
+// Server answers MCP JSON-RPC requests for one backend.
+type Server struct {
+	backend Backend
+	name    string
+	version string
+}
+
+// NewServer creates a server. name/version are reported in serverInfo.
+func NewServer(backend Backend, name, version string) *Server {
+	return &Server{backend: backend, name: name, version: version}
+}
+
+// Handle processes one JSON-RPC message and returns the encoded response,
+// or nil for notifications and responses.
+func (s *Server) Handle(ctx context.Context, scope Scope, data []byte) []byte {
+	var msg rpcMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return encodeReply(json.RawMessage("null"), nil, &RPCError{Code: -32700, Message: "parse error"})
+	}
+	if msg.Method == "" || len(msg.ID) == 0 {
+		return nil // notification or a response to nothing we sent
+	}
+	result, err := s.dispatch(ctx, scope, msg.Method, msg.Params)
+	if err != nil {
+		var rpcErr *RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &RPCError{Code: -32603, Message: err.Error()}
+		}
+		return encodeReply(msg.ID, nil, rpcErr)
+	}
+	return encodeReply(msg.ID, result, nil)
+}
+
+func encodeReply(id json.RawMessage, result any, rpcErr *RPCError) []byte {
+	reply := map[string]any{"jsonrpc": "2.0", "id": id}
+	if rpcErr != nil {
+		reply["error"] = rpcErr
+	} else {
+		reply["result"] = result
+	}
+	data, _ := json.Marshal(reply)
+	return data
+}
+
+func invalidParams(format string, args ...any) *RPCError {
+	return &RPCError{Code: -32602, Message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) dispatch(ctx context.Context, scope Scope, method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": ProtocolVersion,
+			"capabilities": map[string]any{
+				"tools":     map[string]any{},
+				"resources": map[string]any{},
+			},
+			"serverInfo":   ServerInfo{Name: s.name, Version: s.version},
+			"instructions": "Each ask_<agentId> tool sends a message to one ZyHive agent and returns its reply. Pass the returned sessionId to continue the same conversation.",
+		}, nil
+	case "ping":
+		return struct{}{}, nil
+	case "tools/list":
+		return map[string]any{"tools": s.listTools(scope)}, nil
+	case "tools/call":
+		return s.callTool(ctx, scope, params)
+	case "resources/list":
+		return map[string]any{"resources": s.listResources(scope)}, nil
+	case "resources/templates/list":
+		return map[string]any{"resourceTemplates": resourceTemplates(scope)}, nil
+	case "resources/read":
+		return s.readResource(scope, params)
+	}
+	return nil, &RPCError{Code: -32601, Message: "method not found: " + method}
+}
+
+// AskToolName is the tool name for an agent: ask_<agentId>, sanitised to the
+// provider-safe character set.
+func AskToolName(agentID string) string {
+	return "ask_" + sanitizeName(agentID)
+}
+
+var askInputSchema = json.RawMessage(`{
+	"type":"object",
+	"properties":{
+		"message":{"type":"string","description":"Message to send to the agent"},
+		"sessionId":{"type":"string","description":"Continue an existing session (returned by a previous call); omit to start a new one"}
+	},
+	"required":["message"]
+}`)
+
+func (s *Server) visibleAgents(scope Scope) []AgentInfo {
+	var out []AgentInfo
+	for _, a := range s.backend.ListAgents() {
+		if scope.AllowsAgent(a.ID) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (s *Server) listTools(scope Scope) []Tool {
+	agents := s.visibleAgents(scope)
+	tools := make([]Tool, 0, len(agents))
+	for _, a := range agents {
+		desc := fmt.Sprintf("Ask ZyHive agent %q (%s).", a.Name, a.ID)
+		if a.Description != "" {
+			desc += " " + a.Description
+		}
+		tools = append(tools, Tool{
+			Name:        AskToolName(a.ID),
+			Title:       a.Name,
+			Description: desc,
+			InputSchema: askInputSchema,
+		})
+	}
+	return tools
+}
+
+func (s *Server) callTool(ctx context.Context, scope Scope, params json.RawMessage) (any, error) {
+	var p struct {
+		Name      string `json:"name"`
+		Arguments struct {
+			Message   string `json:"message"`
+			SessionID string `json:"sessionId"`
+		} `json:"arguments"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, invalidParams("invalid params: %v", err)
+	}
+	var target *AgentInfo
+	for _, a := range s.visibleAgents(scope) {
+		if AskToolName(a.ID) == p.Name {
+			a := a
+			target = &a
+			break
+		}
+	}
+	if target == nil {
+		return nil, invalidParams("unknown tool %q", p.Name)
+	}
+	if strings.TrimSpace(p.Arguments.Message) == "" {
+		return nil, invalidParams("message is required")
+	}
+	res, err := s.backend.Ask(ctx, target.ID, p.Arguments.SessionID, p.Arguments.Message)
+	if err != nil {
+		// Tool-level failure: reported in-band so the client's model can react.
+		return CallResult{IsError: true, Content: []Content{{Type: "text", Text: err.Error()}}}, nil
+	}
+	structured, _ := json.Marshal(res)
+	return CallResult{
+		Content:           []Content{{Type: "text", Text: res.Reply}},
+		StructuredContent: structured,
+	}, nil
+}
+
+// Resource is one entry of a resources/list result.
+type Resource struct {
+	URI         string `json:"uri"`
+	Name        string `json:"name"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+func agentURI(agentID, kind, rest string) string {
+	return "zyhive://agents/" + url.PathEscape(agentID) + "/" + kind + "/" + escapePath(rest)
+}
+
+func projectURI(projectID, rest string) string {
+	return "zyhive://projects/" + url.PathEscape(projectID) + "/files/" + escapePath(rest)
+}
+
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i := range parts {
+		parts[i] = url.PathEscape(parts[i])
+	}
+	return strings.Join(parts, "/")
+}
+
+func (s *Server) listResources(scope Scope) []Resource {
+	var out []Resource
+	for _, a := range s.visibleAgents(scope) {
+		if sessions, err := s.backend.ListSessions(a.ID); err == nil {
+			if len(sessions) > maxListedSessions {
+				sessions = sessions[:maxListedSessions]
+			}
+			for _, sess := range sessions {
+				out = append(out, Resource{
+					URI:      agentURI(a.ID, "sessions", sess.ID),
+					Name:     a.ID + "/sessions/" + sess.ID,
+					Title:    sess.Title,
+					MimeType: "text/markdown",
+				})
+			}
+		}
+		if files, err := s.backend.ListMemory(a.ID); err == nil {
+			for _, f := range files {
+				out = append(out, Resource{
+					URI:      agentURI(a.ID, "memory", f),
+					Name:     a.ID + "/memory/" + f,
+					MimeType: "text/markdown",
+				})
+			}
+		}
+	}
+	if scope.Projects {
+		for _, p := range s.backend.ListProjects() {
+			files, err := s.backend.ListProjectFiles(p.ID)
+			if err != nil {
+				continue
+			}
+			for _, f := range files {
+				out = append(out, Resource{
+					URI:         projectURI(p.ID, f),
+					Name:        p.ID + "/" + f,
+					Description: p.Name,
+				})
+			}
+		}
+	}
+	return out
+}
+
+type resourceTemplate struct {
+	URITemplate string `json:"uriTemplate"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	MimeType    string `json:"mimeType,omitempty"`
+}
+
+func resourceTemplates(scope Scope) []resourceTemplate {
+	out := []resourceTemplate{
+		{URITemplate: "zyhive://agents/{agentId}/sessions/{sessionId}", Name: "session", Description: "Session transcript", MimeType: "text/markdown"},
+		{URITemplate: "zyhive://agents/{agentId}/memory/{path}", Name: "memory", Description: "Agent memory file", MimeType: "text/markdown"},
+	}
+	if scope.Projects {
+		out = append(out, resourceTemplate{URITemplate: "zyhive://projects/{projectId}/files/{path}", Name: "project-file", Description: "Shared project file"})
+	}
+	return out
+}
+
+func (s *Server) readResource(scope Scope, params json.RawMessage) (any, error) {
+	var p struct {
+		URI string `json:"uri"`
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, invalidParams("invalid params: %v", err)
+	}
+	text, mimeType, err := s.resolveResource(scope, p.URI)
+	if err != nil {
+		return nil, &RPCError{Code: -32002, Message: err.Error()}
+	}
+	return map[string]any{"contents": []ResourceContent{{URI: p.URI, MimeType: mimeType, Text: text}}}, nil
+}
+
+// resolveResource maps a zyhive:// URI to content, enforcing scope. Path
+// confinement is the backend's job (MemoryTree / project manager).
+func (s *Server) resolveResource(scope Scope, rawURI string) (string, string, error) {
+	u, err := url.Parse(rawURI)
+	if err != nil || u.Scheme != "zyhive" {
+		return "", "", fmt.Errorf("resource not found: %s", rawURI)
+	}
+	segs := strings.Split(strings.TrimPrefix(u.EscapedPath(), "/"), "/")
+	for i := range segs {
+		if segs[i], err = url.PathUnescape(segs[i]); err != nil {
+			return "", "", fmt.Errorf("resource not found: %s", rawURI)
+		}
+	}
+	notFound := fmt.Errorf("resource not found: %s", rawURI)
+	switch u.Host {
+	case "agents":
+		if len(segs) < 3 || !scope.AllowsAgent(segs[0]) {
+			return "", "", notFound
+		}
+		agentID, kind, rest := segs[0], segs[1], strings.Join(segs[2:], "/")
+		switch kind {
+		case "sessions":
+			text, err := s.backend.ReadSession(agentID, rest)
+			return text, "text/markdown", err
+		case "memory":
+			text, err := s.backend.ReadMemory(agentID, rest)
+			return text, "text/markdown", err
+		}
+	case "projects":
+		if !scope.Projects || len(segs) < 3 || segs[1] != "files" {
+			return "", "", notFound
+		}
+		text, err := s.backend.ReadProjectFile(segs[0], strings.Join(segs[2:], "/"))
+		return text, "text/plain", err
+	}
+	return "", "", notFound
+}
+
+// ServeStdio serves newline-delimited JSON-RPC on r/w until r is exhausted
+// or ctx is cancelled. Requests run concurrently so a long ask_ call does not
+// block pings; notifications/cancelled aborts the matching request.
+func (s *Server) ServeStdio(ctx context.Context, scope Scope, r io.Reader, w io.Writer) error {
+	var (
+		writeMu sync.Mutex
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		cancels = make(map[string]context.CancelFunc)
+	)
+	write := func(data []byte) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		_, _ = w.Write(append(data, '\n'))
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStdioLine)
+	for scanner.Scan() {
+		line := append([]byte(nil), scanner.Bytes()...)
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		var head struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+			Params struct {
+				RequestID json.RawMessage `json:"requestId"`
+			} `json:"params"`
+		}
+		_ = json.Unmarshal(line, &head)
+		if head.Method == "notifications/cancelled" {
+			mu.Lock()
+			if cancel := cancels[string(head.Params.RequestID)]; cancel != nil {
+				cancel()
+			}
+			mu.Unlock()
+			continue
+		}
+		reqCtx, cancel := context.WithCancel(ctx)
+		key := string(head.ID)
+		if key != "" {
+			mu.Lock()
+			cancels[key] = cancel
+			mu.Unlock()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer cancel()
+			if reply := s.Handle(reqCtx, scope, line); reply != nil && reqCtx.Err() == nil {
+				write(reply)
+			}
+			if key != "" {
+				mu.Lock()
+				delete(cancels, key)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return scanner.Err()
+}
+
+// sortedKeys is a small helper for deterministic scope rendering.
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// String renders the scope for logs.
+func (s Scope) String() string {
+	if s.AllAgents {
+		return "all agents, projects=" + strconv.FormatBool(s.Projects)
+	}
+	return "agents=" + strings.Join(sortedKeys(s.Agents), ",") + " projects=" + strconv.FormatBool(s.Projects)
+}
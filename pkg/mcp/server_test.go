@@ -0,0 +1,185 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+)
+
+type fakeBackend struct {
+	asked []string
+}
+
+func (f *fakeBackend) ListAgents() []AgentInfo {
+	return []AgentInfo{{ID: "main", Name: "Main"}, {ID: "ops", Name: "Ops"}}
+}
+
+func (f *fakeBackend) Ask(ctx context.Context, agentID, sessionID, message string) (AskResult, error) {
+	f.asked = append(f.asked, agentID+":"+message)
+	if message == "fail" {
+		return AskResult{}, errors.New("budget exceeded")
+	}
+	if sessionID == "" {
+		sessionID = "mcp-1"
+	}
+	return AskResult{Reply: "hi from " + agentID, SessionID: sessionID}, nil
+}
+
+func (f *fakeBackend) ListSessions(agentID string) ([]SessionInfo, error) {
+	return []SessionInfo{{ID: "ses-1", Title: "first"}}, nil
+}
+
+func (f *fakeBackend) ReadSession(agentID, sessionID string) (string, error) {
+	return "# " + agentID + "/" + sessionID, nil
+}
+
+func (f *fakeBackend) ListMemory(agentID string) ([]string, error) {
+	return []string{"INDEX.md"}, nil
+}
+
+func (f *fakeBackend) ReadMemory(agentID, path string) (string, error) {
+	return "memory " + path, nil
+}
+
+func (f *fakeBackend) ListProjects() []ProjectInfo {
+	return []ProjectInfo{{ID: "p1", Name: "Project"}}
+}
+
+func (f *fakeBackend) ListProjectFiles(projectID string) ([]string, error) {
+	return []string{"docs/spec.md"}, nil
+}
+
+func (f *fakeBackend) ReadProjectFile(projectID, path string) (string, error) {
+	return "file " + path, nil
+}
+
+func call(t *testing.T, s *Server, scope Scope, method string, params any) (json.RawMessage, *RPCError) {
+	t.Helper()
+	raw, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	var reply rpcMessage
+	if err := json.Unmarshal(s.Handle(context.Background(), scope, raw), &reply); err != nil {
+		t.Fatal(err)
+	}
+	return reply.Result, reply.Error
+}
+
+func TestServerExposesAgentsAsTools(t *testing.T) {
+	backend := &fakeBackend{}
+	s := NewServer(backend, "zyhive", "test")
+	scope := Scope{Agents: map[string]bool{"main": true}}
+
+	res, rpcErr := call(t, s, scope, "tools/list", nil)
+	if rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	var list listToolsResult
+	_ = json.Unmarshal(res, &list)
+	if len(list.Tools) != 1 || list.Tools[0].Name != "ask_main" {
+		t.Fatalf("scoped tools/list = %+v", list.Tools)
+	}
+
+	res, rpcErr = call(t, s, scope, "tools/call", map[string]any{"name": "ask_main", "arguments": map[string]string{"message": "hello"}})
+	if rpcErr != nil {
+		t.Fatal(rpcErr)
+	}
+	var out CallResult
+	_ = json.Unmarshal(res, &out)
+	if out.IsError || out.Text() != "hi from main" || !strings.Contains(string(out.StructuredContent), `"sessionId":"mcp-1"`) {
+		t.Fatalf("ask_main = %+v", out)
+	}
+
+	if _, rpcErr = call(t, s, scope, "tools/call", map[string]any{"name": "ask_ops", "arguments": map[string]string{"message": "x"}}); rpcErr == nil {
+		t.Fatal("out-of-scope agent must not be callable")
+	}
+	res, _ = call(t, s, scope, "tools/call", map[string]any{"name": "ask_main", "arguments": map[string]string{"message": "fail"}})
+	_ = json.Unmarshal(res, &out)
+	if !out.IsError || out.Text() != "budget exceeded" {
+		t.Fatalf("run errors should be reported in-band, got %+v", out)
+	}
+	if len(backend.asked) != 2 {
+		t.Fatalf("backend calls = %v", backend.asked)
+	}
+}
+
+func TestServerResourcesRespectScope(t *testing.T) {
+	s := NewServer(&fakeBackend{}, "zyhive", "test")
+	scope := Scope{Agents: map[string]bool{"main": true}}
+
+	res, _ := call(t, s, scope, "resources/list", nil)
+	if strings.Contains(string(res), "agents/ops") || strings.Contains(string(res), "projects/") {
+		t.Fatalf("resources/list leaked out-of-scope entries: %s", res)
+	}
+	if !strings.Contains(string(res), "zyhive://agents/main/sessions/ses-1") {
+		t.Fatalf("resources/list = %s", res)
+	}
+
+	res, rpcErr := call(t, s, scope, "resources/read", map[string]string{"uri": "zyhive://agents/main/memory/INDEX.md"})
+	if rpcErr != nil || !strings.Contains(string(res), "memory INDEX.md") {
+		t.Fatalf("read memory = %s, %v", res, rpcErr)
+	}
+	for _, uri := range []string{
+		"zyhive://agents/ops/memory/INDEX.md",
+		"zyhive://projects/p1/files/docs/spec.md",
+		"file:///etc/passwd",
+	} {
+		if _, rpcErr := call(t, s, scope, "resources/read", map[string]string{"uri": uri}); rpcErr == nil {
+			t.Fatalf("%s must not be readable in this scope", uri)
+		}
+	}
+	res, rpcErr = call(t, s, FullScope(), "resources/read", map[string]string{"uri": "zyhive://projects/p1/files/docs/spec.md"})
+	if rpcErr != nil || !strings.Contains(string(res), "file docs/spec.md") {
+		t.Fatalf("read project file = %s, %v", res, rpcErr)
+	}
+}
+
+func TestResolveScope(t *testing.T) {
+	cfg := &config.Config{
+		Auth: config.AuthConfig{Token: "admin"},
+		MCPServe: config.MCPServeConfig{Tokens: []config.MCPServeToken{
+			{ID: "ide", Token: "scoped", Agents: []string{"main"}},
+		}},
+	}
+	if s, ok := ResolveScope(cfg, "admin"); !ok || !s.AllAgents || !s.Projects {
+		t.Fatalf("admin scope = %+v, %v", s, ok)
+	}
+	if s, ok := ResolveScope(cfg, "scoped"); !ok || s.AllAgents || !s.AllowsAgent("main") || s.AllowsAgent("ops") || s.Projects {
+		t.Fatalf("scoped scope = %+v, %v", s, ok)
+	}
+	if _, ok := ResolveScope(cfg, ""); ok {
+		t.Fatal("empty token must not resolve")
+	}
+	if _, ok := ResolveScope(cfg, "nope"); ok {
+		t.Fatal("unknown token must not resolve")
+	}
+}
+
+func TestServeStdio(t *testing.T) {
+	s := NewServer(&fakeBackend{}, "zyhive", "test")
+	in := strings.NewReader(strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"ping"}`,
+	}, "\n") + "\n")
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ServeStdio(context.Background(), FullScope(), in, pw)
+		pw.Close()
+	}()
+	data, _ := io.ReadAll(pr)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 responses (notification gets none), got %q", data)
+	}
+	if !strings.Contains(string(data), `"protocolVersion":"`+ProtocolVersion+`"`) {
+		t.Fatalf("initialize response missing protocolVersion: %s", data)
+	}
+}
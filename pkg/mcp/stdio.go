@@ -0,0 +1,286 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxStdioLine caps a single newline-delimited JSON-RPC message from a stdio
+// server. tools/list results of large servers easily exceed bufio's 64 KiB.
+const maxStdioLine = 16 << 20
+
+// stdioShutdownGrace is how long close waits for the server to exit after
+// stdin is closed before killing it.
+const stdioShutdownGrace = 2 * time.Second
+
+// stdioTransport talks newline-delimited JSON-RPC over a subprocess's
+// stdin/stdout. stderr is kept (tail only) for error messages.
+type stdioTransport struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	nextID atomic.Int64
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	pending map[int64]chan *rpcMessage
+	closed  error // non-nil once the read loop has exited
+
+	stderr *tailBuffer
+	done   chan struct{}
+}
+
+func newStdioTransport(command string, args, env []string) (*stdioTransport, error) {
+	if command == "" {
+		return nil, errors.New("mcp: stdio server requires a command")
+	}
+	cmd := exec.Command(command, args...)
+	cmd.Env = append(scrubEnv(os.Environ()), env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	t := &stdioTransport{
+		cmd:     cmd,
+		stdin:   stdin,
+		pending: make(map[int64]chan *rpcMessage),
+		stderr:  &tailBuffer{max: 4096},
+		done:    make(chan struct{}),
+	}
+	cmd.Stderr = t.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("mcp: start %s: %w", command, err)
+	}
+	go t.readLoop(stdout)
+	return t, nil
+}
+
+func (t *stdioTransport) readLoop(stdout io.Reader) {
+	defer close(t.done)
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxStdioLine)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var msg rpcMessage
+		if err := json.Unmarshal(line, &msg); err != nil {
+			continue // servers sometimes log to stdout; ignore non-JSON lines
+		}
+		switch {
+		case msg.isResponse():
+			id, err := strconv.ParseInt(string(msg.ID), 10, 64)
+			if err != nil {
+				continue
+			}
+			t.mu.Lock()
+			ch := t.pending[id]
+			delete(t.pending, id)
+			t.mu.Unlock()
+			if ch != nil {
+				ch <- &msg
+			}
+		case msg.Method != "" && len(msg.ID) > 0:
+			t.answerServerRequest(&msg)
+		}
+	}
+	err := scanner.Err()
+	if err == nil {
+		err = io.EOF
+	}
+	if tail := strings.TrimSpace(t.stderr.String()); tail != "" {
+		err = fmt.Errorf("%w (stderr: %s)", err, tail)
+	}
+	t.mu.Lock()
+	t.closed = fmt.Errorf("mcp: server exited: %w", err)
+	for id, ch := range t.pending {
+		close(ch)
+		delete(t.pending, id)
+	}
+	t.mu.Unlock()
+}
+
+// answerServerRequest replies to server→client requests. ping is answered;
+// everything else (sampling, roots, elicitation) is unsupported.
+func (t *stdioTransport) answerServerRequest(msg *rpcMessage) {
+	reply := map[string]any{"jsonrpc": "2.0", "id": msg.ID}
+	if msg.Method == "ping" {
+		reply["result"] = struct{}{}
+	} else {
+		reply["error"] = RPCError{Code: -32601, Message: "method not found: " + msg.Method}
+	}
+	data, _ := json.Marshal(reply)
+	_ = t.write(data)
+}
+
+func (t *stdioTransport) write(data []byte) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+	_, err := t.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (t *stdioTransport) call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	id := t.nextID.Add(1)
+	raw, err := marshalParams(params)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: &id, Method: method, Params: raw})
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *rpcMessage, 1)
+	t.mu.Lock()
+	if t.closed != nil {
+		err := t.closed
+		t.mu.Unlock()
+		return nil, err
+	}
+	t.pending[id] = ch
+	t.mu.Unlock()
+
+	if err := t.write(data); err != nil {
+		t.forget(id)
+		return nil, fmt.Errorf("mcp: write %s: %w", method, err)
+	}
+	select {
+	case msg, ok := <-ch:
+		if !ok {
+			t.mu.Lock()
+			err := t.closed
+			t.mu.Unlock()
+			return nil, err
+		}
+		if msg.Error != nil {
+			return nil, msg.Error
+		}
+		return msg.Result, nil
+	case <-ctx.Done():
+		t.forget(id)
+		t.cancelRequest(id, ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+func (t *stdioTransport) alive() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed == nil
+}
+
+func (t *stdioTransport) forget(id int64) {
+	t.mu.Lock()
+	delete(t.pending, id)
+	t.mu.Unlock()
+}
+
+// cancelRequest tells the server to stop working on an abandoned request.
+func (t *stdioTransport) cancelRequest(id int64, reason error) {
+	_ = t.notify(context.Background(), "notifications/cancelled", map[string]any{
+		"requestId": id,
+		"reason":    reason.Error(),
+	})
+}
+
+func (t *stdioTransport) notify(_ context.Context, method string, params any) error {
+	raw, err := marshalParams(params)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(rpcRequest{JSONRPC: "2.0", Method: method, Params: raw})
+	if err != nil {
+		return err
+	}
+	return t.write(data)
+}
+
+// close shuts stdin (the spec's graceful shutdown signal) and kills the
+// process if it has not exited within stdioShutdownGrace.
+func (t *stdioTransport) close() error {
+	_ = t.stdin.Close()
+	select {
+	case <-t.done:
+	case <-time.After(stdioShutdownGrace):
+		if t.cmd.Process != nil {
+			_ = t.cmd.Process.Kill()
+		}
+		<-t.done
+	}
+	_ = t.cmd.Wait()
+	return nil
+}
+
+func marshalParams(params any) (json.RawMessage, error) {
+	if params == nil {
+		return nil, nil
+	}
+	if raw, ok := params.(json.RawMessage); ok {
+		return raw, nil
+	}
+	return json.Marshal(params)
+}
+
+// scrubEnv drops provider credentials from the inherited environment so a
+// third-party MCP server never sees the panel's own API keys. Server-specific
+// secrets must be passed explicitly via MCPServerEntry.Env.
+// Mirrors tools.sanitizeEnv (not imported to avoid a package cycle).
+func scrubEnv(env []string) []string {
+	blocked := []string{
+		"_API_KEY", "_SECRET", "_TOKEN", "_PASSWORD", "_PASSWD",
+		"_PRIVATE_KEY", "_ACCESS_KEY", "_AUTH_KEY", "ANTHROPIC_", "OPENAI_",
+		"DEEPSEEK_", "OPENROUTER_",
+	}
+	out := make([]string, 0, len(env))
+next:
+	for _, e := range env {
+		upper := strings.ToUpper(e)
+		for _, b := range blocked {
+			if strings.Contains(upper, b) {
+				continue next
+			}
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// tailBuffer keeps only the last max bytes written to it.
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
@@ -0,0 +1,348 @@
+// pkg/memory/hnsw.go — HNSW approximate nearest-neighbour graph over chunk vectors.
+package memory
+
+import (
+	"math"
+	"slices"
+)
+
+const (
+	hnswM              = 16  // links per node on upper layers
+	hnswM0             = 32  // links per node on layer 0
+	hnswEfConstruction = 100 // candidate list size while inserting
+	hnswEfSearch       = 64  // minimum candidate list size while searching
+	hnswMaxLevel       = 16
+)
+
+// HNSW is a Hierarchical Navigable Small World graph (Malkov & Yashunin,
+// 2016) over SearchIndex.Chunks: node i is chunk i. It only holds links and
+// vector norms; the vectors stay in the chunks. Nodes of removed chunks
+// (tombstones, Source == "") are still traversed but never returned, until
+// the index is compacted and the graph rebuilt.
+//
+// A graph is not safe for concurrent insert and search; indexes are
+// updated on a copy (clone) and searched read-only.
+type HNSW struct {
+	Entry    int32       // entry point; -1 = empty graph
+	MaxLevel int         // level of the entry point
+	Links    [][][]int32 // Links[node][level] = neighbours on that level
+	Norms    []float32   // L2 norm of each node's vector; 0 = not linked
+}
+
+func newHNSW() *HNSW {
+	return &HNSW{Entry: -1}
+}
+
+// clone deep-copies the links so the copy can be extended while the
+// original is being searched.
+func (g *HNSW) clone() *HNSW {
+	if g == nil {
+		return nil
+	}
+	c := &HNSW{Entry: g.Entry, MaxLevel: g.MaxLevel, Norms: slices.Clone(g.Norms)}
+	c.Links = make([][][]int32, len(g.Links))
+	for i, levels := range g.Links {
+		c.Links[i] = make([][]int32, len(levels))
+		for l, ns := range levels {
+			c.Links[i][l] = slices.Clone(ns)
+		}
+	}
+	return c
+}
+
+// hnswCand is a node with its distance to the current query.
+type hnswCand struct {
+	id   int32
+	dist float32
+}
+
+// hnswQuery is one query vector plus the scratch state of a search.
+type hnswQuery struct {
+	vec     []float32
+	norm    float32
+	visited []uint64 // bitset over nodes
+}
+
+// add links every chunk not yet in the graph (chunks[len(g.Links):]).
+// Chunks without a vector become unlinked nodes.
+func (g *HNSW) add(chunks []Chunk) {
+	for id := len(g.Links); id < len(chunks); id++ {
+		g.insert(chunks, int32(id))
+	}
+}
+
+func (g *HNSW) insert(chunks []Chunk, id int32) {
+	vec := chunks[id].Vec
+	norm := vecNorm(vec)
+	if norm == 0 {
+		g.Links = append(g.Links, [][]int32{nil})
+		g.Norms = append(g.Norms, 0)
+		return
+	}
+	level := hnswLevel(id)
+	g.Links = append(g.Links, make([][]int32, level+1))
+	g.Norms = append(g.Norms, norm)
+	if g.Entry < 0 {
+		g.Entry, g.MaxLevel = id, level
+		return
+	}
+
+	q := &hnswQuery{vec: vec, norm: norm, visited: make([]uint64, (len(g.Links)+63)/64)}
+	ep := []hnswCand{{g.Entry, g.dist(chunks, q, g.Entry)}}
+	for l := g.MaxLevel; l > level; l-- {
+		ep = g.searchLayer(chunks, q, ep, 1, l)
+	}
+	for l := min(level, g.MaxLevel); l >= 0; l-- {
+		found := g.searchLayer(chunks, q, ep, hnswEfConstruction, l)
+		neighbours := g.selectNeighbours(chunks, found, hnswM)
+		ids := make([]int32, len(neighbours))
+		for i, n := range neighbours {
+			ids[i] = n.id
+		}
+		g.Links[id][l] = ids
+
+		maxLinks := hnswM
+		if l == 0 {
+			maxLinks = hnswM0
+		}
+		for _, n := range ids {
+			links := append(g.Links[n][l], id)
+			if len(links) > maxLinks {
+				links = g.shrink(chunks, n, links, maxLinks)
+			}
+			g.Links[n][l] = links
+		}
+		ep = found
+	}
+	if level > g.MaxLevel {
+		g.Entry, g.MaxLevel = id, level
+	}
+}
+
+// search returns up to k live nodes nearest to vec, nearest first.
+func (g *HNSW) search(chunks []Chunk, vec []float32, k, ef int) []hnswCand {
+	norm := vecNorm(vec)
+	if g == nil || g.Entry < 0 || norm == 0 || k <= 0 {
+		return nil
+	}
+	q := &hnswQuery{vec: vec, norm: norm, visited: make([]uint64, (len(g.Links)+63)/64)}
+	ep := []hnswCand{{g.Entry, g.dist(chunks, q, g.Entry)}}
+	for l := g.MaxLevel; l > 0; l-- {
+		ep = g.searchLayer(chunks, q, ep, 1, l)
+	}
+	found := g.searchLayer(chunks, q, ep, max(ef, k), 0)
+	out := make([]hnswCand, 0, k)
+	for _, c := range found {
+		if chunks[c.id].Source == "" {
+			continue // tombstone
+		}
+		out = append(out, c)
+		if len(out) == k {
+			break
+		}
+	}
+	return out
+}
+
+// searchLayer is the greedy beam search of one layer. It returns up to ef
+// nodes, nearest first.
+func (g *HNSW) searchLayer(chunks []Chunk, q *hnswQuery, entry []hnswCand, ef, level int) []hnswCand {
+	clear(q.visited)
+	var cands, results candHeap
+	results.farthest = true
+	for _, e := range entry {
+		q.visit(e.id)
+		cands.push(e)
+		results.push(e)
+	}
+	for len(results.items) > ef {
+		results.pop()
+	}
+	for len(cands.items) > 0 {
+		c := cands.pop()
+		if len(results.items) >= ef && c.dist > results.top().dist {
+			break
+		}
+		if level >= len(g.Links[c.id]) {
+			continue
+		}
+		for _, n := range g.Links[c.id][level] {
+			if !q.visit(n) {
+				continue
+			}
+			d := g.dist(chunks, q, n)
+			if len(results.items) < ef || d < results.top().dist {
+				cands.push(hnswCand{n, d})
+				results.push(hnswCand{n, d})
+				if len(results.items) > ef {
+					results.pop()
+				}
+			}
+		}
+	}
+	out := results.items
+	slices.SortFunc(out, func(a, b hnswCand) int { return cmpDist(a.dist, b.dist) })
+	return out
+}
+
+// selectNeighbours is the paper's heuristic (algorithm 4, keeping pruned
+// connections): a candidate is taken only if it is nearer to the query than
+// to every neighbour already taken, which keeps links pointing in different
+// directions; the remaining slots are filled nearest first. cands must be
+// sorted nearest first.
+func (g *HNSW) selectNeighbours(chunks []Chunk, cands []hnswCand, m int) []hnswCand {
+	if len(cands) <= m {
+		return slices.Clone(cands)
+	}
+	out := make([]hnswCand, 0, m)
+	var skipped []hnswCand
+	for _, c := range cands {
+		if len(out) == m {
+			break
+		}
+		diverse := true
+		for _, r := range out {
+			if g.between(chunks, c.id, r.id) < c.dist {
+				diverse = false
+				break
+			}
+		}
+		if diverse {
+			out = append(out, c)
+		} else {
+			skipped = append(skipped, c)
+		}
+	}
+	for _, c := range skipped {
+		if len(out) == m {
+			break
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// shrink re-selects the links of node down to m.
+func (g *HNSW) shrink(chunks []Chunk, node int32, links []int32, m int) []int32 {
+	cands := make([]hnswCand, len(links))
+	for i, n := range links {
+		cands[i] = hnswCand{n, g.between(chunks, node, n)}
+	}
+	slices.SortFunc(cands, func(a, b hnswCand) int { return cmpDist(a.dist, b.dist) })
+	kept := g.selectNeighbours(chunks, cands, m)
+	out := make([]int32, len(kept))
+	for i, c := range kept {
+		out[i] = c.id
+	}
+	return out
+}
+
+// dist is the cosine distance (1 - cosine similarity) from q to node.
+func (g *HNSW) dist(chunks []Chunk, q *hnswQuery, node int32) float32 {
+	return cosineDist(q.vec, q.norm, chunks[node].Vec, g.Norms[node])
+}
+
+func (g *HNSW) between(chunks []Chunk, a, b int32) float32 {
+	return cosineDist(chunks[a].Vec, g.Norms[a], chunks[b].Vec, g.Norms[b])
+}
+
+func cosineDist(a []float32, na float32, b []float32, nb float32) float32 {
+	if len(a) != len(b) || na == 0 || nb == 0 {
+		return 2
+	}
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return 1 - dot/(na*nb)
+}
+
+func vecNorm(v []float32) float32 {
+	var s float32
+	for _, x := range v {
+		s += x * x
+	}
+	return float32(math.Sqrt(float64(s)))
+}
+
+func cmpDist(a, b float32) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// hnswLevel draws the node's top level from the exponential distribution
+// with mL = 1/ln(M). It is derived from the node ID (splitmix64) so a
+// rebuild yields the same graph.
+func hnswLevel(id int32) int {
+	x := uint64(id) + 0x9e3779b97f4a7c15
+	x = (x ^ x>>30) * 0xbf58476d1ce4e5b9
+	x = (x ^ x>>27) * 0x94d049bb133111eb
+	x ^= x >> 31
+	u := (float64(x>>11) + 1) / (1 << 53) // (0, 1]
+	return min(int(-math.Log(u)/math.Log(hnswM)), hnswMaxLevel)
+}
+
+// visit marks node as visited; false if it already was.
+func (q *hnswQuery) visit(node int32) bool {
+	w, bit := node/64, uint64(1)<<(node%64)
+	if q.visited[w]&bit != 0 {
+		return false
+	}
+	q.visited[w] |= bit
+	return true
+}
+
+// candHeap is a binary heap of candidates: nearest on top, or farthest on
+// top when farthest is set.
+type candHeap struct {
+	items    []hnswCand
+	farthest bool
+}
+
+func (h *candHeap) less(i, j int) bool {
+	if h.farthest {
+		return h.items[i].dist > h.items[j].dist
+	}
+	return h.items[i].dist < h.items[j].dist
+}
+
+func (h *candHeap) top() hnswCand { return h.items[0] }
+
+func (h *candHeap) push(c hnswCand) {
+	h.items = append(h.items, c)
+	for i := len(h.items) - 1; i > 0; {
+		p := (i - 1) / 2
+		if !h.less(i, p) {
+			break
+		}
+		h.items[i], h.items[p] = h.items[p], h.items[i]
+		i = p
+	}
+}
+
+func (h *candHeap) pop() hnswCand {
+	top := h.items[0]
+	last := len(h.items) - 1
+	h.items[0] = h.items[last]
+	h.items = h.items[:last]
+	for i := 0; ; {
+		l, r, m := 2*i+1, 2*i+2, i
+		if l < last && h.less(l, m) {
+			m = l
+		}
+		if r < last && h.less(r, m) {
+			m = r
+		}
+		if m == i {
+			break
+		}
+		h.items[i], h.items[m] = h.items[m], h.items[i]
+		i = m
+	}
+	return top
+}
@@ -0,0 +1,369 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// hashEmbedder embeds texts by feature hashing their words, so texts that
+// share words get similar vectors. calls counts embedded texts.
+func hashEmbedder(model string, dim int, calls *int) *indexEmbedder {
+	return &indexEmbedder{model: model, embed: func(_ context.Context, texts []string) ([][]float32, error) {
+		out := make([][]float32, len(texts))
+		for i, t := range texts {
+			v := make([]float32, dim)
+			for _, w := range strings.Fields(strings.ToLower(t)) {
+				h := fnv.New32a()
+				h.Write([]byte(w))
+				x := h.Sum32()
+				v[x%uint32(dim)]++
+				v[(x>>16)%uint32(dim)] -= 0.5
+			}
+			out[i] = v
+		}
+		if calls != nil {
+			*calls += len(texts)
+		}
+		return out, nil
+	}}
+}
+
+func writeMemFile(t testing.TB, ws, rel, content string) {
+	t.Helper()
+	p := filepath.Join(ws, "memory", rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sources(chunks []Chunk) []string {
+	var out []string
+	for _, c := range chunks {
+		out = append(out, c.Source)
+	}
+	return out
+}
+
+func TestUpdateIndexIsIncremental(t *testing.T) {
+	ws := t.TempDir()
+	tree := NewMemoryTree(ws)
+	writeMemFile(t, ws, "core/knowledge.md", "the deploy pipeline uses blue green releases\n\nrollbacks need the release tag")
+	writeMemFile(t, ws, "daily/2026-01-01.md", "standup notes about the quarterly budget review")
+	writeMemFile(t, ws, "daily/2026-01-02.md", "met the design team about the onboarding flow")
+
+	var calls int
+	emb := hashEmbedder("hash-v1", 64, &calls)
+	idx, st, err := updateIndex(context.Background(), tree.scanFiles(), nil, emb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 4 || st.Added != 3 || idx.Len() != 4 || idx.ANN == nil {
+		t.Fatalf("build: calls=%d stats=%+v len=%d", calls, st, idx.Len())
+	}
+	if err := tree.SaveIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	if tree.IsStale(idx) {
+		t.Fatal("fresh index is stale")
+	}
+
+	// Change one file, touch one, delete one, add one.
+	writeMemFile(t, ws, "core/knowledge.md", "the deploy pipeline uses canary releases\n\nrollbacks need the release tag")
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(ws, "memory", "daily", "2026-01-01.md"), later, later)
+	os.Remove(filepath.Join(ws, "memory", "daily", "2026-01-02.md"))
+	writeMemFile(t, ws, "topics/hiring.md", "hiring plan for the backend platform team")
+	if !tree.IsStale(idx) {
+		t.Fatal("changed files not detected")
+	}
+
+	old, _ := tree.LoadIndex()
+	calls = 0
+	idx2, st, err := updateIndex(context.Background(), tree.scanFiles(), old, emb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || st.Added != 1 || st.Changed != 1 || st.Removed != 1 {
+		t.Errorf("update: calls=%d stats=%+v", calls, st)
+	}
+	if idx2.Len() != 4 || tree.IsStale(idx2) {
+		t.Errorf("len=%d stale=%v", idx2.Len(), tree.IsStale(idx2))
+	}
+	if old.Len() != 4 || slices.Contains(sources(old.Chunks), "topics/hiring.md") {
+		t.Error("old index was modified")
+	}
+	got := idx2.Search(nil, "canary releases", 3)
+	if len(got) == 0 || got[0].Source != filepath.Join("memory", "core", "knowledge.md") {
+		t.Errorf("bm25 search = %+v", got)
+	}
+	for _, c := range idx2.Search(nil, "onboarding design", 5) {
+		if strings.Contains(c.Text, "onboarding") {
+			t.Errorf("removed file still found: %+v", c)
+		}
+	}
+
+	// A different embedding model re-embeds everything.
+	calls = 0
+	idx3, _, err := updateIndex(context.Background(), tree.scanFiles(), idx2, hashEmbedder("hash-v2", 64, &calls))
+	if err != nil || calls != 4 || idx3.Model != "hash-v2" {
+		t.Errorf("model change: calls=%d model=%q err=%v", calls, idx3.Model, err)
+	}
+}
+
+func TestUpdateIndexCompactsTombstones(t *testing.T) {
+	ws := t.TempDir()
+	tree := NewMemoryTree(ws)
+	for i := range 8 {
+		writeMemFile(t, ws, fmt.Sprintf("daily/d%d.md", i), fmt.Sprintf("daily log number %d about topic %d", i, i))
+	}
+	emb := hashEmbedder("hash", 32, nil)
+	idx, _, err := updateIndex(context.Background(), tree.scanFiles(), nil, emb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One rewrite leaves a tombstone; three more push them past a quarter.
+	writeMemFile(t, ws, "daily/d0.md", "rewritten daily log zero")
+	idx, st, _ := updateIndex(context.Background(), tree.scanFiles(), idx, emb)
+	if st.Compacted || len(idx.Chunks) != 9 || idx.Len() != 8 {
+		t.Fatalf("after one rewrite: chunks=%d live=%d stats=%+v", len(idx.Chunks), idx.Len(), st)
+	}
+	for i := 1; i <= 3; i++ {
+		writeMemFile(t, ws, fmt.Sprintf("daily/d%d.md", i), fmt.Sprintf("rewritten daily log %d", i))
+	}
+	idx, st, _ = updateIndex(context.Background(), tree.scanFiles(), idx, emb)
+	if !st.Compacted || len(idx.Chunks) != 8 || len(idx.ANN.Links) != 8 {
+		t.Fatalf("after compaction: chunks=%d links=%d stats=%+v", len(idx.Chunks), len(idx.ANN.Links), st)
+	}
+}
+
+// clusteredVectors returns n vectors around a few random centres.
+func clusteredVectors(rng *rand.Rand, n, dim int) [][]float32 {
+	centres := make([][]float32, 50)
+	for i := range centres {
+		centres[i] = make([]float32, dim)
+		for d := range centres[i] {
+			centres[i][d] = float32(rng.NormFloat64())
+		}
+	}
+	out := make([][]float32, n)
+	for i := range out {
+		c := centres[rng.IntN(len(centres))]
+		out[i] = make([]float32, dim)
+		for d := range out[i] {
+			out[i][d] = c[d] + 0.6*float32(rng.NormFloat64())
+		}
+	}
+	return out
+}
+
+func vectorChunks(vecs [][]float32) []Chunk {
+	chunks := make([]Chunk, len(vecs))
+	for i, v := range vecs {
+		chunks[i] = Chunk{Text: fmt.Sprintf("chunk %d", i), Source: "memory/synthetic.md", Line: i + 1, Vec: v}
+	}
+	return chunks
+}
+
+// exactNearest returns the IDs of the k live chunks nearest to q.
+func exactNearest(chunks []Chunk, q []float32, k int) []int32 {
+	type hit struct {
+		id  int32
+		sim float64
+	}
+	var hits []hit
+	for i, c := range chunks {
+		if c.Source != "" {
+			hits = append(hits, hit{int32(i), cosineSim(q, c.Vec)})
+		}
+	}
+	slices.SortFunc(hits, func(a, b hit) int {
+		if a.sim > b.sim {
+			return -1
+		}
+		if a.sim < b.sim {
+			return 1
+		}
+		return 0
+	})
+	out := make([]int32, 0, k)
+	for _, h := range hits[:min(k, len(hits))] {
+		out = append(out, h.id)
+	}
+	return out
+}
+
+func TestPageLines(t *testing.T) {
+	text := "--- page 1 ---\nintro\n\n--- page 2 ---\nbody\nmore"
+	if got := pageLines(text); !slices.Equal(got, []int{1, 1, 1, 2, 2, 2}) {
+		t.Errorf("pages = %v", got)
+	}
+	if got := pageLines("# notes\nno markers"); got != nil {
+		t.Errorf("unpaged = %v", got)
+	}
+}
+
+func TestHNSWRecall(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	chunks := vectorChunks(clusteredVectors(rng, 5000, 32))
+	for i := 0; i < len(chunks); i += 7 {
+		chunks[i].Source = "" // tombstones are traversed but never returned
+	}
+	g := newHNSW()
+	g.add(chunks)
+
+	const k, queries = 10, 100
+	found := 0
+	for _, q := range clusteredVectors(rng, queries, 32) {
+		want := exactNearest(chunks, q, k)
+		for _, h := range g.search(chunks, q, k, hnswEfSearch) {
+			if chunks[h.id].Source == "" {
+				t.Fatalf("tombstone %d returned", h.id)
+			}
+			if slices.Contains(want, h.id) {
+				found++
+			}
+		}
+	}
+	if recall := float64(found) / (k * queries); recall < 0.9 {
+		t.Errorf("recall@%d = %.3f", k, recall)
+	}
+}
+
+func TestIndexRoundTripUsesANN(t *testing.T) {
+	ws := t.TempDir()
+	tree := NewMemoryTree(ws)
+	rng := rand.New(rand.NewPCG(3, 4))
+	chunks := vectorChunks(clusteredVectors(rng, annMinChunks+500, 16))
+	idx := &SearchIndex{Version: indexVersion, IndexedAt: 1, Model: "m", Chunks: chunks, ANN: newHNSW()}
+	idx.ANN.add(idx.Chunks)
+	if err := tree.SaveIndex(idx); err != nil {
+		t.Fatal(err)
+	}
+	indexCache.Delete(tree.indexPath())
+	loaded, err := tree.LoadIndex()
+	if err != nil || loaded.ANN == nil || len(loaded.ANN.Links) != len(chunks) {
+		t.Fatalf("loaded = %v, %v", loaded, err)
+	}
+	if again, _ := tree.LoadIndex(); again != loaded {
+		t.Error("unchanged index decoded twice")
+	}
+	q := chunks[42].Vec
+	if got := loaded.Search(q, "", 3); len(got) == 0 || got[0].Line != 43 {
+		t.Errorf("search = %+v", got)
+	}
+}
+
+// ── Benchmarks ───────────────────────────────────────────────────────────────
+
+const (
+	benchFiles         = 1000 // daily logs
+	benchChunksPerFile = 100  // → 100k chunks
+	benchDim           = 128
+)
+
+var (
+	benchOnce sync.Once
+	benchWS   string
+	benchIdx  *SearchIndex
+	benchErr  error
+)
+
+var benchWords = strings.Fields("deploy release rollback budget review hiring onboarding design " +
+	"customer invoice contract roadmap incident outage database migration latency cache " +
+	"meeting travel vendor security audit training backlog sprint feedback pricing launch")
+
+// benchWorkspace writes a synthetic workspace of benchFiles daily logs with
+// benchChunksPerFile paragraphs each and indexes it once for all benchmarks.
+func benchWorkspace(b *testing.B) (string, *SearchIndex) {
+	benchOnce.Do(func() {
+		benchWS, benchErr = os.MkdirTemp("", "memory-bench-")
+		if benchErr != nil {
+			return
+		}
+		rng := rand.New(rand.NewPCG(5, 6))
+		day := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+		for f := range benchFiles {
+			var sb strings.Builder
+			for p := range benchChunksPerFile {
+				fmt.Fprintf(&sb, "entry %d-%d", f, p)
+				for range 8 {
+					sb.WriteString(" " + benchWords[rng.IntN(len(benchWords))])
+				}
+				sb.WriteString("\n\n")
+			}
+			writeMemFile(b, benchWS, "daily/"+day.AddDate(0, 0, f).Format("2006-01-02")+".md", sb.String())
+		}
+		start := time.Now()
+		benchIdx, _, benchErr = updateIndex(context.Background(), NewMemoryTree(benchWS).scanFiles(), nil, hashEmbedder("hash", benchDim, nil))
+		b.Logf("indexed %d chunks in %s", len(benchIdx.Chunks), time.Since(start))
+	})
+	if benchErr != nil {
+		b.Fatal(benchErr)
+	}
+	return benchWS, benchIdx
+}
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if benchWS != "" {
+		os.RemoveAll(benchWS)
+	}
+	os.Exit(code)
+}
+
+// BenchmarkSearch100k compares HNSW retrieval with the exact linear scan
+// over the same 100k chunks (both followed by decay and MMR).
+func BenchmarkSearch100k(b *testing.B) {
+	_, idx := benchWorkspace(b)
+	emb := hashEmbedder("hash", benchDim, nil)
+	q, _ := emb.embed(context.Background(), []string{"database migration incident latency"})
+	exact := &SearchIndex{Version: idx.Version, Model: idx.Model, Chunks: idx.Chunks}
+	for _, bc := range []struct {
+		name string
+		idx  *SearchIndex
+	}{{"ann", idx}, {"linear", exact}} {
+		b.Run(bc.name, func(b *testing.B) {
+			for b.Loop() {
+				bc.idx.Search(q[0], "", 10)
+			}
+		})
+	}
+}
+
+// BenchmarkUpdate100k measures an incremental update after one daily log
+// changed: one file is re-read and re-embedded, the rest only stat'ed.
+func BenchmarkUpdate100k(b *testing.B) {
+	ws, base := benchWorkspace(b)
+	tree := NewMemoryTree(ws)
+	var calls int
+	emb := hashEmbedder("hash", benchDim, &calls)
+	rel := "daily/2023-01-01.md"
+	orig, err := os.ReadFile(filepath.Join(ws, "memory", rel))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer writeMemFile(b, ws, rel, string(orig))
+	i := 0
+	for b.Loop() {
+		b.StopTimer()
+		i++
+		writeMemFile(b, ws, rel, fmt.Sprintf("%srevision %d of the deploy notes\n", orig, i))
+		b.StartTimer()
+		if _, _, err := updateIndex(context.Background(), tree.scanFiles(), base, emb); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(calls)/float64(b.N), "embedded/op")
+}
@@ -1,14 +1,24 @@
-// pkg/memory/indexer.go — Chunks memory .md files and (optionally) embeds them.
+// pkg/memory/indexer.go — Chunks memory .md files (and documents), (optionally) embeds them
+// and keeps the index up to date incrementally.
 package memory
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/Zyling-ai/zyhive/pkg/docextract"
 	"github.com/Zyling-ai/zyhive/pkg/llm"
 )
 
@@ -24,46 +34,195 @@ const (
 //
 // apiKey is the key for the embedding API call; ignored when embedder is nil.
 func BuildIndex(ctx context.Context, memTree *MemoryTree, embedder *llm.Embedder, apiKey string) (*SearchIndex, error) {
-	chunks, err := chunkAllFiles(memTree)
-	if err != nil {
-		return nil, err
+	return UpdateIndex(ctx, memTree, nil, embedder, apiKey)
+}
+
+// UpdateIndex brings old (may be nil) up to date with the files under
+// memory/: only files whose content hash changed are re-chunked and
+// re-embedded, and their chunks are added to the HNSW graph. old is not
+// modified. The whole index is rebuilt when old has another schema version
+// or was embedded by a different model than embedder.
+//
+// If embedding fails, a new index falls back to BM25-only; an update of a
+// vector index returns the error instead, so the next update retries.
+func UpdateIndex(ctx context.Context, memTree *MemoryTree, old *SearchIndex, embedder *llm.Embedder, apiKey string) (*SearchIndex, error) {
+	idx, _, err := updateIndex(ctx, memTree.scanFiles(), old, newIndexEmbedder(embedder, apiKey))
+	return idx, err
+}
+
+// indexEmbedder embeds chunk texts for an index update.
+type indexEmbedder struct {
+	model string
+	embed func(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+func newIndexEmbedder(embedder *llm.Embedder, apiKey string) *indexEmbedder {
+	if embedder == nil {
+		return nil
+	}
+	return &indexEmbedder{
+		model: embedder.Model(),
+		embed: func(ctx context.Context, texts []string) ([][]float32, error) {
+			return embedder.Embed(ctx, apiKey, texts)
+		},
 	}
+}
 
-	if embedder != nil && len(chunks) > 0 {
-		texts := make([]string, len(chunks))
-		for i, c := range chunks {
-			texts[i] = c.Text
+// IndexStats summarises one index update.
+type IndexStats struct {
+	Added, Changed, Removed int  // files
+	Embedded                int  // chunks sent to the embedding model
+	Compacted               bool // tombstones dropped and the graph rebuilt
+}
+
+// UpdateFileIndex is UpdateIndex over any set of files, e.g. from ScanDir;
+// the map keys become Chunk.Source.
+func UpdateFileIndex(ctx context.Context, files map[string]SourceFile, old *SearchIndex, embedder *llm.Embedder, apiKey string) (*SearchIndex, IndexStats, error) {
+	return updateIndex(ctx, files, old, newIndexEmbedder(embedder, apiKey))
+}
+
+func updateIndex(ctx context.Context, files map[string]SourceFile, old *SearchIndex, emb *indexEmbedder) (*SearchIndex, IndexStats, error) {
+	var st IndexStats
+	model := ""
+	if emb != nil {
+		model = emb.model
+	}
+	idx := &SearchIndex{Version: indexVersion, Model: model, Files: map[string]FileState{}}
+	reuse := old != nil && old.Version == indexVersion && old.Model == model
+	prevFiles := map[string]FileState{}
+	if reuse {
+		prevFiles = old.Files
+		idx.Chunks = slices.Clone(old.Chunks)
+		idx.ANN = old.ANN.clone()
+	}
+	slots := map[string][]int{} // source → chunk positions
+	for i, c := range idx.Chunks {
+		if c.Source != "" {
+			slots[c.Source] = append(slots[c.Source], i)
 		}
-		vecs, embedErr := batchEmbed(ctx, embedder, apiKey, texts)
-		if embedErr != nil {
-			log.Printf("[memory/index] embedding failed (falling back to BM25): %v", embedErr)
-			// 继续构建，只是没有向量
+	}
+	remove := func(source string) {
+		for _, i := range slots[source] {
+			c := &idx.Chunks[i]
+			c.Text, c.Source = "", ""
+			if idx.ANN == nil {
+				c.Vec = nil
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for rel := range files {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	var fresh []Chunk
+	for _, rel := range paths {
+		f := files[rel]
+		prev, had := prevFiles[rel]
+		if had && prev.ModTime.Equal(f.ModTime) && prev.Size == f.Size {
+			idx.Files[rel] = prev
+			continue
+		}
+		data, err := os.ReadFile(f.Abs)
+		if err != nil {
+			if had {
+				idx.Files[rel] = prev // best-effort: keep what was indexed
+			}
+			continue
+		}
+		sum := sha256.Sum256(data)
+		state := FileState{Hash: hex.EncodeToString(sum[:]), ModTime: f.ModTime, Size: f.Size}
+		idx.Files[rel] = state
+		if had && prev.Hash == state.Hash {
+			continue // touched, content unchanged
+		}
+		if had {
+			remove(rel)
+			st.Changed++
 		} else {
-			for i := range chunks {
+			st.Added++
+		}
+		fresh = append(fresh, chunkFile(data, rel, f)...)
+	}
+	for rel := range prevFiles {
+		if _, ok := files[rel]; !ok {
+			remove(rel)
+			st.Removed++
+		}
+	}
+
+	if emb != nil && len(fresh) > 0 {
+		texts := make([]string, len(fresh))
+		for i, c := range fresh {
+			texts[i] = c.Text
+		}
+		vecs, err := batchEmbed(ctx, emb, texts)
+		switch {
+		case err == nil:
+			for i := range fresh {
 				if i < len(vecs) && len(vecs[i]) > 0 {
-					chunks[i].Vec = vecs[i]
+					fresh[i].Vec = vecs[i]
 				}
 			}
+			st.Embedded = len(fresh)
+		case reuse:
+			return nil, st, fmt.Errorf("embed %d chunks: %w", len(fresh), err)
+		default:
+			log.Printf("[memory/index] embedding failed (falling back to BM25): %v", err)
+			// 继续构建，只是没有向量
+			idx.Model = ""
+			for i := range idx.Chunks {
+				idx.Chunks[i].Vec = nil
+			}
+			idx.ANN = nil
 		}
 	}
+	idx.Chunks = append(idx.Chunks, fresh...)
 
-	return &SearchIndex{
-		Version:   1,
-		IndexedAt: time.Now().UnixMilli(),
-		Chunks:    chunks,
-	}, nil
+	// Without a graph tombstones serve no purpose; with one, drop them once
+	// they are a quarter of the chunks and relink the rest.
+	dead := len(idx.Chunks) - idx.Len()
+	if dead > 0 && (idx.ANN == nil || dead*4 > len(idx.Chunks)) {
+		live := make([]Chunk, 0, len(idx.Chunks)-dead)
+		for _, c := range idx.Chunks {
+			if c.Source != "" {
+				live = append(live, c)
+			}
+		}
+		idx.Chunks = live
+		idx.ANN = nil
+		st.Compacted = true
+	}
+	if idx.Model != "" {
+		if idx.ANN == nil {
+			idx.ANN = newHNSW()
+		}
+		idx.ANN.add(idx.Chunks)
+	}
+	idx.IndexedAt = time.Now().UnixMilli()
+	return idx, st, nil
 }
 
+// rebuilding guards against concurrent background updates per index.
+var rebuilding sync.Map // index path -> struct{}
+
 // RebuildIndexIfStale checks whether the on-disk index is stale and, if so,
-// rebuilds it asynchronously in the background. Non-blocking.
+// updates it asynchronously in the background. Non-blocking.
 // embedder / apiKey may be zero-value (BM25-only mode).
 func RebuildIndexIfStale(memTree *MemoryTree, embedder *llm.Embedder, apiKey string) {
 	go func() {
+		p := memTree.indexPath()
+		if _, busy := rebuilding.LoadOrStore(p, struct{}{}); busy {
+			return
+		}
+		defer rebuilding.Delete(p)
+
 		idx, err := memTree.LoadIndex()
 		if err != nil || !memTree.IsStale(idx) {
 			return
 		}
-		newIdx, err := BuildIndex(context.Background(), memTree, embedder, apiKey)
+		newIdx, st, err := updateIndex(context.Background(), memTree.scanFiles(), idx, newIndexEmbedder(embedder, apiKey))
 		if err != nil {
 			log.Printf("[memory/index] rebuild error: %v", err)
 			return
@@ -73,46 +232,67 @@ func RebuildIndexIfStale(memTree *MemoryTree, embedder *llm.Embedder, apiKey str
 			return
 		}
 		mode := "BM25"
-		if embedder != nil && len(newIdx.Chunks) > 0 && len(newIdx.Chunks[0].Vec) > 0 {
-			mode = embedder.Model()
+		if newIdx.Model != "" {
+			mode = newIdx.Model
 		}
-		log.Printf("[memory/index] rebuilt: %d chunks, mode=%s", len(newIdx.Chunks), mode)
+		log.Printf("[memory/index] updated: %d chunks, mode=%s, files +%d ~%d -%d, embedded=%d, compacted=%v",
+			newIdx.Len(), mode, st.Added, st.Changed, st.Removed, st.Embedded, st.Compacted)
 	}()
 }
 
 // ── Internal helpers ─────────────────────────────────────────────────────────
 
-// chunkAllFiles walks memory/ and splits every .md file into paragraph chunks.
-func chunkAllFiles(memTree *MemoryTree) ([]Chunk, error) {
-	memDir := memTree.memDir()
-	if _, err := os.Stat(memDir); os.IsNotExist(err) {
-		return nil, nil
-	}
-
-	var chunks []Chunk
-	err := filepath.Walk(memDir, func(abs string, info os.FileInfo, walkErr error) error {
-		if walkErr != nil || info.IsDir() {
-			return nil
-		}
-		name := info.Name()
-		// Skip hidden files (.search_index.gob etc.) and non-markdown
-		if strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".md") {
+// chunkFile splits a markdown / text file, or the text of a PDF / DOCX /
+// PPTX / XLSX / HTML document, into paragraph chunks stamped with the
+// file's modification time for temporal decay. The format is taken from the
+// on-disk name, so rel may be any citation key (e.g. a URL).
+func chunkFile(data []byte, rel string, f SourceFile) []Chunk {
+	content := string(data)
+	name := filepath.Base(f.Abs)
+	doc := !plainText(name)
+	if doc {
+		res, err := docextract.Extract(data, name, "", docextract.Options{})
+		if err != nil {
+			log.Printf("[memory] skip %s: %v", rel, err)
 			return nil
 		}
-		rel, _ := filepath.Rel(memTree.WorkspaceDir, abs)
-		data, err := os.ReadFile(abs)
-		if err != nil {
-			return nil // best-effort
+		content = res.Text
+	}
+	chunks := splitIntoChunks(content, rel)
+	var pages []int // pages[i] = page of line i+1
+	if doc {
+		pages = pageLines(content)
+	}
+	for i := range chunks {
+		chunks[i].CreatedAt = f.ModTime
+		if n := chunks[i].Line; n <= len(pages) {
+			chunks[i].Page = pages[n-1]
 		}
-		fileChunks := splitIntoChunks(string(data), rel)
-		// Stamp each chunk with the file's modification time for temporal decay.
-		for i := range fileChunks {
-			fileChunks[i].CreatedAt = info.ModTime()
+	}
+	return chunks
+}
+
+// pageMarker matches the "--- page N ---" / "--- slide N ---" separators
+// docextract puts between PDF pages and PPTX slides.
+var pageMarker = regexp.MustCompile(`^--- (?:page|slide) (\d+) ---$`)
+
+// pageLines maps each line of extracted text to the page it is on, or nil
+// when the text has no page markers.
+func pageLines(content string) []int {
+	lines := strings.Split(content, "\n")
+	pages := make([]int, len(lines))
+	page, found := 0, false
+	for i, line := range lines {
+		if m := pageMarker.FindStringSubmatch(line); m != nil {
+			page, _ = strconv.Atoi(m[1])
+			found = true
 		}
-		chunks = append(chunks, fileChunks...)
+		pages[i] = page
+	}
+	if !found {
 		return nil
-	})
-	return chunks, err
+	}
+	return pages
 }
 
 // splitIntoChunks splits file content into paragraph-sized chunks.
@@ -165,14 +345,14 @@ func splitIntoChunks(content, source string) []Chunk {
 }
 
 // batchEmbed calls the embedding API in batches of embedBatchSize.
-func batchEmbed(ctx context.Context, embedder *llm.Embedder, apiKey string, texts []string) ([][]float32, error) {
+func batchEmbed(ctx context.Context, emb *indexEmbedder, texts []string) ([][]float32, error) {
 	all := make([][]float32, len(texts))
 	for start := 0; start < len(texts); start += embedBatchSize {
 		end := start + embedBatchSize
 		if end > len(texts) {
 			end = len(texts)
 		}
-		vecs, err := embedder.Embed(ctx, apiKey, texts[start:end])
+		vecs, err := emb.embed(ctx, texts[start:end])
 		if err != nil {
 			return nil, err
 		}
@@ -182,3 +362,14 @@ func batchEmbed(ctx context.Context, embedder *llm.Embedder, apiKey string, text
 	}
 	return all, nil
 }
+
+// indexable reports whether a file goes into a search index: markdown,
+// plain text, or a document docextract can turn into text.
+func indexable(name string) bool {
+	return plainText(name) || docextract.FormatForName(name) != ""
+}
+
+// plainText reports whether a file is indexed as is, without extraction.
+func plainText(name string) bool {
+	return strings.HasSuffix(name, ".md") || strings.HasSuffix(name, ".txt")
+}
@@ -2,22 +2,35 @@
 package memory
 
 import (
+	"bytes"
 	"encoding/gob"
+	"io/fs"
 	"math"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/persist"
 )
 
-const searchIndexFile = ".search_index.gob"
+const (
+	searchIndexFile = ".search_index.gob"
+	indexVersion    = 3
+
+	// annMinChunks is the size below which vector search scans every chunk
+	// exactly; the HNSW graph pays off only beyond it.
+	annMinChunks = 2000
+)
 
 // Chunk is a single indexed memory fragment.
 type Chunk struct {
 	Text      string    // 段落原文
-	Source    string    // 相对于 workspace 的路径，如 "memory/core/knowledge.md"
-	Line      int       // 在源文件中的起始行号（1-indexed）
+	Source    string    // 相对于 workspace 的路径，如 "memory/core/knowledge.md"；空 = 已删除（墓碑）
+	Line      int       // 在源文件中的起始行号（1-indexed）；文档为提取文本中的行号
+	Page      int       // PDF 页码 / PPTX 幻灯片序号（1-indexed）；0 = 无分页
 	Vec       []float32 // embedding 向量；nil = 仅 BM25 模式
 	CreatedAt time.Time // 来源文件的修改时间；零值表示未知
 }
@@ -30,10 +43,47 @@ type SearchResult struct {
 }
 
 // SearchIndex holds all indexed chunks for one agent workspace.
+//
+// It is updated incrementally (UpdateIndex): Files records the content
+// hash of every source file, so only changed files are re-chunked and
+// re-embedded. Chunks of changed or removed files stay behind as
+// tombstones (empty Source) because ANN refers to chunks by position; they
+// are dropped, and the graph rebuilt, once they exceed a quarter of Chunks.
 type SearchIndex struct {
-	Version   int     // schema 版本，当前 = 1
-	IndexedAt int64   // unix ms，用于 stale 判断
+	Version   int                  // schema 版本，当前 = indexVersion
+	IndexedAt int64                // unix ms
+	Model     string               // Vec 的 embedding 模型；"" = 仅 BM25
+	Files     map[string]FileState // 按 Chunk.Source 记录已索引的文件
 	Chunks    []Chunk
+	ANN       *HNSW // Chunks 向量上的近邻图；nil = 无向量
+}
+
+// FileState records the indexed version of one source file.
+type FileState struct {
+	Hash    string // 文件内容 sha256（hex）
+	ModTime time.Time
+	Size    int64
+}
+
+// Len returns the number of live (non-tombstone) chunks.
+func (idx *SearchIndex) Len() int {
+	n := 0
+	for _, c := range idx.Chunks {
+		if c.Source != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// HasVectors reports whether the chunks carry embeddings.
+func (idx *SearchIndex) HasVectors() bool {
+	for _, c := range idx.Chunks {
+		if c.Source != "" {
+			return len(c.Vec) > 0
+		}
+	}
+	return false
 }
 
 // indexPath returns the absolute path to the search index gob file.
@@ -43,67 +93,142 @@ func (m *MemoryTree) indexPath() string {
 
 // LoadIndex loads the search index from disk.
 // Returns an empty (non-nil) index if the file doesn't exist or is corrupt.
+// The result is shared with other callers and must not be modified.
 func (m *MemoryTree) LoadIndex() (*SearchIndex, error) {
-	f, err := os.Open(m.indexPath())
+	return LoadIndexFile(m.indexPath())
+}
+
+// SaveIndex writes the index to disk (memory/.search_index.gob).
+func (m *MemoryTree) SaveIndex(idx *SearchIndex) error {
+	return SaveIndexFile(m.indexPath(), idx)
+}
+
+// IsStale returns true when any indexable file (markdown or document)
+// under memory/ was added, removed or modified since the index was built.
+func (m *MemoryTree) IsStale(idx *SearchIndex) bool {
+	return idx.StaleFor(m.scanFiles())
+}
+
+// indexCache keeps decoded indexes by path, valid while the file's size
+// and mtime are unchanged, so searches don't decode a large index each
+// time. Cached indexes are shared: callers must not modify them.
+var indexCache sync.Map // path -> cachedIndex
+
+type cachedIndex struct {
+	modTime time.Time
+	size    int64
+	idx     *SearchIndex
+}
+
+// LoadIndexFile loads a search index saved by SaveIndexFile.
+// Returns an empty (non-nil) index if the file doesn't exist or is corrupt.
+// The result is shared with other callers and must not be modified.
+func LoadIndexFile(p string) (*SearchIndex, error) {
+	fi, err := os.Stat(p)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return &SearchIndex{}, nil
 		}
 		return nil, err
 	}
+	if v, ok := indexCache.Load(p); ok {
+		if c := v.(cachedIndex); c.modTime.Equal(fi.ModTime()) && c.size == fi.Size() {
+			return c.idx, nil
+		}
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, err
+	}
 	defer f.Close()
 	var idx SearchIndex
 	if err := gob.NewDecoder(f).Decode(&idx); err != nil {
 		return &SearchIndex{}, nil // 损坏 → 当空处理
 	}
+	indexCache.Store(p, cachedIndex{fi.ModTime(), fi.Size(), &idx})
 	return &idx, nil
 }
 
-// SaveIndex writes the index to disk (memory/.search_index.gob).
-func (m *MemoryTree) SaveIndex(idx *SearchIndex) error {
-	p := m.indexPath()
+// SaveIndexFile writes the index to p atomically.
+func SaveIndexFile(p string, idx *SearchIndex) error {
 	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
 		return err
 	}
-	f, err := os.Create(p)
-	if err != nil {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(idx); err != nil {
 		return err
 	}
-	defer f.Close()
-	return gob.NewEncoder(f).Encode(idx)
+	if err := persist.AtomicWrite(p, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(p); err == nil {
+		indexCache.Store(p, cachedIndex{fi.ModTime(), fi.Size(), idx})
+	}
+	return nil
 }
 
-// IsStale returns true when the index is older than any .md file under memory/.
-func (m *MemoryTree) IsStale(idx *SearchIndex) bool {
-	if idx == nil || idx.IndexedAt == 0 {
+// StaleFor reports whether files (see ScanDir) were added, removed or
+// modified since the index was built from them.
+func (idx *SearchIndex) StaleFor(files map[string]SourceFile) bool {
+	if idx == nil || idx.Version != indexVersion || idx.IndexedAt == 0 {
 		return true
 	}
-	return m.anyNewerThan(m.memDir(), time.UnixMilli(idx.IndexedAt))
+	if len(files) != len(idx.Files) {
+		return true
+	}
+	for rel, f := range files {
+		prev, ok := idx.Files[rel]
+		if !ok || !prev.ModTime.Equal(f.ModTime) || prev.Size != f.Size {
+			return true
+		}
+	}
+	return false
 }
 
-func (m *MemoryTree) anyNewerThan(dir string, t time.Time) bool {
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return false
-	}
-	for _, e := range entries {
-		name := e.Name()
-		if name == searchIndexFile {
-			continue
+// SourceFile is an indexable file on disk.
+type SourceFile struct {
+	Abs     string
+	ModTime time.Time
+	Size    int64
+}
+
+// scanFiles lists the indexable files under memory/ by workspace-relative
+// path. Hidden files (.search_index.gob etc.) are skipped.
+func (m *MemoryTree) scanFiles() map[string]SourceFile {
+	return ScanDir(m.memDir(), "memory")
+}
+
+// ScanDir lists the indexable files (markdown, plain text, documents) under
+// dir, keyed by prefix joined with the path relative to dir; the keys become
+// Chunk.Source. Hidden files and directories are skipped.
+func ScanDir(dir, prefix string) map[string]SourceFile {
+	files := map[string]SourceFile{}
+	_ = filepath.WalkDir(dir, func(abs string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return nil
 		}
-		abs := filepath.Join(dir, name)
-		if e.IsDir() {
-			if m.anyNewerThan(abs, t) {
-				return true
-			}
-		} else if strings.HasSuffix(name, ".md") {
-			fi, err := e.Info()
-			if err == nil && fi.ModTime().After(t) {
-				return true
+		name := d.Name()
+		if d.IsDir() {
+			if abs != dir && strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
 			}
+			return nil
 		}
-	}
-	return false
+		if strings.HasPrefix(name, ".") || !d.Type().IsRegular() || !indexable(name) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, abs)
+		if err != nil {
+			return nil
+		}
+		files[filepath.Join(prefix, rel)] = SourceFile{abs, info.ModTime(), info.Size()}
+		return nil
+	})
+	return files
 }
 
 // Search returns the top-K most relevant chunks for the given query.
@@ -113,10 +238,26 @@ func (m *MemoryTree) anyNewerThan(dir string, t time.Time) bool {
 //  2. Apply temporal decay (score *= exp(-ln2 * age_days / halfLifeDays)).
 //  3. MMR re-ranking to reduce redundancy and improve diversity.
 //
-// If chunks have Vec and queryVec is non-nil → cosine similarity.
+// If chunks have Vec and queryVec is non-nil → cosine similarity, through
+// the HNSW graph once the index has more than annMinChunks chunks.
 // Otherwise → BM25 keyword scoring (Chinese + English both supported).
 func (idx *SearchIndex) Search(queryVec []float32, query string, topK int) []Chunk {
-	if len(idx.Chunks) == 0 {
+	reranked := idx.Rank(queryVec, query, topK, 30)
+	if len(reranked) == 0 {
+		return nil
+	}
+	result := make([]Chunk, 0, len(reranked))
+	for _, r := range reranked {
+		result = append(result, r.Chunk)
+	}
+	return result
+}
+
+// Rank is Search with the retrieval scores kept, for callers that merge
+// results of several indexes. halfLifeDays <= 0 disables temporal decay
+// (reference documents don't get less relevant with age).
+func (idx *SearchIndex) Rank(queryVec []float32, query string, topK int, halfLifeDays float64) []SearchResult {
+	if idx.Len() == 0 {
 		return nil
 	}
 	if topK <= 0 {
@@ -130,53 +271,61 @@ func (idx *SearchIndex) Search(queryVec []float32, query string, topK int) []Chu
 	}
 
 	// Step 2: temporal decay
-	candidates = ApplyTemporalDecay(candidates, 30)
+	if halfLifeDays > 0 {
+		candidates = ApplyTemporalDecay(candidates, halfLifeDays)
+	}
 
 	// Step 3: MMR re-ranking
-	reranked := MMR(queryVec, candidates, 0.7, topK)
-
-	result := make([]Chunk, 0, len(reranked))
-	for _, r := range reranked {
-		result = append(result, r.Chunk)
-	}
-	return result
+	return MMR(queryVec, candidates, 0.7, topK)
 }
 
 // retrieveCandidates returns up to candidateK scored results using cosine/BM25.
 func (idx *SearchIndex) retrieveCandidates(queryVec []float32, query string, candidateK int) []SearchResult {
 	type scored = SearchResult
 
-	// Decide mode: use vectors if available
-	hasVec := len(idx.Chunks) > 0 && len(idx.Chunks[0].Vec) > 0
-
 	var scores []scored
 
-	if hasVec && queryVec != nil {
-		// ── Cosine similarity ───────────────────────────────────────────
-		for _, c := range idx.Chunks {
-			if len(c.Vec) == 0 {
-				continue
+	if idx.HasVectors() && queryVec != nil {
+		if idx.ANN != nil && len(idx.Chunks) > annMinChunks {
+			// ── Approximate nearest neighbours ──────────────────────────────
+			ef := max(hnswEfSearch, 2*candidateK)
+			for _, h := range idx.ANN.search(idx.Chunks, queryVec, candidateK, ef) {
+				c := idx.Chunks[h.id]
+				scores = append(scores, scored{c, cosineSim(queryVec, c.Vec)})
+			}
+		} else {
+			// ── Cosine similarity ───────────────────────────────────────────
+			for _, c := range idx.Chunks {
+				if c.Source == "" || len(c.Vec) == 0 {
+					continue
+				}
+				scores = append(scores, scored{c, cosineSim(queryVec, c.Vec)})
 			}
-			scores = append(scores, scored{c, cosineSim(queryVec, c.Vec)})
 		}
 	} else {
 		// ── BM25 keyword scoring ─────────────────────────────────────────
+		live := make([]Chunk, 0, len(idx.Chunks))
+		for _, c := range idx.Chunks {
+			if c.Source != "" {
+				live = append(live, c)
+			}
+		}
 		terms := tokenize(query)
 		if len(terms) == 0 {
-			n := min(candidateK, len(idx.Chunks))
+			n := min(candidateK, len(live))
 			out := make([]SearchResult, n)
 			for i := 0; i < n; i++ {
-				out[i] = SearchResult{idx.Chunks[i], 1.0}
+				out[i] = SearchResult{live[i], 1.0}
 			}
 			return out
 		}
 
-		N := float64(len(idx.Chunks))
+		N := float64(len(live))
 		// Precompute IDF per term
 		idf := make(map[string]float64, len(terms))
 		for _, term := range terms {
 			df := 0
-			for _, c := range idx.Chunks {
+			for _, c := range live {
 				if strings.Contains(strings.ToLower(c.Text), term) {
 					df++
 				}
@@ -189,12 +338,12 @@ func (idx *SearchIndex) retrieveCandidates(queryVec []float32, query string, can
 		k1, b := 1.5, 0.75 // BM25 params
 		// Estimate average doc length
 		totalWords := 0
-		for _, c := range idx.Chunks {
+		for _, c := range live {
 			totalWords += len(strings.Fields(c.Text))
 		}
 		avgdl := float64(totalWords) / N
 
-		for _, c := range idx.Chunks {
+		for _, c := range live {
 			lower := strings.ToLower(c.Text)
 			dl := float64(len(strings.Fields(c.Text)))
 			score := 0.0
@@ -24,6 +24,12 @@ package network
 const (
 	SourceFeishu   = "feishu"
 	SourceTelegram = "telegram"
+	SourceSlack    = "slack"
+	SourceDiscord  = "discord"
+	SourceEmail    = "email"
+	SourceDingTalk = "dingtalk"
+	SourceWeCom    = "wecom"
+	SourceWebhook  = "webhook"
 	SourceWeb      = "web"
 	SourcePanel    = "panel"
 	SourceCron     = "cron"
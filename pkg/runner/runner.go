@@ -16,14 +16,17 @@ import (
 	"log"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/logging"
 	"github.com/Zyling-ai/zyhive/pkg/session"
 	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
 	"github.com/Zyling-ai/zyhive/pkg/tools"
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
 )
 
 // Config holds all dependencies for a Runner instance.
@@ -52,8 +55,9 @@ type Config struct {
 	ParentSessionID string
 	// Optional: provider name for usage recording (e.g. "anthropic", "openai").
 	Provider string
-	// Optional: called after each LLM turn with token usage data.
-	UsageRecorder func(inputTokens, outputTokens int, provider, model, agentID, sessionID string)
+	// Optional: called after each LLM turn with token usage data (cache
+	// token counts and any provider-reported cost included).
+	UsageRecorder func(u llm.Usage, provider, model, agentID, sessionID string)
 
 	// Optional: pre-flight budget check. When non-nil, called once at the very
 	// start of Run; if it returns Allowed=false the runner emits an error event
@@ -61,6 +65,21 @@ type Config struct {
 	// to the system prompt as a soft warning.
 	BudgetCheck func(agentID string) BudgetCheckResult
 
+	// Optional: session token estimate at which maybeCompactSync compacts,
+	// normally session.CompactionThresholdFor(the model's context window).
+	// 0 = session.CompactionThreshold.
+	CompactionThreshold int
+
+	// Optional: per-turn retrieval (knowledge-base auto-retrieve). Called once
+	// per Run with the user message; a non-empty result is appended to the
+	// system prompt after ExtraContext for this turn only.
+	Retrieve func(ctx context.Context, query string) string
+
+	// Optional: called on every main-loop ChatRequest just before it is sent.
+	// Used to apply the model / agent generation defaults (temperature,
+	// thinking budget, …) without the runner knowing about config.
+	PrepareRequest func(req *llm.ChatRequest)
+
 	// Optional: pre-formatted capabilities block (tool health + wishlist summary)
 	// injected into the system prompt so the AI has accurate self-awareness about
 	// what it can / cannot do. Built by internal/api or pkg/agent using
@@ -139,7 +158,10 @@ type Runner struct {
 // If cfg.SessionID is set, history is loaded from the session store.
 // Otherwise, cfg.PreloadedHistory is used (legacy client-side history).
 func New(cfg Config) *Runner {
-	if cfg.LLM != nil {
+	// A FailoverClient already wraps each of its targets in throttle + retry
+	// keyed by that target's provider; wrapping it again would throttle the
+	// whole chain under the primary's key and retry across all fallbacks.
+	if _, isFailover := cfg.LLM.(*llm.FailoverClient); cfg.LLM != nil && !isFailover {
 		// P1-03: Throttle goes BENEATH retry — retry calls into the throttled
 		// client repeatedly, so each retry is gated independently. Skipped
 		// when no global throttle is installed (preserves today's behaviour).
@@ -153,6 +175,11 @@ func New(cfg Config) *Runner {
 			cfg.LLM = llm.WithRetry(cfg.LLM)
 		}
 	}
+	// Count this session's context with the run model's tokenizer, so the
+	// compaction trigger matches what the model actually sees.
+	if cfg.Session != nil {
+		cfg.Session = cfg.Session.WithModel(cfg.Provider, cfg.Model)
+	}
 	r := &Runner{cfg: cfg}
 
 	// Load server-side session history (preferred)
@@ -188,6 +215,7 @@ func New(cfg Config) *Runner {
 type RunEvent struct {
 	Type string // "text_delta" | "tool_call" | "tool_result" | "usage" | "error" | "done"
 	//                     | "compaction_start" | "compaction_end"  (P0.6)
+	//                     | "model_switch"
 	Text     string
 	ToolCall *llm.ToolCall
 	Error    error
@@ -203,6 +231,8 @@ type RunEvent struct {
 	// Compaction event extras (P0.6)
 	CompactionTokensBefore int `json:",omitempty"`
 	CompactionTokensAfter  int `json:",omitempty"`
+	// model_switch extras: the fallback chain moved to another model
+	ModelSwitch *llm.ModelSwitch `json:",omitempty"`
 }
 
 // Run processes one user message and streams events until the model stops.
@@ -447,7 +477,17 @@ func stripToolResultBlocks(raw json.RawMessage) json.RawMessage {
 	return out
 }
 
-func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) error {
+func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) (runErr error) {
+	// Run span: parent of every llm / tool span of this turn. Agent and
+	// session are bound to ctx so child spans (and log lines) inherit them.
+	ctx = logging.WithSession(logging.WithAgent(ctx, r.cfg.AgentID), r.cfg.SessionID)
+	ctx, runSpan := tracing.Start(ctx, tracing.KindRun, "run "+r.cfg.AgentID)
+	runSpan.SetModel(r.cfg.Provider, r.cfg.Model)
+	if r.cfg.ParentSessionID != "" {
+		runSpan.SetAttr("parent_session_id", r.cfg.ParentSessionID)
+	}
+	defer func() { runSpan.End(runErr) }()
+
 	// P1-02: Pre-flight budget check. When the operator has enabled budget
 	// enforcement and this turn would breach the cap, we abort before doing
 	// any LLM I/O — saves real money and keeps the failure mode obvious.
@@ -464,9 +504,9 @@ func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) e
 	// If this session already crossed the threshold, we run compaction first
 	// and stream start/end events so the user sees "压缩历史上下文中…" rather
 	// than a mysterious long pause.
-	// Note: the session store's CompactionThreshold (50k) is per-session; this
-	// fires early enough that the next LLM call won't blow the model's
-	// context window.
+	// The threshold scales with the model's context window (capped at the
+	// session store's CompactionThreshold), so small-window models compact
+	// before the next LLM call would overflow them.
 	if r.cfg.SessionID != "" && r.cfg.Session != nil {
 		r.maybeCompactSync(ctx, out)
 	}
@@ -558,6 +598,11 @@ func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) e
 	if r.cfg.ExtraContext != "" {
 		systemPrompt = systemPrompt + "\n\n---\n" + r.cfg.ExtraContext
 	}
+	if r.cfg.Retrieve != nil {
+		if passages := r.cfg.Retrieve(ctx, userMsg); passages != "" {
+			systemPrompt = systemPrompt + "\n\n---\n" + passages
+		}
+	}
 	// P1-02: Soft warning when the agent is past the budget warn threshold.
 	// Injected as the LAST block so it sits closer to the model attention.
 	if budgetWarn != "" {
@@ -598,6 +643,9 @@ func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) e
 	const maxIter = 30
 	var totalInputToks, totalOutputToks int
 	planningContinuations := 0 // track how many auto-continuations we've injected
+	// Model that is actually answering. Starts as the configured one and moves
+	// when a FailoverClient switches; usage is recorded against it.
+	callProvider, callModel := r.cfg.Provider, r.cfg.Model
 	for i := 0; i < maxIter; i++ {
 		// Sanitize history before each API call to catch any orphaned tool_use/tool_result
 		// blocks that may have accumulated from previous runs, interruptions, or continuation injections.
@@ -614,9 +662,16 @@ func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) e
 			Messages: r.history,
 			Tools:    toolDefs,
 		}
+		if r.cfg.PrepareRequest != nil {
+			r.cfg.PrepareRequest(req)
+		}
 
-		events, err := r.cfg.LLM.Stream(ctx, req)
+		llmCtx, llmSpan := tracing.Start(ctx, tracing.KindLLM, "chat "+req.Model)
+		llmSpan.SetModel(callProvider, callModel)
+		llmSpan.SetAttr("iteration", strconv.Itoa(i))
+		events, err := r.cfg.LLM.Stream(llmCtx, req)
 		if err != nil {
+			llmSpan.End(err)
 			return fmt.Errorf("llm stream: %w", err)
 		}
 
@@ -626,10 +681,20 @@ func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) e
 			stopReason     string
 			turnInputToks  int
 			turnOutputToks int
+			turnUsage      llm.Usage
 		)
 
 		for ev := range events {
 			switch ev.Type {
+			case llm.EventModelSwitch:
+				if ev.Switch != nil {
+					callProvider, callModel = ev.Switch.ToProvider, ev.Switch.ToModel
+					out <- RunEvent{
+						Type:        "model_switch",
+						Text:        fmt.Sprintf("%s 暂不可用，已切换到 %s", ev.Switch.FromModel, ev.Switch.ToModel),
+						ModelSwitch: ev.Switch,
+					}
+				}
 			case llm.EventThinkingDelta:
 				out <- RunEvent{Type: "thinking_delta", Text: ev.Text}
 			case llm.EventTextDelta:
@@ -644,6 +709,10 @@ func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) e
 				if ev.Usage != nil {
 					turnInputToks += ev.Usage.InputTokens
 					turnOutputToks += ev.Usage.OutputTokens
+					turnUsage.CacheReadTokens += ev.Usage.CacheReadTokens
+					turnUsage.CacheWriteTokens += ev.Usage.CacheWriteTokens
+					turnUsage.ReasoningTokens += ev.Usage.ReasoningTokens
+					turnUsage.Cost += ev.Usage.Cost
 					// Forward accumulated usage to the SSE stream so the UI can show token counts
 					if turnInputToks > 0 || turnOutputToks > 0 {
 						out <- RunEvent{
@@ -656,14 +725,24 @@ func (r *Runner) run(ctx context.Context, userMsg string, out chan<- RunEvent) e
 			case llm.EventStop:
 				stopReason = ev.StopReason
 			case llm.EventError:
+				llmSpan.End(ev.Err)
 				return ev.Err
 			}
 		}
+		llmSpan.SetModel(callProvider, callModel)
+		llmSpan.AddUsage(turnInputToks, turnOutputToks)
+		llmSpan.SetAttr("stop_reason", stopReason)
+		if len(toolCalls) > 0 {
+			llmSpan.SetAttr("tool_calls", strconv.Itoa(len(toolCalls)))
+		}
+		llmSpan.End(ctx.Err())
+		runSpan.AddUsage(turnInputToks, turnOutputToks)
 		// Accumulate total tokens and record usage after each LLM turn
 		totalInputToks += turnInputToks
 		totalOutputToks += turnOutputToks
 		if r.cfg.UsageRecorder != nil && (turnInputToks+turnOutputToks) > 0 {
-			r.cfg.UsageRecorder(turnInputToks, turnOutputToks, r.cfg.Provider, r.cfg.Model, r.cfg.AgentID, r.cfg.SessionID)
+			turnUsage.InputTokens, turnUsage.OutputTokens = turnInputToks, turnOutputToks
+			r.cfg.UsageRecorder(turnUsage, callProvider, callModel, r.cfg.AgentID, r.cfg.SessionID)
 		}
 
 		// 3. Append assistant turn to history
@@ -795,9 +874,13 @@ func (r *Runner) executeTools(ctx context.Context, calls []llm.ToolCall, out cha
 		wg.Add(1)
 		go func(i int, tc llm.ToolCall) {
 			defer wg.Done()
+			toolCtx, span := tracing.Start(ctx, tracing.KindTool, "tool "+tc.Name)
+			span.SetTool(tc.Name)
+			span.SetAttr("tool_call_id", tc.ID)
 			start := time.Now()
-			result, err := r.cfg.Tools.Execute(ctx, tc.Name, tc.Input)
+			result, err := r.cfg.Tools.Execute(tools.WithCallID(toolCtx, tc.ID), tc.Name, tc.Input)
 			dur := time.Since(start)
+			span.End(err)
 			origErr := err
 			if err != nil {
 				// Combine any partial output with the error so the LLM
@@ -943,7 +1026,7 @@ func buildAssistantContent(text string, toolCalls []llm.ToolCall) json.RawMessag
 }
 
 // maybeCompactSync runs compaction synchronously BEFORE the current turn if
-// the session has crossed CompactionThreshold. Emits compaction_start /
+// the session has crossed Config.CompactionThreshold. Emits compaction_start /
 // compaction_end events so the user sees "压缩历史上下文中…" instead of an
 // unexplained long pause.
 //
@@ -955,7 +1038,11 @@ func (r *Runner) maybeCompactSync(ctx context.Context, out chan<- RunEvent) {
 		return
 	}
 	tokensBefore := r.cfg.Session.EstimateTokens(r.cfg.SessionID)
-	if tokensBefore < session.CompactionThreshold {
+	threshold := r.cfg.CompactionThreshold
+	if threshold <= 0 {
+		threshold = session.CompactionThreshold
+	}
+	if tokensBefore < threshold {
 		return
 	}
 
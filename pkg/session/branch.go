@@ -0,0 +1,502 @@
+// pkg/session/branch.go — conversation tree: fork, rewind, restore.
+//
+// A session file stays a linear JSONL log. Rewind does not delete: it marks
+// the messages after a point with RewoundAt so every reader skips them, and
+// Restore clears the mark as long as the session has not moved on. Fork
+// copies the visible path up to a message (or a hidden rewind branch) into
+// a new session whose header and index entry point back at the parent.
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+	"github.com/Zyling-ai/zyhive/pkg/tokenizer"
+)
+
+var (
+	ErrMessageNotFound    = errors.New("message not found")
+	ErrRewindNotFound     = errors.New("rewind not found")
+	ErrInvalidRewindPoint = errors.New("invalid rewind point")
+	ErrRewindConflict     = errors.New("session has new messages since the rewind; fork the rewound branch instead")
+)
+
+// sessionLine is one parsed JSONL line.
+type sessionLine struct {
+	raw []byte
+	typ EntryType
+	msg MessageEntry // EntryTypeMessage only
+	id  string       // message ID, see assignMessageIDs
+}
+
+func (l sessionLine) hidden() bool { return l.typ == EntryTypeMessage && l.msg.RewoundAt != 0 }
+
+// newMessageID returns a random ID for a new message entry.
+func newMessageID() string {
+	var b [6]byte
+	_, _ = rand.Read(b[:])
+	return "m-" + hex.EncodeToString(b[:])
+}
+
+// assignMessageIDs fills l.id: the entry's own ID, or for messages written
+// before IDs existed "t{timestamp}" (suffixed -2, -3 … on collisions).
+// Derived IDs survive compaction and forks because timestamps are kept.
+func assignMessageIDs(lines []sessionLine) {
+	seen := map[string]int{}
+	for i := range lines {
+		if lines[i].typ != EntryTypeMessage {
+			continue
+		}
+		id := lines[i].msg.ID
+		if id == "" {
+			id = "t" + strconv.FormatInt(lines[i].msg.Timestamp, 10)
+		}
+		seen[id]++
+		if n := seen[id]; n > 1 {
+			id += "-" + strconv.Itoa(n)
+		}
+		lines[i].id = id
+	}
+}
+
+func parseSessionLines(raws [][]byte) []sessionLine {
+	lines := make([]sessionLine, 0, len(raws))
+	for _, raw := range raws {
+		var base BaseEntry
+		if len(raw) == 0 || json.Unmarshal(raw, &base) != nil {
+			continue
+		}
+		l := sessionLine{raw: raw, typ: base.Type}
+		if base.Type == EntryTypeMessage && json.Unmarshal(raw, &l.msg) != nil {
+			continue
+		}
+		lines = append(lines, l)
+	}
+	assignMessageIDs(lines)
+	return lines
+}
+
+func readSessionLines(path string) ([]sessionLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var raws [][]byte
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 8*1024*1024), 8*1024*1024)
+	for scanner.Scan() {
+		raws = append(raws, append([]byte(nil), scanner.Bytes()...))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return parseSessionLines(raws), nil
+}
+
+// MessageIDs returns the message ID of each raw JSONL line as returned by
+// ReadAll ("" for non-message lines), for API callers that render entries.
+func MessageIDs(raws []json.RawMessage) []string {
+	ids := make([]string, len(raws))
+	lines := make([]sessionLine, len(raws))
+	for i, raw := range raws {
+		var base BaseEntry
+		if json.Unmarshal(raw, &base) != nil {
+			continue
+		}
+		lines[i].typ = base.Type
+		if base.Type == EntryTypeMessage && json.Unmarshal(raw, &lines[i].msg) != nil {
+			lines[i].typ = ""
+		}
+	}
+	assignMessageIDs(lines)
+	for i := range lines {
+		ids[i] = lines[i].id
+	}
+	return ids
+}
+
+func findMessage(lines []sessionLine, messageID string) int {
+	for i, l := range lines {
+		if l.typ == EntryTypeMessage && l.id == messageID {
+			return i
+		}
+	}
+	return -1
+}
+
+// withToolResults extends cut point p past the tool_result messages that
+// answer a tool_use in message p, so a fork or rewind never leaves an
+// assistant tool call without its results. on selects the lines that
+// belong to the path being cut.
+func withToolResults(lines []sessionLine, p int, on func(sessionLine) bool) int {
+	if len(extractToolUseIDsFromContent(lines[p].msg.Message.Content)) == 0 {
+		return p
+	}
+	end := p
+	for i := p + 1; i < len(lines); i++ {
+		if lines[i].typ != EntryTypeMessage || !on(lines[i]) {
+			continue
+		}
+		if lines[i].msg.Message.Role != "user" || len(extractToolResultIDsFromContent(lines[i].msg.Message.Content)) == 0 {
+			break
+		}
+		end = i
+	}
+	return end
+}
+
+// marshal re-encodes a message line after its entry changed.
+func (l *sessionLine) marshal() error {
+	raw, err := json.Marshal(l.msg)
+	if err != nil {
+		return err
+	}
+	l.raw = raw
+	return nil
+}
+
+func joinLines(lines []sessionLine) []byte {
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.Write(l.raw)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// visibleStats counts the messages readers see (after the last compaction).
+func visibleStats(tk tokenizer.Tokenizer, lines []sessionLine) (count, tokens int) {
+	for _, l := range lines {
+		switch {
+		case l.typ == EntryTypeCompaction:
+			count, tokens = 0, 0
+			var ce CompactionEntry
+			if json.Unmarshal(l.raw, &ce) == nil {
+				tokens = tk.Count(ce.Summary) + 500
+			}
+		case l.typ == EntryTypeMessage && !l.hidden():
+			count++
+			tokens += estimateTokensRaw(tk, l.msg.Message.Content)
+		}
+	}
+	return count, tokens
+}
+
+func mustJSON(v any) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// Fork copies sessionID up to and including messageID into a new session
+// and returns the new session ID. Forking at a rewound message recovers
+// that hidden branch: the copy is the visible path before it plus the
+// rewound messages up to it. The latest compaction summary before the fork
+// point is carried over, so the branch keeps the same earlier context.
+func (s *Store) Fork(sessionID, messageID string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockStore()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	path, err := s.sessionPath(sessionID)
+	if err != nil {
+		return "", err
+	}
+	lines, err := readSessionLines(path)
+	if err != nil {
+		return "", err
+	}
+	p := findMessage(lines, messageID)
+	if p < 0 {
+		return "", fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+	}
+	group := lines[p].msg.RewoundAt
+	onPath := func(l sessionLine) bool { return l.msg.RewoundAt == 0 || l.msg.RewoundAt == group }
+	p = withToolResults(lines, p, onPath)
+
+	idx, err := s.loadIndex()
+	if err != nil {
+		return "", err
+	}
+	parent := idx.Sessions[sessionID]
+	header := SessionHeader{
+		BaseEntry:     BaseEntry{Type: EntryTypeSession},
+		Version:       CurrentVersion,
+		AgentID:       parent.AgentID,
+		CreatedAt:     nowMs(),
+		ParentSession: sessionID,
+		ForkedFrom:    messageID,
+	}
+	for _, l := range lines {
+		if l.typ == EntryTypeSession {
+			var h SessionHeader
+			if json.Unmarshal(l.raw, &h) == nil && h.AgentID != "" {
+				header.AgentID = h.AgentID
+			}
+			break
+		}
+	}
+	out := []sessionLine{{raw: mustJSON(header), typ: EntryTypeSession}}
+	for _, l := range lines[:p+1] {
+		switch {
+		case l.typ == EntryTypeCompaction:
+			out = append(out, l)
+		case l.typ == EntryTypeMessage && onPath(l):
+			l.msg.ID = l.id
+			l.msg.RewoundAt, l.msg.RewoundAfter = 0, ""
+			if err := l.marshal(); err != nil {
+				return "", err
+			}
+			out = append(out, l)
+		}
+	}
+
+	title := parent.Title
+	if title != "" {
+		title = truncateRune(title, 26) + "（分支）"
+	}
+	return s.writeNewSession(idx, out, SessionIndexEntry{
+		AgentID:    header.AgentID,
+		Title:      title,
+		ParentID:   sessionID,
+		ForkedFrom: messageID,
+	})
+}
+
+// writeNewSession stores lines (header first) under a fresh ses-<ms> ID
+// and indexes it with meta's descriptive fields. Called with s.mu and the
+// store lock held.
+func (s *Store) writeNewSession(idx *SessionIndex, lines []sessionLine, meta SessionIndexEntry) (string, error) {
+	newID := fmt.Sprintf("ses-%d", nowMs())
+	for n := 2; ; n++ {
+		if _, taken := idx.Sessions[newID]; !taken {
+			break
+		}
+		newID = fmt.Sprintf("ses-%d-%d", nowMs(), n)
+	}
+	newPath, err := s.sessionPath(newID)
+	if err != nil {
+		return "", err
+	}
+	if err := persist.AtomicWrite(newPath, joinLines(lines), 0o600); err != nil {
+		return "", err
+	}
+	meta.ID = newID
+	meta.FilePath = newID + ".jsonl"
+	meta.CreatedAt = nowMs()
+	meta.LastAt = meta.CreatedAt
+	for _, l := range lines {
+		if l.typ == EntryTypeMessage && l.msg.Timestamp > meta.LastAt {
+			meta.LastAt = l.msg.Timestamp
+		}
+	}
+	meta.MessageCount, meta.TokenEstimate = visibleStats(s.counter(), lines)
+	meta.Source = sessionSource(newID)
+	idx.Sessions[newID] = meta
+	if err := s.saveIndex(idx); err != nil {
+		return "", err
+	}
+	s.search().reindex(newID)
+	return newID, nil
+}
+
+// Rewind hides every message after messageID — and messageID itself when
+// keep is false, which is how edit-and-regenerate drops the edited user
+// turn. It returns the rewind ID for Restore; 0 when nothing was hidden.
+func (s *Store) Rewind(sessionID, messageID string, keep bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockStore()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	path, err := s.sessionPath(sessionID)
+	if err != nil {
+		return 0, err
+	}
+	lines, err := readSessionLines(path)
+	if err != nil {
+		return 0, err
+	}
+	p := findMessage(lines, messageID)
+	if p < 0 || lines[p].hidden() {
+		return 0, fmt.Errorf("%w: %s", ErrMessageNotFound, messageID)
+	}
+	visible := func(l sessionLine) bool { return l.msg.RewoundAt == 0 }
+	start := p
+	if keep {
+		start = withToolResults(lines, p, visible) + 1
+	} else if len(extractToolResultIDsFromContent(lines[p].msg.Message.Content)) > 0 {
+		// Hiding a tool result alone would orphan the assistant's tool_use
+		// in the middle of the history.
+		return 0, fmt.Errorf("%w: %s is a tool result", ErrInvalidRewindPoint, messageID)
+	}
+
+	// Remember where the hidden branch hangs off the visible path, pinning
+	// that message's ID so it survives compaction.
+	after := ""
+	for i := start - 1; i >= 0; i-- {
+		if lines[i].typ != EntryTypeMessage || lines[i].hidden() {
+			continue
+		}
+		after = lines[i].id
+		if lines[i].msg.ID == "" {
+			lines[i].msg.ID = after
+			if err := lines[i].marshal(); err != nil {
+				return 0, err
+			}
+		}
+		break
+	}
+
+	rewindID := nowMs()
+	for _, l := range lines {
+		if l.msg.RewoundAt >= rewindID {
+			rewindID = l.msg.RewoundAt + 1
+		}
+	}
+	hidden := 0
+	for i := start; i < len(lines); i++ {
+		if lines[i].typ != EntryTypeMessage || lines[i].hidden() {
+			continue
+		}
+		lines[i].msg.ID = lines[i].id
+		lines[i].msg.RewoundAt = rewindID
+		lines[i].msg.RewoundAfter = after
+		if err := lines[i].marshal(); err != nil {
+			return 0, err
+		}
+		hidden++
+	}
+	if hidden == 0 {
+		return 0, nil
+	}
+	if err := s.rewriteSession(sessionID, path, lines); err != nil {
+		return 0, err
+	}
+	return rewindID, nil
+}
+
+// Restore brings back the messages hidden by rewindID. It fails with
+// ErrRewindConflict when the session has moved on since — a visible
+// message after the hidden ones, or between them and the message they were
+// rewound after (e.g. an older branch restored in the meantime): the two
+// cannot both be its history; Fork the hidden branch instead.
+func (s *Store) Restore(sessionID string, rewindID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	path, err := s.sessionPath(sessionID)
+	if err != nil {
+		return err
+	}
+	lines, err := readSessionLines(path)
+	if err != nil {
+		return err
+	}
+	first := -1
+	for i, l := range lines {
+		if l.typ == EntryTypeMessage && l.msg.RewoundAt == rewindID {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return fmt.Errorf("%w: %d", ErrRewindNotFound, rewindID)
+	}
+	// A missing anchor (compacted away, or the session start) means every
+	// message before the group was already hidden when it was rewound.
+	anchor := -1
+	if after := lines[first].msg.RewoundAfter; after != "" {
+		anchor = findMessage(lines[:first], after)
+	}
+	for _, l := range lines[anchor+1 : first] {
+		if l.typ == EntryTypeMessage && !l.hidden() {
+			return ErrRewindConflict
+		}
+	}
+	found := false
+	for i := range lines {
+		switch {
+		case lines[i].typ != EntryTypeMessage:
+		case lines[i].msg.RewoundAt == rewindID:
+			found = true
+			lines[i].msg.RewoundAt, lines[i].msg.RewoundAfter = 0, ""
+			if err := lines[i].marshal(); err != nil {
+				return err
+			}
+		case found && lines[i].msg.RewoundAt == 0:
+			return ErrRewindConflict
+		}
+	}
+	return s.rewriteSession(sessionID, path, lines)
+}
+
+// rewriteSession replaces the session file with lines and refreshes the
+// index counters and the search docs. Called with s.mu and the store lock held.
+func (s *Store) rewriteSession(sessionID, path string, lines []sessionLine) error {
+	if err := persist.AtomicWrite(path, joinLines(lines), 0o600); err != nil {
+		return err
+	}
+	idx, err := s.loadIndex()
+	if err != nil {
+		return err
+	}
+	if meta, ok := idx.Sessions[sessionID]; ok {
+		meta.MessageCount, meta.TokenEstimate = visibleStats(s.counter(), lines)
+		idx.Sessions[sessionID] = meta
+		if err := s.saveIndex(idx); err != nil {
+			return err
+		}
+	}
+	s.search().reindex(sessionID)
+	return nil
+}
+
+// visibleTail returns the message lines from the keep-th last visible
+// message onward, plus the number of visible messages in lines. Hidden
+// messages inside the tail stay (they remain restorable); those before it
+// go with the rest of the compacted or trimmed history.
+func visibleTail(lines [][]byte, keep int) ([][]byte, int) {
+	visible := make([]bool, len(lines))
+	count := 0
+	for i, line := range lines {
+		var me MessageEntry
+		if json.Unmarshal(line, &me) == nil && me.RewoundAt == 0 {
+			visible[i] = true
+			count++
+		}
+	}
+	if count <= keep {
+		return lines, count
+	}
+	seen := 0
+	for i := len(lines) - 1; i >= 0; i-- {
+		if !visible[i] {
+			continue
+		}
+		if seen++; seen == keep {
+			return lines[i:], count
+		}
+	}
+	return nil, count // keep == 0
+}
@@ -0,0 +1,219 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/tokenizer"
+)
+
+// messageIDs returns the IDs of the session's visible and hidden messages.
+func messageIDs(t *testing.T, s *Store, sid string) []string {
+	t.Helper()
+	raws, err := s.ReadAll(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, id := range MessageIDs(raws) {
+		if id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func historyTexts(t *testing.T, s *Store, sid string) []string {
+	t.Helper()
+	msgs, _, err := s.ReadHistory(sid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, m := range msgs {
+		var text string
+		if json.Unmarshal(m.Content, &text) != nil {
+			text = string(m.Content)
+		}
+		out = append(out, text)
+	}
+	return out
+}
+
+func TestRewindRestore(t *testing.T) {
+	s := NewStore(t.TempDir())
+	seedSession(t, s, "ses-1", 6)
+	ids := messageIDs(t, s, "ses-1")
+
+	rid, err := s.Rewind("ses-1", ids[1], true)
+	if err != nil || rid == 0 {
+		t.Fatalf("rewind = %d, %v", rid, err)
+	}
+	if got := historyTexts(t, s, "ses-1"); len(got) != 2 || got[1] != "message-01" {
+		t.Fatalf("history after rewind = %q", got)
+	}
+	if meta, _ := s.GetMeta("ses-1"); meta.MessageCount != 2 {
+		t.Errorf("message count = %d", meta.MessageCount)
+	}
+	// Hidden messages keep their IDs and stay out of search.
+	if got := messageIDs(t, s, "ses-1"); len(got) != 6 || got[5] != ids[5] {
+		t.Errorf("ids after rewind = %q", got)
+	}
+	hits, _ := s.Search(context.Background(), SearchQuery{Text: "message", Limit: 100})
+	for _, h := range hits {
+		if h.Snippet != "message-00" && h.Snippet != "message-01" {
+			t.Errorf("search sees rewound message: %+v", h)
+		}
+	}
+
+	if err := s.Restore("ses-1", rid); err != nil {
+		t.Fatal(err)
+	}
+	if got := historyTexts(t, s, "ses-1"); len(got) != 6 {
+		t.Fatalf("history after restore = %q", got)
+	}
+
+	// Edit-and-regenerate: the edited turn replaces the original one, and the
+	// old branch can no longer be restored over the new one.
+	rid, err = s.Rewind("ses-1", ids[4], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendText(t, s, "ses-1", "user", "edited")
+	if got := historyTexts(t, s, "ses-1"); len(got) != 5 || got[4] != "edited" {
+		t.Fatalf("history after edit = %q", got)
+	}
+	if err := s.Restore("ses-1", rid); !errors.Is(err, ErrRewindConflict) {
+		t.Errorf("restore over new turn = %v", err)
+	}
+	if _, err := s.Rewind("ses-1", "m-missing", true); !errors.Is(err, ErrMessageNotFound) {
+		t.Errorf("unknown message = %v", err)
+	}
+}
+
+func TestRewindKeepsToolResults(t *testing.T) {
+	s := NewStore(t.TempDir())
+	if _, _, err := s.GetOrCreate("ses-1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	appendText(t, s, "ses-1", "user", "run it")
+	s.AppendMessage("ses-1", "assistant", json.RawMessage(`[{"type":"tool_use","id":"t1","name":"bash","input":{}}]`))
+	s.AppendMessage("ses-1", "user", json.RawMessage(`[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]`))
+	appendText(t, s, "ses-1", "assistant", "done")
+	ids := messageIDs(t, s, "ses-1")
+
+	if _, err := s.Rewind("ses-1", ids[1], true); err != nil {
+		t.Fatal(err)
+	}
+	msgs, _, _ := s.ReadHistory("ses-1")
+	if len(msgs) != 3 || len(extractToolResultIDsFromContent(msgs[2].Content)) != 1 {
+		t.Fatalf("history = %+v", msgs)
+	}
+	if _, err := s.Rewind("ses-1", ids[2], false); !errors.Is(err, ErrInvalidRewindPoint) {
+		t.Errorf("rewind at tool result = %v", err)
+	}
+}
+
+// TestRestoreAfterOtherRestore — once an older branch is restored, a
+// later rewind hanging off the same point can no longer come back: its
+// messages followed the point, not the restored branch.
+func TestRestoreAfterOtherRestore(t *testing.T) {
+	s := NewStore(t.TempDir())
+	seedSession(t, s, "ses-1", 4)
+	ids := messageIDs(t, s, "ses-1")
+	ridA, err := s.Rewind("ses-1", ids[1], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendText(t, s, "ses-1", "user", "new-user")
+	appendText(t, s, "ses-1", "assistant", "new-reply")
+	ids = messageIDs(t, s, "ses-1")
+	ridB, err := s.Rewind("ses-1", ids[4], false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Restore("ses-1", ridA); err != nil {
+		t.Fatalf("restore A = %v", err)
+	}
+	if err := s.Restore("ses-1", ridB); !errors.Is(err, ErrRewindConflict) {
+		t.Fatalf("restore B after A = %v, want ErrRewindConflict", err)
+	}
+	if got := historyTexts(t, s, "ses-1"); len(got) != 4 || got[3] != "message-03" {
+		t.Errorf("history = %q", got)
+	}
+}
+
+func TestFork(t *testing.T) {
+	s := NewStore(t.TempDir())
+	seedSession(t, s, "ses-1", 30)
+	if err := Compact(context.Background(), s, "ses-1",
+		func(context.Context, string, string) (string, error) { return "summary", nil }, ""); err != nil {
+		t.Fatal(err)
+	}
+	ids := messageIDs(t, s, "ses-1")
+	rid, err := s.Rewind("ses-1", ids[5], true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appendText(t, s, "ses-1", "user", "new direction")
+
+	// Forking at a rewound message recovers that branch with the summary.
+	forkID, err := s.Fork("ses-1", ids[8])
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, summary, err := s.ReadHistory(forkID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "summary" || len(msgs) != 9 {
+		t.Fatalf("fork summary=%q messages=%d", summary, len(msgs))
+	}
+	meta, ok := s.GetMeta(forkID)
+	if !ok || meta.ParentID != "ses-1" || meta.ForkedFrom != ids[8] || meta.MessageCount != 9 {
+		t.Errorf("fork meta = %+v", meta)
+	}
+	// Message IDs are carried over, so the fork can be forked again.
+	if got := messageIDs(t, s, forkID); got[8] != ids[8] {
+		t.Errorf("fork ids = %q", got)
+	}
+	if got := historyTexts(t, s, "ses-1"); len(got) != 7 || got[6] != "new direction" {
+		t.Errorf("parent history = %q", got)
+	}
+	if err := s.Restore("ses-1", rid); !errors.Is(err, ErrRewindConflict) {
+		t.Errorf("restore = %v", err)
+	}
+
+	// The index rebuilt from JSONL keeps the parent link.
+	path, _ := s.sessionPath(forkID)
+	rebuilt, err := rebuildSessionMeta(tokenizer.Default(), path, forkID)
+	if err != nil || rebuilt.ParentID != "ses-1" || rebuilt.MessageCount != 9 {
+		t.Errorf("rebuilt = %+v, %v", rebuilt, err)
+	}
+}
+
+func TestCompactionSkipsRewound(t *testing.T) {
+	s := NewStore(t.TempDir())
+	seedSession(t, s, "ses-1", 30)
+	ids := messageIDs(t, s, "ses-1")
+	if _, err := s.Rewind("ses-1", ids[25], true); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.TrimToLastN("ses-1", 10); err != nil {
+		t.Fatal(err)
+	}
+	got := historyTexts(t, s, "ses-1")
+	if len(got) != 10 || got[9] != "message-25" {
+		t.Fatalf("history = %q", got)
+	}
+	// The rewound tail survives the trim and can still be forked.
+	if len(messageIDs(t, s, "ses-1")) != 14 {
+		t.Errorf("ids = %q", messageIDs(t, s, "ses-1"))
+	}
+	if _, err := s.Fork("ses-1", ids[29]); err != nil {
+		t.Error(err)
+	}
+}
@@ -23,8 +23,32 @@ import (
 // CompactionThreshold is the token count that triggers compaction.
 // Set conservatively so that after compaction the remaining context fits
 // well within any proxy/CDN timeout budget (Cloudflare: ~100s idle).
+// It is also the ceiling for CompactionThresholdFor.
 const CompactionThreshold = 50_000
 
+// CompactionRatio is the share of a model's usable input window (context
+// window minus max output) a session may fill before it is compacted. The
+// remainder is headroom for the system prompt, tool schemas and the new turn.
+const CompactionRatio = 0.75
+
+// CompactionThresholdFor returns the compaction trigger for a model with the
+// given context window / max output (from config.ModelCapabilities):
+// CompactionRatio of the usable window, capped at CompactionThreshold.
+// contextWindow <= 0 (unknown) falls back to CompactionThreshold.
+func CompactionThresholdFor(contextWindow, maxOutput int) int {
+	if contextWindow <= 0 {
+		return CompactionThreshold
+	}
+	usable := contextWindow - maxOutput
+	if usable < contextWindow/2 {
+		usable = contextWindow / 2
+	}
+	if t := int(float64(usable) * CompactionRatio); t < CompactionThreshold {
+		return t
+	}
+	return CompactionThreshold
+}
+
 var ErrSessionChanged = errors.New("session changed during compaction")
 
 // CompactionEventFunc is a lifecycle hook invoked before/after an async
@@ -157,10 +181,10 @@ Be factual and preserve technical details. Reply with just the summary, no pream
 	summary = strings.TrimSpace(summary)
 
 	// Update token estimate to post-compaction size (~summary + recent turns)
-	summaryTokens := len(summary) / 4
+	summaryTokens := store.counter().Count(summary)
 	var recentTokens int
 	for _, m := range msgs[boundary:] {
-		recentTokens += len(m.Content) / 4
+		recentTokens += estimateTokensRaw(store.counter(), m.Content)
 	}
 	newEstimate := summaryTokens + recentTokens + 500 // 500 overhead
 	state = compactionState{
@@ -292,7 +316,7 @@ func (s *Store) compactionSnapshot(sessionID string) (compactionSnapshot, error)
 			}
 		case EntryTypeMessage:
 			var entry MessageEntry
-			if json.Unmarshal(line, &entry) == nil &&
+			if json.Unmarshal(line, &entry) == nil && entry.RewoundAt == 0 &&
 				(entry.Message.Role == "user" || entry.Message.Role == "assistant") {
 				snapshot.Messages = append(snapshot.Messages, entry.Message)
 			}
@@ -337,10 +361,10 @@ func (s *Store) commitCompaction(sessionID string, state compactionState, keepMe
 	if err != nil {
 		return err
 	}
-	if len(messages) <= keepMessages {
+	messages, visible := visibleTail(messages, keepMessages)
+	if visible <= keepMessages {
 		return nil
 	}
-	messages = messages[len(messages)-keepMessages:]
 	entry := CompactionEntry{
 		BaseEntry:        BaseEntry{Type: EntryTypeCompaction},
 		Summary:          state.Summary,
@@ -371,7 +395,7 @@ func (s *Store) commitCompaction(sessionID string, state compactionState, keepMe
 	}
 	if meta, ok := idx.Sessions[sessionID]; ok {
 		meta.TokenEstimate = state.TokensAfter
-		meta.MessageCount = len(messages)
+		meta.MessageCount = keepMessages
 		idx.Sessions[sessionID] = meta
 	}
 	if err := s.saveIndex(idx); err != nil {
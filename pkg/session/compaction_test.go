@@ -5,9 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/tokenizer"
 )
 
 func seedSession(t *testing.T, store *Store, sessionID string, count int) {
@@ -158,3 +161,52 @@ func TestMultipleStoresShareOneSessionBoundary(t *testing.T) {
 		t.Fatalf("meta=%+v ok=%v", meta, ok)
 	}
 }
+
+func TestCompactionThresholdFor(t *testing.T) {
+	cases := []struct{ window, maxOut, want int }{
+		{0, 0, CompactionThreshold},            // unknown model
+		{200_000, 64_000, CompactionThreshold}, // large window: capped
+		{32_768, 4_096, 21_504},                // 0.75 × (32768 − 4096)
+		{8_192, 8_192, 3_072},                  // output ≥ half the window: keep half
+	}
+	for _, tc := range cases {
+		if got := CompactionThresholdFor(tc.window, tc.maxOut); got != tc.want {
+			t.Errorf("CompactionThresholdFor(%d, %d) = %d, want %d", tc.window, tc.maxOut, got, tc.want)
+		}
+	}
+}
+
+func TestEstimateTokensRawChargesImagesFlat(t *testing.T) {
+	big := `[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"` + strings.Repeat("A", 400_000) + `"}},{"type":"text","text":"what is this?"}]`
+	if got := estimateTokensRaw(tokenizer.Default(), json.RawMessage(big)); got < imageTokens || got > imageTokens+50 {
+		t.Fatalf("image message = %d tokens, want ~%d", got, imageTokens)
+	}
+	if got := estimateTokensRaw(tokenizer.Default(), json.RawMessage(`"你好，世界"`)); got < 5 {
+		t.Fatalf("CJK text undercounted: %d", got)
+	}
+}
+
+func TestWithModelCountsWithModelTokenizer(t *testing.T) {
+	dir := t.TempDir()
+	base := NewStore(dir)
+	claude := base.WithModel("anthropic", "claude-sonnet-4-5")
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 200)
+	content, _ := json.Marshal(text)
+
+	for _, s := range []*Store{base, claude} {
+		sid, _, err := s.GetOrCreate("", "agent")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AppendMessage(sid, "user", content); err != nil {
+			t.Fatal(err)
+		}
+		want := estimateTokensRaw(s.counter(), content)
+		if got := s.EstimateTokens(sid); got != want {
+			t.Errorf("%s estimate = %d, want %d", s.counter().Name(), got, want)
+		}
+	}
+	if claude.counter().Name() != tokenizer.FamilyClaude || base.counter().Count(text) == claude.counter().Count(text) {
+		t.Fatal("claude view should count with the claude tokenizer")
+	}
+}
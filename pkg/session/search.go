@@ -0,0 +1,684 @@
+// pkg/session/search.go — full-text and semantic search across one agent's sessions.
+//
+// Every user/assistant message appended through AppendMessageWithTools is
+// also written to a doc log ({sessionsDir}/.search/docs.jsonl). The inverted
+// index (CJK unigrams + bigrams, latin words, BM25) lives in memory: it is
+// rebuilt from the log on the first search in a process and kept current by
+// the same append hook. The first search of a store that has no log yet
+// builds it from the JSONL files; later loads catch up on messages the hook
+// missed (crash, older versions). Embeddings are optional and computed
+// lazily at search time for docs that lack one.
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+)
+
+const (
+	searchDirName    = ".search"
+	searchDocsFile   = "docs.jsonl"
+	searchVecsFile   = "vectors.gob"
+	searchDocRunes   = 8000 // text kept per message
+	searchEmbedRunes = 2000 // text sent to the embedding model
+	searchPerSession = 3    // hits per session, so one long chat can't fill the page
+	searchEmbedBatch = 64
+	searchEmbedMax   = 512 // docs embedded per search; the rest on later searches
+	searchMinCosine  = 0.3 // semantic-only hits below this are dropped
+	snippetRunes     = 120
+)
+
+// SearchEmbedder computes embeddings for semantic session search.
+// Model identifies the vector space; stored vectors from another model are
+// discarded.
+type SearchEmbedder struct {
+	Model string
+	Embed func(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// SearchQuery filters and ranks messages in Store.Search.
+type SearchQuery struct {
+	Text     string
+	Source   string          // session source (feishu / telegram / web ...); "" = all
+	From, To int64           // unix ms, inclusive; 0 = unbounded
+	Limit    int             // default 20, max 100
+	Embedder *SearchEmbedder // nil = keyword search only
+}
+
+// SearchHit is one matching message.
+type SearchHit struct {
+	SessionID string `json:"sessionId"`
+	Title     string `json:"title,omitempty"`
+	Source    string `json:"source,omitempty"`
+	Role      string `json:"role"`
+	Timestamp int64  `json:"timestamp"`
+	Snippet   string `json:"snippet"`
+	// Highlights are [start, end) rune offsets of query matches in Snippet.
+	Highlights [][2]int `json:"highlights,omitempty"`
+	Score      float64  `json:"score"`
+}
+
+// Marked returns the snippet with every highlight wrapped in open/close.
+func (h SearchHit) Marked(open, close string) string {
+	runes := []rune(h.Snippet)
+	var b strings.Builder
+	last := 0
+	for _, r := range h.Highlights {
+		if r[0] < last || r[1] > len(runes) {
+			continue
+		}
+		b.WriteString(string(runes[last:r[0]]))
+		b.WriteString(open)
+		b.WriteString(string(runes[r[0]:r[1]]))
+		b.WriteString(close)
+		last = r[1]
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+// searchDoc is one indexed message, as stored in docs.jsonl.
+type searchDoc struct {
+	Session string `json:"s"`
+	Role    string `json:"r"`
+	TS      int64  `json:"t"`
+	Text    string `json:"x"`
+}
+
+func (d searchDoc) key() string {
+	return d.Session + "/" + strconv.FormatInt(d.TS, 10) + "/" + d.Role
+}
+
+type posting struct{ doc, tf int }
+
+// searchIndex is the per-directory index; one instance per sessions dir is
+// shared by every Store opened on it.
+type searchIndex struct {
+	dir string // {sessionsDir}/.search
+
+	mu       sync.Mutex
+	loaded   bool
+	docs     []searchDoc
+	seen     map[string]bool
+	lastTS   map[string]int64
+	postings map[string][]posting
+	lens     []int
+	total    int
+	vecModel string
+	vecs     map[string][]float32
+}
+
+type searchVectors struct {
+	Model string
+	Vecs  map[string][]float32
+}
+
+var searchIndexes sync.Map
+
+func (s *Store) search() *searchIndex {
+	v, _ := searchIndexes.LoadOrStore(s.dir, &searchIndex{dir: filepath.Join(s.dir, searchDirName)})
+	return v.(*searchIndex)
+}
+
+func (x *searchIndex) logPath() string { return filepath.Join(x.dir, searchDocsFile) }
+
+// add is the append hook. Until the first search has built the log there is
+// nothing to keep current: the build reads the JSONL files anyway.
+func (x *searchIndex) add(sessionID, role string, content json.RawMessage, ts int64) {
+	if role != "user" && role != "assistant" {
+		return
+	}
+	text := searchText(content)
+	if text == "" {
+		return
+	}
+	d := searchDoc{Session: sessionID, Role: role, TS: ts, Text: text}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, err := os.Stat(x.logPath()); err != nil {
+		return
+	}
+	if x.loaded && x.seen[d.key()] {
+		return
+	}
+	if err := appendDocs(x.logPath(), []searchDoc{d}); err != nil {
+		log.Printf("[session] search index %s: %v", x.dir, err)
+		return
+	}
+	if x.loaded {
+		x.insert(d)
+	}
+}
+
+// reindex replaces a session's docs after its file was rewritten (rewind,
+// restore, fork). The log is filtered and rescanned; the in-memory index is
+// dropped and rebuilt from it on the next search.
+func (x *searchIndex) reindex(sessionID string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	data, err := os.ReadFile(x.logPath())
+	if err != nil {
+		return
+	}
+	var buf bytes.Buffer
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var d searchDoc
+		if len(line) == 0 || json.Unmarshal(line, &d) != nil || d.Session == sessionID {
+			continue
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	docs, _ := scanSearchDocs(filepath.Join(filepath.Dir(x.dir), sessionID+".jsonl"), sessionID, 0)
+	for _, d := range docs {
+		line, _ := json.Marshal(d)
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	if err := persist.AtomicWrite(x.logPath(), buf.Bytes(), 0o600); err != nil {
+		log.Printf("[session] search reindex %s: %v", sessionID, err)
+	}
+	x.loaded = false
+}
+
+// load reads the doc log, drops docs of deleted sessions and indexes
+// messages missing from it. Called with x.mu held.
+func (x *searchIndex) load(metas map[string]SessionIndexEntry) error {
+	x.docs, x.lens, x.total = nil, nil, 0
+	x.seen = map[string]bool{}
+	x.lastTS = map[string]int64{}
+	x.postings = map[string][]posting{}
+
+	stale := false
+	data, err := os.ReadFile(x.logPath())
+	exists := err == nil
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var d searchDoc
+		if len(line) == 0 || json.Unmarshal(line, &d) != nil {
+			continue
+		}
+		if _, ok := metas[d.Session]; !ok {
+			stale = true
+			continue
+		}
+		if !x.seen[d.key()] {
+			x.insert(d)
+		}
+	}
+
+	ids := make([]string, 0, len(metas))
+	for id := range metas {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var fresh []searchDoc
+	sessionsDir := filepath.Dir(x.dir)
+	for _, id := range ids {
+		docs, err := scanSearchDocs(filepath.Join(sessionsDir, id+".jsonl"), id, x.lastTS[id])
+		if err != nil {
+			continue
+		}
+		for _, d := range docs {
+			if !x.seen[d.key()] {
+				x.insert(d)
+				fresh = append(fresh, d)
+			}
+		}
+	}
+
+	switch {
+	case !exists || stale:
+		var buf bytes.Buffer
+		for _, d := range x.docs {
+			line, _ := json.Marshal(d)
+			buf.Write(line)
+			buf.WriteByte('\n')
+		}
+		if err := persist.AtomicWrite(x.logPath(), buf.Bytes(), 0o600); err != nil {
+			return err
+		}
+	case len(fresh) > 0:
+		if err := appendDocs(x.logPath(), fresh); err != nil {
+			return err
+		}
+	}
+	x.loaded = true
+	x.loadVectors()
+	return nil
+}
+
+func (x *searchIndex) insert(d searchDoc) {
+	i := len(x.docs)
+	x.docs = append(x.docs, d)
+	x.seen[d.key()] = true
+	if d.TS > x.lastTS[d.Session] {
+		x.lastTS[d.Session] = d.TS
+	}
+	toks := searchTokens(d.Text, true)
+	tf := make(map[string]int, len(toks))
+	for _, t := range toks {
+		tf[t]++
+	}
+	for t, n := range tf {
+		x.postings[t] = append(x.postings[t], posting{i, n})
+	}
+	x.lens = append(x.lens, len(toks))
+	x.total += len(toks)
+}
+
+func (x *searchIndex) loadVectors() {
+	x.vecModel, x.vecs = "", map[string][]float32{}
+	f, err := os.Open(filepath.Join(x.dir, searchVecsFile))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var v searchVectors
+	if gob.NewDecoder(f).Decode(&v) != nil {
+		return // corrupt → re-embed
+	}
+	x.vecModel = v.Model
+	for k, vec := range v.Vecs {
+		if x.seen[k] {
+			x.vecs[k] = vec
+		}
+	}
+}
+
+func (x *searchIndex) saveVectors() error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(searchVectors{Model: x.vecModel, Vecs: x.vecs}); err != nil {
+		return err
+	}
+	return persist.AtomicWrite(filepath.Join(x.dir, searchVecsFile), buf.Bytes(), 0o600)
+}
+
+// Search ranks this store's messages against q: BM25 over the inverted
+// index, blended with cosine similarity when q.Embedder is set. At most
+// searchPerSession hits are returned per session.
+func (s *Store) Search(ctx context.Context, q SearchQuery) ([]SearchHit, error) {
+	if strings.TrimSpace(q.Text) == "" {
+		return nil, fmt.Errorf("empty query")
+	}
+	if q.Limit <= 0 {
+		q.Limit = 20
+	}
+	if q.Limit > 100 {
+		q.Limit = 100
+	}
+	list, err := s.ListSessions()
+	if err != nil {
+		return nil, err
+	}
+	metas := make(map[string]SessionIndexEntry, len(list))
+	for _, m := range list {
+		metas[m.ID] = m
+	}
+
+	x := s.search()
+	x.mu.Lock()
+	if !x.loaded {
+		if err := x.load(metas); err != nil {
+			x.mu.Unlock()
+			return nil, fmt.Errorf("build search index: %w", err)
+		}
+	}
+	x.mu.Unlock()
+
+	var qvec []float32
+	if q.Embedder != nil && q.Embedder.Embed != nil {
+		x.embedPending(ctx, q.Embedder)
+		if vecs, err := q.Embedder.Embed(ctx, []string{q.Text}); err == nil && len(vecs) == 1 {
+			qvec = vecs[0]
+		} else if err != nil {
+			log.Printf("[session] search embed query: %v", err)
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	keep := func(d searchDoc) bool {
+		m, ok := metas[d.Session]
+		if !ok {
+			return false
+		}
+		if q.Source != "" && searchSource(m) != q.Source {
+			return false
+		}
+		return (q.From == 0 || d.TS >= q.From) && (q.To == 0 || d.TS <= q.To)
+	}
+
+	terms := searchTokens(q.Text, false)
+	scores := x.bm25(terms, keep)
+	if qvec != nil && q.Embedder.Model == x.vecModel {
+		// Hybrid: keyword score normalised to [0,1] next to cosine similarity.
+		maxBM := 0.0
+		for _, sc := range scores {
+			maxBM = math.Max(maxBM, sc)
+		}
+		hybrid := make(map[int]float64, len(scores))
+		for i, sc := range scores {
+			if maxBM > 0 {
+				hybrid[i] = 0.5 * sc / maxBM
+			}
+		}
+		for i, d := range x.docs {
+			vec, ok := x.vecs[d.key()]
+			if !ok || !keep(d) {
+				continue
+			}
+			cos := cosine(qvec, vec)
+			if _, kw := scores[i]; kw || cos >= searchMinCosine {
+				hybrid[i] += 0.5 * cos
+			}
+		}
+		scores = hybrid
+	}
+
+	order := make([]int, 0, len(scores))
+	for i := range scores {
+		order = append(order, i)
+	}
+	sort.Slice(order, func(a, b int) bool {
+		if scores[order[a]] != scores[order[b]] {
+			return scores[order[a]] > scores[order[b]]
+		}
+		return x.docs[order[a]].TS > x.docs[order[b]].TS
+	})
+
+	hits := []SearchHit{}
+	perSession := map[string]int{}
+	for _, i := range order {
+		d := x.docs[i]
+		if perSession[d.Session] >= searchPerSession {
+			continue
+		}
+		perSession[d.Session]++
+		m := metas[d.Session]
+		snip, marks := searchSnippet(d.Text, terms)
+		hits = append(hits, SearchHit{
+			SessionID:  d.Session,
+			Title:      m.Title,
+			Source:     searchSource(m),
+			Role:       d.Role,
+			Timestamp:  d.TS,
+			Snippet:    snip,
+			Highlights: marks,
+			Score:      math.Round(scores[i]*1000) / 1000,
+		})
+		if len(hits) >= q.Limit {
+			break
+		}
+	}
+	return hits, nil
+}
+
+// bm25 scores every doc containing at least one term. Called with x.mu held.
+func (x *searchIndex) bm25(terms []string, keep func(searchDoc) bool) map[int]float64 {
+	scores := map[int]float64{}
+	n := float64(len(x.docs))
+	if n == 0 {
+		return scores
+	}
+	avg := float64(x.total) / n
+	const k1, b = 1.2, 0.75
+	for _, t := range terms {
+		ps := x.postings[t]
+		if len(ps) == 0 {
+			continue
+		}
+		df := float64(len(ps))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for _, p := range ps {
+			if !keep(x.docs[p.doc]) {
+				continue
+			}
+			tf := float64(p.tf)
+			dl := float64(x.lens[p.doc])
+			scores[p.doc] += idf * tf * (k1 + 1) / (tf + k1*(1-b+b*dl/avg))
+		}
+	}
+	return scores
+}
+
+// embedPending embeds up to searchEmbedMax docs without a vector, newest
+// first. The API calls run without x.mu so appends are not blocked.
+func (x *searchIndex) embedPending(ctx context.Context, e *SearchEmbedder) {
+	x.mu.Lock()
+	if x.vecModel != e.Model {
+		x.vecModel, x.vecs = e.Model, map[string][]float32{}
+	}
+	var keys, texts []string
+	for i := len(x.docs) - 1; i >= 0 && len(keys) < searchEmbedMax; i-- {
+		d := x.docs[i]
+		if _, ok := x.vecs[d.key()]; !ok {
+			keys = append(keys, d.key())
+			texts = append(texts, truncateRune(d.Text, searchEmbedRunes))
+		}
+	}
+	x.mu.Unlock()
+	if len(keys) == 0 {
+		return
+	}
+
+	got := map[string][]float32{}
+	for start := 0; start < len(texts); start += searchEmbedBatch {
+		end := min(start+searchEmbedBatch, len(texts))
+		vecs, err := e.Embed(ctx, texts[start:end])
+		if err != nil || len(vecs) != end-start {
+			log.Printf("[session] search embed: %v", err)
+			break
+		}
+		for i, v := range vecs {
+			got[keys[start+i]] = v
+		}
+	}
+	if len(got) == 0 {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.vecModel != e.Model {
+		return
+	}
+	for k, v := range got {
+		x.vecs[k] = v
+	}
+	if err := x.saveVectors(); err != nil {
+		log.Printf("[session] save search vectors: %v", err)
+	}
+}
+
+// scanSearchDocs reads the messages of one session file newer than after.
+func scanSearchDocs(path, sessionID string, after int64) ([]searchDoc, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var docs []searchDoc
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 8*1024*1024), 8*1024*1024)
+	for scanner.Scan() {
+		var me MessageEntry
+		if json.Unmarshal(scanner.Bytes(), &me) != nil || me.Type != EntryTypeMessage || me.Timestamp <= after || me.RewoundAt != 0 {
+			continue
+		}
+		if me.Message.Role != "user" && me.Message.Role != "assistant" {
+			continue
+		}
+		if text := searchText(me.Message.Content); text != "" {
+			docs = append(docs, searchDoc{Session: sessionID, Role: me.Message.Role, TS: me.Timestamp, Text: text})
+		}
+	}
+	return docs, scanner.Err()
+}
+
+// appendDocs appends without fsync: a doc lost in a crash is recovered by
+// the catch-up scan on the next load.
+func appendDocs(path string, docs []searchDoc) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, d := range docs {
+		line, _ := json.Marshal(d)
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func searchText(content json.RawMessage) string {
+	return truncateRune(extractTextFromContent(content), searchDocRunes)
+}
+
+func searchSource(m SessionIndexEntry) string {
+	if m.Source != "" {
+		return m.Source
+	}
+	return sessionSource(m.ID)
+}
+
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// searchTokens lowercases s and splits it into latin/digit words (2+ runes)
+// and CJK bigrams. Documents also get CJK unigrams (unigrams=true) so a
+// one-character query still matches; queries use unigrams only for
+// one-character runs, keeping multi-character queries phrase-like.
+func searchTokens(s string, unigrams bool) []string {
+	var out []string
+	var word, cjk []rune
+	flushWord := func() {
+		if len(word) >= 2 {
+			out = append(out, string(word))
+		}
+		word = word[:0]
+	}
+	flushCJK := func() {
+		if len(cjk) == 1 || (unigrams && len(cjk) > 0) {
+			for _, r := range cjk {
+				out = append(out, string(r))
+			}
+		}
+		for i := 0; i+1 < len(cjk); i++ {
+			out = append(out, string(cjk[i:i+2]))
+		}
+		cjk = cjk[:0]
+	}
+	for _, r := range s {
+		r = unicode.ToLower(r)
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return out
+}
+
+// searchSnippet cuts a window of snippetRunes around the first match and
+// returns the rune ranges of all matches inside it.
+func searchSnippet(text string, terms []string) (string, [][2]int) {
+	runes := []rune(text)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+		if r == '\n' || r == '\r' || r == '\t' {
+			runes[i] = ' '
+		}
+	}
+	var ranges [][2]int
+	for _, t := range terms {
+		tr := []rune(t)
+		for i := 0; i+len(tr) <= len(lower); i++ {
+			if string(lower[i:i+len(tr)]) == t {
+				ranges = append(ranges, [2]int{i, i + len(tr)})
+			}
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	var merged [][2]int
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r[0] <= merged[n-1][1] {
+			merged[n-1][1] = max(merged[n-1][1], r[1])
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	start := 0
+	if len(merged) > 0 {
+		start = max(0, merged[0][0]-snippetRunes/3)
+	}
+	end := min(len(runes), start+snippetRunes)
+	start = max(0, end-snippetRunes)
+	prefix, suffix := "", ""
+	if start > 0 {
+		prefix = "…"
+	}
+	if end < len(runes) {
+		suffix = "…"
+	}
+	shift := len([]rune(prefix)) - start
+	var marks [][2]int
+	for _, r := range merged {
+		if r[0] >= end || r[1] <= start {
+			continue
+		}
+		marks = append(marks, [2]int{max(r[0], start) + shift, min(r[1], end) + shift})
+	}
+	return prefix + string(runes[start:end]) + suffix, marks
+}
+
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
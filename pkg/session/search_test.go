@@ -0,0 +1,117 @@
+package session
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func appendText(t *testing.T, s *Store, sid, role, text string) {
+	t.Helper()
+	b, _ := json.Marshal(text)
+	if err := s.AppendMessage(sid, role, b); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSearchTokens(t *testing.T) {
+	if got := searchTokens("部署 Kubernetes 集群, v2", false); !reflect.DeepEqual(got, []string{"部署", "kubernetes", "集群", "v2"}) {
+		t.Errorf("query tokens = %q", got)
+	}
+	if got := searchTokens("猫", false); !reflect.DeepEqual(got, []string{"猫"}) {
+		t.Errorf("single char = %q", got)
+	}
+	if got := searchTokens("会议纪要", true); !reflect.DeepEqual(got, []string{"会", "议", "纪", "要", "会议", "议纪", "纪要"}) {
+		t.Errorf("doc tokens = %q", got)
+	}
+}
+
+func TestStoreSearch(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	for _, id := range []string{"ses-1", "feishu-2"} {
+		if _, _, err := s.GetOrCreate(id, "a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Written before the first search: picked up by the build.
+	appendText(t, s, "ses-1", "user", "下周三的会议纪要请整理一下")
+	appendText(t, s, "ses-1", "assistant", "好的，会议纪要已经整理完毕。")
+	appendText(t, s, "feishu-2", "user", "Kubernetes 集群升级计划")
+
+	hits, err := s.Search(context.Background(), SearchQuery{Text: "会议纪要"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 2 || hits[0].SessionID != "ses-1" || hits[0].Title != "下周三的会议纪要请整理一下" {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if got := hits[0].Marked("[", "]"); !strings.Contains(got, "[会议纪要]") {
+		t.Errorf("marked = %q", got)
+	}
+
+	// Appended after the build: indexed by the hook.
+	appendText(t, s, "feishu-2", "assistant", "升级前先备份 etcd")
+	hits, _ = s.Search(context.Background(), SearchQuery{Text: "ETCD"})
+	if len(hits) != 1 || hits[0].Source != "feishu" || hits[0].Snippet != "升级前先备份 etcd" || !reflect.DeepEqual(hits[0].Highlights, [][2]int{{7, 11}}) {
+		t.Fatalf("hook hits = %+v", hits)
+	}
+
+	// Source and date filters.
+	if hits, _ := s.Search(context.Background(), SearchQuery{Text: "会议", Source: "feishu"}); len(hits) != 0 {
+		t.Errorf("source filter = %+v", hits)
+	}
+	if hits, _ := s.Search(context.Background(), SearchQuery{Text: "会议", From: nowMs() + 60000}); len(hits) != 0 {
+		t.Errorf("date filter = %+v", hits)
+	}
+
+	// Restart: the log is reloaded and deleted sessions are dropped.
+	if err := s.DeleteSession("ses-1"); err != nil {
+		t.Fatal(err)
+	}
+	searchIndexes.Delete(s.dir)
+	hits, _ = NewStore(dir).Search(context.Background(), SearchQuery{Text: "会议纪要 etcd"})
+	if len(hits) != 1 || hits[0].SessionID != "feishu-2" {
+		t.Errorf("after restart = %+v", hits)
+	}
+}
+
+func TestStoreSearchSemantic(t *testing.T) {
+	s := NewStore(t.TempDir())
+	if _, _, err := s.GetOrCreate("ses-1", "a"); err != nil {
+		t.Fatal(err)
+	}
+	appendText(t, s, "ses-1", "user", "我养了一只橘猫")
+	appendText(t, s, "ses-1", "user", "明天去机场接人")
+
+	// Toy embedding: one dimension per topic.
+	calls := 0
+	emb := &SearchEmbedder{Model: "toy", Embed: func(_ context.Context, texts []string) ([][]float32, error) {
+		calls++
+		out := make([][]float32, len(texts))
+		for i, t := range texts {
+			pet := strings.Contains(t, "猫") || strings.Contains(t, "宠物")
+			out[i] = []float32{0.1, 0.1}
+			if pet {
+				out[i][0] = 1
+			} else {
+				out[i][1] = 1
+			}
+		}
+		return out, nil
+	}}
+	hits, err := s.Search(context.Background(), SearchQuery{Text: "宠物", Embedder: emb})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].Snippet != "我养了一只橘猫" || len(hits[0].Highlights) != 0 {
+		t.Fatalf("semantic hits = %+v", hits)
+	}
+	// Vectors are kept: the second search only embeds the query.
+	s.Search(context.Background(), SearchQuery{Text: "宠物", Embedder: emb})
+	if calls != 3 {
+		t.Errorf("embed calls = %d, want 3", calls)
+	}
+}
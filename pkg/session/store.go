@@ -15,6 +15,7 @@ import (
 
 	"github.com/Zyling-ai/zyhive/pkg/persist"
 	"github.com/Zyling-ai/zyhive/pkg/safefs"
+	"github.com/Zyling-ai/zyhive/pkg/tokenizer"
 )
 
 // SessionIndex maps session IDs to their file paths and metadata.
@@ -27,6 +28,7 @@ type SessionIndex struct {
 type Store struct {
 	dir string
 	mu  *sync.Mutex
+	tk  tokenizer.Tokenizer // nil = tokenizer.Default()
 }
 
 var sessionDirLocks sync.Map
@@ -40,6 +42,23 @@ func NewStore(dir string) *Store {
 	return &Store{dir: dir, mu: value.(*sync.Mutex)}
 }
 
+// WithModel returns a view of the store whose token estimates (and so the
+// compaction trigger) use the tokenizer of the given provider type and model
+// instead of the default family. Both views share files and locks.
+func (s *Store) WithModel(provider, model string) *Store {
+	view := *s
+	view.tk = tokenizer.For(provider, model)
+	return &view
+}
+
+// counter is the tokenizer for this store's estimates.
+func (s *Store) counter() tokenizer.Tokenizer {
+	if s.tk == nil {
+		return tokenizer.Default()
+	}
+	return s.tk
+}
+
 func (s *Store) sessionPath(sessionID string) (string, error) {
 	if err := safefs.ValidateResourceID(sessionID); err != nil {
 		return "", fmt.Errorf("invalid session id %q: %w", sessionID, err)
@@ -119,6 +138,48 @@ func (s *Store) Create(sessionID, agentID string) (string, error) {
 	return s.sessionPath(id)
 }
 
+// ImportSession creates a new session for agentID from previously exported
+// MessageEntry / CompactionEntry values and returns its ID. Entries keep
+// their IDs and timestamps; the header and index entry are new.
+func (s *Store) ImportSession(agentID, title string, entries []any) (string, error) {
+	header := SessionHeader{
+		BaseEntry: BaseEntry{Type: EntryTypeSession},
+		Version:   CurrentVersion,
+		AgentID:   agentID,
+		CreatedAt: nowMs(),
+	}
+	raws := [][]byte{mustJSON(header)}
+	for _, e := range entries {
+		raw, err := json.Marshal(e)
+		if err != nil {
+			return "", fmt.Errorf("marshal entry: %w", err)
+		}
+		raws = append(raws, raw)
+	}
+	lines := parseSessionLines(raws)
+	if len(lines) != len(raws) {
+		return "", fmt.Errorf("import: malformed entry")
+	}
+	for _, l := range lines[1:] {
+		if l.typ != EntryTypeMessage && l.typ != EntryTypeCompaction {
+			return "", fmt.Errorf("import: unsupported entry type %q", l.typ)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.lockStore()
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	idx, err := s.loadIndex()
+	if err != nil {
+		return "", err
+	}
+	return s.writeNewSession(idx, lines, SessionIndexEntry{AgentID: agentID, Title: title})
+}
+
 // AppendMessage appends a user or assistant message and updates session metadata.
 func (s *Store) AppendMessage(sessionID, role string, content json.RawMessage) error {
 	return s.AppendMessageWithTools(sessionID, role, content, nil)
@@ -140,7 +201,7 @@ func (s *Store) AppendMessageWithTools(sessionID, role string, content json.RawM
 		return err
 	}
 	entry := MessageEntry{
-		BaseEntry: BaseEntry{Type: EntryTypeMessage},
+		BaseEntry: BaseEntry{Type: EntryTypeMessage, ID: newMessageID()},
 		Message:   Message{Role: role, Content: content, ToolCalls: toolCalls},
 		Timestamp: nowMs(),
 	}
@@ -155,6 +216,7 @@ func (s *Store) AppendMessageWithTools(sessionID, role string, content json.RawM
 	if err := appendEntry(path, entry); err != nil {
 		return err
 	}
+	s.search().add(sessionID, role, content, entry.Timestamp)
 
 	// Update metadata in index
 	if indexErr != nil {
@@ -165,7 +227,7 @@ func (s *Store) AppendMessageWithTools(sessionID, role string, content json.RawM
 	}
 	meta.MessageCount++
 	meta.LastAt = nowMs()
-	meta.TokenEstimate += estimateTokensRaw(content)
+	meta.TokenEstimate += estimateTokensRaw(s.counter(), content)
 
 	// Auto-title from first user message
 	if meta.Title == "" && role == "user" {
@@ -178,7 +240,8 @@ func (s *Store) AppendMessageWithTools(sessionID, role string, content json.RawM
 // ReadHistory loads all conversation turns from a session, handling compaction entries.
 // Returns messages in chronological order, suitable for LLM context.
 // If a compaction entry is found, the summary is returned as a synthetic "system" entry
-// and only messages after the compaction boundary are included.
+// and only messages after the compaction boundary are included. Messages hidden
+// by Rewind are skipped.
 func (s *Store) ReadHistory(sessionID string) ([]Message, string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -227,7 +290,7 @@ func (s *Store) ReadHistory(sessionID string) ([]Message, string, error) {
 		case EntryTypeMessage:
 			if afterCompaction || compactionSummary == "" {
 				var me MessageEntry
-				if err := json.Unmarshal(line, &me); err == nil {
+				if err := json.Unmarshal(line, &me); err == nil && me.RewoundAt == 0 {
 					if me.Message.Role == "user" || me.Message.Role == "assistant" {
 						messages = append(messages, me.Message)
 					}
@@ -512,7 +575,7 @@ func (s *Store) reconcileIndex(idx *SessionIndex) error {
 		if ok && (statErr != nil || info.ModTime().UnixMilli() <= current.LastAt) {
 			continue
 		}
-		rebuilt, rebuildErr := rebuildSessionMeta(path, id)
+		rebuilt, rebuildErr := rebuildSessionMeta(s.counter(), path, id)
 		if rebuildErr == nil {
 			if ok {
 				rebuilt.Title = current.Title
@@ -534,7 +597,7 @@ func (s *Store) reconcileIndex(idx *SessionIndex) error {
 	return nil
 }
 
-func rebuildSessionMeta(path, sessionID string) (SessionIndexEntry, error) {
+func rebuildSessionMeta(tk tokenizer.Tokenizer, path, sessionID string) (SessionIndexEntry, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return SessionIndexEntry{}, err
@@ -565,20 +628,22 @@ func rebuildSessionMeta(path, sessionID string) (SessionIndexEntry, error) {
 			if json.Unmarshal(line, &header) == nil {
 				meta.AgentID = header.AgentID
 				meta.CreatedAt = header.CreatedAt
+				meta.ParentID = header.ParentSession
+				meta.ForkedFrom = header.ForkedFrom
 			}
 		case EntryTypeCompaction:
 			var compaction CompactionEntry
 			if json.Unmarshal(line, &compaction) == nil {
 				meta.MessageCount = 0
-				meta.TokenEstimate = len(compaction.Summary)/4 + 500
+				meta.TokenEstimate = tk.Count(compaction.Summary) + 500
 			}
 		case EntryTypeMessage:
 			var message MessageEntry
-			if json.Unmarshal(line, &message) != nil {
+			if json.Unmarshal(line, &message) != nil || message.RewoundAt != 0 {
 				continue
 			}
 			meta.MessageCount++
-			meta.TokenEstimate += estimateTokensRaw(message.Message.Content)
+			meta.TokenEstimate += estimateTokensRaw(tk, message.Message.Content)
 			if message.Timestamp > meta.LastAt {
 				meta.LastAt = message.Timestamp
 			}
@@ -596,6 +661,20 @@ func sessionSource(sessionID string) string {
 		return "feishu"
 	case strings.HasPrefix(sessionID, "telegram-"), strings.HasPrefix(sessionID, "tg-"):
 		return "telegram"
+	case strings.HasPrefix(sessionID, "slack-"):
+		return "slack"
+	case strings.HasPrefix(sessionID, "discord-"):
+		return "discord"
+	case strings.HasPrefix(sessionID, "email-"):
+		return "email"
+	case strings.HasPrefix(sessionID, "dingtalk-"):
+		return "dingtalk"
+	case strings.HasPrefix(sessionID, "wecom-"):
+		return "wecom"
+	case strings.HasPrefix(sessionID, "webhook-"):
+		return "webhook"
+	case strings.HasPrefix(sessionID, "mcp-"):
+		return "mcp"
 	default:
 		return "web"
 	}
@@ -633,9 +712,47 @@ func appendEntry(path string, v any) error {
 	})
 }
 
-// estimateTokensRaw estimates token count for raw JSON content (~4 chars per token).
-func estimateTokensRaw(content json.RawMessage) int {
-	return len(content) / 4
+// imageTokens is the flat charge for an image block (~1.2MP under Anthropic's
+// width×height/750 rule); base64 length says nothing about vision tokens.
+const imageTokens = 1600
+
+// estimateTokensRaw estimates the token count of one message's raw JSON
+// content with tk: text / thinking / tool input and results are tokenized,
+// images charged a flat imageTokens.
+func estimateTokensRaw(tk tokenizer.Tokenizer, content json.RawMessage) int {
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return tk.Count(s) + 4
+	}
+	var blocks []struct {
+		Type     string          `json:"type"`
+		Text     string          `json:"text"`
+		Thinking string          `json:"thinking"`
+		Name     string          `json:"name"`
+		Input    json.RawMessage `json:"input"`
+		Content  json.RawMessage `json:"content"`
+	}
+	if json.Unmarshal(content, &blocks) != nil {
+		return tk.Count(string(content))
+	}
+	n := 4
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			n += tk.Count(b.Text)
+		case "thinking":
+			n += tk.Count(b.Thinking)
+		case "image":
+			n += imageTokens
+		case "tool_use":
+			n += tk.Count(b.Name) + tk.Count(string(b.Input)) + 8
+		case "tool_result":
+			if len(b.Content) > 0 {
+				n += estimateTokensRaw(tk, b.Content)
+			}
+		}
+	}
+	return n
 }
 
 // extractTitle returns the first 60 chars of a user message as a session title.
@@ -668,7 +785,8 @@ func truncateRune(s string, maxRunes int) string {
 
 // TrimToLastN rewrites the session JSONL keeping only the last keepMsgs messages.
 // keepMsgs = keepTurns * 2 (each turn = 1 user + 1 assistant message).
-// Non-message entries (session header, compaction) are preserved.
+// Non-message entries (session header, compaction) are preserved; messages hidden
+// by Rewind do not count toward keepMsgs.
 func (s *Store) TrimToLastN(sessionID string, keepMsgs int) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -713,8 +831,9 @@ func (s *Store) TrimToLastN(sessionID string, keepMsgs int) error {
 	}
 
 	// Keep only last keepMsgs messages
-	if len(msgLines) > keepMsgs {
-		msgLines = msgLines[len(msgLines)-keepMsgs:]
+	msgLines, visible := visibleTail(msgLines, keepMsgs)
+	if visible > keepMsgs {
+		visible = keepMsgs
 	}
 
 	// Rewrite atomically via temp file
@@ -741,10 +860,13 @@ func (s *Store) TrimToLastN(sessionID string, keepMsgs int) error {
 		if meta, ok := idx.Sessions[sessionID]; ok {
 			var tokens int
 			for _, line := range msgLines {
-				tokens += len(line) / 4
+				var me MessageEntry
+				if json.Unmarshal(line, &me) == nil && me.RewoundAt == 0 {
+					tokens += estimateTokensRaw(s.counter(), me.Message.Content)
+				}
 			}
 			meta.TokenEstimate = tokens
-			meta.MessageCount = len(msgLines)
+			meta.MessageCount = visible
 			idx.Sessions[sessionID] = meta
 			_ = s.saveIndex(idx)
 		}
@@ -29,6 +29,9 @@ type SessionHeader struct {
 	Version   int    `json:"version"`
 	AgentID   string `json:"agentId"`
 	CreatedAt int64  `json:"createdAt"`
+	// ParentSession / ForkedFrom are set on branches created by Store.Fork.
+	ParentSession string `json:"parentSession,omitempty"`
+	ForkedFrom    string `json:"forkedFrom,omitempty"`
 }
 
 // MessageEntry wraps a user or assistant message.
@@ -36,6 +39,14 @@ type MessageEntry struct {
 	BaseEntry
 	Message   Message `json:"message"`
 	Timestamp int64   `json:"timestamp"`
+	// RewoundAt is the rewind ID (unix ms) that hid this message; hidden
+	// messages stay in the file so the rewind can be restored or forked,
+	// but no reader treats them as history.
+	RewoundAt int64 `json:"rewoundAt,omitempty"`
+	// RewoundAfter is the ID of the last visible message when the rewind
+	// happened ("" = session start); Restore checks nothing has become
+	// visible between it and the hidden messages.
+	RewoundAfter string `json:"rewoundAfter,omitempty"`
 }
 
 // ToolCallRecord persists tool call display metadata alongside a message.
@@ -91,7 +102,7 @@ type SessionIndexEntry struct {
 	LastAt        int64  `json:"lastAt"`                 // last activity timestamp
 	TokenEstimate int    `json:"tokenEstimate"`          // rough token count, triggers compaction
 	Active        bool   `json:"active,omitempty"`       // if true, reaper will never delete this session
-	Source        string `json:"source,omitempty"`       // "web" | "telegram" | "feishu" | "whatsapp" etc.
+	Source        string `json:"source,omitempty"`       // "web" | "telegram" | "feishu" | "slack" | "discord" | "email" | "dingtalk" | "wecom" etc.
 	// TitleOverridden=true when the user manually renamed via PATCH /sessions/:id
 	// or when title was set by a LLM-summarizer. Auto-title logic won't touch
 	// these again (respect user choice / avoid recompute cost).
@@ -99,4 +110,8 @@ type SessionIndexEntry struct {
 	// TitledAtMsgCount records the MessageCount at which the last auto-retitle
 	// ran. Used to throttle retitle frequency (only fire at fixed milestones).
 	TitledAtMsgCount int `json:"titledAtMsgCount,omitempty"`
+	// ParentID / ForkedFrom link a branch back to the session and message
+	// it was forked from (mirrors the JSONL header).
+	ParentID   string `json:"parentId,omitempty"`
+	ForkedFrom string `json:"forkedFrom,omitempty"`
 }
@@ -10,16 +10,37 @@ import (
 const criticSystemPrompt = `你是「技能复盘官」。下面是某个 AI 技能做出的若干**预测**以及它们对应的**真实结果**（这些都是预测失败/未命中的样本）。
 请逐条分析失败原因，输出归因标签与可执行教训，用于改进该技能。
 
-严格只输出一个 JSON 数组，不要任何解释性文字、不要 markdown 代码块。格式：
-[
+严格只输出一个 JSON 对象，不要任何解释性文字、不要 markdown 代码块。格式：
+{"attributions": [
   {"entryId": "<原样回填的预测ID>", "tags": ["归因标签1","归因标签2"], "lesson": "一句话可执行教训（<=40字）"}
-]
+]}
 
 要求：
 - entryId 必须与输入中的 ID 完全一致；
 - tags 用简短中文名词（如「忽略主场优势」「样本过期」「过度自信」）；
 - lesson 必须是可落到规则里的具体行动，不要空话；
-- 只输出 JSON 数组本身。`
+- 只输出 JSON 对象本身。`
+
+// criticSchema is the structured-output shape of the critic reply. The array
+// is wrapped in an object because OpenAI json_schema needs an object root.
+const criticSchema = `{
+	"type": "object",
+	"properties": {
+		"attributions": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"entryId": {"type": "string"},
+					"tags": {"type": "array", "items": {"type": "string"}},
+					"lesson": {"type": "string"}
+				},
+				"required": ["entryId", "tags", "lesson"]
+			}
+		}
+	},
+	"required": ["attributions"]
+}`
 
 // Critique asks the LLM to attribute each missed prediction to root-cause tags
 // and a concrete lesson. The returned slice aligns to input entries by ID; any
@@ -42,7 +63,7 @@ func Critique(ctx context.Context, misses []LedgerEntry, callLLM CallLLM) ([]Att
 		sb.WriteString(fmt.Sprintf("真实结果: %s\n\n", e.Oracle))
 	}
 
-	raw, err := callLLM(ctx, criticSystemPrompt, sb.String())
+	raw, err := callLLM(ctx, criticSystemPrompt, sb.String(), json.RawMessage(criticSchema))
 	if err != nil {
 		return nil, fmt.Errorf("skillopt critic llm: %w", err)
 	}
@@ -67,26 +88,13 @@ func Critique(ctx context.Context, misses []LedgerEntry, callLLM CallLLM) ([]Att
 	return out, nil
 }
 
-// parseAttributions tolerantly extracts the JSON array from an LLM reply that
-// may be wrapped in prose or ```json fences.
-func parseAttributions(raw string) ([]Attribution, error) {
-	js := extractJSONArray(raw)
-	if js == "" {
-		return nil, fmt.Errorf("skillopt critic: no JSON array in reply")
+// parseAttributions decodes the critic reply (already schema-checked).
+func parseAttributions(raw json.RawMessage) ([]Attribution, error) {
+	var out struct {
+		Attributions []Attribution `json:"attributions"`
 	}
-	var attrs []Attribution
-	if err := json.Unmarshal([]byte(js), &attrs); err != nil {
+	if err := json.Unmarshal(raw, &out); err != nil {
 		return nil, fmt.Errorf("skillopt critic: parse attributions: %w", err)
 	}
-	return attrs, nil
-}
-
-// extractJSONArray returns the substring from the first '[' to its matching ']'.
-func extractJSONArray(s string) string {
-	start := strings.IndexByte(s, '[')
-	end := strings.LastIndexByte(s, ']')
-	if start < 0 || end <= start {
-		return ""
-	}
-	return s[start : end+1]
+	return out.Attributions, nil
 }
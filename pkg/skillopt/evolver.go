@@ -28,6 +28,17 @@ const evolverSystemPrompt = `你是「技能进化器」。你只能改写技能
 严格只输出一个 JSON 对象，不要解释、不要 markdown 代码块：
 {"rules": "进化后的规则区全文", "lessons": "进化后的教训区全文"}`
 
+// evolveSchema is the structured-output shape of the evolver reply. Each
+// region may come back as one block or as a list of lines (see stringOrList).
+const evolveSchema = `{
+	"type": "object",
+	"properties": {
+		"rules": {"type": ["string", "array"], "items": {"type": "string"}},
+		"lessons": {"type": ["string", "array"], "items": {"type": "string"}}
+	},
+	"required": ["rules", "lessons"]
+}`
+
 // Evolve produces a bounded-edit Proposal from a batch of attributions.
 //
 //	(nil, nil)  → nothing actionable (no lessons, or deduped against rejection buffer)
@@ -65,7 +76,7 @@ func Evolve(ctx context.Context, s *Store, attrs []Attribution, callLLM CallLLM)
 		strings.TrimSpace(oldRules), strings.TrimSpace(oldLessons), lessonsBuf.String())
 	system := fmt.Sprintf(evolverSystemPrompt, maxRuleLines, maxLessonLines)
 
-	raw, err := callLLM(ctx, system, user)
+	raw, err := callLLM(ctx, system, user, json.RawMessage(evolveSchema))
 	if err != nil {
 		return nil, fmt.Errorf("skillopt evolver llm: %w", err)
 	}
@@ -240,27 +251,14 @@ type evolveOut struct {
 	Lessons stringOrList `json:"lessons"`
 }
 
-func parseEvolveOut(raw string) (evolveOut, error) {
-	js := extractJSONObject(raw)
-	if js == "" {
-		return evolveOut{}, fmt.Errorf("skillopt evolver: no JSON object in reply")
-	}
+func parseEvolveOut(raw json.RawMessage) (evolveOut, error) {
 	var out evolveOut
-	if err := json.Unmarshal([]byte(js), &out); err != nil {
+	if err := json.Unmarshal(raw, &out); err != nil {
 		return evolveOut{}, fmt.Errorf("skillopt evolver: parse output: %w", err)
 	}
 	return out, nil
 }
 
-func extractJSONObject(s string) string {
-	start := strings.IndexByte(s, '{')
-	end := strings.LastIndexByte(s, '}')
-	if start < 0 || end <= start {
-		return ""
-	}
-	return s[start : end+1]
-}
-
 // stringOrList accepts either a JSON string or a JSON array of strings and
 // normalises to a single newline-joined string (the model occasionally returns
 // a list of bullet lines instead of one block).
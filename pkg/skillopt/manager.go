@@ -2,6 +2,7 @@ package skillopt
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -11,9 +12,10 @@ import (
 	"github.com/Zyling-ai/zyhive/pkg/skill"
 )
 
-// CallLLMForAgent runs one system+user completion using a specific agent's model.
-// Production wires pkg/agent.Pool.CallLLMOnce here; tests inject a fake.
-type CallLLMForAgent func(ctx context.Context, agentID, system, user string) (string, error)
+// CallLLMForAgent runs one structured-output completion using a specific
+// agent's model. Production wires pkg/agent.Pool.CallLLMOnceJSON here; tests
+// inject a fake.
+type CallLLMForAgent func(ctx context.Context, agentID, system, user string, schema json.RawMessage) (json.RawMessage, error)
 
 // Manager is the orchestration entry point for SkillOpt maintenance, shared by
 // the cron sentinel and the REST API.
@@ -177,11 +179,11 @@ func (m *Manager) Evolve(ctx context.Context, agentID, workspaceDir, skillID str
 }
 
 func (m *Manager) boundLLM(agentID string) CallLLM {
-	return func(ctx context.Context, system, user string) (string, error) {
+	return func(ctx context.Context, system, user string, schema json.RawMessage) (json.RawMessage, error) {
 		if m.callLLM == nil {
-			return "", fmt.Errorf("skillopt: no LLM caller configured")
+			return nil, fmt.Errorf("skillopt: no LLM caller configured")
 		}
-		return m.callLLM(ctx, agentID, system, user)
+		return m.callLLM(ctx, agentID, system, user, schema)
 	}
 }
 
@@ -12,7 +12,7 @@ import (
 // fakeLLM handles both the critic and evolver prompts deterministically.
 // critic → one attribution per "ID:" line; evolver → fixed rules/lessons.
 func fakeLLM(rules, lessons string) CallLLM {
-	return func(_ context.Context, system, user string) (string, error) {
+	return func(_ context.Context, system, user string, _ json.RawMessage) (json.RawMessage, error) {
 		if strings.Contains(system, "复盘官") { // critic
 			var arr []map[string]any
 			for _, ln := range strings.Split(user, "\n") {
@@ -22,12 +22,10 @@ func fakeLLM(rules, lessons string) CallLLM {
 					arr = append(arr, map[string]any{"entryId": id, "tags": []string{"忽略主场"}, "lesson": "重视主场优势"})
 				}
 			}
-			b, _ := json.Marshal(arr)
-			return string(b), nil
+			return json.Marshal(map[string]any{"attributions": arr})
 		}
 		// evolver
-		out, _ := json.Marshal(map[string]string{"rules": rules, "lessons": lessons})
-		return string(out), nil
+		return json.Marshal(map[string]string{"rules": rules, "lessons": lessons})
 	}
 }
 
@@ -9,11 +9,15 @@
 // callback (dependency injection, mirroring pkg/memory.Consolidate).
 package skillopt
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+)
 
-// CallLLM performs a single system+user completion and returns the text.
-// Injected by the caller (pkg/agent.Pool.CallLLMOnce in production, a fake in tests).
-type CallLLM func(ctx context.Context, system, user string) (string, error)
+// CallLLM performs a single system+user structured-output completion and
+// returns the reply as JSON already checked against schema. Injected by the
+// caller (pkg/agent.Pool.CallLLMOnceJSON in production, a fake in tests).
+type CallLLM func(ctx context.Context, system, user string, schema json.RawMessage) (json.RawMessage, error)
 
 // ── Tunable defaults ────────────────────────────────────────────────────────
 const (
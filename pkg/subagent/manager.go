@@ -3,6 +3,7 @@ package subagent
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -12,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
 	"github.com/google/uuid"
 )
 
@@ -299,12 +301,15 @@ func (m *Manager) Spawn(opts SpawnOpts) (*Task, error) {
 		CreatedAt:        time.Now().UnixMilli(),
 	}
 
+	// The task outlives the spawning tool call, so its context starts from
+	// Background and only carries the parent span over.
+	base := tracing.ContextWithRef(context.Background(), opts.Parent)
 	var ctx context.Context
 	var cancel context.CancelFunc
 	if opts.TimeoutSec > 0 {
-		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(opts.TimeoutSec)*time.Second)
+		ctx, cancel = context.WithTimeout(base, time.Duration(opts.TimeoutSec)*time.Second)
 	} else {
-		ctx, cancel = context.WithCancel(context.Background())
+		ctx, cancel = context.WithCancel(base)
 	}
 
 	m.mu.Lock()
@@ -415,10 +420,18 @@ func (m *Manager) runTask(ctx context.Context, task *Task, enrichedTask string)
 		}
 	}()
 
+	ctx, span := tracing.Start(ctx, tracing.KindSubagent, "subagent "+task.AgentID)
+	span.SetAgent(task.AgentID, task.SessionID)
+	span.SetAttr("task_id", task.ID)
+	span.SetAttr("label", task.Label)
+	span.SetAttr("spawned_by", task.SpawnedBy)
+	span.SetAttr("parent_session_id", task.SpawnedBySession)
+
 	// Mark as running
 	m.mu.Lock()
 	task.Status = TaskRunning
 	task.StartedAt = time.Now().UnixMilli()
+	task.TraceID = tracing.RefFromContext(ctx).TraceID
 	m.mu.Unlock()
 	m.persist(task)
 
@@ -461,6 +474,14 @@ func (m *Manager) runTask(ctx context.Context, task *Task, enrichedTask string)
 	}
 
 	m.persist(task)
+	switch {
+	case taskErr != nil:
+		span.End(taskErr)
+	case task.Status == TaskKilled:
+		span.End(errors.New("killed"))
+	default:
+		span.End(nil)
+	}
 	log.Printf("[subagent] task %s finished: status=%s duration=%s", task.ID, task.Status, task.Duration())
 
 	// Broadcast completion event to parent session
@@ -6,6 +6,8 @@ package subagent
 import (
 	"fmt"
 	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
 )
 
 // BroadcastFn is a function that publishes an event to a session's broadcaster.
@@ -78,6 +80,9 @@ type Task struct {
 	// Structured output from executor (populated via report_result tool)
 	Artifacts []TaskArtifact `json:"artifacts,omitempty"`
 
+	// TraceID links the task to its spans (GET /api/traces/:traceId).
+	TraceID string `json:"traceId,omitempty"`
+
 	CreatedAt int64 `json:"createdAt"`
 	StartedAt int64 `json:"startedAt,omitempty"`
 	EndedAt   int64 `json:"endedAt,omitempty"`
@@ -168,4 +173,7 @@ type SpawnOpts struct {
 	// SharedProjectID grants the spawned agent write access to this project.
 	// The executor can use project_write to deposit output files there.
 	SharedProjectID string
+	// Parent is the span that spawned the task (normally the agent_spawn
+	// tool span); the task's subagent span is recorded as its child.
+	Parent tracing.SpanRef
 }
@@ -0,0 +1,61 @@
+package tokenizer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApproxCountsNearCL100K(t *testing.T) {
+	// want = real cl100k_base counts; the approximation must land within ±25%.
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"Hello, world!", 4},
+		{"The quick brown fox jumps over the lazy dog.", 10},
+		{"func getUserName(id int) (string, error) {\n\treturn users[id].Name, nil\n}", 22},
+		{strings.Repeat("上下文窗口", 20), 100},
+	}
+	tk := Get(FamilyCL100K)
+	for _, tc := range cases {
+		got := tk.Count(tc.text)
+		if lo, hi := tc.want*3/4, tc.want*5/4+1; got < lo || got > hi {
+			t.Errorf("Count(%q) = %d, want %d±25%%", tc.text, got, tc.want)
+		}
+	}
+	if tk.Count("") != 0 {
+		t.Error("empty text should be 0 tokens")
+	}
+}
+
+func TestFamilyOf(t *testing.T) {
+	cases := map[[2]string]string{
+		{"anthropic", "claude-sonnet-4-20250514"}:     FamilyClaude,
+		{"openrouter", "anthropic/claude-3.5-sonnet"}: FamilyClaude,
+		{"openai", "gpt-4o-mini"}:                     FamilyO200K,
+		{"openai", "o3-mini"}:                         FamilyO200K,
+		{"openai", "gpt-4-turbo"}:                     FamilyCL100K,
+		{"gemini", "gemini-2.5-flash"}:                FamilyGemini,
+		{"deepseek", "deepseek-chat"}:                 FamilyCL100K,
+		{"ollama", "llama3"}:                          FamilyCL100K,
+	}
+	for in, want := range cases {
+		if got := FamilyOf(in[0], in[1]); got != want {
+			t.Errorf("FamilyOf(%q, %q) = %q, want %q", in[0], in[1], got, want)
+		}
+	}
+}
+
+type fixedTokenizer struct{}
+
+func (fixedTokenizer) Name() string     { return "fixed" }
+func (fixedTokenizer) Count(string) int { return 42 }
+
+func TestRegisterOverridesFamily(t *testing.T) {
+	orig := Get(FamilyGemini)
+	Register(FamilyGemini, fixedTokenizer{})
+	defer Register(FamilyGemini, orig)
+	if got := For("gemini", "gemini-2.5-pro").Count("anything"); got != 42 {
+		t.Fatalf("Count = %d, want registered tokenizer's 42", got)
+	}
+}
@@ -207,6 +207,31 @@ func (l *Log) ListBySession(sessionID string, limit int) ([]Entry, error) {
 	return out, nil
 }
 
+// SessionEntries returns every entry of a session logged between from and
+// to (UTC days, inclusive), oldest first, with blob overflow inlined. Used
+// by session export, which needs the full tool history rather than a page.
+func (l *Log) SessionEntries(sessionID string, from, to time.Time) ([]Entry, error) {
+	if l == nil {
+		return nil, nil
+	}
+	var out []Entry
+	for d := startOfDay(from.UTC()); !d.After(startOfDay(to.UTC())); d = d.AddDate(0, 0, 1) {
+		path := l.fileFor(d)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		matches, err := l.scanFile(path, func(e *Entry) bool { return e.SessionID == sessionID })
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range matches {
+			out = append(out, *l.materialize(e))
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Timestamp < out[j].Timestamp })
+	return out, nil
+}
+
 // ListAll returns the last `limit` entries across all sessions matching the
 // optional filter. Used by the admin ToolAuditView.
 type ListFilter struct {
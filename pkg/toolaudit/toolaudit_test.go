@@ -209,3 +209,26 @@ func idx(i int) string {
 	}
 	return ""
 }
+
+func TestSessionEntriesSpansDaysAndInlinesBlobs(t *testing.T) {
+	l := New(t.TempDir())
+	day1 := time.Date(2026, 5, 1, 23, 0, 0, 0, time.UTC)
+	day2 := day1.Add(2 * time.Hour)
+	big := strings.Repeat("x", InlineCapBytes+1)
+	for _, e := range []Entry{
+		{Timestamp: day2.UnixMilli(), SessionID: "s1", ToolCallID: "t2", Name: "exec", Result: big},
+		{Timestamp: day1.UnixMilli(), SessionID: "s1", ToolCallID: "t1", Name: "read"},
+		{Timestamp: day1.UnixMilli(), SessionID: "s2", ToolCallID: "t3", Name: "read"},
+	} {
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := l.SessionEntries("s1", day1, day2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].ToolCallID != "t1" || got[1].Result != big || got[1].ResultRef != "" {
+		t.Fatalf("entries = %d %+v", len(got), got[0])
+	}
+}
@@ -11,17 +11,27 @@ import (
 // AgentHealthCtx 是"健康检查"需要的外部配置快照。
 // 由 internal/api 层构造并传入，避免 pkg/tools 反向依赖 config/agent 包。
 type AgentHealthCtx struct {
-	ModelProvider string          // "anthropic" / "openai" / ""；用于判断 image/vision 支持
+	ModelProvider string          // "anthropic" / "openai" / ""；空 = 未知模型，不做视觉判断
+	Model         ModelInfo       // 绑定模型的能力（config.ModelCapabilities 解析结果）
 	ChannelTypes  map[string]bool // 启用的 channel type: feishu / telegram / ...
 	ToolAPIKeys   map[string]bool // 已配置 key 的 tool type: brave_search / elevenlabs / ...
 	HasRelations  bool            // RELATIONS.md 是否有任何条目（决定是否提"派遣受限"）
 }
 
+// ModelInfo 是能力提示词里展示的模型能力快照（镜像 config.ModelCaps，避免反向依赖）。
+type ModelInfo struct {
+	Name          string
+	ContextWindow int
+	MaxOutput     int
+	Vision        bool
+	Reasoning     bool
+}
+
 // 与 internal/api/agent_ext.go::ToolHealth 用同一套判定规则（保持一致性）。
 // 这里不做真实 HTTP 探测，只基于配置存在性检查，启动时调用成本低。
 
 var groupOrder = []string{"fs", "runtime", "web", "browser", "agent", "sessions", "cron",
-	"memory", "project", "self", "messaging", "feishu", "telegram", "ui", "misc"}
+	"memory", "project", "self", "messaging", "feishu", "dingtalk", "wecom", "telegram", "ui", "misc"}
 
 var groupLabel = map[string]string{
 	"fs":        "📁 文件/命令",
@@ -36,6 +46,8 @@ var groupLabel = map[string]string{
 	"self":      "🎛️ 自管理",
 	"messaging": "📨 消息",
 	"feishu":    "📱 飞书",
+	"dingtalk":  "📱 钉钉",
+	"wecom":     "📱 企业微信",
 	"telegram":  "✈️ Telegram",
 	"ui":        "🖼️ UI",
 	"misc":      "🔧 其它",
@@ -102,6 +114,10 @@ func FormatCapabilitiesForPrompt(r *Registry, ctx AgentHealthCtx) string {
 		}
 	}
 
+	if line := formatModelInfo(ctx.Model); line != "" {
+		sb.WriteString("\n" + line + "\n")
+	}
+
 	// 关键约束
 	sb.WriteString("\n📋 关键约束：\n")
 	if ctx.HasRelations {
@@ -114,6 +130,31 @@ func FormatCapabilitiesForPrompt(r *Registry, ctx AgentHealthCtx) string {
 	return sb.String()
 }
 
+// formatModelInfo 渲染"当前模型"一行，让 AI 知道自己的上下文 / 输出上限（例如决定一次读多少文件）。
+func formatModelInfo(m ModelInfo) string {
+	if m.Name == "" || m.ContextWindow <= 0 {
+		return ""
+	}
+	line := fmt.Sprintf("🧠 当前模型：%s（上下文约 %s tokens，单次输出上限 %s", m.Name, humanTokens(m.ContextWindow), humanTokens(m.MaxOutput))
+	if m.Vision {
+		line += "，支持看图"
+	}
+	if m.Reasoning {
+		line += "，支持深度思考"
+	}
+	return line + "）"
+}
+
+func humanTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%dk", n/1_000)
+	}
+	return fmt.Sprintf("%d", n)
+}
+
 // toolGroupOf 给一个工具名分组（和 policy.go/agent_ext.go 的规则保持一致）。
 func toolGroupOf(name string) string {
 	switch {
@@ -137,10 +178,14 @@ func toolGroupOf(name string) string {
 		return "project"
 	case strings.HasPrefix(name, "self_") || name == "wish_add" || name == "wish_list":
 		return "self"
-	case strings.HasPrefix(name, "send_"):
+	case strings.HasPrefix(name, "send_") || strings.HasPrefix(name, "email_"):
 		return "messaging"
 	case strings.HasPrefix(name, "feishu_"):
 		return "feishu"
+	case strings.HasPrefix(name, "dingtalk_"):
+		return "dingtalk"
+	case strings.HasPrefix(name, "wecom_"):
+		return "wecom"
 	case strings.HasPrefix(name, "telegram_"):
 		return "telegram"
 	case name == "image" || name == "show_image" || name == "tts":
@@ -157,18 +202,34 @@ func checkToolReadiness(name string, ctx AgentHealthCtx) (bool, string, string)
 		if !ctx.ToolAPIKeys["brave_search"] {
 			return false, "未配置 Brave Search API Key", "前往「密钥管理」添加 brave_search 类型的 key"
 		}
+	case name == "tts":
+		if !ctx.ToolAPIKeys["openai_tts"] && !ctx.ToolAPIKeys["elevenlabs"] {
+			return false, "未配置语音合成服务", "前往「密钥管理」添加 openai_tts 或 elevenlabs 类型的 key"
+		}
 	case name == "image":
-		if ctx.ModelProvider != "" && ctx.ModelProvider != "anthropic" && ctx.ModelProvider != "openai" {
+		if ctx.ModelProvider != "" && !ctx.Model.Vision {
 			return false, "当前绑定模型不支持视觉", "切换到 Claude / GPT-4o 等多模态模型"
 		}
 	case name == "send_message" || name == "send_file":
 		if len(ctx.ChannelTypes) == 0 {
 			return false, "未绑定任何消息渠道", "先在「渠道」tab 绑定飞书/Telegram 等"
 		}
+	case name == "email_send":
+		if !ctx.ChannelTypes["email"] {
+			return false, "未绑定邮件渠道", "前往「渠道」tab 添加邮件渠道"
+		}
 	case strings.HasPrefix(name, "feishu_"):
 		if !ctx.ChannelTypes["feishu"] {
 			return false, "未绑定飞书渠道", "前往「渠道」tab 添加飞书 Bot"
 		}
+	case strings.HasPrefix(name, "dingtalk_"):
+		if !ctx.ChannelTypes["dingtalk"] {
+			return false, "未绑定钉钉渠道", "前往「渠道」tab 添加钉钉机器人"
+		}
+	case strings.HasPrefix(name, "wecom_"):
+		if !ctx.ChannelTypes["wecom"] {
+			return false, "未绑定企业微信渠道", "前往「渠道」tab 添加企业微信应用"
+		}
 	case strings.HasPrefix(name, "telegram_"):
 		if !ctx.ChannelTypes["telegram"] {
 			return false, "未绑定 Telegram 渠道", "添加 Telegram Bot Token"
@@ -0,0 +1,264 @@
+package tools
+
+// DingTalk Tools — registered when an agent has a DingTalk channel configured.
+// Provides messaging (robot markdown to users / groups) and the org directory
+// (departments, members). Injected via Registry.WithDingTalk(appKey, appSecret, robotCode).
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	lllm "github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+)
+
+// dingtalkClient is a lightweight DingTalk API client with token caching.
+type dingtalkClient struct {
+	appKey    string
+	appSecret string
+	robotCode string
+	apiBase   string // https://api.dingtalk.com (v1.0 OpenAPI)
+	oapiBase  string // https://oapi.dingtalk.com (topapi)
+	mu        sync.Mutex
+	token     string
+	expiry    time.Time
+	hc        *http.Client
+}
+
+func newDingTalkClient(appKey, appSecret, robotCode string) *dingtalkClient {
+	if robotCode == "" {
+		robotCode = appKey
+	}
+	return &dingtalkClient{
+		appKey: appKey, appSecret: appSecret, robotCode: robotCode,
+		apiBase: "https://api.dingtalk.com", oapiBase: "https://oapi.dingtalk.com",
+		hc: netguard.NewSafeClient(15 * time.Second),
+	}
+}
+
+func (c *dingtalkClient) getToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expiry) {
+		return c.token, nil
+	}
+	var tr struct {
+		AccessToken string `json:"accessToken"`
+		ExpireIn    int    `json:"expireIn"`
+		Message     string `json:"message"`
+	}
+	if err := c.post(ctx, c.apiBase+"/v1.0/oauth2/accessToken", "",
+		map[string]string{"appKey": c.appKey, "appSecret": c.appSecret}, &tr); err != nil {
+		return "", err
+	}
+	if tr.AccessToken == "" {
+		return "", fmt.Errorf("dingtalk token error: %s", tr.Message)
+	}
+	expire := tr.ExpireIn
+	if expire <= 0 {
+		expire = 7200
+	}
+	c.token = tr.AccessToken
+	c.expiry = time.Now().Add(time.Duration(expire-300) * time.Second)
+	return c.token, nil
+}
+
+// post sends JSON and decodes the reply; non-2xx responses become errors.
+func (c *dingtalkClient) post(ctx context.Context, u, token string, body, out interface{}) error {
+	data, _ := json.Marshal(body)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("x-acs-dingtalk-access-token", token)
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("dingtalk HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if out != nil && len(raw) > 0 {
+		return json.Unmarshal(raw, out)
+	}
+	return nil
+}
+
+// api calls the v1.0 OpenAPI.
+func (c *dingtalkClient) api(ctx context.Context, path string, body, out interface{}) error {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, c.apiBase+path, token, body, out)
+}
+
+// topapi calls the legacy oapi host and returns its "result".
+func (c *dingtalkClient) topapi(ctx context.Context, path string, body interface{}) (json.RawMessage, error) {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var r struct {
+		ErrCode int             `json:"errcode"`
+		ErrMsg  string          `json:"errmsg"`
+		Result  json.RawMessage `json:"result"`
+	}
+	if err := c.post(ctx, c.oapiBase+path+"?access_token="+url.QueryEscape(token), "", body, &r); err != nil {
+		return nil, err
+	}
+	if r.ErrCode != 0 {
+		return nil, fmt.Errorf("dingtalk error %d: %s", r.ErrCode, r.ErrMsg)
+	}
+	return r.Result, nil
+}
+
+// WithDingTalk registers 3 DingTalk tools using the provided app credentials.
+func (r *Registry) WithDingTalk(appKey, appSecret, robotCode string) {
+	if appKey == "" || appSecret == "" {
+		return
+	}
+	dc := newDingTalkClient(appKey, appSecret, robotCode)
+
+	// 1. dingtalk_send_message
+	r.register(lllm.ToolDef{
+		Name:        "dingtalk_send_message",
+		Description: "以钉钉机器人身份发送 Markdown 消息：给一个或多个成员单聊（user_ids，最多 20 个），或发到机器人所在的群（open_conversation_id）。",
+		InputSchema: json.RawMessage(`{
+			"type":"object",
+			"properties":{
+				"user_ids":{"type":"array","items":{"type":"string"},"description":"接收人 userid 列表（单聊）"},
+				"open_conversation_id":{"type":"string","description":"群会话 openConversationId（群聊，与 user_ids 二选一）"},
+				"title":{"type":"string","description":"消息标题（通知栏展示），默认取正文第一行"},
+				"text":{"type":"string","description":"Markdown 正文"}
+			},
+			"required":["text"]
+		}`),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var p struct {
+			UserIDs            []string `json:"user_ids"`
+			OpenConversationID string   `json:"open_conversation_id"`
+			Title              string   `json:"title"`
+			Text               string   `json:"text"`
+		}
+		if err := json.Unmarshal(input, &p); err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(p.Text) == "" {
+			return "", fmt.Errorf("text is required")
+		}
+		if p.OpenConversationID == "" && len(p.UserIDs) == 0 {
+			return "", fmt.Errorf("user_ids or open_conversation_id is required")
+		}
+		if len(p.UserIDs) > 20 {
+			return "", fmt.Errorf("at most 20 user_ids per message")
+		}
+		if p.Title == "" {
+			p.Title, _, _ = strings.Cut(strings.TrimSpace(p.Text), "\n")
+			p.Title = strings.TrimLeft(p.Title, "#> *")
+		}
+		param, _ := json.Marshal(map[string]string{"title": p.Title, "text": p.Text})
+		body := map[string]interface{}{"robotCode": dc.robotCode, "msgKey": "sampleMarkdown", "msgParam": string(param)}
+		path := "/v1.0/robot/oToMessages/batchSend"
+		if p.OpenConversationID != "" {
+			path = "/v1.0/robot/groupMessages/send"
+			body["openConversationId"] = p.OpenConversationID
+		} else {
+			body["userIds"] = p.UserIDs
+		}
+		var out struct {
+			ProcessQueryKey string `json:"processQueryKey"`
+		}
+		if err := dc.api(ctx, path, body, &out); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("消息已发送，processQueryKey=%s", out.ProcessQueryKey), nil
+	})
+
+	// 2. dingtalk_list_departments
+	r.register(lllm.ToolDef{
+		Name:        "dingtalk_list_departments",
+		Description: "列出钉钉通讯录中某部门的下一级子部门（dept_id 为空时从根部门 1 开始）。需要应用开通通讯录读权限。",
+		InputSchema: json.RawMessage(`{
+			"type":"object",
+			"properties":{
+				"dept_id":{"type":"integer","description":"父部门 ID，默认 1（根部门）"}
+			}
+		}`),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var p struct {
+			DeptID int64 `json:"dept_id"`
+		}
+		_ = json.Unmarshal(input, &p)
+		if p.DeptID <= 0 {
+			p.DeptID = 1
+		}
+		result, err := dc.topapi(ctx, "/topapi/v2/department/listsub", map[string]interface{}{"dept_id": p.DeptID})
+		if err != nil {
+			return "", err
+		}
+		var depts []struct {
+			DeptID   int64  `json:"dept_id"`
+			Name     string `json:"name"`
+			ParentID int64  `json:"parent_id"`
+		}
+		_ = json.Unmarshal(result, &depts)
+		return fJSON(depts), nil
+	})
+
+	// 3. dingtalk_list_users
+	r.register(lllm.ToolDef{
+		Name:        "dingtalk_list_users",
+		Description: "分页列出钉钉某部门的成员（userid + 姓名）。返回 next_cursor 时可继续翻页。",
+		InputSchema: json.RawMessage(`{
+			"type":"object",
+			"properties":{
+				"dept_id":{"type":"integer","description":"部门 ID，默认 1（根部门）"},
+				"cursor":{"type":"integer","description":"分页游标，首次为 0"},
+				"size":{"type":"integer","description":"每页数量，最大 100，默认 50"}
+			}
+		}`),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var p struct {
+			DeptID int64 `json:"dept_id"`
+			Cursor int64 `json:"cursor"`
+			Size   int   `json:"size"`
+		}
+		_ = json.Unmarshal(input, &p)
+		if p.DeptID <= 0 {
+			p.DeptID = 1
+		}
+		if p.Size <= 0 || p.Size > 100 {
+			p.Size = 50
+		}
+		result, err := dc.topapi(ctx, "/topapi/user/listsimple", map[string]interface{}{
+			"dept_id": p.DeptID, "cursor": p.Cursor, "size": p.Size,
+		})
+		if err != nil {
+			return "", err
+		}
+		var page struct {
+			HasMore    bool  `json:"has_more"`
+			NextCursor int64 `json:"next_cursor"`
+			List       []struct {
+				UserID string `json:"userid"`
+				Name   string `json:"name"`
+			} `json:"list"`
+		}
+		_ = json.Unmarshal(result, &page)
+		return fJSON(page), nil
+	})
+}
@@ -0,0 +1,68 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+)
+
+// EmailSenderFunc sends a new mail through the agent's email channel and
+// returns a delivery note (Message-ID, or the draft id when the channel
+// holds outgoing mail for approval).
+// Provided by the channel layer (BotPool) and injected per-agent at registry build time.
+type EmailSenderFunc func(ctx context.Context, to []string, subject, body string) (string, error)
+
+// WithEmailSender registers the email_send tool into the registry.
+// If sender is nil, the tool is not registered (graceful degradation).
+// Like every tool it is subject to ToolPolicy (group:messaging) and approvals.
+func (r *Registry) WithEmailSender(sender EmailSenderFunc) *Registry {
+	if sender == nil {
+		return r
+	}
+
+	r.register(llm.ToolDef{
+		Name:        "email_send",
+		Description: "通过当前智能成员的邮件渠道发送一封新邮件（纯文本）。对方回复会进入独立的邮件会话。若渠道开启了回复审批，邮件会先进入待审批发件箱，由管理员批准后才发出。",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"to": {
+					"type": "array",
+					"items": {"type": "string"},
+					"description": "收件人邮箱地址列表"
+				},
+				"subject": {
+					"type": "string",
+					"description": "邮件主题"
+				},
+				"body": {
+					"type": "string",
+					"description": "邮件正文（纯文本）"
+				}
+			},
+			"required": ["to", "subject", "body"]
+		}`),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var params struct {
+			To      []string `json:"to"`
+			Subject string   `json:"subject"`
+			Body    string   `json:"body"`
+		}
+		if err := json.Unmarshal(input, &params); err != nil {
+			return "", fmt.Errorf("email_send: invalid params: %w", err)
+		}
+		if len(params.To) == 0 || strings.TrimSpace(params.Subject) == "" || strings.TrimSpace(params.Body) == "" {
+			return "", fmt.Errorf("email_send: to, subject and body are required")
+		}
+		note, err := sender(ctx, params.To, params.Subject, params.Body)
+		if err != nil {
+			return "", fmt.Errorf("email_send: %w", err)
+		}
+		return note, nil
+	})
+
+	return r
+}
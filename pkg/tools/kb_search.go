@@ -0,0 +1,101 @@
+// pkg/tools/kb_search.go — kb_search built-in tool.
+// Registers kb_search on the Registry via WithKnowledgeSearch().
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Zyling-ai/zyhive/pkg/knowledge"
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+)
+
+const kbSearchInputSchema = `{
+	"type": "object",
+	"properties": {
+		"query": {
+			"type": "string",
+			"description": "搜索查询，用自然语言描述要找的信息"
+		},
+		"kb": {
+			"type": "string",
+			"description": "只搜索这个知识库 ID（默认搜索全部已挂载的知识库）"
+		},
+		"top_k": {
+			"type": "integer",
+			"description": "返回的段落数量（默认 5，最大 20）",
+			"default": 5
+		}
+	},
+	"required": ["query"]
+}`
+
+// WithKnowledgeSearch registers the kb_search tool over the knowledge bases
+// attached to agentID. Nothing is registered when the agent has none.
+//
+// Attachments are re-checked on every call, so a knowledge base detached
+// mid-session is no longer searched.
+func (r *Registry) WithKnowledgeSearch(kbMgr *knowledge.Manager, agentID string) {
+	kbs := kbMgr.ForAgent(agentID)
+	if len(kbs) == 0 {
+		return
+	}
+	var desc strings.Builder
+	desc.WriteString("检索挂载给你的知识库（上传文档、共享项目、网页），返回最相关的段落及出处（来源路径 + 行号或页码）。" +
+		"回答涉及知识库内容时先检索，并在回答中注明出处。可用知识库：")
+	for _, kb := range kbs {
+		fmt.Fprintf(&desc, "\n- %s（%s）", kb.ID, kb.Name)
+		if kb.Description != "" {
+			desc.WriteString("：" + kb.Description)
+		}
+	}
+
+	r.register(llm.ToolDef{
+		Name:        "kb_search",
+		Description: desc.String(),
+		InputSchema: json.RawMessage(kbSearchInputSchema),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var p struct {
+			Query string `json:"query"`
+			KB    string `json:"kb"`
+			TopK  int    `json:"top_k"`
+		}
+		if err := json.Unmarshal(input, &p); err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(p.Query) == "" {
+			return "", fmt.Errorf("query 不能为空")
+		}
+		topK := p.TopK
+		if topK <= 0 {
+			topK = 5
+		}
+		if topK > 20 {
+			topK = 20
+		}
+
+		var ids []string
+		for _, kb := range kbMgr.ForAgent(agentID) {
+			if p.KB == "" || kb.ID == p.KB {
+				ids = append(ids, kb.ID)
+			}
+		}
+		if len(ids) == 0 {
+			if p.KB != "" {
+				return "", fmt.Errorf("知识库 %q 不存在或未挂载给当前 agent", p.KB)
+			}
+			return "（当前没有挂载的知识库）", nil
+		}
+
+		passages, err := kbMgr.Search(ctx, ids, p.Query, topK)
+		if err != nil {
+			return "", fmt.Errorf("知识库检索失败: %w", err)
+		}
+		if len(passages) == 0 {
+			return "（知识库中未找到相关内容）", nil
+		}
+		return fmt.Sprintf("找到 %d 段相关内容：\n\n%s", len(passages), knowledge.FormatPassages(passages)), nil
+	})
+}
@@ -0,0 +1,43 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
+)
+
+// WithMCP mounts the tools of the selected MCP servers (see
+// config.MCPServerEntry) as mcp__<server>__<tool>. Registration goes through
+// register(), so ToolPolicy allow/deny/ask — including prefix patterns such
+// as "mcp__github__*" — the approval Broker and the runner's toolaudit log
+// apply exactly as for built-in tools.
+//
+// serverIDs comes from the agent's mcpServerIds ("*" = all). A nil manager
+// or empty selection registers nothing.
+func (r *Registry) WithMCP(mgr *mcp.Manager, serverIDs []string) *Registry {
+	if mgr == nil {
+		return r
+	}
+	for _, mt := range mgr.Tools(serverIDs) {
+		schema := mt.Tool.InputSchema
+		if len(schema) == 0 {
+			schema = json.RawMessage(`{"type":"object","properties":{}}`)
+		}
+		desc := mt.Tool.Description
+		if desc == "" {
+			desc = mt.Tool.Title
+		}
+		serverID, toolName := mt.ServerID, mt.Tool.Name
+		r.register(llm.ToolDef{
+			Name:        mt.Name,
+			Description: fmt.Sprintf("[MCP %s] %s", serverID, desc),
+			InputSchema: schema,
+		}, func(ctx context.Context, input json.RawMessage) (string, error) {
+			return mgr.Call(ctx, serverID, toolName, input)
+		})
+	}
+	return r
+}
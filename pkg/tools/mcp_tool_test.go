@@ -0,0 +1,91 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/mcp"
+)
+
+// newTestMCPServer serves a minimal streamable-HTTP MCP server with two
+// tools: "lookup" and "delete_repo".
+func newTestMCPServer(t *testing.T) *mcp.Manager {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if len(req.ID) == 0 {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		var result any
+		switch req.Method {
+		case "initialize":
+			result = map[string]any{"protocolVersion": mcp.ProtocolVersion, "serverInfo": map[string]string{"name": "gh"}}
+		case "tools/list":
+			result = map[string]any{"tools": []map[string]any{
+				{"name": "lookup", "description": "look something up"},
+				{"name": "delete_repo", "description": "dangerous"},
+			}}
+		case "tools/call":
+			result = map[string]any{"content": []map[string]string{{"type": "text", "text": "found it"}}}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(srv.Close)
+	mgr := mcp.NewManager()
+	t.Cleanup(mgr.Close)
+	mgr.Start(context.Background(), []config.MCPServerEntry{{ID: "github", Transport: "http", URL: srv.URL, Enabled: true}})
+	return mgr
+}
+
+func TestMCPToolsRunThroughRegistry(t *testing.T) {
+	mgr := newTestMCPServer(t)
+
+	registry := New(t.TempDir(), t.TempDir(), "agent-1")
+	registry.WithMCP(mgr, nil)
+	if hasTool(registry, "mcp__github__lookup") {
+		t.Fatal("agents without mcpServerIds must not see MCP tools")
+	}
+
+	registry.WithMCP(mgr, []string{"github"})
+	if err := registry.ConfigureGovernance(
+		json.RawMessage(`{"deny":["mcp__github__delete*"]}`),
+		nil, nil, time.Second,
+	); err != nil {
+		t.Fatal(err)
+	}
+	if hasTool(registry, "mcp__github__delete_repo") {
+		t.Fatal("prefix deny pattern did not remove the MCP tool")
+	}
+	out, err := registry.Execute(context.Background(), "mcp__github__lookup", json.RawMessage(`{}`))
+	if err != nil || out != "found it" {
+		t.Fatalf("Execute = %q, %v", out, err)
+	}
+}
+
+func TestMCPPrefixAskPatternRequiresApproval(t *testing.T) {
+	mgr := newTestMCPServer(t)
+	registry := New(t.TempDir(), t.TempDir(), "agent-1")
+	registry.WithMCP(mgr, []string{"*"})
+	if err := registry.ConfigureGovernance(
+		json.RawMessage(`{"ask":["mcp__github__*"]}`),
+		nil, nil, time.Second,
+	); err != nil {
+		t.Fatal(err)
+	}
+	_, err := registry.Execute(context.Background(), "mcp__github__lookup", json.RawMessage(`{}`))
+	if !errors.Is(err, ErrApprovalUnavailable) {
+		t.Fatalf("expected approval gate, got %v", err)
+	}
+}
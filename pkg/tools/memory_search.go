@@ -42,7 +42,7 @@ var memorySearchToolDef = llm.ToolDef{
 // apiKey   — API key for the embedding provider; ignored when embedder is nil.
 //
 // On first use the index is loaded from disk (or built on-the-fly if missing).
-// A background rebuild is triggered when the index is stale.
+// A background incremental update is triggered when the index is stale.
 func (r *Registry) WithMemorySearch(memTree *memory.MemoryTree, embedder *llm.Embedder, apiKey string) {
 	// Trigger an initial async index build/refresh at registration time
 	memory.RebuildIndexIfStale(memTree, embedder, apiKey)
@@ -68,7 +68,7 @@ func (r *Registry) WithMemorySearch(memTree *memory.MemoryTree, embedder *llm.Em
 
 		// Load index (may be empty if not yet built)
 		idx, err := memTree.LoadIndex()
-		if err != nil || len(idx.Chunks) == 0 {
+		if err != nil || idx.Len() == 0 {
 			// 没有索引时同步构建（首次调用）
 			idx, err = memory.BuildIndex(ctx, memTree, embedder, apiKey)
 			if err != nil {
@@ -82,9 +82,10 @@ func (r *Registry) WithMemorySearch(memTree *memory.MemoryTree, embedder *llm.Em
 			memory.RebuildIndexIfStale(memTree, embedder, apiKey)
 		}
 
-		// Optionally embed the query for vector search
+		// Optionally embed the query for vector search (same model as the
+		// chunks; an index from another model waits for its rebuild)
 		var queryVec []float32
-		if embedder != nil && len(idx.Chunks) > 0 && len(idx.Chunks[0].Vec) > 0 {
+		if embedder != nil && idx.HasVectors() && (idx.Model == "" || idx.Model == embedder.Model()) {
 			vecs, embedErr := embedder.Embed(ctx, apiKey, []string{p.Query})
 			if embedErr == nil && len(vecs) > 0 {
 				queryVec = vecs[0]
@@ -104,7 +105,11 @@ func (r *Registry) WithMemorySearch(memTree *memory.MemoryTree, embedder *llm.Em
 		}
 		sb.WriteString(fmt.Sprintf("找到 %d 条相关记忆（搜索模式: %s）：\n\n", len(results), mode))
 		for i, c := range results {
-			sb.WriteString(fmt.Sprintf("[%d] %s:%d\n%s\n\n", i+1, c.Source, c.Line, strings.TrimSpace(c.Text)))
+			cite := fmt.Sprintf("%s:%d", c.Source, c.Line)
+			if c.Page > 0 {
+				cite = fmt.Sprintf("%s（第 %d 页）", c.Source, c.Page)
+			}
+			sb.WriteString(fmt.Sprintf("[%d] %s\n%s\n\n", i+1, cite, strings.TrimSpace(c.Text)))
 		}
 		return strings.TrimRight(sb.String(), "\n"), nil
 	})
@@ -16,10 +16,11 @@ import (
 // Deny wins over allow. Profile sets a base allowlist before allow/deny are applied.
 type ToolPolicy struct {
 	Profile string   `json:"profile,omitempty"` // "full"|"coding"|"messaging"|"minimal"
-	Allow   []string `json:"allow,omitempty"`   // tool names or group:xxx shorthands
-	Deny    []string `json:"deny,omitempty"`    // tool names or group:xxx shorthands
+	Allow   []string `json:"allow,omitempty"`   // tool names, group:xxx shorthands or prefix* patterns
+	Deny    []string `json:"deny,omitempty"`    // tool names, group:xxx shorthands or prefix* patterns
 	// Ask 在 F-01 (26.5.12v1) 引入：tools whose names appear here go through
-	// the approval Broker before executing. Supports group:xxx shorthands.
+	// the approval Broker before executing. Supports group:xxx shorthands
+	// and prefix* patterns.
 	Ask []string `json:"ask,omitempty"`
 }
 
@@ -30,7 +31,7 @@ var toolGroups = map[string][]string{
 	"group:fs":      {"read", "write", "edit", "grep", "glob"},
 	"group:runtime": {"exec", "process", "acp_list", "acp_spawn"},
 	"group:web":     {"web_fetch", "web_search"},
-	"group:memory":  {"memory_search"},
+	"group:memory":  {"memory_search", "kb_search"},
 	"group:ui": {
 		"browser_navigate", "browser_snapshot", "browser_screenshot",
 		"browser_click", "browser_type", "browser_fill", "browser_press",
@@ -42,9 +43,9 @@ var toolGroups = map[string][]string{
 		"agent_list", "agent_spawn", "agent_tasks", "agent_kill", "agent_result",
 		"report_result", "report_to_parent",
 	},
-	"group:sessions":  {"sessions_list", "sessions_history", "sessions_send", "session_rename"},
+	"group:sessions":  {"sessions_list", "sessions_history", "sessions_search", "sessions_send", "session_rename"},
 	"group:cron":      {"cron_list", "cron_add", "cron_remove", "self_schedule"},
-	"group:messaging": {"send_message", "send_file"},
+	"group:messaging": {"send_message", "send_file", "email_send"},
 	"group:self":      {"self_list_skills", "self_install_skill", "self_uninstall_skill", "self_rename", "self_update_soul", "self_set_env", "self_delete_env", "wish_add", "wish_list"},
 	"group:project":   {"project_list", "project_read", "project_write", "project_create", "project_glob"},
 	"group:network":   {"network_note", "chat_note"},
@@ -53,7 +54,7 @@ var toolGroups = map[string][]string{
 // profileAllowlists maps profile name → allowed tool names (nil = all).
 var profileAllowlists = map[string][]string{
 	"minimal": {
-		"send_message", "memory_search",
+		"send_message", "memory_search", "kb_search",
 	},
 	"coding": flatten(
 		toolGroups["group:fs"],
@@ -65,7 +66,7 @@ var profileAllowlists = map[string][]string{
 	"messaging": flatten(
 		toolGroups["group:messaging"],
 		toolGroups["group:sessions"],
-		[]string{"memory_search"},
+		toolGroups["group:memory"],
 	),
 	"full": nil, // nil = no restriction
 }
@@ -100,6 +101,23 @@ func expandNames(patterns []string) map[string]bool {
 	return result
 }
 
+// matchesNames reports whether name is covered by an expanded name set.
+// Besides exact names and the "*" sentinel, entries ending in "*" match by
+// prefix so dynamically named tools can be governed as a family — e.g.
+// "mcp__github__*" covers every tool mounted from the github MCP server.
+func matchesNames(set map[string]bool, name string) bool {
+	name = strings.ToLower(name)
+	if set["*"] || set[name] {
+		return true
+	}
+	for pattern := range set {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // DecodeToolPolicy parses one optional policy layer. Invalid JSON and unknown
 // profiles are rejected so callers can fail closed instead of silently granting
 // the full tool set.
@@ -201,8 +219,7 @@ func allowsAllPolicyLayers(policies []*ToolPolicy, name string) bool {
 }
 
 func policyAllowsTool(policy ToolPolicy, name string) bool {
-	deny := expandNames(policy.Deny)
-	if deny["*"] || deny[name] {
+	if matchesNames(expandNames(policy.Deny), name) {
 		return false
 	}
 	allowed := true
@@ -215,8 +232,7 @@ func policyAllowsTool(policy ToolPolicy, name string) bool {
 			}
 		}
 	}
-	extra := expandNames(policy.Allow)
-	return allowed || extra["*"] || extra[name]
+	return allowed || matchesNames(expandNames(policy.Allow), name)
 }
 
 // ConfigureGovernance is the single finalization point for registry policy and
@@ -21,11 +21,26 @@ import (
 	"github.com/Zyling-ai/zyhive/pkg/safefs"
 	"github.com/Zyling-ai/zyhive/pkg/skill"
 	"github.com/Zyling-ai/zyhive/pkg/subagent"
+	"github.com/Zyling-ai/zyhive/pkg/tracing"
 )
 
 // Handler executes a tool call and returns the result string.
 type Handler func(ctx context.Context, input json.RawMessage) (string, error)
 
+type callIDKey struct{}
+
+// WithCallID tags ctx with the model's tool call ID. The runner sets it
+// before Execute so handlers can derive idempotency keys from the call.
+func WithCallID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, callIDKey{}, id)
+}
+
+// CallID returns the tool call ID set by WithCallID ("" outside a run).
+func CallID(ctx context.Context) string {
+	id, _ := ctx.Value(callIDKey{}).(string)
+	return id
+}
+
 // Registry maps tool names to their definition and handler.
 type Registry struct {
 	defs            []llm.ToolDef
@@ -772,7 +787,7 @@ func (r *Registry) Execute(ctx context.Context, name string, input json.RawMessa
 		return "", fmt.Errorf("unknown tool %q — available tools: [%s]", name, strings.Join(available, ", "))
 	}
 	// Approval gate (F-01). A missing broker fails closed.
-	if r.askNames["*"] || r.askNames[strings.ToLower(name)] {
+	if matchesNames(r.askNames, name) {
 		if r.broker == nil {
 			return "", fmt.Errorf("%w: %s", ErrApprovalUnavailable, name)
 		}
@@ -798,6 +813,18 @@ func (r *Registry) Execute(ctx context.Context, name string, input json.RawMessa
 	return result, nil
 }
 
+// Stub replaces the handler of an already registered tool, keeping its
+// definition so the model still sees and can call it. Returns false when
+// name is not registered. Used by eval replays to neutralise tools whose
+// effects would escape the sandbox.
+func (r *Registry) Stub(name string, h Handler) bool {
+	if _, ok := r.handlers[name]; !ok {
+		return false
+	}
+	r.handlers[name] = h
+	return true
+}
+
 func (r *Registry) register(def llm.ToolDef, h Handler) {
 	if r.governanceConfigured && !allowsAllPolicyLayers(r.policyLayers, def.Name) {
 		return
@@ -1172,7 +1199,7 @@ func (r *Registry) handleSelfUpdateSoul(_ context.Context, input json.RawMessage
 
 // ── Subagent Tools ────────────────────────────────────────────────────────────
 
-func (r *Registry) handleAgentSpawn(_ context.Context, input json.RawMessage) (string, error) {
+func (r *Registry) handleAgentSpawn(ctx context.Context, input json.RawMessage) (string, error) {
 	if r.subagentMgr == nil {
 		return "", fmt.Errorf("subagent manager not configured — cannot dispatch tasks in this context")
 	}
@@ -1302,6 +1329,7 @@ func (r *Registry) handleAgentSpawn(_ context.Context, input json.RawMessage) (s
 		Attachments:      attachments,
 		ContextSnapshot:  contextSnapshot,
 		SharedProjectID:  p.SharedProjectID,
+		Parent:           tracing.RefFromContext(ctx),
 	}
 
 	task, err := r.subagentMgr.Spawn(opts)
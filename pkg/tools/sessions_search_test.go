@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/session"
+)
+
+// storeSearcher searches a single store and records the agent it was asked for.
+type storeSearcher struct {
+	store   *session.Store
+	agentID string
+}
+
+func (s *storeSearcher) SearchSessions(ctx context.Context, agentID string, q session.SearchQuery) ([]session.SearchHit, error) {
+	s.agentID = agentID
+	return s.store.Search(ctx, q)
+}
+
+func TestSessionsSearch(t *testing.T) {
+	store := session.NewStore(t.TempDir())
+	if _, _, err := store.GetOrCreate("ses-1", "agent-1"); err != nil {
+		t.Fatal(err)
+	}
+	msg, _ := json.Marshal("报销流程要先找财务审批")
+	if err := store.AppendMessage("ses-1", "user", msg); err != nil {
+		t.Fatal(err)
+	}
+
+	searcher := &storeSearcher{store: store}
+	r := New(t.TempDir(), t.TempDir(), "agent-1")
+	r.WithSessionSearch(searcher)
+	r.WithSessionID("ses-1")
+
+	out, err := r.handleSessionsSearch(context.Background(), json.RawMessage(`{"query":"财务审批"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if searcher.agentID != "agent-1" || !strings.Contains(out, "ses-1") || !strings.Contains(out, "【财务审批】") || !strings.Contains(out, "（当前会话）") {
+		t.Errorf("output = %s", out)
+	}
+	if out, _ := r.handleSessionsSearch(context.Background(), json.RawMessage(`{"query":"财务","to":"2000-01-01"}`)); !strings.Contains(out, "没有找到") {
+		t.Errorf("date filter output = %s", out)
+	}
+	if _, err := r.handleSessionsSearch(context.Background(), json.RawMessage(`{"query":" "}`)); err == nil {
+		t.Error("empty query accepted")
+	}
+}
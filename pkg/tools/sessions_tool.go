@@ -50,12 +50,18 @@ type SessionTitleWriter interface {
 	GetMeta(sessionID string) (session.SessionIndexEntry, bool)
 }
 
+// SessionSearcher runs full-text / semantic search over one agent's sessions.
+type SessionSearcher interface {
+	SearchSessions(ctx context.Context, agentID string, q session.SearchQuery) ([]session.SearchHit, error)
+}
+
 // sessionToolSet groups the optional session interfaces.
 type sessionToolSet struct {
-	lister SessionLister
-	reader SessionHistoryReader
-	sender SessionSender
-	titler SessionTitleWriter
+	lister   SessionLister
+	reader   SessionHistoryReader
+	sender   SessionSender
+	titler   SessionTitleWriter
+	searcher SessionSearcher
 }
 
 // ── Store adapters ─────────────────────────────────────────────────────────────
@@ -182,6 +188,39 @@ var sessionsHistoryDef = llm.ToolDef{
 	}`),
 }
 
+var sessionsSearchDef = llm.ToolDef{
+	Name: "sessions_search",
+	Description: "在你自己过去的全部会话中搜索消息（全文检索，配置了 embedding 时叠加语义检索）。" +
+		"返回命中的会话 ID、时间和高亮片段；需要上下文时再用 sessions_history 读取该会话。" +
+		"当用户提到「上次说过」「之前讨论的」等需要回忆旧对话时使用。",
+	InputSchema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"query": {
+				"type": "string",
+				"description": "搜索内容，关键词或自然语言（中英文均可）"
+			},
+			"source": {
+				"type": "string",
+				"description": "只搜某个渠道的会话：web / feishu / telegram / slack / discord / email / dingtalk / wecom / webhook / mcp"
+			},
+			"from": {
+				"type": "string",
+				"description": "起始日期 YYYY-MM-DD（含）"
+			},
+			"to": {
+				"type": "string",
+				"description": "结束日期 YYYY-MM-DD（含）"
+			},
+			"limit": {
+				"type": "integer",
+				"description": "返回条数上限（默认 10，最大 50）"
+			}
+		},
+		"required": ["query"]
+	}`),
+}
+
 var sessionsSendDef = llm.ToolDef{
 	Name:        "sessions_send",
 	Description: "向另一个 Agent 的会话发送消息（用于跨 Agent 通信）。",
@@ -295,6 +334,17 @@ func (r *Registry) WithSessionTools(lister SessionLister, reader SessionHistoryR
 	})
 }
 
+// WithSessionSearch registers sessions_search, scoped to this registry's agent.
+func (r *Registry) WithSessionSearch(searcher SessionSearcher) {
+	if r.sessionTools == nil {
+		r.sessionTools = &sessionToolSet{}
+	}
+	r.sessionTools.searcher = searcher
+	r.register(sessionsSearchDef, func(ctx context.Context, input json.RawMessage) (string, error) {
+		return r.handleSessionsSearch(ctx, input)
+	})
+}
+
 // ── Handlers ──────────────────────────────────────────────────────────────────
 
 func (r *Registry) handleSessionsList(_ context.Context, input json.RawMessage) (string, error) {
@@ -399,3 +449,64 @@ func (r *Registry) handleSessionsSend(_ context.Context, input json.RawMessage)
 	}
 	return fmt.Sprintf("✅ 已向 Agent %s 发送消息\n%s", p.AgentID, result), nil
 }
+
+func (r *Registry) handleSessionsSearch(ctx context.Context, input json.RawMessage) (string, error) {
+	if r.sessionTools == nil || r.sessionTools.searcher == nil {
+		return "", fmt.Errorf("session search not configured")
+	}
+	var p struct {
+		Query  string `json:"query"`
+		Source string `json:"source"`
+		From   string `json:"from"`
+		To     string `json:"to"`
+		Limit  int    `json:"limit"`
+	}
+	if err := json.Unmarshal(input, &p); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(p.Query) == "" {
+		return "", fmt.Errorf("query is required")
+	}
+	q := session.SearchQuery{Text: p.Query, Source: p.Source, Limit: p.Limit}
+	if q.Limit <= 0 {
+		q.Limit = 10
+	}
+	if q.Limit > 50 {
+		q.Limit = 50
+	}
+	if p.From != "" {
+		t, err := time.ParseInLocation("2006-01-02", p.From, time.Local)
+		if err != nil {
+			return "", fmt.Errorf("from: %w", err)
+		}
+		q.From = t.UnixMilli()
+	}
+	if p.To != "" {
+		t, err := time.ParseInLocation("2006-01-02", p.To, time.Local)
+		if err != nil {
+			return "", fmt.Errorf("to: %w", err)
+		}
+		q.To = t.AddDate(0, 0, 1).UnixMilli() - 1
+	}
+
+	hits, err := r.sessionTools.searcher.SearchSessions(ctx, r.agentID, q)
+	if err != nil {
+		return "", fmt.Errorf("搜索失败: %w", err)
+	}
+	if len(hits) == 0 {
+		return "（没有找到相关的历史消息）", nil
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("找到 %d 条相关消息：\n\n", len(hits)))
+	for i, h := range hits {
+		sb.WriteString(fmt.Sprintf("[%d] %s · %s · %s", i+1, h.SessionID, time.UnixMilli(h.Timestamp).Format("2006-01-02 15:04"), h.Role))
+		if h.Title != "" {
+			sb.WriteString(fmt.Sprintf(" ·「%s」", h.Title))
+		}
+		if h.SessionID == r.sessionID {
+			sb.WriteString("（当前会话）")
+		}
+		sb.WriteString("\n    " + h.Marked("【", "】") + "\n\n")
+	}
+	return sb.String(), nil
+}
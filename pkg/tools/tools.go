@@ -16,6 +16,7 @@ import (
 	"time"
 
 	"github.com/Zyling-ai/zyhive/pkg/aiteam/promptdef"
+	"github.com/Zyling-ai/zyhive/pkg/docextract"
 	lllm "github.com/Zyling-ai/zyhive/pkg/llm"
 	"github.com/Zyling-ai/zyhive/pkg/netguard"
 )
@@ -24,13 +25,16 @@ import (
 
 var readToolDef = lllm.ToolDef{
 	Name:        "read",
-	Description: "Read the contents of a file. Supports text files. Output is truncated to 2000 lines or 50KB.",
+	Description: "Read the contents of a file. Supports text files; PDF, DOCX, PPTX and XLSX documents are converted to text (select parts with pages / sheet). Output is truncated to 2000 lines or 50KB.",
 	InputSchema: json.RawMessage(`{
 		"type":"object",
 		"properties":{
 			"file_path":{"type":"string","description":"Path to the file to read"},
 			"offset":{"type":"number","description":"Line number to start reading from (1-indexed)"},
-			"limit":{"type":"number","description":"Maximum number of lines to read"}
+			"limit":{"type":"number","description":"Maximum number of lines to read"},
+			"pages":{"type":"string","description":"Documents only: PDF pages or PPTX slides to read, e.g. \"1-5\" or \"2,7,10-\""},
+			"sheet":{"type":"string","description":"Documents only: XLSX sheet names or 1-based indexes, comma separated"},
+			"csv":{"type":"boolean","description":"Documents only: render XLSX sheets as CSV instead of markdown tables"}
 		},
 		"required":["file_path"]
 	}`),
@@ -41,6 +45,9 @@ func handleRead(_ context.Context, input json.RawMessage) (string, error) {
 		FilePath string `json:"file_path"`
 		Offset   int    `json:"offset"`
 		Limit    int    `json:"limit"`
+		Pages    string `json:"pages"`
+		Sheet    string `json:"sheet"`
+		CSV      bool   `json:"csv"`
 	}
 	if err := json.Unmarshal(input, &p); err != nil {
 		return "", fmt.Errorf("invalid input: %v", err)
@@ -55,6 +62,31 @@ func handleRead(_ context.Context, input json.RawMessage) (string, error) {
 		}
 		return "", fmt.Errorf("read %s failed: %v", p.FilePath, err)
 	}
+	header, footer := "", ""
+	if format := docextract.Detect(p.FilePath, "", data); format != "" && format != docextract.FormatHTML {
+		// Binary documents: read the extracted text. HTML stays raw so it
+		// can still be edited.
+		res, err := docextract.Extract(data, p.FilePath, "", docextract.Options{Pages: p.Pages, Sheets: p.Sheet, CSV: p.CSV})
+		if err != nil {
+			return "", fmt.Errorf("extract text from %s failed: %v", p.FilePath, err)
+		}
+		header = "[" + strings.ToUpper(format)
+		if res.Parts > 0 {
+			unit := "pages"
+			switch format {
+			case docextract.FormatPPTX:
+				unit = "slides"
+			case docextract.FormatXLSX:
+				unit = "sheets"
+			}
+			header += fmt.Sprintf(", %d %s", res.Parts, unit)
+		}
+		header += "]\n"
+		if res.Truncated {
+			footer = "\n[truncated: select fewer pages / sheets to read the rest]"
+		}
+		data = []byte(res.Text)
+	}
 	lines := strings.Split(string(data), "\n")
 	start, end := 0, len(lines)
 	if p.Offset > 0 {
@@ -66,7 +98,7 @@ func handleRead(_ context.Context, input json.RawMessage) (string, error) {
 	if start > len(lines) {
 		return "", fmt.Errorf("offset %d exceeds file length %d", p.Offset, len(lines))
 	}
-	return strings.Join(lines[start:end], "\n"), nil
+	return header + strings.Join(lines[start:end], "\n") + footer, nil
 }
 
 // ── Write ───────────────────────────────────────────────────────────────────
@@ -319,12 +351,13 @@ func handleGlob(_ context.Context, input json.RawMessage) (string, error) {
 
 var webFetchToolDef = lllm.ToolDef{
 	Name:        "web_fetch",
-	Description: "Fetch and extract readable content from a URL.",
+	Description: "Fetch and extract readable content from a URL. HTML pages are reduced to their main text; PDF / DOCX / PPTX / XLSX responses are converted to text.",
 	InputSchema: json.RawMessage(`{
 		"type":"object",
 		"properties":{
 			"url":{"type":"string"},
-			"max_chars":{"type":"number"}
+			"max_chars":{"type":"number"},
+			"raw":{"type":"boolean","description":"Return the response body unconverted (e.g. to inspect HTML markup)"}
 		},
 		"required":["url"]
 	}`),
@@ -335,10 +368,15 @@ var newWebFetchClient = func() *http.Client {
 }
 var validateWebFetchURL = netguard.ValidateURL
 
+// webFetchHTMLReadFactor bounds an HTML read at this many bytes per
+// requested character.
+const webFetchHTMLReadFactor = 8
+
 func handleWebFetch(ctx context.Context, input json.RawMessage) (string, error) {
 	var p struct {
 		URL      string `json:"url"`
 		MaxChars int    `json:"max_chars"`
+		Raw      bool   `json:"raw"`
 	}
 	if err := json.Unmarshal(input, &p); err != nil {
 		return "", fmt.Errorf("invalid input: %v", err)
@@ -365,7 +403,24 @@ func handleWebFetch(ctx context.Context, input json.RawMessage) (string, error)
 	if maxChars > 200000 {
 		maxChars = 200000
 	}
-	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxChars)))
+	// Binary documents are converted as a whole, so read them up to the
+	// extractor's limit and cap the extracted text instead. HTML parses
+	// fine when cut short; markup is a few times the text it renders, so
+	// a small multiple of maxChars is plenty.
+	ctype := resp.Header.Get("Content-Type")
+	format := ""
+	if !p.Raw && resp.StatusCode < 400 {
+		format = docextract.Detect(req.URL.Path, ctype, nil)
+	}
+	readLimit := int64(maxChars)
+	switch format {
+	case "":
+	case docextract.FormatHTML:
+		readLimit = int64(maxChars) * webFetchHTMLReadFactor
+	default:
+		readLimit = docextract.MaxInputBytes
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, readLimit))
 	if err != nil {
 		return "", fmt.Errorf("read response body failed: %v", err)
 	}
@@ -377,6 +432,24 @@ func handleWebFetch(ctx context.Context, input json.RawMessage) (string, error)
 		return "", fmt.Errorf("HTTP %d %s\nURL: %s\nResponse: %s",
 			resp.StatusCode, resp.Status, p.URL, snippet)
 	}
+	content := string(body)
+	if format != "" {
+		res, err := docextract.Extract(body, req.URL.Path, ctype, docextract.Options{MaxChars: maxChars})
+		switch {
+		case err == nil:
+			content = res.Text
+			if res.Truncated || int64(len(body)) >= readLimit {
+				content += "\n…(truncated)"
+			}
+		case format == docextract.FormatHTML:
+			// Unparseable page: fall back to the raw markup.
+			if len(body) > maxChars {
+				content = string(body[:maxChars])
+			}
+		default:
+			return "", fmt.Errorf("extract text from %s failed: %v", p.URL, err)
+		}
+	}
 	// PR-008 (S3, 26.5.10v9): when ZYHIVE_EXPERIMENTAL_PROMPTDEF=1, run
 	// the fetched body through promptdef so any inline jailbreak / role-
 	// override text is wrapped in <untrusted_external_content>. The Guard
@@ -384,7 +457,7 @@ func handleWebFetch(ctx context.Context, input json.RawMessage) (string, error)
 	// Audit logging is enabled per-registry via SetPromptDefGuard; here
 	// we only have a free function so we use the package's nil-audit
 	// guard. Registry callers that want audit logs go through Registry.
-	res := promptDefGuard.Wrap(string(body), promptdef.SourceWebFetch, "", "")
+	res := promptDefGuard.Wrap(content, promptdef.SourceWebFetch, "", "")
 	return res.Wrapped, nil
 }
 
@@ -1,9 +1,14 @@
 package tools
 
 import (
+	"archive/zip"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"strings"
@@ -101,6 +106,27 @@ func TestRead(t *testing.T) {
 		assertErr(t, "read/bad-json", "invalid input", err)
 	})
 
+	t.Run("document_text", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := zip.NewWriter(&buf)
+		w, _ := zw.Create("ppt/presentation.xml")
+		w.Write([]byte("<p:presentation/>"))
+		for i, text := range []string{"封面", "议程", "总结"} {
+			w, _ := zw.Create(fmt.Sprintf("ppt/slides/slide%d.xml", i+1))
+			w.Write([]byte(`<p:sld><a:p><a:r><a:t>` + text + `</a:t></a:r></a:p></p:sld>`))
+		}
+		zw.Close()
+		os.WriteFile(filepath.Join(ws, "deck.pptx"), buf.Bytes(), 0644)
+
+		res, err := call(r, "read", map[string]any{"file_path": "deck.pptx", "pages": "2"})
+		assertOK(t, "read/pptx", res, err)
+		if res != "[PPTX, 3 slides]\n--- slide 2 ---\n议程" {
+			t.Errorf("pptx pages=2, got %q", res)
+		}
+		_, err = call(r, "read", map[string]any{"file_path": "deck.pptx", "pages": "9"})
+		assertErr(t, "read/pptx-range", "out of range", err)
+	})
+
 	t.Run("offset_beyond_file", func(t *testing.T) {
 		_, err := call(r, "read", map[string]any{
 			"file_path": "test.txt",
@@ -511,6 +537,49 @@ func TestWebFetch(t *testing.T) {
 		}
 	})
 
+	t.Run("html_converted_to_text", func(t *testing.T) {
+		allowLocalWebFetchForTest(t)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<html><head><title>Doc</title><style>p{}</style></head><body><nav>Menu</nav><main><h2>Intro</h2><p>Readable   text.</p></main></body></html>`))
+		}))
+		defer srv.Close()
+		res, err := call(r, "web_fetch", map[string]any{"url": srv.URL})
+		assertOK(t, "web_fetch/html", res, err)
+		if res != "# Doc\n\n## Intro\n\nReadable text." {
+			t.Errorf("converted page = %q", res)
+		}
+		res, err = call(r, "web_fetch", map[string]any{"url": srv.URL, "raw": true})
+		assertOK(t, "web_fetch/raw", res, err)
+		if !strings.Contains(res, "<nav>Menu</nav>") {
+			t.Errorf("raw page = %q", res)
+		}
+	})
+
+	t.Run("html_read_is_bounded", func(t *testing.T) {
+		allowLocalWebFetchForTest(t)
+		page := "<html><body>" + strings.Repeat("<p>filler text</p>", 1<<18) + "</body></html>"
+		body := &countingReader{r: strings.NewReader(page)}
+		newWebFetchClient = func() *http.Client {
+			return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"text/html"}},
+					Body:       io.NopCloser(body),
+					Request:    req,
+				}, nil
+			})}
+		}
+		res, err := call(r, "web_fetch", map[string]any{"url": "http://example.test/", "max_chars": 1000})
+		assertOK(t, "web_fetch/html-bounded", res, err)
+		if body.n > 1000*webFetchHTMLReadFactor {
+			t.Errorf("read %d bytes of a %d-byte page for max_chars=1000", body.n, len(page))
+		}
+		if !strings.HasSuffix(res, "…(truncated)") {
+			t.Errorf("clipped page should be marked truncated: %q", res[max(0, len(res)-40):])
+		}
+	})
+
 	// Network-dependent tests — skip if offline
 	t.Run("http_404_explicit_status", func(t *testing.T) {
 		_, err := call(r, "web_fetch", map[string]any{
@@ -537,6 +606,22 @@ func TestWebFetch(t *testing.T) {
 	})
 }
 
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
+
 // ─── UNKNOWN TOOL ─────────────────────────────────────────────────────────────
 
 func TestUnknownTool(t *testing.T) {
@@ -0,0 +1,81 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/llm"
+)
+
+// SpeechFunc synthesizes text with the configured TTS provider (voice ""
+// = provider default) and returns the audio and its file extension.
+type SpeechFunc func(ctx context.Context, text, voice string) (audio []byte, ext string, err error)
+
+// WithTTS registers the tts tool: the audio is saved under the workspace
+// (media/tts/) and, in a channel conversation with file delivery, sent to
+// the current chat. Registered only when a TTS provider is configured.
+func (r *Registry) WithTTS(speak SpeechFunc) {
+	if speak == nil {
+		return
+	}
+	r.register(llm.ToolDef{
+		Name:        "tts",
+		Description: "把一段文字合成为语音（使用「密钥管理」中配置的 TTS 服务）。音频保存到工作区 media/tts/ 目录；在 Telegram 等支持发文件的渠道会话中默认直接发送给当前对话。",
+		InputSchema: json.RawMessage(`{
+			"type": "object",
+			"properties": {
+				"text": {
+					"type": "string",
+					"description": "要朗读的文字（最多约 4000 字）"
+				},
+				"voice": {
+					"type": "string",
+					"description": "可选：音色（OpenAI 如 alloy / nova；ElevenLabs 为 voice id），默认使用配置的音色"
+				},
+				"send": {
+					"type": "boolean",
+					"description": "是否发送到当前对话，默认 true；为 false 时只保存文件"
+				}
+			},
+			"required": ["text"]
+		}`),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var p struct {
+			Text  string `json:"text"`
+			Voice string `json:"voice"`
+			Send  *bool  `json:"send"`
+		}
+		if err := json.Unmarshal(input, &p); err != nil {
+			return "", fmt.Errorf("tts: invalid params: %w", err)
+		}
+		if strings.TrimSpace(p.Text) == "" {
+			return "", fmt.Errorf("tts: text is required")
+		}
+		audio, ext, err := speak(ctx, p.Text, p.Voice)
+		if err != nil {
+			return "", fmt.Errorf("tts: %w", err)
+		}
+		rel := filepath.Join("media", "tts", time.Now().Format("20060102-150405")+ext)
+		abs := filepath.Join(r.workspaceDir, rel)
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			return "", fmt.Errorf("tts: %w", err)
+		}
+		if err := os.WriteFile(abs, audio, 0o644); err != nil {
+			return "", fmt.Errorf("tts: %w", err)
+		}
+		result := fmt.Sprintf("语音已保存：%s (%.1f KB)", filepath.ToSlash(rel), float64(len(audio))/1024)
+		if r.fileSender != nil && (p.Send == nil || *p.Send) {
+			status, err := r.fileSender(abs)
+			if err != nil {
+				return result + "\n发送失败：" + err.Error(), nil
+			}
+			result += "\n" + status
+		}
+		return result, nil
+	})
+}
@@ -0,0 +1,231 @@
+package tools
+
+// WeCom Tools — registered when an agent has a WeCom (企业微信) channel configured.
+// Provides messaging (app markdown to members / app chats) and the org
+// directory (departments, members). Injected via Registry.WithWeCom(corpID, secret, agentID).
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	lllm "github.com/Zyling-ai/zyhive/pkg/llm"
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+)
+
+// wecomClient is a lightweight WeCom API client with access_token caching.
+type wecomClient struct {
+	corpID  string
+	secret  string
+	agentID string // the WeCom app's AgentId
+	base    string
+	mu      sync.Mutex
+	token   string
+	expiry  time.Time
+	hc      *http.Client
+}
+
+func newWeComClient(corpID, secret, agentID string) *wecomClient {
+	return &wecomClient{
+		corpID: corpID, secret: secret, agentID: agentID,
+		base: "https://qyapi.weixin.qq.com",
+		hc:   netguard.NewSafeClient(15 * time.Second),
+	}
+}
+
+func (c *wecomClient) getToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token != "" && time.Now().Before(c.expiry) {
+		return c.token, nil
+	}
+	var tr struct {
+		ErrCode     int    `json:"errcode"`
+		ErrMsg      string `json:"errmsg"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	q := url.Values{"corpid": {c.corpID}, "corpsecret": {c.secret}}
+	if err := c.send(ctx, http.MethodGet, c.base+"/cgi-bin/gettoken?"+q.Encode(), nil, &tr); err != nil {
+		return "", err
+	}
+	if tr.ErrCode != 0 {
+		return "", fmt.Errorf("wecom token error %d: %s", tr.ErrCode, tr.ErrMsg)
+	}
+	expire := tr.ExpiresIn
+	if expire <= 0 {
+		expire = 7200
+	}
+	c.token = tr.AccessToken
+	c.expiry = time.Now().Add(time.Duration(expire-300) * time.Second)
+	return c.token, nil
+}
+
+func (c *wecomClient) send(ctx context.Context, method, u string, body, out interface{}) error {
+	var rd io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		rd = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, rd)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 256*1024))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("wecom HTTP %d", resp.StatusCode)
+	}
+	return json.Unmarshal(raw, out)
+}
+
+// do calls a /cgi-bin API with the access token and checks errcode.
+func (c *wecomClient) do(ctx context.Context, method, path string, query url.Values, body interface{}) (map[string]interface{}, error) {
+	token, err := c.getToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("access_token", token)
+	var result map[string]interface{}
+	if err := c.send(ctx, method, c.base+path+"?"+query.Encode(), body, &result); err != nil {
+		return nil, err
+	}
+	if code, ok := result["errcode"].(float64); ok && code != 0 {
+		if code == 40014 || code == 42001 {
+			c.mu.Lock()
+			c.token = ""
+			c.mu.Unlock()
+		}
+		msg, _ := result["errmsg"].(string)
+		return result, fmt.Errorf("wecom error %d: %s", int(code), msg)
+	}
+	return result, nil
+}
+
+// WithWeCom registers 3 WeCom tools using the provided app credentials.
+func (r *Registry) WithWeCom(corpID, secret, agentID string) {
+	if corpID == "" || secret == "" || agentID == "" {
+		return
+	}
+	wc := newWeComClient(corpID, secret, agentID)
+
+	// 1. wecom_send_message
+	r.register(lllm.ToolDef{
+		Name:        "wecom_send_message",
+		Description: "以企业微信应用身份发送 Markdown 消息：给一个或多个成员（user_ids），或发到应用创建的群聊（chat_id）。单条不超过 2048 字节。",
+		InputSchema: json.RawMessage(`{
+			"type":"object",
+			"properties":{
+				"user_ids":{"type":"array","items":{"type":"string"},"description":"接收成员 userid 列表；[\"@all\"] 表示应用可见范围内全员"},
+				"chat_id":{"type":"string","description":"群聊 chatid（与 user_ids 二选一）"},
+				"content":{"type":"string","description":"Markdown 正文"}
+			},
+			"required":["content"]
+		}`),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var p struct {
+			UserIDs []string `json:"user_ids"`
+			ChatID  string   `json:"chat_id"`
+			Content string   `json:"content"`
+		}
+		if err := json.Unmarshal(input, &p); err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(p.Content) == "" {
+			return "", fmt.Errorf("content is required")
+		}
+		body := map[string]interface{}{"msgtype": "markdown", "markdown": map[string]string{"content": p.Content}}
+		path := "/cgi-bin/message/send"
+		switch {
+		case p.ChatID != "":
+			path = "/cgi-bin/appchat/send"
+			body["chatid"] = p.ChatID
+		case len(p.UserIDs) > 0:
+			body["touser"] = strings.Join(p.UserIDs, "|")
+			body["agentid"] = wc.agentID
+		default:
+			return "", fmt.Errorf("user_ids or chat_id is required")
+		}
+		result, err := wc.do(ctx, http.MethodPost, path, nil, body)
+		if err != nil {
+			return "", err
+		}
+		if bad, _ := result["invaliduser"].(string); bad != "" {
+			return fmt.Sprintf("消息已发送，但以下成员无效或不在应用可见范围：%s", bad), nil
+		}
+		if msgID, ok := result["msgid"].(string); ok {
+			return fmt.Sprintf("消息已发送，msgid=%s", msgID), nil
+		}
+		return "消息已发送", nil
+	})
+
+	// 2. wecom_list_departments
+	r.register(lllm.ToolDef{
+		Name:        "wecom_list_departments",
+		Description: "列出企业微信通讯录中某部门及其全部子部门（id 为空时列出应用可见范围内的全部部门）。",
+		InputSchema: json.RawMessage(`{
+			"type":"object",
+			"properties":{
+				"id":{"type":"integer","description":"部门 ID（可选）"}
+			}
+		}`),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var p struct {
+			ID int64 `json:"id"`
+		}
+		_ = json.Unmarshal(input, &p)
+		q := url.Values{}
+		if p.ID > 0 {
+			q.Set("id", strconv.FormatInt(p.ID, 10))
+		}
+		result, err := wc.do(ctx, http.MethodGet, "/cgi-bin/department/list", q, nil)
+		if err != nil {
+			return "", err
+		}
+		return fJSON(result["department"]), nil
+	})
+
+	// 3. wecom_list_users
+	r.register(lllm.ToolDef{
+		Name:        "wecom_list_users",
+		Description: "列出企业微信某部门的成员（userid + 姓名）。",
+		InputSchema: json.RawMessage(`{
+			"type":"object",
+			"properties":{
+				"department_id":{"type":"integer","description":"部门 ID，默认 1（根部门）"}
+			}
+		}`),
+	}, func(ctx context.Context, input json.RawMessage) (string, error) {
+		var p struct {
+			DepartmentID int64 `json:"department_id"`
+		}
+		_ = json.Unmarshal(input, &p)
+		if p.DepartmentID <= 0 {
+			p.DepartmentID = 1
+		}
+		q := url.Values{"department_id": {strconv.FormatInt(p.DepartmentID, 10)}}
+		result, err := wc.do(ctx, http.MethodGet, "/cgi-bin/user/simplelist", q, nil)
+		if err != nil {
+			return "", err
+		}
+		return fJSON(result["userlist"]), nil
+	})
+}
@@ -0,0 +1,283 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OTLP exporter defaults.
+const (
+	otlpBatchSize     = 256
+	otlpQueueSize     = 4096
+	otlpFlushInterval = 5 * time.Second
+)
+
+// OTLPExporter ships spans to an OpenTelemetry collector over OTLP/HTTP
+// using the JSON encoding (POST {endpoint}/v1/traces). Spans are batched
+// in the background; when the queue is full new spans are dropped rather
+// than blocking the run.
+type OTLPExporter struct {
+	url     string
+	headers map[string]string
+	service string
+	client  *http.Client
+
+	queue chan Span
+	flush chan chan struct{}
+	done  chan struct{}
+	once  sync.Once
+}
+
+// NewOTLPExporter starts an exporter for endpoint — a base URL such as
+// http://localhost:4318 or the full /v1/traces URL. serviceName defaults
+// to "zyhive". Call Close to flush on shutdown.
+func NewOTLPExporter(endpoint string, headers map[string]string, serviceName string) *OTLPExporter {
+	url := strings.TrimRight(strings.TrimSpace(endpoint), "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	if serviceName == "" {
+		serviceName = "zyhive"
+	}
+	e := &OTLPExporter{
+		url:     url,
+		headers: headers,
+		service: serviceName,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		queue:   make(chan Span, otlpQueueSize),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+// Record implements Sink.
+func (e *OTLPExporter) Record(sp Span) {
+	select {
+	case e.queue <- sp:
+	default:
+		log.Printf("[tracing] otlp queue full, dropping span %s/%s", sp.TraceID, sp.SpanID)
+	}
+}
+
+// Flush blocks until every queued span has been sent (or ctx ends).
+func (e *OTLPExporter) Flush(ctx context.Context) {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+	case <-e.done:
+		return
+	case <-ctx.Done():
+		return
+	}
+	select {
+	case <-ack:
+	case <-ctx.Done():
+	}
+}
+
+// Close flushes pending spans and stops the background loop.
+func (e *OTLPExporter) Close() {
+	e.once.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		e.Flush(ctx)
+		close(e.done)
+	})
+}
+
+func (e *OTLPExporter) loop() {
+	ticker := time.NewTicker(otlpFlushInterval)
+	defer ticker.Stop()
+	batch := make([]Span, 0, otlpBatchSize)
+	send := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			log.Printf("[tracing] otlp export %d spans: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+	for {
+		select {
+		case sp := <-e.queue:
+			batch = append(batch, sp)
+			if len(batch) >= otlpBatchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-e.flush:
+			for drained := false; !drained; {
+				select {
+				case sp := <-e.queue:
+					batch = append(batch, sp)
+					if len(batch) >= otlpBatchSize {
+						send()
+					}
+				default:
+					drained = true
+				}
+			}
+			send()
+			close(ack)
+		case <-e.done:
+			return
+		}
+	}
+}
+
+func (e *OTLPExporter) export(spans []Span) error {
+	body, err := json.Marshal(otlpPayload(e.service, spans))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// ── OTLP/JSON encoding ──────────────────────────────────────────────────────
+
+type otlpKV struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"` // int64 as decimal string per OTLP/JSON
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 1 = OK, 2 = ERROR
+	Message string `json:"message,omitempty"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"` // 1 = INTERNAL, 3 = CLIENT
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []otlpKV   `json:"attributes,omitempty"`
+	Status            otlpStatus `json:"status"`
+}
+
+func strKV(k, v string) otlpKV { return otlpKV{Key: k, Value: otlpAnyValue{StringValue: &v}} }
+
+func intKV(k string, v int) otlpKV {
+	s := strconv.Itoa(v)
+	return otlpKV{Key: k, Value: otlpAnyValue{IntValue: &s}}
+}
+
+func otlpPayload(service string, spans []Span) map[string]any {
+	out := make([]otlpSpan, 0, len(spans))
+	for _, sp := range spans {
+		out = append(out, toOTLPSpan(sp))
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []otlpKV{strKV("service.name", service)}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "github.com/Zyling-ai/zyhive/pkg/tracing"},
+				"spans": out,
+			}},
+		}},
+	}
+}
+
+func toOTLPSpan(sp Span) otlpSpan {
+	start := sp.StartMs * int64(time.Millisecond)
+	end := start + sp.DurationMs*int64(time.Millisecond)
+	o := otlpSpan{
+		TraceID:           otlpID(sp.TraceID, 16),
+		SpanID:            otlpID(sp.SpanID, 8),
+		Name:              sp.Name,
+		Kind:              1,
+		StartTimeUnixNano: strconv.FormatInt(start, 10),
+		EndTimeUnixNano:   strconv.FormatInt(end, 10),
+		Status:            otlpStatus{Code: 1},
+	}
+	if sp.ParentID != "" {
+		o.ParentSpanID = otlpID(sp.ParentID, 8)
+	}
+	if sp.Kind == KindLLM {
+		o.Kind = 3
+	}
+	if sp.Status == StatusError {
+		o.Status = otlpStatus{Code: 2, Message: sp.Error}
+	}
+	attrs := []otlpKV{strKV("zyhive.span.kind", string(sp.Kind))}
+	add := func(k, v string) {
+		if v != "" {
+			attrs = append(attrs, strKV(k, v))
+		}
+	}
+	add("zyhive.agent_id", sp.AgentID)
+	add("zyhive.session_id", sp.SessionID)
+	add("gen_ai.system", sp.Provider)
+	add("gen_ai.request.model", sp.Model)
+	add("zyhive.tool", sp.Tool)
+	if sp.InputTokens > 0 {
+		attrs = append(attrs, intKV("gen_ai.usage.input_tokens", sp.InputTokens))
+	}
+	if sp.OutputTokens > 0 {
+		attrs = append(attrs, intKV("gen_ai.usage.output_tokens", sp.OutputTokens))
+	}
+	keys := make([]string, 0, len(sp.Attrs))
+	for k := range sp.Attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, sp.Attrs[k])
+	}
+	o.Attributes = attrs
+	return o
+}
+
+// otlpID renders id as the 2*size hex digits OTLP requires. Our ids are
+// 16 hex (8 bytes): span ids pass through, trace ids are left-padded.
+// Anything else (e.g. a caller-supplied X-Trace-Id) is hashed so it still
+// maps to a stable, valid id.
+func otlpID(id string, size int) string {
+	want := size * 2
+	if b, err := hex.DecodeString(id); err == nil && len(b) > 0 && len(b) <= size {
+		return strings.Repeat("0", want-len(id)) + strings.ToLower(id)
+	}
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:size])
+}
@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOTLPExporterPostsBatch(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		bodies []map[string]any
+		paths  []string
+		auth   string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		var body map[string]any
+		_ = json.Unmarshal(raw, &body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		paths = append(paths, r.URL.Path)
+		auth = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer srv.Close()
+
+	e := NewOTLPExporter(srv.URL+"/", map[string]string{"Authorization": "Bearer k"}, "")
+	defer e.Close()
+	start := time.UnixMilli(1_700_000_000_000)
+	e.Record(Span{TraceID: "0123456789abcdef", SpanID: "1111111111111111", Name: "run", Kind: KindRun,
+		StartMs: start.UnixMilli(), DurationMs: 1500, Status: StatusOK, AgentID: "a1"})
+	e.Record(Span{TraceID: "0123456789abcdef", SpanID: "2222222222222222", ParentID: "1111111111111111",
+		Name: "chat gpt-4o", Kind: KindLLM, StartMs: start.UnixMilli(), DurationMs: 900,
+		Status: StatusError, Error: "429", Model: "gpt-4o", InputTokens: 12})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	e.Flush(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(bodies) != 1 || paths[0] != "/v1/traces" || auth != "Bearer k" {
+		t.Fatalf("posts=%d paths=%v auth=%q", len(bodies), paths, auth)
+	}
+	rs := bodies[0]["resourceSpans"].([]any)[0].(map[string]any)
+	svc := rs["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+	if svc["value"].(map[string]any)["stringValue"] != "zyhive" {
+		t.Errorf("service.name = %v", svc)
+	}
+	spans := rs["scopeSpans"].([]any)[0].(map[string]any)["spans"].([]any)
+	if len(spans) != 2 {
+		t.Fatalf("spans = %d", len(spans))
+	}
+	root, llm := spans[0].(map[string]any), spans[1].(map[string]any)
+	if root["traceId"] != "00000000000000000123456789abcdef" || root["spanId"] != "1111111111111111" {
+		t.Errorf("ids = %v / %v", root["traceId"], root["spanId"])
+	}
+	if root["startTimeUnixNano"] != "1700000000000000000" || root["endTimeUnixNano"] != "1700000001500000000" {
+		t.Errorf("times = %v..%v", root["startTimeUnixNano"], root["endTimeUnixNano"])
+	}
+	if llm["parentSpanId"] != "1111111111111111" || llm["kind"] != float64(3) {
+		t.Errorf("llm span = %v", llm)
+	}
+	if st := llm["status"].(map[string]any); st["code"] != float64(2) || st["message"] != "429" {
+		t.Errorf("status = %v", st)
+	}
+}
+
+func TestOTLPIDHashesForeignIDs(t *testing.T) {
+	if got := otlpID("ABCDEF", 8); got != "0000000000abcdef" {
+		t.Errorf("short hex = %q", got)
+	}
+	a, b := otlpID("req-trace", 16), otlpID("req-trace", 16)
+	if len(a) != 32 || a != b {
+		t.Errorf("foreign id not stable 32-hex: %q %q", a, b)
+	}
+}
@@ -0,0 +1,171 @@
+package tracing
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRetentionDays is used when NewStore gets retentionDays <= 0.
+const DefaultRetentionDays = 7
+
+// Store is the local JSONL sink, one file per UTC day:
+//
+//	{dir}/2026-05-12.jsonl  ← one finished span per line
+//
+// Files older than the retention window are removed when the day rolls
+// over. nil-safe: methods on a nil *Store are no-ops.
+type Store struct {
+	dir       string
+	retention int
+
+	mu      sync.Mutex
+	lastDay string
+}
+
+// NewStore returns a Store rooted at dir ("" disables, returns nil).
+func NewStore(dir string, retentionDays int) *Store {
+	if dir == "" {
+		return nil
+	}
+	if retentionDays <= 0 {
+		retentionDays = DefaultRetentionDays
+	}
+	return &Store{dir: dir, retention: retentionDays}
+}
+
+// Dir returns the directory holding the JSONL files.
+func (s *Store) Dir() string {
+	if s == nil {
+		return ""
+	}
+	return s.dir
+}
+
+// Record implements Sink. Write errors are logged, never returned: tracing
+// must not break the run it observes.
+func (s *Store) Record(sp Span) {
+	if err := s.Append(sp); err != nil {
+		log.Printf("[tracing] append span %s/%s: %v", sp.TraceID, sp.SpanID, err)
+	}
+}
+
+// Append writes one span to the file for its start day.
+func (s *Store) Append(sp Span) error {
+	if s == nil {
+		return nil
+	}
+	raw, err := json.Marshal(sp)
+	if err != nil {
+		return err
+	}
+	day := time.UnixMilli(sp.StartMs).UTC().Format("2006-01-02")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.MkdirAll(s.dir, 0o700); err != nil {
+		return err
+	}
+	if day > s.lastDay {
+		s.lastDay = day
+		s.pruneLocked(time.Now())
+	}
+	f, err := os.OpenFile(filepath.Join(s.dir, day+".jsonl"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(raw, '\n'))
+	return err
+}
+
+// Get returns every span of traceID sorted by start time (nil if unknown).
+// A trace may straddle midnight, so all retained files are scanned.
+func (s *Store) Get(traceID string) ([]Span, error) {
+	if s == nil || traceID == "" {
+		return nil, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := s.filesLocked()
+	if err != nil {
+		return nil, err
+	}
+	needle := []byte(`"traceId":"` + traceID + `"`)
+	var out []Span
+	for _, name := range files {
+		f, err := os.Open(filepath.Join(s.dir, name))
+		if err != nil {
+			continue
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+		for sc.Scan() {
+			line := sc.Bytes()
+			if !bytes.Contains(line, needle) {
+				continue
+			}
+			var sp Span
+			if json.Unmarshal(line, &sp) == nil && sp.TraceID == traceID {
+				out = append(out, sp)
+			}
+		}
+		f.Close()
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].StartMs < out[j].StartMs })
+	return out, nil
+}
+
+// Prune removes day files older than the retention window.
+func (s *Store) Prune(now time.Time) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked(now)
+}
+
+func (s *Store) pruneLocked(now time.Time) {
+	files, err := s.filesLocked()
+	if err != nil {
+		return
+	}
+	cutoff := now.UTC().AddDate(0, 0, -s.retention).Format("2006-01-02")
+	for _, name := range files {
+		if strings.TrimSuffix(name, ".jsonl") < cutoff {
+			_ = os.Remove(filepath.Join(s.dir, name))
+		}
+	}
+}
+
+// filesLocked lists the day files, oldest first.
+func (s *Store) filesLocked() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".jsonl") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Dir returns the conventional trace directory under the agents dir.
+func Dir(agentsDir string) string {
+	return filepath.Join(agentsDir, ".traces")
+}
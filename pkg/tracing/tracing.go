@@ -0,0 +1,309 @@
+// Package tracing — run-level spans so one turn can be inspected as a tree:
+//
+//	channel / cron
+//	  └─ run                (runner.run)
+//	       ├─ llm           (one per model turn: model, tokens, stop reason)
+//	       └─ tool          (one per tool call)
+//	            └─ subagent (agent_spawn → subagent.Manager.runTask)
+//	                 └─ run → llm / tool …
+//
+// Spans are linked through context: Start reads the parent from ctx and
+// returns a child ctx carrying the new span. The trace id is shared with
+// pkg/logging, so `trace_id` in log lines matches the trace on disk.
+//
+// Recording is fire-and-forget: finished spans go to every registered Sink
+// (the local JSONL Store, optionally an OTLP exporter). With no default
+// Tracer installed Start still propagates ids but records nothing, so call
+// sites never need to check whether tracing is enabled.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/logging"
+)
+
+// Kind classifies a span.
+type Kind string
+
+const (
+	KindRun      Kind = "run"
+	KindLLM      Kind = "llm"
+	KindTool     Kind = "tool"
+	KindSubagent Kind = "subagent"
+	KindCron     Kind = "cron"
+	KindChannel  Kind = "channel"
+)
+
+// Span status values.
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+// Span is one finished unit of work, the row persisted to JSONL.
+type Span struct {
+	TraceID      string            `json:"traceId"`
+	SpanID       string            `json:"spanId"`
+	ParentID     string            `json:"parentId,omitempty"`
+	Name         string            `json:"name"`
+	Kind         Kind              `json:"kind"`
+	AgentID      string            `json:"agentId,omitempty"`
+	SessionID    string            `json:"sessionId,omitempty"`
+	StartMs      int64             `json:"startMs"` // UnixMilli
+	DurationMs   int64             `json:"durationMs"`
+	Status       string            `json:"status"` // ok | error
+	Error        string            `json:"error,omitempty"`
+	Provider     string            `json:"provider,omitempty"`
+	Model        string            `json:"model,omitempty"`
+	Tool         string            `json:"tool,omitempty"`
+	InputTokens  int               `json:"inputTokens,omitempty"`
+	OutputTokens int               `json:"outputTokens,omitempty"`
+	Attrs        map[string]string `json:"attrs,omitempty"`
+}
+
+// Sink receives finished spans. Implementations must be safe for
+// concurrent use and should not block for long.
+type Sink interface {
+	Record(Span)
+}
+
+// Tracer fans finished spans out to its sinks.
+type Tracer struct {
+	sinks []Sink
+}
+
+// NewTracer returns a Tracer writing to sinks (nil sinks are skipped).
+func NewTracer(sinks ...Sink) *Tracer {
+	t := &Tracer{}
+	for _, s := range sinks {
+		if s != nil {
+			t.sinks = append(t.sinks, s)
+		}
+	}
+	return t
+}
+
+func (t *Tracer) record(s Span) {
+	for _, sink := range t.sinks {
+		sink.Record(s)
+	}
+}
+
+var defaultTracer atomic.Pointer[Tracer]
+
+// SetDefault installs the process-wide tracer (nil disables recording).
+func SetDefault(t *Tracer) { defaultTracer.Store(t) }
+
+// Default returns the process-wide tracer, nil when tracing is disabled.
+func Default() *Tracer { return defaultTracer.Load() }
+
+// SpanRef identifies a span across goroutine / context boundaries, e.g.
+// a subagent whose run context is detached from the spawning tool call.
+type SpanRef struct {
+	TraceID string `json:"traceId,omitempty"`
+	SpanID  string `json:"spanId,omitempty"`
+}
+
+type ctxKey struct{}
+
+// RefFromContext returns the innermost span in ctx (zero when none).
+func RefFromContext(ctx context.Context) SpanRef {
+	if ctx == nil {
+		return SpanRef{}
+	}
+	ref, _ := ctx.Value(ctxKey{}).(SpanRef)
+	return ref
+}
+
+// ContextWithRef makes ref the parent of spans started from the returned
+// ctx and binds its trace id for logging. A zero ref returns ctx as-is.
+func ContextWithRef(ctx context.Context, ref SpanRef) context.Context {
+	if ref.TraceID == "" {
+		return ctx
+	}
+	ctx = context.WithValue(ctx, ctxKey{}, ref)
+	return logging.WithTraceID(ctx, ref.TraceID)
+}
+
+// ActiveSpan is a started, not yet ended span. All methods are nil-safe;
+// Start returns nil when no tracer is installed.
+type ActiveSpan struct {
+	tracer *Tracer
+	start  time.Time
+	once   sync.Once
+	mu     sync.Mutex
+	span   Span
+}
+
+// Start opens a span of kind under the span in ctx (or a new root). The
+// trace id is inherited from the parent, else from logging.TraceID(ctx),
+// else freshly generated. Agent/session ids default to the logging ctx.
+func Start(ctx context.Context, kind Kind, name string) (context.Context, *ActiveSpan) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := RefFromContext(ctx)
+	traceID := parent.TraceID
+	if traceID == "" {
+		traceID = logging.TraceID(ctx)
+	}
+	if traceID == "" {
+		traceID = logging.NewTraceID()
+	}
+	ref := SpanRef{TraceID: traceID, SpanID: newSpanID()}
+	ctx = ContextWithRef(ctx, ref)
+
+	t := Default()
+	if t == nil {
+		return ctx, nil
+	}
+	return ctx, &ActiveSpan{
+		tracer: t,
+		start:  time.Now(),
+		span: Span{
+			TraceID:   ref.TraceID,
+			SpanID:    ref.SpanID,
+			ParentID:  parent.SpanID,
+			Name:      name,
+			Kind:      kind,
+			AgentID:   logging.AgentID(ctx),
+			SessionID: logging.SessionID(ctx),
+		},
+	}
+}
+
+// SetAgent records the agent / session the span belongs to.
+func (s *ActiveSpan) SetAgent(agentID, sessionID string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if agentID != "" {
+		s.span.AgentID = agentID
+	}
+	if sessionID != "" {
+		s.span.SessionID = sessionID
+	}
+}
+
+// SetModel records the provider / model answering an llm span.
+func (s *ActiveSpan) SetModel(provider, model string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Provider, s.span.Model = provider, model
+}
+
+// SetTool records the tool name of a tool span.
+func (s *ActiveSpan) SetTool(name string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.Tool = name
+}
+
+// AddUsage accumulates token counts (run spans sum their llm turns).
+func (s *ActiveSpan) AddUsage(input, output int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.span.InputTokens += input
+	s.span.OutputTokens += output
+}
+
+// SetAttr sets a free-form attribute; empty values are ignored.
+func (s *ActiveSpan) SetAttr(key, value string) {
+	if s == nil || value == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.span.Attrs == nil {
+		s.span.Attrs = make(map[string]string)
+	}
+	s.span.Attrs[key] = value
+}
+
+// Ref returns the span's id pair (zero for a nil span).
+func (s *ActiveSpan) Ref() SpanRef {
+	if s == nil {
+		return SpanRef{}
+	}
+	return SpanRef{TraceID: s.span.TraceID, SpanID: s.span.SpanID}
+}
+
+// End finishes the span with err's status and hands it to the sinks.
+// Only the first call has any effect.
+func (s *ActiveSpan) End(err error) {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		s.mu.Lock()
+		sp := s.span
+		s.mu.Unlock()
+		sp.StartMs = s.start.UnixMilli()
+		sp.DurationMs = time.Since(s.start).Milliseconds()
+		sp.Status = StatusOK
+		if err != nil {
+			sp.Status = StatusError
+			sp.Error = err.Error()
+		}
+		s.tracer.record(sp)
+	})
+}
+
+// Node is a span with its children, as returned by BuildTree.
+type Node struct {
+	Span
+	Children []*Node `json:"children,omitempty"`
+}
+
+// BuildTree links spans by ParentID. Spans whose parent is not in the set
+// (e.g. still running or pruned) become roots. Siblings sort by start.
+func BuildTree(spans []Span) []*Node {
+	nodes := make(map[string]*Node, len(spans))
+	for _, s := range spans {
+		nodes[s.SpanID] = &Node{Span: s}
+	}
+	var roots []*Node
+	for _, s := range spans {
+		n := nodes[s.SpanID]
+		if p, ok := nodes[s.ParentID]; ok && s.ParentID != s.SpanID {
+			p.Children = append(p.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	var sortNodes func([]*Node)
+	sortNodes = func(ns []*Node) {
+		sort.SliceStable(ns, func(i, j int) bool { return ns[i].StartMs < ns[j].StartMs })
+		for _, n := range ns {
+			sortNodes(n.Children)
+		}
+	}
+	sortNodes(roots)
+	return roots
+}
+
+func newSpanID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "span-fallback"
+	}
+	return hex.EncodeToString(b[:])
+}
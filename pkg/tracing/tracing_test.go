@@ -0,0 +1,189 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/logging"
+)
+
+// memSink collects spans in memory.
+type memSink struct {
+	mu    sync.Mutex
+	spans []Span
+}
+
+func (m *memSink) Record(s Span) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spans = append(m.spans, s)
+}
+
+func (m *memSink) byName() map[string]Span {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]Span, len(m.spans))
+	for _, s := range m.spans {
+		out[s.Name] = s
+	}
+	return out
+}
+
+func installSink(t *testing.T) *memSink {
+	t.Helper()
+	sink := &memSink{}
+	SetDefault(NewTracer(sink))
+	t.Cleanup(func() { SetDefault(nil) })
+	return sink
+}
+
+func TestStartLinksParentAndChild(t *testing.T) {
+	sink := installSink(t)
+	ctx := logging.WithAgent(context.Background(), "a1")
+
+	ctx, run := Start(ctx, KindRun, "run")
+	run.SetModel("openai", "gpt-4o")
+	llmCtx, call := Start(ctx, KindLLM, "chat")
+	call.AddUsage(100, 20)
+	call.End(nil)
+	_, tool := Start(ctx, KindTool, "tool exec")
+	tool.SetTool("exec")
+	tool.End(errors.New("boom"))
+	run.AddUsage(100, 20)
+	run.End(nil)
+
+	got := sink.byName()
+	if len(got) != 3 {
+		t.Fatalf("recorded %d spans, want 3", len(got))
+	}
+	root, llm, tl := got["run"], got["chat"], got["tool exec"]
+	if root.ParentID != "" || root.TraceID == "" {
+		t.Errorf("root span = %+v", root)
+	}
+	for _, s := range []Span{llm, tl} {
+		if s.TraceID != root.TraceID || s.ParentID != root.SpanID {
+			t.Errorf("%s not a child of run: %+v", s.Name, s)
+		}
+		if s.AgentID != "a1" {
+			t.Errorf("%s agent = %q, want inherited a1", s.Name, s.AgentID)
+		}
+	}
+	if llm.InputTokens != 100 || llm.OutputTokens != 20 || llm.Status != StatusOK {
+		t.Errorf("llm span = %+v", llm)
+	}
+	if tl.Status != StatusError || tl.Error != "boom" || tl.Tool != "exec" {
+		t.Errorf("tool span = %+v", tl)
+	}
+	if logging.TraceID(llmCtx) != root.TraceID {
+		t.Errorf("logging trace id %q, want %q", logging.TraceID(llmCtx), root.TraceID)
+	}
+}
+
+func TestStartReusesLoggingTraceID(t *testing.T) {
+	sink := installSink(t)
+	ctx := logging.WithTraceID(context.Background(), "req-trace")
+	_, s := Start(ctx, KindChannel, "telegram dispatch")
+	s.End(nil)
+	s.End(errors.New("ignored")) // only the first End counts
+	if len(sink.spans) != 1 || sink.spans[0].TraceID != "req-trace" || sink.spans[0].Status != StatusOK {
+		t.Fatalf("spans = %+v", sink.spans)
+	}
+}
+
+func TestContextWithRefCrossesDetachedContexts(t *testing.T) {
+	sink := installSink(t)
+	ctx, tool := Start(context.Background(), KindTool, "tool agent_spawn")
+	ref := RefFromContext(ctx)
+	tool.End(nil)
+
+	// The subagent runs on a fresh Background context.
+	_, sub := Start(ContextWithRef(context.Background(), ref), KindSubagent, "subagent")
+	sub.End(nil)
+
+	got := sink.byName()
+	if got["subagent"].ParentID != got["tool agent_spawn"].SpanID || got["subagent"].TraceID != ref.TraceID {
+		t.Fatalf("subagent not linked to spawning tool: %+v", got)
+	}
+}
+
+func TestStartWithoutTracerIsNoop(t *testing.T) {
+	SetDefault(nil)
+	ctx, s := Start(context.Background(), KindRun, "run")
+	if s != nil {
+		t.Fatal("expected nil span without a tracer")
+	}
+	// nil-safe methods, ids still propagate.
+	s.SetModel("p", "m")
+	s.AddUsage(1, 1)
+	s.SetAttr("k", "v")
+	s.End(nil)
+	if RefFromContext(ctx).TraceID == "" {
+		t.Error("trace id not propagated without a tracer")
+	}
+}
+
+func TestBuildTree(t *testing.T) {
+	spans := []Span{
+		{SpanID: "c2", ParentID: "r", StartMs: 30},
+		{SpanID: "r", StartMs: 10},
+		{SpanID: "c1", ParentID: "r", StartMs: 20},
+		{SpanID: "g", ParentID: "c2", StartMs: 40},
+		{SpanID: "orphan", ParentID: "missing", StartMs: 5},
+	}
+	roots := BuildTree(spans)
+	if len(roots) != 2 || roots[0].SpanID != "orphan" || roots[1].SpanID != "r" {
+		t.Fatalf("roots = %+v", roots)
+	}
+	r := roots[1]
+	if len(r.Children) != 2 || r.Children[0].SpanID != "c1" || r.Children[1].SpanID != "c2" {
+		t.Fatalf("children = %+v", r.Children)
+	}
+	if len(r.Children[1].Children) != 1 || r.Children[1].Children[0].SpanID != "g" {
+		t.Fatalf("grandchild missing: %+v", r.Children[1])
+	}
+}
+
+func TestStoreAppendGetAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir, 2)
+	now := time.Now().UTC()
+	yesterday := now.AddDate(0, 0, -1)
+
+	// One trace straddling midnight plus an unrelated one.
+	for _, sp := range []Span{
+		{TraceID: "t1", SpanID: "b", ParentID: "a", Name: "child", StartMs: now.UnixMilli()},
+		{TraceID: "t1", SpanID: "a", Name: "root", StartMs: yesterday.UnixMilli()},
+		{TraceID: "t2", SpanID: "x", Name: "other", StartMs: now.UnixMilli()},
+	} {
+		if err := s.Append(sp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := s.Get("t1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Name != "root" || got[1].Name != "child" {
+		t.Fatalf("Get(t1) = %+v", got)
+	}
+	if got, _ := s.Get("nope"); got != nil {
+		t.Errorf("Get(unknown) = %+v", got)
+	}
+
+	old := filepath.Join(dir, now.AddDate(0, 0, -10).Format("2006-01-02")+".jsonl")
+	if err := os.WriteFile(old, []byte("{}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s.Prune(now)
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expired file not pruned: %v", err)
+	}
+	if got, _ := s.Get("t1"); len(got) != 2 {
+		t.Errorf("retained trace lost after prune: %+v", got)
+	}
+}
@@ -0,0 +1,366 @@
+// Package transcript exports a session as a portable bundle — messages,
+// compaction summaries, the tool-audit history and inline attachments —
+// renders it as Markdown or self-contained HTML, and imports a bundle as a
+// new session of any agent.
+//
+// The JSON bundle is the interchange format (BundleFormat, BundleVersion);
+// Markdown and HTML are read-only views generated from it. Redaction is
+// applied to the bundle before rendering, so every output is redacted alike.
+package transcript
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/session"
+	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
+)
+
+const (
+	BundleFormat  = "zyhive-session"
+	BundleVersion = 1
+)
+
+// redactedInput replaces tool inputs when Options.RedactToolInputs is set.
+const redactedInput = `{"_redacted":true}`
+
+// Bundle is the JSON export of one session.
+type Bundle struct {
+	Format     string        `json:"format"`  // always BundleFormat
+	Version    int           `json:"version"` // BundleVersion
+	ExportedAt int64         `json:"exportedAt"`
+	Agent      BundleAgent   `json:"agent"`
+	Session    BundleSession `json:"session"`
+	// Entries are the visible history in file order; rewound messages are
+	// not exported.
+	Entries []Entry `json:"entries"`
+	// ToolCalls is the full tool-audit history of the session, oldest first.
+	ToolCalls []toolaudit.Entry `json:"toolCalls,omitempty"`
+	// Attachments hold the bytes of image / document blocks, which refer to
+	// them by ID (source.type "attachment").
+	Attachments []Attachment `json:"attachments,omitempty"`
+	// Redacted lists what was masked: "toolInputs", "env".
+	Redacted []string `json:"redacted,omitempty"`
+}
+
+// BundleAgent identifies the exporting agent.
+type BundleAgent struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// BundleSession is the exported session metadata.
+type BundleSession struct {
+	ID         string `json:"id"`
+	Title      string `json:"title,omitempty"`
+	Source     string `json:"source,omitempty"`
+	CreatedAt  int64  `json:"createdAt"`
+	LastAt     int64  `json:"lastAt"`
+	ParentID   string `json:"parentId,omitempty"`
+	ForkedFrom string `json:"forkedFrom,omitempty"`
+}
+
+// Entry is one history entry: a message or a compaction summary.
+type Entry struct {
+	Type      string `json:"type"` // "message" | "compaction"
+	ID        string `json:"id,omitempty"`
+	Role      string `json:"role,omitempty"`
+	Timestamp int64  `json:"timestamp"`
+	// Content is the model content as stored: a string or content blocks.
+	Content   json.RawMessage          `json:"content,omitempty"`
+	ToolCalls []session.ToolCallRecord `json:"toolCalls,omitempty"`
+	Summary   string                   `json:"summary,omitempty"`
+}
+
+// Attachment is a binary payload lifted out of message content.
+type Attachment struct {
+	ID        string `json:"id"` // sha256 of the bytes, hex
+	MediaType string `json:"mediaType"`
+	Size      int    `json:"size"`
+	Data      string `json:"data"` // base64
+}
+
+// Source is what Build reads from.
+type Source struct {
+	Store     *session.Store
+	SessionID string
+	AgentID   string
+	AgentName string
+	Audit     *toolaudit.Log // nil = no tool history
+}
+
+// Options control redaction.
+type Options struct {
+	RedactToolInputs bool              // replace tool inputs with {"_redacted":true}
+	Env              map[string]string // values are replaced with [env:KEY]; nil = keep
+}
+
+// Build exports the visible history of a session.
+func Build(src Source, opts Options) (*Bundle, error) {
+	meta, ok := src.Store.GetMeta(src.SessionID)
+	if !ok {
+		return nil, fmt.Errorf("session %s not found", src.SessionID)
+	}
+	raws, err := src.Store.ReadAll(src.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	b := &Bundle{
+		Format:     BundleFormat,
+		Version:    BundleVersion,
+		ExportedAt: time.Now().UnixMilli(),
+		Agent:      BundleAgent{ID: src.AgentID, Name: src.AgentName},
+		Session: BundleSession{
+			ID:         meta.ID,
+			Title:      meta.Title,
+			Source:     meta.Source,
+			CreatedAt:  meta.CreatedAt,
+			LastAt:     meta.LastAt,
+			ParentID:   meta.ParentID,
+			ForkedFrom: meta.ForkedFrom,
+		},
+		Entries: []Entry{},
+	}
+	atts := map[string]Attachment{}
+	ids := session.MessageIDs(raws)
+	for i, raw := range raws {
+		var base session.BaseEntry
+		if json.Unmarshal(raw, &base) != nil {
+			continue
+		}
+		switch base.Type {
+		case session.EntryTypeMessage:
+			var me session.MessageEntry
+			if json.Unmarshal(raw, &me) != nil || me.RewoundAt != 0 {
+				continue
+			}
+			b.Entries = append(b.Entries, Entry{
+				Type:      string(session.EntryTypeMessage),
+				ID:        ids[i],
+				Role:      me.Message.Role,
+				Timestamp: me.Timestamp,
+				Content:   liftAttachments(me.Message.Content, atts),
+				ToolCalls: me.Message.ToolCalls,
+			})
+		case session.EntryTypeCompaction:
+			var ce session.CompactionEntry
+			if json.Unmarshal(raw, &ce) != nil {
+				continue
+			}
+			b.Entries = append(b.Entries, Entry{
+				Type:      string(session.EntryTypeCompaction),
+				Timestamp: ce.Timestamp,
+				Summary:   ce.Summary,
+			})
+		}
+	}
+	for _, a := range atts {
+		b.Attachments = append(b.Attachments, a)
+	}
+	sort.Slice(b.Attachments, func(i, j int) bool { return b.Attachments[i].ID < b.Attachments[j].ID })
+
+	if src.Audit != nil {
+		// Imported sessions keep their original message and tool timestamps,
+		// which predate CreatedAt.
+		from := meta.CreatedAt
+		for _, e := range b.Entries {
+			if e.Timestamp > 0 && e.Timestamp < from {
+				from = e.Timestamp
+			}
+		}
+		calls, err := src.Audit.SessionEntries(src.SessionID, time.UnixMilli(from), time.Now())
+		if err != nil {
+			return nil, fmt.Errorf("read tool audit: %w", err)
+		}
+		b.ToolCalls = calls
+	}
+
+	if opts.RedactToolInputs {
+		redactToolInputs(b)
+	}
+	if len(opts.Env) > 0 {
+		redactEnv(b, opts.Env)
+	}
+	return b, nil
+}
+
+// mediaSource is the source of an image / document content block.
+type mediaSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	ID        string `json:"id,omitempty"`
+}
+
+// mapMediaSources calls fn for the source of every image / document block
+// in content and writes back the sources it changed. Other block fields are
+// kept as they are.
+func mapMediaSources(content json.RawMessage, fn func(*mediaSource) (bool, error)) (json.RawMessage, error) {
+	var blocks []map[string]json.RawMessage
+	if json.Unmarshal(content, &blocks) != nil {
+		return content, nil
+	}
+	changed := false
+	for _, blk := range blocks {
+		var typ string
+		_ = json.Unmarshal(blk["type"], &typ)
+		var src mediaSource
+		if (typ != "image" && typ != "document") || json.Unmarshal(blk["source"], &src) != nil {
+			continue
+		}
+		ok, err := fn(&src)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			blk["source"], _ = json.Marshal(src)
+			changed = true
+		}
+	}
+	if !changed {
+		return content, nil
+	}
+	return json.Marshal(blocks)
+}
+
+// liftAttachments moves base64 image / document payloads into atts and
+// leaves {"type":"attachment","id":...} sources in their place.
+func liftAttachments(content json.RawMessage, atts map[string]Attachment) json.RawMessage {
+	out, _ := mapMediaSources(content, func(src *mediaSource) (bool, error) {
+		if src.Type != "base64" {
+			return false, nil
+		}
+		data, err := base64.StdEncoding.DecodeString(src.Data)
+		if err != nil {
+			return false, nil
+		}
+		sum := sha256.Sum256(data)
+		id := hex.EncodeToString(sum[:])
+		atts[id] = Attachment{ID: id, MediaType: src.MediaType, Size: len(data), Data: src.Data}
+		src.Type, src.ID, src.Data = "attachment", id, ""
+		return true, nil
+	})
+	return out
+}
+
+// restoreAttachments is the inverse of liftAttachments.
+func restoreAttachments(content json.RawMessage, atts map[string]Attachment) (json.RawMessage, error) {
+	return mapMediaSources(content, func(src *mediaSource) (bool, error) {
+		if src.Type != "attachment" {
+			return false, nil
+		}
+		a, ok := atts[src.ID]
+		if !ok {
+			return false, fmt.Errorf("attachment %s missing from bundle", src.ID)
+		}
+		src.Type, src.ID, src.Data = "base64", "", a.Data
+		if src.MediaType == "" {
+			src.MediaType = a.MediaType
+		}
+		return true, nil
+	})
+}
+
+func redactToolInputs(b *Bundle) {
+	b.Redacted = append(b.Redacted, "toolInputs")
+	for i := range b.Entries {
+		e := &b.Entries[i]
+		for j := range e.ToolCalls {
+			e.ToolCalls[j].Input = redactedInput
+		}
+		var blocks []map[string]json.RawMessage
+		if json.Unmarshal(e.Content, &blocks) != nil {
+			continue
+		}
+		changed := false
+		for _, blk := range blocks {
+			if string(blk["type"]) == `"tool_use"` {
+				blk["input"] = json.RawMessage(redactedInput)
+				changed = true
+			}
+		}
+		if changed {
+			e.Content, _ = json.Marshal(blocks)
+		}
+	}
+	for i := range b.ToolCalls {
+		b.ToolCalls[i].Input = json.RawMessage(redactedInput)
+		b.ToolCalls[i].InputRef = ""
+	}
+}
+
+// redactEnv replaces every env value (4+ characters; shorter ones match too
+// much ordinary text) with [env:KEY] in message text, tool data and
+// summaries. Longer values are replaced first so a value containing
+// another is masked whole.
+func redactEnv(b *Bundle, env map[string]string) {
+	type pair struct{ key, val string }
+	var pairs []pair
+	for k, v := range env {
+		if len(v) >= 4 {
+			pairs = append(pairs, pair{k, v})
+		}
+	}
+	if len(pairs) == 0 {
+		return
+	}
+	b.Redacted = append(b.Redacted, "env")
+	sort.Slice(pairs, func(i, j int) bool { return len(pairs[i].val) > len(pairs[j].val) })
+	var plain, escaped []string
+	for _, p := range pairs {
+		mark := "[env:" + p.key + "]"
+		plain = append(plain, p.val, mark)
+		// Values inside raw JSON appear escaped.
+		q, _ := json.Marshal(p.val)
+		escaped = append(escaped, string(q[1:len(q)-1]), mark)
+	}
+	text := strings.NewReplacer(plain...)
+	raw := strings.NewReplacer(escaped...)
+	rawJSON := func(m json.RawMessage) json.RawMessage {
+		if len(m) == 0 {
+			return m
+		}
+		return json.RawMessage(raw.Replace(string(m)))
+	}
+	for i := range b.Entries {
+		e := &b.Entries[i]
+		e.Content = rawJSON(e.Content)
+		e.Summary = text.Replace(e.Summary)
+		for j := range e.ToolCalls {
+			e.ToolCalls[j].Input = text.Replace(e.ToolCalls[j].Input)
+			e.ToolCalls[j].Result = text.Replace(e.ToolCalls[j].Result)
+		}
+	}
+	for i := range b.ToolCalls {
+		c := &b.ToolCalls[i]
+		c.Input = rawJSON(c.Input)
+		c.Result = text.Replace(c.Result)
+		c.Error = text.Replace(c.Error)
+	}
+	b.Session.Title = text.Replace(b.Session.Title)
+}
+
+// Decode parses and validates a JSON bundle.
+func Decode(data []byte) (*Bundle, error) {
+	var b Bundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("parse bundle: %w", err)
+	}
+	if b.Format != BundleFormat {
+		return nil, fmt.Errorf("not a session bundle (format %q)", b.Format)
+	}
+	if b.Version < 1 || b.Version > BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version %d", b.Version)
+	}
+	if len(b.Entries) == 0 {
+		return nil, errors.New("bundle has no entries")
+	}
+	return &b, nil
+}
@@ -0,0 +1,63 @@
+package transcript
+
+import (
+	"fmt"
+
+	"github.com/Zyling-ai/zyhive/pkg/session"
+	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
+)
+
+// Import stores b as a new session of agentID and returns the session ID.
+// Message IDs and timestamps are kept; attachments go back inline. Tool
+// audit rows are re-keyed to the new session (audit may be nil to skip
+// them). Redacted values stay redacted.
+func Import(store *session.Store, audit *toolaudit.Log, agentID string, b *Bundle) (string, error) {
+	atts := make(map[string]Attachment, len(b.Attachments))
+	for _, a := range b.Attachments {
+		atts[a.ID] = a
+	}
+	entries := make([]any, 0, len(b.Entries))
+	for i, e := range b.Entries {
+		switch e.Type {
+		case string(session.EntryTypeMessage):
+			if e.Role != "user" && e.Role != "assistant" {
+				return "", fmt.Errorf("entry %d: unsupported role %q", i, e.Role)
+			}
+			if len(e.Content) == 0 {
+				return "", fmt.Errorf("entry %d: empty content", i)
+			}
+			content, err := restoreAttachments(e.Content, atts)
+			if err != nil {
+				return "", fmt.Errorf("entry %d: %w", i, err)
+			}
+			entries = append(entries, session.MessageEntry{
+				BaseEntry: session.BaseEntry{Type: session.EntryTypeMessage, ID: e.ID},
+				Message:   session.Message{Role: e.Role, Content: content, ToolCalls: e.ToolCalls},
+				Timestamp: e.Timestamp,
+			})
+		case string(session.EntryTypeCompaction):
+			entries = append(entries, session.CompactionEntry{
+				BaseEntry: session.BaseEntry{Type: session.EntryTypeCompaction},
+				Summary:   e.Summary,
+				Timestamp: e.Timestamp,
+			})
+		default:
+			return "", fmt.Errorf("entry %d: unsupported type %q", i, e.Type)
+		}
+	}
+
+	sessionID, err := store.ImportSession(agentID, b.Session.Title, entries)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range b.ToolCalls {
+		if c.ToolCallID == "" {
+			continue
+		}
+		c.AgentID, c.SessionID = agentID, sessionID
+		if err := audit.Append(c); err != nil {
+			return sessionID, fmt.Errorf("import tool audit: %w", err)
+		}
+	}
+	return sessionID, nil
+}
@@ -0,0 +1,334 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
+)
+
+// Per-field caps for tool input / output in the rendered views; the JSON
+// bundle always carries the full text.
+const (
+	markdownToolRunes = 4000
+	htmlToolRunes     = 20000
+)
+
+// view is the render-ready form of a bundle shared by Markdown and HTML.
+type view struct {
+	Title    string
+	Agent    string
+	Session  string
+	Source   string
+	Period   string
+	Exported string
+	Redacted string
+	Items    []viewItem
+}
+
+type viewItem struct {
+	Compaction  bool
+	Role        string // 用户 / 助手
+	User        bool
+	Time        string
+	Text        string
+	Attachments []viewAttachment
+	Tools       []viewTool
+}
+
+type viewAttachment struct {
+	MediaType string
+	Size      string
+	Image     bool
+	DataURL   template.URL
+}
+
+type viewTool struct {
+	Name     string
+	Duration string
+	Input    string
+	Result   string
+	Error    string
+}
+
+var safeMediaType = regexp.MustCompile(`^[a-z]+/[a-z0-9.+-]+$`)
+
+func fmtTime(ms int64) string {
+	if ms <= 0 {
+		return ""
+	}
+	return time.UnixMilli(ms).Format("2006-01-02 15:04:05")
+}
+
+func fmtSize(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+func clip(s string, maxRunes int) string {
+	if utf8.RuneCountInString(s) <= maxRunes {
+		return s
+	}
+	return string([]rune(s)[:maxRunes]) + "\n…（已截断，完整内容见 JSON 导出）"
+}
+
+// prettyJSON indents a JSON tool input; anything else is returned as is.
+func prettyJSON(s string) string {
+	var buf bytes.Buffer
+	if json.Indent(&buf, []byte(s), "", "  ") == nil {
+		return buf.String()
+	}
+	return s
+}
+
+// contentParts splits message content into display text, attachment refs
+// and whether it only carries tool_use / tool_result blocks (intermediate
+// agent-loop messages, whose calls are shown on the final reply).
+func contentParts(content json.RawMessage) (text string, refs []mediaSource, toolOnly bool) {
+	var s string
+	if json.Unmarshal(content, &s) == nil {
+		return s, nil, false
+	}
+	var blocks []struct {
+		Type   string      `json:"type"`
+		Text   string      `json:"text"`
+		Source mediaSource `json:"source"`
+	}
+	if json.Unmarshal(content, &blocks) != nil {
+		return "", nil, false
+	}
+	var texts []string
+	tools := 0
+	for _, b := range blocks {
+		switch b.Type {
+		case "text":
+			if b.Text != "" {
+				texts = append(texts, b.Text)
+			}
+		case "image", "document":
+			refs = append(refs, b.Source)
+		case "tool_use", "tool_result":
+			tools++
+		}
+	}
+	return strings.Join(texts, "\n\n"), refs, tools > 0 && tools == len(blocks)
+}
+
+func buildView(b *Bundle, toolRunes int) view {
+	v := view{
+		Title:    b.Session.Title,
+		Agent:    b.Agent.Name,
+		Session:  b.Session.ID,
+		Source:   b.Session.Source,
+		Period:   fmtTime(b.Session.CreatedAt) + " — " + fmtTime(b.Session.LastAt),
+		Exported: fmtTime(b.ExportedAt),
+	}
+	if v.Title == "" {
+		v.Title = b.Session.ID
+	}
+	if v.Agent == "" {
+		v.Agent = b.Agent.ID
+	} else if b.Agent.ID != "" {
+		v.Agent += "（" + b.Agent.ID + "）"
+	}
+	var redacted []string
+	for _, r := range b.Redacted {
+		switch r {
+		case "toolInputs":
+			redacted = append(redacted, "工具输入")
+		case "env":
+			redacted = append(redacted, "环境变量值")
+		}
+	}
+	v.Redacted = strings.Join(redacted, "、")
+
+	atts := make(map[string]Attachment, len(b.Attachments))
+	for _, a := range b.Attachments {
+		atts[a.ID] = a
+	}
+	audit := make(map[string]toolaudit.Entry, len(b.ToolCalls))
+	for _, c := range b.ToolCalls {
+		audit[c.ToolCallID] = c
+	}
+
+	for _, e := range b.Entries {
+		if e.Type == "compaction" {
+			v.Items = append(v.Items, viewItem{Compaction: true, Time: fmtTime(e.Timestamp), Text: e.Summary})
+			continue
+		}
+		if e.Role != "user" && e.Role != "assistant" {
+			continue
+		}
+		text, refs, toolOnly := contentParts(e.Content)
+		if toolOnly && len(e.ToolCalls) == 0 {
+			continue
+		}
+		it := viewItem{Role: "助手", User: e.Role == "user", Time: fmtTime(e.Timestamp), Text: text}
+		if it.User {
+			it.Role = "用户"
+		}
+		for _, ref := range refs {
+			a, ok := atts[ref.ID]
+			if !ok {
+				continue
+			}
+			va := viewAttachment{MediaType: a.MediaType, Size: fmtSize(a.Size)}
+			if safeMediaType.MatchString(a.MediaType) {
+				va.Image = strings.HasPrefix(a.MediaType, "image/")
+				va.DataURL = template.URL("data:" + a.MediaType + ";base64," + a.Data)
+			}
+			it.Attachments = append(it.Attachments, va)
+		}
+		for _, rec := range e.ToolCalls {
+			t := viewTool{Name: rec.Name, Input: rec.Input, Result: rec.Result}
+			if full, ok := audit[rec.ID]; ok {
+				t.Input, t.Result, t.Error = string(full.Input), full.Result, full.Error
+				if full.DurationMs > 0 {
+					t.Duration = fmt.Sprintf("%d ms", full.DurationMs)
+				}
+			}
+			t.Input = clip(prettyJSON(t.Input), toolRunes)
+			t.Result = clip(t.Result, toolRunes)
+			it.Tools = append(it.Tools, t)
+		}
+		if it.Text == "" && len(it.Attachments) == 0 && len(it.Tools) == 0 {
+			continue
+		}
+		v.Items = append(v.Items, it)
+	}
+	return v
+}
+
+// fence returns a code fence longer than any backtick run in s.
+func fence(s string) string {
+	n, run := 3, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			if run >= n {
+				n = run + 1
+			}
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", n)
+}
+
+// Markdown renders the bundle as a Markdown transcript. Attachments are
+// listed by type and size; their bytes stay in the JSON bundle.
+func Markdown(b *Bundle) []byte {
+	v := buildView(b, markdownToolRunes)
+	var w bytes.Buffer
+	fmt.Fprintf(&w, "# %s\n\n", v.Title)
+	fmt.Fprintf(&w, "- 成员：%s\n", v.Agent)
+	fmt.Fprintf(&w, "- 会话：`%s`（来源 %s）\n", v.Session, v.Source)
+	fmt.Fprintf(&w, "- 时间：%s\n", v.Period)
+	fmt.Fprintf(&w, "- 导出于：%s\n", v.Exported)
+	if v.Redacted != "" {
+		fmt.Fprintf(&w, "- 已隐藏：%s\n", v.Redacted)
+	}
+	for _, it := range v.Items {
+		w.WriteString("\n---\n\n")
+		if it.Compaction {
+			w.WriteString("> **较早的对话已压缩，摘要如下**\n>\n")
+			for _, line := range strings.Split(strings.TrimSpace(it.Text), "\n") {
+				w.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+			}
+			continue
+		}
+		fmt.Fprintf(&w, "### %s · %s\n\n", it.Role, it.Time)
+		if it.Text != "" {
+			w.WriteString(strings.TrimSpace(it.Text) + "\n\n")
+		}
+		for _, a := range it.Attachments {
+			fmt.Fprintf(&w, "*（附件：%s，%s）*\n\n", a.MediaType, a.Size)
+		}
+		for _, t := range it.Tools {
+			fmt.Fprintf(&w, "**工具 `%s`**", t.Name)
+			if t.Duration != "" {
+				fmt.Fprintf(&w, "（%s）", t.Duration)
+			}
+			w.WriteString("\n\n")
+			if t.Input != "" {
+				f := fence(t.Input)
+				fmt.Fprintf(&w, "输入：\n\n%sjson\n%s\n%s\n\n", f, t.Input, f)
+			}
+			if t.Error != "" {
+				fmt.Fprintf(&w, "错误：%s\n\n", t.Error)
+			}
+			if t.Result != "" {
+				f := fence(t.Result)
+				fmt.Fprintf(&w, "结果：\n\n%s\n%s\n%s\n\n", f, t.Result, f)
+			}
+		}
+	}
+	return w.Bytes()
+}
+
+// HTML renders the bundle as one self-contained, script-free page: styles
+// are inline and images are embedded as data URLs.
+func HTML(b *Bundle) ([]byte, error) {
+	var w bytes.Buffer
+	if err := htmlTemplate.Execute(&w, buildView(b, htmlToolRunes)); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+body{margin:0;background:#f6f7f9;color:#1f2328;font:15px/1.6 -apple-system,BlinkMacSystemFont,"Segoe UI","PingFang SC","Microsoft YaHei",sans-serif}
+main{max-width:860px;margin:0 auto;padding:24px 16px 64px}
+h1{font-size:22px;margin:0 0 8px}
+.meta{color:#656d76;font-size:13px;margin-bottom:24px}
+.meta span{margin-right:16px}
+.msg{background:#fff;border:1px solid #d0d7de;border-radius:8px;padding:12px 16px;margin:12px 0}
+.msg.user{background:#eef6ff;border-color:#b6d4fe}
+.head{font-size:12px;color:#656d76;margin-bottom:6px}
+.head b{color:#1f2328}
+.text{white-space:pre-wrap;word-wrap:break-word}
+.compact{border-left:4px solid #d0d7de;background:#fff;padding:8px 16px;margin:16px 0;color:#57606a}
+.compact .text{font-size:14px}
+details{margin-top:8px;border:1px solid #d0d7de;border-radius:6px;background:#f6f8fa}
+summary{cursor:pointer;padding:6px 10px;font-size:13px}
+summary code{font-weight:600}
+pre{margin:0;padding:8px 10px;overflow:auto;font:12px/1.5 ui-monospace,SFMono-Regular,Menlo,Consolas,monospace;white-space:pre-wrap;word-break:break-all;border-top:1px solid #d0d7de}
+.err{color:#cf222e}
+img{max-width:100%;border-radius:6px;margin-top:8px;display:block}
+.att{font-size:13px;color:#656d76;margin-top:8px}
+footer{color:#8c959f;font-size:12px;text-align:center;margin-top:32px}
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+<div class="meta"><span>成员：{{.Agent}}</span><span>会话：{{.Session}}（{{.Source}}）</span><span>{{.Period}}</span>{{if .Redacted}}<span>已隐藏：{{.Redacted}}</span>{{end}}</div>
+{{range .Items}}{{if .Compaction}}<div class="compact"><div class="head"><b>较早的对话已压缩</b> · {{.Time}}</div><div class="text">{{.Text}}</div></div>
+{{else}}<div class="msg{{if .User}} user{{end}}"><div class="head"><b>{{.Role}}</b> · {{.Time}}</div>{{if .Text}}<div class="text">{{.Text}}</div>{{end}}
+{{range .Attachments}}{{if .Image}}<img src="{{.DataURL}}" alt="{{.MediaType}}">{{else if .DataURL}}<div class="att"><a href="{{.DataURL}}" download>附件：{{.MediaType}}，{{.Size}}</a></div>{{else}}<div class="att">附件：{{.MediaType}}，{{.Size}}</div>{{end}}
+{{end}}{{range .Tools}}<details><summary>工具 <code>{{.Name}}</code>{{if .Duration}} · {{.Duration}}{{end}}{{if .Error}} · <span class="err">失败</span>{{end}}</summary>{{if .Input}}<pre>{{.Input}}</pre>{{end}}{{if .Error}}<pre class="err">{{.Error}}</pre>{{end}}{{if .Result}}<pre>{{.Result}}</pre>{{end}}</details>
+{{end}}</div>
+{{end}}{{end}}<footer>只读记录 · 导出于 {{.Exported}}</footer>
+</main>
+</body>
+</html>
+`))
@@ -0,0 +1,154 @@
+package transcript
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/session"
+	"github.com/Zyling-ai/zyhive/pkg/toolaudit"
+)
+
+const pngData = "iVBORw0KGgo=" // PNG signature, base64
+
+func seed(t *testing.T) (*session.Store, *toolaudit.Log) {
+	t.Helper()
+	dir := t.TempDir()
+	store := session.NewStore(filepath.Join(dir, "sessions"))
+	audit := toolaudit.New(dir)
+	if _, _, err := store.GetOrCreate("ses-1", "a1"); err != nil {
+		t.Fatal(err)
+	}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(store.AppendMessage("ses-1", "user", json.RawMessage(
+		`[{"type":"image","source":{"type":"base64","media_type":"image/png","data":"`+pngData+`"}},{"type":"text","text":"用 sk-live-1234 查一下这张图"}]`)))
+	must(store.AppendMessage("ses-1", "assistant", json.RawMessage(
+		`[{"type":"tool_use","id":"toolu_1","name":"exec","input":{"cmd":"curl -H sk-live-1234 api"}}]`)))
+	must(store.AppendMessage("ses-1", "user", json.RawMessage(
+		`[{"type":"tool_result","tool_use_id":"toolu_1","content":"ok"}]`)))
+	must(store.AppendMessageWithTools("ses-1", "assistant", json.RawMessage(`"查好了，结果是 ok"`),
+		[]session.ToolCallRecord{{ID: "toolu_1", Name: "exec", Input: `{"cmd":"curl -H sk-live-1234 api"}`, Result: "ok"}}))
+	must(audit.Append(toolaudit.Entry{AgentID: "a1", SessionID: "ses-1", ToolCallID: "toolu_1", Name: "exec",
+		Input: json.RawMessage(`{"cmd":"curl -H sk-live-1234 api"}`), Result: "ok", DurationMs: 42}))
+	return store, audit
+}
+
+// sameJSON compares values by their decoded JSON, ignoring key order.
+func sameJSON(t *testing.T, a, b any) bool {
+	t.Helper()
+	var x, y any
+	for _, p := range []struct {
+		in  any
+		out *any
+	}{{a, &x}, {b, &y}} {
+		data, err := json.Marshal(p.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		json.Unmarshal(data, p.out)
+	}
+	return reflect.DeepEqual(x, y)
+}
+
+func TestBuildRenderImport(t *testing.T) {
+	store, audit := seed(t)
+	b, err := Build(Source{Store: store, SessionID: "ses-1", AgentID: "a1", AgentName: "小助手", Audit: audit}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Entries) != 4 || len(b.ToolCalls) != 1 || len(b.Attachments) != 1 {
+		t.Fatalf("entries=%d tools=%d attachments=%d", len(b.Entries), len(b.ToolCalls), len(b.Attachments))
+	}
+	if bytes.Contains(b.Entries[0].Content, []byte(pngData)) || !bytes.Contains(b.Entries[0].Content, []byte(b.Attachments[0].ID)) {
+		t.Errorf("attachment not lifted: %s", b.Entries[0].Content)
+	}
+
+	md := string(Markdown(b))
+	for _, want := range []string{"# 用 sk-live-1234", "### 用户", "**工具 `exec`**（42 ms）", "*（附件：image/png，8 B）*", "查好了"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+	page, err := HTML(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(page, []byte(`src="data:image/png;base64,`+pngData+`"`)) || bytes.Contains(page, []byte("<script")) {
+		t.Errorf("html:\n%s", page)
+	}
+
+	// Round trip through JSON into another agent's store.
+	data, _ := json.Marshal(b)
+	decoded, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	dst := session.NewStore(filepath.Join(dir, "sessions"))
+	dstAudit := toolaudit.New(dir)
+	sid, err := Import(dst, dstAudit, "a2", decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _, _ := store.ReadHistory("ses-1")
+	got, _, _ := dst.ReadHistory(sid)
+	if !sameJSON(t, got, want) {
+		t.Errorf("imported history differs:\n%+v\n%+v", got, want)
+	}
+	if meta, _ := dst.GetMeta(sid); meta.AgentID != "a2" || meta.MessageCount != 4 || meta.Title != b.Session.Title {
+		t.Errorf("imported meta = %+v", meta)
+	}
+	if e, _ := dstAudit.GetByID("toolu_1"); e == nil || e.SessionID != sid || e.AgentID != "a2" {
+		t.Errorf("imported audit = %+v", e)
+	}
+	// Re-exporting the import finds the audit rows despite their old dates.
+	again, err := Build(Source{Store: dst, SessionID: sid, AgentID: "a2", Audit: dstAudit}, Options{})
+	if err != nil || len(again.ToolCalls) != 1 {
+		t.Errorf("re-export tools = %v, %v", again, err)
+	}
+}
+
+func TestBuildRedacts(t *testing.T) {
+	store, audit := seed(t)
+	b, err := Build(Source{Store: store, SessionID: "ses-1", AgentID: "a1", Audit: audit},
+		Options{RedactToolInputs: true, Env: map[string]string{"API_KEY": "sk-live-1234", "SHORT": "ok"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(b)
+	if bytes.Contains(data, []byte("sk-live-1234")) || bytes.Contains(data, []byte("curl")) {
+		t.Errorf("secret left in bundle: %s", data)
+	}
+	if !bytes.Contains(data, []byte("[env:API_KEY]")) || !bytes.Contains(data, []byte(`"ok"`)) {
+		t.Errorf("bundle = %s", data)
+	}
+	if got := strings.Join(b.Redacted, ","); got != "toolInputs,env" {
+		t.Errorf("redacted = %q", got)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	for _, in := range []string{
+		`{"format":"other","version":1,"entries":[{}]}`,
+		`{"format":"zyhive-session","version":99,"entries":[{}]}`,
+		`{"format":"zyhive-session","version":1,"entries":[]}`,
+	} {
+		if _, err := Decode([]byte(in)); err == nil {
+			t.Errorf("accepted %s", in)
+		}
+	}
+	b := &Bundle{Entries: []Entry{{Type: "message", Role: "user", Timestamp: time.Now().UnixMilli(),
+		Content: json.RawMessage(`[{"type":"image","source":{"type":"attachment","id":"missing"}}]`)}}}
+	if _, err := Import(session.NewStore(t.TempDir()), nil, "a", b); err == nil {
+		t.Error("missing attachment accepted")
+	}
+}
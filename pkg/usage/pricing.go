@@ -1,55 +1,391 @@
-// pkg/usage/pricing.go — per-model cost estimation (USD per 1M tokens).
+// pkg/usage/pricing.go — per-model cost estimation from a versioned price table.
+//
+// Prices are per 1M tokens. Each row matches models by case-insensitive
+// substring (longest match wins, as the old hard-coded switch did by
+// ordering) and may carry an effective date, so a price change only applies
+// to usage recorded on/after it — re-pricing history never back-dates a cut.
+//
+// The table lives in .usage/pricing.json next to the usage JSONL (editable
+// via PUT /api/usage/pricing); without that file the built-in table below is
+// used. Every saved edit bumps Version, and each Record stores the version it
+// was priced with so reports can tell stale rows apart (see Store.Reprice).
 package usage
 
-import "strings"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+)
+
+// Price is one pricing-table row.
+type Price struct {
+	Match         string  `json:"match"`                   // case-insensitive substring of the model ID
+	Provider      string  `json:"provider,omitempty"`      // restrict to a provider type, e.g. "openrouter"
+	Input         float64 `json:"input"`                   // uncached prompt tokens
+	Output        float64 `json:"output"`                  // completion (incl. reasoning) tokens
+	CacheRead     float64 `json:"cacheRead,omitempty"`     // prompt-cache hits; 0 = Input rate
+	CacheWrite    float64 `json:"cacheWrite,omitempty"`    // prompt-cache writes; 0 = Input rate
+	Currency      string  `json:"currency,omitempty"`      // "" = table currency
+	EffectiveFrom string  `json:"effectiveFrom,omitempty"` // YYYY-MM-DD (UTC); "" = always
+}
+
+// PricingTable is the versioned price list.
+type PricingTable struct {
+	Version   int    `json:"version"`
+	UpdatedAt int64  `json:"updatedAt,omitempty"` // Unix seconds
+	Currency  string `json:"currency"`            // currency of Record.Cost; budgets assume "USD"
+	// Rates converts row currencies into Currency: units of X per 1 Currency,
+	// e.g. {"CNY": 7.2}. Required for every non-table currency in use.
+	Rates    map[string]float64 `json:"rates,omitempty"`
+	Fallback Price              `json:"fallback"` // unknown models
+	Prices   []Price            `json:"prices"`
+}
+
+// Tokens is the token breakdown of one priced call. Input is the whole
+// prompt; CacheRead / CacheWrite are the cached subsets of it (llm.Usage
+// semantics).
+type Tokens struct {
+	Input, Output, CacheRead, CacheWrite int
+}
+
+// builtinPricingVersion is bumped whenever the built-in table changes.
+const builtinPricingVersion = 1
+
+// DefaultPricingTable returns the built-in table (USD).
+func DefaultPricingTable() PricingTable {
+	return PricingTable{
+		Version:  builtinPricingVersion,
+		Currency: "USD",
+		Fallback: Price{Input: 1.0, Output: 2.0},
+		Prices: []Price{
+			// Anthropic: cache read 0.1×, 5-minute cache write 1.25× input
+			{Match: "claude-opus-4", Input: 15.0, Output: 75.0, CacheRead: 1.5, CacheWrite: 18.75},
+			{Match: "claude-sonnet-4", Input: 3.0, Output: 15.0, CacheRead: 0.3, CacheWrite: 3.75},
+			{Match: "claude-haiku-4", Input: 0.8, Output: 4.0, CacheRead: 0.08, CacheWrite: 1.0},
+			{Match: "claude-3-7-sonnet", Input: 3.0, Output: 15.0, CacheRead: 0.3, CacheWrite: 3.75},
+			{Match: "claude-3-5-sonnet", Input: 3.0, Output: 15.0, CacheRead: 0.3, CacheWrite: 3.75},
+			{Match: "claude-3-5-haiku", Input: 0.8, Output: 4.0, CacheRead: 0.08, CacheWrite: 1.0},
+			{Match: "claude-3-opus", Input: 15.0, Output: 75.0, CacheRead: 1.5, CacheWrite: 18.75},
+			{Match: "claude-3-sonnet", Input: 3.0, Output: 15.0},
+			{Match: "claude-3-haiku", Input: 0.25, Output: 1.25, CacheRead: 0.03, CacheWrite: 0.3},
+			// OpenAI: cached input 0.5×, no write surcharge
+			{Match: "o3-mini", Input: 1.1, Output: 4.4, CacheRead: 0.55},
+			{Match: "o3", Input: 10.0, Output: 40.0, CacheRead: 2.5},
+			{Match: "o1-mini", Input: 3.0, Output: 12.0, CacheRead: 1.5},
+			{Match: "o1", Input: 15.0, Output: 60.0, CacheRead: 7.5},
+			{Match: "gpt-4o-mini", Input: 0.15, Output: 0.6, CacheRead: 0.075},
+			{Match: "gpt-4o", Input: 2.5, Output: 10.0, CacheRead: 1.25},
+			{Match: "gpt-4-turbo", Input: 10.0, Output: 30.0},
+			{Match: "gpt-4", Input: 30.0, Output: 60.0},
+			{Match: "gpt-3.5-turbo", Input: 0.5, Output: 1.5},
+			// DeepSeek
+			{Match: "deepseek-reasoner", Input: 0.14, Output: 2.19},
+			{Match: "deepseek-chat", Input: 0.07, Output: 1.1},
+			{Match: "deepseek-coder", Input: 0.14, Output: 0.28},
+			// MiniMax
+			{Match: "abab6.5s", Input: 0.1, Output: 0.1},
+			{Match: "abab5.5s", Input: 0.05, Output: 0.05},
+			// Moonshot / Kimi
+			{Match: "moonshot-v1-128k", Input: 0.06, Output: 0.06},
+			{Match: "moonshot-v1-32k", Input: 0.024, Output: 0.024},
+			{Match: "moonshot-v1-8k", Input: 0.012, Output: 0.012},
+			// Zhipu
+			{Match: "glm-4-plus", Input: 0.05, Output: 0.05},
+			{Match: "glm-4", Input: 0.1, Output: 0.1},
+			{Match: "glm-3-turbo", Input: 0.005, Output: 0.005},
+			// Google Gemini (≤200k prompt tier; thinking tokens are billed as output; cached 0.25×)
+			{Match: "gemini-2.5-pro", Input: 1.25, Output: 10.0, CacheRead: 0.31},
+			{Match: "gemini-2.5-flash-lite", Input: 0.1, Output: 0.4, CacheRead: 0.025},
+			{Match: "gemini-2.5-flash", Input: 0.3, Output: 2.5, CacheRead: 0.075},
+			{Match: "gemini-2.0-flash-lite", Input: 0.075, Output: 0.3},
+			{Match: "gemini-2.0-flash", Input: 0.1, Output: 0.4, CacheRead: 0.025},
+			{Match: "gemini-1.5-pro", Input: 1.25, Output: 5.0},
+			{Match: "gemini-1.5-flash", Input: 0.075, Output: 0.3},
+		},
+	}
+}
+
+// Validate checks a table before it is saved.
+func (t *PricingTable) Validate() error {
+	if t.Currency == "" {
+		t.Currency = "USD"
+	}
+	if err := t.checkPrice("fallback", t.Fallback); err != nil {
+		return err
+	}
+	for i, p := range t.Prices {
+		if strings.TrimSpace(p.Match) == "" {
+			return fmt.Errorf("prices[%d]: match is required", i)
+		}
+		if err := t.checkPrice(fmt.Sprintf("prices[%d] (%s)", i, p.Match), p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateOverrides checks per-provider overrides against the table (their
+// currencies must be convertible). Unlike table rows, Match may be empty.
+func (t *PricingTable) ValidateOverrides(ps []Price) error {
+	for i, p := range ps {
+		if err := t.checkPrice(fmt.Sprintf("pricing[%d]", i), p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *PricingTable) checkPrice(where string, p Price) error {
+	if p.Input < 0 || p.Output < 0 || p.CacheRead < 0 || p.CacheWrite < 0 {
+		return fmt.Errorf("%s: prices must not be negative", where)
+	}
+	if p.EffectiveFrom != "" {
+		if _, err := time.Parse("2006-01-02", p.EffectiveFrom); err != nil {
+			return fmt.Errorf("%s: effectiveFrom must be YYYY-MM-DD", where)
+		}
+	}
+	if c := p.Currency; c != "" && !strings.EqualFold(c, t.Currency) && t.Rates[strings.ToUpper(c)] <= 0 {
+		return fmt.Errorf("%s: no rate for currency %s", where, c)
+	}
+	return nil
+}
+
+// lookup picks the row for (provider, model) at time at: overrides first
+// (a per-ProviderEntry override with an empty Match covers every model),
+// then the table, then Fallback. Longest Match wins; ties go to the latest
+// EffectiveFrom that has already started.
+func (t *PricingTable) lookup(provider, model string, at time.Time, overrides []Price) Price {
+	id := strings.ToLower(model)
+	day := at.UTC().Format("2006-01-02")
+	pick := func(rows []Price, allowEmpty bool) (Price, bool) {
+		var best Price
+		found := false
+		for _, p := range rows {
+			m := strings.ToLower(p.Match)
+			if (m == "" && !allowEmpty) || !strings.Contains(id, m) {
+				continue
+			}
+			if p.Provider != "" && !strings.EqualFold(p.Provider, provider) {
+				continue
+			}
+			if p.EffectiveFrom != "" && p.EffectiveFrom > day {
+				continue
+			}
+			if !found || len(m) > len(best.Match) || (len(m) == len(best.Match) && p.EffectiveFrom > best.EffectiveFrom) {
+				best, found = p, true
+			}
+		}
+		return best, found
+	}
+	if p, ok := pick(overrides, true); ok {
+		return p
+	}
+	if p, ok := pick(t.Prices, false); ok {
+		return p
+	}
+	return t.Fallback
+}
+
+// cost prices tk with row p, converted into the table currency.
+func (t *PricingTable) cost(p Price, tk Tokens) float64 {
+	cr, cw := p.CacheRead, p.CacheWrite
+	if cr == 0 {
+		cr = p.Input
+	}
+	if cw == 0 {
+		cw = p.Input
+	}
+	uncached := tk.Input - tk.CacheRead - tk.CacheWrite
+	if uncached < 0 {
+		uncached = 0
+	}
+	c := (float64(uncached)*p.Input + float64(tk.CacheRead)*cr +
+		float64(tk.CacheWrite)*cw + float64(tk.Output)*p.Output) / 1_000_000
+	if p.Currency != "" && !strings.EqualFold(p.Currency, t.Currency) {
+		if rate := t.Rates[strings.ToUpper(p.Currency)]; rate > 0 {
+			c /= rate
+		}
+	}
+	return c
+}
+
+// Pricer holds the active table and persists edits.
+type Pricer struct {
+	path string // "" = in-memory only
+
+	mu    sync.RWMutex
+	table PricingTable
+}
+
+// NewPricer loads the table at path, falling back to DefaultPricingTable
+// when the file does not exist yet. path may be "" for an in-memory pricer.
+func NewPricer(path string) (*Pricer, error) {
+	p := &Pricer{path: path, table: DefaultPricingTable()}
+	if path == "" {
+		return p, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var t PricingTable
+	if err := json.Unmarshal(data, &t); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := t.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	p.table = t
+	return p, nil
+}
+
+// Table returns a copy of the active table.
+func (p *Pricer) Table() PricingTable {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	t := p.table
+	t.Prices = append([]Price(nil), p.table.Prices...)
+	if p.table.Rates != nil {
+		t.Rates = make(map[string]float64, len(p.table.Rates))
+		for k, v := range p.table.Rates {
+			t.Rates[k] = v
+		}
+	}
+	return t
+}
+
+// Update validates t, stamps it with the next version and persists it.
+func (p *Pricer) Update(t PricingTable) (PricingTable, error) {
+	if err := t.Validate(); err != nil {
+		return PricingTable{}, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	t.Version = p.table.Version + 1
+	t.UpdatedAt = time.Now().Unix()
+	if p.path != "" {
+		data, err := json.MarshalIndent(t, "", "  ")
+		if err != nil {
+			return PricingTable{}, err
+		}
+		if err := persist.AtomicWrite(p.path, data, 0o644); err != nil {
+			return PricingTable{}, err
+		}
+	}
+	p.table = t
+	return t, nil
+}
+
+// Quote prices one call made at time at and returns the cost (table
+// currency) and the table version used.
+func (p *Pricer) Quote(provider, model string, at time.Time, overrides []Price, tk Tokens) (float64, int) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	row := p.table.lookup(provider, model, at, overrides)
+	return p.table.cost(row, tk), p.table.Version
+}
+
+// PriceRecord fills r.Cost / r.PricingVersion from its token counts. Records
+// carrying a provider-reported cost (CostSource "provider") are left alone.
+func (p *Pricer) PriceRecord(r *Record, overrides []Price) {
+	if r.CostSource == CostSourceProvider {
+		return
+	}
+	r.Cost, r.PricingVersion = p.Quote(r.Provider, r.Model, time.Unix(r.CreatedAt, 0), overrides, Tokens{
+		Input:      r.InputTokens,
+		Output:     r.OutputTokens,
+		CacheRead:  r.CacheReadTokens,
+		CacheWrite: r.CacheWriteTokens,
+	})
+	r.CostSource = CostSourceTable
+}
+
+var defaultPricer atomic.Pointer[Pricer]
+
+func init() {
+	p, _ := NewPricer("")
+	defaultPricer.Store(p)
+}
+
+// SetDefaultPricer installs the process-wide pricer (main wires the one
+// backed by .usage/pricing.json).
+func SetDefaultPricer(p *Pricer) {
+	if p != nil {
+		defaultPricer.Store(p)
+	}
+}
+
+// DefaultPricer returns the process-wide pricer.
+func DefaultPricer() *Pricer { return defaultPricer.Load() }
+
+// ProviderOverrides converts a ProviderEntry's pricing overrides into table
+// rows. Returns nil when pe is nil or has none.
+func ProviderOverrides(pe *config.ProviderEntry) []Price {
+	if pe == nil || len(pe.Pricing) == 0 {
+		return nil
+	}
+	out := make([]Price, len(pe.Pricing))
+	for i, o := range pe.Pricing {
+		out[i] = Price{
+			Match:         o.Match,
+			Input:         o.Input,
+			Output:        o.Output,
+			CacheRead:     o.CacheRead,
+			CacheWrite:    o.CacheWrite,
+			Currency:      o.Currency,
+			EffectiveFrom: o.EffectiveFrom,
+		}
+	}
+	return out
+}
 
 // EstimateCost returns estimated cost in USD for the given token counts.
 func EstimateCost(model string, inputTokens, outputTokens int) float64 {
-	in, out := lookupPrice(model)
-	return float64(inputTokens)/1_000_000*in + float64(outputTokens)/1_000_000*out
+	c, _ := DefaultPricer().Quote("", model, time.Now(), nil, Tokens{Input: inputTokens, Output: outputTokens})
+	return c
 }
 
-// lookupPrice returns [inputPricePerMillion, outputPricePerMillion] in USD.
-func lookupPrice(model string) (float64, float64) {
-	id := strings.ToLower(model)
-	// Anthropic
-	switch {
-	case contains(id, "claude-opus-4"):        return 15.0, 75.0
-	case contains(id, "claude-sonnet-4"):      return 3.0, 15.0
-	case contains(id, "claude-haiku-4"):       return 0.8, 4.0
-	case contains(id, "claude-3-5-sonnet"):    return 3.0, 15.0
-	case contains(id, "claude-3-5-haiku"):     return 0.8, 4.0
-	case contains(id, "claude-3-opus"):        return 15.0, 75.0
-	case contains(id, "claude-3-sonnet"):      return 3.0, 15.0
-	case contains(id, "claude-3-haiku"):       return 0.25, 1.25
-	// OpenAI
-	case contains(id, "o3-mini"):              return 1.1, 4.4
-	case contains(id, "o3"):                   return 10.0, 40.0
-	case contains(id, "o1-mini"):              return 3.0, 12.0
-	case contains(id, "o1"):                   return 15.0, 60.0
-	case contains(id, "gpt-4o-mini"):          return 0.15, 0.6
-	case contains(id, "gpt-4o"):               return 2.5, 10.0
-	case contains(id, "gpt-4-turbo"):          return 10.0, 30.0
-	case contains(id, "gpt-4"):                return 30.0, 60.0
-	case contains(id, "gpt-3.5-turbo"):        return 0.5, 1.5
-	// DeepSeek
-	case contains(id, "deepseek-reasoner"):    return 0.14, 2.19
-	case contains(id, "deepseek-chat"):        return 0.07, 1.1
-	case contains(id, "deepseek-coder"):       return 0.14, 0.28
-	// MiniMax
-	case contains(id, "abab6.5s"):             return 0.1, 0.1
-	case contains(id, "abab5.5s"):             return 0.05, 0.05
-	// Moonshot / Kimi
-	case contains(id, "moonshot-v1-128k"):     return 0.06, 0.06
-	case contains(id, "moonshot-v1-32k"):      return 0.024, 0.024
-	case contains(id, "moonshot-v1-8k"):       return 0.012, 0.012
-	// Zhipu
-	case contains(id, "glm-4-plus"):           return 0.05, 0.05
-	case contains(id, "glm-4"):                return 0.1, 0.1
-	case contains(id, "glm-3-turbo"):          return 0.005, 0.005
-	}
-	// Generic fallback: $1/$2 per 1M tokens
-	return 1.0, 2.0
-}
-
-func contains(s, sub string) bool { return strings.Contains(s, sub) }
+// ResolveProviderID finds the ProviderEntry ID behind a (provider type,
+// model) pair as reported by the runner; model may be bare or in
+// "provider/model" form. Returns "" when no configured model matches.
+func ResolveProviderID(cfg *config.Config, provider, model string) string {
+	if cfg == nil {
+		return ""
+	}
+	for i := range cfg.Models {
+		m := &cfg.Models[i]
+		if m.Provider == provider && (m.Model == model || m.ProviderModel() == model) && m.ProviderID != "" {
+			return m.ProviderID
+		}
+	}
+	return ""
+}
+
+// ConfigOverrides returns an overridesFor func (see Store.Reprice) that
+// looks up a record's ProviderEntry pricing overrides in cfg.
+func ConfigOverrides(cfg *config.Config) func(Record) []Price {
+	return func(r Record) []Price {
+		if cfg == nil {
+			return nil
+		}
+		id := r.ProviderID
+		if id == "" {
+			id = ResolveProviderID(cfg, r.Provider, r.Model)
+		}
+		if id == "" {
+			return nil
+		}
+		return ProviderOverrides(cfg.FindProvider(id))
+	}
+}
@@ -0,0 +1,179 @@
+package usage
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+)
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestQuoteChargesCacheRates(t *testing.T) {
+	p, _ := NewPricer("")
+	now := time.Now()
+	// claude-sonnet-4: in 3, out 15, cache read 0.3, cache write 3.75 per 1M.
+	got, ver := p.Quote("anthropic", "claude-sonnet-4-20250514", now, nil, Tokens{
+		Input: 1_000_000, CacheRead: 600_000, CacheWrite: 200_000, Output: 100_000,
+	})
+	want := 0.2*3 + 0.6*0.3 + 0.2*3.75 + 0.1*15
+	if !approx(got, want) || ver != builtinPricingVersion {
+		t.Fatalf("Quote = %v (v%d), want %v (v%d)", got, ver, want, builtinPricingVersion)
+	}
+	// Longest match: gpt-4o-mini must not be priced as gpt-4o or gpt-4.
+	got, _ = p.Quote("openai", "openai/gpt-4o-mini", now, nil, Tokens{Input: 1_000_000})
+	if !approx(got, 0.15) {
+		t.Fatalf("gpt-4o-mini = %v, want 0.15", got)
+	}
+	// Unknown model → fallback; old EstimateCost contract unchanged.
+	if got := EstimateCost("llama3", 1_000_000, 1_000_000); !approx(got, 3.0) {
+		t.Fatalf("fallback = %v, want 3", got)
+	}
+}
+
+func TestQuoteOverridesAndEffectiveDates(t *testing.T) {
+	p, _ := NewPricer("")
+	tbl := p.Table()
+	tbl.Rates = map[string]float64{"CNY": 8}
+	tbl.Prices = append(tbl.Prices,
+		Price{Match: "deepseek-chat", Input: 0.27, Output: 1.1, EffectiveFrom: "2026-03-01"},
+	)
+	if _, err := p.Update(tbl); err != nil {
+		t.Fatal(err)
+	}
+	before := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	tk := Tokens{Input: 1_000_000}
+	if got, _ := p.Quote("deepseek", "deepseek-chat", before, nil, tk); !approx(got, 0.07) {
+		t.Fatalf("before = %v, want old price 0.07", got)
+	}
+	if got, ver := p.Quote("deepseek", "deepseek-chat", after, nil, tk); !approx(got, 0.27) || ver != builtinPricingVersion+1 {
+		t.Fatalf("after = %v (v%d), want 0.27 (v%d)", got, ver, builtinPricingVersion+1)
+	}
+	// A provider override with an empty Match covers every model, in CNY.
+	ov := ProviderOverrides(&config.ProviderEntry{Pricing: []config.PriceOverride{{Input: 8, Output: 16, Currency: "CNY"}}})
+	if got, _ := p.Quote("deepseek", "deepseek-chat", after, ov, Tokens{Input: 1_000_000, Output: 1_000_000}); !approx(got, 3) {
+		t.Fatalf("override = %v, want 3 USD", got)
+	}
+	bad := p.Table()
+	bad.Prices = []Price{{Match: "x", Input: 1, Currency: "EUR"}}
+	if _, err := p.Update(bad); err == nil {
+		t.Fatal("expected error for currency without a rate")
+	}
+}
+
+func TestPricerPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".usage", "pricing.json")
+	p, err := NewPricer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl := p.Table()
+	tbl.Fallback = Price{Input: 5, Output: 5}
+	if _, err := p.Update(tbl); err != nil {
+		t.Fatal(err)
+	}
+	p2, err := NewPricer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p2.Table(); got.Version != builtinPricingVersion+1 || got.Fallback.Input != 5 {
+		t.Fatalf("reloaded table = v%d fallback %+v", got.Version, got.Fallback)
+	}
+}
+
+func TestRepriceRewritesHistory(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	ts := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC).Unix()
+	rows := []Record{
+		{ID: "1", AgentID: "a", Provider: "anthropic", Model: "claude-sonnet-4", InputTokens: 1_000_000, CacheReadTokens: 1_000_000, Cost: 3, CreatedAt: ts},
+		{ID: "2", AgentID: "a", Provider: "openrouter", Model: "x", InputTokens: 10, Cost: 0.5, CostSource: CostSourceProvider, CreatedAt: ts},
+	}
+	if err := os.MkdirAll(filepath.Join(dir, ".usage"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, ".usage", "2026-01.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range rows {
+		b, _ := json.Marshal(r)
+		f.Write(append(b, '\n'))
+	}
+	f.Close()
+
+	p, _ := NewPricer("")
+	res, err := s.Reprice(p, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Records != 2 || res.Changed != 1 || res.Skipped != 1 || res.Files != 1 || !approx(res.NewTotal, 0.8) {
+		t.Fatalf("result = %+v", res)
+	}
+	sum := s.Summarize(0, 0, "", "")
+	if !approx(sum.TotalCost, 0.8) {
+		t.Fatalf("summary cost = %v, want 0.8", sum.TotalCost)
+	}
+	got := s.Query(QueryParams{}).Records
+	for _, r := range got {
+		if r.ID == "1" && (r.PricingVersion != builtinPricingVersion || r.CostSource != CostSourceTable) {
+			t.Fatalf("record 1 = %+v", r)
+		}
+	}
+	// Second run is a no-op.
+	if res, _ := s.Reprice(p, nil, 0, 0); res.Files != 0 || res.Changed != 0 {
+		t.Fatalf("second run = %+v", res)
+	}
+}
+
+func TestRepriceKeepsBadLinesAndRefusesMixedCurrency(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore(dir)
+	ts := time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC).Unix()
+	table, _ := json.Marshal(Record{ID: "1", Provider: "anthropic", Model: "claude-sonnet-4", InputTokens: 1_000_000, Cost: 1, CreatedAt: ts})
+	provider, _ := json.Marshal(Record{ID: "2", Provider: "openrouter", Model: "x", Cost: 0.5, CostSource: CostSourceProvider, CreatedAt: ts})
+	const bad = `{"id":"3","inputTokens":`
+	path := filepath.Join(dir, ".usage", "2026-01.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	orig := string(table) + "\n" + bad + "\n" + string(provider) + "\n"
+	if err := os.WriteFile(path, []byte(orig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A non-USD table must not mix its costs with provider USD costs.
+	cny, _ := NewPricer("")
+	tbl := cny.Table()
+	tbl.Currency = "CNY"
+	if _, err := cny.Update(tbl); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Reprice(cny, nil, 0, 0); !errors.Is(err, ErrMixedCurrency) {
+		t.Fatalf("err = %v, want ErrMixedCurrency", err)
+	}
+	if b, _ := os.ReadFile(path); string(b) != orig {
+		t.Fatalf("refused reprice rewrote the file:\n%s", b)
+	}
+
+	p, _ := NewPricer("")
+	res, err := s.Reprice(p, nil, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Files != 1 || res.Records != 2 {
+		t.Fatalf("result = %+v", res)
+	}
+	b, _ := os.ReadFile(path)
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if len(lines) != 3 || lines[1] != bad {
+		t.Fatalf("unparseable line not preserved in place:\n%s", b)
+	}
+}
@@ -0,0 +1,168 @@
+// pkg/usage/reprice.go — recompute stored costs after a pricing-table change.
+package usage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zyling-ai/zyhive/pkg/persist"
+)
+
+// RepriceResult summarises one Reprice run.
+type RepriceResult struct {
+	Files    int     `json:"files"`    // month files rewritten
+	Records  int     `json:"records"`  // records in range
+	Changed  int     `json:"changed"`  // records whose cost changed
+	Skipped  int     `json:"skipped"`  // provider-reported costs left as-is
+	OldTotal float64 `json:"oldTotal"` // table currency, records in range
+	NewTotal float64 `json:"newTotal"`
+	Version  int     `json:"version"` // pricing version applied
+}
+
+// ErrMixedCurrency is returned by Reprice when the table currency is not
+// USD but provider-reported (always USD) costs fall in range: rewriting
+// would leave one file summing two currencies.
+var ErrMixedCurrency = errors.New("pricing table currency is not USD but provider-reported USD costs are in range")
+
+// Reprice recomputes Cost for every record in [from,to] (0 = unbounded)
+// with p, rewriting the affected month files atomically. overridesFor
+// returns the per-provider overrides for a record (may be nil). Lines that
+// do not parse as a Record are copied through verbatim.
+//
+// The budget store is a derivative of these files and is NOT updated here;
+// callers reseed it from Summarize afterwards.
+func (s *Store) Reprice(p *Pricer, overridesFor func(Record) []Price, from, to int64) (RepriceResult, error) {
+	if p == nil {
+		p = DefaultPricer()
+	}
+	table := p.Table()
+	res := RepriceResult{Version: table.Version}
+	inRange := func(r *Record) bool {
+		return !(from > 0 && r.CreatedAt < from || to > 0 && r.CreatedAt > to)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.usageDir())
+	if os.IsNotExist(err) {
+		return res, nil
+	}
+	if err != nil {
+		return res, err
+	}
+	type monthFile struct {
+		path  string
+		lines []repriceLine
+	}
+	var files []monthFile
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".jsonl") {
+			continue
+		}
+		path := filepath.Join(s.usageDir(), e.Name())
+		lines, err := readRepriceLines(path)
+		if err != nil {
+			return res, err
+		}
+		files = append(files, monthFile{path, lines})
+	}
+	// Check before touching anything so a refusal leaves every file intact.
+	if !strings.EqualFold(table.Currency, "USD") {
+		for _, f := range files {
+			for _, l := range f.lines {
+				if l.rec != nil && l.rec.CostSource == CostSourceProvider && inRange(l.rec) {
+					return res, ErrMixedCurrency
+				}
+			}
+		}
+	}
+
+	for _, f := range files {
+		dirty := false
+		for _, l := range f.lines {
+			r := l.rec
+			if r == nil || !inRange(r) {
+				continue
+			}
+			res.Records++
+			res.OldTotal += r.Cost
+			if r.CostSource == CostSourceProvider {
+				res.Skipped++
+				res.NewTotal += r.Cost
+				continue
+			}
+			old, oldVer := r.Cost, r.PricingVersion
+			var ov []Price
+			if overridesFor != nil {
+				ov = overridesFor(*r)
+			}
+			p.PriceRecord(r, ov)
+			res.NewTotal += r.Cost
+			if r.Cost != old {
+				res.Changed++
+			}
+			if r.Cost != old || r.PricingVersion != oldVer {
+				dirty = true
+			}
+		}
+		if !dirty {
+			continue
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, l := range f.lines {
+			if l.rec == nil {
+				buf.Write(l.raw)
+				buf.WriteByte('\n')
+				continue
+			}
+			if err := enc.Encode(l.rec); err != nil {
+				return res, err
+			}
+		}
+		if err := persist.AtomicWrite(f.path, buf.Bytes(), 0o644); err != nil {
+			return res, err
+		}
+		res.Files++
+	}
+	return res, nil
+}
+
+// repriceLine is one non-empty line of a month file: rec is nil when raw
+// did not parse and must be written back untouched.
+type repriceLine struct {
+	raw []byte
+	rec *Record
+}
+
+func readRepriceLines(path string) ([]repriceLine, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []repriceLine
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 1<<20), 1<<20)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		l := repriceLine{raw: append([]byte(nil), line...)}
+		var r Record
+		if json.Unmarshal(line, &r) == nil {
+			l.rec = &r
+		}
+		out = append(out, l)
+	}
+	// A scan error (e.g. an over-long line) would otherwise truncate the
+	// file on rewrite.
+	return out, sc.Err()
+}
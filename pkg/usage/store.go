@@ -20,12 +20,26 @@ type Record struct {
 	SessionID    string  `json:"session_id,omitempty"` // 26.4.23v3+; empty for legacy rows
 	Provider     string  `json:"provider"`
 	Model        string  `json:"model"`
-	InputTokens  int     `json:"input_tokens"`
+	InputTokens  int     `json:"input_tokens"` // whole prompt, incl. cached tokens
 	OutputTokens int     `json:"output_tokens"`
 	Cost         float64 `json:"cost"`   // USD, estimated
 	CreatedAt    int64   `json:"created_at"` // Unix seconds
+
+	// Pricing inputs (empty on legacy rows, which Reprice treats as uncached).
+	CacheReadTokens  int    `json:"cache_read_tokens,omitempty"`
+	CacheWriteTokens int    `json:"cache_write_tokens,omitempty"`
+	ReasoningTokens  int    `json:"reasoning_tokens,omitempty"` // subset of OutputTokens, informational
+	ProviderID       string `json:"provider_id,omitempty"`     // config ProviderEntry.ID, for per-provider price overrides
+	PricingVersion   int    `json:"pricing_version,omitempty"` // PricingTable.Version used for Cost
+	CostSource       string `json:"cost_source,omitempty"`     // CostSourceTable / CostSourceProvider
 }
 
+// Record.CostSource values.
+const (
+	CostSourceTable    = "table"    // estimated from the pricing table
+	CostSourceProvider = "provider" // reported by the provider (e.g. OpenRouter usage.cost); never re-priced
+)
+
 // Store writes and reads usage JSONL files under dir/.usage/YYYY-MM.jsonl
 type Store struct {
 	dir string
@@ -55,6 +69,9 @@ func (s *Store) SetBudgetCharger(fn func(agentID string, costUSD float64)) {
 
 func (s *Store) usageDir() string { return filepath.Join(s.dir, ".usage") }
 
+// PricingPath is where the editable pricing table lives (see NewPricer).
+func (s *Store) PricingPath() string { return filepath.Join(s.usageDir(), "pricing.json") }
+
 func (s *Store) monthFile(t time.Time) string {
 	return filepath.Join(s.usageDir(), t.UTC().Format("2006-01")+".jsonl")
 }
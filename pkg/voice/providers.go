@@ -0,0 +1,185 @@
+package voice
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// ── Speech to text ────────────────────────────────────────────────────────
+
+// openAITranscriber calls an OpenAI-compatible /audio/transcriptions
+// endpoint (OpenAI, Groq, SiliconFlow, a local faster-whisper server, ...).
+type openAITranscriber struct {
+	base, apiKey, model string
+	client              *http.Client
+}
+
+func (t *openAITranscriber) Transcribe(ctx context.Context, audio []byte, fileName, contentType string) (string, error) {
+	body, ctype, err := multipartAudio(audio, fileName, contentType, map[string]string{
+		"model":           t.model,
+		"response_format": "json",
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.base+"/audio/transcriptions", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", ctype)
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	return transcript(t.client, req, "openai_stt")
+}
+
+// whisperCppTranscriber calls the whisper.cpp example server
+// (`whisper-server`), which takes the same multipart upload on /inference.
+type whisperCppTranscriber struct {
+	base, apiKey string
+	client       *http.Client
+}
+
+func (t *whisperCppTranscriber) Transcribe(ctx context.Context, audio []byte, fileName, contentType string) (string, error) {
+	body, ctype, err := multipartAudio(audio, fileName, contentType, map[string]string{
+		"response_format": "json",
+		"temperature":     "0.0",
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.base+"/inference", body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", ctype)
+	if t.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	}
+	return transcript(t.client, req, "whisper_cpp")
+}
+
+// multipartAudio builds the upload both STT APIs accept: the audio as
+// "file" plus plain form fields.
+func multipartAudio(audio []byte, fileName, contentType string, fields map[string]string) (*bytes.Buffer, string, error) {
+	if len(audio) == 0 {
+		return nil, "", fmt.Errorf("voice: empty audio")
+	}
+	if len(audio) > maxAudioBytes {
+		return nil, "", fmt.Errorf("voice: audio exceeds %d bytes", maxAudioBytes)
+	}
+	if fileName == "" {
+		fileName = "audio" + Ext(contentType)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k, v := range fields {
+		_ = mw.WriteField(k, v)
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, fileName))
+	h.Set("Content-Type", contentType)
+	fw, err := mw.CreatePart(h)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := fw.Write(audio); err != nil {
+		return nil, "", err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return &buf, mw.FormDataContentType(), nil
+}
+
+// transcript sends req and extracts {"text": "..."}.
+func transcript(client *http.Client, req *http.Request, provider string) (string, error) {
+	body, _, err := do(client, req)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", provider, err)
+	}
+	var out struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return "", fmt.Errorf("%s: parse response: %w", provider, err)
+	}
+	return strings.TrimSpace(out.Text), nil
+}
+
+// ── Text to speech ────────────────────────────────────────────────────────
+
+// openAISynthesizer calls an OpenAI-compatible /audio/speech endpoint.
+type openAISynthesizer struct {
+	base, apiKey, model, voice string
+	client                     *http.Client
+}
+
+func (s *openAISynthesizer) Synthesize(ctx context.Context, text, voice string) ([]byte, string, error) {
+	payload, _ := json.Marshal(map[string]string{
+		"model":           s.model,
+		"input":           clip(text),
+		"voice":           orDefault(voice, s.voice),
+		"response_format": "opus",
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.base+"/audio/speech", bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	return speech(s.client, req, "openai_tts")
+}
+
+// elevenLabsSynthesizer calls the ElevenLabs text-to-speech API; voice is
+// an ElevenLabs voice id.
+type elevenLabsSynthesizer struct {
+	base, apiKey, model, voice string
+	client                     *http.Client
+}
+
+func (s *elevenLabsSynthesizer) Synthesize(ctx context.Context, text, voice string) ([]byte, string, error) {
+	payload, _ := json.Marshal(map[string]string{
+		"text":     clip(text),
+		"model_id": s.model,
+	})
+	u := s.base + "/v1/text-to-speech/" + url.PathEscape(orDefault(voice, s.voice)) + "?output_format=opus_48000_64"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "audio/ogg")
+	req.Header.Set("xi-api-key", s.apiKey)
+	return speech(s.client, req, "elevenlabs")
+}
+
+// speech sends req and returns the audio. Both APIs are asked for Opus in
+// an Ogg container, whatever Content-Type they answer with.
+func speech(client *http.Client, req *http.Request, provider string) ([]byte, string, error) {
+	body, _, err := do(client, req)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: %w", provider, err)
+	}
+	if len(body) == 0 {
+		return nil, "", fmt.Errorf("%s: empty audio", provider)
+	}
+	return body, "audio/ogg", nil
+}
+
+// clip trims text to MaxSpeechChars runes.
+func clip(text string) string {
+	text = strings.TrimSpace(text)
+	if r := []rune(text); len(r) > MaxSpeechChars {
+		return string(r[:MaxSpeechChars])
+	}
+	return text
+}
@@ -0,0 +1,194 @@
+// Package voice provides speech-to-text and text-to-speech providers.
+//
+// Providers are configured as global tool entries (config.ToolEntry):
+//
+//	openai_stt  — OpenAI-compatible POST {baseUrl}/audio/transcriptions
+//	whisper_cpp — local whisper.cpp server, POST {baseUrl}/inference
+//	openai_tts  — OpenAI-compatible POST {baseUrl}/audio/speech
+//	elevenlabs  — ElevenLabs POST /v1/text-to-speech/{voice}
+//
+// Channels transcribe voice notes before running the agent; the tts tool
+// and per-channel voice replies use the synthesizer.
+package voice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+	"github.com/Zyling-ai/zyhive/pkg/netguard"
+)
+
+// requestTimeout caps one provider call (long voice notes take a while).
+const requestTimeout = 2 * time.Minute
+
+// maxAudioBytes caps provider responses and uploads.
+const maxAudioBytes = 25 << 20
+
+// MaxSpeechChars is the longest text sent to a synthesizer in one call
+// (OpenAI rejects input over 4096 characters).
+const MaxSpeechChars = 4000
+
+// ErrNotConfigured is returned when no enabled provider of a kind exists.
+var ErrNotConfigured = errors.New("voice: no speech provider configured")
+
+// Transcriber turns speech into text.
+type Transcriber interface {
+	// Transcribe returns the text spoken in audio. fileName carries the
+	// format hint ("voice.ogg"); contentType may be empty.
+	Transcribe(ctx context.Context, audio []byte, fileName, contentType string) (string, error)
+}
+
+// Synthesizer turns text into speech.
+type Synthesizer interface {
+	// Synthesize returns Ogg/Opus audio (playable as a Telegram or Feishu
+	// voice message) and its content type. voice overrides the configured
+	// voice ("" = default).
+	Synthesize(ctx context.Context, text, voice string) ([]byte, string, error)
+}
+
+// IsSTT reports whether a ToolEntry type is a speech-to-text provider.
+func IsSTT(typ string) bool { return typ == "openai_stt" || typ == "whisper_cpp" }
+
+// IsTTS reports whether a ToolEntry type is a text-to-speech provider.
+func IsTTS(typ string) bool { return typ == "openai_tts" || typ == "elevenlabs" }
+
+// usable reports whether e has the credentials its type needs
+// (whisper.cpp runs locally without a key, but needs its address).
+func usable(e config.ToolEntry) bool {
+	if !e.Enabled {
+		return false
+	}
+	if e.Type == "whisper_cpp" {
+		return e.BaseURL != ""
+	}
+	return e.APIKey != ""
+}
+
+// FromTools returns the first enabled, usable provider of each kind;
+// either may be nil.
+func FromTools(entries []config.ToolEntry) (Transcriber, Synthesizer) {
+	var stt Transcriber
+	var tts Synthesizer
+	for _, e := range entries {
+		if !usable(e) {
+			continue
+		}
+		if stt == nil && IsSTT(e.Type) {
+			stt = NewTranscriber(e)
+		}
+		if tts == nil && IsTTS(e.Type) {
+			tts = NewSynthesizer(e)
+		}
+	}
+	return stt, tts
+}
+
+// Live returns the providers configured in cfg right now, so keys added
+// in the panel apply without a restart.
+func Live(cfg *config.Config) (Transcriber, Synthesizer) {
+	snap, err := config.Snapshot(cfg)
+	if err != nil {
+		return nil, nil
+	}
+	return FromTools(snap.Tools)
+}
+
+// NewTranscriber builds the STT provider for e (nil for other types).
+func NewTranscriber(e config.ToolEntry) Transcriber {
+	switch e.Type {
+	case "openai_stt":
+		return &openAITranscriber{base: baseURL(e.BaseURL, "https://api.openai.com/v1"), apiKey: e.APIKey, model: orDefault(e.Model, "whisper-1"), client: newClient(e.BaseURL)}
+	case "whisper_cpp":
+		return &whisperCppTranscriber{base: baseURL(e.BaseURL, "http://127.0.0.1:8080"), apiKey: e.APIKey, client: newClient(e.BaseURL)}
+	}
+	return nil
+}
+
+// NewSynthesizer builds the TTS provider for e (nil for other types).
+func NewSynthesizer(e config.ToolEntry) Synthesizer {
+	switch e.Type {
+	case "openai_tts":
+		return &openAISynthesizer{base: baseURL(e.BaseURL, "https://api.openai.com/v1"), apiKey: e.APIKey, model: orDefault(e.Model, "tts-1"), voice: orDefault(e.Voice, "alloy"), client: newClient(e.BaseURL)}
+	case "elevenlabs":
+		return &elevenLabsSynthesizer{base: baseURL(e.BaseURL, "https://api.elevenlabs.io"), apiKey: e.APIKey, model: orDefault(e.Model, "eleven_multilingual_v2"), voice: orDefault(e.Voice, "21m00Tcm4TlvDq8ikWAM"), client: newClient(e.BaseURL)}
+	}
+	return nil
+}
+
+// newClient allows a loopback base URL (a local whisper.cpp or TTS server)
+// for its exact origin only; everything else must be public.
+func newClient(rawURL string) *http.Client {
+	if rawURL != "" {
+		if c, err := netguard.NewExactLoopbackClient(requestTimeout, rawURL); err == nil {
+			return c
+		}
+	}
+	return netguard.NewSafeClient(requestTimeout)
+}
+
+func baseURL(configured, def string) string {
+	if s := strings.TrimRight(strings.TrimSpace(configured), "/"); s != "" {
+		return s
+	}
+	return def
+}
+
+func orDefault(s, def string) string {
+	if s != "" {
+		return s
+	}
+	return def
+}
+
+// do sends req and returns the (capped) body of a 2xx response.
+func do(client *http.Client, req *http.Request) ([]byte, http.Header, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAudioBytes+1))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg := strings.TrimSpace(string(body))
+		if len(msg) > 300 {
+			msg = msg[:300]
+		}
+		return nil, nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, msg)
+	}
+	if len(body) > maxAudioBytes {
+		return nil, nil, fmt.Errorf("response exceeds %d bytes", maxAudioBytes)
+	}
+	return body, resp.Header, nil
+}
+
+// Ext returns the file extension (with dot) for an audio content type.
+func Ext(contentType string) string {
+	ct := strings.ToLower(contentType)
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = strings.TrimSpace(ct[:i])
+	}
+	switch ct {
+	case "audio/ogg", "audio/opus", "audio/oga":
+		return ".ogg"
+	case "audio/mpeg", "audio/mp3":
+		return ".mp3"
+	case "audio/mp4", "audio/m4a", "audio/x-m4a", "audio/aac":
+		return ".m4a"
+	case "audio/wav", "audio/x-wav", "audio/wave":
+		return ".wav"
+	case "audio/webm":
+		return ".webm"
+	case "audio/amr":
+		return ".amr"
+	}
+	return ".bin"
+}
@@ -0,0 +1,99 @@
+package voice
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Zyling-ai/zyhive/pkg/config"
+)
+
+func TestFromTools(t *testing.T) {
+	stt, tts := FromTools([]config.ToolEntry{
+		{Type: "brave_search", APIKey: "k", Enabled: true},
+		{Type: "openai_stt", APIKey: "k", Enabled: false},
+		{Type: "openai_tts", Enabled: true}, // no key
+		{Type: "whisper_cpp", BaseURL: "http://127.0.0.1:8080", Enabled: true},
+		{Type: "elevenlabs", APIKey: "k", Enabled: true},
+	})
+	if _, ok := stt.(*whisperCppTranscriber); !ok {
+		t.Errorf("stt = %T", stt)
+	}
+	if _, ok := tts.(*elevenLabsSynthesizer); !ok {
+		t.Errorf("tts = %T", tts)
+	}
+	if stt, tts := FromTools(nil); stt != nil || tts != nil {
+		t.Errorf("empty config gave %T / %T", stt, tts)
+	}
+}
+
+func TestTranscribers(t *testing.T) {
+	var path, auth, model, file string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		model = r.FormValue("model")
+		if f, h, err := r.FormFile("file"); err == nil {
+			data, _ := io.ReadAll(f)
+			file = h.Filename + ":" + string(data)
+		}
+		_, _ = w.Write([]byte(`{"text":" 你好 \n"}`))
+	}))
+	defer srv.Close()
+
+	openai := NewTranscriber(config.ToolEntry{Type: "openai_stt", APIKey: "sk", BaseURL: srv.URL + "/v1/"})
+	text, err := openai.Transcribe(context.Background(), []byte("OggS"), "voice.ogg", "audio/ogg")
+	if err != nil || text != "你好" {
+		t.Fatalf("openai = %q, %v", text, err)
+	}
+	if path != "/v1/audio/transcriptions" || auth != "Bearer sk" || model != "whisper-1" || file != "voice.ogg:OggS" {
+		t.Errorf("openai request: %s %q %q %q", path, auth, model, file)
+	}
+
+	local := NewTranscriber(config.ToolEntry{Type: "whisper_cpp", BaseURL: srv.URL})
+	if text, err := local.Transcribe(context.Background(), []byte("RIFF"), "", "audio/wav"); err != nil || text != "你好" {
+		t.Fatalf("whisper.cpp = %q, %v", text, err)
+	}
+	if path != "/inference" || auth != "" || file != "audio.wav:RIFF" {
+		t.Errorf("whisper.cpp request: %s %q %q", path, auth, file)
+	}
+}
+
+func TestSynthesizers(t *testing.T) {
+	var path, auth, query string
+	var body map[string]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query = r.URL.Path, r.URL.RawQuery
+		auth = r.Header.Get("Authorization") + r.Header.Get("xi-api-key")
+		body = nil
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body["input"] == "fail" {
+			http.Error(w, `{"error":"quota"}`, http.StatusTooManyRequests)
+			return
+		}
+		_, _ = w.Write([]byte("OggS-audio"))
+	}))
+	defer srv.Close()
+
+	openai := NewSynthesizer(config.ToolEntry{Type: "openai_tts", APIKey: "sk", BaseURL: srv.URL + "/v1", Voice: "nova"})
+	audio, ct, err := openai.Synthesize(context.Background(), "hello", "")
+	if err != nil || string(audio) != "OggS-audio" || ct != "audio/ogg" {
+		t.Fatalf("openai = %q %q %v", audio, ct, err)
+	}
+	if path != "/v1/audio/speech" || auth != "Bearer sk" || body["voice"] != "nova" || body["model"] != "tts-1" || body["response_format"] != "opus" {
+		t.Errorf("openai request: %s %q %v", path, auth, body)
+	}
+	if _, _, err := openai.Synthesize(context.Background(), "fail", "echo"); err == nil {
+		t.Error("429 not reported")
+	}
+
+	eleven := NewSynthesizer(config.ToolEntry{Type: "elevenlabs", APIKey: "xi", BaseURL: srv.URL})
+	if _, _, err := eleven.Synthesize(context.Background(), "hi", "voice-1"); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/text-to-speech/voice-1" || query != "output_format=opus_48000_64" || auth != "xi" || body["text"] != "hi" {
+		t.Errorf("elevenlabs request: %s?%s %q %v", path, query, auth, body)
+	}
+}
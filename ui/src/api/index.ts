@@ -57,6 +57,14 @@ export interface AgentInfo {
   env?: Record<string, string>  // per-agent env vars for exec tool
   heartbeat?: HeartbeatConfig   // built-in heartbeat config
   toolPolicy?: ToolPolicy       // per-agent tool permission policy
+  generation?: GenerationParams // per-agent sampling overrides (over the model's)
+}
+
+export interface GenerationParams {
+  temperature?: number
+  topP?: number
+  stop?: string[]
+  thinkingBudget?: number // extended-thinking / reasoning token budget; 0 = off
 }
 
 export interface ProviderEntry {
@@ -68,6 +76,38 @@ export interface ProviderEntry {
   embedModel?: string // 覆盖默认 embedding 模型（可选）
   status: string      // "ok" | "error" | "untested"
   modelCount: number
+  pricing?: PriceEntry[] // 覆盖全局价格表（match 为空 = 该 provider 全部模型）
+}
+
+// 价格表条目：每百万 tokens；cacheRead/cacheWrite 为 0 时按 input 计
+export interface PriceEntry {
+  match?: string
+  provider?: string
+  input: number
+  output: number
+  cacheRead?: number
+  cacheWrite?: number
+  currency?: string
+  effectiveFrom?: string // YYYY-MM-DD
+}
+
+export interface PricingTable {
+  version: number
+  updatedAt?: number
+  currency: string
+  rates?: Record<string, number> // 1 单位表货币 = N 单位该货币
+  fallback: PriceEntry
+  prices: PriceEntry[]
+}
+
+export interface RepriceResult {
+  files: number
+  records: number
+  changed: number
+  skipped: number
+  oldTotal: number
+  newTotal: number
+  version: number
 }
 
 export interface ModelEntry {
@@ -81,10 +121,27 @@ export interface ModelEntry {
   isDefault: boolean
   status: string // "ok" | "error" | "untested"
   supportsTools?: boolean // false = 不支持工具调用（如 deepseek-reasoner）
+  fallbacks?: string[] // 备用模型 ID，主模型不可用时按顺序切换
+  generation?: GenerationParams // 默认采样参数，成员可覆盖
+  capabilities?: Partial<ModelCaps> // 覆盖内置能力表
+  api?: 'chat' | 'responses' // 仅 openai：/chat/completions（默认）或 /responses
+  chainResponses?: boolean // responses：工具循环用 previous_response_id 续接
+  /** 内置能力表 + capabilities 覆盖后的最终能力（后端计算，只读） */
+  resolvedCapabilities?: ModelCaps
   /** 绑定 provider 的测试状态（后端 join 附加） */
   providerStatus?: string // "ok" | "error" | "untested"
 }
 
+export interface ModelCaps {
+  contextWindow: number // tokens
+  maxOutput: number
+  vision: boolean
+  tools: boolean
+  promptCaching: boolean
+  reasoning: boolean
+  responsesOnly: boolean // 仅 OpenAI Responses API 可用
+}
+
 export interface ProbeModelInfo {
   id: string
   name: string
@@ -112,6 +169,8 @@ export interface ToolEntry {
   type: string
   apiKey: string
   baseUrl?: string
+  model?: string
+  voice?: string
   enabled: boolean
   status: string
 }
@@ -216,6 +275,19 @@ export const agentChannels = {
   allowUser: (agentId: string, chId: string, userId: number | string) => api.post(`/agents/${agentId}/channels/${chId}/pending/${userId}/allow`),
   dismissUser: (agentId: string, chId: string, userId: number | string) => api.delete(`/agents/${agentId}/channels/${chId}/pending/${userId}`),
   removeAllowed: (agentId: string, chId: string, userId: number | string) => api.delete(`/agents/${agentId}/channels/${chId}/allowed/${userId}`),
+  // Outbox (email approveReplies): replies waiting for approval
+  listOutbox: (agentId: string, chId: string) => api.get<OutboxDraft[]>(`/agents/${agentId}/channels/${chId}/outbox`),
+  approveDraft: (agentId: string, chId: string, draftId: string, thread = false) => api.post(`/agents/${agentId}/channels/${chId}/outbox/${draftId}/approve`, { thread }),
+  discardDraft: (agentId: string, chId: string, draftId: string) => api.delete(`/agents/${agentId}/channels/${chId}/outbox/${draftId}`),
+}
+
+export interface OutboxDraft {
+  id: string
+  threadKey: string
+  to: string[]
+  subject: string
+  body: string
+  createdAt: number
 }
 
 export interface PendingUser {
@@ -425,6 +497,28 @@ export interface FeishuProbeResult {
   }>
 }
 
+// Telegram per-channel status: receive mode + getWebhookInfo health.
+export interface TelegramProbeResult {
+  ok: boolean
+  error?: 'auth_failed' | 'network' | 'unknown' | ''
+  bot: {
+    id?: number
+    username?: string
+  }
+  mode: 'webhook' | 'polling' | ''
+  expectedUrl?: string
+  webhook?: {
+    url: string
+    pending_update_count: number
+    ip_address?: string
+    last_error_date?: number
+    last_error_message?: string
+    max_connections?: number
+  }
+  healthy: boolean
+  issues?: string[]
+}
+
 // B-03 (26.5.12v1) — aggregated cross-agent views.
 export interface ContactPerAgent {
   agentId: string
@@ -899,6 +993,7 @@ export interface TaskInfo {
   startedAt?: number
   endedAt?: number
   duration?: string
+  traceId?: string  // GET /traces/:traceId
 }
 
 export interface EligibleTarget {
@@ -1051,6 +1146,165 @@ export const usageApi = {
   summary: (params: Record<string, any>) => api.get('/usage/summary', { params }),
   timeline: (params: Record<string, any>) => api.get('/usage/timeline', { params }),
   records: (params: Record<string, any>) => api.get('/usage/records', { params }),
+  pricing: () => api.get<{ pricing: PricingTable }>('/usage/pricing'),
+  updatePricing: (table: PricingTable) => api.put<{ pricing: PricingTable }>('/usage/pricing', table),
+  reprice: (params?: { from?: number; to?: number }) => api.post<{ result: RepriceResult }>('/usage/reprice', null, { params }),
+}
+
+export interface TraceSpan {
+  traceId: string
+  spanId: string
+  parentId?: string
+  name: string
+  kind: 'run' | 'llm' | 'tool' | 'subagent' | 'cron' | 'channel'
+  agentId?: string
+  sessionId?: string
+  startMs: number
+  durationMs: number
+  status: 'ok' | 'error'
+  error?: string
+  provider?: string
+  model?: string
+  tool?: string
+  inputTokens?: number
+  outputTokens?: number
+  attrs?: Record<string, string>
+}
+
+export interface TraceNode extends TraceSpan {
+  children?: TraceNode[]
+}
+
+export interface TraceDetail {
+  traceId: string
+  startMs: number
+  durationMs: number
+  spans: TraceSpan[]
+  tree: TraceNode[]
+}
+
+export const tracesApi = {
+  get: (traceId: string) => api.get<TraceDetail>(`/traces/${encodeURIComponent(traceId)}`),
+}
+
+// ── Offline evals ────────────────────────────────────────────────────────────
+
+export interface EvalExpectation {
+  type: 'exact' | 'contains' | 'regex' | 'json_schema' | 'tool_called' | 'tool_not_called' | 'llm_judge'
+  turn?: number            // 1-based; 0/omitted = last turn
+  value?: string
+  schema?: Record<string, unknown>
+  tool?: string
+  inputContains?: string
+  resultContains?: string
+  minCalls?: number
+  rubric?: string
+  minScore?: number
+}
+
+export interface EvalScriptTurn {
+  text?: string
+  toolCalls?: { name: string; input?: Record<string, unknown> }[]
+  error?: string
+  inputTokens?: number
+  outputTokens?: number
+}
+
+export interface EvalCase {
+  id: string
+  name?: string
+  turns: string[]
+  expect: EvalExpectation[]
+  script?: EvalScriptTurn[]
+}
+
+export interface EvalDataset {
+  id: string
+  name: string
+  description?: string
+  agentId?: string
+  cases: EvalCase[]
+  createdAt: number
+  updatedAt: number
+}
+
+export interface EvalGrade {
+  type: string
+  pass: boolean
+  score?: number
+  detail?: string
+}
+
+export interface EvalTurnResult {
+  input: string
+  output: string
+  toolCalls?: string[]
+  inputTokens?: number
+  outputTokens?: number
+  durationMs: number
+  error?: string
+}
+
+export interface EvalCaseResult {
+  caseId: string
+  name?: string
+  sessionId: string
+  pass: boolean
+  turns: EvalTurnResult[]
+  grades: EvalGrade[]
+  error?: string
+  durationMs: number
+}
+
+export interface EvalRun {
+  id: string
+  datasetId: string
+  datasetName?: string
+  agentId: string
+  model?: string
+  label?: string
+  status: 'running' | 'done' | 'failed'
+  startedAt: number
+  endedAt?: number
+  cases?: EvalCaseResult[]
+  summary: {
+    total: number
+    passed: number
+    failed: number
+    errored: number
+    passRate: number
+    inputTokens: number
+    outputTokens: number
+  }
+  error?: string
+}
+
+export interface EvalComparison {
+  base: EvalRun
+  head: EvalRun
+  cases: {
+    caseId: string
+    name?: string
+    base?: EvalCaseResult
+    head?: EvalCaseResult
+    change: 'same' | 'fixed' | 'regressed' | 'added' | 'removed'
+  }[]
+  regressions: number
+  fixes: number
+}
+
+export const evalsApi = {
+  listDatasets: () => api.get<EvalDataset[]>('/evals/datasets'),
+  getDataset: (id: string) => api.get<EvalDataset>(`/evals/datasets/${encodeURIComponent(id)}`),
+  createDataset: (ds: Partial<EvalDataset>) => api.post<EvalDataset>('/evals/datasets', ds),
+  updateDataset: (id: string, ds: Partial<EvalDataset>) =>
+    api.put<EvalDataset>(`/evals/datasets/${encodeURIComponent(id)}`, ds),
+  deleteDataset: (id: string) => api.delete(`/evals/datasets/${encodeURIComponent(id)}`),
+  startRun: (req: { datasetId: string; agentId?: string; model?: string; label?: string; caseIds?: string[] }) =>
+    api.post<EvalRun>('/evals/runs', req),
+  listRuns: (datasetId?: string) => api.get<EvalRun[]>('/evals/runs', { params: datasetId ? { datasetId } : undefined }),
+  getRun: (id: string) => api.get<EvalRun>(`/evals/runs/${encodeURIComponent(id)}`),
+  compare: (base: string, head: string) => api.get<EvalComparison>('/evals/compare', { params: { base, head } }),
 }
 
 export default api